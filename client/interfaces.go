@@ -64,12 +64,15 @@ type ContainerServer interface {
 	// Server functions
 	GetServer() (server *api.Server, ETag string, err error)
 	GetServerResources() (resources *api.Resources, err error)
+	GetMetadataConfiguration() (metadata *api.MetadataConfiguration, err error)
+	EmergencyShutdown() (op Operation, err error)
 	UpdateServer(server api.ServerPut, ETag string) (err error)
 	HasExtension(extension string) (exists bool)
 	RequireAuthenticated(authenticated bool)
 	IsClustered() (clustered bool)
 	UseTarget(name string) (client ContainerServer)
 	UseProject(name string) (client ContainerServer)
+	Search(query string) (results []api.SearchResult, err error)
 
 	// Certificate functions
 	GetCertificateFingerprints() (fingerprints []string, err error)
@@ -91,15 +94,43 @@ type ContainerServer interface {
 	RenameContainer(name string, container api.ContainerPost) (op Operation, err error)
 	MigrateContainer(name string, container api.ContainerPost) (op Operation, err error)
 	DeleteContainer(name string) (op Operation, err error)
+	ApplyContainer(name string, spec api.ContainerApplyPost) (op Operation, err error)
+	GetContainerApplyPlan(name string, spec api.ContainerApplyPost) (steps []api.ContainerApplyStep, err error)
 
 	ExecContainer(containerName string, exec api.ContainerExecPost, args *ContainerExecArgs) (op Operation, err error)
+	GetContainerExecSessions(containerName string) (sessions []api.ContainerExecSession, err error)
+	GetContainerTasks(containerName string) (tasks []api.ContainerTask, err error)
 	ConsoleContainer(containerName string, console api.ContainerConsolePost, args *ContainerConsoleArgs) (op Operation, err error)
 	GetContainerConsoleLog(containerName string, args *ContainerConsoleLogArgs) (content io.ReadCloser, err error)
 	DeleteContainerConsoleLog(containerName string, args *ContainerConsoleLogArgs) (err error)
 
+	GetContainerDeviceLog(containerName string) (entries []api.ContainerDeviceLogEntry, err error)
+
 	GetContainerFile(containerName string, path string) (content io.ReadCloser, resp *ContainerFileResponse, err error)
 	CreateContainerFile(containerName string, path string, args ContainerFileArgs) (err error)
 	DeleteContainerFile(containerName string, path string) (err error)
+	SyncContainerFiles(containerName string, sync api.ContainerFilesSyncPost) (op Operation, err error)
+	TestContainerSecurity(containerName string, test api.ContainerSecurityTestPost) (op Operation, err error)
+	RebuildContainer(containerName string, rebuild api.ContainerRebuildPost) (op Operation, err error)
+	RebuildContainerIdmap(containerName string) (op Operation, err error)
+	RespawnContainer(containerName string, respawn api.ContainerRespawnPost) (op Operation, err error)
+	ReapplyContainerNetwork(containerName string, reapply api.ContainerNetworkReapplyPost) (op Operation, err error)
+	GetContainerProcesses(containerName string) (processes *api.ContainerProcesses, err error)
+	GetContainerRevisions(containerName string) (revisions []api.ContainerRevision, err error)
+	RollbackContainer(containerName string, rollback api.ContainerRevisionsPost) (op Operation, err error)
+	GetContainerConfigSnapshots(containerName string) (snapshots []api.ContainerConfigSnapshot, err error)
+	GetContainerConfigSnapshot(containerName string, name string) (snapshot *api.ContainerConfigSnapshot, err error)
+	CreateContainerConfigSnapshot(containerName string, snapshot api.ContainerConfigSnapshotsPost) (err error)
+	RestoreContainerConfigSnapshot(containerName string, name string) (op Operation, err error)
+	DeleteContainerConfigSnapshot(containerName string, name string) (err error)
+	GetContainerCheckpoints(containerName string) (checkpoints []api.ContainerCheckpoint, err error)
+	CreateContainerCheckpoint(containerName string, checkpoint api.ContainerCheckpointsPost) (op Operation, err error)
+	RestoreContainerCheckpoint(containerName string, name string) (op Operation, err error)
+	DeleteContainerCheckpoint(containerName string, name string) (op Operation, err error)
+	GetContainerSecretNames(containerName string) (names []string, err error)
+	SetContainerSecret(containerName string, name string, secret api.ContainerSecretPut) (err error)
+	DeleteContainerSecret(containerName string, name string) (err error)
+	GetContainerSecurityDenials(containerName string) (denials []api.ContainerSecurityDenial, err error)
 
 	GetContainerSnapshotNames(containerName string) (names []string, err error)
 	GetContainerSnapshots(containerName string) (snapshots []api.ContainerSnapshot, err error)
@@ -118,8 +149,15 @@ type ContainerServer interface {
 	RenameContainerBackup(containerName string, name string, backup api.ContainerBackupPost) (op Operation, err error)
 	DeleteContainerBackup(containerName string, name string) (op Operation, err error)
 	GetContainerBackupFile(containerName string, name string, req *BackupFileRequest) (resp *BackupFileResponse, err error)
+	GetContainerBackupChunk(containerName string, name string, index int, chunk io.Writer) (size int64, err error)
 	CreateContainerFromBackup(args ContainerBackupArgs) (op Operation, err error)
 
+	CreateBackupUpload(upload api.BackupUploadsPost) (backupUpload *api.BackupUpload, err error)
+	GetBackupUpload(id string) (backupUpload *api.BackupUpload, err error)
+	UploadBackupChunk(id string, index int, chunk io.Reader) (err error)
+	CreateContainerFromBackupUpload(id string, req api.BackupUploadPost) (op Operation, err error)
+	DeleteBackupUpload(id string) (err error)
+
 	GetContainerState(name string) (state *api.ContainerState, ETag string, err error)
 	UpdateContainerState(name string, state api.ContainerStatePut, ETag string) (op Operation, err error)
 
@@ -145,18 +183,42 @@ type ContainerServer interface {
 	UpdateImage(fingerprint string, image api.ImagePut, ETag string) (err error)
 	DeleteImage(fingerprint string) (op Operation, err error)
 	RefreshImage(fingerprint string) (op Operation, err error)
+	GetImageDownloads() (downloads *api.ImageDownloads, err error)
+	GetImageCatalog(fingerprints []string) (catalog *api.ImageCatalog, err error)
+	ImportImageCatalog(req api.ImageCatalogPost) (op Operation, err error)
 	CreateImageSecret(fingerprint string) (op Operation, err error)
 	CreateImageAlias(alias api.ImageAliasesPost) (err error)
 	UpdateImageAlias(name string, alias api.ImageAliasesEntryPut, ETag string) (err error)
 	RenameImageAlias(name string, alias api.ImageAliasesEntryPost) (err error)
 	DeleteImageAlias(name string) (err error)
 
+	// AppArmor profile functions ("apparmor_profiles" API extension)
+	GetApparmorProfileNames() (names []string, err error)
+	GetApparmorProfiles() (profiles []api.ApparmorProfile, err error)
+	GetApparmorProfile(name string) (profile *api.ApparmorProfile, ETag string, err error)
+	CreateApparmorProfile(profile api.ApparmorProfilesPost) (err error)
+	UpdateApparmorProfile(name string, profile api.ApparmorProfilePut, ETag string) (err error)
+	DeleteApparmorProfile(name string) (err error)
+
+	// Load-balancer functions ("load_balancers" API extension)
+	GetLoadBalancerNames() (names []string, err error)
+	GetLoadBalancers() (loadBalancers []api.LoadBalancer, err error)
+	GetLoadBalancer(name string) (loadBalancer *api.LoadBalancer, ETag string, err error)
+	CreateLoadBalancer(loadBalancer api.LoadBalancersPost) (err error)
+	UpdateLoadBalancer(name string, loadBalancer api.LoadBalancerPut, ETag string) (err error)
+	DeleteLoadBalancer(name string) (err error)
+
+	// MAAS functions ("maas_reconcile" API extension)
+	GetMAASSync() (entries []api.MAASSyncEntry, err error)
+	ReconcileMAAS(req api.MAASReconcilePost) (op Operation, err error)
+
 	// Network functions ("network" API extension)
 	GetNetworkNames() (names []string, err error)
 	GetNetworks() (networks []api.Network, err error)
 	GetNetwork(name string) (network *api.Network, ETag string, err error)
 	GetNetworkLeases(name string) (leases []api.NetworkLease, err error)
 	GetNetworkState(name string) (state *api.NetworkState, err error)
+	GetNetworkTopology() (topology *api.NetworkTopology, err error)
 	CreateNetwork(network api.NetworksPost) (err error)
 	UpdateNetwork(name string, network api.NetworkPut, ETag string) (err error)
 	RenameNetwork(name string, network api.NetworkPost) (err error)
@@ -178,6 +240,7 @@ type ContainerServer interface {
 	UpdateProfile(name string, profile api.ProfilePut, ETag string) (err error)
 	RenameProfile(name string, profile api.ProfilePost) (err error)
 	DeleteProfile(name string) (err error)
+	RestartProfileInstances(name string, req api.ProfileInstancesRestartPost) (op Operation, err error)
 
 	// Project functions
 	GetProjectNames() (names []string, err error)
@@ -185,6 +248,7 @@ type ContainerServer interface {
 	GetProject(name string) (project *api.Project, ETag string, err error)
 	CreateProject(project api.ProjectsPost) (err error)
 	UpdateProject(name string, project api.ProjectPut, ETag string) (err error)
+	UpdateProjectContainersProtection(name string, protection api.ProjectContainersProtectionPut) (err error)
 	RenameProject(name string, project api.ProjectPost) (op Operation, err error)
 	DeleteProject(name string) (err error)
 
@@ -320,6 +384,12 @@ type ImageFileResponse struct {
 
 	// Size of the rootfs file
 	RootfsSize int64
+
+	// Fingerprint of the image the downloaded rootfs delta applied to, if any
+	RootfsDeltaBase string
+
+	// Size of the downloaded rootfs delta
+	RootfsDeltaSize int64
 }
 
 // The ImageCopyArgs struct is used to pass additional options during image copy
@@ -0,0 +1,112 @@
+package lxd
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+// GetApparmorProfileNames returns a list of AppArmor profile names
+func (r *ProtocolLXD) GetApparmorProfileNames() ([]string, error) {
+	if !r.HasExtension("apparmor_profiles") {
+		return nil, fmt.Errorf("The server is missing the required \"apparmor_profiles\" API extension")
+	}
+
+	urls := []string{}
+
+	// Fetch the raw value
+	_, err := r.queryStruct("GET", "/apparmor-profiles", nil, "", &urls)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse it
+	names := []string{}
+	for _, url := range urls {
+		fields := strings.Split(url, "/apparmor-profiles/")
+		names = append(names, fields[len(fields)-1])
+	}
+
+	return names, nil
+}
+
+// GetApparmorProfiles returns a list of ApparmorProfile struct
+func (r *ProtocolLXD) GetApparmorProfiles() ([]api.ApparmorProfile, error) {
+	if !r.HasExtension("apparmor_profiles") {
+		return nil, fmt.Errorf("The server is missing the required \"apparmor_profiles\" API extension")
+	}
+
+	apparmorProfiles := []api.ApparmorProfile{}
+
+	// Fetch the raw value
+	_, err := r.queryStruct("GET", "/apparmor-profiles?recursion=1", nil, "", &apparmorProfiles)
+	if err != nil {
+		return nil, err
+	}
+
+	return apparmorProfiles, nil
+}
+
+// GetApparmorProfile returns a ApparmorProfile entry for the provided name
+func (r *ProtocolLXD) GetApparmorProfile(name string) (*api.ApparmorProfile, string, error) {
+	if !r.HasExtension("apparmor_profiles") {
+		return nil, "", fmt.Errorf("The server is missing the required \"apparmor_profiles\" API extension")
+	}
+
+	apparmorProfile := api.ApparmorProfile{}
+
+	// Fetch the raw value
+	etag, err := r.queryStruct("GET", fmt.Sprintf("/apparmor-profiles/%s", url.QueryEscape(name)), nil, "", &apparmorProfile)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &apparmorProfile, etag, nil
+}
+
+// CreateApparmorProfile defines a new AppArmor profile using the provided ApparmorProfile struct
+func (r *ProtocolLXD) CreateApparmorProfile(apparmorProfile api.ApparmorProfilesPost) error {
+	if !r.HasExtension("apparmor_profiles") {
+		return fmt.Errorf("The server is missing the required \"apparmor_profiles\" API extension")
+	}
+
+	// Send the request
+	_, _, err := r.query("POST", "/apparmor-profiles", apparmorProfile, "")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UpdateApparmorProfile updates the AppArmor profile to match the provided ApparmorProfile struct
+func (r *ProtocolLXD) UpdateApparmorProfile(name string, apparmorProfile api.ApparmorProfilePut, ETag string) error {
+	if !r.HasExtension("apparmor_profiles") {
+		return fmt.Errorf("The server is missing the required \"apparmor_profiles\" API extension")
+	}
+
+	// Send the request
+	_, _, err := r.query("PUT", fmt.Sprintf("/apparmor-profiles/%s", url.QueryEscape(name)), apparmorProfile, ETag)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteApparmorProfile deletes an existing AppArmor profile
+func (r *ProtocolLXD) DeleteApparmorProfile(name string) error {
+	if !r.HasExtension("apparmor_profiles") {
+		return fmt.Errorf("The server is missing the required \"apparmor_profiles\" API extension")
+	}
+
+	// Send the request
+	_, _, err := r.query("DELETE", fmt.Sprintf("/apparmor-profiles/%s", url.QueryEscape(name)), nil, "")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
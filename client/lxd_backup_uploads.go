@@ -0,0 +1,91 @@
+package lxd
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+// CreateBackupUpload starts uploading a backup tarball in chunks
+func (r *ProtocolLXD) CreateBackupUpload(upload api.BackupUploadsPost) (*api.BackupUpload, error) {
+	if !r.HasExtension("container_backup_chunks") {
+		return nil, fmt.Errorf("The server is missing the required \"container_backup_chunks\" API extension")
+	}
+
+	backupUpload := api.BackupUpload{}
+
+	// Send the request
+	_, err := r.queryStruct("POST", "/backup-uploads", upload, "", &backupUpload)
+	if err != nil {
+		return nil, err
+	}
+
+	return &backupUpload, nil
+}
+
+// GetBackupUpload returns the progress of a backup upload, including the
+// chunks still missing
+func (r *ProtocolLXD) GetBackupUpload(id string) (*api.BackupUpload, error) {
+	if !r.HasExtension("container_backup_chunks") {
+		return nil, fmt.Errorf("The server is missing the required \"container_backup_chunks\" API extension")
+	}
+
+	backupUpload := api.BackupUpload{}
+
+	// Fetch the raw value
+	_, err := r.queryStruct("GET", fmt.Sprintf("/backup-uploads/%s", url.QueryEscape(id)), nil, "", &backupUpload)
+	if err != nil {
+		return nil, err
+	}
+
+	return &backupUpload, nil
+}
+
+// UploadBackupChunk sends a chunk of a backup upload, which may be sent
+// again if it failed
+func (r *ProtocolLXD) UploadBackupChunk(id string, index int, chunk io.Reader) error {
+	if !r.HasExtension("container_backup_chunks") {
+		return fmt.Errorf("The server is missing the required \"container_backup_chunks\" API extension")
+	}
+
+	// Send the request
+	_, _, err := r.query("PUT", fmt.Sprintf("/backup-uploads/%s/chunks/%d", url.QueryEscape(id), index), chunk, "")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// CreateContainerFromBackupUpload creates a container from a complete backup
+// upload
+func (r *ProtocolLXD) CreateContainerFromBackupUpload(id string, req api.BackupUploadPost) (Operation, error) {
+	if !r.HasExtension("container_backup_chunks") {
+		return nil, fmt.Errorf("The server is missing the required \"container_backup_chunks\" API extension")
+	}
+
+	// Send the request
+	op, _, err := r.queryOperation("POST", fmt.Sprintf("/backup-uploads/%s", url.QueryEscape(id)), req, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return op, nil
+}
+
+// DeleteBackupUpload aborts a backup upload
+func (r *ProtocolLXD) DeleteBackupUpload(id string) error {
+	if !r.HasExtension("container_backup_chunks") {
+		return fmt.Errorf("The server is missing the required \"container_backup_chunks\" API extension")
+	}
+
+	// Send the request
+	_, _, err := r.query("DELETE", fmt.Sprintf("/backup-uploads/%s", url.QueryEscape(id)), nil, "")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
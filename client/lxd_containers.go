@@ -1,6 +1,7 @@
 package lxd
 
 import (
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -694,6 +695,44 @@ func (r *ProtocolLXD) DeleteContainer(name string) (Operation, error) {
 	return op, nil
 }
 
+// ApplyContainer requests that LXD brings the container to the desired state
+func (r *ProtocolLXD) ApplyContainer(name string, spec api.ContainerApplyPost) (Operation, error) {
+	if !r.HasExtension("container_apply") {
+		return nil, fmt.Errorf("The server is missing the required \"container_apply\" API extension")
+	}
+
+	// Sanity check
+	if spec.DryRun {
+		return nil, fmt.Errorf("Can't ask for a dry run through ApplyContainer")
+	}
+
+	// Send the request
+	op, _, err := r.queryOperation("POST", fmt.Sprintf("/containers/%s/apply", url.QueryEscape(name)), spec, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return op, nil
+}
+
+// GetContainerApplyPlan returns the steps needed to bring the container to the desired state
+func (r *ProtocolLXD) GetContainerApplyPlan(name string, spec api.ContainerApplyPost) ([]api.ContainerApplyStep, error) {
+	if !r.HasExtension("container_apply") {
+		return nil, fmt.Errorf("The server is missing the required \"container_apply\" API extension")
+	}
+
+	steps := []api.ContainerApplyStep{}
+	spec.DryRun = true
+
+	// Fetch the raw value
+	_, err := r.queryStruct("POST", fmt.Sprintf("/containers/%s/apply", url.QueryEscape(name)), spec, "", &steps)
+	if err != nil {
+		return nil, err
+	}
+
+	return steps, nil
+}
+
 // ExecContainer requests that LXD spawns a command inside the container
 func (r *ProtocolLXD) ExecContainer(containerName string, exec api.ContainerExecPost, args *ContainerExecArgs) (Operation, error) {
 	if exec.RecordOutput {
@@ -837,6 +876,40 @@ func (r *ProtocolLXD) ExecContainer(containerName string, exec api.ContainerExec
 	return op, nil
 }
 
+// GetContainerExecSessions returns the commands currently running in a container through exec
+func (r *ProtocolLXD) GetContainerExecSessions(containerName string) ([]api.ContainerExecSession, error) {
+	if !r.HasExtension("container_exec_sessions") {
+		return nil, fmt.Errorf("The server is missing the required \"container_exec_sessions\" API extension")
+	}
+
+	sessions := []api.ContainerExecSession{}
+
+	// Fetch the raw value
+	_, err := r.queryStruct("GET", fmt.Sprintf("/containers/%s/exec/sessions", url.QueryEscape(containerName)), nil, "", &sessions)
+	if err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// GetContainerTasks returns the periodic commands of the container along with their last runs
+func (r *ProtocolLXD) GetContainerTasks(containerName string) ([]api.ContainerTask, error) {
+	if !r.HasExtension("container_tasks") {
+		return nil, fmt.Errorf("The server is missing the required \"container_tasks\" API extension")
+	}
+
+	tasks := []api.ContainerTask{}
+
+	// Fetch the raw value
+	_, err := r.queryStruct("GET", fmt.Sprintf("/containers/%s/tasks", url.QueryEscape(containerName)), nil, "", &tasks)
+	if err != nil {
+		return nil, err
+	}
+
+	return tasks, nil
+}
+
 // GetContainerFile retrieves the provided path from the container
 func (r *ProtocolLXD) GetContainerFile(containerName string, path string) (io.ReadCloser, *ContainerFileResponse, error) {
 	// Prepare the HTTP request
@@ -999,6 +1072,351 @@ func (r *ProtocolLXD) DeleteContainerFile(containerName string, path string) err
 	return nil
 }
 
+// SyncContainerFiles copies a path from another container on the same node
+func (r *ProtocolLXD) SyncContainerFiles(containerName string, sync api.ContainerFilesSyncPost) (Operation, error) {
+	if !r.HasExtension("container_files_sync") {
+		return nil, fmt.Errorf("The server is missing the required \"container_files_sync\" API extension")
+	}
+
+	// Send the request
+	op, _, err := r.queryOperation("POST", fmt.Sprintf("/containers/%s/files/sync", url.QueryEscape(containerName)), sync, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return op, nil
+}
+
+// TestContainerSecurity tries a security policy on a running container without enforcing it
+func (r *ProtocolLXD) TestContainerSecurity(containerName string, test api.ContainerSecurityTestPost) (Operation, error) {
+	if !r.HasExtension("container_security_test") {
+		return nil, fmt.Errorf("The server is missing the required \"container_security_test\" API extension")
+	}
+
+	// Send the request
+	op, _, err := r.queryOperation("POST", fmt.Sprintf("/containers/%s/security/test", url.QueryEscape(containerName)), test, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return op, nil
+}
+
+// GetContainerSecurityDenials returns the recent AppArmor and seccomp denials of the container
+func (r *ProtocolLXD) GetContainerSecurityDenials(containerName string) ([]api.ContainerSecurityDenial, error) {
+	if !r.HasExtension("container_security_denials") {
+		return nil, fmt.Errorf("The server is missing the required \"container_security_denials\" API extension")
+	}
+
+	denials := []api.ContainerSecurityDenial{}
+
+	// Fetch the raw value
+	_, err := r.queryStruct("GET", fmt.Sprintf("/containers/%s/security/denials", url.QueryEscape(containerName)), nil, "", &denials)
+	if err != nil {
+		return nil, err
+	}
+
+	return denials, nil
+}
+
+// RebuildContainer replaces the root filesystem of the stopped container with the one of another image
+func (r *ProtocolLXD) RebuildContainer(containerName string, rebuild api.ContainerRebuildPost) (Operation, error) {
+	if !r.HasExtension("container_rebuild") {
+		return nil, fmt.Errorf("The server is missing the required \"container_rebuild\" API extension")
+	}
+
+	// Send the request
+	op, _, err := r.queryOperation("POST", fmt.Sprintf("/containers/%s/rebuild", url.QueryEscape(containerName)), rebuild, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return op, nil
+}
+
+// RebuildContainerIdmap shifts the root filesystem of the stopped container to the idmap it will use on next start
+func (r *ProtocolLXD) RebuildContainerIdmap(containerName string) (Operation, error) {
+	if !r.HasExtension("container_rebuild_idmap") {
+		return nil, fmt.Errorf("The server is missing the required \"container_rebuild_idmap\" API extension")
+	}
+
+	// Send the request
+	op, _, err := r.queryOperation("POST", fmt.Sprintf("/containers/%s/rebuild-idmap", url.QueryEscape(containerName)), nil, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return op, nil
+}
+
+// RespawnContainer resets the container to its image and starts it again
+func (r *ProtocolLXD) RespawnContainer(containerName string, respawn api.ContainerRespawnPost) (Operation, error) {
+	if !r.HasExtension("container_respawn") {
+		return nil, fmt.Errorf("The server is missing the required \"container_respawn\" API extension")
+	}
+
+	// Send the request
+	op, _, err := r.queryOperation("POST", fmt.Sprintf("/containers/%s/respawn", url.QueryEscape(containerName)), respawn, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return op, nil
+}
+
+// ReapplyContainerNetwork sets up the host side of the network devices of the running container again
+func (r *ProtocolLXD) ReapplyContainerNetwork(containerName string, reapply api.ContainerNetworkReapplyPost) (Operation, error) {
+	if !r.HasExtension("container_network_reapply") {
+		return nil, fmt.Errorf("The server is missing the required \"container_network_reapply\" API extension")
+	}
+
+	// Send the request
+	op, _, err := r.queryOperation("POST", fmt.Sprintf("/containers/%s/network/reapply", url.QueryEscape(containerName)), reapply, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return op, nil
+}
+
+// GetContainerProcesses returns the processes of the container along with their host PIDs
+func (r *ProtocolLXD) GetContainerProcesses(containerName string) (*api.ContainerProcesses, error) {
+	if !r.HasExtension("container_host_pidns_view") {
+		return nil, fmt.Errorf("The server is missing the required \"container_host_pidns_view\" API extension")
+	}
+
+	processes := api.ContainerProcesses{}
+
+	// Fetch the raw value
+	_, err := r.queryStruct("GET", fmt.Sprintf("/containers/%s/processes", url.QueryEscape(containerName)), nil, "", &processes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &processes, nil
+}
+
+// GetContainerRevisions returns the recorded configurations of the container, oldest first
+func (r *ProtocolLXD) GetContainerRevisions(containerName string) ([]api.ContainerRevision, error) {
+	if !r.HasExtension("container_revisions") {
+		return nil, fmt.Errorf("The server is missing the required \"container_revisions\" API extension")
+	}
+
+	revisions := []api.ContainerRevision{}
+
+	// Fetch the raw value
+	_, err := r.queryStruct("GET", fmt.Sprintf("/containers/%s/revisions", url.QueryEscape(containerName)), nil, "", &revisions)
+	if err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+// RollbackContainer applies a recorded configuration to the container
+func (r *ProtocolLXD) RollbackContainer(containerName string, rollback api.ContainerRevisionsPost) (Operation, error) {
+	if !r.HasExtension("container_revisions") {
+		return nil, fmt.Errorf("The server is missing the required \"container_revisions\" API extension")
+	}
+
+	// Send the request
+	op, _, err := r.queryOperation("POST", fmt.Sprintf("/containers/%s/revisions", url.QueryEscape(containerName)), rollback, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return op, nil
+}
+
+// GetContainerConfigSnapshots returns the configuration snapshots of the container, oldest first
+func (r *ProtocolLXD) GetContainerConfigSnapshots(containerName string) ([]api.ContainerConfigSnapshot, error) {
+	if !r.HasExtension("container_config_snapshots") {
+		return nil, fmt.Errorf("The server is missing the required \"container_config_snapshots\" API extension")
+	}
+
+	snapshots := []api.ContainerConfigSnapshot{}
+
+	// Fetch the raw value
+	_, err := r.queryStruct("GET", fmt.Sprintf("/containers/%s/config-snapshots", url.QueryEscape(containerName)), nil, "", &snapshots)
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshots, nil
+}
+
+// GetContainerConfigSnapshot returns a configuration snapshot of the container
+func (r *ProtocolLXD) GetContainerConfigSnapshot(containerName string, name string) (*api.ContainerConfigSnapshot, error) {
+	if !r.HasExtension("container_config_snapshots") {
+		return nil, fmt.Errorf("The server is missing the required \"container_config_snapshots\" API extension")
+	}
+
+	snapshot := api.ContainerConfigSnapshot{}
+
+	// Fetch the raw value
+	_, err := r.queryStruct("GET", fmt.Sprintf("/containers/%s/config-snapshots/%s", url.QueryEscape(containerName), url.QueryEscape(name)), nil, "", &snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	return &snapshot, nil
+}
+
+// CreateContainerConfigSnapshot saves the current configuration of the container
+func (r *ProtocolLXD) CreateContainerConfigSnapshot(containerName string, snapshot api.ContainerConfigSnapshotsPost) error {
+	if !r.HasExtension("container_config_snapshots") {
+		return fmt.Errorf("The server is missing the required \"container_config_snapshots\" API extension")
+	}
+
+	// Send the request
+	_, _, err := r.query("POST", fmt.Sprintf("/containers/%s/config-snapshots", url.QueryEscape(containerName)), snapshot, "")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RestoreContainerConfigSnapshot applies a configuration snapshot to the container
+func (r *ProtocolLXD) RestoreContainerConfigSnapshot(containerName string, name string) (Operation, error) {
+	if !r.HasExtension("container_config_snapshots") {
+		return nil, fmt.Errorf("The server is missing the required \"container_config_snapshots\" API extension")
+	}
+
+	// Send the request
+	op, _, err := r.queryOperation("POST", fmt.Sprintf("/containers/%s/config-snapshots/%s", url.QueryEscape(containerName), url.QueryEscape(name)), nil, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return op, nil
+}
+
+// DeleteContainerConfigSnapshot deletes a configuration snapshot of the container
+func (r *ProtocolLXD) DeleteContainerConfigSnapshot(containerName string, name string) error {
+	if !r.HasExtension("container_config_snapshots") {
+		return fmt.Errorf("The server is missing the required \"container_config_snapshots\" API extension")
+	}
+
+	// Send the request
+	_, _, err := r.query("DELETE", fmt.Sprintf("/containers/%s/config-snapshots/%s", url.QueryEscape(containerName), url.QueryEscape(name)), nil, "")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetContainerCheckpoints returns the checkpoints of the container, oldest first
+func (r *ProtocolLXD) GetContainerCheckpoints(containerName string) ([]api.ContainerCheckpoint, error) {
+	if !r.HasExtension("container_checkpoint_tags") {
+		return nil, fmt.Errorf("The server is missing the required \"container_checkpoint_tags\" API extension")
+	}
+
+	checkpoints := []api.ContainerCheckpoint{}
+
+	// Fetch the raw value
+	_, err := r.queryStruct("GET", fmt.Sprintf("/containers/%s/checkpoints", url.QueryEscape(containerName)), nil, "", &checkpoints)
+	if err != nil {
+		return nil, err
+	}
+
+	return checkpoints, nil
+}
+
+// CreateContainerCheckpoint tags the current state of the container as a checkpoint
+func (r *ProtocolLXD) CreateContainerCheckpoint(containerName string, checkpoint api.ContainerCheckpointsPost) (Operation, error) {
+	if !r.HasExtension("container_checkpoint_tags") {
+		return nil, fmt.Errorf("The server is missing the required \"container_checkpoint_tags\" API extension")
+	}
+
+	// Send the request
+	op, _, err := r.queryOperation("POST", fmt.Sprintf("/containers/%s/checkpoints", url.QueryEscape(containerName)), checkpoint, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return op, nil
+}
+
+// RestoreContainerCheckpoint brings the container back to a checkpoint, stopping it and starting it
+// again if it was running
+func (r *ProtocolLXD) RestoreContainerCheckpoint(containerName string, name string) (Operation, error) {
+	if !r.HasExtension("container_checkpoint_tags") {
+		return nil, fmt.Errorf("The server is missing the required \"container_checkpoint_tags\" API extension")
+	}
+
+	// Send the request
+	op, _, err := r.queryOperation("POST", fmt.Sprintf("/containers/%s/checkpoints/%s", url.QueryEscape(containerName), url.QueryEscape(name)), nil, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return op, nil
+}
+
+// DeleteContainerCheckpoint deletes a checkpoint of the container
+func (r *ProtocolLXD) DeleteContainerCheckpoint(containerName string, name string) (Operation, error) {
+	if !r.HasExtension("container_checkpoint_tags") {
+		return nil, fmt.Errorf("The server is missing the required \"container_checkpoint_tags\" API extension")
+	}
+
+	// Send the request
+	op, _, err := r.queryOperation("DELETE", fmt.Sprintf("/containers/%s/checkpoints/%s", url.QueryEscape(containerName), url.QueryEscape(name)), nil, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return op, nil
+}
+
+// GetContainerSecretNames returns the names of the secrets of the container
+func (r *ProtocolLXD) GetContainerSecretNames(containerName string) ([]string, error) {
+	if !r.HasExtension("container_secrets") {
+		return nil, fmt.Errorf("The server is missing the required \"container_secrets\" API extension")
+	}
+
+	names := []string{}
+
+	// Fetch the raw value
+	_, err := r.queryStruct("GET", fmt.Sprintf("/containers/%s/secrets", url.QueryEscape(containerName)), nil, "", &names)
+	if err != nil {
+		return nil, err
+	}
+
+	return names, nil
+}
+
+// SetContainerSecret sets or rotates a secret of the container
+func (r *ProtocolLXD) SetContainerSecret(containerName string, name string, secret api.ContainerSecretPut) error {
+	if !r.HasExtension("container_secrets") {
+		return fmt.Errorf("The server is missing the required \"container_secrets\" API extension")
+	}
+
+	// Send the request
+	_, _, err := r.query("PUT", fmt.Sprintf("/containers/%s/secrets/%s", url.QueryEscape(containerName), url.QueryEscape(name)), secret, "")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteContainerSecret deletes a secret of the container
+func (r *ProtocolLXD) DeleteContainerSecret(containerName string, name string) error {
+	if !r.HasExtension("container_secrets") {
+		return fmt.Errorf("The server is missing the required \"container_secrets\" API extension")
+	}
+
+	// Send the request
+	_, _, err := r.query("DELETE", fmt.Sprintf("/containers/%s/secrets/%s", url.QueryEscape(containerName), url.QueryEscape(name)), nil, "")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 // GetContainerSnapshotNames returns a list of snapshot names for the container
 func (r *ProtocolLXD) GetContainerSnapshotNames(containerName string) ([]string, error) {
 	urls := []string{}
@@ -1687,6 +2105,23 @@ func (r *ProtocolLXD) ConsoleContainer(containerName string, console api.Contain
 	return op, nil
 }
 
+// GetContainerDeviceLog returns the recent device changes of a running container
+func (r *ProtocolLXD) GetContainerDeviceLog(containerName string) ([]api.ContainerDeviceLogEntry, error) {
+	if !r.HasExtension("container_device_log") {
+		return nil, fmt.Errorf("The server is missing the required \"container_device_log\" API extension")
+	}
+
+	entries := []api.ContainerDeviceLogEntry{}
+
+	// Fetch the raw value
+	_, err := r.queryStruct("GET", fmt.Sprintf("/containers/%s/devices/log", url.QueryEscape(containerName)), nil, "", &entries)
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
 // GetContainerConsoleLog requests that LXD attaches to the console device of a container.
 //
 // Note that it's the caller's responsibility to close the returned ReadCloser
@@ -1809,6 +2244,10 @@ func (r *ProtocolLXD) CreateContainerBackup(containerName string, backup api.Con
 		return nil, fmt.Errorf("The server is missing the required \"container_backup\" API extension")
 	}
 
+	if backup.CompressionAlgorithm != "" && !r.HasExtension("container_backup_compression") {
+		return nil, fmt.Errorf("The server is missing the required \"container_backup_compression\" API extension")
+	}
+
 	// Send the request
 	op, _, err := r.queryOperation("POST", fmt.Sprintf("/containers/%s/backups",
 		url.QueryEscape(containerName)), backup, "")
@@ -1913,3 +2352,56 @@ func (r *ProtocolLXD) GetContainerBackupFile(containerName string, name string,
 
 	return &resp, nil
 }
+
+// GetContainerBackupChunk downloads a chunk of a backup created in chunks,
+// returning an error if it doesn't match its checksum
+func (r *ProtocolLXD) GetContainerBackupChunk(containerName string, name string, index int, chunk io.Writer) (int64, error) {
+	if !r.HasExtension("container_backup_chunks") {
+		return -1, fmt.Errorf("The server is missing the required \"container_backup_chunks\" API extension")
+	}
+
+	// Build the URL
+	uri := fmt.Sprintf("%s/1.0/containers/%s/backups/%s/export?chunk=%d", r.httpHost,
+		url.QueryEscape(containerName), url.QueryEscape(name), index)
+	if r.project != "" {
+		uri += fmt.Sprintf("&project=%s", url.QueryEscape(r.project))
+	}
+
+	// Prepare the download request
+	request, err := http.NewRequest("GET", uri, nil)
+	if err != nil {
+		return -1, err
+	}
+
+	if r.httpUserAgent != "" {
+		request.Header.Set("User-Agent", r.httpUserAgent)
+	}
+
+	// Start the request
+	response, err := r.do(request)
+	if err != nil {
+		return -1, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		_, _, err := lxdParseResponse(response)
+		if err != nil {
+			return -1, err
+		}
+	}
+
+	// Handle the data
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(chunk, hash), response.Body)
+	if err != nil {
+		return -1, err
+	}
+
+	checksum := fmt.Sprintf("%x", hash.Sum(nil))
+	if checksum != response.Header.Get("X-LXD-checksum") {
+		return -1, fmt.Errorf("Checksum mismatch for chunk %d", index)
+	}
+
+	return size, nil
+}
@@ -652,6 +652,61 @@ func (r *ProtocolLXD) RefreshImage(fingerprint string) (Operation, error) {
 	return op, nil
 }
 
+// GetImageDownloads returns the image download statistics of the server
+func (r *ProtocolLXD) GetImageDownloads() (*api.ImageDownloads, error) {
+	if !r.HasExtension("image_delta_stats") {
+		return nil, fmt.Errorf("The server is missing the required \"image_delta_stats\" API extension")
+	}
+
+	downloads := api.ImageDownloads{}
+
+	// Fetch the raw value
+	_, err := r.queryStruct("GET", "/images/downloads", nil, "", &downloads)
+	if err != nil {
+		return nil, err
+	}
+
+	return &downloads, nil
+}
+
+// GetImageCatalog returns the images of the server, with their aliases and
+// properties, optionally restricted to those matching the fingerprint prefixes
+func (r *ProtocolLXD) GetImageCatalog(fingerprints []string) (*api.ImageCatalog, error) {
+	if !r.HasExtension("images_catalog") {
+		return nil, fmt.Errorf("The server is missing the required \"images_catalog\" API extension")
+	}
+
+	catalog := api.ImageCatalog{}
+
+	path := "/images/catalog"
+	if len(fingerprints) > 0 {
+		path = fmt.Sprintf("%s?fingerprints=%s", path, url.QueryEscape(strings.Join(fingerprints, ",")))
+	}
+
+	// Fetch the raw value
+	_, err := r.queryStruct("GET", path, nil, "", &catalog)
+	if err != nil {
+		return nil, err
+	}
+
+	return &catalog, nil
+}
+
+// ImportImageCatalog requests that LXD imports the image catalog of another server
+func (r *ProtocolLXD) ImportImageCatalog(req api.ImageCatalogPost) (Operation, error) {
+	if !r.HasExtension("images_catalog") {
+		return nil, fmt.Errorf("The server is missing the required \"images_catalog\" API extension")
+	}
+
+	// Send the request
+	op, _, err := r.queryOperation("POST", "/images/catalog", req, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return op, nil
+}
+
 // CreateImageSecret requests that LXD issues a temporary image secret
 func (r *ProtocolLXD) CreateImageSecret(fingerprint string) (Operation, error) {
 	// Send the request
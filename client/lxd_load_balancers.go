@@ -0,0 +1,112 @@
+package lxd
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+// GetLoadBalancerNames returns a list of load-balancer names
+func (r *ProtocolLXD) GetLoadBalancerNames() ([]string, error) {
+	if !r.HasExtension("load_balancers") {
+		return nil, fmt.Errorf("The server is missing the required \"load_balancers\" API extension")
+	}
+
+	urls := []string{}
+
+	// Fetch the raw value
+	_, err := r.queryStruct("GET", "/load-balancers", nil, "", &urls)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse it
+	names := []string{}
+	for _, url := range urls {
+		fields := strings.Split(url, "/load-balancers/")
+		names = append(names, fields[len(fields)-1])
+	}
+
+	return names, nil
+}
+
+// GetLoadBalancers returns a list of LoadBalancer struct
+func (r *ProtocolLXD) GetLoadBalancers() ([]api.LoadBalancer, error) {
+	if !r.HasExtension("load_balancers") {
+		return nil, fmt.Errorf("The server is missing the required \"load_balancers\" API extension")
+	}
+
+	loadBalancers := []api.LoadBalancer{}
+
+	// Fetch the raw value
+	_, err := r.queryStruct("GET", "/load-balancers?recursion=1", nil, "", &loadBalancers)
+	if err != nil {
+		return nil, err
+	}
+
+	return loadBalancers, nil
+}
+
+// GetLoadBalancer returns a LoadBalancer entry for the provided name
+func (r *ProtocolLXD) GetLoadBalancer(name string) (*api.LoadBalancer, string, error) {
+	if !r.HasExtension("load_balancers") {
+		return nil, "", fmt.Errorf("The server is missing the required \"load_balancers\" API extension")
+	}
+
+	loadBalancer := api.LoadBalancer{}
+
+	// Fetch the raw value
+	etag, err := r.queryStruct("GET", fmt.Sprintf("/load-balancers/%s", url.QueryEscape(name)), nil, "", &loadBalancer)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &loadBalancer, etag, nil
+}
+
+// CreateLoadBalancer defines a new load-balancer using the provided LoadBalancer struct
+func (r *ProtocolLXD) CreateLoadBalancer(loadBalancer api.LoadBalancersPost) error {
+	if !r.HasExtension("load_balancers") {
+		return fmt.Errorf("The server is missing the required \"load_balancers\" API extension")
+	}
+
+	// Send the request
+	_, _, err := r.query("POST", "/load-balancers", loadBalancer, "")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UpdateLoadBalancer updates the load-balancer to match the provided LoadBalancer struct
+func (r *ProtocolLXD) UpdateLoadBalancer(name string, loadBalancer api.LoadBalancerPut, ETag string) error {
+	if !r.HasExtension("load_balancers") {
+		return fmt.Errorf("The server is missing the required \"load_balancers\" API extension")
+	}
+
+	// Send the request
+	_, _, err := r.query("PUT", fmt.Sprintf("/load-balancers/%s", url.QueryEscape(name)), loadBalancer, ETag)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteLoadBalancer deletes an existing load-balancer
+func (r *ProtocolLXD) DeleteLoadBalancer(name string) error {
+	if !r.HasExtension("load_balancers") {
+		return fmt.Errorf("The server is missing the required \"load_balancers\" API extension")
+	}
+
+	// Send the request
+	_, _, err := r.query("DELETE", fmt.Sprintf("/load-balancers/%s", url.QueryEscape(name)), nil, "")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
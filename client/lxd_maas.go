@@ -0,0 +1,39 @@
+package lxd
+
+import (
+	"fmt"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+// GetMAASSync returns the MAAS record updates pending a retry
+func (r *ProtocolLXD) GetMAASSync() ([]api.MAASSyncEntry, error) {
+	if !r.HasExtension("maas_reconcile") {
+		return nil, fmt.Errorf("The server is missing the required \"maas_reconcile\" API extension")
+	}
+
+	entries := []api.MAASSyncEntry{}
+
+	// Fetch the raw value
+	_, err := r.queryStruct("GET", "/maas/sync", nil, "", &entries)
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// ReconcileMAAS compares the MAAS records with the containers
+func (r *ProtocolLXD) ReconcileMAAS(req api.MAASReconcilePost) (Operation, error) {
+	if !r.HasExtension("maas_reconcile") {
+		return nil, fmt.Errorf("The server is missing the required \"maas_reconcile\" API extension")
+	}
+
+	// Send the request
+	op, _, err := r.queryOperation("POST", "/maas/reconcile", req, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return op, nil
+}
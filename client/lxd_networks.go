@@ -100,6 +100,23 @@ func (r *ProtocolLXD) GetNetworkState(name string) (*api.NetworkState, error) {
 	return &state, nil
 }
 
+// GetNetworkTopology returns the graph of networks, containers and NICs across the cluster
+func (r *ProtocolLXD) GetNetworkTopology() (*api.NetworkTopology, error) {
+	if !r.HasExtension("network_topology") {
+		return nil, fmt.Errorf("The server is missing the required \"network_topology\" API extension")
+	}
+
+	topology := api.NetworkTopology{}
+
+	// Fetch the raw value
+	_, err := r.queryStruct("GET", "/networks/topology", nil, "", &topology)
+	if err != nil {
+		return nil, err
+	}
+
+	return &topology, nil
+}
+
 // CreateNetwork defines a new network using the provided Network struct
 func (r *ProtocolLXD) CreateNetwork(network api.NetworksPost) error {
 	if !r.HasExtension("network") {
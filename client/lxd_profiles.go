@@ -99,3 +99,18 @@ func (r *ProtocolLXD) DeleteProfile(name string) error {
 
 	return nil
 }
+
+// RestartProfileInstances restarts the running containers using the profile in batches
+func (r *ProtocolLXD) RestartProfileInstances(name string, req api.ProfileInstancesRestartPost) (Operation, error) {
+	if !r.HasExtension("profile_rolling_restart") {
+		return nil, fmt.Errorf("The server is missing the required \"profile_rolling_restart\" API extension")
+	}
+
+	// Send the request
+	op, _, err := r.queryOperation("POST", fmt.Sprintf("/profiles/%s/instances/restart", url.QueryEscape(name)), req, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return op, nil
+}
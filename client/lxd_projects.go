@@ -98,6 +98,21 @@ func (r *ProtocolLXD) UpdateProject(name string, project api.ProjectPut, ETag st
 	return nil
 }
 
+// UpdateProjectContainersProtection sets or clears the start protection of all the containers of a project
+func (r *ProtocolLXD) UpdateProjectContainersProtection(name string, protection api.ProjectContainersProtectionPut) error {
+	if !r.HasExtension("container_protection_start") {
+		return fmt.Errorf("The server is missing the required \"container_protection_start\" API extension")
+	}
+
+	// Send the request
+	_, _, err := r.query("PUT", fmt.Sprintf("/projects/%s/containers/protection", url.QueryEscape(name)), protection, "")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 // RenameProject renames an existing project entry
 func (r *ProtocolLXD) RenameProject(name string, project api.ProjectPost) (Operation, error) {
 	if !r.HasExtension("projects") {
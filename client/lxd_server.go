@@ -2,6 +2,7 @@ package lxd
 
 import (
 	"fmt"
+	"net/url"
 
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
@@ -89,6 +90,55 @@ func (r *ProtocolLXD) GetServerResources() (*api.Resources, error) {
 	return &resources, nil
 }
 
+// Search returns the containers matching a name, IP address or MAC address
+func (r *ProtocolLXD) Search(query string) ([]api.SearchResult, error) {
+	if !r.HasExtension("search") {
+		return nil, fmt.Errorf("The server is missing the required \"search\" API extension")
+	}
+
+	results := []api.SearchResult{}
+
+	// Fetch the raw value
+	_, err := r.queryStruct("GET", fmt.Sprintf("/search?q=%s", url.QueryEscape(query)), nil, "", &results)
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// GetMetadataConfiguration returns the configuration keys supported by the server
+func (r *ProtocolLXD) GetMetadataConfiguration() (*api.MetadataConfiguration, error) {
+	if !r.HasExtension("metadata_configuration") {
+		return nil, fmt.Errorf("The server is missing the required \"metadata_configuration\" API extension")
+	}
+
+	metadata := api.MetadataConfiguration{}
+
+	// Fetch the raw value
+	_, err := r.queryStruct("GET", "/metadata/configuration", nil, "", &metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	return &metadata, nil
+}
+
+// EmergencyShutdown stops all the containers running on the server
+func (r *ProtocolLXD) EmergencyShutdown() (Operation, error) {
+	if !r.HasExtension("emergency_shutdown") {
+		return nil, fmt.Errorf("The server is missing the required \"emergency_shutdown\" API extension")
+	}
+
+	// Send the request
+	op, _, err := r.queryOperation("POST", "/emergency-shutdown", nil, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return op, nil
+}
+
 // UseProject returns a client that will use a specific project.
 func (r *ProtocolLXD) UseProject(name string) ContainerServer {
 	return &ProtocolLXD{
@@ -12,6 +12,7 @@ import (
 
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/simplestreams"
 )
 
 // Image handling functions
@@ -117,7 +118,8 @@ func (r *ProtocolSimpleStreams) GetImageFile(fingerprint string, req ImageFileRe
 	// Download the rootfs
 	rootfs, ok := files["root"]
 	if ok && req.RootfsFile != nil {
-		// Look for deltas (requires xdelta3)
+		// Look for deltas (requires xdelta3), falling back to the whole
+		// file if none can be applied
 		downloaded := false
 		_, err := exec.LookPath("xdelta3")
 		if err == nil && req.DeltaSourceRetriever != nil {
@@ -133,44 +135,28 @@ func (r *ProtocolSimpleStreams) GetImageFile(fingerprint string, req ImageFileRe
 					continue
 				}
 
-				// Create temporary file for the delta
-				deltaFile, err := ioutil.TempFile("", "lxc_image_")
+				size, deltaSize, err := applyImageDelta(download, srcPath, file, rootfs.Sha256, req.RootfsFile)
 				if err != nil {
-					return nil, err
-				}
-				defer deltaFile.Close()
-				defer os.Remove(deltaFile.Name())
+					// Handle cancelation
+					if err.Error() == "net/http: request canceled" {
+						return nil, err
+					}
 
-				// Download the delta
-				_, err = download(file.Path, "rootfs delta", file.Sha256, deltaFile)
-				if err != nil {
-					return nil, err
-				}
-
-				// Create temporary file for the delta
-				patchedFile, err := ioutil.TempFile("", "lxc_image_")
-				if err != nil {
-					return nil, err
-				}
-				defer patchedFile.Close()
-				defer os.Remove(patchedFile.Name())
-
-				// Apply it
-				_, err = shared.RunCommand("xdelta3", "-f", "-d", "-s", srcPath, deltaFile.Name(), patchedFile.Name())
-				if err != nil {
-					return nil, err
-				}
+					_, err = req.RootfsFile.Seek(0, io.SeekStart)
+					if err != nil {
+						return nil, err
+					}
 
-				// Copy to the target
-				size, err := io.Copy(req.RootfsFile, patchedFile)
-				if err != nil {
-					return nil, err
+					continue
 				}
 
 				parts := strings.Split(rootfs.Path, "/")
 				resp.RootfsName = parts[len(parts)-1]
 				resp.RootfsSize = size
+				resp.RootfsDeltaBase = srcFingerprint
+				resp.RootfsDeltaSize = deltaSize
 				downloaded = true
+				break
 			}
 		}
 
@@ -190,6 +176,65 @@ func (r *ProtocolSimpleStreams) GetImageFile(fingerprint string, req ImageFileRe
 	return &resp, nil
 }
 
+// applyImageDelta downloads a rootfs delta and applies it to the given source
+// file, checking the result against the hash of the full rootfs before
+// writing it to the target. It returns the size of the rootfs and the size of
+// the delta.
+func applyImageDelta(download func(string, string, string, io.WriteSeeker) (int64, error), srcPath string, delta simplestreams.SimpleStreamsFile, hash string, target io.WriteSeeker) (int64, int64, error) {
+	// Create temporary file for the delta
+	deltaFile, err := ioutil.TempFile("", "lxc_image_")
+	if err != nil {
+		return -1, -1, err
+	}
+	defer deltaFile.Close()
+	defer os.Remove(deltaFile.Name())
+
+	// Download the delta
+	deltaSize, err := download(delta.Path, "rootfs delta", delta.Sha256, deltaFile)
+	if err != nil {
+		return -1, -1, err
+	}
+
+	// Create temporary file for the patched rootfs
+	patchedFile, err := ioutil.TempFile("", "lxc_image_")
+	if err != nil {
+		return -1, -1, err
+	}
+	defer patchedFile.Close()
+	defer os.Remove(patchedFile.Name())
+
+	// Apply it
+	_, err = shared.RunCommand("xdelta3", "-f", "-d", "-s", srcPath, deltaFile.Name(), patchedFile.Name())
+	if err != nil {
+		return -1, -1, err
+	}
+
+	// Validate the result
+	hashFunc := sha256.New()
+	_, err = io.Copy(hashFunc, patchedFile)
+	if err != nil {
+		return -1, -1, err
+	}
+
+	result := fmt.Sprintf("%x", hashFunc.Sum(nil))
+	if result != hash {
+		return -1, -1, fmt.Errorf("Hash mismatch for the patched rootfs: %s != %s", result, hash)
+	}
+
+	// Copy to the target
+	_, err = patchedFile.Seek(0, io.SeekStart)
+	if err != nil {
+		return -1, -1, err
+	}
+
+	size, err := io.Copy(target, patchedFile)
+	if err != nil {
+		return -1, -1, err
+	}
+
+	return size, deltaSize, nil
+}
+
 // GetImageSecret isn't relevant for the simplestreams protocol
 func (r *ProtocolSimpleStreams) GetImageSecret(fingerprint string) (string, error) {
 	return "", fmt.Errorf("Private images aren't supported by the simplestreams protocol")
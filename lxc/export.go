@@ -20,8 +20,9 @@ import (
 type cmdExport struct {
 	global *cmdGlobal
 
-	flagContainerOnly    bool
-	flagOptimizedStorage bool
+	flagContainerOnly        bool
+	flagOptimizedStorage     bool
+	flagCompressionAlgorithm string
 }
 
 func (c *cmdExport) Command() *cobra.Command {
@@ -39,6 +40,8 @@ func (c *cmdExport) Command() *cobra.Command {
 		i18n.G("Whether or not to only backup the container (without snapshots)"))
 	cmd.Flags().BoolVar(&c.flagOptimizedStorage, "optimized-storage", false,
 		i18n.G("Use storage driver optimized format (can only be restored on a similar pool)"))
+	cmd.Flags().StringVar(&c.flagCompressionAlgorithm, "compression", "",
+		i18n.G("Compression algorithm and optional level to use (e.g. \"zstd -19\" or none)")+"``")
 
 	return cmd
 }
@@ -64,10 +67,11 @@ func (c *cmdExport) Run(cmd *cobra.Command, args []string) error {
 	}
 
 	req := api.ContainerBackupsPost{
-		Name:             "",
-		ExpiresAt:        time.Now().Add(24 * time.Hour),
-		ContainerOnly:    c.flagContainerOnly,
-		OptimizedStorage: c.flagOptimizedStorage,
+		Name:                 "",
+		ExpiresAt:            time.Now().Add(24 * time.Hour),
+		ContainerOnly:        c.flagContainerOnly,
+		OptimizedStorage:     c.flagOptimizedStorage,
+		CompressionAlgorithm: c.flagCompressionAlgorithm,
 	}
 
 	op, err := d.CreateContainerBackup(name, req)
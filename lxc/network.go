@@ -244,6 +244,8 @@ func (c *cmdNetworkAttachProfile) Run(cmd *cobra.Command, args []string) error {
 type cmdNetworkCreate struct {
 	global  *cmdGlobal
 	network *cmdNetwork
+
+	flagType string
 }
 
 func (c *cmdNetworkCreate) Command() *cobra.Command {
@@ -254,6 +256,7 @@ func (c *cmdNetworkCreate) Command() *cobra.Command {
 		`Create new networks`))
 
 	cmd.Flags().StringVar(&c.network.flagTarget, "target", "", i18n.G("Cluster member name")+"``")
+	cmd.Flags().StringVar(&c.flagType, "type", "", i18n.G("Network type (bridge or external)")+"``")
 	cmd.RunE = c.Run
 
 	return cmd
@@ -278,6 +281,7 @@ func (c *cmdNetworkCreate) Run(cmd *cobra.Command, args []string) error {
 	// Create the network
 	network := api.NetworksPost{}
 	network.Name = resource.name
+	network.Type = c.flagType
 	network.Config = map[string]string{}
 
 	for i := 1; i < len(args); i++ {
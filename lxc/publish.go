@@ -22,6 +22,7 @@ type cmdPublish struct {
 	flagCompressionAlgorithm string
 	flagMakePublic           bool
 	flagForce                bool
+	flagProfile              string
 }
 
 func (c *cmdPublish) showByDefault() bool {
@@ -40,6 +41,7 @@ func (c *cmdPublish) Command() *cobra.Command {
 	cmd.Flags().StringArrayVar(&c.flagAliases, "alias", nil, i18n.G("New alias to define at target")+"``")
 	cmd.Flags().BoolVarP(&c.flagForce, "force", "f", false, i18n.G("Stop the container if currently running"))
 	cmd.Flags().StringVar(&c.flagCompressionAlgorithm, "compression", "", i18n.G("Define a compression algorithm: for image or none")+"``")
+	cmd.Flags().StringVar(&c.flagProfile, "profile", "", i18n.G("Profile to create at target from the container configuration and devices")+"``")
 
 	return cmd
 }
@@ -96,6 +98,18 @@ func (c *cmdPublish) Run(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	if c.flagProfile != "" && !s.HasExtension("image_publish_profile") {
+		return fmt.Errorf(i18n.G("The server doesn't support generating profiles on publish"))
+	}
+
+	// The profile ends up on the target, check it's available there before publishing
+	if c.flagProfile != "" && cRemote != iRemote {
+		_, _, err := d.GetProfile(c.flagProfile)
+		if err == nil {
+			return fmt.Errorf(i18n.G("Profile %s already exists on the target"), c.flagProfile)
+		}
+	}
+
 	if !shared.IsSnapshot(cName) {
 		ct, etag, err := s.GetContainer(cName)
 		if err != nil {
@@ -201,6 +215,7 @@ func (c *cmdPublish) Run(cmd *cobra.Command, args []string) error {
 			Name: cName,
 		},
 		CompressionAlgorithm: c.flagCompressionAlgorithm,
+		Profile:              c.flagProfile,
 	}
 	req.Properties = properties
 
@@ -246,6 +261,11 @@ func (c *cmdPublish) Run(cmd *cobra.Command, args []string) error {
 	if cRemote != iRemote {
 		defer s.DeleteImage(fingerprint)
 
+		// The profile was generated on the source, along with the image
+		if c.flagProfile != "" {
+			defer s.DeleteProfile(c.flagProfile)
+		}
+
 		// Get the source image
 		image, _, err := s.GetImage(fingerprint)
 		if err != nil {
@@ -267,6 +287,24 @@ func (c *cmdPublish) Run(cmd *cobra.Command, args []string) error {
 		if err != nil {
 			return err
 		}
+
+		// Copy the generated profile from the source to the target,
+		// not leaving the image behind on failure
+		if c.flagProfile != "" {
+			profile, _, err := s.GetProfile(c.flagProfile)
+			if err == nil {
+				err = d.CreateProfile(api.ProfilesPost{ProfilePut: profile.Writable(), Name: profile.Name})
+			}
+
+			if err != nil {
+				op, opErr := d.DeleteImage(fingerprint)
+				if opErr == nil {
+					op.Wait()
+				}
+
+				return err
+			}
+		}
 	}
 
 	err = ensureImageAliases(d, aliases, fingerprint)
@@ -275,5 +313,9 @@ func (c *cmdPublish) Run(cmd *cobra.Command, args []string) error {
 	}
 	fmt.Printf(i18n.G("Container published with fingerprint: %s")+"\n", fingerprint)
 
+	if c.flagProfile != "" {
+		fmt.Printf(i18n.G("Profile %s created")+"\n", c.flagProfile)
+	}
+
 	return nil
 }
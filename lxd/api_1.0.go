@@ -31,34 +31,74 @@ var api10Cmd = APIEndpoint{
 var api10 = []APIEndpoint{
 	api10Cmd,
 	api10ResourcesCmd,
+	apparmorProfileCmd,
+	apparmorProfilesCmd,
+	backupUploadChunkCmd,
+	backupUploadCmd,
+	backupUploadsCmd,
 	certificateCmd,
 	certificatesCmd,
 	clusterCmd,
 	clusterNodeCmd,
 	clusterNodesCmd,
+	containerApplyCmd,
 	containerBackupCmd,
 	containerBackupExportCmd,
 	containerBackupsCmd,
+	containerCheckpointTagCmd,
+	containerCheckpointTagsCmd,
 	containerCmd,
+	containerConfigSnapshotCmd,
+	containerConfigSnapshotsCmd,
 	containerConsoleCmd,
+	containerDevicesLogCmd,
 	containerExecCmd,
+	containerExecSessionsCmd,
 	containerFileCmd,
+	containerFilesSyncCmd,
 	containerLogCmd,
 	containerLogsCmd,
 	containerMetadataCmd,
 	containerMetadataTemplatesCmd,
+	containerMonitorCmd,
+	containerNetworkReapplyCmd,
+	containerProcessesCmd,
+	containerRebuildCmd,
+	containerRebuildIdmapCmd,
+	containerRespawnCmd,
+	containerRevisionsCmd,
 	containersCmd,
+	containerSecretCmd,
+	containerSecretsCmd,
+	containerSecurityDenialsCmd,
+	containerSecurityTestCmd,
 	containerSnapshotCmd,
 	containerSnapshotsCmd,
 	containerStateCmd,
+	containerTasksCmd,
+	databaseBackupCmd,
+	databaseBackupsCmd,
+	emergencyShutdownCmd,
 	eventsCmd,
+	healthCmd,
 	imageAliasCmd,
 	imageAliasesCmd,
+	// Must come before imageCmd which would match it too
+	imageDownloadsCmd,
+	imagesCatalogCmd,
 	imageCmd,
 	imageExportCmd,
 	imageRefreshCmd,
 	imagesCmd,
 	imageSecretCmd,
+	loadBalancerCmd,
+	loadBalancersCmd,
+	maasReconcileCmd,
+	maasSyncCmd,
+	metadataConfigurationCmd,
+	metricsCmd,
+	// Must come before networkCmd which would match it too
+	networkTopologyCmd,
 	networkCmd,
 	networkLeasesCmd,
 	networksCmd,
@@ -68,9 +108,13 @@ var api10 = []APIEndpoint{
 	operationWait,
 	operationWebsocket,
 	profileCmd,
+	profileInstancesRestartCmd,
 	profilesCmd,
 	projectCmd,
+	projectActivityCmd,
+	projectContainersProtectionCmd,
 	projectsCmd,
+	searchCmd,
 	storagePoolCmd,
 	storagePoolResourcesCmd,
 	storagePoolsCmd,
@@ -97,6 +141,11 @@ func api10Get(d *Daemon, r *http.Request) Response {
 			authMethods = append(authMethods, "candid")
 		}
 
+		oidcIssuer, _, _, _, _, _ := config.OIDCServer()
+		if oidcIssuer != "" {
+			authMethods = append(authMethods, "oidc")
+		}
+
 		return nil
 	})
 	if err != nil {
@@ -209,6 +258,9 @@ func api10Get(d *Daemon, r *http.Request) Response {
 		"unpriv_fscaps":      fmt.Sprintf("%v", d.os.VFS3Fscaps),
 		"seccomp_listener":   fmt.Sprintf("%v", d.os.SeccompListener),
 		"shiftfs":            fmt.Sprintf("%v", d.os.Shiftfs),
+		"idmapped_mounts":    fmt.Sprintf("%v", d.os.IdmappedMounts),
+		"cgroup2":            fmt.Sprintf("%v", d.os.CGroupV2),
+		"time_namespace":     fmt.Sprintf("%v", d.os.TimeNamespace),
 	}
 
 	if d.os.LXCFeatures != nil {
@@ -464,6 +516,7 @@ func doApi10UpdateTriggers(d *Daemon, nodeChanged, clusterChanged map[string]str
 	maasChanged := false
 	candidChanged := false
 	rbacChanged := false
+	oidcChanged := false
 
 	for key := range clusterChanged {
 		switch key {
@@ -507,6 +560,18 @@ func doApi10UpdateTriggers(d *Daemon, nodeChanged, clusterChanged map[string]str
 			fallthrough
 		case "rbac.expiry":
 			rbacChanged = true
+		case "oidc.issuer":
+			fallthrough
+		case "oidc.audience":
+			fallthrough
+		case "oidc.claims.username":
+			fallthrough
+		case "oidc.claims.groups":
+			fallthrough
+		case "oidc.groups.admin":
+			fallthrough
+		case "oidc.groups.projects":
+			oidcChanged = true
 		}
 	}
 
@@ -577,6 +642,14 @@ func doApi10UpdateTriggers(d *Daemon, nodeChanged, clusterChanged map[string]str
 		}
 	}
 
+	if oidcChanged {
+		issuer, audience, usernameClaim, groupsClaim, adminGroups, projectGroups := clusterConfig.OIDCServer()
+		err := d.setupOIDC(issuer, audience, usernameClaim, groupsClaim, adminGroups, projectGroups)
+		if err != nil {
+			return err
+		}
+	}
+
 	if rbacChanged {
 		apiURL, apiKey, apiExpiry, agentURL, agentUsername, agentPrivateKey, agentPublicKey := clusterConfig.RBACServer()
 
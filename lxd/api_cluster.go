@@ -437,6 +437,14 @@ func clusterPutJoin(d *Daemon, req api.ClusterPut) Response {
 		}
 		d.endpoints.NetworkUpdateCert(cert)
 
+		// Use the key of the cluster to encrypt secrets
+		if info.SecretsKey != nil {
+			err = util.SecretKeyWrite(d.os.VarDir, info.SecretsKey)
+			if err != nil {
+				return errors.Wrap(err, "failed to save secrets key")
+			}
+		}
+
 		// Update local setup and possibly join the raft dqlite
 		// cluster.
 		nodes := make([]db.RaftNode, len(info.RaftNodes))
@@ -1015,6 +1023,11 @@ func internalClusterPostAccept(d *Daemon, r *http.Request) Response {
 		return SmartError(err)
 	}
 
+	secretsKey, err := containerSecretsKey(d.State())
+	if err != nil {
+		return SmartError(err)
+	}
+
 	nodes, err := cluster.Accept(d.State(), d.gateway, req.Name, req.Address, req.Schema, req.API)
 	if err != nil {
 		return BadRequest(err)
@@ -1022,6 +1035,7 @@ func internalClusterPostAccept(d *Daemon, r *http.Request) Response {
 	accepted := internalClusterPostAcceptResponse{
 		RaftNodes:  make([]internalRaftNode, len(nodes)),
 		PrivateKey: d.endpoints.NetworkPrivateKey(),
+		SecretsKey: secretsKey,
 	}
 	for i, node := range nodes {
 		accepted.RaftNodes[i].ID = node.ID
@@ -1044,6 +1058,7 @@ type internalClusterPostAcceptRequest struct {
 type internalClusterPostAcceptResponse struct {
 	RaftNodes  []internalRaftNode `json:"raft_nodes" yaml:"raft_nodes"`
 	PrivateKey []byte             `json:"private_key" yaml:"private_key"`
+	SecretsKey []byte             `json:"secrets_key" yaml:"secrets_key"`
 }
 
 // Represent a LXD node that is part of the dqlite raft cluster.
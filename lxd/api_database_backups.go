@@ -0,0 +1,431 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	sqlite3 "github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+	cron "gopkg.in/robfig/cron.v2"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/db"
+	dbCluster "github.com/lxc/lxd/lxd/db/cluster"
+	"github.com/lxc/lxd/lxd/db/query"
+	"github.com/lxc/lxd/lxd/dbbackup"
+	"github.com/lxc/lxd/lxd/node"
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/version"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+func init() {
+	sql.Register("sqlite3_backup_validate", &sqlite3.SQLiteDriver{ConnectHook: sqliteBackupValidate})
+}
+
+// sqliteBackupValidate forbids attaching databases to the scratch database the
+// dumps fetched from the backup targets are loaded in, so that they can't
+// write host files through ATTACH or VACUUM INTO.
+func sqliteBackupValidate(conn *sqlite3.SQLiteConn) error {
+	conn.SetLimit(sqlite3.SQLITE_LIMIT_ATTACHED, 0)
+	return nil
+}
+
+var databaseBackupsCmd = APIEndpoint{
+	Name: "database/backups",
+
+	Get:  APIEndpointAction{Handler: databaseBackupsGet},
+	Post: APIEndpointAction{Handler: databaseBackupsPost},
+}
+
+var databaseBackupCmd = APIEndpoint{
+	Name: "database/backups/{name}",
+
+	Get: APIEndpointAction{Handler: databaseBackupGet},
+}
+
+// Tables whose row count is reported when validating a backup.
+var databaseBackupEntities = []string{"nodes", "projects", "containers", "images", "profiles", "networks", "storage_pools"}
+
+func databaseBackupTarget(d *Daemon) (dbbackup.Target, int, error) {
+	var target string
+	var password string
+	var retention int64
+
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		config, err := cluster.ConfigLoad(tx)
+		if err != nil {
+			return err
+		}
+
+		target, password = config.DatabaseBackupTarget()
+		retention = config.DatabaseBackupRetention()
+		return nil
+	})
+	if err != nil {
+		return nil, -1, err
+	}
+
+	if target == "" {
+		return nil, -1, fmt.Errorf("No database backup target configured")
+	}
+
+	t, err := dbbackup.NewTarget(target, password)
+	if err != nil {
+		return nil, -1, err
+	}
+
+	return t, int(retention), nil
+}
+
+func databaseBackupsGet(d *Daemon, r *http.Request) Response {
+	t, _, err := databaseBackupTarget(d)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	names, err := t.List()
+	if err != nil {
+		return SmartError(err)
+	}
+
+	resultString := []string{}
+	for _, name := range names {
+		resultString = append(resultString, fmt.Sprintf("/%s/database/backups/%s", version.APIVersion, name))
+	}
+
+	return SyncResponse(true, resultString)
+}
+
+func databaseBackupsPost(d *Daemon, r *http.Request) Response {
+	t, retention, err := databaseBackupTarget(d)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	name := dbbackup.NewName(time.Now())
+
+	run := func(op *operation) error {
+		return databaseBackupCreate(d, t, name, retention)
+	}
+
+	resources := map[string][]string{}
+	resources["database_backups"] = []string{name}
+
+	op, err := operationCreate(d.cluster, "", operationClassTask, db.OperationDatabaseBackupCreate, resources, nil, run, nil, nil)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	return OperationResponse(op)
+}
+
+func databaseBackupGet(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+	if !dbbackup.IsName(name) {
+		return BadRequest(fmt.Errorf("Invalid database backup name: %s", name))
+	}
+
+	t, _, err := databaseBackupTarget(d)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	f, err := t.Get(name)
+	if err != nil {
+		return SmartError(err)
+	}
+	defer f.Close()
+
+	backup, err := databaseBackupValidate(f)
+	if err != nil {
+		return BadRequest(errors.Wrapf(err, "Database backup %s is invalid", name))
+	}
+	backup.Name = name
+
+	return SyncResponse(true, backup)
+}
+
+// databaseBackupCreate uploads a logical backup of the cluster database to
+// the target, along with the raft snapshots of this node, then applies the
+// retention policy.
+func databaseBackupCreate(d *Daemon, t dbbackup.Target, name string, retention int) error {
+	logger.Info("Creating database backup", log.Ctx{"name": name})
+
+	tx, err := d.cluster.DB().Begin()
+	if err != nil {
+		return errors.Wrap(err, "Failed to start transaction")
+	}
+
+	dump, err := query.Dump(tx, dbCluster.FreshSchema(), false)
+	tx.Rollback()
+	if err != nil {
+		return errors.Wrap(err, "Failed to dump the cluster database")
+	}
+
+	buf := bytes.Buffer{}
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	hdr := &tar.Header{Name: "global.sql", Mode: 0600, Size: int64(len(dump)), ModTime: time.Now()}
+	err = tw.WriteHeader(hdr)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(tw, dump)
+	if err != nil {
+		return err
+	}
+
+	// Include the raft snapshots, if any
+	snapshots, err := filepath.Glob(filepath.Join(d.os.VarDir, "database", "global", "snapshot-*"))
+	if err != nil {
+		return err
+	}
+
+	for _, path := range snapshots {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+
+		hdr, err := tar.FileInfoHeader(fi, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.Join("raft", fi.Name())
+
+		err = tw.WriteHeader(hdr)
+		if err != nil {
+			return err
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(tw, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tw.Close()
+	if err != nil {
+		return err
+	}
+
+	err = gz.Close()
+	if err != nil {
+		return err
+	}
+
+	err = t.Put(name, &buf)
+	if err != nil {
+		return errors.Wrap(err, "Failed to upload the database backup")
+	}
+
+	err = dbbackup.Prune(t, retention)
+	if err != nil {
+		return errors.Wrap(err, "Failed to remove old database backups")
+	}
+
+	logger.Info("Created database backup", log.Ctx{"name": name})
+	return nil
+}
+
+// databaseBackupValidate checks that the SQL dump of the backup can be loaded
+// and that its schema is one this server can deal with.
+func databaseBackupValidate(r io.Reader) (*api.DatabaseBackup, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	backup := api.DatabaseBackup{Entities: map[string]int{}}
+	dump := ""
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if hdr.Name == "global.sql" {
+			content, err := ioutil.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+
+			dump = string(content)
+		}
+
+		if strings.HasPrefix(hdr.Name, "raft/") {
+			backup.RaftSnapshot = true
+		}
+	}
+
+	if dump == "" {
+		return nil, fmt.Errorf("Backup is missing global.sql")
+	}
+
+	// Load the dump in a scratch database
+	sqldb, err := sql.Open("sqlite3_backup_validate", ":memory:")
+	if err != nil {
+		return nil, err
+	}
+	defer sqldb.Close()
+
+	// Each connection would get its own in-memory database
+	sqldb.SetMaxOpenConns(1)
+
+	_, err = sqldb.Exec(dump)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to load the SQL dump")
+	}
+
+	err = sqldb.QueryRow("SELECT MAX(version) FROM schema").Scan(&backup.SchemaVersion)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get the schema version")
+	}
+
+	if backup.SchemaVersion > dbCluster.SchemaVersion {
+		return nil, fmt.Errorf("Schema version %d is newer than the one of this server (%d)", backup.SchemaVersion, dbCluster.SchemaVersion)
+	}
+
+	for _, table := range databaseBackupEntities {
+		var count int
+		err = sqldb.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s", table)).Scan(&count)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to count rows of table %s", table)
+		}
+
+		backup.Entities[table] = count
+	}
+
+	return &backup, nil
+}
+
+// Only the raft leader (or a standalone node) takes scheduled backups.
+func databaseBackupShouldRun(d *Daemon) bool {
+	clustered, err := cluster.Enabled(d.db)
+	if err != nil {
+		return false
+	}
+
+	if !clustered {
+		return true
+	}
+
+	address, err := node.ClusterAddress(d.db)
+	if err != nil {
+		return false
+	}
+
+	leader, err := d.gateway.LeaderAddress()
+	if err != nil {
+		return false
+	}
+
+	return address == leader
+}
+
+func autoCreateDatabaseBackupTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		var schedule string
+		err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+			config, err := cluster.ConfigLoad(tx)
+			if err != nil {
+				return err
+			}
+
+			schedule = config.DatabaseBackupSchedule()
+			return nil
+		})
+		if err != nil {
+			logger.Error("Failed to load database backup schedule", log.Ctx{"err": err})
+			return
+		}
+
+		if schedule == "" {
+			return
+		}
+
+		// Extend our schedule to one that is accepted by the used cron parser
+		sched, err := cron.Parse(fmt.Sprintf("* %s", schedule))
+		if err != nil {
+			return
+		}
+
+		// Check if it's time for a backup, ignoring everything more precise
+		// than minutes.
+		now := time.Now().Truncate(time.Minute)
+		next := sched.Next(now).Truncate(time.Minute)
+		if !now.Equal(next) {
+			return
+		}
+
+		if !databaseBackupShouldRun(d) {
+			return
+		}
+
+		t, retention, err := databaseBackupTarget(d)
+		if err != nil {
+			logger.Error("Failed to load database backup target", log.Ctx{"err": err})
+			return
+		}
+
+		name := dbbackup.NewName(time.Now())
+		opRun := func(op *operation) error {
+			return databaseBackupCreate(d, t, name, retention)
+		}
+
+		op, err := operationCreate(d.cluster, "", operationClassTask, db.OperationDatabaseBackupCreate, nil, nil, opRun, nil, nil)
+		if err != nil {
+			logger.Error("Failed to start database backup operation", log.Ctx{"err": err})
+			return
+		}
+
+		_, err = op.Run()
+		if err != nil {
+			logger.Error("Failed to create database backup", log.Ctx{"err": err})
+		}
+	}
+
+	first := true
+	schedule := func() (time.Duration, error) {
+		interval := time.Minute
+
+		if first {
+			first = false
+			return interval, task.ErrSkip
+		}
+
+		return interval, nil
+	}
+
+	return f, schedule
+}
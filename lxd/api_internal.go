@@ -33,15 +33,19 @@ import (
 var apiInternal = []APIEndpoint{
 	internalReadyCmd,
 	internalShutdownCmd,
+	internalHealthCmd,
 	internalContainerOnStartCmd,
+	internalContainerOnStartHostCmd,
 	internalContainerOnStopNSCmd,
 	internalContainerOnStopCmd,
+	internalContainerOnKeepAliveCmd,
 	internalContainersCmd,
 	internalSQLCmd,
 	internalClusterAcceptCmd,
 	internalClusterRebalanceCmd,
 	internalClusterPromoteCmd,
 	internalClusterContainerMovedCmd,
+	internalClusterSecretsKeyCmd,
 	internalGarbageCollectorCmd,
 	internalRAFTSnapshotCmd,
 }
@@ -64,6 +68,12 @@ var internalContainerOnStartCmd = APIEndpoint{
 	Get: APIEndpointAction{Handler: internalContainerOnStart},
 }
 
+var internalContainerOnStartHostCmd = APIEndpoint{
+	Name: "containers/{id}/onstarthost",
+
+	Get: APIEndpointAction{Handler: internalContainerOnStartHost},
+}
+
 var internalContainerOnStopNSCmd = APIEndpoint{
 	Name: "containers/{id}/onstopns",
 
@@ -76,6 +86,12 @@ var internalContainerOnStopCmd = APIEndpoint{
 	Get: APIEndpointAction{Handler: internalContainerOnStop},
 }
 
+var internalContainerOnKeepAliveCmd = APIEndpoint{
+	Name: "containers/{id}/onkeepalive",
+
+	Get: APIEndpointAction{Handler: internalContainerOnKeepAlive},
+}
+
 var internalSQLCmd = APIEndpoint{
 	Name: "sql",
 
@@ -137,6 +153,47 @@ func internalContainerOnStart(d *Daemon, r *http.Request) Response {
 	return EmptySyncResponse
 }
 
+// internalContainerOnKeepAlive lets hooks taking a while extend the timeout
+// of the running start, stop or restore operation of the container.
+func internalContainerOnKeepAlive(d *Daemon, r *http.Request) Response {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return SmartError(err)
+	}
+
+	err = containerOperationKeepAlive(id)
+	if err != nil {
+		return NotFound(err)
+	}
+
+	return EmptySyncResponse
+}
+
+func internalContainerOnStartHost(d *Daemon, r *http.Request) Response {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return SmartError(err)
+	}
+
+	pid, err := strconv.Atoi(queryParam(r, "pid"))
+	if err != nil {
+		return BadRequest(fmt.Errorf("Invalid container PID"))
+	}
+
+	c, err := containerLoadById(d.State(), id)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	err = c.OnStartHost(pid)
+	if err != nil {
+		logger.Error("The start-host hook failed", log.Ctx{"container": c.Name(), "err": err})
+		return SmartError(err)
+	}
+
+	return EmptySyncResponse
+}
+
 func internalContainerOnStopNS(d *Daemon, r *http.Request) Response {
 	id, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
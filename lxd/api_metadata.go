@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+)
+
+var metadataConfigurationCmd = APIEndpoint{
+	Name: "metadata/configuration",
+
+	Get: APIEndpointAction{Handler: metadataConfigurationGet, AccessHandler: AllowAuthenticated},
+}
+
+// /1.0/metadata/configuration
+// Get the list of supported configuration keys
+func metadataConfigurationGet(d *Daemon, r *http.Request) Response {
+	metadata := api.MetadataConfiguration{
+		Configs: map[string]map[string]api.MetadataConfigurationKey{
+			"container":    metadataConfigurationKeys(shared.KnownContainerConfigKeys, shared.KnownContainerConfigNamespaces),
+			"network":      metadataConfigurationKeys(networkConfigKeys),
+			"storage-pool": metadataConfigurationKeys(storagePoolConfigKeys),
+		},
+	}
+
+	return SyncResponse(true, metadata)
+}
+
+// metadataConfigurationKeys returns the description of the provided
+// configuration keys. Internal "volatile." keys of containers aren't
+// described.
+func metadataConfigurationKeys(configKeys ...map[string]shared.ConfigKey) map[string]api.MetadataConfigurationKey {
+	metadata := map[string]api.MetadataConfigurationKey{}
+	for _, keys := range configKeys {
+		for key, configKey := range keys {
+			if configKey.Description == "" && strings.HasPrefix(key, "volatile.") {
+				continue
+			}
+
+			metadata[key] = configKey.Metadata()
+		}
+	}
+
+	return metadata
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lxc/lxd/shared"
+)
+
+// All the configuration keys but the internal ones must be described.
+func TestMetadataConfiguration_Described(t *testing.T) {
+	configKeys := map[string]map[string]shared.ConfigKey{
+		"container":           shared.KnownContainerConfigKeys,
+		"container namespace": shared.KnownContainerConfigNamespaces,
+		"network":             networkConfigKeys,
+		"storage pool":        storagePoolConfigKeys,
+	}
+
+	for entity, keys := range configKeys {
+		for key, configKey := range keys {
+			assert.NotNil(t, configKey.Validator, "Missing validator for %s key %q", entity, key)
+
+			if entity == "container" && strings.HasPrefix(key, "volatile.") {
+				continue
+			}
+
+			assert.NotEmpty(t, configKey.Type, "Missing type for %s key %q", entity, key)
+			assert.NotEmpty(t, configKey.Description, "Missing description for %s key %q", entity, key)
+		}
+	}
+}
+
+// Container keys in a namespace are validated by the namespace.
+func TestMetadataConfiguration_ContainerNamespaces(t *testing.T) {
+	for namespace := range shared.KnownContainerConfigNamespaces {
+		_, err := shared.ConfigKeyChecker(strings.Replace(namespace, "*", "foo.bar", 1))
+		assert.NoError(t, err, "Namespace %q doesn't validate its keys", namespace)
+
+		_, err = shared.ConfigKeyChecker(strings.Replace(namespace, "*", "", 1))
+		assert.Error(t, err, "Namespace %q validates keys without a sub-key", namespace)
+	}
+}
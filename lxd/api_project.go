@@ -243,7 +243,7 @@ func projectPut(d *Daemon, r *http.Request) Response {
 		return BadRequest(err)
 	}
 
-	return projectChange(d, project, req)
+	return projectChange(d, r, project, req)
 }
 
 func projectPatch(d *Daemon, r *http.Request) Response {
@@ -310,11 +310,11 @@ func projectPatch(d *Daemon, r *http.Request) Response {
 		req.Config["features.images"] = project.Config["features.profiles"]
 	}
 
-	return projectChange(d, project, req)
+	return projectChange(d, r, project, req)
 }
 
 // Common logic between PUT and PATCH.
-func projectChange(d *Daemon, project *api.Project, req api.ProjectPut) Response {
+func projectChange(d *Daemon, r *http.Request, project *api.Project, req api.ProjectPut) Response {
 	// Flag indicating if any feature has changed.
 	featuresChanged := req.Config["features.images"] != project.Config["features.images"] || req.Config["features.profiles"] != project.Config["features.profiles"]
 
@@ -333,6 +333,20 @@ func projectChange(d *Daemon, project *api.Project, req api.ProjectPut) Response
 		return BadRequest(err)
 	}
 
+	// Only administrators may set the keys restricted to them as defaults
+	err = containerConfigCheckAdmin(d, r, projectDefaultsProfile(project.Config).Config, projectDefaultsProfile(req.Config).Config)
+	if err != nil {
+		return Forbidden(err)
+	}
+
+	// Only administrators may lift the restrictions of a project
+	if !d.userIsAdmin(r) {
+		err = projectRestrictionsCheckAdmin(project.Config, req.Config)
+		if err != nil {
+			return Forbidden(err)
+		}
+	}
+
 	// Update the database entry
 	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
 		err := tx.ProjectUpdate(project.Name, req)
@@ -487,8 +501,31 @@ func projectIsEmpty(project *api.Project) bool {
 
 // Validate the project configuration
 var projectConfigKeys = map[string]func(value string) error{
-	"features.profiles": shared.IsBool,
-	"features.images":   shared.IsBool,
+	"features.profiles":   shared.IsBool,
+	"features.images":     shared.IsBool,
+	"images.auto_rebuild": shared.IsBool,
+	"restricted.pools":    shared.IsAny,
+	"default.pool":        shared.IsAny,
+	"default.protected":   shared.IsAny,
+	"restricted":          shared.IsBool,
+	"restricted.containers.privilege": func(value string) error {
+		return shared.IsOneOf(value, []string{"unprivileged", "allow"})
+	},
+	"restricted.devices.disk": func(value string) error {
+		return shared.IsOneOf(value, []string{"managed", "allow"})
+	},
+	"restricted.devices.unix": func(value string) error {
+		return shared.IsOneOf(value, []string{"block", "allow"})
+	},
+	"restricted.devices.nic": func(value string) error {
+		return shared.IsOneOf(value, []string{"block", "managed", "allow"})
+	},
+	"restricted.devices.proxy": func(value string) error {
+		return shared.IsOneOf(value, []string{"block", "allow"})
+	},
+	"restricted.raw.lxc": func(value string) error {
+		return shared.IsOneOf(value, []string{"block", "allow"})
+	},
 }
 
 func projectValidateConfig(config map[string]string) error {
@@ -500,6 +537,16 @@ func projectValidateConfig(config map[string]string) error {
 			continue
 		}
 
+		// Default configuration and devices of the containers
+		if strings.HasPrefix(key, "default.config.") || strings.HasPrefix(key, "default.devices.") {
+			err := projectDefaultsValidateKey(key, v)
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
 		// Then validate
 		validator, ok := projectConfigKeys[key]
 		if !ok {
@@ -512,5 +559,61 @@ func projectValidateConfig(config map[string]string) error {
 		}
 	}
 
+	err := projectDefaultsValidate(config)
+	if err != nil {
+		return err
+	}
+
+	// The default pool must be one the project can use
+	if config["default.pool"] != "" && config["restricted.pools"] != "" {
+		if !shared.StringInSlice(config["default.pool"], projectRestrictedPools(config)) {
+			return fmt.Errorf("The default pool %q isn't part of the restricted pools", config["default.pool"])
+		}
+	}
+
+	return nil
+}
+
+// projectRestrictedPools returns the storage pools the containers of the
+// project are restricted to, or nil if they can use any.
+func projectRestrictedPools(config map[string]string) []string {
+	if config["restricted.pools"] == "" {
+		return nil
+	}
+
+	pools := []string{}
+	for _, pool := range strings.Split(config["restricted.pools"], ",") {
+		pool = strings.TrimSpace(pool)
+		if pool != "" {
+			pools = append(pools, pool)
+		}
+	}
+
+	return pools
+}
+
+// projectStoragePools returns the default storage pool of the project along
+// with the pools its containers are restricted to.
+func projectStoragePools(cluster *db.Cluster, project string) (string, []string, error) {
+	config, err := projectConfig(cluster, project)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return config["default.pool"], projectRestrictedPools(config), nil
+}
+
+// projectStoragePoolCheck returns an error if the project restricts the
+// storage pools its containers can use and the given one isn't part of them.
+func projectStoragePoolCheck(cluster *db.Cluster, project string, pool string) error {
+	_, restricted, err := projectStoragePools(cluster, project)
+	if err != nil {
+		return err
+	}
+
+	if restricted != nil && !shared.StringInSlice(pool, restricted) {
+		return fmt.Errorf("Storage pool %q isn't allowed in project %q", pool, project)
+	}
+
 	return nil
 }
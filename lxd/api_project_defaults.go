@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+)
+
+// projectDefaultsProfile returns the default configuration and devices of the
+// containers of a project, set through its default.config.<key> and
+// default.devices.<device>.<property> keys.
+func projectDefaultsProfile(projectConfig map[string]string) api.Profile {
+	profile := api.Profile{}
+	profile.Config = map[string]string{}
+	profile.Devices = map[string]map[string]string{}
+
+	for k, v := range projectConfig {
+		if strings.HasPrefix(k, "default.config.") {
+			profile.Config[strings.TrimPrefix(k, "default.config.")] = v
+			continue
+		}
+
+		if strings.HasPrefix(k, "default.devices.") {
+			fields := strings.SplitN(strings.TrimPrefix(k, "default.devices."), ".", 2)
+			if len(fields) != 2 {
+				continue
+			}
+
+			if profile.Devices[fields[0]] == nil {
+				profile.Devices[fields[0]] = map[string]string{}
+			}
+
+			profile.Devices[fields[0]][fields[1]] = v
+		}
+	}
+
+	return profile
+}
+
+// projectDefaultsProtected returns the keys of the default configuration of a
+// project which its containers and profiles can't override.
+func projectDefaultsProtected(projectConfig map[string]string) []string {
+	protected := []string{}
+	for _, key := range strings.Split(projectConfig["default.protected"], ",") {
+		key = strings.TrimSpace(key)
+		if key != "" {
+			protected = append(protected, key)
+		}
+	}
+
+	return protected
+}
+
+// projectExpandConfig expands the configuration of a container with its
+// profiles on top of the default configuration of its project, the protected
+// keys of which always win.
+func projectExpandConfig(projectConfig map[string]string, localConfig map[string]string, profiles []api.Profile) map[string]string {
+	defaults := projectDefaultsProfile(projectConfig)
+
+	expandedConfig := db.ProfilesExpandConfig(localConfig, append([]api.Profile{defaults}, profiles...))
+	for _, key := range projectDefaultsProtected(projectConfig) {
+		expandedConfig[key] = defaults.Config[key]
+	}
+
+	return expandedConfig
+}
+
+// projectExpandDevices expands the devices of a container with its profiles
+// on top of the default devices of its project.
+func projectExpandDevices(projectConfig map[string]string, localDevices config.Devices, profiles []api.Profile) config.Devices {
+	defaults := projectDefaultsProfile(projectConfig)
+
+	return db.ProfilesExpandDevices(localDevices, append([]api.Profile{defaults}, profiles...))
+}
+
+// projectDefaultsCheckConfig returns an error if the configuration of a
+// container or profile overrides a protected key of its project.
+func projectDefaultsCheckConfig(projectConfig map[string]string, containerConfig map[string]string) error {
+	defaults := projectDefaultsProfile(projectConfig)
+
+	for _, key := range projectDefaultsProtected(projectConfig) {
+		value, ok := containerConfig[key]
+		if ok && value != defaults.Config[key] {
+			return fmt.Errorf("The %q configuration key is protected by the project", key)
+		}
+	}
+
+	return nil
+}
+
+// projectDefaultsValidateKey validates a default.config.<key> or
+// default.devices.<device>.<property> project key.
+func projectDefaultsValidateKey(key string, value string) error {
+	if strings.HasPrefix(key, "default.config.") {
+		containerKey := strings.TrimPrefix(key, "default.config.")
+		if strings.HasPrefix(containerKey, "volatile.") {
+			return fmt.Errorf("Volatile keys can't be set in the default configuration")
+		}
+
+		validator, err := shared.ConfigKeyChecker(containerKey)
+		if err != nil {
+			return err
+		}
+
+		return validator(value)
+	}
+
+	fields := strings.SplitN(strings.TrimPrefix(key, "default.devices."), ".", 2)
+	if len(fields) != 2 || fields[0] == "" || fields[1] == "" {
+		return fmt.Errorf("Invalid project configuration key: %s", key)
+	}
+
+	return nil
+}
+
+// projectDefaultsValidate checks that the default devices of a project have a
+// type and that its protected keys have a default value.
+func projectDefaultsValidate(projectConfig map[string]string) error {
+	defaults := projectDefaultsProfile(projectConfig)
+
+	for name, m := range defaults.Devices {
+		if m["type"] == "" {
+			return fmt.Errorf("Default device %q is missing its type", name)
+		}
+	}
+
+	for _, key := range projectDefaultsProtected(projectConfig) {
+		_, ok := defaults.Config[key]
+		if !ok {
+			return fmt.Errorf("The protected key %q has no default value", key)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/shared/api"
+)
+
+func TestProjectExpandConfig(t *testing.T) {
+	projectConfig := map[string]string{
+		"default.config.limits.memory":       "2GB",
+		"default.config.security.nesting":    "false",
+		"default.config.security.privileged": "false",
+		"default.protected":                  "security.privileged",
+	}
+
+	profiles := []api.Profile{
+		{ProfilePut: api.ProfilePut{Config: map[string]string{"limits.memory": "4GB", "security.privileged": "true"}}},
+	}
+
+	expanded := projectExpandConfig(projectConfig, map[string]string{"security.nesting": "true"}, profiles)
+	assert.Equal(t, map[string]string{
+		"limits.memory":       "4GB",
+		"security.nesting":    "true",
+		"security.privileged": "false",
+	}, expanded)
+}
+
+func TestProjectExpandDevices(t *testing.T) {
+	projectConfig := map[string]string{
+		"default.devices.root.type": "disk",
+		"default.devices.root.path": "/",
+		"default.devices.root.pool": "default",
+	}
+
+	devices := config.Devices{"eth0": {"type": "nic", "nictype": "bridged", "parent": "lxdbr0"}}
+
+	expanded := projectExpandDevices(projectConfig, devices, nil)
+	assert.Equal(t, config.Devices{
+		"eth0": {"type": "nic", "nictype": "bridged", "parent": "lxdbr0"},
+		"root": {"type": "disk", "path": "/", "pool": "default"},
+	}, expanded)
+}
+
+func TestProjectDefaultsCheckConfig(t *testing.T) {
+	projectConfig := map[string]string{
+		"default.config.security.privileged": "false",
+		"default.protected":                  "security.privileged",
+	}
+
+	assert.NoError(t, projectDefaultsCheckConfig(projectConfig, map[string]string{"limits.cpu": "2"}))
+	assert.NoError(t, projectDefaultsCheckConfig(projectConfig, map[string]string{"security.privileged": "false"}))
+	assert.Error(t, projectDefaultsCheckConfig(projectConfig, map[string]string{"security.privileged": "true"}))
+}
+
+func TestProjectValidateConfig_Defaults(t *testing.T) {
+	assert.NoError(t, projectValidateConfig(map[string]string{
+		"default.config.limits.memory": "2GB",
+		"default.devices.root.type":    "disk",
+		"default.devices.root.path":    "/",
+		"default.protected":            "limits.memory",
+	}))
+
+	assert.Error(t, projectValidateConfig(map[string]string{"default.config.limits.memory": "lots"}))
+	assert.Error(t, projectValidateConfig(map[string]string{"default.config.volatile.base_image": "abc"}))
+	assert.Error(t, projectValidateConfig(map[string]string{"default.devices.root": "disk"}))
+	assert.Error(t, projectValidateConfig(map[string]string{"default.devices.root.path": "/"}))
+	assert.Error(t, projectValidateConfig(map[string]string{"default.protected": "limits.memory"}))
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+
+	lxd "github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/node"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+)
+
+var projectContainersProtectionCmd = APIEndpoint{
+	Name: "projects/{name}/containers/protection",
+
+	Put: APIEndpointAction{Handler: projectContainersProtectionPut, AccessHandler: AllowAuthenticated},
+}
+
+// Set or clear security.protection.start on all the containers of a project,
+// e.g. to put them in maintenance.
+func projectContainersProtectionPut(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	// Check user permissions
+	if !d.userHasPermission(r, name, "manage-containers") {
+		return Forbidden(nil)
+	}
+
+	req := api.ProjectContainersProtectionPut{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	// Clearing the key lets the profiles decide again
+	value := ""
+	if req.Start {
+		value = "true"
+	}
+
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		_, err := tx.ProjectGet(name)
+		return err
+	})
+	if err != nil {
+		return SmartError(err)
+	}
+
+	// Go through the regular container update for the containers of this
+	// node, so that the change is validated and recorded like any other
+	containers, err := containerLoadNodeProjectAll(d.State(), name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	admin := d.userIsAdmin(r)
+	for _, c := range containers {
+		if c.LocalConfig()["security.protection.start"] == value {
+			continue
+		}
+
+		config := map[string]string{}
+		for k, v := range c.LocalConfig() {
+			config[k] = v
+		}
+
+		if value == "" {
+			delete(config, "security.protection.start")
+		} else {
+			config["security.protection.start"] = value
+		}
+
+		err = c.Update(db.ContainerArgs{
+			Architecture: c.Architecture(),
+			Config:       config,
+			Description:  c.Description(),
+			Devices:      c.LocalDevices(),
+			Ephemeral:    c.IsEphemeral(),
+			ExpiryDate:   c.ExpiryDate(),
+			Profiles:     c.Profiles(),
+			Project:      c.Project(),
+			Admin:        admin,
+		}, true)
+		if err != nil {
+			return SmartError(errors.Wrapf(err, "Failed to update container %q", c.Name()))
+		}
+	}
+
+	// Have the other nodes update their own containers
+	if !isClusterNotification(r) {
+		notifier, err := cluster.NewNotifier(d.State(), d.endpoints.NetworkCert(), cluster.NotifyAlive)
+		if err != nil {
+			return SmartError(err)
+		}
+
+		err = notifier(func(client lxd.ContainerServer) error {
+			_, _, err := client.RawQuery("PUT", fmt.Sprintf("/1.0/projects/%s/containers/protection", name), req, "")
+			return err
+		})
+		if err != nil {
+			return SmartError(err)
+		}
+	}
+
+	return EmptySyncResponse
+}
+
+// containerProtectionStartCheck returns an error if the container or the
+// node it's on is protected from starting containers.
+func containerProtectionStartCheck(c container) error {
+	if shared.IsTrue(c.ExpandedConfig()["security.protection.start"]) {
+		return fmt.Errorf("Container is protected from being started (security.protection.start)")
+	}
+
+	var protected bool
+	err := c.DaemonState().Node.Transaction(func(tx *db.NodeTx) error {
+		config, err := node.ConfigLoad(tx)
+		if err != nil {
+			return err
+		}
+
+		protected = config.ProtectionStart()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if protected {
+		return fmt.Errorf("The server is protected from starting containers (security.protection.start)")
+	}
+
+	return nil
+}
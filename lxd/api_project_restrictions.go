@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/shared"
+)
+
+// Default values of the restrictions of a project with restricted=true.
+var projectRestrictionsDefaults = map[string]string{
+	"restricted.containers.privilege": "unprivileged",
+	"restricted.devices.disk":         "managed",
+	"restricted.devices.unix":         "block",
+	"restricted.devices.nic":          "managed",
+	"restricted.devices.proxy":        "block",
+	"restricted.raw.lxc":              "block",
+}
+
+// projectConfig returns the configuration of a project.
+func projectConfig(cluster *db.Cluster, project string) (map[string]string, error) {
+	var config map[string]string
+	err := cluster.Transaction(func(tx *db.ClusterTx) error {
+		p, err := tx.ProjectGet(project)
+		if err != nil {
+			return err
+		}
+
+		config = p.Config
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Fetch project %q", project)
+	}
+
+	return config, nil
+}
+
+// projectRestrictions returns the restrictions which apply to the containers
+// of a project, or nil if it isn't restricted.
+func projectRestrictions(cluster *db.Cluster, project string) (map[string]string, error) {
+	config, err := projectConfig(cluster, project)
+	if err != nil {
+		return nil, err
+	}
+
+	if !shared.IsTrue(config["restricted"]) {
+		return nil, nil
+	}
+
+	restrictions := map[string]string{}
+	for k, v := range projectRestrictionsDefaults {
+		restrictions[k] = v
+		if config[k] != "" {
+			restrictions[k] = config[k]
+		}
+	}
+
+	return restrictions, nil
+}
+
+// projectRestrictionsCheckAdmin returns a permission error if the new config
+// of a project changes its restrictions, which only administrators may do.
+func projectRestrictionsCheckAdmin(oldConfig map[string]string, newConfig map[string]string) error {
+	keys := []string{"restricted", "restricted.pools"}
+	for k := range projectRestrictionsDefaults {
+		keys = append(keys, k)
+	}
+
+	for _, key := range keys {
+		if oldConfig[key] != newConfig[key] {
+			return errors.Wrapf(os.ErrPermission, "Only administrators can change '%s'", key)
+		}
+	}
+
+	return nil
+}
+
+// projectRestrictionsCheckConfig returns an error if the expanded
+// configuration of a container goes against the restrictions of its project.
+func projectRestrictionsCheckConfig(restrictions map[string]string, config map[string]string) error {
+	if restrictions == nil {
+		return nil
+	}
+
+	if restrictions["restricted.containers.privilege"] == "unprivileged" {
+		if shared.IsTrue(config["security.privileged"]) {
+			return fmt.Errorf("Privileged containers are forbidden in this project")
+		}
+
+		if config["raw.idmap"] != "" {
+			return fmt.Errorf("raw.idmap is forbidden in this project")
+		}
+	}
+
+	if restrictions["restricted.raw.lxc"] == "block" && config["raw.lxc"] != "" {
+		return fmt.Errorf("raw.lxc is forbidden in this project")
+	}
+
+	return nil
+}
+
+// projectRestrictionsCheckDevice returns an error if an expanded device of a
+// container goes against the restrictions of its project.
+func projectRestrictionsCheckDevice(cluster *db.Cluster, restrictions map[string]string, name string, m config.Device) error {
+	if restrictions == nil {
+		return nil
+	}
+
+	switch m["type"] {
+	case "disk":
+		// Only the disks backed by a storage pool are allowed, not
+		// host paths or remote filesystems
+		if restrictions["restricted.devices.disk"] == "managed" && m["source"] != "" && m["pool"] == "" {
+			return fmt.Errorf("Device '%s': only disks from storage pools are allowed in this project", name)
+		}
+	case "unix-char", "unix-block", "usb", "hotplug", "gpu", "tpm", "watchdog":
+		if restrictions["restricted.devices.unix"] == "block" {
+			return fmt.Errorf("Device '%s': %s devices are forbidden in this project", name, m["type"])
+		}
+	case "proxy":
+		// Proxies reach into the host, including the LXD socket
+		if restrictions["restricted.devices.proxy"] == "block" {
+			return fmt.Errorf("Device '%s': proxy devices are forbidden in this project", name)
+		}
+	case "nic", "infiniband":
+		if restrictions["restricted.raw.lxc"] == "block" && m["raw.lxc"] != "" {
+			return fmt.Errorf("Device '%s': raw.lxc is forbidden in this project", name)
+		}
+
+		switch restrictions["restricted.devices.nic"] {
+		case "block":
+			return fmt.Errorf("Device '%s': %s devices are forbidden in this project", name, m["type"])
+		case "managed":
+			if m["type"] != "nic" || !shared.StringInSlice(m["nictype"], []string{"bridged", "macvlan"}) {
+				return fmt.Errorf("Device '%s': only bridged and macvlan nics are allowed in this project", name)
+			}
+
+			network := m["network"]
+			if network == "" {
+				network = m["parent"]
+			}
+
+			networks, err := cluster.Networks()
+			if err != nil {
+				return err
+			}
+
+			if !shared.StringInSlice(network, networks) {
+				return fmt.Errorf("Device '%s': only managed networks are allowed in this project", name)
+			}
+		}
+	}
+
+	return nil
+}
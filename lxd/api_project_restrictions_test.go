@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProjectRestrictionsCheckConfig(t *testing.T) {
+	restrictions := map[string]string{}
+	for k, v := range projectRestrictionsDefaults {
+		restrictions[k] = v
+	}
+
+	assert.NoError(t, projectRestrictionsCheckConfig(nil, map[string]string{"security.privileged": "true", "raw.lxc": "lxc.apparmor.profile=unconfined"}))
+	assert.NoError(t, projectRestrictionsCheckConfig(restrictions, map[string]string{"limits.cpu": "2"}))
+	assert.Error(t, projectRestrictionsCheckConfig(restrictions, map[string]string{"security.privileged": "true"}))
+	assert.Error(t, projectRestrictionsCheckConfig(restrictions, map[string]string{"raw.idmap": "both 1000 1000"}))
+	assert.Error(t, projectRestrictionsCheckConfig(restrictions, map[string]string{"raw.lxc": "lxc.apparmor.profile=unconfined"}))
+
+	restrictions["restricted.containers.privilege"] = "allow"
+	restrictions["restricted.raw.lxc"] = "allow"
+	assert.NoError(t, projectRestrictionsCheckConfig(restrictions, map[string]string{"security.privileged": "true", "raw.lxc": "lxc.apparmor.profile=unconfined"}))
+}
+
+func TestProjectRestrictionsCheckDevice(t *testing.T) {
+	restrictions := map[string]string{}
+	for k, v := range projectRestrictionsDefaults {
+		restrictions[k] = v
+	}
+
+	assert.NoError(t, projectRestrictionsCheckDevice(nil, restrictions, "root", map[string]string{"type": "disk", "path": "/", "pool": "default"}))
+	assert.NoError(t, projectRestrictionsCheckDevice(nil, restrictions, "data", map[string]string{"type": "disk", "path": "/data", "pool": "default", "source": "data"}))
+	assert.Error(t, projectRestrictionsCheckDevice(nil, restrictions, "host", map[string]string{"type": "disk", "path": "/host", "source": "/"}))
+	assert.Error(t, projectRestrictionsCheckDevice(nil, restrictions, "kvm", map[string]string{"type": "unix-char", "path": "/dev/kvm"}))
+	assert.Error(t, projectRestrictionsCheckDevice(nil, restrictions, "tpm0", map[string]string{"type": "tpm", "path": "/dev/tpm0"}))
+	assert.Error(t, projectRestrictionsCheckDevice(nil, restrictions, "watchdog", map[string]string{"type": "watchdog"}))
+	assert.Error(t, projectRestrictionsCheckDevice(nil, restrictions, "lxd", map[string]string{"type": "proxy", "listen": "unix:/lxd.socket", "connect": "unix:/var/lib/lxd/unix.socket", "bind": "container"}))
+	assert.Error(t, projectRestrictionsCheckDevice(nil, restrictions, "eth1", map[string]string{"type": "nic", "nictype": "physical", "parent": "eth1"}))
+	assert.Error(t, projectRestrictionsCheckDevice(nil, restrictions, "eth0", map[string]string{"type": "nic", "nictype": "bridged", "parent": "lxdbr0", "raw.lxc": "flags = up"}))
+
+	restrictions["restricted.devices.unix"] = "allow"
+	restrictions["restricted.devices.nic"] = "allow"
+	restrictions["restricted.devices.disk"] = "allow"
+	restrictions["restricted.devices.proxy"] = "allow"
+	assert.NoError(t, projectRestrictionsCheckDevice(nil, restrictions, "kvm", map[string]string{"type": "unix-char", "path": "/dev/kvm"}))
+	assert.NoError(t, projectRestrictionsCheckDevice(nil, restrictions, "eth1", map[string]string{"type": "nic", "nictype": "physical", "parent": "eth1"}))
+	assert.NoError(t, projectRestrictionsCheckDevice(nil, restrictions, "host", map[string]string{"type": "disk", "path": "/host", "source": "/"}))
+	assert.NoError(t, projectRestrictionsCheckDevice(nil, restrictions, "lxd", map[string]string{"type": "proxy", "listen": "unix:/lxd.socket", "connect": "unix:/var/lib/lxd/unix.socket", "bind": "container"}))
+}
+
+func TestProjectRestrictionsCheckAdmin(t *testing.T) {
+	config := map[string]string{"restricted": "true", "restricted.devices.unix": "block", "limits.containers": "10"}
+
+	assert.NoError(t, projectRestrictionsCheckAdmin(config, map[string]string{"restricted": "true", "restricted.devices.unix": "block", "limits.containers": "5"}))
+	assert.Error(t, projectRestrictionsCheckAdmin(config, map[string]string{"restricted.devices.unix": "block"}))
+	assert.Error(t, projectRestrictionsCheckAdmin(config, map[string]string{"restricted": "true"}))
+	assert.Error(t, projectRestrictionsCheckAdmin(config, map[string]string{"restricted": "true", "restricted.devices.unix": "block", "restricted.pools": "default"}))
+}
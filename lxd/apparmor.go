@@ -6,9 +6,11 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"path"
 	"strings"
 
+	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/project"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/logger"
@@ -512,10 +514,68 @@ func AAProfileShort(c container) string {
 	return fmt.Sprintf("lxd-%s", name)
 }
 
+// AAProfileName returns the name of the AppArmor profile confining the
+// container, being the one set through security.apparmor.profile if any.
+func AAProfileName(c container) string {
+	name := c.ExpandedConfig()["security.apparmor.profile"]
+	if name != "" {
+		return AAManagedProfileName(name)
+	}
+
+	return AAProfileFull(c)
+}
+
+// AAManagedProfileName returns the name in the kernel of an AppArmor profile
+// managed by LXD, kept apart from the profiles of the host.
+func AAManagedProfileName(name string) string {
+	return fmt.Sprintf("lxd-%s", name)
+}
+
+// aaCustomProfile returns the AppArmor profile managed by LXD which the
+// container uses through security.apparmor.profile, nil if none.
+func aaCustomProfile(c container) (*db.ApparmorProfile, error) {
+	name := c.ExpandedConfig()["security.apparmor.profile"]
+	if name == "" {
+		return nil, nil
+	}
+
+	var profile db.ApparmorProfile
+	err := c.DaemonState().Cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		profile, err = tx.ApparmorProfileGet(name)
+		return err
+	})
+	if err == db.ErrNoSuchObject {
+		return nil, fmt.Errorf("AppArmor profile %q doesn't exist", name)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &profile, nil
+}
+
 // getProfileContent generates the apparmor profile template from the given
 // container. This includes the stock lxc includes as well as stuff from
-// raw.apparmor.
-func getAAProfileContent(c container) string {
+// raw.apparmor, unless the container uses a profile managed by LXD through
+// security.apparmor.profile.
+func getAAProfileContent(c container) (string, error) {
+	profile, err := aaCustomProfile(c)
+	if err != nil {
+		return "", err
+	}
+
+	if profile != nil {
+		return profile.Content, nil
+	}
+
+	return aaProfileContent(c, c.ExpandedConfig(), nil), nil
+}
+
+// aaProfileContent generates the apparmor profile of a container using the
+// given configuration, optionally with extra rules which only get audited.
+func aaProfileContent(c container, config map[string]string, audited []string) string {
 	profile := strings.TrimLeft(AA_PROFILE_BASE, "\n")
 
 	// Apply new features
@@ -589,7 +649,7 @@ func getAAProfileContent(c container) string {
 	}
 
 	// Append raw.apparmor
-	rawApparmor, ok := c.ExpandedConfig()["raw.apparmor"]
+	rawApparmor, ok := config["raw.apparmor"]
 	if ok {
 		profile += "\n  ### Configuration: raw.apparmor\n"
 		for _, line := range strings.Split(strings.Trim(rawApparmor, "\n"), "\n") {
@@ -597,6 +657,13 @@ func getAAProfileContent(c container) string {
 		}
 	}
 
+	if len(audited) > 0 {
+		profile += "\n  ### Test: audited rules\n"
+		for _, rule := range audited {
+			profile += fmt.Sprintf("  audit %s\n", rule)
+		}
+	}
+
 	return fmt.Sprintf(`#include <tunables/global>
 profile "%s" flags=(attach_disconnected,mediate_deleted) {
 %s
@@ -688,7 +755,10 @@ func AALoadProfile(c container) error {
 		return err
 	}
 
-	updated := getAAProfileContent(c)
+	updated, err := getAAProfileContent(c)
+	if err != nil {
+		return err
+	}
 
 	if string(content) != string(updated) {
 		if err := os.MkdirAll(path.Join(aaPath, "cache"), 0700); err != nil {
@@ -707,6 +777,58 @@ func AALoadProfile(c container) error {
 	return runApparmor(APPARMOR_CMD_LOAD, c)
 }
 
+// Replace the container's policy in the kernel by its enforced policy along
+// with the given rules, which only get audited. The policy on disk and in the
+// cache is left alone, AALoadProfile puts it back in the kernel.
+func AALoadTestProfile(c container, audited []string) error {
+	state := c.DaemonState()
+	if !state.OS.AppArmorAdmin {
+		return fmt.Errorf("AppArmor policies can't be managed on this system")
+	}
+
+	err := os.MkdirAll(path.Join(aaPath, "profiles"), 0700)
+	if err != nil {
+		return err
+	}
+
+	// Keep the test policy out of the cache and away from the real one, it's
+	// only removed once the real one is back in place.
+	err = ioutil.WriteFile(aaTestProfilePath(c), []byte(aaProfileContent(c, c.ExpandedConfig(), audited)), 0600)
+	if err != nil {
+		return err
+	}
+
+	_, err = shared.RunCommand("apparmor_parser", "-rK", aaTestProfilePath(c))
+	if err != nil {
+		os.Remove(aaTestProfilePath(c))
+		return fmt.Errorf("Failed to load the AppArmor policy: %v", err)
+	}
+
+	return nil
+}
+
+// AAUnloadTestProfile puts the enforced policy of the container back in the
+// kernel in place of the test one.
+func AAUnloadTestProfile(c container) error {
+	err := AALoadProfile(c)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(aaTestProfilePath(c))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// aaTestProfilePath returns the path of the policy being tested on the
+// container.
+func aaTestProfilePath(c container) string {
+	return path.Join(aaPath, "profiles", fmt.Sprintf("%s.test", AAProfileShort(c)))
+}
+
 // Ensure that the container's policy namespace is unloaded to free kernel
 // memory. This does not delete the policy from disk or cache.
 func AADestroy(c container) error {
@@ -722,6 +844,11 @@ func AADestroy(c container) error {
 		}
 	}
 
+	// Profiles managed by LXD may be shared with other containers
+	if c.ExpandedConfig()["security.apparmor.profile"] != "" {
+		return nil
+	}
+
 	return runApparmor(APPARMOR_CMD_UNLOAD, c)
 }
 
@@ -732,9 +859,77 @@ func AAParseProfile(c container) error {
 		return nil
 	}
 
+	profile, err := aaCustomProfile(c)
+	if err != nil {
+		return err
+	}
+
+	if profile != nil {
+		return AAParseProfileContent(profile.Name, profile.Content)
+	}
+
 	return runApparmor(APPARMOR_CMD_PARSE, c)
 }
 
+// AAParseProfileContent checks that the given content is a valid AppArmor
+// policy only defining the profile managed by LXD of the given name, without
+// loading it into the kernel.
+func AAParseProfileContent(name string, content string) error {
+	// Nothing else may tell whether the policy replaces host profiles
+	_, err := exec.LookPath("apparmor_parser")
+	if err != nil {
+		return fmt.Errorf("AppArmor profiles can't be checked without apparmor_parser")
+	}
+
+	file, err := ioutil.TempFile("", "lxd_apparmor_")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	_, err = file.WriteString(content)
+	if err != nil {
+		return err
+	}
+
+	_, stderr, err := shared.RunCommandSplit("apparmor_parser", "-QK", file.Name())
+	if err != nil {
+		return fmt.Errorf("Invalid AppArmor policy: %s", strings.TrimSpace(stderr))
+	}
+
+	names, stderr, err := shared.RunCommandSplit("apparmor_parser", "-N", file.Name())
+	if err != nil {
+		return fmt.Errorf("Invalid AppArmor policy: %s", strings.TrimSpace(stderr))
+	}
+
+	return aaProfileNamesCheck(strings.Split(strings.TrimSpace(names), "\n"), name)
+}
+
+// aaProfileNamesCheck checks that the profiles defined by the policy of an
+// AppArmor profile managed by LXD are the profile itself and its children.
+func aaProfileNamesCheck(names []string, name string) error {
+	expected := AAManagedProfileName(name)
+
+	found := false
+	for _, profile := range names {
+		if profile == expected {
+			found = true
+			continue
+		}
+
+		if !strings.HasPrefix(profile, fmt.Sprintf("%s//", expected)) {
+			return fmt.Errorf("The AppArmor policy may only define the %q profile, not %q", expected, profile)
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("The AppArmor policy doesn't define a profile named %q", expected)
+	}
+
+	return nil
+}
+
 // Delete the policy from cache/disk.
 func AADeleteProfile(c container) {
 	state := c.DaemonState()
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/version"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+var apparmorProfilesCmd = APIEndpoint{
+	Name: "apparmor-profiles",
+
+	Get:  APIEndpointAction{Handler: apparmorProfilesGet},
+	Post: APIEndpointAction{Handler: apparmorProfilesPost},
+}
+
+var apparmorProfileCmd = APIEndpoint{
+	Name: "apparmor-profiles/{name}",
+
+	Delete: APIEndpointAction{Handler: apparmorProfileDelete},
+	Get:    APIEndpointAction{Handler: apparmorProfileGet},
+	Put:    APIEndpointAction{Handler: apparmorProfilePut},
+}
+
+// AppArmor profile names, which also name the profile in the kernel.
+var apparmorProfileNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// apparmorProfileLoad returns the AppArmor profile with the given name along
+// with what uses it.
+func apparmorProfileLoad(d *Daemon, name string) (*api.ApparmorProfile, error) {
+	var profile db.ApparmorProfile
+	var usedBy []string
+
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		profile, err = tx.ApparmorProfileGet(name)
+		if err != nil {
+			return err
+		}
+
+		usedBy, err = tx.ApparmorProfileUsedBy(name)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &api.ApparmorProfile{
+		ApparmorProfilePut: api.ApparmorProfilePut{
+			Description: profile.Description,
+			Content:     profile.Content,
+		},
+		Name:   profile.Name,
+		UsedBy: usedBy,
+	}, nil
+}
+
+func apparmorProfilesGet(d *Daemon, r *http.Request) Response {
+	recursion := util.IsRecursionRequest(r)
+
+	var names []string
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		names, err = tx.ApparmorProfileNames()
+		return err
+	})
+	if err != nil {
+		return SmartError(err)
+	}
+
+	resultString := []string{}
+	resultMap := []api.ApparmorProfile{}
+	for _, name := range names {
+		if !recursion {
+			resultString = append(resultString, fmt.Sprintf("/%s/apparmor-profiles/%s", version.APIVersion, name))
+		} else {
+			profile, err := apparmorProfileLoad(d, name)
+			if err != nil {
+				continue
+			}
+
+			resultMap = append(resultMap, *profile)
+		}
+	}
+
+	if !recursion {
+		return SyncResponse(true, resultString)
+	}
+
+	return SyncResponse(true, resultMap)
+}
+
+func apparmorProfilesPost(d *Daemon, r *http.Request) Response {
+	req := api.ApparmorProfilesPost{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	if !apparmorProfileNameRegexp.MatchString(req.Name) {
+		return BadRequest(fmt.Errorf("Invalid AppArmor profile name %q", req.Name))
+	}
+
+	err = AAParseProfileContent(req.Name, req.Content)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		_, err := tx.ApparmorProfileGet(req.Name)
+		if err == nil {
+			return db.ErrAlreadyDefined
+		}
+
+		if err != db.ErrNoSuchObject {
+			return err
+		}
+
+		return tx.ApparmorProfileCreate(db.ApparmorProfile{
+			Name:        req.Name,
+			Description: req.Description,
+			Content:     req.Content,
+		})
+	})
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return SyncResponseLocation(true, nil, fmt.Sprintf("/%s/apparmor-profiles/%s", version.APIVersion, req.Name))
+}
+
+func apparmorProfileGet(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	profile, err := apparmorProfileLoad(d, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	etag := []interface{}{profile.Name, profile.Description, profile.Content}
+	return SyncResponseETag(true, profile, etag)
+}
+
+// apparmorProfilePut changes an AppArmor profile and reloads it for the
+// running containers using it, on all nodes.
+func apparmorProfilePut(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	// The profile was already updated by the node notifying us
+	if isClusterNotification(r) {
+		apparmorProfileReload(d, name)
+		return EmptySyncResponse
+	}
+
+	profile, err := apparmorProfileLoad(d, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	// Validate the ETag
+	etag := []interface{}{profile.Name, profile.Description, profile.Content}
+	err = util.EtagCheck(r, etag)
+	if err != nil {
+		return PreconditionFailed(err)
+	}
+
+	req := api.ApparmorProfilePut{}
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	err = AAParseProfileContent(name, req.Content)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		return tx.ApparmorProfileUpdate(name, db.ApparmorProfile{
+			Description: req.Description,
+			Content:     req.Content,
+		})
+	})
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if req.Content != profile.Content {
+		apparmorProfileReload(d, name)
+
+		// Notify all other nodes. If a node is down, it will be ignored.
+		notifier, err := cluster.NewNotifier(d.State(), d.endpoints.NetworkCert(), cluster.NotifyAlive)
+		if err != nil {
+			return SmartError(err)
+		}
+
+		err = notifier(func(client lxd.ContainerServer) error {
+			return client.UpdateApparmorProfile(name, req, "")
+		})
+		if err != nil {
+			return SmartError(err)
+		}
+	}
+
+	return EmptySyncResponse
+}
+
+// apparmorProfileReload loads the new content of an AppArmor profile for the
+// running containers of this node using it.
+func apparmorProfileReload(d *Daemon, name string) {
+	containers, err := containerLoadNodeAll(d.State())
+	if err != nil {
+		logger.Error("Failed to load containers to reload AppArmor profile", log.Ctx{"profile": name, "err": err})
+		return
+	}
+
+	for _, c := range containers {
+		if c.IsSnapshot() || !c.IsRunning() || c.ExpandedConfig()["security.apparmor.profile"] != name {
+			continue
+		}
+
+		err := AALoadProfile(c)
+		if err != nil {
+			logger.Error("Failed to reload AppArmor profile", log.Ctx{"profile": name, "container": c.Name(), "project": c.Project(), "err": err})
+		}
+	}
+}
+
+// apparmorProfileDelete deletes an AppArmor profile, which nothing may use.
+func apparmorProfileDelete(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	profile, err := apparmorProfileLoad(d, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if len(profile.UsedBy) > 0 {
+		return BadRequest(fmt.Errorf("The AppArmor profile is currently in use"))
+	}
+
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		return tx.ApparmorProfileDelete(name)
+	})
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return EmptySyncResponse
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAAProfileNamesCheck(t *testing.T) {
+	assert.NoError(t, aaProfileNamesCheck([]string{"lxd-postgres"}, "postgres"))
+	assert.NoError(t, aaProfileNamesCheck([]string{"lxd-postgres", "lxd-postgres//helper"}, "postgres"))
+
+	// Host profiles can't be replaced
+	assert.Error(t, aaProfileNamesCheck([]string{"postgres"}, "postgres"))
+	assert.Error(t, aaProfileNamesCheck([]string{"lxd-postgres", "/usr/sbin/ntpd"}, "postgres"))
+	assert.Error(t, aaProfileNamesCheck([]string{"lxd-postgres", "lxd-postgres-12"}, "postgres"))
+	assert.Error(t, aaProfileNamesCheck([]string{}, "postgres"))
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/lxc/lxd/lxd/oidc"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// requestIdentity returns who sent an API request and how they authenticated,
+// along with their groups for the OpenID Connect users. The claims of their
+// token are left out as the identity ends up in operation metadata.
+func requestIdentity(r *http.Request) shared.Jmap {
+	username, _ := r.Context().Value("username").(string)
+	protocol, _ := r.Context().Value("protocol").(string)
+
+	identity := shared.Jmap{
+		"username": username,
+		"protocol": protocol,
+		"address":  r.RemoteAddr,
+	}
+
+	oidcIdentity, ok := r.Context().Value("oidc").(*oidc.Identity)
+	if ok {
+		identity["groups"] = oidcIdentity.Groups
+	}
+
+	return identity
+}
+
+// auditLog records an action done on behalf of the sender of an API request.
+func auditLog(r *http.Request, action string, ctx log.Ctx) {
+	identity := requestIdentity(r)
+
+	ctx["action"] = action
+	ctx["user"] = identity["username"]
+	ctx["protocol"] = identity["protocol"]
+	ctx["ip"] = identity["address"]
+
+	oidcIdentity, ok := r.Context().Value("oidc").(*oidc.Identity)
+	if ok {
+		ctx["groups"] = oidcIdentity.Groups
+		ctx["claims"] = oidcIdentity.Claims
+	}
+
+	logger.Info("Audit", ctx)
+}
@@ -2,8 +2,10 @@ package main
 
 import (
 	"archive/tar"
+	"crypto/sha256"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -17,6 +19,7 @@ import (
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/state"
 	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
 	log "github.com/lxc/lxd/shared/log15"
@@ -51,8 +54,10 @@ func backupLoadByName(s *state.State, project, name string) (*backup, error) {
 	}, nil
 }
 
-// Create a new backup
-func backupCreate(s *state.State, args db.ContainerBackupArgs, sourceContainer container) error {
+// Create a new backup, compressed with the given algorithm or else with
+// backups.compression_algorithm, and recorded in chunks of the given size
+// unless zero.
+func backupCreate(s *state.State, args db.ContainerBackupArgs, sourceContainer container, compression string, chunkSize int64) error {
 	// Create the database entry
 	err := s.Cluster.ContainerBackupCreate(args)
 	if err != nil {
@@ -69,6 +74,20 @@ func backupCreate(s *state.State, args db.ContainerBackupArgs, sourceContainer c
 		return errors.Wrap(err, "Load backup object")
 	}
 
+	// Chunked backups default to an algorithm that can be resumed
+	if compression == "" && chunkSize > 0 {
+		compression = backupChunksCompressionDefault
+	} else if compression == "" {
+		compression, err = cluster.ConfigGetString(s.Cluster, "backups.compression_algorithm")
+		if err != nil {
+			s.Cluster.ContainerBackupRemove(args.Name)
+			return err
+		}
+	}
+
+	b.compressionAlgorithm = compression
+	b.chunkSize = chunkSize
+
 	// Now create the empty snapshot
 	err = sourceContainer.Storage().ContainerBackupCreate(*b, sourceContainer)
 	if err != nil {
@@ -91,6 +110,14 @@ type backup struct {
 	expiryDate       time.Time
 	containerOnly    bool
 	optimizedStorage bool
+
+	// Only set when creating the backup
+	compressionAlgorithm string
+	chunkSize            int64
+
+	// Set by the storage drivers which compressed their send streams with
+	// compressionAlgorithm, instead of the whole tarball
+	streamCompression string
 }
 
 type backupInfo struct {
@@ -101,6 +128,9 @@ type backupInfo struct {
 	Pool            string   `json:"pool" yaml:"pool"`
 	Snapshots       []string `json:"snapshots,omitempty" yaml:"snapshots,omitempty"`
 	HasBinaryFormat bool     `json:"-" yaml:"-"`
+
+	// Compression of the send streams of optimized backups
+	Compression string `json:"compression,omitempty" yaml:"compression,omitempty"`
 }
 
 // Rename renames a container backup
@@ -123,6 +153,12 @@ func (b *backup) Rename(newName string) error {
 		return err
 	}
 
+	// Rename the chunk manifest
+	err = backupChunksRename(b.name, newName)
+	if err != nil {
+		return err
+	}
+
 	// Check if we can remove the container directory
 	empty, _ := shared.PathIsEmpty(backupsPath)
 	if empty {
@@ -147,12 +183,18 @@ func (b *backup) Delete() error {
 }
 
 func (b *backup) Render() *api.ContainerBackup {
+	chunks, err := backupChunksLoad(b.name)
+	if err != nil {
+		logger.Error("Failed to load the backup chunks", log.Ctx{"backup": b.name, "err": err})
+	}
+
 	return &api.ContainerBackup{
 		Name:             strings.SplitN(b.name, "/", 2)[1],
 		CreatedAt:        b.creationDate,
 		ExpiresAt:        b.expiryDate,
 		ContainerOnly:    b.containerOnly,
 		OptimizedStorage: b.optimizedStorage,
+		Chunks:           chunks,
 	}
 }
 
@@ -195,6 +237,10 @@ func backupGetInfo(r io.ReadSeeker) (*backupInfo, error) {
 		tr = tar.NewReader(r)
 	}
 
+	// Checksums of the files in the tarball and those recorded at creation
+	var expected map[string]string
+	actual := map[string]string{}
+
 	for {
 		hdr, err := tr.Next()
 		if err == io.EOF {
@@ -204,8 +250,35 @@ func backupGetInfo(r io.ReadSeeker) (*backupInfo, error) {
 			return nil, err
 		}
 
+		name := strings.TrimPrefix(hdr.Name, "backup/")
+
+		if hdr.Typeflag == tar.TypeLink {
+			checksum, ok := actual[strings.TrimPrefix(hdr.Linkname, "backup/")]
+			if ok {
+				actual[name] = checksum
+			}
+
+			continue
+		}
+
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		if hdr.Name == "backup/"+shared.ChecksumsFile {
+			expected, err = shared.ChecksumsParse(tr)
+			if err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		hash := sha256.New()
+		reader := io.TeeReader(tr, hash)
+
 		if hdr.Name == "backup/index.yaml" {
-			err = yaml.NewDecoder(tr).Decode(&result)
+			err = yaml.NewDecoder(reader).Decode(&result)
 			if err != nil {
 				return nil, err
 			}
@@ -216,12 +289,27 @@ func backupGetInfo(r io.ReadSeeker) (*backupInfo, error) {
 		if hdr.Name == "backup/container.bin" {
 			hasBinaryFormat = true
 		}
+
+		_, err = io.Copy(ioutil.Discard, reader)
+		if err != nil {
+			return nil, err
+		}
+
+		actual[name] = fmt.Sprintf("%x", hash.Sum(nil))
 	}
 
 	if !hasIndexFile {
 		return nil, fmt.Errorf("Backup is missing index.yaml")
 	}
 
+	// Backups created before the manifest was introduced can't be verified
+	if expected != nil {
+		err = shared.ChecksumsVerify(expected, actual)
+		if err != nil {
+			return nil, errors.Wrap(err, "Backup integrity check failed")
+		}
+	}
+
 	result.HasBinaryFormat = hasBinaryFormat
 	return &result, nil
 }
@@ -330,11 +418,12 @@ func backupCreateTarball(s *state.State, path string, backup backup) error {
 	}
 
 	indexFile := backupInfo{
-		Name:       container.Name(),
-		Backend:    container.Storage().GetStorageTypeName(),
-		Privileged: container.IsPrivileged(),
-		Pool:       pool,
-		Snapshots:  []string{},
+		Name:        container.Name(),
+		Backend:     container.Storage().GetStorageTypeName(),
+		Privileged:  container.IsPrivileged(),
+		Pool:        pool,
+		Snapshots:   []string{},
+		Compression: backup.streamCompression,
 	}
 
 	if !backup.containerOnly {
@@ -365,6 +454,23 @@ func backupCreateTarball(s *state.State, path string, backup backup) error {
 		return err
 	}
 
+	// Create the checksums manifest
+	checksums, err := shared.ChecksumsFromDir(path)
+	if err != nil {
+		return errors.Wrap(err, "Generate backup checksums")
+	}
+
+	file, err = os.Create(filepath.Join(path, shared.ChecksumsFile))
+	if err != nil {
+		return err
+	}
+
+	err = shared.ChecksumsWrite(file, checksums)
+	file.Close()
+	if err != nil {
+		return err
+	}
+
 	// Create the target path if needed
 	backupsPath := shared.VarPath("backups", backup.container.Name())
 	if !shared.PathExists(backupsPath) {
@@ -385,8 +491,13 @@ func backupCreateTarball(s *state.State, path string, backup backup) error {
 		os.RemoveAll(backupPath)
 	}()
 
-	args := []string{"-cf", backupPath, "--xattrs", "-C", path, "--transform", "s,^./,backup/,", "."}
-	_, err = shared.RunCommand("tar", args...)
+	// The send streams are compressed already
+	compress := backup.compressionAlgorithm
+	if backup.streamCompression != "" {
+		compress = "none"
+	}
+
+	err = backupTarballWrite(path, backupPath, compress)
 	if err != nil {
 		return err
 	}
@@ -396,54 +507,134 @@ func backupCreateTarball(s *state.State, path string, backup backup) error {
 		return err
 	}
 
-	// Compress it
-	compress, err := cluster.ConfigGetString(s.Cluster, "backups.compression_algorithm")
+	// Set permissions
+	err = os.Chmod(backupPath, 0600)
 	if err != nil {
 		return err
 	}
 
-	if compress != "none" {
-		infile, err := os.Open(backupPath)
+	// Record the chunks it will be exported in
+	if backup.chunkSize > 0 {
+		err = backupChunksCreate(backup.name, backup.chunkSize)
 		if err != nil {
-			return err
+			return errors.Wrap(err, "Record backup chunks")
 		}
-		defer infile.Close()
+	}
 
-		compressed, err := os.Create(backupPath + ".compressed")
-		if err != nil {
-			return err
-		}
-		compressedName := compressed.Name()
+	success = true
+	return nil
+}
 
-		defer compressed.Close()
-		defer os.Remove(compressedName)
+// backupTarballWrite packs the given directory into a tarball, compressing it
+// as it's produced unless compress is "none".
+func backupTarballWrite(path string, target string, compress string) error {
+	tarball, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer tarball.Close()
 
-		err = compressFile(compress, infile, compressed)
-		if err != nil {
-			return err
-		}
+	tarCmd := exec.Command("tar", "-cf", "-", "--xattrs", "-C", path, "--transform", "s,^./,backup/,", ".")
+	if compress == "none" {
+		tarCmd.Stdout = tarball
+		return tarCmd.Run()
+	}
 
-		err = os.Remove(backupPath)
-		if err != nil {
-			return err
-		}
+	return backupStreamCompress(tarCmd, compress, tarball)
+}
 
-		err = os.Rename(compressedName, backupPath)
-		if err != nil {
-			return err
-		}
+// backupStreamCompress runs the given command, compressing its output into
+// the writer as it's produced.
+func backupStreamCompress(cmd *exec.Cmd, compress string, w io.Writer) error {
+	stream, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
 	}
 
-	// Set permissions
-	err = os.Chmod(backupPath, 0600)
+	err = cmd.Start()
 	if err != nil {
 		return err
 	}
 
-	success = true
+	err = compressFile(compress, stream, w)
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return errors.Wrap(err, "Compress")
+	}
+
+	err = cmd.Wait()
+	if err != nil {
+		return errors.Wrapf(err, "Run %s", filepath.Base(cmd.Path))
+	}
+
 	return nil
 }
 
+// backupSendStream runs the send command of a storage driver, writing its
+// stream to the given file of an optimized backup, compressed as it's
+// produced unless compress is "none".
+func backupSendStream(cmd *exec.Cmd, target string, compress string) error {
+	f, err := os.OpenFile(target, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if compress == "none" {
+		cmd.Stdout = f
+		return cmd.Run()
+	}
+
+	return backupStreamCompress(cmd, compress, f)
+}
+
+// backupReceiveStream runs the receive command of a storage driver, feeding
+// it the stream stored in the given file of an optimized backup,
+// decompressing it on the fly if it was compressed with compress. The output
+// of the receive command is returned.
+func backupReceiveStream(cmd *exec.Cmd, source string, compress string) ([]byte, error) {
+	feeder, err := os.Open(source)
+	if err != nil {
+		return nil, err
+	}
+	defer feeder.Close()
+
+	if compress == "" || compress == "none" {
+		cmd.Stdin = feeder
+		return cmd.CombinedOutput()
+	}
+
+	name, args, err := util.DecompressionCommand(compress)
+	if err != nil {
+		return nil, err
+	}
+
+	decompressCmd := exec.Command(name, args...)
+	decompressCmd.Stdin = feeder
+	cmd.Stdin, err = decompressCmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	err = decompressCmd.Start()
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := cmd.CombinedOutput()
+	decompressErr := decompressCmd.Wait()
+	if err != nil {
+		return output, err
+	}
+
+	if decompressErr != nil {
+		return output, errors.Wrap(decompressErr, "Decompress")
+	}
+
+	return output, nil
+}
+
 func pruneExpiredContainerBackupsTask(d *Daemon) (task.Func, task.Schedule) {
 	f := func(ctx context.Context) {
 		opRun := func(op *operation) error {
@@ -496,6 +687,11 @@ func pruneExpiredContainerBackups(ctx context.Context, d *Daemon) error {
 		}
 	}
 
+	err = backupUploadsPrune()
+	if err != nil {
+		return errors.Wrap(err, "Unable to remove the expired backup uploads")
+	}
+
 	return nil
 }
 
@@ -510,6 +706,11 @@ func doBackupDelete(s *state.State, backupName, containerName string) error {
 		}
 	}
 
+	err := backupChunksRemove(backupName)
+	if err != nil {
+		return err
+	}
+
 	// Check if we can remove the container directory
 	backupsPath := shared.VarPath("backups", containerName)
 	empty, _ := shared.PathIsEmpty(backupsPath)
@@ -521,7 +722,7 @@ func doBackupDelete(s *state.State, backupName, containerName string) error {
 	}
 
 	// Remove the database record
-	err := s.Cluster.ContainerBackupRemove(backupName)
+	err = s.Cluster.ContainerBackupRemove(backupName)
 	if err != nil {
 		return err
 	}
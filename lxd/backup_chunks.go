@@ -0,0 +1,207 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+)
+
+// The smallest chunk size, which keeps the number of checksums reasonable.
+const backupChunkSizeMin = 1024 * 1024
+
+// The largest chunk size, as uploaded chunks are checked in memory.
+const backupChunkSizeMax = 256 * 1024 * 1024
+
+// Chunked backups are compressed with zstd unless told otherwise.
+const backupChunksCompressionDefault = "zstd"
+
+// backupChunksPath returns the path of the chunk manifest of a backup. The
+// underscore keeps it apart from the container directories.
+func backupChunksPath(name string) string {
+	return shared.VarPath("backups", "lxd_chunks", name)
+}
+
+// backupChunkCount returns how many chunks of the given size make up size
+// bytes.
+func backupChunkCount(size int64, chunkSize int64) int {
+	return int((size + chunkSize - 1) / chunkSize)
+}
+
+// backupChunkRange returns the offset and length of a chunk.
+func backupChunkRange(size int64, chunkSize int64, index int) (int64, int64, error) {
+	if index < 0 || index >= backupChunkCount(size, chunkSize) {
+		return -1, -1, fmt.Errorf("Chunk %d out of range", index)
+	}
+
+	offset := int64(index) * chunkSize
+	length := chunkSize
+	if offset+length > size {
+		length = size - offset
+	}
+
+	return offset, length, nil
+}
+
+// backupChunksCompute splits the content of the reader in chunks of the given
+// size and returns their checksums.
+func backupChunksCompute(r io.Reader, chunkSize int64) (*api.ContainerBackupChunks, error) {
+	chunks := api.ContainerBackupChunks{
+		ChunkSize: chunkSize,
+		Checksums: []string{},
+	}
+
+	for {
+		hash := sha256.New()
+		n, err := io.CopyN(hash, r, chunkSize)
+		if n > 0 {
+			chunks.Size += n
+			chunks.Checksums = append(chunks.Checksums, fmt.Sprintf("%x", hash.Sum(nil)))
+		}
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &chunks, nil
+}
+
+// backupChunksCreate records the chunks of the backup tarball of the given
+// name.
+func backupChunksCreate(name string, chunkSize int64) error {
+	f, err := os.Open(shared.VarPath("backups", name))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	chunks, err := backupChunksCompute(f, chunkSize)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(chunks)
+	if err != nil {
+		return err
+	}
+
+	path := backupChunksPath(name)
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0600)
+}
+
+// backupChunksLoad returns the chunks of the backup of the given name, or nil
+// if it wasn't created in chunks.
+func backupChunksLoad(name string) (*api.ContainerBackupChunks, error) {
+	f, err := os.Open(backupChunksPath(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+	defer f.Close()
+
+	chunks := api.ContainerBackupChunks{}
+	err = json.NewDecoder(f).Decode(&chunks)
+	if err != nil {
+		return nil, err
+	}
+
+	return &chunks, nil
+}
+
+// backupChunksRemove removes the chunk manifest of a backup, if any, along
+// with its container directory once empty.
+func backupChunksRemove(name string) error {
+	path := backupChunksPath(name)
+
+	err := os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	empty, _ := shared.PathIsEmpty(filepath.Dir(path))
+	if empty {
+		os.Remove(filepath.Dir(path))
+	}
+
+	return nil
+}
+
+// backupChunksRename moves the chunk manifest of a backup, if any, along with
+// the backup, creating the container directory it's moved to as needed.
+func backupChunksRename(oldName string, newName string) error {
+	oldPath := backupChunksPath(oldName)
+	newPath := backupChunksPath(newName)
+
+	if !shared.PathExists(oldPath) {
+		return nil
+	}
+
+	err := os.MkdirAll(filepath.Dir(newPath), 0700)
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(oldPath, newPath)
+	if err != nil {
+		return err
+	}
+
+	empty, _ := shared.PathIsEmpty(filepath.Dir(oldPath))
+	if empty {
+		os.Remove(filepath.Dir(oldPath))
+	}
+
+	return nil
+}
+
+// Chunk response, serving part of a file along with its checksum
+type chunkResponse struct {
+	path     string
+	offset   int64
+	length   int64
+	checksum string
+}
+
+func (r *chunkResponse) Render(w http.ResponseWriter) error {
+	f, err := os.Open(r.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", r.length))
+	w.Header().Set("X-LXD-checksum", r.checksum)
+
+	_, err = io.Copy(w, io.NewSectionReader(f, r.offset, r.length))
+	return err
+}
+
+func (r *chunkResponse) String() string {
+	return fmt.Sprintf("%d bytes at %d", r.length, r.offset)
+}
+
+// ChunkResponse serves length bytes of a file starting at offset.
+func ChunkResponse(path string, offset int64, length int64, checksum string) Response {
+	return &chunkResponse{path, offset, length, checksum}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+)
+
+func TestBackupChunksCompute(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 25)
+
+	chunks, err := backupChunksCompute(bytes.NewReader(data), 100)
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(250), chunks.Size)
+	assert.Equal(t, int64(100), chunks.ChunkSize)
+	assert.Equal(t, []string{
+		fmt.Sprintf("%x", sha256.Sum256(data[0:100])),
+		fmt.Sprintf("%x", sha256.Sum256(data[100:200])),
+		fmt.Sprintf("%x", sha256.Sum256(data[200:250])),
+	}, chunks.Checksums)
+
+	chunks, err = backupChunksCompute(bytes.NewReader(data[:200]), 100)
+	require.NoError(t, err)
+	assert.Len(t, chunks.Checksums, 2)
+}
+
+func TestBackupChunkRange(t *testing.T) {
+	assert.Equal(t, 3, backupChunkCount(250, 100))
+	assert.Equal(t, 2, backupChunkCount(200, 100))
+
+	offset, length, err := backupChunkRange(250, 100, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), offset)
+	assert.Equal(t, int64(100), length)
+
+	offset, length, err = backupChunkRange(250, 100, 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(200), offset)
+	assert.Equal(t, int64(50), length)
+
+	_, _, err = backupChunkRange(250, 100, 3)
+	assert.Error(t, err)
+
+	_, _, err = backupChunkRange(250, 100, -1)
+	assert.Error(t, err)
+}
+
+func TestBackupUpload(t *testing.T) {
+	req := api.BackupUploadsPost{Size: 250, ChunkSize: 100, Checksums: []string{"a", "b", "c"}}
+	assert.NoError(t, backupUploadValidate(req))
+
+	req.Checksums = []string{"a", "b"}
+	assert.Error(t, backupUploadValidate(req))
+
+	req.ChunkSize = 0
+	assert.Error(t, backupUploadValidate(req))
+
+	upload := backupUpload{
+		BackupUploadsPost: api.BackupUploadsPost{Size: 250, ChunkSize: 100, Checksums: []string{"a", "b", "c"}},
+		Received:          []bool{true, false, false},
+	}
+	assert.Equal(t, []int{1, 2}, upload.Render("id").Missing)
+}
+
+func TestBackupUploadSizeMax(t *testing.T) {
+	req := api.BackupUploadsPost{Size: backupUploadSizeMax + 1, ChunkSize: backupChunkSizeMax}
+	req.Checksums = make([]string, backupChunkCount(req.Size, req.ChunkSize))
+	assert.Error(t, backupUploadValidate(req))
+}
+
+func TestBackupUploadLoadProject(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd-backup-uploads-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	os.Setenv("LXD_DIR", dir)
+	defer os.Unsetenv("LXD_DIR")
+
+	id := uuid.NewRandom().String()
+	require.NoError(t, os.MkdirAll(backupUploadPath(id), 0700))
+	require.NoError(t, backupUploadSave(id, &backupUpload{Project: "foo"}))
+
+	_, err = backupUploadLoad("foo", id)
+	assert.NoError(t, err)
+
+	_, err = backupUploadLoad("default", id)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestBackupChunksRename(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd-backup-chunks-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	os.Setenv("LXD_DIR", dir)
+	defer os.Unsetenv("LXD_DIR")
+
+	require.NoError(t, os.MkdirAll(filepath.Dir(backupChunksPath("c1/backup0")), 0700))
+	require.NoError(t, ioutil.WriteFile(backupChunksPath("c1/backup0"), []byte("{}"), 0600))
+
+	require.NoError(t, backupChunksRename("c1/backup0", "c2/backup0"))
+	assert.True(t, shared.PathExists(backupChunksPath("c2/backup0")))
+	assert.False(t, shared.PathExists(filepath.Dir(backupChunksPath("c1/backup0"))))
+
+	// Backups without manifest are left alone
+	assert.NoError(t, backupChunksRename("c3/backup0", "c4/backup0"))
+	assert.False(t, shared.PathExists(filepath.Dir(backupChunksPath("c4/backup0"))))
+}
@@ -0,0 +1,385 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pborman/uuid"
+	"golang.org/x/sys/unix"
+
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/units"
+	"github.com/lxc/lxd/shared/version"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// A backup upload receives a backup tarball in chunks, in any order and as
+// many times as needed, so that a broken connection doesn't mean starting over.
+// Uploads are local to the node receiving them.
+var backupUploadsCmd = APIEndpoint{
+	Name: "backup-uploads",
+
+	Post: APIEndpointAction{Handler: backupUploadsPost, AccessHandler: AllowProjectPermission("containers", "manage-containers")},
+}
+
+var backupUploadCmd = APIEndpoint{
+	Name: "backup-uploads/{id}",
+
+	Delete: APIEndpointAction{Handler: backupUploadDelete, AccessHandler: AllowProjectPermission("containers", "manage-containers")},
+	Get:    APIEndpointAction{Handler: backupUploadGet, AccessHandler: AllowProjectPermission("containers", "manage-containers")},
+	Post:   APIEndpointAction{Handler: backupUploadPost, AccessHandler: AllowProjectPermission("containers", "manage-containers")},
+}
+
+var backupUploadChunkCmd = APIEndpoint{
+	Name: "backup-uploads/{id}/chunks/{chunk}",
+
+	Put: APIEndpointAction{Handler: backupUploadChunkPut, AccessHandler: AllowProjectPermission("containers", "manage-containers")},
+}
+
+// How long an upload nobody sends chunks to is kept around.
+const backupUploadExpiry = 24 * time.Hour
+
+// The largest backup which can be uploaded.
+const backupUploadSizeMax = 64 * 1024 * 1024 * 1024
+
+// Serializes the updates of the received chunks of the uploads.
+var backupUploadsLock sync.Mutex
+
+// backupUpload is the state of an upload, stored next to the data received so
+// far.
+type backupUpload struct {
+	api.BackupUploadsPost
+
+	Project  string `json:"project"`
+	Received []bool `json:"received"`
+}
+
+// Render returns the API representation of the upload.
+func (u *backupUpload) Render(id string) *api.BackupUpload {
+	upload := api.BackupUpload{
+		BackupUploadsPost: u.BackupUploadsPost,
+		ID:                id,
+		Missing:           []int{},
+	}
+
+	for i, received := range u.Received {
+		if !received {
+			upload.Missing = append(upload.Missing, i)
+		}
+	}
+
+	return &upload
+}
+
+// backupUploadPath returns the path of the directory of an upload.
+func backupUploadPath(id string) string {
+	return shared.VarPath("backups", "lxd_uploads", id)
+}
+
+// backupUploadValidate checks that the checksums of a new upload match its
+// size and chunk size.
+func backupUploadValidate(req api.BackupUploadsPost) error {
+	if req.Size <= 0 {
+		return fmt.Errorf("Invalid backup size %d", req.Size)
+	}
+
+	if req.Size > backupUploadSizeMax {
+		return fmt.Errorf("Backups can't be larger than %s", units.GetByteSizeString(backupUploadSizeMax, 0))
+	}
+
+	if req.ChunkSize <= 0 || req.ChunkSize > backupChunkSizeMax {
+		return fmt.Errorf("Invalid chunk size %d", req.ChunkSize)
+	}
+
+	count := backupChunkCount(req.Size, req.ChunkSize)
+	if len(req.Checksums) != count {
+		return fmt.Errorf("Expected %d chunk checksums, got %d", count, len(req.Checksums))
+	}
+
+	return nil
+}
+
+// backupUploadSpace checks that the backups directory has room for an upload
+// of the given size, as the data is only allocated as chunks come in.
+func backupUploadSpace(size int64) error {
+	var st unix.Statfs_t
+	err := unix.Statfs(shared.VarPath("backups"), &st)
+	if err != nil {
+		return err
+	}
+
+	available := int64(st.Bavail) * int64(st.Bsize)
+	if size > available {
+		return fmt.Errorf("Not enough space for a backup of %s, only %s available", units.GetByteSizeString(size, 0), units.GetByteSizeString(available, 0))
+	}
+
+	return nil
+}
+
+// backupUploadLoad returns the upload with the given identifier, which must
+// belong to the given project.
+func backupUploadLoad(project string, id string) (*backupUpload, error) {
+	// The identifier ends up in a path
+	if uuid.Parse(id) == nil {
+		return nil, os.ErrNotExist
+	}
+
+	f, err := os.Open(filepath.Join(backupUploadPath(id), "state"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, os.ErrNotExist
+		}
+
+		return nil, err
+	}
+	defer f.Close()
+
+	upload := backupUpload{}
+	err = json.NewDecoder(f).Decode(&upload)
+	if err != nil {
+		return nil, err
+	}
+
+	// Uploads of other projects don't exist as far as the caller is concerned
+	if upload.Project != project {
+		return nil, os.ErrNotExist
+	}
+
+	return &upload, nil
+}
+
+func backupUploadSave(id string, upload *backupUpload) error {
+	data, err := json.Marshal(upload)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(backupUploadPath(id), "state"), data, 0600)
+}
+
+func backupUploadsPost(d *Daemon, r *http.Request) Response {
+	req := api.BackupUploadsPost{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	err = backupUploadValidate(req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	err = backupUploadSpace(req.Size)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	id := uuid.NewRandom().String()
+	path := backupUploadPath(id)
+
+	err = os.MkdirAll(path, 0700)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	upload := &backupUpload{
+		BackupUploadsPost: req,
+		Project:           projectParam(r),
+		Received:          make([]bool, len(req.Checksums)),
+	}
+
+	// Chunks get written in place as they arrive
+	f, err := os.OpenFile(filepath.Join(path, "backup"), os.O_CREATE|os.O_WRONLY, 0600)
+	if err == nil {
+		err = f.Truncate(req.Size)
+		f.Close()
+	}
+	if err != nil {
+		os.RemoveAll(path)
+		return InternalError(err)
+	}
+
+	err = backupUploadSave(id, upload)
+	if err != nil {
+		os.RemoveAll(path)
+		return InternalError(err)
+	}
+
+	return SyncResponseLocation(true, upload.Render(id), fmt.Sprintf("/%s/backup-uploads/%s", version.APIVersion, id))
+}
+
+func backupUploadGet(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+	id := mux.Vars(r)["id"]
+
+	upload, err := backupUploadLoad(project, id)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return SyncResponse(true, upload.Render(id))
+}
+
+// backupUploadChunkPut receives a chunk of an upload, which is only kept if
+// it matches its checksum.
+func backupUploadChunkPut(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+	id := mux.Vars(r)["id"]
+
+	index, err := strconv.Atoi(mux.Vars(r)["chunk"])
+	if err != nil {
+		return BadRequest(fmt.Errorf("Invalid chunk %q", mux.Vars(r)["chunk"]))
+	}
+
+	upload, err := backupUploadLoad(project, id)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	offset, length, err := backupChunkRange(upload.Size, upload.ChunkSize, index)
+	if err != nil {
+		return NotFound(err)
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(r.Body, length+1))
+	if err != nil {
+		return InternalError(err)
+	}
+
+	if int64(len(data)) != length {
+		return BadRequest(fmt.Errorf("Expected %d bytes for chunk %d, got %d", length, index, len(data)))
+	}
+
+	checksum := fmt.Sprintf("%x", sha256.Sum256(data))
+	if checksum != upload.Checksums[index] {
+		return BadRequest(fmt.Errorf("Checksum mismatch for chunk %d", index))
+	}
+
+	f, err := os.OpenFile(filepath.Join(backupUploadPath(id), "backup"), os.O_WRONLY, 0600)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	_, err = f.WriteAt(data, offset)
+	f.Close()
+	if err != nil {
+		return InternalError(err)
+	}
+
+	// Reload the state, other chunks may have been received meanwhile
+	backupUploadsLock.Lock()
+	defer backupUploadsLock.Unlock()
+
+	upload, err = backupUploadLoad(project, id)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	upload.Received[index] = true
+	err = backupUploadSave(id, upload)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	return EmptySyncResponse
+}
+
+// backupUploadPost creates a container from a complete upload, which goes
+// away once its data was handed over.
+func backupUploadPost(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+	id := mux.Vars(r)["id"]
+
+	req := api.BackupUploadPost{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	upload, err := backupUploadLoad(project, id)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	missing := upload.Render(id).Missing
+	if len(missing) > 0 {
+		return BadRequest(fmt.Errorf("The upload is missing %d chunks", len(missing)))
+	}
+
+	f, err := os.Open(filepath.Join(backupUploadPath(id), "backup"))
+	if err != nil {
+		return SmartError(err)
+	}
+	defer f.Close()
+
+	response := createFromBackup(d, project, f, req.Pool, d.userIsAdmin(r))
+
+	_, ok := response.(*operationResponse)
+	if ok {
+		err = os.RemoveAll(backupUploadPath(id))
+		if err != nil {
+			logger.Error("Failed to remove backup upload", log.Ctx{"id": id, "err": err})
+		}
+	}
+
+	return response
+}
+
+func backupUploadDelete(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+	id := mux.Vars(r)["id"]
+
+	_, err := backupUploadLoad(project, id)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	err = os.RemoveAll(backupUploadPath(id))
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return EmptySyncResponse
+}
+
+// backupUploadsPrune removes the uploads which didn't receive anything for
+// backupUploadExpiry.
+func backupUploadsPrune() error {
+	dents, err := ioutil.ReadDir(shared.VarPath("backups", "lxd_uploads"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	for _, dent := range dents {
+		path := filepath.Join(shared.VarPath("backups", "lxd_uploads"), dent.Name())
+
+		fi, err := os.Stat(filepath.Join(path, "state"))
+		if err == nil && time.Since(fi.ModTime()) < backupUploadExpiry {
+			continue
+		}
+
+		err = os.RemoveAll(path)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
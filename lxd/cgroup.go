@@ -2,10 +2,15 @@ package main
 
 import (
 	"bufio"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path"
+	"strconv"
 	"strings"
+
+	"github.com/lxc/lxd/lxd/sys"
+	"github.com/lxc/lxd/shared"
 )
 
 func getInitCgroupPath(controller string) string {
@@ -42,9 +47,35 @@ func getInitCgroupPath(controller string) string {
 	return "/"
 }
 
-func cGroupGet(controller, cgroup, file string) (string, error) {
+// cGroupUnifiedOnly is whether the host only has the unified hierarchy. It's
+// detected once, the hierarchies being mounted at boot.
+var cGroupUnifiedOnly = shared.PathExists("/sys/fs/cgroup/cgroup.controllers")
+
+// cGroupPath returns the path of a file of the host cgroups, translating the
+// legacy hierarchy file names on hosts only having the unified one.
+func cGroupPath(controller, cgroup, file string) string {
+	if cGroupUnifiedOnly {
+		// The root cgroup only has the effective cpuset files
+		if cgroup == "/" && (file == "cpuset.cpus" || file == "cpuset.mems") {
+			file = file + ".effective"
+		}
+
+		switch file {
+		case "cpuset.effective_cpus":
+			file = "cpuset.cpus.effective"
+		case "cpuset.effective_mems":
+			file = "cpuset.mems.effective"
+		}
+
+		return path.Join("/sys/fs/cgroup", cgroup, file)
+	}
+
 	initPath := getInitCgroupPath(controller)
-	path := path.Join("/sys/fs/cgroup", controller, initPath, cgroup, file)
+	return path.Join("/sys/fs/cgroup", controller, initPath, cgroup, file)
+}
+
+func cGroupGet(controller, cgroup, file string) (string, error) {
+	path := cGroupPath(controller, cgroup, file)
 
 	contents, err := ioutil.ReadFile(path)
 	if err != nil {
@@ -54,8 +85,298 @@ func cGroupGet(controller, cgroup, file string) (string, error) {
 }
 
 func cGroupSet(controller, cgroup, file string, value string) error {
-	initPath := getInitCgroupPath(controller)
-	path := path.Join("/sys/fs/cgroup", controller, initPath, cgroup, file)
+	path := cGroupPath(controller, cgroup, file)
 
 	return ioutil.WriteFile(path, []byte(value), 0755)
 }
+
+// cGroupV2Translate returns the unified hierarchy key and value equivalent to
+// a legacy hierarchy key and value, those being used throughout LXD. An empty
+// key means the setting has no equivalent on this host and must be skipped.
+//
+// The unified hierarchy holds the CPU quota and period in the single cpu.max
+// file, so the period must be set before the quota, which LXD always does.
+// Likewise the swap is limited on its own rather than along with the memory,
+// so the memory limit must be set before the memory and swap one, whose
+// translation reads the unified hierarchy keys through the given function.
+func cGroupV2Translate(sysOS *sys.OS, key string, value string, get func(key string) (string, error)) (string, string, error) {
+	unlimited := func(value string) string {
+		if value == "-1" {
+			return "max"
+		}
+
+		return value
+	}
+
+	switch key {
+	case "memory.limit_in_bytes":
+		return "memory.max", unlimited(value), nil
+	case "memory.soft_limit_in_bytes":
+		// The memory above it gets reclaimed first, under pressure
+		return "memory.high", unlimited(value), nil
+	case "memory.memsw.limit_in_bytes":
+		if !sysOS.CGroupSwapAccounting {
+			return "", "", nil
+		}
+
+		if value == "-1" {
+			return "memory.swap.max", "max", nil
+		}
+
+		total, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return "", "", err
+		}
+
+		memory, err := get("memory.max")
+		if err != nil {
+			return "", "", err
+		}
+
+		memory = strings.TrimSpace(memory)
+		if memory == "max" {
+			return "memory.swap.max", value, nil
+		}
+
+		limit, err := strconv.ParseInt(memory, 10, 64)
+		if err != nil {
+			return "", "", err
+		}
+
+		// The swap can't be limited along with the memory, so it's
+		// limited to what's left of the total, or to the total itself
+		// when the swap only counts against the memory limit
+		if total > limit {
+			return "memory.swap.max", fmt.Sprintf("%d", total-limit), nil
+		}
+
+		return "memory.swap.max", value, nil
+	case "memory.swappiness":
+		// No per-cgroup swappiness, only prevent swapping, the swap
+		// being otherwise limited through the memory and swap limit
+		if !sysOS.CGroupSwapAccounting || value != "0" {
+			return "", "", nil
+		}
+
+		return "memory.swap.max", "0", nil
+	case "cpu.shares":
+		shares, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return "", "", err
+		}
+
+		// Map the default 1024 shares to the default weight of 100
+		weight := shares * 100 / 1024
+		if weight < 1 {
+			weight = 1
+		} else if weight > 10000 {
+			weight = 10000
+		}
+
+		return "cpu.weight", fmt.Sprintf("%d", weight), nil
+	case "cpu.cfs_period_us":
+		if value == "-1" {
+			value = "100000"
+		}
+
+		return "cpu.max", fmt.Sprintf("max %s", value), nil
+	case "cpu.cfs_quota_us":
+		return "cpu.max", unlimited(value), nil
+	case "cpu.cfs_burst_us":
+		return "cpu.max.burst", value, nil
+	case "blkio.weight":
+		weight, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return "", "", err
+		}
+
+		// Map the legacy default of 500 to the default weight of 100
+		weight = weight / 5
+		if weight < 1 {
+			weight = 1
+		}
+
+		return "io.weight", fmt.Sprintf("%d", weight), nil
+	case "blkio.throttle.read_bps_device", "blkio.throttle.read_iops_device", "blkio.throttle.write_bps_device", "blkio.throttle.write_iops_device":
+		fields := strings.Fields(value)
+		if len(fields) != 2 {
+			return "", "", fmt.Errorf("Invalid %s value: %s", key, value)
+		}
+
+		limits := map[string]string{
+			"blkio.throttle.read_bps_device":   "rbps",
+			"blkio.throttle.read_iops_device":  "riops",
+			"blkio.throttle.write_bps_device":  "wbps",
+			"blkio.throttle.write_iops_device": "wiops",
+		}
+
+		return "io.max", fmt.Sprintf("%s %s=%s", fields[0], limits[key], unlimited(fields[1])), nil
+	case "net_prio.ifpriomap":
+		return "", "", nil
+	}
+
+	if strings.HasPrefix(key, "hugetlb.") && strings.HasSuffix(key, ".limit_in_bytes") {
+		return fmt.Sprintf("%s.max", strings.TrimSuffix(key, ".limit_in_bytes")), unlimited(value), nil
+	}
+
+	return key, value, nil
+}
+
+// cGroupV2Read returns the value of a legacy hierarchy key, computed from the
+// unified hierarchy keys read by the given function.
+func cGroupV2Read(key string, get func(key string) (string, error)) (string, error) {
+	readInt := func(key string) (int64, error) {
+		value, err := get(key)
+		if err != nil {
+			return -1, err
+		}
+
+		return strconv.ParseInt(value, 10, 64)
+	}
+
+	switch key {
+	case "cpuacct.usage":
+		stat, err := get("cpu.stat")
+		if err != nil {
+			return "", err
+		}
+
+		for _, line := range strings.Split(stat, "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 2 && fields[0] == "usage_usec" {
+				usage, err := strconv.ParseInt(fields[1], 10, 64)
+				if err != nil {
+					return "", err
+				}
+
+				return fmt.Sprintf("%d", usage*1000), nil
+			}
+		}
+
+		return "", fmt.Errorf("No CPU usage in cpu.stat")
+	case "memory.usage_in_bytes":
+		return get("memory.current")
+	case "memory.max_usage_in_bytes":
+		return get("memory.peak")
+	case "memory.memsw.usage_in_bytes":
+		memory, err := readInt("memory.current")
+		if err != nil {
+			return "", err
+		}
+
+		swap, err := readInt("memory.swap.current")
+		if err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf("%d", memory+swap), nil
+	case "memory.memsw.max_usage_in_bytes", "memory.memsw.limit_in_bytes":
+		return "", fmt.Errorf("No unified hierarchy equivalent to %s", key)
+	case "memory.limit_in_bytes":
+		value, err := get("memory.max")
+		if err != nil {
+			return "", err
+		}
+
+		if value == "max" {
+			return "-1", nil
+		}
+
+		return value, nil
+	case "memory.soft_limit_in_bytes":
+		return get("memory.low")
+	}
+
+	return get(key)
+}
+
+// cGroupV2ParseIOStat parses the content of an io.stat file, returning the
+// statistics of each block device indexed by its major:minor.
+func cGroupV2ParseIOStat(stat string) map[string]map[string]int64 {
+	result := map[string]map[string]int64{}
+
+	for _, line := range strings.Split(stat, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		values := map[string]int64{}
+		for _, field := range fields[1:] {
+			entry := strings.SplitN(field, "=", 2)
+			if len(entry) != 2 {
+				continue
+			}
+
+			value, err := strconv.ParseInt(entry[1], 10, 64)
+			if err != nil {
+				continue
+			}
+
+			values[entry[0]] = value
+		}
+
+		result[fields[0]] = values
+	}
+
+	return result
+}
+
+// The kernel reports the times of cpuacct.stat in USER_HZ units, which are
+// always a hundredth of a second.
+const cGroupUserHZ = 100
+
+// cGroupParseCPUStat parses the content of a cpuacct.stat file, or of a
+// cpu.stat file on the unified hierarchy, returning the user and system CPU
+// times in nanoseconds.
+func cGroupParseCPUStat(stat string, unified bool) (int64, int64, error) {
+	keys := map[string]string{"user": "user", "system": "system"}
+	scale := int64(1000000000 / cGroupUserHZ)
+	if unified {
+		keys = map[string]string{"user_usec": "user", "system_usec": "system"}
+		scale = 1000
+	}
+
+	values := map[string]int64{}
+	for _, line := range strings.Split(stat, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || keys[fields[0]] == "" {
+			continue
+		}
+
+		value, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return -1, -1, err
+		}
+
+		values[keys[fields[0]]] = value * scale
+	}
+
+	user, ok := values["user"]
+	if !ok {
+		return -1, -1, fmt.Errorf("No user CPU time in %q", stat)
+	}
+
+	system, ok := values["system"]
+	if !ok {
+		return -1, -1, fmt.Errorf("No system CPU time in %q", stat)
+	}
+
+	return user, system, nil
+}
+
+// cGroupParseCPUUsagePerCPU parses the content of a cpuacct.usage_percpu
+// file, returning the CPU usage in nanoseconds of each CPU.
+func cGroupParseCPUUsagePerCPU(value string) ([]int64, error) {
+	usage := []int64{}
+	for _, field := range strings.Fields(value) {
+		valueInt, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		usage = append(usage, valueInt)
+	}
+
+	return usage, nil
+}
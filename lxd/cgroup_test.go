@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/lxd/sys"
+)
+
+func TestCGroupV2Translate(t *testing.T) {
+	sysOS := &sys.OS{CGroupSwapAccounting: true}
+	get := func(key string) (string, error) {
+		if key != "memory.max" {
+			return "", fmt.Errorf("No such file %s", key)
+		}
+
+		return "1073741824", nil
+	}
+
+	cases := []struct {
+		key      string
+		value    string
+		v2Key    string
+		v2Value  string
+		disabled bool
+	}{
+		{"memory.limit_in_bytes", "1073741824", "memory.max", "1073741824", false},
+		{"memory.limit_in_bytes", "-1", "memory.max", "max", false},
+		{"memory.soft_limit_in_bytes", "966367641", "memory.high", "966367641", false},
+		{"memory.soft_limit_in_bytes", "-1", "memory.high", "max", false},
+		{"memory.memsw.limit_in_bytes", "2147483648", "memory.swap.max", "1073741824", false},
+		{"memory.memsw.limit_in_bytes", "1073741824", "memory.swap.max", "1073741824", false},
+		{"memory.memsw.limit_in_bytes", "-1", "memory.swap.max", "max", false},
+		{"memory.swappiness", "0", "memory.swap.max", "0", false},
+		{"memory.swappiness", "55", "", "", true},
+		{"cpu.shares", "1024", "cpu.weight", "100", false},
+		{"cpu.shares", "0", "cpu.weight", "1", false},
+		{"cpu.cfs_period_us", "50000", "cpu.max", "max 50000", false},
+		{"cpu.cfs_quota_us", "20000", "cpu.max", "20000", false},
+		{"cpu.cfs_quota_us", "-1", "cpu.max", "max", false},
+		{"cpu.cfs_burst_us", "10000", "cpu.max.burst", "10000", false},
+		{"blkio.weight", "500", "io.weight", "100", false},
+		{"blkio.throttle.write_iops_device", "8:0 100", "io.max", "8:0 wiops=100", false},
+		{"hugetlb.2MB.limit_in_bytes", "4194304", "hugetlb.2MB.max", "4194304", false},
+		{"pids.max", "100", "pids.max", "100", false},
+		{"net_prio.ifpriomap", "eth0 5", "", "", true},
+	}
+
+	for _, c := range cases {
+		t.Run(fmt.Sprintf("%s=%s", c.key, c.value), func(t *testing.T) {
+			key, value, err := cGroupV2Translate(sysOS, c.key, c.value, get)
+			require.NoError(t, err)
+
+			if c.disabled {
+				assert.Equal(t, "", key)
+				return
+			}
+
+			assert.Equal(t, c.v2Key, key)
+			assert.Equal(t, c.v2Value, value)
+		})
+	}
+
+	// Without swap accounting the swap can't be limited
+	key, _, err := cGroupV2Translate(&sys.OS{}, "memory.swappiness", "0", get)
+	require.NoError(t, err)
+	assert.Equal(t, "", key)
+
+	key, _, err = cGroupV2Translate(&sys.OS{}, "memory.memsw.limit_in_bytes", "2147483648", get)
+	require.NoError(t, err)
+	assert.Equal(t, "", key)
+
+	// Without a memory limit, the swap is limited to the total
+	key, value, err := cGroupV2Translate(sysOS, "memory.memsw.limit_in_bytes", "2147483648", func(key string) (string, error) {
+		return "max", nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "memory.swap.max", key)
+	assert.Equal(t, "2147483648", value)
+}
+
+func TestCGroupV2Read(t *testing.T) {
+	files := map[string]string{
+		"cpu.stat":            "usage_usec 1500\nuser_usec 1000\nsystem_usec 500",
+		"memory.current":      "2048",
+		"memory.swap.current": "1024",
+		"memory.max":          "max",
+		"pids.current":        "12",
+	}
+
+	get := func(key string) (string, error) {
+		value, ok := files[key]
+		if !ok {
+			return "", fmt.Errorf("No such file %s", key)
+		}
+
+		return value, nil
+	}
+
+	value, err := cGroupV2Read("cpuacct.usage", get)
+	require.NoError(t, err)
+	assert.Equal(t, "1500000", value)
+
+	value, err = cGroupV2Read("memory.usage_in_bytes", get)
+	require.NoError(t, err)
+	assert.Equal(t, "2048", value)
+
+	value, err = cGroupV2Read("memory.memsw.usage_in_bytes", get)
+	require.NoError(t, err)
+	assert.Equal(t, "3072", value)
+
+	value, err = cGroupV2Read("memory.limit_in_bytes", get)
+	require.NoError(t, err)
+	assert.Equal(t, "-1", value)
+
+	value, err = cGroupV2Read("pids.current", get)
+	require.NoError(t, err)
+	assert.Equal(t, "12", value)
+
+	_, err = cGroupV2Read("memory.max_usage_in_bytes", get)
+	assert.Error(t, err)
+}
+
+func TestCGroupV2ParseIOStat(t *testing.T) {
+	stat := "8:0 rbytes=4096 wbytes=0 rios=1 wios=0 dbytes=0 dios=0 use_delay=1 delay_nsec=2500\n259:0 rbytes=0 wbytes=0 rios=0 wios=0 dbytes=0 dios=0 depth=16 avg_lat=120 win=100\n"
+
+	assert.Equal(t, map[string]map[string]int64{
+		"8:0":   {"rbytes": 4096, "wbytes": 0, "rios": 1, "wios": 0, "dbytes": 0, "dios": 0, "use_delay": 1, "delay_nsec": 2500},
+		"259:0": {"rbytes": 0, "wbytes": 0, "rios": 0, "wios": 0, "dbytes": 0, "dios": 0, "depth": 16, "avg_lat": 120, "win": 100},
+	}, cGroupV2ParseIOStat(stat))
+}
+
+func TestCGroupParseCPUStat(t *testing.T) {
+	user, system, err := cGroupParseCPUStat("user 301\nsystem 197", false)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3010000000), user)
+	assert.Equal(t, int64(1970000000), system)
+
+	user, system, err = cGroupParseCPUStat("usage_usec 1500\nuser_usec 1000\nsystem_usec 500", true)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1000000), user)
+	assert.Equal(t, int64(500000), system)
+
+	_, _, err = cGroupParseCPUStat("usage_usec 1500", true)
+	assert.Error(t, err)
+}
+
+func TestCGroupParseCPUUsagePerCPU(t *testing.T) {
+	usage, err := cGroupParseCPUUsagePerCPU("2493009861 2493009861 0 ")
+	require.NoError(t, err)
+	assert.Equal(t, []int64{2493009861, 2493009861, 0}, usage)
+
+	_, err = cGroupParseCPUUsagePerCPU("12 abc")
+	assert.Error(t, err)
+}
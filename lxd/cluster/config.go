@@ -5,14 +5,17 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io"
-	"os/exec"
 	"strconv"
 	"time"
 
 	"golang.org/x/crypto/scrypt"
+	cron "gopkg.in/robfig/cron.v2"
 
 	"github.com/lxc/lxd/lxd/config"
 	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/dbbackup"
+	"github.com/lxc/lxd/lxd/oidc"
+	"github.com/lxc/lxd/lxd/util"
 	"github.com/pkg/errors"
 )
 
@@ -72,6 +75,17 @@ func (c *Config) CandidServer() (string, string, int64, string) {
 		c.m.GetString("candid.domains")
 }
 
+// OIDCServer returns all the OpenID Connect settings needed to verify the
+// bearer tokens and map their holders to permissions.
+func (c *Config) OIDCServer() (string, string, string, string, string, string) {
+	return c.m.GetString("oidc.issuer"),
+		c.m.GetString("oidc.audience"),
+		c.m.GetString("oidc.claims.username"),
+		c.m.GetString("oidc.claims.groups"),
+		c.m.GetString("oidc.groups.admin"),
+		c.m.GetString("oidc.groups.projects")
+}
+
 // RBACServer returns all the Candid settings needed to connect to a server.
 func (c *Config) RBACServer() (string, string, int64, string, string, string, string) {
 	return c.m.GetString("rbac.api.url"),
@@ -130,6 +144,35 @@ func (c *Config) ImagesMinimalReplica() int64 {
 	return c.m.GetInt64("cluster.images_minimal_replica")
 }
 
+// DatabaseBackupSchedule returns the cron expression used to schedule
+// database backups.
+func (c *Config) DatabaseBackupSchedule() string {
+	return c.m.GetString("backups.database.schedule")
+}
+
+// DatabaseBackupTarget returns the URL of the external target database
+// backups are stored in, along with its password.
+func (c *Config) DatabaseBackupTarget() (string, string) {
+	return c.m.GetString("backups.database.target"), c.m.GetString("backups.database.target.password")
+}
+
+// DatabaseBackupRetention returns the number of database backups to keep.
+func (c *Config) DatabaseBackupRetention() int64 {
+	return c.m.GetInt64("backups.database.retention")
+}
+
+// ExecSessionsLimit returns the default maximum number of concurrent exec
+// sessions of a container, zero meaning no limit.
+func (c *Config) ExecSessionsLimit() int64 {
+	return c.m.GetInt64("core.exec_sessions_limit")
+}
+
+// RenderWorkers returns the number of workers used to render containers in
+// parallel.
+func (c *Config) RenderWorkers() int64 {
+	return c.m.GetInt64("core.render_workers")
+}
+
 // Dump current configuration keys and their values. Keys with values matching
 // their defaults are omitted.
 func (c *Config) Dump() map[string]interface{} {
@@ -219,35 +262,48 @@ func configGet(cluster *db.Cluster) (*Config, error) {
 
 // ConfigSchema defines available server configuration keys.
 var ConfigSchema = config.Schema{
-	"backups.compression_algorithm":  {Default: "gzip", Validator: validateCompression},
-	"cluster.offline_threshold":      {Type: config.Int64, Default: offlineThresholdDefault(), Validator: offlineThresholdValidator},
-	"cluster.images_minimal_replica": {Type: config.Int64, Default: "3", Validator: imageMinimalReplicaValidator},
-	"core.https_allowed_headers":     {},
-	"core.https_allowed_methods":     {},
-	"core.https_allowed_origin":      {},
-	"core.https_allowed_credentials": {Type: config.Bool},
-	"core.proxy_http":                {},
-	"core.proxy_https":               {},
-	"core.proxy_ignore_hosts":        {},
-	"core.trust_password":            {Hidden: true, Setter: passwordSetter},
-	"candid.api.key":                 {},
-	"candid.api.url":                 {},
-	"candid.domains":                 {},
-	"candid.expiry":                  {Type: config.Int64, Default: "3600"},
-	"images.auto_update_cached":      {Type: config.Bool, Default: "true"},
-	"images.auto_update_interval":    {Type: config.Int64, Default: "6"},
-	"images.compression_algorithm":   {Default: "gzip", Validator: validateCompression},
-	"images.remote_cache_expiry":     {Type: config.Int64, Default: "10"},
-	"maas.api.key":                   {},
-	"maas.api.url":                   {},
-	"rbac.agent.url":                 {},
-	"rbac.agent.username":            {},
-	"rbac.agent.private_key":         {},
-	"rbac.agent.public_key":          {},
-	"rbac.api.expiry":                {Type: config.Int64, Default: "3600"},
-	"rbac.api.key":                   {},
-	"rbac.api.url":                   {},
-	"rbac.expiry":                    {Type: config.Int64, Default: "3600"},
+	"backups.compression_algorithm":    {Default: "gzip", Validator: validateCompression},
+	"backups.database.retention":       {Type: config.Int64, Default: "7"},
+	"backups.database.schedule":        {Validator: validateSchedule},
+	"backups.database.target":          {Validator: dbbackup.ValidateTarget},
+	"backups.database.target.password": {Hidden: true},
+	"cluster.offline_threshold":        {Type: config.Int64, Default: offlineThresholdDefault(), Validator: offlineThresholdValidator},
+	"cluster.images_minimal_replica":   {Type: config.Int64, Default: "3", Validator: imageMinimalReplicaValidator},
+	"core.exec_sessions_limit":         {Type: config.Int64, Default: "0"},
+	"core.https_allowed_headers":       {},
+	"core.https_allowed_methods":       {},
+	"core.https_allowed_origin":        {},
+	"core.https_allowed_credentials":   {Type: config.Bool},
+	"core.proxy_http":                  {},
+	"core.proxy_https":                 {},
+	"core.proxy_ignore_hosts":          {},
+	"core.render_workers":              {Type: config.Int64, Default: "4", Validator: renderWorkersValidator},
+	"core.trust_password":              {Hidden: true, Setter: passwordSetter},
+	"candid.api.key":                   {},
+	"candid.api.url":                   {},
+	"candid.domains":                   {},
+	"candid.expiry":                    {Type: config.Int64, Default: "3600"},
+	"ephemeral.cleanup":                {Type: config.Bool, Default: "false"},
+	"images.auto_update_cached":        {Type: config.Bool, Default: "true"},
+	"images.auto_update_interval":      {Type: config.Int64, Default: "6"},
+	"images.compression_algorithm":     {Default: "gzip", Validator: validateCompression},
+	"images.remote_cache_expiry":       {Type: config.Int64, Default: "10"},
+	"maas.api.key":                     {},
+	"maas.api.url":                     {},
+	"oidc.audience":                    {},
+	"oidc.claims.groups":               {Default: "groups"},
+	"oidc.claims.username":             {Default: "sub"},
+	"oidc.groups.admin":                {},
+	"oidc.groups.projects":             {Validator: validateOIDCGroups},
+	"oidc.issuer":                      {},
+	"rbac.agent.url":                   {},
+	"rbac.agent.username":              {},
+	"rbac.agent.private_key":           {},
+	"rbac.agent.public_key":            {},
+	"rbac.api.expiry":                  {Type: config.Int64, Default: "3600"},
+	"rbac.api.key":                     {},
+	"rbac.api.url":                     {},
+	"rbac.expiry":                      {Type: config.Int64, Default: "3600"},
 
 	// Keys deprecated since the implementation of the storage api.
 	"storage.lvm_fstype":           {Setter: deprecatedStorage, Default: "ext4"},
@@ -292,6 +348,19 @@ func imageMinimalReplicaValidator(value string) error {
 	return nil
 }
 
+func renderWorkersValidator(value string) error {
+	count, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("Render workers count is not a number")
+	}
+
+	if count < 1 {
+		return fmt.Errorf("Render workers count must be at least 1")
+	}
+
+	return nil
+}
+
 func passwordSetter(value string) (string, error) {
 	// Nothing to do on unset
 	if value == "" {
@@ -316,13 +385,27 @@ func passwordSetter(value string) (string, error) {
 	return value, nil
 }
 
+func validateOIDCGroups(value string) error {
+	_, err := oidc.ParseRoles("", value)
+	return err
+}
+
 func validateCompression(value string) error {
-	if value == "none" {
+	return util.ValidateCompression(value)
+}
+
+func validateSchedule(value string) error {
+	if value == "" {
 		return nil
 	}
 
-	_, err := exec.LookPath(value)
-	return err
+	// Extend the schedule to one that is accepted by the used cron parser
+	_, err := cron.Parse(fmt.Sprintf("* %s", value))
+	if err != nil {
+		return errors.Wrap(err, "Error parsing schedule")
+	}
+
+	return nil
 }
 
 func deprecatedStorage(value string) (string, error) {
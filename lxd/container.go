@@ -174,6 +174,25 @@ func containerValidDeviceConfigKey(t, k string) bool {
 			return true
 		case "shift":
 			return true
+		case "usage.warning":
+			return true
+		case "backing":
+			return true
+		case "numa":
+			return true
+		case "hugepages.size":
+			return true
+		case "io.threads":
+			return true
+		default:
+			return false
+		}
+	case "hotplug":
+		switch k {
+		case "subsystem", "vendorid", "productid", "serial", "devname":
+			return true
+		case "mode", "gid", "uid":
+			return true
 		default:
 			return false
 		}
@@ -216,7 +235,9 @@ func allowedUnprivilegedOnlyMap(rawIdmap string) error {
 	return nil
 }
 
-func containerValidConfig(sysOS *sys.OS, config map[string]string, profile bool, expanded bool) error {
+// The restrictions of the container's project, if any, are only checked
+// against the expanded configuration.
+func containerValidConfig(sysOS *sys.OS, config map[string]string, profile bool, expanded bool, restrictions map[string]string) error {
 	if config == nil {
 		return nil
 	}
@@ -242,6 +263,10 @@ func containerValidConfig(sysOS *sys.OS, config map[string]string, profile bool,
 	blacklistDefault := shared.IsTrue(config["security.syscalls.blacklist_default"])
 	blacklistCompat := shared.IsTrue(config["security.syscalls.blacklist_compat"])
 
+	if config["raw.apparmor"] != "" && config["security.apparmor.profile"] != "" {
+		return fmt.Errorf("raw.apparmor is mutually exclusive with security.apparmor.profile")
+	}
+
 	if rawSeccomp && (whitelist || blacklist || blacklistDefault || blacklistCompat) {
 		return fmt.Errorf("raw.seccomp is mutually exclusive with security.syscalls*")
 	}
@@ -250,6 +275,33 @@ func containerValidConfig(sysOS *sys.OS, config map[string]string, profile bool,
 		return fmt.Errorf("security.syscalls.whitelist is mutually exclusive with security.syscalls.blacklist*")
 	}
 
+	if config["limits.cpu.allowance.burst"] != "" && !sysOS.CGroupCPUBurst {
+		return fmt.Errorf("limits.cpu.allowance.burst isn't supported by this kernel")
+	}
+
+	if expanded && config["security.nesting.cgroups"] == "full" {
+		err := containerValidNestedCgroups(sysOS, config)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Profiles are checked too so that they can't hold what the project
+	// forbids, even though the containers are checked on their own
+	if expanded || profile {
+		err := projectRestrictionsCheckConfig(restrictions, config)
+		if err != nil {
+			return err
+		}
+	}
+
+	if expanded {
+		err := containerSysctlValidate(config)
+		if err != nil {
+			return err
+		}
+	}
+
 	if expanded && (config["security.privileged"] == "" || !shared.IsTrue(config["security.privileged"])) && sysOS.IdmapSet == nil {
 		return fmt.Errorf("LXD doesn't have a uid/gid allocation. In this mode, only privileged containers are supported")
 	}
@@ -271,7 +323,48 @@ func containerValidConfig(sysOS *sys.OS, config map[string]string, profile bool,
 	return nil
 }
 
-func containerValidDevices(state *state.State, cluster *db.Cluster, devices config.Devices, profile bool, expanded bool) error {
+// containerValidNestedCgroups checks that the host can provide a writable
+// cgroup tree with the controllers needed by nested container runtimes.
+func containerValidNestedCgroups(sysOS *sys.OS, config map[string]string) error {
+	if !shared.IsTrue(config["security.nesting"]) {
+		return fmt.Errorf("security.nesting.cgroups=full requires security.nesting")
+	}
+
+	if !sysOS.CGroupNamespace {
+		return fmt.Errorf("security.nesting.cgroups=full requires cgroup namespace support")
+	}
+
+	controllers := []struct {
+		name      string
+		available bool
+	}{
+		{"blkio", sysOS.CGroupBlkioController},
+		{"cpu", sysOS.CGroupCPUController},
+		{"cpuacct", sysOS.CGroupCPUacctController},
+		{"cpuset", sysOS.CGroupCPUsetController},
+		{"devices", sysOS.CGroupDevicesController},
+		{"freezer", sysOS.CGroupFreezerController},
+		{"memory", sysOS.CGroupMemoryController},
+		{"pids", sysOS.CGroupPidsController},
+	}
+
+	missing := []string{}
+	for _, controller := range controllers {
+		if !controller.available {
+			missing = append(missing, controller.name)
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("security.nesting.cgroups=full requires the missing cgroup controllers: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// The restrictions of the container's project, if any, are checked against
+// the expanded devices and the devices of profiles.
+func containerValidDevices(state *state.State, cluster *db.Cluster, devices config.Devices, profile bool, expanded bool, restrictions map[string]string) error {
 	// Empty device list
 	if devices == nil {
 		return nil
@@ -284,10 +377,17 @@ func containerValidDevices(state *state.State, cluster *db.Cluster, devices conf
 			return fmt.Errorf("Missing device type for device '%s'", name)
 		}
 
-		if !shared.StringInSlice(m["type"], []string{"disk", "gpu", "infiniband", "nic", "none", "proxy", "unix-block", "unix-char", "usb"}) {
+		if !shared.StringInSlice(m["type"], []string{"disk", "entropy", "gpu", "hotplug", "infiniband", "nic", "none", "proxy", "tpm", "unix-block", "unix-char", "usb", "watchdog"}) {
 			return fmt.Errorf("Invalid device type for device '%s'", name)
 		}
 
+		if expanded || profile {
+			err := projectRestrictionsCheckDevice(cluster, restrictions, name, m)
+			if err != nil {
+				return err
+			}
+		}
+
 		// Validate config using device interface.
 		_, err := device.New(&containerLXC{}, state, name, config.Device(m), nil, nil)
 		if err != device.ErrUnsupportedDevType {
@@ -326,42 +426,57 @@ func containerValidDevices(state *state.State, cluster *db.Cluster, devices conf
 				return fmt.Errorf("Disk entry is missing the required \"path\" property")
 			}
 
-			if m["source"] == "" && m["path"] != "/" {
-				return fmt.Errorf("Disk entry is missing the required \"source\" property")
-			}
-
-			if m["path"] == "/" && m["source"] != "" {
-				return fmt.Errorf("Root disk entry may not have a \"source\" property set")
-			}
+			if m["backing"] != "" {
+				// Hugepages backed disks have no source, pool or quota
+				err := containerHugepagesValidDisk(m)
+				if err != nil {
+					return err
+				}
+			} else {
+				if m["source"] == "" && m["path"] != "/" {
+					return fmt.Errorf("Disk entry is missing the required \"source\" property")
+				}
 
-			if m["size"] != "" && m["path"] != "/" {
-				return fmt.Errorf("Only the root disk may have a size quota")
-			}
+				if m["path"] == "/" && m["source"] != "" {
+					return fmt.Errorf("Root disk entry may not have a \"source\" property set")
+				}
 
-			if (m["path"] == "/" || !shared.IsDir(shared.HostPath(m["source"]))) && m["recursive"] != "" {
-				return fmt.Errorf("The recursive option is only supported for additional bind-mounted paths")
-			}
+				if m["size"] != "" && m["path"] != "/" {
+					if m["pool"] == "" && !shared.IsDir(shared.HostPath(m["source"])) {
+						return fmt.Errorf("Only the root disk, storage volumes and directories may have a size quota")
+					}
 
-			if m["pool"] != "" {
-				if filepath.IsAbs(m["source"]) {
-					return fmt.Errorf("Storage volumes cannot be specified as absolute paths")
+					_, err := units.ParseByteSizeString(m["size"])
+					if err != nil {
+						return errors.Wrap(err, "Invalid disk size")
+					}
 				}
 
-				_, err := cluster.StoragePoolGetID(m["pool"])
-				if err != nil {
-					return fmt.Errorf("The \"%s\" storage pool doesn't exist", m["pool"])
+				if (m["path"] == "/" || !shared.IsDir(shared.HostPath(m["source"]))) && m["recursive"] != "" {
+					return fmt.Errorf("The recursive option is only supported for additional bind-mounted paths")
 				}
 
-				if !profile && expanded && m["source"] != "" && m["path"] != "/" {
-					isAvailable, err := cluster.StorageVolumeIsAvailable(
-						m["pool"], m["source"])
+				if m["pool"] != "" {
+					if filepath.IsAbs(m["source"]) {
+						return fmt.Errorf("Storage volumes cannot be specified as absolute paths")
+					}
+
+					_, err := cluster.StoragePoolGetID(m["pool"])
 					if err != nil {
-						return errors.Wrap(err, "Check if volume is available")
+						return fmt.Errorf("The \"%s\" storage pool doesn't exist", m["pool"])
 					}
-					if !isAvailable {
-						return fmt.Errorf(
-							"Storage volume %q is already attached to a container "+
-								"on a different node", m["source"])
+
+					if !profile && expanded && m["source"] != "" && m["path"] != "/" {
+						isAvailable, err := cluster.StorageVolumeIsAvailable(
+							m["pool"], m["source"])
+						if err != nil {
+							return errors.Wrap(err, "Check if volume is available")
+						}
+						if !isAvailable {
+							return fmt.Errorf(
+								"Storage volume %q is already attached to a container "+
+									"on a different node", m["source"])
+						}
 					}
 				}
 			}
@@ -377,6 +492,24 @@ func containerValidDevices(state *state.State, cluster *db.Cluster, devices conf
 					return fmt.Errorf("The \"shift\" property cannot be used with custom storage volumes")
 				}
 			}
+
+			if m["usage.warning"] != "" {
+				_, err := containerDiskUsageWarningParse(m["usage.warning"])
+				if err != nil {
+					return err
+				}
+			}
+
+			if m["io.threads"] != "" {
+				if m["pool"] != "" || m["backing"] != "" || m["path"] == "/" {
+					return fmt.Errorf("The number of I/O threads can only be set for block device disks")
+				}
+
+				threads, err := strconv.ParseUint(m["io.threads"], 10, 32)
+				if err != nil || threads == 0 {
+					return fmt.Errorf("Invalid value for io.threads: %q", m["io.threads"])
+				}
+			}
 		} else if shared.StringInSlice(m["type"], []string{"unix-char", "unix-block"}) {
 			if m["source"] == "" && m["path"] == "" {
 				return fmt.Errorf("Unix device entry is missing the required \"source\" or \"path\" property")
@@ -406,6 +539,11 @@ func containerValidDevices(state *state.State, cluster *db.Cluster, devices conf
 			}
 		} else if m["type"] == "usb" {
 			// Nothing needed for usb.
+		} else if m["type"] == "hotplug" {
+			err := hotplugDeviceValidate(m)
+			if err != nil {
+				return err
+			}
 		} else if m["type"] == "none" {
 			continue
 		} else {
@@ -435,6 +573,8 @@ type container interface {
 
 	// Snapshots & migration & backups
 	Restore(sourceContainer container, stateful bool) error
+	Rebuild(fingerprint string) error
+	RemapRootfs() error
 	/* actionScript here is a script called action.sh in the stateDir, to
 	 * be passed to CRIU as --action-script
 	 */
@@ -447,12 +587,13 @@ type container interface {
 	Update(newConfig db.ContainerArgs, userRequested bool) error
 
 	Delete() error
-	Export(w io.Writer, properties map[string]string) error
+	Export(w io.Writer, properties map[string]string, parent string) error
 
 	// Live configuration
 	CGroupGet(key string) (string, error)
 	CGroupSet(key string, value string) error
 	VolatileSet(changes map[string]string) error
+	NetworkReapply(restart bool) error
 
 	// File handling
 	FileExists(path string) error
@@ -496,6 +637,7 @@ type container interface {
 
 	// Hooks
 	OnStart() error
+	OnStartHost(pid int) error
 	OnStopNS(target string, netns string) error
 	OnStop(target string) error
 
@@ -557,7 +699,9 @@ func containerCreateAsEmpty(d *Daemon, args db.ContainerArgs) (container, error)
 	}
 
 	// Now create the empty storage
+	release := storagePoolOperationStart(c.Storage(), c)
 	err = c.Storage().ContainerCreate(c)
+	release()
 	if err != nil {
 		c.Delete()
 		return nil, err
@@ -663,30 +807,9 @@ func containerCreateFromImage(d *Daemon, args db.ContainerArgs, hash string, tra
 	}
 
 	// Check if the image is available locally or it's on another node.
-	nodeAddress, err := s.Cluster.ImageLocate(hash)
+	err = containerImageMakeLocal(d, args.Project, hash)
 	if err != nil {
-		return nil, errors.Wrapf(err, "Locate image %s in the cluster", hash)
-	}
-	if nodeAddress != "" {
-		// The image is available from another node, let's try to
-		// import it.
-		logger.Debugf("Transferring image %s from node %s", hash, nodeAddress)
-		client, err := cluster.Connect(nodeAddress, d.endpoints.NetworkCert(), false)
-		if err != nil {
-			return nil, err
-		}
-
-		client = client.UseProject(args.Project)
-
-		err = imageImportFromNode(filepath.Join(d.os.VarDir, "images"), client, hash)
-		if err != nil {
-			return nil, err
-		}
-
-		err = d.cluster.ImageAssociateNode(args.Project, hash)
-		if err != nil {
-			return nil, err
-		}
+		return nil, err
 	}
 
 	// Set the "image.*" keys
@@ -712,7 +835,9 @@ func containerCreateFromImage(d *Daemon, args db.ContainerArgs, hash string, tra
 	}
 
 	// Now create the storage from an image
+	release := storagePoolOperationStart(c.Storage(), c)
 	err = c.Storage().ContainerCreateFromImage(c, hash, tracker)
+	release()
 	if err != nil {
 		c.Delete()
 		return nil, errors.Wrap(err, "Create container from image")
@@ -728,6 +853,35 @@ func containerCreateFromImage(d *Daemon, args db.ContainerArgs, hash string, tra
 	return c, nil
 }
 
+// containerImageMakeLocal imports an image from another node if it isn't
+// available on this one.
+func containerImageMakeLocal(d *Daemon, project string, hash string) error {
+	nodeAddress, err := d.cluster.ImageLocate(hash)
+	if err != nil {
+		return errors.Wrapf(err, "Locate image %s in the cluster", hash)
+	}
+	if nodeAddress == "" {
+		return nil
+	}
+
+	// The image is available from another node, let's try to
+	// import it.
+	logger.Debugf("Transferring image %s from node %s", hash, nodeAddress)
+	client, err := cluster.Connect(nodeAddress, d.endpoints.NetworkCert(), false)
+	if err != nil {
+		return err
+	}
+
+	client = client.UseProject(project)
+
+	err = imageImportFromNode(filepath.Join(d.os.VarDir, "images"), client, hash)
+	if err != nil {
+		return err
+	}
+
+	return d.cluster.ImageAssociateNode(project, hash)
+}
+
 func containerCreateAsCopy(s *state.State, args db.ContainerArgs, sourceContainer container, containerOnly bool, refresh bool) (container, error) {
 	var ct container
 	var err error
@@ -853,13 +1007,16 @@ func containerCreateAsCopy(s *state.State, args db.ContainerArgs, sourceContaine
 	}
 
 	// Now clone or refresh the storage
+	release := storagePoolOperationStart(ct.Storage(), ct)
 	if refresh {
 		err = ct.Storage().ContainerRefresh(ct, sourceContainer, snapshots)
+		release()
 		if err != nil {
 			return nil, err
 		}
 	} else {
 		err = ct.Storage().ContainerCopy(ct, sourceContainer, containerOnly)
+		release()
 		if err != nil {
 			if !refresh {
 				ct.Delete()
@@ -896,6 +1053,42 @@ func containerCreateAsCopy(s *state.State, args db.ContainerArgs, sourceContaine
 	return ct, nil
 }
 
+// containerCreateAsClone creates a new container whose root filesystem is an
+// instant copy-on-write copy of the source container, leaving its snapshots
+// behind. Both containers must use the same storage pool.
+func containerCreateAsClone(s *state.State, args db.ContainerArgs, sourceContainer container) (container, error) {
+	// Create the container.
+	ct, err := containerCreateInternal(s, args)
+	if err != nil {
+		return nil, err
+	}
+
+	_, sourcePool, _ := sourceContainer.Storage().GetContainerPoolInfo()
+	_, targetPool, _ := ct.Storage().GetContainerPoolInfo()
+	if sourcePool != targetPool {
+		ct.Delete()
+		return nil, fmt.Errorf("Copy-on-write copies require the source and target to use the same storage pool")
+	}
+
+	// Now clone the storage
+	release := storagePoolOperationStart(ct.Storage(), ct)
+	err = ct.Storage().ContainerClone(ct, sourceContainer)
+	release()
+	if err != nil {
+		ct.Delete()
+		return nil, err
+	}
+
+	// Apply any post-storage configuration.
+	err = containerConfigureInternal(ct)
+	if err != nil {
+		ct.Delete()
+		return nil, err
+	}
+
+	return ct, nil
+}
+
 func containerCreateAsSnapshot(s *state.State, args db.ContainerArgs, sourceContainer container) (container, error) {
 	// Deal with state
 	if args.Stateful {
@@ -924,17 +1117,7 @@ func containerCreateAsSnapshot(s *state.State, args db.ContainerArgs, sourceCont
 		 * after snapshotting will fail.
 		 */
 
-		criuMigrationArgs := CriuMigrationArgs{
-			cmd:          lxc.MIGRATE_DUMP,
-			stateDir:     stateDir,
-			function:     "snapshot",
-			stop:         false,
-			actionScript: false,
-			dumpDir:      "",
-			preDumpDir:   "",
-		}
-
-		err = sourceContainer.Migrate(&criuMigrationArgs)
+		err = containerCheckpoint(sourceContainer, stateDir, false, nil)
 		if err != nil {
 			os.RemoveAll(sourceContainer.StatePath())
 			return nil, err
@@ -948,7 +1131,9 @@ func containerCreateAsSnapshot(s *state.State, args db.ContainerArgs, sourceCont
 	}
 
 	// Clone the container
+	release := storagePoolOperationStart(sourceContainer.Storage(), c)
 	err = sourceContainer.Storage().ContainerSnapshotCreate(c, sourceContainer)
+	release()
 	if err != nil {
 		c.Delete()
 		return nil, err
@@ -984,6 +1169,49 @@ func containerCreateAsSnapshot(s *state.State, args db.ContainerArgs, sourceCont
 	return c, nil
 }
 
+// containerFillDefaultRootDisk adds a root disk device using the default pool
+// of the project when neither the container nor its profiles set a pool.
+func containerFillDefaultRootDisk(s *state.State, args *db.ContainerArgs) error {
+	defaultPool, _, err := projectStoragePools(s.Cluster, args.Project)
+	if err != nil {
+		return err
+	}
+
+	if defaultPool == "" {
+		return nil
+	}
+
+	profiles, err := s.Cluster.ProfilesGet(args.Project, args.Profiles)
+	if err != nil {
+		return err
+	}
+
+	projConfig, err := projectConfig(s.Cluster, args.Project)
+	if err != nil {
+		return err
+	}
+
+	devices := projectExpandDevices(projConfig, args.Devices, profiles)
+	rootKey, rootDevice, _ := shared.GetRootDiskDevice(devices)
+	if rootKey != "" && rootDevice["pool"] != "" {
+		return nil
+	}
+
+	if rootKey == "" {
+		rootKey = "root"
+		rootDevice = map[string]string{"type": "disk", "path": "/"}
+	}
+
+	device := map[string]string{}
+	for k, v := range rootDevice {
+		device[k] = v
+	}
+	device["pool"] = defaultPool
+	args.Devices[rootKey] = device
+
+	return nil
+}
+
 func containerCreateInternal(s *state.State, args db.ContainerArgs) (container, error) {
 	// Set default values
 	if args.Project == "" {
@@ -1022,13 +1250,13 @@ func containerCreateInternal(s *state.State, args db.ContainerArgs) (container,
 	}
 
 	// Validate container config
-	err := containerValidConfig(s.OS, args.Config, false, false)
+	err := containerValidConfig(s.OS, args.Config, false, false, nil)
 	if err != nil {
 		return nil, err
 	}
 
 	// Validate container devices
-	err = containerValidDevices(s, s.Cluster, args.Devices, false, false)
+	err = containerValidDevices(s, s.Cluster, args.Devices, false, false, nil)
 	if err != nil {
 		return nil, errors.Wrap(err, "Invalid devices")
 	}
@@ -1062,6 +1290,15 @@ func containerCreateInternal(s *state.State, args db.ContainerArgs) (container,
 		checkedProfiles = append(checkedProfiles, profile)
 	}
 
+	// Give the container a root disk on the default pool of the project if
+	// it doesn't get one with a pool from its profiles
+	if args.Ctype == db.CTypeRegular {
+		err = containerFillDefaultRootDisk(s, &args)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	if args.CreationDate.IsZero() {
 		args.CreationDate = time.Now().UTC()
 	}
@@ -1225,7 +1462,7 @@ func containerLoadByProjectAndName(s *state.State, project, name string) (contai
 
 	args := db.ContainerToArgs(container)
 
-	c, err := containerLXCLoad(s, args, nil)
+	c, err := containerLXCLoad(s, args, nil, nil)
 	if err != nil {
 		return nil, errors.Wrap(err, "Failed to load container")
 	}
@@ -1355,6 +1592,17 @@ func containerLoadAllInternal(cts []db.Instance, s *state.State) ([]container, e
 		}
 	}
 
+	// Get the project configs, holding their default config and devices
+	var projectConfigs map[string]map[string]string
+	err := s.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		projectConfigs, err = tx.ProjectConfigRef(db.ProjectFilter{})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	// Load the container structs
 	containers := []container{}
 	for _, container := range cts {
@@ -1366,7 +1614,13 @@ func containerLoadAllInternal(cts []db.Instance, s *state.State) ([]container, e
 
 		args := db.ContainerToArgs(&container)
 
-		ct, err := containerLXCLoad(s, args, cProfiles)
+		// Projects without any config aren't listed
+		projConfig, ok := projectConfigs[container.Project]
+		if !ok {
+			projConfig = map[string]string{}
+		}
+
+		ct, err := containerLXCLoad(s, args, cProfiles, projConfig)
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+var projectActivityCmd = APIEndpoint{
+	Name: "projects/{name}/activity",
+
+	Get: APIEndpointAction{Handler: projectActivityGet, AccessHandler: AllowAuthenticated},
+}
+
+// Number of bytes a container must send or receive between two checks for its
+// network traffic to count as activity.
+const containerActivityNetworkThreshold = 64 * 1024
+
+// The activity of the containers on this node which wasn't yet written to the
+// database, indexed by container ID and then activity type. It gets flushed
+// every minute so that busy containers don't cause a write per request.
+var containerActivityLock sync.Mutex
+var containerActivityPending = map[int]map[string]time.Time{}
+
+// The network byte counters of the running containers as of the last check,
+// indexed by container ID.
+var containerActivityNetworkBytes = map[int]uint64{}
+
+// containerActivityRecord notes that the container had activity of the given
+// type.
+func containerActivityRecord(c container, kind string) {
+	containerActivityLock.Lock()
+	defer containerActivityLock.Unlock()
+
+	if containerActivityPending[c.Id()] == nil {
+		containerActivityPending[c.Id()] = map[string]time.Time{}
+	}
+
+	containerActivityPending[c.Id()][kind] = time.Now().UTC()
+}
+
+// containerActivityRender returns the latest activity of the container, from
+// the activity recorded in the database, which is loaded unless given, and
+// the activity which wasn't flushed yet.
+func containerActivityRender(c container, activity map[string]time.Time) (api.ContainerActivity, error) {
+	if activity == nil {
+		err := c.DaemonState().Cluster.Transaction(func(tx *db.ClusterTx) error {
+			var err error
+			activity, err = tx.ContainerActivityGet(c.Id())
+			return err
+		})
+		if err != nil {
+			return api.ContainerActivity{}, err
+		}
+	}
+
+	return containerActivityMerge(c.Id(), activity), nil
+}
+
+// containerActivityMerge returns the latest activity of the container with the
+// given ID, merging the activity which wasn't flushed yet into the recorded one.
+func containerActivityMerge(id int, recorded map[string]time.Time) api.ContainerActivity {
+	activity := map[string]time.Time{}
+	for kind, date := range recorded {
+		activity[kind] = date
+	}
+
+	containerActivityLock.Lock()
+	for kind, date := range containerActivityPending[id] {
+		if date.After(activity[kind]) {
+			activity[kind] = date
+		}
+	}
+	containerActivityLock.Unlock()
+
+	return api.ContainerActivity{
+		LastExecAt:    activity[db.ContainerActivityExec],
+		LastConsoleAt: activity[db.ContainerActivityConsole],
+		LastFileAt:    activity[db.ContainerActivityFile],
+		LastNetworkAt: activity[db.ContainerActivityNetwork],
+	}
+}
+
+// containerActivityPreload loads the activity recorded for the containers of
+// a project at once, for them to be rendered without a query each.
+func containerActivityPreload(s *state.State, project string, containers []container) error {
+	var activity map[int]map[string]time.Time
+	err := s.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		activity, err = tx.ContainerActivityGetProject(project)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, c := range containers {
+		ct, ok := c.(*containerLXC)
+		if !ok {
+			continue
+		}
+
+		ct.activity = activity[c.Id()]
+		if ct.activity == nil {
+			ct.activity = map[string]time.Time{}
+		}
+	}
+
+	return nil
+}
+
+// /1.0/projects/{name}/activity
+// Get the latest activity of the containers of a project
+func projectActivityGet(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	// Check user permissions
+	if !d.userHasPermission(r, name, "view") {
+		return Forbidden(nil)
+	}
+
+	var containers map[string]int
+	var activity map[int]map[string]time.Time
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		_, err := tx.ProjectGet(name)
+		if err != nil {
+			return err
+		}
+
+		containers, err = tx.ContainerIDsByName(name)
+		if err != nil {
+			return err
+		}
+
+		activity, err = tx.ContainerActivityGetProject(name)
+		return err
+	})
+	if err != nil {
+		return SmartError(err)
+	}
+
+	result := api.ProjectActivity{Containers: map[string]api.ContainerActivity{}}
+	for ctName, id := range containers {
+		ct := containerActivityMerge(id, activity[id])
+		result.Containers[ctName] = ct
+
+		for _, date := range []time.Time{ct.LastExecAt, ct.LastConsoleAt, ct.LastFileAt, ct.LastNetworkAt} {
+			if date.After(result.LastActivityAt) {
+				result.LastActivityAt = date
+			}
+		}
+	}
+
+	return SyncResponse(true, result)
+}
+
+// containerActivityNetworkCount returns the number of bytes sent and received
+// by the host side of the network interfaces of a running container.
+func containerActivityNetworkCount(c container) (uint64, error) {
+	total := uint64(0)
+	for _, name := range c.ExpandedDevices().DeviceNames() {
+		hostName := c.LocalConfig()[fmt.Sprintf("volatile.%s.host_name", name)]
+		if hostName == "" {
+			continue
+		}
+
+		for _, counter := range []string{"rx_bytes", "tx_bytes"} {
+			content, err := ioutil.ReadFile(fmt.Sprintf("/sys/class/net/%s/statistics/%s", hostName, counter))
+			if err != nil {
+				return 0, err
+			}
+
+			value, err := strconv.ParseUint(strings.TrimSpace(string(content)), 10, 64)
+			if err != nil {
+				return 0, err
+			}
+
+			total += value
+		}
+	}
+
+	return total, nil
+}
+
+func containerActivityTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		containers, err := containerLoadNodeAll(d.State())
+		if err != nil {
+			logger.Error("Failed to load containers for the activity tracking", log.Ctx{"err": err})
+			return
+		}
+
+		now := time.Now().UTC()
+		exists := map[int]bool{}
+		running := map[int]bool{}
+		for _, c := range containers {
+			if c.IsSnapshot() {
+				continue
+			}
+
+			exists[c.Id()] = true
+			if !c.IsRunning() {
+				continue
+			}
+
+			count, err := containerActivityNetworkCount(c)
+			if err != nil {
+				logger.Debug("Failed to read container network counters", log.Ctx{"container": c.Name(), "project": c.Project(), "err": err})
+				continue
+			}
+			running[c.Id()] = true
+
+			containerActivityLock.Lock()
+			previous, ok := containerActivityNetworkBytes[c.Id()]
+			containerActivityNetworkBytes[c.Id()] = count
+			if ok && count >= previous && count-previous >= containerActivityNetworkThreshold {
+				if containerActivityPending[c.Id()] == nil {
+					containerActivityPending[c.Id()] = map[string]time.Time{}
+				}
+
+				containerActivityPending[c.Id()][db.ContainerActivityNetwork] = now
+			}
+			containerActivityLock.Unlock()
+		}
+
+		// Take the pending activity, forgetting about the containers which are gone or stopped
+		containerActivityLock.Lock()
+		pending := containerActivityPending
+		containerActivityPending = map[int]map[string]time.Time{}
+		for id := range containerActivityNetworkBytes {
+			if !running[id] {
+				delete(containerActivityNetworkBytes, id)
+			}
+		}
+		containerActivityLock.Unlock()
+
+		for id := range pending {
+			if !exists[id] {
+				delete(pending, id)
+			}
+		}
+
+		if len(pending) == 0 {
+			return
+		}
+
+		err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+			for id, activity := range pending {
+				for kind, date := range activity {
+					err := tx.ContainerActivityUpdate(id, kind, date)
+					if err != nil {
+						return err
+					}
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
+			logger.Error("Failed to record container activity", log.Ctx{"err": err})
+		}
+	}
+
+	return f, task.Every(time.Minute)
+}
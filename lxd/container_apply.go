@@ -0,0 +1,313 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+)
+
+var containerApplyCmd = APIEndpoint{
+	Name: "containers/{name}/apply",
+
+	Post: APIEndpointAction{Handler: containerApplyPost, AccessHandler: AllowProjectPermission("containers", "manage-containers")},
+}
+
+// Config keys which are only applied when the container starts, changing them
+// on a running container requires a restart.
+var containerApplyRestartKeys = []string{
+	"nvidia.driver.capabilities",
+	"nvidia.require.cuda",
+	"nvidia.require.driver",
+	"nvidia.runtime",
+	"raw.idmap",
+	"raw.lxc",
+	"raw.seccomp",
+	"security.idmap.base",
+	"security.idmap.isolated",
+	"security.idmap.size",
+	"security.nesting.cgroups",
+	"security.nesting.profile",
+	"security.privileged",
+	"security.syscalls.blacklist",
+	"security.syscalls.blacklist_compat",
+	"security.syscalls.blacklist_default",
+	"security.syscalls.intercept.mknod",
+	"security.syscalls.intercept.setxattr",
+	"security.syscalls.whitelist",
+}
+
+// How long a container is given to shutdown cleanly before being killed.
+var containerApplyShutdownTimeout = 30 * time.Second
+
+func containerApplyPost(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	// Handle requests targeted to a container on a different node
+	response, err := ForwardedResponseIfContainerIsRemote(d, r, project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+	if response != nil {
+		return response
+	}
+
+	req := api.ContainerApplyPost{}
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	if !shared.StringInSlice(req.State, []string{"", "running", "stopped"}) {
+		return BadRequest(fmt.Errorf("Invalid state %q, must be either \"running\" or \"stopped\"", req.State))
+	}
+
+	// Don't mess with containers while in setup mode
+	<-d.readyChan
+
+	c, err := containerLoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if c.IsSnapshot() {
+		return BadRequest(fmt.Errorf("A desired state can't be applied to snapshots"))
+	}
+
+	args, steps, err := containerApplyPlan(d, c, req)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	args.Admin = d.userIsAdmin(r)
+
+	if req.DryRun {
+		return SyncResponse(true, steps)
+	}
+
+	for i := range steps {
+		steps[i].Status = "pending"
+	}
+
+	run := func(op *operation) error {
+		c.SetOperation(op)
+		return containerApplyRun(op, c, args, steps)
+	}
+
+	resources := map[string][]string{}
+	resources["containers"] = []string{name}
+
+	op, err := operationCreate(d.cluster, project, operationClassTask, db.OperationContainerApply, resources, shared.Jmap{"plan": steps}, run, nil, nil)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	return OperationResponse(op)
+}
+
+// containerApplyPlan computes the steps needed to bring the container to the
+// desired state, along with the arguments of its update if one is needed.
+func containerApplyPlan(d *Daemon, c container, req api.ContainerApplyPost) (db.ContainerArgs, []api.ContainerApplyStep, error) {
+	// The volatile keys are managed by LXD, keep the current ones
+	newConfig := map[string]string{}
+	for k, v := range req.Config {
+		if !strings.HasPrefix(k, "volatile.") {
+			newConfig[k] = v
+		}
+	}
+
+	oldConfig := c.LocalConfig()
+	for k, v := range oldConfig {
+		if strings.HasPrefix(k, "volatile.") {
+			newConfig[k] = v
+		}
+	}
+
+	newDevices := config.Devices(req.Devices)
+	if newDevices == nil {
+		newDevices = config.Devices{}
+	}
+	oldDevices := c.LocalDevices()
+
+	newProfiles := req.Profiles
+	if newProfiles == nil {
+		newProfiles = []string{}
+	}
+
+	args := db.ContainerArgs{
+		Architecture: c.Architecture(),
+		Config:       newConfig,
+		Description:  req.Description,
+		Devices:      newDevices,
+		Ephemeral:    req.Ephemeral,
+		Profiles:     newProfiles,
+		Project:      c.Project(),
+	}
+
+	// Diff the local configuration
+	changes := []string{}
+	for _, k := range containerApplyChangedKeys(oldConfig, newConfig) {
+		changes = append(changes, fmt.Sprintf("config.%s", k))
+	}
+
+	deviceNames := map[string]bool{}
+	for name := range oldDevices {
+		deviceNames[name] = true
+	}
+	for name := range newDevices {
+		deviceNames[name] = true
+	}
+
+	for name := range deviceNames {
+		if !reflect.DeepEqual(oldDevices[name], newDevices[name]) {
+			changes = append(changes, fmt.Sprintf("devices.%s", name))
+		}
+	}
+
+	if strings.Join(c.Profiles(), "\n") != strings.Join(newProfiles, "\n") {
+		changes = append(changes, "profiles")
+	}
+
+	if c.IsEphemeral() != req.Ephemeral {
+		changes = append(changes, "ephemeral")
+	}
+
+	if c.Description() != req.Description {
+		changes = append(changes, "description")
+	}
+
+	sort.Strings(changes)
+
+	// Figure out the config keys which can't be applied live
+	restartKeys := []string{}
+	if len(changes) > 0 && c.IsRunning() && req.State != "stopped" {
+		profiles, err := d.cluster.ProfilesGet(c.Project(), newProfiles)
+		if err != nil {
+			return args, nil, err
+		}
+
+		projConfig, err := projectConfig(d.cluster, c.Project())
+		if err != nil {
+			return args, nil, err
+		}
+
+		expandedConfig := projectExpandConfig(projConfig, newConfig, profiles)
+		for _, k := range containerApplyChangedKeys(c.ExpandedConfig(), expandedConfig) {
+			if shared.StringInSlice(k, containerApplyRestartKeys) || strings.HasPrefix(k, "limits.kernel.") {
+				restartKeys = append(restartKeys, fmt.Sprintf("config.%s", k))
+			}
+		}
+	}
+
+	steps := []api.ContainerApplyStep{}
+	if c.IsRunning() && req.State == "stopped" {
+		steps = append(steps, api.ContainerApplyStep{Action: "stop", Changes: []string{"state"}})
+	}
+
+	if len(changes) > 0 {
+		steps = append(steps, api.ContainerApplyStep{Action: "update", Changes: changes})
+	}
+
+	if len(restartKeys) > 0 {
+		steps = append(steps, api.ContainerApplyStep{Action: "restart", Changes: restartKeys})
+	}
+
+	if !c.IsRunning() && req.State == "running" {
+		steps = append(steps, api.ContainerApplyStep{Action: "start", Changes: []string{"state"}})
+	}
+
+	for i := range steps {
+		steps[i].Status = "planned"
+	}
+
+	return args, steps, nil
+}
+
+// containerApplyChangedKeys returns the sorted keys whose value differs
+// between the two configurations.
+func containerApplyChangedKeys(oldConfig map[string]string, newConfig map[string]string) []string {
+	keys := []string{}
+	for k, v := range oldConfig {
+		newValue, ok := newConfig[k]
+		if !ok || newValue != v {
+			keys = append(keys, k)
+		}
+	}
+
+	for k := range newConfig {
+		_, ok := oldConfig[k]
+		if !ok {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// containerApplyRun executes the plan, recording the outcome of each step in
+// the operation metadata. The steps following a failed one are skipped.
+func containerApplyRun(op *operation, c container, args db.ContainerArgs, steps []api.ContainerApplyStep) error {
+	for i, step := range steps {
+		var err error
+
+		switch step.Action {
+		case "update":
+			err = c.Update(args, true)
+		case "stop":
+			err = containerApplyStop(c)
+		case "restart":
+			err = containerApplyStop(c)
+			if err == nil {
+				err = c.Start(false)
+			}
+		case "start":
+			err = c.Start(false)
+		default:
+			err = fmt.Errorf("Unknown action %q", step.Action)
+		}
+
+		if err != nil {
+			steps[i].Status = "failed"
+			steps[i].Error = err.Error()
+			for j := i + 1; j < len(steps); j++ {
+				steps[j].Status = "skipped"
+			}
+
+			op.UpdateMetadata(shared.Jmap{"plan": steps})
+			return fmt.Errorf("Failed to %s the container: %v", step.Action, err)
+		}
+
+		steps[i].Status = "done"
+		op.UpdateMetadata(shared.Jmap{"plan": steps})
+	}
+
+	return nil
+}
+
+func containerApplyStop(c container) error {
+	if c.IsFrozen() {
+		err := c.Unfreeze()
+		if err != nil {
+			return err
+		}
+	}
+
+	err := c.Shutdown(containerApplyShutdownTimeout)
+	if err != nil {
+		return c.Stop(false)
+	}
+
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -144,6 +145,19 @@ func containerBackupsPost(d *Daemon, r *http.Request) Response {
 
 	fullName := name + shared.SnapshotDelimiter + req.Name
 
+	// Validate the compression algorithm
+	if req.CompressionAlgorithm != "" {
+		err = util.ValidateCompression(req.CompressionAlgorithm)
+		if err != nil {
+			return BadRequest(errors.Wrap(err, "Invalid compression algorithm"))
+		}
+	}
+
+	// Validate the chunk size
+	if req.ChunkSize != 0 && (req.ChunkSize < backupChunkSizeMin || req.ChunkSize > backupChunkSizeMax) {
+		return BadRequest(fmt.Errorf("Backup chunks must be between %d and %d bytes", backupChunkSizeMin, backupChunkSizeMax))
+	}
+
 	backup := func(op *operation) error {
 		args := db.ContainerBackupArgs{
 			Name:             fullName,
@@ -154,7 +168,7 @@ func containerBackupsPost(d *Daemon, r *http.Request) Response {
 			OptimizedStorage: req.OptimizedStorage,
 		}
 
-		err := backupCreate(d.State(), args, c)
+		err := backupCreate(d.State(), args, c, req.CompressionAlgorithm, req.ChunkSize)
 		if err != nil {
 			return errors.Wrap(err, "Create backup")
 		}
@@ -313,6 +327,31 @@ func containerBackupExportGet(d *Daemon, r *http.Request) Response {
 		return SmartError(err)
 	}
 
+	// Export a single chunk of the backup
+	chunk := queryParam(r, "chunk")
+	if chunk != "" {
+		index, err := strconv.Atoi(chunk)
+		if err != nil {
+			return BadRequest(fmt.Errorf("Invalid chunk %q", chunk))
+		}
+
+		chunks, err := backupChunksLoad(backup.name)
+		if err != nil {
+			return SmartError(err)
+		}
+
+		if chunks == nil {
+			return BadRequest(fmt.Errorf("The backup wasn't created in chunks"))
+		}
+
+		offset, length, err := backupChunkRange(chunks.Size, chunks.ChunkSize, index)
+		if err != nil {
+			return NotFound(err)
+		}
+
+		return ChunkResponse(shared.VarPath("backups", backup.name), offset, length, chunks.Checksums[index])
+	}
+
 	ent := fileResponseEntry{
 		path: shared.VarPath("backups", backup.name),
 	}
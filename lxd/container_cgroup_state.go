@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// The cgroup directories of the running containers, indexed by container ID
+// and only valid for the init process they were looked up for.
+var containerCGroupDirsLock sync.Mutex
+var containerCGroupDirs = map[int]containerCGroupDirsEntry{}
+
+type containerCGroupDirsEntry struct {
+	pid  int
+	dirs map[string]string
+}
+
+// containerCGroupState reads the cgroup files making the state of a running
+// container straight from /sys/fs/cgroup, rather than through one liblxc
+// lookup per key, each file being read at most once.
+type containerCGroupState struct {
+	c       *containerLXC
+	unified bool
+
+	// The directory of each controller, nil to go through liblxc
+	dirs map[string]string
+
+	files map[string]string
+}
+
+func newContainerCGroupState(c *containerLXC) *containerCGroupState {
+	cg := &containerCGroupState{
+		c:       c,
+		unified: c.state.OS.CGroupV2,
+		files:   map[string]string{},
+	}
+
+	dirs, err := containerCGroupDirsGet(c.Id(), c.InitPID(), cg.unified)
+	if err == nil {
+		cg.dirs = dirs
+	}
+
+	return cg
+}
+
+// Get returns the value of a cgroup key, as CGroupGet would.
+func (cg *containerCGroupState) Get(key string) (string, error) {
+	if cg.dirs == nil {
+		return cg.c.CGroupGet(key)
+	}
+
+	if cg.unified {
+		return cGroupV2Read(key, cg.read)
+	}
+
+	return cg.read(key)
+}
+
+func (cg *containerCGroupState) read(file string) (string, error) {
+	value, ok := cg.files[file]
+	if ok {
+		return value, nil
+	}
+
+	// The unified hierarchy has all the controllers in the same directory
+	controller := ""
+	if !cg.unified {
+		controller = strings.SplitN(file, ".", 2)[0]
+	}
+
+	dir, ok := cg.dirs[controller]
+	if !ok {
+		return "", fmt.Errorf("Failed to get cgroup %s", file)
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, file))
+	if err != nil {
+		return "", err
+	}
+
+	value = strings.TrimSpace(string(content))
+	cg.files[file] = value
+
+	return value, nil
+}
+
+// containerCGroupDirsGet returns the cgroup directories of a running
+// container, looking them up only once per init process.
+func containerCGroupDirsGet(id int, pid int, unified bool) (map[string]string, error) {
+	if pid < 1 {
+		return nil, fmt.Errorf("The container isn't running")
+	}
+
+	containerCGroupDirsLock.Lock()
+	defer containerCGroupDirsLock.Unlock()
+
+	entry, ok := containerCGroupDirs[id]
+	if ok && entry.pid == pid {
+		return entry.dirs, nil
+	}
+
+	f, err := os.Open(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	lines := []string{}
+	scan := bufio.NewScanner(f)
+	for scan.Scan() {
+		lines = append(lines, scan.Text())
+	}
+
+	err = scan.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	dirs := containerCGroupDirsParse(lines, unified)
+	if len(dirs) == 0 {
+		return nil, fmt.Errorf("No cgroup found for process %d", pid)
+	}
+
+	containerCGroupDirs[id] = containerCGroupDirsEntry{pid: pid, dirs: dirs}
+
+	return dirs, nil
+}
+
+// containerCGroupDirsForget drops the cached cgroup directories of a
+// container.
+func containerCGroupDirsForget(id int) {
+	containerCGroupDirsLock.Lock()
+	delete(containerCGroupDirs, id)
+	containerCGroupDirsLock.Unlock()
+}
+
+// containerCGroupDirsParse parses the content of /proc/<pid>/cgroup into the
+// directory of each controller, the unified hierarchy having the empty name.
+func containerCGroupDirsParse(lines []string, unified bool) map[string]string {
+	dirs := map[string]string{}
+
+	for _, line := range lines {
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+
+		// The init system of the container may have moved itself
+		// below the container cgroup
+		cgroup := strings.TrimSuffix(fields[2], "/init.scope")
+
+		if unified {
+			if fields[0] == "0" && fields[1] == "" {
+				dirs[""] = filepath.Join("/sys/fs/cgroup", cgroup)
+			}
+
+			continue
+		}
+
+		if fields[1] == "" || strings.HasPrefix(fields[1], "name=") {
+			continue
+		}
+
+		for _, controller := range strings.Split(fields[1], ",") {
+			dirs[controller] = filepath.Join("/sys/fs/cgroup", fields[1], cgroup)
+		}
+	}
+
+	return dirs
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContainerCGroupDirsParse(t *testing.T) {
+	lines := []string{
+		"12:pids:/lxc.payload/c1",
+		"4:cpu,cpuacct:/lxc.payload/c1",
+		"1:name=systemd:/lxc.payload/c1/init.scope",
+		"5:memory:/lxc.payload/c1/init.scope",
+		"0::/lxc.payload/c1/init.scope",
+	}
+
+	assert.Equal(t, map[string]string{
+		"pids":    "/sys/fs/cgroup/pids/lxc.payload/c1",
+		"cpu":     "/sys/fs/cgroup/cpu,cpuacct/lxc.payload/c1",
+		"cpuacct": "/sys/fs/cgroup/cpu,cpuacct/lxc.payload/c1",
+		"memory":  "/sys/fs/cgroup/memory/lxc.payload/c1",
+	}, containerCGroupDirsParse(lines, false))
+
+	assert.Equal(t, map[string]string{
+		"": "/sys/fs/cgroup/lxc.payload/c1",
+	}, containerCGroupDirsParse(lines, true))
+}
+
+func TestContainerCGroupStateGet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd-cgroup-state-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"memory.current":      "1048576\n",
+		"memory.swap.current": "4096\n",
+		"cpu.stat":            "usage_usec 42\nuser_usec 40\nsystem_usec 2\n",
+	}
+
+	for name, content := range files {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	cg := &containerCGroupState{
+		unified: true,
+		dirs:    map[string]string{"": dir},
+		files:   map[string]string{},
+	}
+
+	value, err := cg.Get("memory.usage_in_bytes")
+	require.NoError(t, err)
+	assert.Equal(t, "1048576", value)
+
+	value, err = cg.Get("memory.memsw.usage_in_bytes")
+	require.NoError(t, err)
+	assert.Equal(t, "1052672", value)
+
+	value, err = cg.Get("cpuacct.usage")
+	require.NoError(t, err)
+	assert.Equal(t, "42000", value)
+
+	// Files are only read once
+	require.NoError(t, os.Remove(filepath.Join(dir, "memory.current")))
+	value, err = cg.Get("memory.usage_in_bytes")
+	require.NoError(t, err)
+	assert.Equal(t, "1048576", value)
+
+	_, err = cg.Get("pids.current")
+	assert.Error(t, err)
+}
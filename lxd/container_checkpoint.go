@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+
+	"gopkg.in/lxc/go-lxc.v2"
+
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// Directory of the final CRIU dump in a state directory holding pre-dumps.
+const containerCheckpointFinalDir = "final"
+
+// containerCheckpoint dumps the state of a running container to stateDir for
+// a stateful stop or snapshot. When incremental memory transfer is enabled,
+// the memory is first pre-dumped up to migration.incremental.memory.iterations
+// times while the container keeps running, so that only the pages dirtied
+// since the last pre-dump are left to write once it's frozen for the final
+// dump. The preDumped function, if any, is called after each pre-dump.
+func containerCheckpoint(c container, stateDir string, stop bool, preDumped func()) error {
+	preDumpDir := ""
+	dumpDir := ""
+
+	iterations := containerPreDumpIterations(c)
+	if iterations > 0 {
+		dumpDir = containerCheckpointFinalDir
+	}
+
+	for i := 1; i <= iterations; i++ {
+		dir := fmt.Sprintf("%03d", i)
+
+		criuMigrationArgs := CriuMigrationArgs{
+			cmd:          lxc.MIGRATE_PRE_DUMP,
+			stateDir:     stateDir,
+			function:     "snapshot",
+			stop:         false,
+			actionScript: false,
+			dumpDir:      dir,
+			preDumpDir:   preDumpDir,
+		}
+
+		err := c.Migrate(&criuMigrationArgs)
+		if err != nil {
+			return err
+		}
+
+		preDumpDir = dir
+
+		if preDumped != nil {
+			preDumped()
+		}
+
+		done, err := preDumpGoalReached(c, filepath.Join(stateDir, dir))
+		if err != nil {
+			return err
+		}
+
+		if done {
+			break
+		}
+	}
+
+	criuMigrationArgs := CriuMigrationArgs{
+		cmd:          lxc.MIGRATE_DUMP,
+		stateDir:     stateDir,
+		function:     "snapshot",
+		stop:         stop,
+		actionScript: false,
+		dumpDir:      dumpDir,
+		preDumpDir:   preDumpDir,
+	}
+
+	return c.Migrate(&criuMigrationArgs)
+}
+
+// containerPreDumpIterations returns the maximum number of memory pre-dumps
+// to do before checkpointing a container, zero if incremental memory
+// transfer isn't enabled or CRIU can't track the dirty memory pages.
+func containerPreDumpIterations(c container) int {
+	if !shared.IsTrue(c.ExpandedConfig()["migration.incremental.memory"]) {
+		return 0
+	}
+
+	criuMigrationArgs := CriuMigrationArgs{
+		cmd:          lxc.MIGRATE_FEATURE_CHECK,
+		stateDir:     "",
+		function:     "feature-check",
+		stop:         false,
+		actionScript: false,
+		dumpDir:      "",
+		preDumpDir:   "",
+		features:     lxc.FEATURE_MEM_TRACK,
+	}
+
+	err := c.Migrate(&criuMigrationArgs)
+	if err != nil {
+		logger.Warn("CRIU can't pre-dump the container memory, doing a single dump", log.Ctx{"container": c.Name(), "project": c.Project()})
+		return 0
+	}
+
+	iterations := 10
+	value := c.ExpandedConfig()["migration.incremental.memory.iterations"]
+	if value != "" {
+		iterations, _ = strconv.Atoi(value)
+	}
+
+	// The pre-dump directories are named after their 3 digits index
+	if iterations > 999 {
+		iterations = 999
+	}
+
+	return iterations
+}
+
+// containerCheckpointDumpDir returns the directory of a state directory
+// holding the final CRIU dump to restore.
+func containerCheckpointDumpDir(stateDir string) string {
+	if shared.PathExists(filepath.Join(stateDir, containerCheckpointFinalDir)) {
+		return containerCheckpointFinalDir
+	}
+
+	return ""
+}
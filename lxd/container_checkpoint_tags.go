@@ -0,0 +1,346 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// A checkpoint tags the state of a container through one of its snapshots,
+// which holds the root filesystem as well as the configuration and which the
+// checkpoint references.
+var containerCheckpointTagsCmd = APIEndpoint{
+	Name: "containers/{name}/checkpoints",
+
+	Get:  APIEndpointAction{Handler: containerCheckpointTagsGet, AccessHandler: AllowProjectPermission("containers", "view")},
+	Post: APIEndpointAction{Handler: containerCheckpointTagsPost, AccessHandler: AllowProjectPermission("containers", "operate-containers")},
+}
+
+var containerCheckpointTagCmd = APIEndpoint{
+	Name: "containers/{name}/checkpoints/{checkpoint}",
+
+	Delete: APIEndpointAction{Handler: containerCheckpointTagDelete, AccessHandler: AllowProjectPermission("containers", "operate-containers")},
+	Post:   APIEndpointAction{Handler: containerCheckpointTagPost, AccessHandler: AllowProjectPermission("containers", "manage-containers")},
+}
+
+// containerCheckpointTagLoad returns a checkpoint of a container along with
+// the snapshot it references.
+func containerCheckpointTagLoad(d *Daemon, c container, name string) (container, db.ContainerCheckpoint, error) {
+	var checkpoint db.ContainerCheckpoint
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		checkpoint, err = tx.ContainerCheckpointGet(c.Id(), name)
+		return err
+	})
+	if err != nil {
+		return nil, checkpoint, err
+	}
+
+	sc, err := containerLoadByProjectAndName(d.State(), c.Project(), checkpoint.Snapshot)
+	if err != nil {
+		return nil, checkpoint, err
+	}
+
+	return sc, checkpoint, nil
+}
+
+// containerCheckpointTagCreate snapshots a container and records a checkpoint
+// referencing the snapshot, which is deleted again if the checkpoint can't be
+// recorded.
+func containerCheckpointTagCreate(d *Daemon, c container, name string, description string) error {
+	args := db.ContainerArgs{
+		Project:      c.Project(),
+		Architecture: c.Architecture(),
+		Config:       c.LocalConfig(),
+		Ctype:        db.CTypeSnapshot,
+		Devices:      c.LocalDevices(),
+		Ephemeral:    c.IsEphemeral(),
+		Name:         c.Name() + shared.SnapshotDelimiter + name,
+		Profiles:     c.Profiles(),
+	}
+
+	sc, err := containerCreateAsSnapshot(d.State(), args, c)
+	if err != nil {
+		return err
+	}
+
+	revert := true
+	defer func() {
+		if !revert {
+			return
+		}
+
+		err := sc.Delete()
+		if err != nil {
+			logger.Warn("Failed to delete checkpoint snapshot", log.Ctx{"container": c.Name(), "project": c.Project(), "snapshot": sc.Name(), "err": err})
+		}
+	}()
+
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		return tx.ContainerCheckpointAdd(c.Id(), db.ContainerCheckpoint{
+			Name:        name,
+			Date:        time.Now().UTC(),
+			Description: description,
+			SnapshotID:  sc.Id(),
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	revert = false
+	return nil
+}
+
+// containerCheckpointTagRestore stops a container, restores the snapshot of a
+// checkpoint, which brings back its configuration too, and starts it again if
+// it was running. A container which was running is started again when the
+// restore fails, rather than being left stopped.
+func containerCheckpointTagRestore(d *Daemon, c container, sc container, requester string, progress func(step string)) error {
+	wasRunning := c.IsRunning()
+	if wasRunning {
+		progress("Stopping container")
+		err := c.Stop(false)
+		if err != nil {
+			return err
+		}
+	}
+
+	revert := wasRunning
+	defer func() {
+		if !revert {
+			return
+		}
+
+		err := c.Start(false)
+		if err != nil {
+			logger.Warn("Failed to start container after failed checkpoint restore", log.Ctx{"container": c.Name(), "project": c.Project(), "err": err})
+		}
+	}()
+
+	progress("Restoring root filesystem and configuration")
+	previous := containerRevisionCurrent(c)
+	err := c.Restore(sc, false)
+	if err != nil {
+		return err
+	}
+
+	containerRevisionRecord(d, c, previous, requester)
+	revert = false
+
+	if wasRunning {
+		progress("Starting container")
+		err = c.Start(false)
+		if err != nil {
+			return err
+		}
+	}
+
+	progress("Done")
+	return nil
+}
+
+func containerCheckpointTagsGet(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	c, err := containerLoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	var checkpoints []db.ContainerCheckpoint
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		checkpoints, err = tx.ContainerCheckpoints(c.Id())
+		return err
+	})
+	if err != nil {
+		return SmartError(err)
+	}
+
+	result := []api.ContainerCheckpoint{}
+	for _, checkpoint := range checkpoints {
+		result = append(result, api.ContainerCheckpoint{
+			Name:        checkpoint.Name,
+			CreatedAt:   checkpoint.Date,
+			Description: checkpoint.Description,
+			Snapshot:    shared.ExtractSnapshotName(checkpoint.Snapshot),
+		})
+	}
+
+	return SyncResponse(true, result)
+}
+
+func containerCheckpointTagsPost(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	// Handle requests targeted to a container on a different node
+	response, err := ForwardedResponseIfContainerIsRemote(d, r, project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+	if response != nil {
+		return response
+	}
+
+	req := api.ContainerCheckpointsPost{}
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	if req.Name == "" || strings.Contains(req.Name, "/") {
+		return BadRequest(fmt.Errorf("Invalid checkpoint name %q", req.Name))
+	}
+
+	c, err := containerLoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	// Neither a snapshot nor another checkpoint may use the name
+	fullName := name + shared.SnapshotDelimiter + req.Name
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		_, err := tx.InstanceID(project, fullName)
+		if err == nil {
+			return fmt.Errorf("Snapshot %q already exists", req.Name)
+		}
+
+		if err != db.ErrNoSuchObject {
+			return err
+		}
+
+		_, err = tx.ContainerCheckpointGet(c.Id(), req.Name)
+		if err == nil {
+			return fmt.Errorf("Checkpoint %q already exists", req.Name)
+		}
+
+		if err != db.ErrNoSuchObject {
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return Conflict(err)
+	}
+
+	run := func(op *operation) error {
+		return containerCheckpointTagCreate(d, c, req.Name, req.Description)
+	}
+
+	resources := map[string][]string{}
+	resources["containers"] = []string{name}
+
+	op, err := operationCreate(d.cluster, project, operationClassTask, db.OperationCheckpointCreate, resources, nil, run, nil, nil)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	return OperationResponse(op)
+}
+
+// containerCheckpointTagPost restores a checkpoint in a single operation,
+// stopping the container, restoring its root filesystem and configuration
+// and starting it again if it was running.
+func containerCheckpointTagPost(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+	checkpointName := mux.Vars(r)["checkpoint"]
+
+	// Handle requests targeted to a container on a different node
+	response, err := ForwardedResponseIfContainerIsRemote(d, r, project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+	if response != nil {
+		return response
+	}
+
+	c, err := containerLoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	sc, _, err := containerCheckpointTagLoad(d, c, checkpointName)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	// Stopping an ephemeral container would delete it
+	if c.IsEphemeral() && c.IsRunning() {
+		return BadRequest(fmt.Errorf("Running ephemeral containers can't be restored to a checkpoint"))
+	}
+
+	requester := requestRequester(r)
+
+	run := func(op *operation) error {
+		progress := func(step string) {
+			op.UpdateMetadata(shared.Jmap{"checkpoint_progress": step})
+		}
+
+		return containerCheckpointTagRestore(d, c, sc, requester, progress)
+	}
+
+	resources := map[string][]string{}
+	resources["containers"] = []string{name}
+
+	op, err := operationCreate(d.cluster, project, operationClassTask, db.OperationCheckpointRestore, resources, nil, run, nil, nil)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	return OperationResponse(op)
+}
+
+func containerCheckpointTagDelete(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+	checkpointName := mux.Vars(r)["checkpoint"]
+
+	// Handle requests targeted to a container on a different node
+	response, err := ForwardedResponseIfContainerIsRemote(d, r, project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+	if response != nil {
+		return response
+	}
+
+	c, err := containerLoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	sc, _, err := containerCheckpointTagLoad(d, c, checkpointName)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	// The checkpoint goes away with its snapshot
+	run := func(op *operation) error {
+		return sc.Delete()
+	}
+
+	resources := map[string][]string{}
+	resources["containers"] = []string{name}
+
+	op, err := operationCreate(d.cluster, project, operationClassTask, db.OperationCheckpointDelete, resources, nil, run, nil, nil)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	return OperationResponse(op)
+}
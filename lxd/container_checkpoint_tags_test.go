@@ -0,0 +1,91 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/lxc/lxd/lxd/db"
+)
+
+type containerCheckpointTagsTestSuite struct {
+	lxdTestSuite
+}
+
+func (suite *containerCheckpointTagsTestSuite) createContainer(name string) container {
+	args := db.ContainerArgs{
+		Ctype: db.CTypeRegular,
+		Name:  name,
+	}
+
+	c, err := containerCreateInternal(suite.d.State(), args)
+	suite.Req.Nil(err)
+
+	// The backup file of the container is updated along with its snapshots
+	suite.Req.Nil(os.MkdirAll(c.Path(), 0711))
+
+	return c
+}
+
+func (suite *containerCheckpointTagsTestSuite) TestContainerCheckpointTagCreate() {
+	c := suite.createContainer("c1")
+	defer c.Delete()
+
+	err := containerCheckpointTagCreate(suite.d, c, "before-upgrade", "Known good")
+	suite.Req.Nil(err)
+
+	sc, checkpoint, err := containerCheckpointTagLoad(suite.d, c, "before-upgrade")
+	suite.Req.Nil(err)
+	suite.Equal("c1/before-upgrade", sc.Name())
+	suite.Equal(sc.Id(), checkpoint.SnapshotID)
+	suite.Equal("Known good", checkpoint.Description)
+
+	// The checkpoint goes away with its snapshot
+	suite.Req.Nil(sc.Delete())
+
+	_, _, err = containerCheckpointTagLoad(suite.d, c, "before-upgrade")
+	suite.Equal(db.ErrNoSuchObject, err)
+}
+
+func (suite *containerCheckpointTagsTestSuite) TestContainerCheckpointTagCreate_Rollback() {
+	c := suite.createContainer("c1")
+	defer c.Delete()
+
+	// Take the name of the checkpoint, but not of its snapshot
+	args := db.ContainerArgs{
+		Ctype: db.CTypeSnapshot,
+		Name:  "c1/other",
+	}
+
+	other, err := containerCreateAsSnapshot(suite.d.State(), args, c)
+	suite.Req.Nil(err)
+
+	err = suite.d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		return tx.ContainerCheckpointAdd(c.Id(), db.ContainerCheckpoint{
+			Name:       "before-upgrade",
+			Date:       time.Now().UTC(),
+			SnapshotID: other.Id(),
+		})
+	})
+	suite.Req.Nil(err)
+
+	err = containerCheckpointTagCreate(suite.d, c, "before-upgrade", "")
+	suite.Req.NotNil(err)
+
+	// The snapshot taken for the checkpoint was deleted again
+	err = suite.d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		_, err := tx.InstanceID("default", "c1/before-upgrade")
+		return err
+	})
+	suite.Equal(db.ErrNoSuchObject, err)
+
+	_, checkpoint, err := containerCheckpointTagLoad(suite.d, c, "before-upgrade")
+	suite.Req.Nil(err)
+	suite.Equal(other.Id(), checkpoint.SnapshotID)
+}
+
+func TestContainerCheckpointTagsTestSuite(t *testing.T) {
+	suite.Run(t, new(containerCheckpointTagsTestSuite))
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/version"
+)
+
+var containerConfigSnapshotsCmd = APIEndpoint{
+	Name: "containers/{name}/config-snapshots",
+
+	Get:  APIEndpointAction{Handler: containerConfigSnapshotsGet, AccessHandler: AllowProjectPermission("containers", "view")},
+	Post: APIEndpointAction{Handler: containerConfigSnapshotsPost, AccessHandler: AllowProjectPermission("containers", "manage-containers")},
+}
+
+var containerConfigSnapshotCmd = APIEndpoint{
+	Name: "containers/{name}/config-snapshots/{snapshot}",
+
+	Delete: APIEndpointAction{Handler: containerConfigSnapshotDelete, AccessHandler: AllowProjectPermission("containers", "manage-containers")},
+	Get:    APIEndpointAction{Handler: containerConfigSnapshotGet, AccessHandler: AllowProjectPermission("containers", "view")},
+	Post:   APIEndpointAction{Handler: containerConfigSnapshotPost, AccessHandler: AllowProjectPermission("containers", "manage-containers")},
+}
+
+// containerConfigSnapshotRender renders a configuration snapshot, which is a
+// container revision given a name and kept until deleted.
+func containerConfigSnapshotRender(revision db.ContainerRevision) api.ContainerConfigSnapshot {
+	return api.ContainerConfigSnapshot{
+		Name:        revision.Snapshot,
+		CreatedAt:   revision.Date,
+		Description: revision.SnapshotDescription,
+		Revision:    revision.Revision,
+		Config:      revision.Config,
+		Devices:     revision.Devices,
+		Profiles:    revision.Profiles,
+	}
+}
+
+func containerConfigSnapshotsGet(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	c, err := containerLoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	var snapshots []db.ContainerRevision
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		snapshots, err = tx.ContainerRevisionSnapshots(c.Id())
+		return err
+	})
+	if err != nil {
+		return SmartError(err)
+	}
+
+	result := []api.ContainerConfigSnapshot{}
+	for _, snapshot := range snapshots {
+		result = append(result, containerConfigSnapshotRender(snapshot))
+	}
+
+	return SyncResponse(true, result)
+}
+
+func containerConfigSnapshotsPost(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	req := api.ContainerConfigSnapshotsPost{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	if req.Name == "" || strings.Contains(req.Name, "/") {
+		return BadRequest(fmt.Errorf("Invalid configuration snapshot name %q", req.Name))
+	}
+
+	c, err := containerLoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	snapshot := containerRevisionCurrent(c)
+	snapshot.Requester = requestRequester(r)
+	snapshot.Snapshot = req.Name
+	snapshot.SnapshotDescription = req.Description
+
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		_, err := tx.ContainerRevisionSnapshotGet(c.Id(), req.Name)
+		if err == nil {
+			return db.ErrAlreadyDefined
+		}
+
+		if err != db.ErrNoSuchObject {
+			return err
+		}
+
+		_, err = tx.ContainerRevisionAdd(c.Id(), snapshot, containerRevisionsSize)
+		return err
+	})
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return SyncResponseLocation(true, nil, fmt.Sprintf("/%s/containers/%s/config-snapshots/%s", version.APIVersion, name, req.Name))
+}
+
+func containerConfigSnapshotGet(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+	snapshotName := mux.Vars(r)["snapshot"]
+
+	c, err := containerLoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	var snapshot db.ContainerRevision
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		snapshot, err = tx.ContainerRevisionSnapshotGet(c.Id(), snapshotName)
+		return err
+	})
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return SyncResponse(true, containerConfigSnapshotRender(snapshot))
+}
+
+func containerConfigSnapshotDelete(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+	snapshotName := mux.Vars(r)["snapshot"]
+
+	c, err := containerLoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		return tx.ContainerRevisionSnapshotDelete(c.Id(), snapshotName)
+	})
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return EmptySyncResponse
+}
+
+// containerConfigSnapshotPost restores a configuration snapshot, applying it
+// like any other update.
+func containerConfigSnapshotPost(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+	snapshotName := mux.Vars(r)["snapshot"]
+
+	// Handle requests targeted to a container on a different node
+	response, err := ForwardedResponseIfContainerIsRemote(d, r, project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+	if response != nil {
+		return response
+	}
+
+	c, err := containerLoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	var snapshot db.ContainerRevision
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		snapshot, err = tx.ContainerRevisionSnapshotGet(c.Id(), snapshotName)
+		return err
+	})
+	if err != nil {
+		return SmartError(err)
+	}
+
+	requester := requestRequester(r)
+	admin := d.userIsAdmin(r)
+
+	run := func(op *operation) error {
+		return containerRevisionApply(d, c, snapshot, requester, admin)
+	}
+
+	resources := map[string][]string{}
+	resources["containers"] = []string{name}
+
+	op, err := operationCreate(d.cluster, project, operationClassTask, db.OperationContainerUpdate, resources, nil, run, nil, nil)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	return OperationResponse(op)
+}
@@ -22,6 +22,8 @@ import (
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
 )
 
 type consoleWs struct {
@@ -54,6 +56,9 @@ type consoleWs struct {
 
 	// terminal height
 	height int
+
+	// who opened the console
+	identity shared.Jmap
 }
 
 func (s *consoleWs) Metadata() interface{} {
@@ -66,7 +71,7 @@ func (s *consoleWs) Metadata() interface{} {
 		}
 	}
 
-	return shared.Jmap{"fds": fds}
+	return shared.Jmap{"fds": fds, "identity": s.identity}
 }
 
 func (s *consoleWs) Connect(op *operation, r *http.Request, w http.ResponseWriter) error {
@@ -326,7 +331,11 @@ func containerConsolePost(d *Daemon, r *http.Request) Response {
 	ws.allConnected = make(chan bool, 1)
 	ws.controlConnected = make(chan bool, 1)
 
+	containerActivityRecord(c, db.ContainerActivityConsole)
+	auditLog(r, "container-console", log.Ctx{"container": name, "project": project})
+
 	ws.container = c
+	ws.identity = requestIdentity(r)
 	ws.width = post.Width
 	ws.height = post.Height
 
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Core scheduling prctl() operations and scopes, from linux/prctl.h.
+const (
+	prSchedCore                 = 62
+	prSchedCoreGet              = 0
+	prSchedCoreCreate           = 1
+	prSchedCoreShareFrom        = 3
+	prSchedCoreScopeThread      = 0
+	prSchedCoreScopeThreadGroup = 1
+)
+
+// coreSchedulingCreate assigns a new core scheduling cookie to all the
+// threads of the current process. The processes it forks inherit it, so that
+// they only ever share SMT siblings with each other.
+func coreSchedulingCreate() error {
+	return unix.Prctl(prSchedCore, prSchedCoreCreate, 0, prSchedCoreScopeThreadGroup, 0)
+}
+
+// coreSchedulingShareFrom copies the core scheduling cookie of pid to the
+// calling thread, which must be locked to its goroutine.
+func coreSchedulingShareFrom(pid int) error {
+	return unix.Prctl(prSchedCore, prSchedCoreShareFrom, uintptr(pid), prSchedCoreScopeThread, 0)
+}
+
+// coreSchedulingCookie returns the core scheduling cookie of pid, zero
+// meaning that it isn't isolated.
+func coreSchedulingCookie(pid int) (uint64, error) {
+	var cookie uint64
+
+	err := unix.Prctl(prSchedCore, prSchedCoreGet, uintptr(pid), prSchedCoreScopeThread, uintptr(unsafe.Pointer(&cookie)))
+	if err != nil {
+		return 0, err
+	}
+
+	return cookie, nil
+}
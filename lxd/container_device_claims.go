@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lxc/lxd/lxd/device"
+	"github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/lxd/state"
+)
+
+// containerDeviceClaim is a host resource used by a device of a container.
+//
+// The claims of kind "netdev" are host network interfaces moved into the
+// container, which nothing else may use. Those of kind "parent" are host
+// network interfaces on which the container's interface is created (VLAN or
+// SR-IOV virtual function), which can be shared but not moved into another
+// container. Those of kind "usb" are USB devices identified as
+// "vendorid:productid", an empty product matching all products of the vendor.
+type containerDeviceClaim struct {
+	kind   string
+	id     string
+	device string
+}
+
+// conflicts returns whether the two claims can't be held at the same time.
+func (claim containerDeviceClaim) conflicts(other containerDeviceClaim) bool {
+	switch claim.kind {
+	case "netdev":
+		return (other.kind == "netdev" || other.kind == "parent") && other.id == claim.id
+	case "parent":
+		return other.kind == "netdev" && other.id == claim.id
+	case "usb":
+		if other.kind != "usb" {
+			return false
+		}
+
+		fields := strings.SplitN(claim.id, ":", 2)
+		otherFields := strings.SplitN(other.id, ":", 2)
+		if fields[0] != otherFields[0] {
+			return false
+		}
+
+		return fields[1] == "" || otherFields[1] == "" || fields[1] == otherFields[1]
+	}
+
+	return false
+}
+
+// String describes the claimed resource.
+func (claim containerDeviceClaim) String() string {
+	if claim.kind == "usb" {
+		return fmt.Sprintf("USB device '%s'", claim.id)
+	}
+
+	return fmt.Sprintf("network interface '%s'", claim.id)
+}
+
+// containerDeviceClaims returns the host resources claimed by the given
+// devices. The volatile keys of a running container are used to include the
+// SR-IOV virtual functions it was given.
+func containerDeviceClaims(devices config.Devices, volatile map[string]string) []containerDeviceClaim {
+	claims := []containerDeviceClaim{}
+	for _, name := range devices.DeviceNames() {
+		m := devices[name]
+
+		switch m["type"] {
+		case "nic", "infiniband":
+			if m["parent"] == "" {
+				continue
+			}
+
+			switch m["nictype"] {
+			case "physical":
+				if m["vlan"] != "" {
+					claims = append(claims, containerDeviceClaim{kind: "parent", id: m["parent"], device: name})
+					claims = append(claims, containerDeviceClaim{kind: "netdev", id: fmt.Sprintf("%s.%s", m["parent"], m["vlan"]), device: name})
+					continue
+				}
+
+				claims = append(claims, containerDeviceClaim{kind: "netdev", id: m["parent"], device: name})
+			case "bonded":
+				for _, parent := range device.NICBondedParents(m["parent"]) {
+					claims = append(claims, containerDeviceClaim{kind: "netdev", id: parent, device: name})
+				}
+			case "sriov":
+				claims = append(claims, containerDeviceClaim{kind: "parent", id: m["parent"], device: name})
+
+				hostName := volatile[fmt.Sprintf("volatile.%s.host_name", name)]
+				if hostName != "" {
+					claims = append(claims, containerDeviceClaim{kind: "netdev", id: hostName, device: name})
+				}
+			}
+		case "usb":
+			if m["vendorid"] == "" {
+				continue
+			}
+
+			claims = append(claims, containerDeviceClaim{kind: "usb", id: fmt.Sprintf("%s:%s", m["vendorid"], m["productid"]), device: name})
+		}
+	}
+
+	return claims
+}
+
+// containerDeviceClaimsCheck returns an error naming the other container and
+// device if the given devices of a container being started claim a host
+// resource held by another running container of this node.
+func containerDeviceClaimsCheck(s *state.State, c container, devices config.Devices) error {
+	claims := containerDeviceClaims(devices, c.LocalConfig())
+	if len(claims) == 0 {
+		return nil
+	}
+
+	containers, err := containerLoadNodeAll(s)
+	if err != nil {
+		return err
+	}
+
+	for _, other := range containers {
+		if other.IsSnapshot() || other.Id() == c.Id() {
+			continue
+		}
+
+		// Stopped containers don't hold their devices
+		if !other.IsRunning() {
+			continue
+		}
+
+		otherName := fmt.Sprintf("container '%s'", other.Name())
+		if other.Project() != c.Project() {
+			otherName = fmt.Sprintf("container '%s' of project '%s'", other.Name(), other.Project())
+		}
+
+		for _, otherClaim := range containerDeviceClaims(other.ExpandedDevices(), other.LocalConfig()) {
+			for _, claim := range claims {
+				if claim.conflicts(otherClaim) {
+					return fmt.Errorf("Device '%s' conflicts with device '%s' of %s over %s", claim.device, otherClaim.device, otherName, claim)
+				}
+			}
+		}
+	}
+
+	return nil
+}
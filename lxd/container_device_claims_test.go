@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lxc/lxd/lxd/device/config"
+)
+
+func TestContainerDeviceClaims(t *testing.T) {
+	devices := config.Devices{
+		"eth0": {"type": "nic", "nictype": "bridged", "parent": "lxdbr0"},
+		"eth1": {"type": "nic", "nictype": "physical", "parent": "enp5s0"},
+		"eth2": {"type": "nic", "nictype": "sriov", "parent": "enp6s0"},
+		"eth3": {"type": "nic", "nictype": "physical", "parent": "enp7s0", "vlan": "10"},
+		"eth4": {"type": "nic", "nictype": "bonded", "parent": "enp8s0,enp9s0"},
+		"key":  {"type": "usb", "vendorid": "1050"},
+	}
+
+	claims := containerDeviceClaims(devices, map[string]string{"volatile.eth2.host_name": "enp6s0v1"})
+	assert.Equal(t, []containerDeviceClaim{
+		{kind: "netdev", id: "enp5s0", device: "eth1"},
+		{kind: "parent", id: "enp6s0", device: "eth2"},
+		{kind: "netdev", id: "enp6s0v1", device: "eth2"},
+		{kind: "parent", id: "enp7s0", device: "eth3"},
+		{kind: "netdev", id: "enp7s0.10", device: "eth3"},
+		{kind: "netdev", id: "enp8s0", device: "eth4"},
+		{kind: "netdev", id: "enp9s0", device: "eth4"},
+		{kind: "usb", id: "1050:", device: "key"},
+	}, claims)
+}
+
+func TestContainerDeviceClaimConflicts(t *testing.T) {
+	netdev := containerDeviceClaim{kind: "netdev", id: "enp5s0"}
+	parent := containerDeviceClaim{kind: "parent", id: "enp5s0"}
+	assert.True(t, netdev.conflicts(netdev))
+	assert.True(t, netdev.conflicts(parent))
+	assert.True(t, parent.conflicts(netdev))
+	assert.False(t, parent.conflicts(parent))
+	assert.False(t, netdev.conflicts(containerDeviceClaim{kind: "netdev", id: "enp6s0"}))
+
+	vendor := containerDeviceClaim{kind: "usb", id: "1050:"}
+	product := containerDeviceClaim{kind: "usb", id: "1050:0407"}
+	assert.True(t, vendor.conflicts(product))
+	assert.True(t, product.conflicts(vendor))
+	assert.False(t, product.conflicts(containerDeviceClaim{kind: "usb", id: "1050:0120"}))
+	assert.False(t, product.conflicts(containerDeviceClaim{kind: "usb", id: "046d:0407"}))
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/shared/api"
+)
+
+var containerDevicesLogCmd = APIEndpoint{
+	Name: "containers/{name}/devices/log",
+
+	Get: APIEndpointAction{Handler: containerDevicesLogGet, AccessHandler: AllowProjectPermission("containers", "view")},
+}
+
+// Number of device events kept for each container.
+const containerDevicesLogSize = 100
+
+// The device history is only kept in memory, indexed by container ID.
+var containerDevicesLogLock sync.Mutex
+var containerDevicesLog = map[int][]api.ContainerDeviceLogEntry{}
+
+// containerDevicesLogAdd records a device change, dropping the oldest entry
+// once the history is full.
+func containerDevicesLogAdd(c container, name string, m config.Device, action string, source string, err error) {
+	entry := api.ContainerDeviceLogEntry{
+		Timestamp: time.Now().UTC(),
+		Device:    name,
+		Type:      m["type"],
+		Action:    action,
+		Source:    source,
+	}
+
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	containerDevicesLogLock.Lock()
+	defer containerDevicesLogLock.Unlock()
+
+	entries := append(containerDevicesLog[c.Id()], entry)
+	if len(entries) > containerDevicesLogSize {
+		entries = entries[len(entries)-containerDevicesLogSize:]
+	}
+
+	containerDevicesLog[c.Id()] = entries
+}
+
+// containerDevicesLogRemove forgets the history of a deleted container.
+func containerDevicesLogRemove(c container) {
+	containerDevicesLogLock.Lock()
+	delete(containerDevicesLog, c.Id())
+	containerDevicesLogLock.Unlock()
+}
+
+func containerDevicesLogGet(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	// Handle requests targeted to a container on a different node
+	response, err := ForwardedResponseIfContainerIsRemote(d, r, project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+	if response != nil {
+		return response
+	}
+
+	c, err := containerLoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	containerDevicesLogLock.Lock()
+	entries := make([]api.ContainerDeviceLogEntry, len(containerDevicesLog[c.Id()]))
+	copy(entries, containerDevicesLog[c.Id()])
+	containerDevicesLogLock.Unlock()
+
+	return SyncResponse(true, entries)
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// containerDiskUsage is the usage of a filesystem mounted in a container, as
+// reported by statfs from inside its mount namespace.
+type containerDiskUsage struct {
+	total       int64
+	used        int64
+	available   int64
+	inodesTotal int64
+	inodesUsed  int64
+}
+
+// percent returns the highest of the space and inodes usage ratios, counting
+// the space reserved to root as used since it's not available to most
+// applications.
+func (u *containerDiskUsage) percent() int64 {
+	percent := int64(0)
+	if u.total > 0 {
+		percent = (u.total - u.available) * 100 / u.total
+	}
+
+	if u.inodesTotal > 0 {
+		inodes := u.inodesUsed * 100 / u.inodesTotal
+		if inodes > percent {
+			percent = inodes
+		}
+	}
+
+	return percent
+}
+
+// containerDiskStatfs returns the usage of the filesystems mounted at the
+// given paths inside a running container. Paths which couldn't be looked up
+// are missing from the result.
+func containerDiskStatfs(c container, paths []string) (map[string]*containerDiskUsage, error) {
+	if !c.IsRunning() {
+		return nil, fmt.Errorf("The container isn't running")
+	}
+
+	args := []string{"forkfile", "statfs", c.RootfsPath(), fmt.Sprintf("%d", c.InitPID())}
+	args = append(args, paths...)
+
+	stdout, err := shared.RunCommand(c.DaemonState().OS.ExecPath, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := strings.Split(strings.TrimRight(stdout, "\n"), "\n")
+	if len(lines) != len(paths) {
+		return nil, fmt.Errorf("Unexpected statfs output: %q", stdout)
+	}
+
+	usage := map[string]*containerDiskUsage{}
+	for i, line := range lines {
+		if strings.HasPrefix(line, "error: ") {
+			logger.Debug("Failed to statfs container path", log.Ctx{"container": c.Name(), "path": paths[i], "err": strings.TrimPrefix(line, "error: ")})
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 5 {
+			return nil, fmt.Errorf("Unexpected statfs output: %q", line)
+		}
+
+		values := make([]int64, len(fields))
+		for j, field := range fields {
+			values[j], err = strconv.ParseInt(field, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		usage[paths[i]] = &containerDiskUsage{
+			total:       values[0],
+			used:        values[0] - values[1],
+			available:   values[2],
+			inodesTotal: values[3],
+			inodesUsed:  values[3] - values[4],
+		}
+	}
+
+	return usage, nil
+}
+
+// containerDiskUsageWarningParse parses the usage.warning property of a disk
+// device, a percentage of the space or inodes in use.
+func containerDiskUsageWarningParse(value string) (int64, error) {
+	if !strings.HasSuffix(value, "%") {
+		return -1, fmt.Errorf("Invalid disk usage warning %q, must be a percentage", value)
+	}
+
+	percent, err := strconv.ParseInt(strings.TrimSuffix(value, "%"), 10, 64)
+	if err != nil || percent < 1 || percent > 100 {
+		return -1, fmt.Errorf("Invalid disk usage warning %q, must be between 1%% and 100%%", value)
+	}
+
+	return percent, nil
+}
+
+// Whether each disk device was above its warning threshold on the last check,
+// indexed by container ID and then device name.
+var containerDiskWarningsLock sync.Mutex
+var containerDiskWarnings = map[int]map[string]bool{}
+
+func containerDiskUsageTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		containers, err := containerLoadNodeAll(d.State())
+		if err != nil {
+			logger.Error("Failed to load containers for disk usage check", log.Ctx{"err": err})
+			return
+		}
+
+		seen := map[int]bool{}
+		for _, c := range containers {
+			select {
+			case <-ctx.Done():
+				return
+			default:
+			}
+
+			if c.IsSnapshot() || !c.IsRunning() {
+				continue
+			}
+
+			seen[c.Id()] = true
+			containerDiskUsageCheck(c)
+		}
+
+		// Forget about the containers which are gone or stopped
+		containerDiskWarningsLock.Lock()
+		for id := range containerDiskWarnings {
+			if !seen[id] {
+				delete(containerDiskWarnings, id)
+			}
+		}
+		containerDiskWarningsLock.Unlock()
+	}
+
+	return f, task.Every(time.Minute)
+}
+
+// containerDiskUsageCheck compares the usage of the container's disks with
+// their warning threshold, emitting an event when it's crossed either way.
+func containerDiskUsageCheck(c container) {
+	devices := c.ExpandedDevices()
+
+	thresholds := map[string]int64{}
+	paths := []string{}
+	for _, name := range devices.DeviceNames() {
+		m := devices[name]
+		if m["type"] != "disk" || m["usage.warning"] == "" {
+			continue
+		}
+
+		threshold, err := containerDiskUsageWarningParse(m["usage.warning"])
+		if err != nil {
+			continue
+		}
+
+		thresholds[name] = threshold
+		paths = append(paths, m["path"])
+	}
+
+	if len(paths) == 0 {
+		return
+	}
+
+	usage, err := containerDiskStatfs(c, paths)
+	if err != nil {
+		logger.Debug("Failed to check container disk usage", log.Ctx{"container": c.Name(), "project": c.Project(), "err": err})
+		return
+	}
+
+	for name, threshold := range thresholds {
+		path := devices[name]["path"]
+
+		u, ok := usage[path]
+		if !ok {
+			continue
+		}
+
+		warning := u.percent() >= threshold
+
+		containerDiskWarningsLock.Lock()
+		if containerDiskWarnings[c.Id()] == nil {
+			containerDiskWarnings[c.Id()] = map[string]bool{}
+		}
+		previous := containerDiskWarnings[c.Id()][name]
+		containerDiskWarnings[c.Id()][name] = warning
+		containerDiskWarningsLock.Unlock()
+
+		if warning == previous {
+			continue
+		}
+
+		ctx := map[string]interface{}{
+			"device":       name,
+			"path":         path,
+			"usage":        u.used,
+			"total":        u.total,
+			"available":    u.available,
+			"inodes_usage": u.inodesUsed,
+			"inodes_total": u.inodesTotal,
+			"threshold":    threshold,
+		}
+
+		action := "container-disk-usage-normal"
+		if warning {
+			action = "container-disk-usage-warning"
+			logger.Warn("Container disk usage above threshold", log.Ctx{"container": c.Name(), "project": c.Project(), "device": name, "path": path, "percent": u.percent(), "threshold": threshold})
+		} else {
+			logger.Info("Container disk usage back below threshold", log.Ctx{"container": c.Name(), "project": c.Project(), "device": name, "path": path, "percent": u.percent(), "threshold": threshold})
+		}
+
+		eventSendLifecycle(c.Project(), action, fmt.Sprintf("/1.0/containers/%s", c.Name()), ctx)
+	}
+}
@@ -15,6 +15,7 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
 	"golang.org/x/sys/unix"
 
 	"github.com/lxc/lxd/lxd/cluster"
@@ -46,6 +47,7 @@ type execWs struct {
 	uid              uint32
 	gid              uint32
 	cwd              string
+	identity         shared.Jmap
 }
 
 func (s *execWs) Metadata() interface{} {
@@ -63,6 +65,7 @@ func (s *execWs) Metadata() interface{} {
 		"command":     s.command,
 		"environment": s.env,
 		"interactive": s.interactive,
+		"identity":    s.identity,
 	}
 }
 
@@ -148,6 +151,16 @@ func (s *execWs) Do(op *operation) error {
 		stderr = ttys[2]
 	}
 
+	record, err := execRecordStart(s.container, op.id, s.command, s.env, s.uid, s.gid, s.cwd, s.interactive, s.identity, true)
+	if err != nil {
+		for i := range ttys {
+			ttys[i].Close()
+			ptys[i].Close()
+		}
+
+		return errors.Wrap(err, "Failed to record exec session")
+	}
+
 	controlExit := make(chan bool)
 	attachedChildIsBorn := make(chan int)
 	attachedChildIsDead := make(chan bool, 1)
@@ -243,7 +256,7 @@ func (s *execWs) Do(op *operation) error {
 			s.connsLock.Unlock()
 
 			logger.Debugf("Starting to mirror websocket")
-			readDone, writeDone := netutils.WebsocketExecMirror(conn, ptys[0], ptys[0], attachedChildIsDead, int(ptys[0].Fd()))
+			readDone, writeDone := netutils.WebsocketExecMirror(conn, ptys[0], record.reader(ptys[0]), attachedChildIsDead, int(ptys[0].Fd()))
 
 			<-readDone
 			<-writeDone
@@ -269,7 +282,7 @@ func (s *execWs) Do(op *operation) error {
 					conn := s.conns[i]
 					s.connsLock.Unlock()
 
-					<-shared.WebsocketSendStream(conn, ptys[i], -1)
+					<-shared.WebsocketSendStream(conn, record.reader(ptys[i]), -1)
 					ptys[i].Close()
 					wgEOF.Done()
 				}
@@ -277,6 +290,8 @@ func (s *execWs) Do(op *operation) error {
 		}
 	}
 
+	var session *execSession
+
 	finisher := func(cmdResult int, cmdErr error) error {
 		for _, tty := range ttys {
 			tty.Close()
@@ -302,7 +317,13 @@ func (s *execWs) Do(op *operation) error {
 			pty.Close()
 		}
 
-		metadata := shared.Jmap{"return": cmdResult}
+		metadata := shared.Jmap{"return": cmdResult, "identity": s.identity}
+		if session != nil {
+			metadata["usage"] = session.stop()
+		}
+
+		record.stop(cmdResult)
+
 		err = op.UpdateMetadata(metadata)
 		if err != nil {
 			return err
@@ -313,9 +334,14 @@ func (s *execWs) Do(op *operation) error {
 
 	cmd, _, attachedPid, err := s.container.Exec(s.command, s.env, stdin, stdout, stderr, false, s.cwd, s.uid, s.gid)
 	if err != nil {
+		record.stop(-1)
 		return err
 	}
 
+	session = execSessionStart(s.container, op.id, s.command, attachedPid)
+	metadata, _ := s.Metadata().(shared.Jmap)
+	session.watch(op, metadata)
+
 	if s.interactive {
 		attachedChildIsBorn <- attachedPid
 	}
@@ -329,12 +355,12 @@ func (s *execWs) Do(op *operation) error {
 	if ok {
 		status, ok := exitErr.Sys().(syscall.WaitStatus)
 		if ok {
-			return finisher(status.ExitStatus(), nil)
-		}
+			if status.Signaled() {
+				// 128 + n == Fatal error signal "n"
+				return finisher(128+int(status.Signal()), nil)
+			}
 
-		if status.Signaled() {
-			// 128 + n == Fatal error signal "n"
-			return finisher(128+int(status.Signal()), nil)
+			return finisher(status.ExitStatus(), nil)
 		}
 	}
 
@@ -386,49 +412,20 @@ func containerExecPost(d *Daemon, r *http.Request) Response {
 		return BadRequest(fmt.Errorf("Container is frozen"))
 	}
 
-	env := map[string]string{}
-
-	for k, v := range c.ExpandedConfig() {
-		if strings.HasPrefix(k, "environment.") {
-			env[strings.TrimPrefix(k, "environment.")] = v
-		}
-	}
-
-	if post.Environment != nil {
-		for k, v := range post.Environment {
-			env[k] = v
-		}
+	ok, limit, err := execSessionReserve(d, c)
+	if err != nil {
+		return SmartError(err)
 	}
 
-	// Set default value for PATH
-	_, ok := env["PATH"]
 	if !ok {
-		env["PATH"] = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
-		if c.FileExists("/snap") == nil {
-			env["PATH"] = fmt.Sprintf("%s:/snap/bin", env["PATH"])
-		}
+		return TooManyRequests(fmt.Errorf("Container reached its limit of %d concurrent exec sessions", limit))
 	}
 
-	// If running as root, set some env variables
-	if post.User == 0 {
-		// Set default value for HOME
-		_, ok = env["HOME"]
-		if !ok {
-			env["HOME"] = "/root"
-		}
-
-		// Set default value for USER
-		_, ok = env["USER"]
-		if !ok {
-			env["USER"] = "root"
-		}
-	}
+	containerActivityRecord(c, db.ContainerActivityExec)
+	auditLog(r, "container-exec", log.Ctx{"container": name, "project": project, "command": post.Command})
+	identity := requestIdentity(r)
 
-	// Set default value for LANG
-	_, ok = env["LANG"]
-	if !ok {
-		env["LANG"] = "C.UTF-8"
-	}
+	env := execEnvironment(c, post.Environment, post.User)
 
 	if post.WaitForWS {
 		ws := &execWs{}
@@ -436,6 +433,7 @@ func containerExecPost(d *Daemon, r *http.Request) Response {
 
 		idmapset, err := c.CurrentIdmap()
 		if err != nil {
+			execSessionRelease(c)
 			return InternalError(err)
 		}
 
@@ -456,6 +454,7 @@ func containerExecPost(d *Daemon, r *http.Request) Response {
 		for i := -1; i < len(ws.conns)-1; i++ {
 			ws.fds[i], err = shared.RandomCryptoString()
 			if err != nil {
+				execSessionRelease(c)
 				return InternalError(err)
 			}
 		}
@@ -470,12 +469,19 @@ func containerExecPost(d *Daemon, r *http.Request) Response {
 		ws.cwd = post.Cwd
 		ws.uid = post.User
 		ws.gid = post.Group
+		ws.identity = identity
 
 		resources := map[string][]string{}
 		resources["containers"] = []string{ws.container.Name()}
 
-		op, err := operationCreate(d.cluster, project, operationClassWebsocket, db.OperationCommandExec, resources, ws.Metadata(), ws.Do, nil, ws.Connect)
+		run := func(op *operation) error {
+			defer execSessionRelease(c)
+			return ws.Do(op)
+		}
+
+		op, err := operationCreate(d.cluster, project, operationClassWebsocket, db.OperationCommandExec, resources, ws.Metadata(), run, nil, ws.Connect)
 		if err != nil {
+			execSessionRelease(c)
 			return InternalError(err)
 		}
 
@@ -483,9 +489,36 @@ func containerExecPost(d *Daemon, r *http.Request) Response {
 	}
 
 	run := func(op *operation) error {
+		defer execSessionRelease(c)
+
 		var cmdErr error
 		var cmdResult int
-		metadata := shared.Jmap{}
+		metadata := shared.Jmap{"identity": identity}
+
+		// Run the command in its own accounting session
+		execRun := func(stdout *os.File, stderr *os.File) (int, error) {
+			record, err := execRecordStart(c, op.id, post.Command, env, post.User, post.Group, post.Cwd, false, identity, false)
+			if err != nil {
+				return -1, errors.Wrap(err, "Failed to record exec session")
+			}
+
+			cmd, _, attachedPid, err := c.Exec(post.Command, env, nil, stdout, stderr, false, post.Cwd, post.User, post.Group)
+			if err != nil {
+				record.stop(-1)
+				return -1, err
+			}
+
+			session := execSessionStart(c, op.id, post.Command, attachedPid)
+			session.watch(op, nil)
+			defer func() {
+				metadata["usage"] = session.stop()
+			}()
+
+			cmdResult, err := execWait(cmd)
+			record.stop(cmdResult)
+
+			return cmdResult, err
+		}
 
 		if post.RecordOutput {
 			// Prepare stdout and stderr recording
@@ -502,7 +535,7 @@ func containerExecPost(d *Daemon, r *http.Request) Response {
 			defer stderr.Close()
 
 			// Run the command
-			_, cmdResult, _, cmdErr = c.Exec(post.Command, env, nil, stdout, stderr, true, post.Cwd, post.User, post.Group)
+			cmdResult, cmdErr = execRun(stdout, stderr)
 
 			// Update metadata with the right URLs
 			metadata["return"] = cmdResult
@@ -511,7 +544,7 @@ func containerExecPost(d *Daemon, r *http.Request) Response {
 				"2": fmt.Sprintf("/%s/containers/%s/logs/%s", version.APIVersion, c.Name(), filepath.Base(stderr.Name())),
 			}
 		} else {
-			_, cmdResult, _, cmdErr = c.Exec(post.Command, env, nil, nil, nil, true, post.Cwd, post.User, post.Group)
+			cmdResult, cmdErr = execRun(nil, nil)
 			metadata["return"] = cmdResult
 		}
 
@@ -528,8 +561,80 @@ func containerExecPost(d *Daemon, r *http.Request) Response {
 
 	op, err := operationCreate(d.cluster, project, operationClassTask, db.OperationCommandExec, resources, nil, run, nil, nil)
 	if err != nil {
+		execSessionRelease(c)
 		return InternalError(err)
 	}
 
 	return OperationResponse(op)
 }
+
+// execEnvironment returns the environment of a command run in the container,
+// made of its environment.* keys, the extra variables and sensible defaults.
+func execEnvironment(c container, extra map[string]string, uid uint32) map[string]string {
+	env := map[string]string{}
+
+	for k, v := range c.ExpandedConfig() {
+		if strings.HasPrefix(k, "environment.") {
+			env[strings.TrimPrefix(k, "environment.")] = v
+		}
+	}
+
+	for k, v := range extra {
+		env[k] = v
+	}
+
+	// Set default value for PATH
+	_, ok := env["PATH"]
+	if !ok {
+		env["PATH"] = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+		if c.FileExists("/snap") == nil {
+			env["PATH"] = fmt.Sprintf("%s:/snap/bin", env["PATH"])
+		}
+	}
+
+	// If running as root, set some env variables
+	if uid == 0 {
+		// Set default value for HOME
+		_, ok = env["HOME"]
+		if !ok {
+			env["HOME"] = "/root"
+		}
+
+		// Set default value for USER
+		_, ok = env["USER"]
+		if !ok {
+			env["USER"] = "root"
+		}
+	}
+
+	// Set default value for LANG
+	_, ok = env["LANG"]
+	if !ok {
+		env["LANG"] = "C.UTF-8"
+	}
+
+	return env
+}
+
+// execWait waits for a command started by Exec and returns its exit code.
+func execWait(cmd *exec.Cmd) (int, error) {
+	err := cmd.Wait()
+	if err != nil {
+		exitErr, ok := err.(*exec.ExitError)
+		if ok {
+			status, ok := exitErr.Sys().(syscall.WaitStatus)
+			if ok {
+				if status.Signaled() {
+					// 128 + n == Fatal error signal "n"
+					return 128 + int(status.Signal()), nil
+				}
+
+				return status.ExitStatus(), nil
+			}
+		}
+
+		return -1, err
+	}
+
+	return 0, nil
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// The environment variables kept in the record of an exec session, the
+// others may hold secrets.
+var execRecordEnvironment = []string{"HOME", "LANG", "PATH", "PWD", "SHELL", "TERM", "USER"}
+
+// The maximum size of the transcript of an exec session, the output past it
+// not being recorded.
+var execRecordTranscriptMaxSize int64 = 64 * 1024 * 1024
+
+// execRecordEntry is the content of the record of an exec session, written
+// to exec_<operation>.record in the container's log directory when the
+// session starts and again when it ends.
+type execRecordEntry struct {
+	ID          string            `json:"id"`
+	Command     []string          `json:"command"`
+	Environment map[string]string `json:"environment"`
+	User        uint32            `json:"user"`
+	Group       uint32            `json:"group"`
+	Cwd         string            `json:"cwd"`
+	Interactive bool              `json:"interactive"`
+	Identity    shared.Jmap       `json:"identity"`
+	StartedAt   time.Time         `json:"started_at"`
+	StoppedAt   *time.Time        `json:"stopped_at,omitempty"`
+	Return      *int              `json:"return,omitempty"`
+	Transcript  string            `json:"transcript,omitempty"`
+	Truncated   bool              `json:"truncated,omitempty"`
+}
+
+// execRecord records an exec session of a container with
+// security.exec_record enabled, along with a ttyrec transcript of its output
+// if security.exec_record.transcript is enabled too.
+type execRecord struct {
+	path  string
+	entry execRecordEntry
+
+	transcript     *os.File
+	transcriptSize int64
+	transcriptLock sync.Mutex
+}
+
+// execRecordStart starts recording an exec session, returning nil if the
+// container doesn't record them. Only the sessions whose output is streamed
+// through LXD can have a transcript.
+func execRecordStart(c container, id string, command []string, env map[string]string, uid uint32, gid uint32, cwd string, interactive bool, identity shared.Jmap, streamed bool) (*execRecord, error) {
+	config := c.ExpandedConfig()
+	if !shared.IsTrue(config["security.exec_record"]) {
+		return nil, nil
+	}
+
+	r := &execRecord{
+		path: filepath.Join(c.LogPath(), fmt.Sprintf("exec_%s.record", id)),
+		entry: execRecordEntry{
+			ID:          id,
+			Command:     command,
+			Environment: map[string]string{},
+			User:        uid,
+			Group:       gid,
+			Cwd:         cwd,
+			Interactive: interactive,
+			Identity:    identity,
+			StartedAt:   time.Now().UTC(),
+		},
+	}
+
+	for _, key := range execRecordEnvironment {
+		value, ok := env[key]
+		if ok {
+			r.entry.Environment[key] = value
+		}
+	}
+
+	if streamed && shared.IsTrue(config["security.exec_record.transcript"]) {
+		name := fmt.Sprintf("exec_%s.ttyrec", id)
+		transcript, err := os.OpenFile(filepath.Join(c.LogPath(), name), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			return nil, err
+		}
+
+		r.transcript = transcript
+		r.entry.Transcript = name
+	}
+
+	err := r.write()
+	if err != nil {
+		if r.transcript != nil {
+			r.transcript.Close()
+		}
+
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// write saves the record of the session.
+func (r *execRecord) write() error {
+	content, err := json.MarshalIndent(r.entry, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(r.path, append(content, '\n'), 0600)
+}
+
+// stop records the end of the session and its exit code.
+func (r *execRecord) stop(cmdResult int) {
+	if r == nil {
+		return
+	}
+
+	r.transcriptLock.Lock()
+	if r.transcript != nil {
+		r.transcript.Close()
+		r.transcript = nil
+	}
+	r.transcriptLock.Unlock()
+
+	stoppedAt := time.Now().UTC()
+	r.entry.StoppedAt = &stoppedAt
+	r.entry.Return = &cmdResult
+
+	err := r.write()
+	if err != nil {
+		logger.Error("Failed to record the end of exec session", log.Ctx{"path": r.path, "err": err})
+	}
+}
+
+// writeFrame appends some output of the session to its transcript, as a
+// ttyrec frame made of the time in seconds and microseconds and the length
+// of the data, all little-endian 32-bit integers, followed by the data.
+func (r *execRecord) writeFrame(buf []byte) {
+	r.transcriptLock.Lock()
+	defer r.transcriptLock.Unlock()
+
+	if r.transcript == nil || len(buf) == 0 {
+		return
+	}
+
+	// Stop at the maximum size, recording that the transcript is partial
+	if r.transcriptSize+int64(len(buf))+12 > execRecordTranscriptMaxSize {
+		r.transcript.Close()
+		r.transcript = nil
+		r.entry.Truncated = true
+		return
+	}
+
+	r.transcriptSize += int64(len(buf)) + 12
+
+	err := execRecordFrame(r.transcript, time.Now(), buf)
+	if err != nil {
+		logger.Error("Failed to write exec session transcript", log.Ctx{"path": r.path, "err": err})
+		r.transcript.Close()
+		r.transcript = nil
+	}
+}
+
+func execRecordFrame(w io.Writer, now time.Time, buf []byte) error {
+	header := make([]byte, 12)
+	binary.LittleEndian.PutUint32(header[0:4], uint32(now.Unix()))
+	binary.LittleEndian.PutUint32(header[4:8], uint32(now.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(header[8:12], uint32(len(buf)))
+
+	_, err := w.Write(append(header, buf...))
+	return err
+}
+
+// reader returns a reader of the output of the session, copying it to the
+// transcript if there is one.
+func (r *execRecord) reader(output io.ReadCloser) io.ReadCloser {
+	if r == nil || r.transcript == nil {
+		return output
+	}
+
+	return &execRecordReader{ReadCloser: output, record: r}
+}
+
+type execRecordReader struct {
+	io.ReadCloser
+	record *execRecord
+}
+
+func (er *execRecordReader) Read(p []byte) (int, error) {
+	n, err := er.ReadCloser.Read(p)
+	if n > 0 {
+		er.record.writeFrame(p[:n])
+	}
+
+	return n, err
+}
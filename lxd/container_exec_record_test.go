@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecRecordFrame(t *testing.T) {
+	buf := &bytes.Buffer{}
+	now := time.Unix(1500000000, 123456789)
+
+	err := execRecordFrame(buf, now, []byte("hello"))
+	assert.NoError(t, err)
+
+	expected := []byte{0x00, 0x2f, 0x68, 0x59, 0x40, 0xe2, 0x01, 0x00, 0x05, 0x00, 0x00, 0x00}
+	expected = append(expected, []byte("hello")...)
+	assert.Equal(t, expected, buf.Bytes())
+}
+
+func TestExecRecordTranscriptMaxSize(t *testing.T) {
+	defer func(size int64) { execRecordTranscriptMaxSize = size }(execRecordTranscriptMaxSize)
+	execRecordTranscriptMaxSize = 40
+
+	f, err := ioutil.TempFile("", "lxd_exec_record_")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+
+	r := &execRecord{transcript: f}
+	r.writeFrame([]byte("hello"))
+	r.writeFrame([]byte("world"))
+	assert.False(t, r.entry.Truncated)
+
+	// The third frame goes past the maximum size
+	r.writeFrame([]byte("again"))
+	assert.True(t, r.entry.Truncated)
+	assert.Nil(t, r.transcript)
+
+	content, err := ioutil.ReadFile(f.Name())
+	require.NoError(t, err)
+	assert.Len(t, content, 34)
+}
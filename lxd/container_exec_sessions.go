@@ -0,0 +1,346 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+var containerExecSessionsCmd = APIEndpoint{
+	Name: "containers/{name}/exec/sessions",
+
+	Get: APIEndpointAction{Handler: containerExecSessionsGet, AccessHandler: AllowProjectPermission("containers", "view")},
+}
+
+// Prefix of the per-session sub-cgroups created below the container's own.
+const execSessionCgroupPrefix = "lxd-exec-"
+
+// Controllers used to account the resources of an exec session.
+var execSessionControllers = []string{"memory", "cpuacct"}
+
+// How often the usage recorded in the operation metadata is refreshed.
+var execSessionRefreshInterval = 5 * time.Second
+
+// The running sessions, indexed by container ID and then operation ID.
+var execSessionsLock sync.Mutex
+var execSessions = map[int]map[string]*execSession{}
+
+// The number of exec requests being handled, from their creation until the
+// command exits, indexed by container ID.
+var execSessionsActive = map[int]int{}
+
+type execSession struct {
+	id        string
+	container container
+	command   []string
+	pid       int
+	createdAt time.Time
+
+	// Sub-cgroup directory of each accounting controller.
+	cgroups map[string]string
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// execSessionStart moves the attached process into its own sub-cgroup so that
+// the resources used by it and its children can be accounted separately.
+// Failing to do so isn't fatal, the session is then reported without usage.
+func execSessionStart(c container, id string, command []string, pid int) *execSession {
+	s := &execSession{
+		id:        id,
+		container: c,
+		command:   command,
+		pid:       pid,
+		createdAt: time.Now().UTC(),
+		cgroups:   map[string]string{},
+		done:      make(chan struct{}),
+	}
+
+	paths, err := execSessionCgroupPaths(pid)
+	if err != nil {
+		logger.Warn("Failed to lookup the cgroups of exec session", log.Ctx{"container": c.Name(), "pid": pid, "err": err})
+	}
+
+	for _, controller := range execSessionControllers {
+		parent, ok := paths[controller]
+		if !ok {
+			continue
+		}
+
+		dir := filepath.Join(parent, execSessionCgroupPrefix+id)
+		err := os.Mkdir(dir, 0755)
+		if err != nil && !os.IsExist(err) {
+			logger.Warn("Failed to create exec session cgroup", log.Ctx{"container": c.Name(), "path": dir, "err": err})
+			continue
+		}
+
+		err = ioutil.WriteFile(filepath.Join(dir, "cgroup.procs"), []byte(fmt.Sprintf("%d", pid)), 0644)
+		if err != nil {
+			logger.Warn("Failed to move process into exec session cgroup", log.Ctx{"container": c.Name(), "path": dir, "err": err})
+			os.Remove(dir)
+			continue
+		}
+
+		s.cgroups[controller] = dir
+	}
+
+	execSessionsLock.Lock()
+	if execSessions[c.Id()] == nil {
+		execSessions[c.Id()] = map[string]*execSession{}
+	}
+	execSessions[c.Id()][id] = s
+	execSessionsLock.Unlock()
+
+	return s
+}
+
+// execSessionReserve accounts for a new exec request, failing if the
+// container already reached its limit of concurrent sessions. The limit comes
+// from limits.exec.sessions, or else from core.exec_sessions_limit.
+func execSessionReserve(d *Daemon, c container) (bool, int, error) {
+	var limit int64
+	value := c.ExpandedConfig()["limits.exec.sessions"]
+	if value != "" {
+		var err error
+		limit, err = strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return false, 0, err
+		}
+	} else {
+		err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+			config, err := cluster.ConfigLoad(tx)
+			if err != nil {
+				return err
+			}
+
+			limit = config.ExecSessionsLimit()
+			return nil
+		})
+		if err != nil {
+			return false, 0, err
+		}
+	}
+
+	execSessionsLock.Lock()
+	defer execSessionsLock.Unlock()
+
+	if limit > 0 && int64(execSessionsActive[c.Id()]) >= limit {
+		return false, int(limit), nil
+	}
+
+	execSessionsActive[c.Id()]++
+	return true, int(limit), nil
+}
+
+// execSessionRelease accounts for the end of an exec request.
+func execSessionRelease(c container) {
+	execSessionsLock.Lock()
+	defer execSessionsLock.Unlock()
+
+	execSessionsActive[c.Id()]--
+	if execSessionsActive[c.Id()] <= 0 {
+		delete(execSessionsActive, c.Id())
+	}
+}
+
+// execSessionCount returns the number of exec requests being handled for the
+// container.
+func execSessionCount(c container) int {
+	execSessionsLock.Lock()
+	defer execSessionsLock.Unlock()
+
+	return execSessionsActive[c.Id()]
+}
+
+// execSessionCgroupPaths returns the cgroup directory of the given process for
+// each of its cgroup v1 controllers.
+func execSessionCgroupPaths(pid int) (map[string]string, error) {
+	f, err := os.Open(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	paths := map[string]string{}
+
+	scan := bufio.NewScanner(f)
+	for scan.Scan() {
+		fields := strings.SplitN(scan.Text(), ":", 3)
+		if len(fields) != 3 || fields[1] == "" {
+			continue
+		}
+
+		for _, controller := range strings.Split(fields[1], ",") {
+			paths[controller] = filepath.Join("/sys/fs/cgroup", fields[1], fields[2])
+		}
+	}
+
+	return paths, scan.Err()
+}
+
+// watch periodically publishes the session usage in the operation metadata,
+// alongside the provided static metadata.
+func (s *execSession) watch(op *operation, metadata shared.Jmap) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(execSessionRefreshInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+			}
+
+			current := shared.Jmap{}
+			for k, v := range metadata {
+				current[k] = v
+			}
+			current["usage"] = s.usage()
+
+			err := op.UpdateMetadata(current)
+			if err != nil {
+				logger.Debug("Failed to update exec session usage", log.Ctx{"operation": op.id, "err": err})
+			}
+		}
+	}()
+}
+
+// usage reads the current resource consumption of the session.
+func (s *execSession) usage() api.ContainerExecSessionUsage {
+	usage := api.ContainerExecSessionUsage{
+		MemoryUsage:     -1,
+		MemoryUsagePeak: -1,
+		CPUUsage:        -1,
+	}
+
+	dir, ok := s.cgroups["memory"]
+	if ok {
+		value, err := execSessionCgroupRead(dir, "memory.usage_in_bytes")
+		if err == nil {
+			usage.MemoryUsage = value
+		}
+
+		value, err = execSessionCgroupRead(dir, "memory.max_usage_in_bytes")
+		if err == nil {
+			usage.MemoryUsagePeak = value
+		}
+	}
+
+	dir, ok = s.cgroups["cpuacct"]
+	if ok {
+		value, err := execSessionCgroupRead(dir, "cpuacct.usage")
+		if err == nil {
+			usage.CPUUsage = value
+		}
+	}
+
+	return usage
+}
+
+func execSessionCgroupRead(dir string, key string) (int64, error) {
+	content, err := ioutil.ReadFile(filepath.Join(dir, key))
+	if err != nil {
+		return -1, err
+	}
+
+	return strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64)
+}
+
+// stop unregisters the session and removes its sub-cgroups, returning the
+// final usage. Processes left behind by the session are moved back into the
+// container's own cgroup.
+func (s *execSession) stop() api.ContainerExecSessionUsage {
+	close(s.done)
+	s.wg.Wait()
+
+	usage := s.usage()
+
+	execSessionsLock.Lock()
+	delete(execSessions[s.container.Id()], s.id)
+	if len(execSessions[s.container.Id()]) == 0 {
+		delete(execSessions, s.container.Id())
+	}
+	execSessionsLock.Unlock()
+
+	for _, dir := range s.cgroups {
+		content, err := ioutil.ReadFile(filepath.Join(dir, "cgroup.procs"))
+		if err == nil {
+			for _, pid := range strings.Fields(string(content)) {
+				ioutil.WriteFile(filepath.Join(filepath.Dir(dir), "cgroup.procs"), []byte(pid), 0644)
+			}
+		}
+
+		err = os.Remove(dir)
+		if err != nil {
+			logger.Debug("Failed to remove exec session cgroup", log.Ctx{"path": dir, "err": err})
+		}
+	}
+
+	return usage
+}
+
+func containerExecSessionsGet(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	// Handle requests targeted to a container on a different node
+	response, err := ForwardedResponseIfContainerIsRemote(d, r, project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+	if response != nil {
+		return response
+	}
+
+	c, err := containerLoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	execSessionsLock.Lock()
+	running := []*execSession{}
+	for _, s := range execSessions[c.Id()] {
+		running = append(running, s)
+	}
+	execSessionsLock.Unlock()
+
+	sort.Slice(running, func(i, j int) bool {
+		return running[i].createdAt.Before(running[j].createdAt)
+	})
+
+	sessions := []api.ContainerExecSession{}
+	for _, s := range running {
+		sessions = append(sessions, api.ContainerExecSession{
+			ID:        s.id,
+			Command:   s.command,
+			Pid:       s.pid,
+			CreatedAt: s.createdAt,
+			Usage:     s.usage(),
+		})
+	}
+
+	return SyncResponse(true, sessions)
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/gorilla/mux"
 
+	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/shared"
 )
 
@@ -35,6 +36,8 @@ func containerFileHandler(d *Daemon, r *http.Request) Response {
 		return BadRequest(fmt.Errorf("missing path argument"))
 	}
 
+	containerActivityRecord(c, db.ContainerActivityFile)
+
 	switch r.Method {
 	case "GET":
 		return containerFileGet(c, path, r)
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+)
+
+var containerFilesSyncCmd = APIEndpoint{
+	Name: "containers/{name}/files/sync",
+
+	Post: APIEndpointAction{Handler: containerFilesSyncPost, AccessHandler: AllowProjectPermission("containers", "operate-containers")},
+}
+
+func containerFilesSyncPost(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	// Handle requests targeted to a container on a different node
+	response, err := ForwardedResponseIfContainerIsRemote(d, r, project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+	if response != nil {
+		return response
+	}
+
+	req := api.ContainerFilesSyncPost{}
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	if req.Source == "" || req.SourcePath == "" || req.Path == "" {
+		return BadRequest(fmt.Errorf("The source, source path and path are required"))
+	}
+
+	// Both containers must be on this node
+	client, err := cluster.ConnectIfContainerIsRemote(d.cluster, project, req.Source, d.endpoints.NetworkCert())
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if client != nil {
+		return BadRequest(fmt.Errorf("The source container must be on the same node as the target"))
+	}
+
+	source, err := containerLoadByProjectAndName(d.State(), project, req.Source)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	target, err := containerLoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	srcPath := filepath.Clean(req.SourcePath)
+	dstPath := filepath.Clean(req.Path)
+	if source.Id() == target.Id() && (dstPath == srcPath || strings.HasPrefix(dstPath, srcPath+"/")) {
+		return BadRequest(fmt.Errorf("Can't copy a path into itself"))
+	}
+
+	run := func(op *operation) error {
+		return containerFilesSync(op, source, target, srcPath, dstPath)
+	}
+
+	resources := map[string][]string{}
+	resources["containers"] = []string{name, req.Source}
+
+	op, err := operationCreate(d.cluster, project, operationClassTask, db.OperationContainerFilesSync, resources, nil, run, nil, nil)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	return OperationResponse(op)
+}
+
+// containerFilesSync copies a path tree between two containers through
+// forkfile. The ownership is read and set from within each container, so it's
+// translated between their idmaps.
+func containerFilesSync(op *operation, source container, target container, srcPath string, dstPath string) error {
+	// Keep the storage of stopped containers around for the whole copy
+	for _, c := range []container{source, target} {
+		if c.IsRunning() {
+			continue
+		}
+
+		ourStart, err := c.StorageStart()
+		if err != nil {
+			return err
+		}
+
+		if ourStart {
+			defer c.StorageStop()
+		}
+	}
+
+	count := 0
+	err := containerFilesSyncPath(source, target, srcPath, dstPath, func() {
+		count++
+		if count%100 == 0 {
+			op.UpdateMetadata(shared.Jmap{"entries": count})
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	return op.UpdateMetadata(shared.Jmap{"entries": count})
+}
+
+func containerFilesSyncPath(source container, target container, srcPath string, dstPath string, progress func()) error {
+	temp, err := ioutil.TempFile("", "lxd_forksyncfile_")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	defer temp.Close()
+
+	uid, gid, mode, type_, dirEnts, err := source.FilePull(srcPath, temp.Name())
+	if err != nil {
+		return fmt.Errorf("Failed to read %s: %v", srcPath, err)
+	}
+
+	switch type_ {
+	case "file":
+		err = target.FilePush("file", temp.Name(), dstPath, uid, gid, int(mode), "overwrite")
+	case "symlink":
+		var link []byte
+		link, err = ioutil.ReadAll(temp)
+		if err == nil {
+			err = target.FilePush("symlink", strings.TrimSuffix(string(link), "\n"), dstPath, uid, gid, int(mode), "overwrite")
+		}
+	case "directory":
+		err = target.FilePush("directory", "", dstPath, uid, gid, int(mode), "overwrite")
+	default:
+		err = fmt.Errorf("Bad file type %s", type_)
+	}
+
+	if err != nil {
+		return fmt.Errorf("Failed to write %s: %v", dstPath, err)
+	}
+
+	progress()
+
+	for _, ent := range dirEnts {
+		err := containerFilesSyncPath(source, target, filepath.Join(srcPath, ent), filepath.Join(dstPath, ent), progress)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
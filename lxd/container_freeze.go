@@ -0,0 +1,114 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// The timers unfreezing the containers which were frozen with a timeout,
+// indexed by container ID.
+var containerFreezeTimersLock sync.Mutex
+var containerFreezeTimers = map[int]*time.Timer{}
+
+// containerFreezeTimeoutSet records when a frozen container must be unfrozen
+// and arms the timer doing it. The deadline is kept in the container's
+// volatile config so the timer survives a daemon restart.
+func containerFreezeTimeoutSet(c container, timeout time.Duration) error {
+	deadline := time.Now().UTC().Add(timeout)
+
+	err := c.VolatileSet(map[string]string{"volatile.last_state.unfreeze": deadline.Format(time.RFC3339Nano)})
+	if err != nil {
+		return err
+	}
+
+	containerFreezeTimerStart(c, deadline)
+
+	return nil
+}
+
+// containerFreezeTimeoutClear disarms the timer of a container and forgets
+// its deadline, for when it got frozen again or unfrozen.
+func containerFreezeTimeoutClear(c container) {
+	containerFreezeTimersLock.Lock()
+	timer, ok := containerFreezeTimers[c.Id()]
+	if ok {
+		timer.Stop()
+		delete(containerFreezeTimers, c.Id())
+	}
+	containerFreezeTimersLock.Unlock()
+
+	if c.LocalConfig()["volatile.last_state.unfreeze"] == "" {
+		return
+	}
+
+	err := c.VolatileSet(map[string]string{"volatile.last_state.unfreeze": ""})
+	if err != nil {
+		logger.Warn("Failed to clear container unfreeze time", log.Ctx{"container": c.Name(), "project": c.Project(), "err": err})
+	}
+}
+
+// containerFreezeTimeoutRestore arms the timer of a frozen container again
+// after a daemon restart, unfreezing it right away if its deadline passed.
+func containerFreezeTimeoutRestore(c container) {
+	value := c.LocalConfig()["volatile.last_state.unfreeze"]
+	if value == "" || !c.IsFrozen() {
+		return
+	}
+
+	deadline, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		logger.Warn("Invalid container unfreeze time", log.Ctx{"container": c.Name(), "project": c.Project(), "err": err})
+		return
+	}
+
+	containerFreezeTimerStart(c, deadline)
+}
+
+// containerFreezeTimerStart arms the timer unfreezing a container at the
+// given time, replacing any previous one.
+func containerFreezeTimerStart(c container, deadline time.Time) {
+	containerFreezeTimersLock.Lock()
+	defer containerFreezeTimersLock.Unlock()
+
+	previous, ok := containerFreezeTimers[c.Id()]
+	if ok {
+		previous.Stop()
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(time.Until(deadline), func() {
+		// Only act if the timer wasn't replaced or disarmed meanwhile
+		containerFreezeTimersLock.Lock()
+		current := containerFreezeTimers[c.Id()] == timer
+		if current {
+			delete(containerFreezeTimers, c.Id())
+		}
+		containerFreezeTimersLock.Unlock()
+
+		if current {
+			containerFreezeExpired(c)
+		}
+	})
+
+	containerFreezeTimers[c.Id()] = timer
+}
+
+// containerFreezeExpired unfreezes a container whose freeze timeout expired.
+func containerFreezeExpired(c container) {
+	// Use a fresh copy of the container, its state may have changed
+	c, err := containerLoadByProjectAndName(c.DaemonState(), c.Project(), c.Name())
+	if err != nil || !c.IsFrozen() {
+		return
+	}
+
+	logger.Warn("Container freeze timeout expired", log.Ctx{"container": c.Name(), "project": c.Project()})
+
+	err = c.Unfreeze()
+	if err != nil {
+		logger.Error("Failed to unfreeze container", log.Ctx{"container": c.Name(), "project": c.Project(), "err": err})
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// How long a user hook may run before being killed.
+var containerHookTimeout = 5 * time.Minute
+
+// How often a running hook extends the timeout of the container operation.
+var containerHookKeepAliveInterval = 10 * time.Second
+
+// containerHookRun runs the host script set for the given lifecycle hook
+// through the hooks.<hook> configuration key of a container, if any. Its
+// output is appended to the hooks.log file of the container.
+func containerHookRun(c container, hook string) error {
+	path := c.ExpandedConfig()[fmt.Sprintf("hooks.%s", hook)]
+	if path == "" {
+		return nil
+	}
+
+	err := os.MkdirAll(c.LogPath(), 0700)
+	if err != nil {
+		return err
+	}
+
+	logFile, err := os.OpenFile(filepath.Join(c.LogPath(), "hooks.log"), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	fmt.Fprintf(logFile, "%s: running %s hook %s\n", time.Now().UTC().Format(time.RFC3339), hook, path)
+
+	ctx, cancel := context.WithTimeout(context.Background(), containerHookTimeout)
+	defer cancel()
+
+	cmd, err := hostScriptCommand(ctx, c, path,
+		fmt.Sprintf("LXD_HOOK=%s", hook),
+		fmt.Sprintf("LXD_CONTAINER_PID=%d", c.InitPID()))
+	if err != nil {
+		return err
+	}
+
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+
+	stop := hostScriptKeepAlive(c)
+	err = cmd.Run()
+	stop()
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("Timed out after %s", containerHookTimeout)
+	}
+
+	if err != nil {
+		fmt.Fprintf(logFile, "%s: %s hook %s failed: %v\n", time.Now().UTC().Format(time.RFC3339), hook, path, err)
+		return fmt.Errorf("Failed to run %s hook %q: %v", hook, path, err)
+	}
+
+	return nil
+}
+
+// containerHookLog runs a lifecycle hook whose failure doesn't affect the
+// transition, only logging it.
+func containerHookLog(c container, hook string) {
+	err := containerHookRun(c, hook)
+	if err != nil {
+		logger.Error("Failed to run container hook", log.Ctx{"container": c.Name(), "project": c.Project(), "hook": hook, "err": err})
+	}
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/units"
+)
+
+// The huge page sizes which can be used, in bytes. They're named like the
+// hugetlb cgroup controller files.
+var containerHugepagesSizes = map[string]int64{
+	"2MB": 2 * 1024 * 1024,
+	"1GB": 1024 * 1024 * 1024,
+}
+
+// containerHugepagesDiskSize returns the page size of a hugepages backed disk
+// device, along with its size in bytes.
+func containerHugepagesDiskSize(m config.Device) (string, int64, error) {
+	pageSize := m["hugepages.size"]
+	if pageSize == "" {
+		pageSize = "2MB"
+	}
+
+	pageBytes, ok := containerHugepagesSizes[pageSize]
+	if !ok {
+		return "", -1, fmt.Errorf("Invalid huge page size %q, must be 2MB or 1GB", pageSize)
+	}
+
+	if m["size"] == "" {
+		return "", -1, fmt.Errorf("Hugepages backed disks must have a size")
+	}
+
+	size, err := units.ParseByteSizeString(m["size"])
+	if err != nil {
+		return "", -1, err
+	}
+
+	if size <= 0 || size%pageBytes != 0 {
+		return "", -1, fmt.Errorf("The size of hugepages backed disks must be a multiple of %s", pageSize)
+	}
+
+	return pageSize, size, nil
+}
+
+// containerHugepagesValidDisk validates the configuration of a hugepages
+// backed disk device.
+func containerHugepagesValidDisk(m config.Device) error {
+	if m["backing"] != "hugepages" {
+		return fmt.Errorf("Invalid disk backing %q", m["backing"])
+	}
+
+	if m["source"] != "" || m["pool"] != "" {
+		return fmt.Errorf("Hugepages backed disks can't have a source or pool")
+	}
+
+	if m["path"] == "/" {
+		return fmt.Errorf("The root disk can't be hugepages backed")
+	}
+
+	_, _, err := containerHugepagesDiskSize(m)
+	if err != nil {
+		return err
+	}
+
+	if m["numa"] != "" {
+		_, err := strconv.ParseUint(m["numa"], 10, 32)
+		if err != nil {
+			return fmt.Errorf("Invalid NUMA node %q", m["numa"])
+		}
+	}
+
+	return nil
+}
+
+// containerHugepagesCheckLimits checks that the hugepages backed disks of a
+// container fit within its huge page limits.
+func containerHugepagesCheckLimits(config map[string]string, devices config.Devices) error {
+	total := map[string]int64{}
+	for _, m := range devices {
+		if m["type"] != "disk" || m["backing"] != "hugepages" {
+			continue
+		}
+
+		pageSize, size, err := containerHugepagesDiskSize(m)
+		if err != nil {
+			return err
+		}
+
+		total[pageSize] += size
+	}
+
+	for pageSize, size := range total {
+		key := fmt.Sprintf("limits.hugepages.%s", pageSize)
+		if config[key] == "" {
+			continue
+		}
+
+		limit, err := units.ParseByteSizeString(config[key])
+		if err != nil {
+			return err
+		}
+
+		if size > limit {
+			return fmt.Errorf("The hugepages backed disks use %s of %s huge pages, more than %s=%s", units.GetByteSizeString(size, 0), pageSize, key, config[key])
+		}
+	}
+
+	return nil
+}
+
+// containerHugepagesLimit returns the hugetlb cgroup limit of a container for
+// the given page size, that's limits.hugepages.<size> when set and otherwise
+// the total size of its hugepages backed disks, so that the pages they
+// reserve are accounted for. It's -1 when the usage isn't limited.
+func containerHugepagesLimit(config map[string]string, devices config.Devices, pageSize string) (int64, error) {
+	limit := config[fmt.Sprintf("limits.hugepages.%s", pageSize)]
+	if limit != "" {
+		return units.ParseByteSizeString(limit)
+	}
+
+	total := int64(0)
+	for _, m := range devices {
+		if m["type"] != "disk" || m["backing"] != "hugepages" {
+			continue
+		}
+
+		diskPageSize, size, err := containerHugepagesDiskSize(m)
+		if err != nil {
+			return -1, err
+		}
+
+		if diskPageSize == pageSize {
+			total += size
+		}
+	}
+
+	if total == 0 {
+		return -1, nil
+	}
+
+	return total, nil
+}
+
+// containerHugepagesHasDisks returns whether a container has hugepages backed
+// disks.
+func containerHugepagesHasDisks(devices config.Devices) bool {
+	for _, m := range devices {
+		if m["type"] == "disk" && m["backing"] == "hugepages" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// containerHugepagesFree returns the number of bytes of free huge pages of the
+// given size, on a NUMA node or on the whole system when the node is empty.
+func containerHugepagesFree(node string, pageSize string) (int64, error) {
+	pageBytes := containerHugepagesSizes[pageSize]
+	path := fmt.Sprintf("/sys/kernel/mm/hugepages/hugepages-%dkB/free_hugepages", pageBytes/1024)
+	if node != "" {
+		path = fmt.Sprintf("/sys/devices/system/node/node%s/hugepages/hugepages-%dkB/free_hugepages", node, pageBytes/1024)
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		if node != "" {
+			return -1, fmt.Errorf("No %s huge pages on NUMA node %s", pageSize, node)
+		}
+
+		return -1, fmt.Errorf("No %s huge pages on this system", pageSize)
+	}
+
+	free, err := strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64)
+	if err != nil {
+		return -1, err
+	}
+
+	return free * pageBytes, nil
+}
+
+// mountHugepagesDisk mounts a hugetlbfs instance owned by the container's root
+// user on the host side path of a hugepages backed disk device. The mount is
+// done by forkhugetlbfs from the hugetlb cgroup of the container's init, so
+// that the pages reserved at mount time are charged to the container.
+//
+// The numa option only checks that the node has enough free huge pages, the
+// pages being allocated from the nodes the faulting task may use. Workloads
+// pick the node of their huge pages themselves, through the mempolicy of the
+// mapping, and the memory of the container isn't restricted to that node.
+func (c *containerLXC) mountHugepagesDisk(devPath string, m config.Device, pid int) error {
+	pageSize, size, err := containerHugepagesDiskSize(m)
+	if err != nil {
+		return err
+	}
+
+	free, err := containerHugepagesFree(m["numa"], pageSize)
+	if err != nil {
+		return err
+	}
+
+	if free < size {
+		if m["numa"] != "" {
+			return fmt.Errorf("Not enough free %s huge pages on NUMA node %s (%s available)", pageSize, m["numa"], units.GetByteSizeString(free, 0))
+		}
+
+		return fmt.Errorf("Not enough free %s huge pages (%s available)", pageSize, units.GetByteSizeString(free, 0))
+	}
+
+	// Make the container's root user the owner of the filesystem
+	uid := int64(0)
+	gid := int64(0)
+	if !c.IsPrivileged() {
+		idmapset, err := c.CurrentIdmap()
+		if err != nil {
+			return err
+		}
+
+		if idmapset != nil {
+			uid, gid = idmapset.ShiftFromNs(0, 0)
+		}
+	}
+
+	options := fmt.Sprintf("pagesize=%d,size=%d,min_size=%d,uid=%d,gid=%d,mode=0770", containerHugepagesSizes[pageSize], size, size, uid, gid)
+	_, err = shared.RunCommand(c.state.OS.ExecPath, "forkhugetlbfs", fmt.Sprintf("%d", pid), devPath, options)
+	if err != nil {
+		return fmt.Errorf("Failed to mount hugetlbfs on %s: %v", devPath, err)
+	}
+
+	return nil
+}
+
+// shareHugepagesDisk turns the host side path of a hugepages backed disk
+// device into a shared mount, for the hugetlbfs mounted on it once the
+// container's init exists to propagate into the container.
+func shareHugepagesDisk(devPath string) error {
+	err := unix.Mount(devPath, devPath, "", unix.MS_BIND, "")
+	if err != nil {
+		return fmt.Errorf("Failed to bind mount %s: %v", devPath, err)
+	}
+
+	err = unix.Mount("", devPath, "", unix.MS_SHARED, "")
+	if err != nil {
+		unix.Unmount(devPath, unix.MNT_DETACH)
+		return fmt.Errorf("Failed to make %s a shared mount: %v", devPath, err)
+	}
+
+	return nil
+}
+
+// mountHugepagesDisks mounts the hugepages backed disks of a starting
+// container, from the start-host hook once its init is in its cgroups.
+func (c *containerLXC) mountHugepagesDisks(pid int) error {
+	for _, name := range c.expandedDevices.DeviceNames() {
+		m := c.expandedDevices[name]
+		if m["type"] != "disk" || m["backing"] != "hugepages" {
+			continue
+		}
+
+		err := c.mountHugepagesDisk(c.diskDevicePath(name, m), m, pid)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setHugepagesLimits applies the huge page limits of a running container.
+func (c *containerLXC) setHugepagesLimits() error {
+	if !c.state.OS.CGroupHugetlbController {
+		return nil
+	}
+
+	for pageSize := range containerHugepagesSizes {
+		limit, err := containerHugepagesLimit(c.expandedConfig, c.expandedDevices, pageSize)
+		if err != nil {
+			return err
+		}
+
+		err = c.CGroupSet(fmt.Sprintf("hugetlb.%s.limit_in_bytes", pageSize), fmt.Sprintf("%d", limit))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
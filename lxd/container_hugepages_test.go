@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/lxd/device/config"
+)
+
+func TestContainerHugepagesLimit(t *testing.T) {
+	devices := config.Devices{
+		"pages0": config.Device{"type": "disk", "path": "/mnt/pages0", "backing": "hugepages", "size": "64MiB"},
+		"pages1": config.Device{"type": "disk", "path": "/mnt/pages1", "backing": "hugepages", "size": "32MiB", "hugepages.size": "2MB"},
+		"pages2": config.Device{"type": "disk", "path": "/mnt/pages2", "backing": "hugepages", "size": "1GiB", "hugepages.size": "1GB"},
+		"root":   config.Device{"type": "disk", "path": "/", "pool": "default"},
+	}
+
+	// The disks are accounted for without limits
+	limit, err := containerHugepagesLimit(map[string]string{}, devices, "2MB")
+	require.NoError(t, err)
+	assert.Equal(t, int64(96*1024*1024), limit)
+
+	limit, err = containerHugepagesLimit(map[string]string{}, devices, "1GB")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1024*1024*1024), limit)
+
+	// The configured limits win
+	limit, err = containerHugepagesLimit(map[string]string{"limits.hugepages.2MB": "1GiB"}, devices, "2MB")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1024*1024*1024), limit)
+
+	// No disks and no limits
+	limit, err = containerHugepagesLimit(map[string]string{}, config.Devices{}, "2MB")
+	require.NoError(t, err)
+	assert.Equal(t, int64(-1), limit)
+}
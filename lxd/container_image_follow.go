@@ -0,0 +1,333 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/version"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// Prefix of the snapshots taken before rebuilding a container, used to roll
+// it back if it fails to boot.
+const containerImageFollowSnapshotPrefix = "image-follow-"
+
+// How long a rebuilt container must keep running to be considered healthy.
+var containerImageFollowBootDelay = 30 * time.Second
+
+func containerImageFollowTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		// Load all local containers
+		allContainers, err := containerLoadNodeAll(d.State())
+		if err != nil {
+			logger.Error("Failed to load containers for image following", log.Ctx{"err": err})
+			return
+		}
+
+		// Figure out which follow an image
+		containers := []container{}
+		for _, c := range allContainers {
+			if c.IsSnapshot() || c.ExpandedConfig()["image.follow"] == "" {
+				continue
+			}
+
+			containers = append(containers, c)
+		}
+
+		if len(containers) == 0 {
+			return
+		}
+
+		opRun := func(op *operation) error {
+			return containerImageFollowCheck(ctx, d, op, containers)
+		}
+
+		op, err := operationCreate(d.cluster, "", operationClassTask, db.OperationContainersImageFollow, nil, nil, opRun, nil, nil)
+		if err != nil {
+			logger.Error("Failed to start image following operation", log.Ctx{"err": err})
+			return
+		}
+
+		logger.Debug("Checking followed images")
+		_, err = op.Run()
+		if err != nil {
+			logger.Error("Failed to check followed images", log.Ctx{"err": err})
+		}
+		logger.Debug("Done checking followed images")
+	}
+
+	return f, task.Every(15 * time.Minute)
+}
+
+func containerImageFollowCheck(ctx context.Context, d *Daemon, op *operation, containers []container) error {
+	// Resolve each followed alias only once
+	fingerprints := map[string]string{}
+
+	for _, c := range containers {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+
+		config := c.ExpandedConfig()
+
+		alias, server, err := shared.ParseImageFollow(config["image.follow"])
+		if err != nil {
+			logger.Warn("Invalid image to follow", log.Ctx{"container": c.Name(), "project": c.Project(), "err": err})
+			continue
+		}
+
+		fingerprint, ok := fingerprints[config["image.follow"]]
+		if !ok {
+			fingerprint, err = containerImageFollowResolve(d, server, alias)
+			if err != nil {
+				logger.Warn("Failed to resolve followed image", log.Ctx{"alias": alias, "server": server, "err": err})
+				continue
+			}
+
+			fingerprints[config["image.follow"]] = fingerprint
+		}
+
+		if fingerprint == config["volatile.base_image"] {
+			// Up to date, clear any previous notification
+			if config["volatile.image.follow.available"] != "" {
+				c.VolatileSet(map[string]string{"volatile.image.follow.available": ""})
+			}
+
+			continue
+		}
+
+		// Notify once for each new image
+		if config["volatile.image.follow.available"] != fingerprint {
+			logger.Warn("New version of the followed image available", log.Ctx{"container": c.Name(), "project": c.Project(), "image": config["image.follow"], "fingerprint": fingerprint})
+
+			err := c.VolatileSet(map[string]string{"volatile.image.follow.available": fingerprint})
+			if err != nil {
+				logger.Error("Failed to record the available image", log.Ctx{"container": c.Name(), "project": c.Project(), "err": err})
+			}
+		}
+
+		if config["image.follow.mode"] != "rebuild" {
+			continue
+		}
+
+		// Don't retry an image which already failed to boot
+		if config["volatile.image.follow.failed"] == fingerprint {
+			continue
+		}
+
+		if config["image.follow.window"] != "" {
+			window, err := shared.ParseMaintenanceWindow(config["image.follow.window"])
+			if err != nil || !window.Contains(time.Now()) {
+				continue
+			}
+		}
+
+		allowed, err := containerImageFollowAllowed(d, c.Project())
+		if err != nil {
+			logger.Error("Failed to check the project configuration", log.Ctx{"project": c.Project(), "err": err})
+			continue
+		}
+
+		if !allowed {
+			logger.Debug("Automatic rebuilds aren't enabled for the project", log.Ctx{"container": c.Name(), "project": c.Project()})
+			continue
+		}
+
+		err = containerImageFollowRebuild(d, op, c, server, alias)
+		if err != nil {
+			logger.Error("Failed to rebuild container from the followed image", log.Ctx{"container": c.Name(), "project": c.Project(), "fingerprint": fingerprint, "err": err})
+			continue
+		}
+
+		logger.Info("Rebuilt container from the followed image", log.Ctx{"container": c.Name(), "project": c.Project(), "fingerprint": fingerprint})
+	}
+
+	return nil
+}
+
+// containerImageFollowResolve returns the fingerprint the alias currently
+// points to on the given simplestreams server.
+func containerImageFollowResolve(d *Daemon, server string, alias string) (string, error) {
+	remote, err := lxd.ConnectSimpleStreams(server, &lxd.ConnectionArgs{
+		UserAgent: version.UserAgent,
+		Proxy:     d.proxy,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	entry, _, err := remote.GetImageAlias(alias)
+	if err != nil {
+		return "", err
+	}
+
+	return entry.Target, nil
+}
+
+// containerImageFollowAllowed returns whether the project opted in for
+// automatic rebuilds.
+func containerImageFollowAllowed(d *Daemon, projectName string) (bool, error) {
+	allowed := false
+
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		project, err := tx.ProjectGet(projectName)
+		if err != nil {
+			return err
+		}
+
+		allowed = shared.IsTrue(project.Config["images.auto_rebuild"])
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return allowed, nil
+}
+
+// containerImageFollowRebuild replaces the root filesystem of the container
+// with the latest version of the followed image, keeping its configuration.
+// A snapshot is taken first and restored if the container fails to boot.
+func containerImageFollowRebuild(d *Daemon, op *operation, c container, server string, alias string) error {
+	s := d.State()
+
+	// Download the new image
+	info, err := d.ImageDownload(op, server, "simplestreams", "", "", alias, true, false, "", true, c.Project())
+	if err != nil {
+		return errors.Wrap(err, "Download image")
+	}
+
+	// Stop the container
+	wasRunning := c.IsRunning()
+	if wasRunning {
+		err = c.Shutdown(time.Duration(30) * time.Second)
+		if err != nil {
+			err = c.Stop(false)
+			if err != nil {
+				return errors.Wrap(err, "Stop container")
+			}
+		}
+	}
+
+	// Snapshot the current state
+	snapshotName := fmt.Sprintf("%s%s%s%s", c.Name(), shared.SnapshotDelimiter, containerImageFollowSnapshotPrefix, time.Now().UTC().Format("20060102-150405"))
+	args := db.ContainerArgs{
+		Architecture: c.Architecture(),
+		Config:       c.LocalConfig(),
+		Ctype:        db.CTypeSnapshot,
+		Devices:      c.LocalDevices(),
+		Ephemeral:    c.IsEphemeral(),
+		Name:         snapshotName,
+		Profiles:     c.Profiles(),
+		Project:      c.Project(),
+	}
+
+	snapshot, err := containerCreateAsSnapshot(s, args, c)
+	if err != nil {
+		if wasRunning {
+			c.Start(false)
+		}
+
+		return errors.Wrap(err, "Snapshot container")
+	}
+
+	err = containerImageFollowReplaceRootfs(d, c, info.Fingerprint)
+	if err == nil && wasRunning {
+		err = c.Start(false)
+		if err == nil {
+			time.Sleep(containerImageFollowBootDelay)
+			if !c.IsRunning() {
+				err = fmt.Errorf("Container stopped after rebuild")
+			}
+		}
+	}
+
+	if err != nil {
+		// Roll back to the previous image
+		if c.IsRunning() {
+			c.Stop(false)
+		}
+
+		rollbackErr := c.Restore(snapshot, false)
+		if rollbackErr != nil {
+			return errors.Wrapf(err, "Failed to roll back (%v)", rollbackErr)
+		}
+
+		c.VolatileSet(map[string]string{"volatile.image.follow.failed": info.Fingerprint})
+
+		if wasRunning {
+			c.Start(false)
+		}
+
+		return errors.Wrap(err, "Rebuilt container failed, rolled back")
+	}
+
+	// Only keep the latest rollback snapshot
+	snapshots, err := c.Snapshots()
+	if err == nil {
+		for _, snap := range snapshots {
+			_, name, _ := containerGetParentAndSnapshotName(snap.Name())
+			if snap.Name() == snapshot.Name() || !strings.HasPrefix(name, containerImageFollowSnapshotPrefix) {
+				continue
+			}
+
+			err := snap.Delete()
+			if err != nil {
+				logger.Warn("Failed to delete old rollback snapshot", log.Ctx{"snapshot": snap.Name(), "err": err})
+			}
+		}
+	}
+
+	return nil
+}
+
+// containerImageFollowReplaceRootfs unpacks the image over the container's
+// storage, recording the new base image.
+func containerImageFollowReplaceRootfs(d *Daemon, c container, fingerprint string) error {
+	ourStart, err := c.StorageStart()
+	if err != nil {
+		return err
+	}
+	if ourStart {
+		defer c.StorageStop()
+	}
+
+	// Remove the content coming from the previous image
+	for _, path := range []string{c.RootfsPath(), c.TemplatesPath(), filepath.Join(c.Path(), "metadata.yaml")} {
+		err := os.RemoveAll(path)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = unpackImage(shared.VarPath("images", fingerprint), c.Path(), c.Storage().GetStorageType(), d.os.RunningInUserNS, nil)
+	if err != nil {
+		return errors.Wrap(err, "Unpack image")
+	}
+
+	// The new files are unshifted and the templates need applying again
+	err = c.VolatileSet(map[string]string{
+		"volatile.base_image":             fingerprint,
+		"volatile.last_state.idmap":       "[]",
+		"volatile.image.follow.available": "",
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.TemplateApply("create")
+}
@@ -76,7 +76,19 @@ func validLogFileName(fname string) bool {
 		fname == "lxc.conf" ||
 		strings.HasPrefix(fname, "migration_") ||
 		strings.HasPrefix(fname, "snapshot_") ||
-		strings.HasPrefix(fname, "exec_")
+		strings.HasPrefix(fname, "exec_") ||
+		fname == "syscalls.log" ||
+		fname == "hooks.log"
+}
+
+// deletableLogFileName returns whether a log file may be deleted through the
+// API, which isn't the case of those recording what LXD, its hooks or the exec
+// sessions did.
+func deletableLogFileName(fname string) bool {
+	return fname != "lxc.log" &&
+		fname != "lxc.conf" &&
+		fname != "hooks.log" &&
+		!strings.HasPrefix(fname, "exec_")
 }
 
 func containerLogGet(d *Daemon, r *http.Request) Response {
@@ -133,8 +145,8 @@ func containerLogDelete(d *Daemon, r *http.Request) Response {
 		return BadRequest(fmt.Errorf("log file name %s not valid", file))
 	}
 
-	if file == "lxc.log" || file == "lxc.conf" {
-		return BadRequest(fmt.Errorf("lxc.log and lxc.conf may not be deleted"))
+	if !deletableLogFileName(file) {
+		return BadRequest(fmt.Errorf("%s may not be deleted", file))
 	}
 
 	return SmartError(os.Remove(shared.LogPath(name, file)))
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeletableLogFileName(t *testing.T) {
+	assert.True(t, deletableLogFileName("migration_dump_2019-01-01T00:00:00Z.log"))
+	assert.True(t, deletableLogFileName("syscalls.log"))
+	assert.False(t, deletableLogFileName("lxc.log"))
+	assert.False(t, deletableLogFileName("lxc.conf"))
+	assert.False(t, deletableLogFileName("hooks.log"))
+	assert.False(t, deletableLogFileName("exec_0ba2d9ad-8ede-4edb-a5d8-3c1d5b5a5c8e.record"))
+	assert.False(t, deletableLogFileName("exec_0ba2d9ad-8ede-4edb-a5d8-3c1d5b5a5c8e.ttyrec"))
+}
@@ -32,9 +32,12 @@ import (
 	"github.com/lxc/lxd/lxd/device"
 	"github.com/lxc/lxd/lxd/device/config"
 	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/iptables"
 	"github.com/lxc/lxd/lxd/maas"
+	"github.com/lxc/lxd/lxd/node"
 	"github.com/lxc/lxd/lxd/project"
 	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/storage/quota"
 	"github.com/lxc/lxd/lxd/template"
 	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
@@ -57,12 +60,14 @@ type lxcContainerOperation struct {
 	err       error
 	id        int
 	reusable  bool
+	timeout   time.Duration
 }
 
-func (op *lxcContainerOperation) Create(id int, action string, reusable bool) *lxcContainerOperation {
+func (op *lxcContainerOperation) Create(id int, action string, reusable bool, timeout time.Duration) *lxcContainerOperation {
 	op.id = id
 	op.action = action
 	op.reusable = reusable
+	op.timeout = timeout
 	op.chanDone = make(chan error, 0)
 	op.chanReset = make(chan bool, 0)
 
@@ -71,8 +76,10 @@ func (op *lxcContainerOperation) Create(id int, action string, reusable bool) *l
 			select {
 			case <-op.chanReset:
 				continue
-			case <-time.After(time.Second * 30):
-				op.Done(fmt.Errorf("Container %s operation timed out after 30 seconds", op.action))
+			case <-op.chanDone:
+				return
+			case <-time.After(op.timeout):
+				op.Done(fmt.Errorf("Container %s operation timed out after %s", op.action, op.timeout))
 				return
 			}
 		}
@@ -86,10 +93,19 @@ func (op *lxcContainerOperation) Reset() error {
 		return fmt.Errorf("Can't reset a non-reusable operation")
 	}
 
-	op.chanReset <- true
+	op.KeepAlive()
 	return nil
 }
 
+// KeepAlive restarts the timeout of the operation, letting it run longer
+// as long as it makes progress.
+func (op *lxcContainerOperation) KeepAlive() {
+	select {
+	case op.chanReset <- true:
+	case <-op.chanDone:
+	}
+}
+
 func (op *lxcContainerOperation) Wait() error {
 	<-op.chanDone
 
@@ -115,6 +131,25 @@ func (op *lxcContainerOperation) Done(err error) {
 var lxcContainerOperationsLock sync.Mutex
 var lxcContainerOperations map[int]*lxcContainerOperation = make(map[int]*lxcContainerOperation)
 
+// lxcContainerOperationTimeout is used when the configured timeout of an
+// operation can't be loaded.
+const lxcContainerOperationTimeout = 30 * time.Second
+
+// containerOperationKeepAlive restarts the timeout of the running operation
+// of the container with the given ID.
+func containerOperationKeepAlive(id int) error {
+	lxcContainerOperationsLock.Lock()
+	op := lxcContainerOperations[id]
+	lxcContainerOperationsLock.Unlock()
+
+	if op == nil {
+		return fmt.Errorf("No running container operation")
+	}
+
+	op.KeepAlive()
+	return nil
+}
+
 // Helper functions
 func lxcSetConfigItem(c *lxc.Container, key string, value string) error {
 	if c == nil {
@@ -368,14 +403,38 @@ func containerLXCCreate(s *state.State, args db.ContainerArgs) (container, error
 	}
 
 	// Validate expanded config
-	err = containerValidConfig(s.OS, c.expandedConfig, false, true)
+	restrictions, err := projectRestrictions(s.Cluster, c.project)
+	if err != nil {
+		c.Delete()
+		logger.Error("Failed creating container", ctxMap)
+		return nil, err
+	}
+
+	err = containerValidConfig(s.OS, c.expandedConfig, false, true, restrictions)
 	if err != nil {
 		c.Delete()
 		logger.Error("Failed creating container", ctxMap)
 		return nil, err
 	}
 
-	err = containerValidDevices(s, s.Cluster, c.expandedDevices, false, true)
+	// Check that the protected keys of the project aren't overridden
+	if !c.IsSnapshot() {
+		projConfig, err := projectConfig(s.Cluster, c.project)
+		if err != nil {
+			c.Delete()
+			logger.Error("Failed creating container", ctxMap)
+			return nil, err
+		}
+
+		err = projectDefaultsCheckConfig(projConfig, c.localConfig)
+		if err != nil {
+			c.Delete()
+			logger.Error("Failed creating container", ctxMap)
+			return nil, err
+		}
+	}
+
+	err = containerValidDevices(s, s.Cluster, c.expandedDevices, false, true, restrictions)
 	if err != nil {
 		c.Delete()
 		logger.Error("Failed creating container", ctxMap)
@@ -396,6 +455,16 @@ func containerLXCCreate(s *state.State, args db.ContainerArgs) (container, error
 
 	storagePool := rootDiskDevice["pool"]
 
+	// Check that the project allows the pool
+	if !c.IsSnapshot() {
+		err = projectStoragePoolCheck(s.Cluster, args.Project, storagePool)
+		if err != nil {
+			c.Delete()
+			logger.Error("Failed creating container", ctxMap)
+			return nil, err
+		}
+	}
+
 	// Get the storage pool ID for the container
 	poolID, pool, err := s.Cluster.StoragePoolGet(storagePool)
 	if err != nil {
@@ -403,6 +472,16 @@ func containerLXCCreate(s *state.State, args db.ContainerArgs) (container, error
 		return nil, err
 	}
 
+	// Check that the root disk fits the policies of the pool
+	if !c.IsSnapshot() {
+		err = storagePoolCommitCheck(s, c, rootDiskDevice, pool)
+		if err != nil {
+			c.Delete()
+			logger.Error("Failed creating container", ctxMap)
+			return nil, err
+		}
+	}
+
 	// Fill in any default volume config
 	volumeConfig := map[string]string{}
 	err = storageVolumeFillDefault(storagePool, volumeConfig, pool)
@@ -497,12 +576,10 @@ func containerLXCCreate(s *state.State, args db.ContainerArgs) (container, error
 	}
 
 	if !c.IsSnapshot() {
-		// Update MAAS
+		// Update MAAS, retrying in the background if it's unavailable
 		err = c.maasUpdate(nil)
 		if err != nil {
-			c.Delete()
-			logger.Error("Failed creating container", ctxMap)
-			return nil, err
+			maasSyncQueueAdd(project.Prefix(c.project, c.name), c.project, c.name, "update", err)
 		}
 
 		// Add devices to container.
@@ -522,9 +599,10 @@ func containerLXCCreate(s *state.State, args db.ContainerArgs) (container, error
 	return c, nil
 }
 
-func containerLXCLoad(s *state.State, args db.ContainerArgs, profiles []api.Profile) (container, error) {
+func containerLXCLoad(s *state.State, args db.ContainerArgs, profiles []api.Profile, projectConfig map[string]string) (container, error) {
 	// Create the container struct
 	c := containerLXCInstantiate(s, args)
+	c.projectConfig = projectConfig
 
 	// Setup finalizer
 	runtime.SetFinalizer(c, containerLXCUnload)
@@ -610,6 +688,7 @@ type containerLXC struct {
 	localConfig     map[string]string
 	localDevices    config.Devices
 	profiles        []string
+	projectConfig   map[string]string
 
 	// Cache
 	c       *lxc.Container
@@ -628,6 +707,13 @@ type containerLXC struct {
 	op *operation
 
 	expiryDate time.Time
+
+	// Activity recorded in the database, when preloaded
+	activity map[string]time.Time
+
+	// Snapshots and backups, when preloaded
+	snapshots []container
+	backups   []backup
 }
 
 func (c *containerLXC) Type() string {
@@ -649,12 +735,37 @@ func (c *containerLXC) createOperation(action string, reusable bool, reuse bool)
 	defer lxcContainerOperationsLock.Unlock()
 
 	op = &lxcContainerOperation{}
-	op.Create(c.id, action, reusable)
+	op.Create(c.id, action, reusable, c.operationTimeout(action))
 	lxcContainerOperations[c.id] = op
 
 	return lxcContainerOperations[c.id], nil
 }
 
+// operationTimeout returns the core.operation_timeout.<action> of the node.
+func (c *containerLXC) operationTimeout(action string) time.Duration {
+	timeout := lxcContainerOperationTimeout
+
+	// Other actions don't have a configurable timeout
+	if !shared.StringInSlice(action, []string{"restore", "start", "stop"}) {
+		return timeout
+	}
+	err := c.state.Node.Transaction(func(tx *db.NodeTx) error {
+		config, err := node.ConfigLoad(tx)
+		if err != nil {
+			return err
+		}
+
+		timeout = config.OperationTimeout(action)
+		return nil
+	})
+	if err != nil {
+		logger.Warn("Failed to load the container operation timeout", log.Ctx{"container": c.Name(), "action": action, "err": err})
+		return lxcContainerOperationTimeout
+	}
+
+	return timeout
+}
+
 func (c *containerLXC) getOperation(action string) (*lxcContainerOperation, error) {
 	lxcContainerOperationsLock.Lock()
 	defer lxcContainerOperationsLock.Unlock()
@@ -1072,8 +1183,12 @@ func (c *containerLXC) initLXC(config bool) error {
 		mounts = append(mounts, "sys:rw")
 	}
 
+	nestedCgroups := c.expandedConfig["security.nesting.cgroups"] == "full"
 	if !shared.PathExists("/proc/self/ns/cgroup") {
 		mounts = append(mounts, "cgroup:mixed")
+	} else if nestedCgroups {
+		// Writable cgroup tree, scoped to the container by its namespace
+		mounts = append(mounts, "cgroup:rw:force")
 	}
 
 	err = lxcSetConfigItem(cc, "lxc.mount.auto", strings.Join(mounts, " "))
@@ -1081,6 +1196,13 @@ func (c *containerLXC) initLXC(config bool) error {
 		return err
 	}
 
+	if nestedCgroups {
+		err = c.initLXCNestedCgroups(cc)
+		if err != nil {
+			return err
+		}
+	}
+
 	err = lxcSetConfigItem(cc, "lxc.autodev", "1")
 	if err != nil {
 		return err
@@ -1143,7 +1265,7 @@ func (c *containerLXC) initLXC(config bool) error {
 
 	// Configure devices cgroup
 	if c.IsPrivileged() && !c.state.OS.RunningInUserNS && c.state.OS.CGroupDevicesController {
-		err = lxcSetConfigItem(cc, "lxc.cgroup.devices.deny", "a")
+		err = c.cgroupConfigSet(cc, "devices.deny", "a")
 		if err != nil {
 			return err
 		}
@@ -1165,7 +1287,7 @@ func (c *containerLXC) initLXC(config bool) error {
 		}
 
 		for _, dev := range devices {
-			err = lxcSetConfigItem(cc, "lxc.cgroup.devices.allow", dev)
+			err = c.cgroupConfigSet(cc, "devices.allow", dev)
 			if err != nil {
 				return err
 			}
@@ -1218,7 +1340,7 @@ func (c *containerLXC) initLXC(config bool) error {
 		return err
 	}
 
-	if c.state.OS.Shiftfs && !c.IsPrivileged() && diskIdmap == nil {
+	if !c.idmappedRootfs() && c.state.OS.Shiftfs && !c.IsPrivileged() && diskIdmap == nil {
 		// Host side mark mount
 		err = lxcSetConfigItem(cc, "lxc.hook.pre-start", fmt.Sprintf("/bin/mount -t shiftfs -o mark,passthrough=3 %s %s", c.RootfsPath(), c.RootfsPath()))
 		if err != nil {
@@ -1238,6 +1360,15 @@ func (c *containerLXC) initLXC(config bool) error {
 		}
 	}
 
+	// Restore the firewall rules of the container and mount its hugepages
+	// backed disks before its init runs
+	if shared.IsTrue(c.expandedConfig["network.firewall.persist"]) || containerHugepagesHasDisks(c.expandedDevices) {
+		err = lxcSetConfigItem(cc, "lxc.hook.start-host", fmt.Sprintf("%s callhook %s %d starthost", c.state.OS.ExecPath, shared.VarPath(""), c.id))
+		if err != nil {
+			return err
+		}
+	}
+
 	err = lxcSetConfigItem(cc, "lxc.hook.stop", fmt.Sprintf("%s callhook %s %d stopns", c.state.OS.ExecPath, shared.VarPath(""), c.id))
 	if err != nil {
 		return err
@@ -1280,7 +1411,7 @@ func (c *containerLXC) initLXC(config bool) error {
 			}
 		} else {
 			// If not currently confined, use the container's profile
-			profile := AAProfileFull(c)
+			profile := AAProfileName(c)
 
 			/* In the nesting case, we want to enable the inside
 			 * LXD to load its profile. Unprivileged containers can
@@ -1318,6 +1449,27 @@ func (c *containerLXC) initLXC(config bool) error {
 		}
 	}
 
+	// Setup the time namespace
+	timeOffsets := map[string]string{
+		"lxc.time.offset.boot":      c.expandedConfig["security.time.offset.boottime"],
+		"lxc.time.offset.monotonic": c.expandedConfig["security.time.offset.monotonic"],
+	}
+
+	for _, key := range []string{"lxc.time.offset.boot", "lxc.time.offset.monotonic"} {
+		if timeOffsets[key] == "" {
+			continue
+		}
+
+		if !c.state.OS.TimeNamespace || !c.state.OS.LXCFeatures["time_namespace"] {
+			return fmt.Errorf("The time offsets require time namespace support from the kernel and liblxc")
+		}
+
+		err = lxcSetConfigItem(cc, key, timeOffsets[key])
+		if err != nil {
+			return err
+		}
+	}
+
 	// Setup idmap
 	idmapset, err := c.NextIdmap()
 	if err != nil {
@@ -1431,28 +1583,28 @@ func (c *containerLXC) initLXC(config bool) error {
 			}
 
 			if memoryEnforce == "soft" {
-				err = lxcSetConfigItem(cc, "lxc.cgroup.memory.soft_limit_in_bytes", fmt.Sprintf("%d", valueInt))
+				err = c.cgroupConfigSet(cc, "memory.soft_limit_in_bytes", fmt.Sprintf("%d", valueInt))
 				if err != nil {
 					return err
 				}
 			} else {
 				if c.state.OS.CGroupSwapAccounting && (memorySwap == "" || shared.IsTrue(memorySwap)) {
-					err = lxcSetConfigItem(cc, "lxc.cgroup.memory.limit_in_bytes", fmt.Sprintf("%d", valueInt))
+					err = c.cgroupConfigSet(cc, "memory.limit_in_bytes", fmt.Sprintf("%d", valueInt))
 					if err != nil {
 						return err
 					}
-					err = lxcSetConfigItem(cc, "lxc.cgroup.memory.memsw.limit_in_bytes", fmt.Sprintf("%d", valueInt))
+					err = c.cgroupConfigSet(cc, "memory.memsw.limit_in_bytes", fmt.Sprintf("%d", valueInt))
 					if err != nil {
 						return err
 					}
 				} else {
-					err = lxcSetConfigItem(cc, "lxc.cgroup.memory.limit_in_bytes", fmt.Sprintf("%d", valueInt))
+					err = c.cgroupConfigSet(cc, "memory.limit_in_bytes", fmt.Sprintf("%d", valueInt))
 					if err != nil {
 						return err
 					}
 				}
 				// Set soft limit to value 10% less than hard limit
-				err = lxcSetConfigItem(cc, "lxc.cgroup.memory.soft_limit_in_bytes", fmt.Sprintf("%.0f", float64(valueInt)*0.9))
+				err = c.cgroupConfigSet(cc, "memory.soft_limit_in_bytes", fmt.Sprintf("%.0f", float64(valueInt)*0.9))
 				if err != nil {
 					return err
 				}
@@ -1461,7 +1613,7 @@ func (c *containerLXC) initLXC(config bool) error {
 
 		// Configure the swappiness
 		if memorySwap != "" && !shared.IsTrue(memorySwap) {
-			err = lxcSetConfigItem(cc, "lxc.cgroup.memory.swappiness", "0")
+			err = c.cgroupConfigSet(cc, "memory.swappiness", "0")
 			if err != nil {
 				return err
 			}
@@ -1471,7 +1623,7 @@ func (c *containerLXC) initLXC(config bool) error {
 				return err
 			}
 
-			err = lxcSetConfigItem(cc, "lxc.cgroup.memory.swappiness", fmt.Sprintf("%d", 60-10+priority))
+			err = c.cgroupConfigSet(cc, "memory.swappiness", fmt.Sprintf("%d", 60-10+priority))
 			if err != nil {
 				return err
 			}
@@ -1489,27 +1641,41 @@ func (c *containerLXC) initLXC(config bool) error {
 		}
 
 		if cpuShares != "1024" {
-			err = lxcSetConfigItem(cc, "lxc.cgroup.cpu.shares", cpuShares)
+			err = c.cgroupConfigSet(cc, "cpu.shares", cpuShares)
 			if err != nil {
 				return err
 			}
 		}
 
 		if cpuCfsPeriod != "-1" {
-			err = lxcSetConfigItem(cc, "lxc.cgroup.cpu.cfs_period_us", cpuCfsPeriod)
+			err = c.cgroupConfigSet(cc, "cpu.cfs_period_us", cpuCfsPeriod)
 			if err != nil {
 				return err
 			}
 		}
 
 		if cpuCfsQuota != "-1" {
-			err = lxcSetConfigItem(cc, "lxc.cgroup.cpu.cfs_quota_us", cpuCfsQuota)
+			err = c.cgroupConfigSet(cc, "cpu.cfs_quota_us", cpuCfsQuota)
 			if err != nil {
 				return err
 			}
 		}
 	}
 
+	// CPU burst (must come after the quota)
+	cpuBurst := c.expandedConfig["limits.cpu.allowance.burst"]
+	if cpuBurst != "" && c.state.OS.CGroupCPUBurst {
+		cpuCfsBurst, err := deviceParseCPUBurst(cpuBurst)
+		if err != nil {
+			return err
+		}
+
+		err = c.cgroupConfigSet(cc, "cpu.cfs_burst_us", cpuCfsBurst)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Disk limits
 	if c.state.OS.CGroupBlkioController {
 		diskPriority := c.expandedConfig["limits.disk.priority"]
@@ -1525,7 +1691,7 @@ func (c *containerLXC) initLXC(config bool) error {
 				priority = 10
 			}
 
-			err = lxcSetConfigItem(cc, "lxc.cgroup.blkio.weight", fmt.Sprintf("%d", priority))
+			err = c.cgroupConfigSet(cc, "blkio.weight", fmt.Sprintf("%d", priority))
 			if err != nil {
 				return err
 			}
@@ -1577,34 +1743,67 @@ func (c *containerLXC) initLXC(config bool) error {
 
 			for block, limit := range diskLimits {
 				if limit.readBps > 0 {
-					err = lxcSetConfigItem(cc, "lxc.cgroup.blkio.throttle.read_bps_device", fmt.Sprintf("%s %d", block, limit.readBps))
+					err = c.cgroupConfigSet(cc, "blkio.throttle.read_bps_device", fmt.Sprintf("%s %d", block, limit.readBps))
 					if err != nil {
 						return err
 					}
 				}
 
 				if limit.readIops > 0 {
-					err = lxcSetConfigItem(cc, "lxc.cgroup.blkio.throttle.read_iops_device", fmt.Sprintf("%s %d", block, limit.readIops))
+					err = c.cgroupConfigSet(cc, "blkio.throttle.read_iops_device", fmt.Sprintf("%s %d", block, limit.readIops))
 					if err != nil {
 						return err
 					}
 				}
 
 				if limit.writeBps > 0 {
-					err = lxcSetConfigItem(cc, "lxc.cgroup.blkio.throttle.write_bps_device", fmt.Sprintf("%s %d", block, limit.writeBps))
+					err = c.cgroupConfigSet(cc, "blkio.throttle.write_bps_device", fmt.Sprintf("%s %d", block, limit.writeBps))
 					if err != nil {
 						return err
 					}
 				}
 
 				if limit.writeIops > 0 {
-					err = lxcSetConfigItem(cc, "lxc.cgroup.blkio.throttle.write_iops_device", fmt.Sprintf("%s %d", block, limit.writeIops))
+					err = c.cgroupConfigSet(cc, "blkio.throttle.write_iops_device", fmt.Sprintf("%s %d", block, limit.writeIops))
 					if err != nil {
 						return err
 					}
 				}
 			}
 		}
+
+		// Latency targets, only found on the unified hierarchy
+		diskLatency := c.expandedConfig["limits.disk.latency"]
+		if diskLatency != "" && c.state.OS.CGroupIOLatency {
+			ourStart := false
+
+			// Detect initial creation where the rootfs doesn't exist yet (can't mount it)
+			if shared.PathExists(c.RootfsPath()) {
+				ourStart, err = c.StorageStart()
+				if err != nil {
+					return err
+				}
+			}
+
+			entries, err := c.getDiskLatency(diskLatency)
+			if ourStart {
+				_, err2 := c.StorageStop()
+				if err2 != nil {
+					return err2
+				}
+			}
+
+			if err != nil {
+				return err
+			}
+
+			for _, entry := range entries {
+				err = c.cgroupConfigSet(cc, "io.latency", entry)
+				if err != nil {
+					return err
+				}
+			}
+		}
 	}
 
 	// Processes
@@ -1616,7 +1815,49 @@ func (c *containerLXC) initLXC(config bool) error {
 				return err
 			}
 
-			err = lxcSetConfigItem(cc, "lxc.cgroup.pids.max", fmt.Sprintf("%d", valueInt))
+			err = c.cgroupConfigSet(cc, "pids.max", fmt.Sprintf("%d", valueInt))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// Huge pages
+	if c.state.OS.CGroupHugetlbController {
+		for pageSize := range containerHugepagesSizes {
+			limit, err := containerHugepagesLimit(c.expandedConfig, c.expandedDevices, pageSize)
+			if err != nil {
+				return err
+			}
+
+			if limit == -1 {
+				continue
+			}
+
+			err = c.cgroupConfigSet(cc, fmt.Sprintf("hugetlb.%s.limit_in_bytes", pageSize), fmt.Sprintf("%d", limit))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// Keep the memory on the NUMA nodes the container is bound to
+	nodes, err := c.memoryNodes()
+	if err != nil {
+		return err
+	}
+
+	if len(nodes) > 0 && c.state.OS.CGroupCPUsetController {
+		err = c.cgroupConfigSet(cc, "cpuset.mems", strings.Join(nodes, ","))
+		if err != nil {
+			return err
+		}
+	}
+
+	// Setup sysctls
+	for k, v := range c.expandedConfig {
+		if strings.HasPrefix(k, "linux.sysctl.") && v != "" {
+			err = lxcSetConfigItem(cc, fmt.Sprintf("lxc.sysctl.%s", strings.TrimPrefix(k, "linux.sysctl.")), v)
 			if err != nil {
 				return err
 			}
@@ -1685,10 +1926,10 @@ func (c *containerLXC) initLXC(config bool) error {
 			isRecursive := shared.IsTrue(m["recursive"])
 
 			// If we want to mount a storage volume from a storage
-			// pool we created via our storage api, we are always
-			// mounting a directory.
+			// pool we created via our storage api or a hugetlbfs, we
+			// are always mounting a directory.
 			isFile := false
-			if m["pool"] == "" {
+			if m["pool"] == "" && m["backing"] == "" {
 				isFile = !shared.IsDir(srcPath) && !device.IsBlockdev(srcPath)
 			}
 
@@ -1717,9 +1958,20 @@ func (c *containerLXC) initLXC(config bool) error {
 					return err
 				}
 
+				rootfsOptions := []string{}
+
 				// Read-only rootfs (unlikely to work very well)
 				if isReadOnly {
-					err = lxcSetConfigItem(cc, "lxc.rootfs.options", "ro")
+					rootfsOptions = append(rootfsOptions, "ro")
+				}
+
+				// Let liblxc idmap the unshifted rootfs
+				if c.idmappedRootfs() && diskIdmap == nil {
+					rootfsOptions = append(rootfsOptions, "idmap=container")
+				}
+
+				if len(rootfsOptions) > 0 {
+					err = lxcSetConfigItem(cc, "lxc.rootfs.options", strings.Join(rootfsOptions, ","))
 					if err != nil {
 						return err
 					}
@@ -1745,9 +1997,10 @@ func (c *containerLXC) initLXC(config bool) error {
 					}
 				}
 
-				if shift {
+				idmapped := shift && c.idmappedDisk(m, srcPath)
+				if shift && !idmapped {
 					if !c.state.OS.Shiftfs {
-						return fmt.Errorf("shiftfs is required by disk entry '%s' but isn't supported on system", k)
+						return fmt.Errorf("shiftfs or idmapped mounts are required by disk entry '%s' but aren't supported on system", k)
 					}
 
 					err = lxcSetConfigItem(cc, "lxc.hook.pre-start", fmt.Sprintf("/bin/mount -t shiftfs -o mark,passthrough=3 %s %s", sourceDevPath, sourceDevPath))
@@ -1776,6 +2029,10 @@ func (c *containerLXC) initLXC(config bool) error {
 					options = append(options, "optional")
 				}
 
+				if idmapped {
+					options = append(options, "idmap=container")
+				}
+
 				if isRecursive {
 					rbind = "r"
 				}
@@ -1860,6 +2117,50 @@ func (c *containerLXC) runHooks(hooks []func() error) error {
 	return nil
 }
 
+// Files virtualized by lxcfs according to the reader's cgroup.
+var lxcfsFiles = []string{
+	"proc/cpuinfo",
+	"proc/diskstats",
+	"proc/loadavg",
+	"proc/meminfo",
+	"proc/stat",
+	"proc/swaps",
+	"proc/uptime",
+	"sys/devices/system/cpu/online",
+}
+
+// initLXCNestedCgroups sets up the mounts needed by container runtimes
+// running inside the container (security.nesting.cgroups=full).
+func (c *containerLXC) initLXCNestedCgroups(cc *lxc.Container) error {
+	// Expose the container's part of the unified hierarchy on hybrid hosts
+	if c.state.OS.CGroupUnified {
+		err := lxcSetConfigItem(cc, "lxc.mount.entry", "cgroup2 sys/fs/cgroup/unified cgroup2 rw,nosuid,nodev,noexec,relatime,create=dir,optional 0 0")
+		if err != nil {
+			return err
+		}
+	}
+
+	// Have the statistics read by the nested runtimes reflect the limits
+	// of the cgroup they're read from rather than the host.
+	if !shared.PathExists("/var/lib/lxcfs/proc") {
+		return nil
+	}
+
+	for _, path := range lxcfsFiles {
+		source := filepath.Join("/var/lib/lxcfs", path)
+		if !shared.PathExists(source) {
+			continue
+		}
+
+		err := lxcSetConfigItem(cc, "lxc.mount.entry", fmt.Sprintf("%s %s none bind,create=file,optional 0 0", source, path))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // deviceLoad instantiates and validates a new device and returns it along with enriched config.
 func (c *containerLXC) deviceLoad(deviceName string, rawConfig map[string]string) (device.Device, map[string]string, error) {
 	var configCopy config.Device
@@ -2068,6 +2369,52 @@ func (c *containerLXC) deviceUpdate(deviceName string, rawConfig map[string]stri
 	return nil
 }
 
+// NetworkReapply sets up the host side of the network devices of a running
+// container again, for when the host bridges or firewall were rebuilt. The
+// limits, routes, filters and static leases are re-applied in place, or the
+// devices are stopped and started again (causing a link flap) when restart is
+// true, which also covers the types that can't be re-applied in place.
+func (c *containerLXC) NetworkReapply(restart bool) error {
+	if !c.IsRunning() {
+		return fmt.Errorf("The container isn't running")
+	}
+
+	for _, name := range c.expandedDevices.DeviceNames() {
+		m := c.expandedDevices[name]
+		if !shared.StringInSlice(m["type"], []string{"nic", "infiniband"}) {
+			continue
+		}
+
+		logger.Debug("Re-applying network device", log.Ctx{"container": c.Name(), "project": c.Project(), "device": name, "restart": restart})
+
+		if !restart {
+			oldConfig := config.Device{}
+			for k, v := range m {
+				oldConfig[k] = v
+			}
+
+			err := c.deviceUpdate(name, m, oldConfig, true)
+			if err != nil {
+				return errors.Wrapf(err, "Failed to re-apply device '%s'", name)
+			}
+
+			continue
+		}
+
+		err := c.deviceStop(name, m, "")
+		if err != nil {
+			return errors.Wrapf(err, "Failed to stop device '%s'", name)
+		}
+
+		_, err = c.deviceStart(name, m, true)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to start device '%s'", name)
+		}
+	}
+
+	return nil
+}
+
 // deviceStop loads a new device and calls its Stop() function.
 func (c *containerLXC) deviceStop(deviceName string, rawConfig map[string]string, stopHookNetnsPath string) error {
 	d, configCopy, err := c.deviceLoad(deviceName, rawConfig)
@@ -2266,7 +2613,12 @@ func (c *containerLXC) expandConfig(profiles []api.Profile) error {
 		}
 	}
 
-	c.expandedConfig = db.ProfilesExpandConfig(c.localConfig, profiles)
+	projConfig, err := c.projectDefaults()
+	if err != nil {
+		return err
+	}
+
+	c.expandedConfig = projectExpandConfig(projConfig, c.localConfig, profiles)
 
 	return nil
 }
@@ -2280,16 +2632,61 @@ func (c *containerLXC) expandDevices(profiles []api.Profile) error {
 		}
 	}
 
-	c.expandedDevices = db.ProfilesExpandDevices(c.localDevices, profiles)
+	projConfig, err := c.projectDefaults()
+	if err != nil {
+		return err
+	}
+
+	c.expandedDevices = projectExpandDevices(projConfig, c.localDevices, profiles)
 
 	return nil
 }
 
+// projectDefaults returns the configuration of the project of the container,
+// holding its default config and devices, only fetching it if it wasn't
+// loaded along with the container.
+func (c *containerLXC) projectDefaults() (map[string]string, error) {
+	if c.projectConfig != nil {
+		return c.projectConfig, nil
+	}
+
+	projConfig, err := projectConfig(c.state.Cluster, c.project)
+	if err != nil {
+		return nil, err
+	}
+
+	c.projectConfig = projConfig
+	return projConfig, nil
+}
+
+// memoryNodes returns the NUMA nodes the memory of the container must be
+// allocated from, those set in limits.cpu.nodes. It's empty when the memory
+// isn't restricted.
+func (c *containerLXC) memoryNodes() ([]string, error) {
+	nodes := []string{}
+
+	if c.expandedConfig["limits.cpu.nodes"] != "" {
+		ids, err := parseCpuset(c.expandedConfig["limits.cpu.nodes"])
+		if err != nil {
+			return nil, err
+		}
+
+		for _, id := range ids {
+			node := fmt.Sprintf("%d", id)
+			if !shared.StringInSlice(node, nodes) {
+				nodes = append(nodes, node)
+			}
+		}
+	}
+
+	return nodes, nil
+}
+
 // setupUnixDevice() creates the unix device and sets up the necessary low-level
 // liblxc configuration items.
 func (c *containerLXC) setupUnixDevice(prefix string, dev config.Device, major int, minor int, path string, createMustSucceed bool, defaultMode bool) error {
 	if c.isCurrentlyPrivileged() && !c.state.OS.RunningInUserNS && c.state.OS.CGroupDevicesController {
-		err := lxcSetConfigItem(c.c, "lxc.cgroup.devices.allow", fmt.Sprintf("c %d:%d rwm", major, minor))
+		err := c.cgroupConfigSet(c.c, "devices.allow", fmt.Sprintf("c %d:%d rwm", major, minor))
 		if err != nil {
 			return err
 		}
@@ -2327,7 +2724,7 @@ func (c *containerLXC) setupUnixDevice(prefix string, dev config.Device, major i
 	return lxcSetConfigItem(c.c, "lxc.mount.entry", val)
 }
 
-func shiftBtrfsRootfs(path string, diskIdmap *idmap.IdmapSet, shift bool) error {
+func shiftBtrfsRootfs(path string, diskIdmap *idmap.IdmapSet, shift bool, skipper func(dir string, absPath string, fi os.FileInfo) bool) error {
 	var err error
 	roSubvols := []string{}
 	subvols, _ := btrfsSubVolumesGet(path)
@@ -2344,9 +2741,9 @@ func shiftBtrfsRootfs(path string, diskIdmap *idmap.IdmapSet, shift bool) error
 	}
 
 	if shift {
-		err = diskIdmap.ShiftRootfs(path, nil)
+		err = diskIdmap.ShiftRootfs(path, skipper)
 	} else {
-		err = diskIdmap.UnshiftRootfs(path, nil)
+		err = diskIdmap.UnshiftRootfs(path, skipper)
 	}
 
 	for _, subvol := range roSubvols {
@@ -2357,32 +2754,218 @@ func shiftBtrfsRootfs(path string, diskIdmap *idmap.IdmapSet, shift bool) error
 }
 
 func ShiftBtrfsRootfs(path string, diskIdmap *idmap.IdmapSet) error {
-	return shiftBtrfsRootfs(path, diskIdmap, true)
+	return shiftBtrfsRootfs(path, diskIdmap, true, nil)
 }
 
 func UnshiftBtrfsRootfs(path string, diskIdmap *idmap.IdmapSet) error {
-	return shiftBtrfsRootfs(path, diskIdmap, false)
+	return shiftBtrfsRootfs(path, diskIdmap, false, nil)
 }
 
-// Start functions
-func (c *containerLXC) startCommon() (string, []func() error, error) {
-	var ourStart bool
-	postStartHooks := []func() error{}
+// remapRootfs shifts the root filesystem of the container from the idmap it's
+// currently shifted to onto the given one, reporting the number of files done
+// as the progress of the container operation. The storage must be started.
+func (c *containerLXC) remapRootfs(diskIdmap *idmap.IdmapSet, nextIdmap *idmap.IdmapSet) error {
+	progress := func(stage string) func(dir string, absPath string, fi os.FileInfo) bool {
+		files := 0
+		c.updateProgress(fmt.Sprintf("Remapping container filesystem (%s)", stage))
 
-	// Load the go-lxc struct
-	err := c.initLXC(true)
-	if err != nil {
-		return "", postStartHooks, errors.Wrap(err, "Load go-lxc struct")
-	}
+		return func(dir string, absPath string, fi os.FileInfo) bool {
+			if c.Storage().GetStorageType() == storageTypeZfs && zfsIdmapSetSkipper(dir, absPath, fi) {
+				return true
+			}
 
-	// Check that we're not already running
-	if c.IsRunning() {
-		return "", postStartHooks, fmt.Errorf("The container is already running")
+			files++
+			if files%10000 == 0 {
+				c.updateProgress(fmt.Sprintf("Remapping container filesystem (%s, %d files)", stage, files))
+
+				// Large filesystems take longer than the operation timeout
+				containerOperationKeepAlive(c.id)
+			}
+
+			return false
+		}
 	}
 
-	// Sanity checks for devices
-	for name, m := range c.expandedDevices {
-		switch m["type"] {
+	var err error
+	if diskIdmap != nil {
+		if c.Storage().GetStorageType() == storageTypeBtrfs {
+			err = shiftBtrfsRootfs(c.RootfsPath(), diskIdmap, false, progress("unshifting"))
+		} else {
+			err = diskIdmap.UnshiftRootfs(c.RootfsPath(), progress("unshifting"))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if nextIdmap != nil && !c.shiftsAtMount() {
+		if c.Storage().GetStorageType() == storageTypeBtrfs {
+			err = shiftBtrfsRootfs(c.RootfsPath(), nextIdmap, true, progress("shifting"))
+		} else {
+			err = nextIdmap.ShiftRootfs(c.RootfsPath(), progress("shifting"))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	jsonDiskIdmap := "[]"
+	if nextIdmap != nil && !c.shiftsAtMount() {
+		idmapBytes, err := json.Marshal(nextIdmap.Idmap)
+		if err != nil {
+			return err
+		}
+		jsonDiskIdmap = string(idmapBytes)
+	}
+
+	err = c.VolatileSet(map[string]string{"volatile.last_state.idmap": jsonDiskIdmap})
+	if err != nil {
+		return errors.Wrapf(err, "Set volatile.last_state.idmap config key on container %q (id %d)", c.name, c.id)
+	}
+
+	c.updateProgress("")
+
+	return nil
+}
+
+// shiftsAtMount returns whether the root filesystem is left unshifted on disk
+// and mapped to the container's idmap when mounted, through idmapped mounts
+// or shiftfs.
+func (c *containerLXC) shiftsAtMount() bool {
+	return c.state.OS.Shiftfs || c.idmappedRootfs()
+}
+
+// idmappedRootfs returns whether the root filesystem of the container gets
+// idmapped when mounted, which its storage pool must support. Containers on
+// other pools fall back to shiftfs or to shifting their root filesystem.
+func (c *containerLXC) idmappedRootfs() bool {
+	if !c.state.OS.IdmappedMounts || c.IsPrivileged() {
+		return false
+	}
+
+	poolName, err := c.StoragePool()
+	if err != nil {
+		return false
+	}
+
+	// Probe the pool through the root filesystem of the container
+	if !storagePoolIdmapKnown(poolName) {
+		ourStart, err := c.StorageStart()
+		if err != nil {
+			return false
+		}
+
+		if ourStart {
+			defer c.StorageStop()
+		}
+	}
+
+	return storagePoolIdmappable(poolName, c.RootfsPath())
+}
+
+// idmappedDisk returns whether a shifted disk gets idmapped when mounted,
+// which the filesystem of its source must support. Block devices only get
+// mounted at start, liblxc failing the start when they can't be idmapped.
+func (c *containerLXC) idmappedDisk(m config.Device, srcPath string) bool {
+	if !c.state.OS.IdmappedMounts {
+		return false
+	}
+
+	if m["pool"] == "" {
+		if device.IsBlockdev(srcPath) {
+			return true
+		}
+
+		return CanIdmapPath(srcPath)
+	}
+
+	// Probe the pool through the volume
+	if !storagePoolIdmapKnown(m["pool"]) {
+		s, err := storagePoolVolumeInit(c.state, "default", m["pool"], m["source"], storagePoolVolumeTypeCustom)
+		if err != nil {
+			return false
+		}
+
+		ourMount, err := s.StoragePoolVolumeMount()
+		if err != nil {
+			return false
+		}
+
+		if ourMount {
+			defer s.StoragePoolVolumeUmount()
+		}
+	}
+
+	return storagePoolIdmappable(m["pool"], shared.VarPath("storage-pools", m["pool"], storagePoolVolumeTypeNameCustom, m["source"]))
+}
+
+// RemapRootfs shifts the root filesystem of a stopped container to the idmap
+// it will use on next start, so that the start doesn't have to.
+func (c *containerLXC) RemapRootfs() error {
+	// Prevent the container from starting while it's remapped
+	op, err := c.createOperation("remap", false, false)
+	if err != nil {
+		return errors.Wrap(err, "Create container remap operation")
+	}
+	defer op.Done(nil)
+
+	if c.IsRunning() {
+		return fmt.Errorf("The container must be stopped to be remapped")
+	}
+
+	nextIdmap, err := c.NextIdmap()
+	if err != nil {
+		return errors.Wrap(err, "Set ID map")
+	}
+
+	diskIdmap, err := c.DiskIdmap()
+	if err != nil {
+		return errors.Wrap(err, "Set last ID map")
+	}
+
+	if nextIdmap.Equals(diskIdmap) || (diskIdmap == nil && c.shiftsAtMount()) {
+		return nil
+	}
+
+	if shared.IsTrue(c.expandedConfig["security.protection.shift"]) {
+		return fmt.Errorf("Container is protected against filesystem shifting")
+	}
+
+	ourStart, err := c.StorageStart()
+	if err != nil {
+		return errors.Wrap(err, "Storage start")
+	}
+	if ourStart {
+		defer c.StorageStop()
+	}
+
+	logger.Info("Remapping container filesystem", log.Ctx{"project": c.project, "name": c.name})
+
+	return c.remapRootfs(diskIdmap, nextIdmap)
+}
+
+// Start functions
+func (c *containerLXC) startCommon() (string, []func() error, error) {
+	var ourStart bool
+	postStartHooks := []func() error{}
+
+	// Apply the nesting profile ahead of the go-lxc configuration
+	containerNestingExpandConfig(c.state, c.expandedConfig)
+
+	// Load the go-lxc struct
+	err := c.initLXC(true)
+	if err != nil {
+		return "", postStartHooks, errors.Wrap(err, "Load go-lxc struct")
+	}
+
+	// Check that we're not already running
+	if c.IsRunning() {
+		return "", postStartHooks, fmt.Errorf("The container is already running")
+	}
+
+	// Sanity checks for devices
+	for name, m := range c.expandedDevices {
+		switch m["type"] {
 		case "disk":
 			// When we want to attach a storage volume created via
 			// the storage api m["source"] only contains the name of
@@ -2399,7 +2982,7 @@ func (c *containerLXC) startCommon() (string, []func() error, error) {
 			}
 
 			if srcPath != "" && m["required"] != "" && !shared.IsTrue(m["required"]) {
-				err = deviceInotifyAddClosestLivingAncestor(c.state, filepath.Dir(srcPath))
+				err = deviceInotifyAddDevice(c.state, c.id, name, deviceInotifyDevicePath(m))
 				if err != nil {
 					logger.Errorf("Failed to add \"%s\" to inotify targets", srcPath)
 					return "", postStartHooks, fmt.Errorf("Failed to setup inotify watch for '%s': %v", srcPath, err)
@@ -2410,6 +2993,35 @@ func (c *containerLXC) startCommon() (string, []func() error, error) {
 		}
 	}
 
+	// Check that the hugepages backed disks fit within the limits
+	err = containerHugepagesCheckLimits(c.expandedConfig, c.expandedDevices)
+	if err != nil {
+		return "", postStartHooks, err
+	}
+
+	// Check that no running container uses the same host devices
+	err = containerDeviceClaimsCheck(c.state, c, c.expandedDevices)
+	if err != nil {
+		return "", postStartHooks, err
+	}
+
+	// Check the prerequisites of the nesting profile
+	err = containerNestingCheck(c)
+	if err != nil {
+		nestingErr, ok := err.(containerNestingError)
+		if ok && c.op != nil {
+			meta := c.op.metadata
+			if meta == nil {
+				meta = make(map[string]interface{})
+			}
+
+			meta["nesting_missing"] = nestingErr.missing
+			c.op.UpdateMetadata(meta)
+		}
+
+		return "", postStartHooks, err
+	}
+
 	// Load any required kernel modules
 	kernelModules := c.expandedConfig["linux.kernel_modules"]
 	if kernelModules != "" {
@@ -2460,66 +3072,25 @@ func (c *containerLXC) startCommon() (string, []func() error, error) {
 		return "", postStartHooks, errors.Wrap(err, "Set last ID map")
 	}
 
-	if !nextIdmap.Equals(diskIdmap) && !(diskIdmap == nil && c.state.OS.Shiftfs) {
+	if !nextIdmap.Equals(diskIdmap) && !(diskIdmap == nil && c.shiftsAtMount()) {
 		if shared.IsTrue(c.expandedConfig["security.protection.shift"]) {
 			return "", postStartHooks, fmt.Errorf("Container is protected against filesystem shifting")
 		}
 
 		logger.Debugf("Container idmap changed, remapping")
-		c.updateProgress("Remapping container filesystem")
 
 		ourStart, err = c.StorageStart()
 		if err != nil {
 			return "", postStartHooks, errors.Wrap(err, "Storage start")
 		}
 
-		if diskIdmap != nil {
-			if c.Storage().GetStorageType() == storageTypeZfs {
-				err = diskIdmap.UnshiftRootfs(c.RootfsPath(), zfsIdmapSetSkipper)
-			} else if c.Storage().GetStorageType() == storageTypeBtrfs {
-				err = UnshiftBtrfsRootfs(c.RootfsPath(), diskIdmap)
-			} else {
-				err = diskIdmap.UnshiftRootfs(c.RootfsPath(), nil)
-			}
-			if err != nil {
-				if ourStart {
-					c.StorageStop()
-				}
-				return "", postStartHooks, err
-			}
-		}
-
-		if nextIdmap != nil && !c.state.OS.Shiftfs {
-			if c.Storage().GetStorageType() == storageTypeZfs {
-				err = nextIdmap.ShiftRootfs(c.RootfsPath(), zfsIdmapSetSkipper)
-			} else if c.Storage().GetStorageType() == storageTypeBtrfs {
-				err = ShiftBtrfsRootfs(c.RootfsPath(), nextIdmap)
-			} else {
-				err = nextIdmap.ShiftRootfs(c.RootfsPath(), nil)
-			}
-			if err != nil {
-				if ourStart {
-					c.StorageStop()
-				}
-				return "", postStartHooks, err
-			}
-		}
-
-		jsonDiskIdmap := "[]"
-		if nextIdmap != nil && !c.state.OS.Shiftfs {
-			idmapBytes, err := json.Marshal(nextIdmap.Idmap)
-			if err != nil {
-				return "", postStartHooks, err
-			}
-			jsonDiskIdmap = string(idmapBytes)
-		}
-
-		err = c.VolatileSet(map[string]string{"volatile.last_state.idmap": jsonDiskIdmap})
+		err = c.remapRootfs(diskIdmap, nextIdmap)
 		if err != nil {
-			return "", postStartHooks, errors.Wrapf(err, "Set volatile.last_state.idmap config key on container %q (id %d)", c.name, c.id)
+			if ourStart {
+				c.StorageStop()
+			}
+			return "", postStartHooks, err
 		}
-
-		c.updateProgress("")
 	}
 
 	var idmapBytes []byte
@@ -2549,10 +3120,18 @@ func (c *containerLXC) startCommon() (string, []func() error, error) {
 	c.removeDiskDevices()
 
 	var usbs []usbDevice
+	var hotplugs []hotplugDevice
 	diskDevices := map[string]config.Device{}
 
+	// Load the network index of the NICs
+	nicIndexes, err := c.nicIndexes()
+	if err != nil {
+		return "", postStartHooks, errors.Wrap(err, "Failed to allocate the NIC indexes")
+	}
+
+	nicConfigs := map[int][]device.RunConfigItem{}
+
 	// Create the devices
-	nicID := -1
 	for _, k := range c.expandedDevices.DeviceNames() {
 		m := c.expandedDevices[k]
 		if shared.StringInSlice(m["type"], []string{"unix-char", "unix-block"}) {
@@ -2569,17 +3148,7 @@ func (c *containerLXC) startCommon() (string, []func() error, error) {
 					return "", postStartHooks, err
 				}
 
-				srcPath := m["source"]
-				if srcPath == "" {
-					srcPath = m["path"]
-				}
-				srcPath = shared.HostPath(srcPath)
-
-				err = deviceInotifyAddClosestLivingAncestor(c.state, srcPath)
-				if err != nil {
-					logger.Errorf("Failed to add \"%s\" to inotify targets", srcPath)
-					return "", postStartHooks, err
-				}
+				// The device was registered with the inotify watcher above
 				continue
 			}
 			devPath := d.HostPath
@@ -2591,7 +3160,7 @@ func (c *containerLXC) startCommon() (string, []func() error, error) {
 						return "", postStartHooks, err
 					}
 				} else {
-					err = lxcSetConfigItem(c.c, "lxc.cgroup.devices.allow", fmt.Sprintf("%s %d:%d rwm", dType, dMajor, dMinor))
+					err = c.cgroupConfigSet(c.c, "devices.allow", fmt.Sprintf("%s %d:%d rwm", dType, dMajor, dMinor))
 					if err != nil {
 						return "", postStartHooks, fmt.Errorf("Failed to add cgroup rule for device")
 					}
@@ -2615,6 +3184,26 @@ func (c *containerLXC) startCommon() (string, []func() error, error) {
 					return "", postStartHooks, err
 				}
 			}
+		} else if m["type"] == "hotplug" {
+			hotplugIndexAdd(c.id, k)
+
+			if hotplugs == nil {
+				hotplugs, err = deviceLoadHotplug()
+				if err != nil {
+					return "", postStartHooks, err
+				}
+			}
+
+			for _, dev := range hotplugs {
+				if !hotplugDeviceMatch(m, dev) {
+					continue
+				}
+
+				err := c.setupUnixDevice(fmt.Sprintf("unix.%s", k), hotplugDeviceConfig(m, dev), dev.major, dev.minor, dev.path, false, false)
+				if err != nil {
+					return "", postStartHooks, err
+				}
+			}
 		} else if m["type"] == "disk" {
 			if m["path"] != "/" {
 				diskDevices[k] = m
@@ -2630,7 +3219,7 @@ func (c *containerLXC) startCommon() (string, []func() error, error) {
 				// Pass any cgroups rules into LXC.
 				if len(runConfig.CGroups) > 0 {
 					for _, rule := range runConfig.CGroups {
-						err = lxcSetConfigItem(c.c, fmt.Sprintf("lxc.cgroup.%s", rule.Key), rule.Value)
+						err = c.cgroupConfigSet(c.c, rule.Key, rule.Value)
 						if err != nil {
 							return "", postStartHooks, err
 						}
@@ -2648,22 +3237,15 @@ func (c *containerLXC) startCommon() (string, []func() error, error) {
 					}
 				}
 
-				// Pass any network setup config into LXC.
+				// Keep any network setup config, followed by the raw
+				// liblxc options of the device, for its network index.
 				if len(runConfig.NetworkInterface) > 0 {
-					// Increment nicID so that LXC network index is unique per device.
-					nicID++
-
-					networkKeyPrefix := "lxc.net"
-					if !util.RuntimeLiblxcVersionAtLeast(2, 1, 0) {
-						networkKeyPrefix = "lxc.network"
+					rawItems, err := device.NICRawLXCConfig(m["raw.lxc"])
+					if err != nil {
+						return "", postStartHooks, errors.Wrapf(err, "Failed to start device '%s'", k)
 					}
 
-					for _, dev := range runConfig.NetworkInterface {
-						err = lxcSetConfigItem(c.c, fmt.Sprintf("%s.%d.%s", networkKeyPrefix, nicID, dev.Key), dev.Value)
-						if err != nil {
-							return "", postStartHooks, err
-						}
-					}
+					nicConfigs[nicIndexes[k]] = append(runConfig.NetworkInterface, rawItems...)
 				}
 
 				// Add any post start hooks.
@@ -2676,6 +3258,28 @@ func (c *containerLXC) startCommon() (string, []func() error, error) {
 		}
 	}
 
+	// Pass the network setup config into LXC, ordered by network index as
+	// liblxc creates the interfaces in the order they're first configured.
+	networkKeyPrefix := "lxc.net"
+	if !util.RuntimeLiblxcVersionAtLeast(2, 1, 0) {
+		networkKeyPrefix = "lxc.network"
+	}
+
+	nicIDs := []int{}
+	for nicID := range nicConfigs {
+		nicIDs = append(nicIDs, nicID)
+	}
+	sort.Ints(nicIDs)
+
+	for _, nicID := range nicIDs {
+		for _, dev := range nicConfigs[nicID] {
+			err = lxcSetConfigItem(c.c, fmt.Sprintf("%s.%d.%s", networkKeyPrefix, nicID, dev.Key), dev.Value)
+			if err != nil {
+				return "", postStartHooks, err
+			}
+		}
+	}
+
 	err = c.addDiskDevices(diskDevices, func(name string, d config.Device) error {
 		_, err := c.createDiskDevice(name, d)
 		return err
@@ -2716,6 +3320,34 @@ func (c *containerLXC) startCommon() (string, []func() error, error) {
 		return "", postStartHooks, err
 	}
 
+	// Setup the extra mounts now that their sources can be resolved
+	err = c.setupExtraMounts()
+	if err != nil {
+		if ourStart {
+			c.StorageStop()
+		}
+		return "", postStartHooks, err
+	}
+
+	// Pass the secrets from a file of their own
+	secretsPath, err := containerSecretsSetup(c.state, c)
+	if err != nil {
+		if ourStart {
+			c.StorageStop()
+		}
+		return "", postStartHooks, errors.Wrap(err, "Setup secrets")
+	}
+
+	if secretsPath != "" {
+		err = lxcSetConfigItem(c.c, "lxc.include", secretsPath)
+		if err != nil {
+			if ourStart {
+				c.StorageStop()
+			}
+			return "", postStartHooks, err
+		}
+	}
+
 	// Generate the LXC config
 	configPath := filepath.Join(c.LogPath(), "lxc.conf")
 	err = c.c.SaveConfigFile(configPath)
@@ -2796,21 +3428,41 @@ func (c *containerLXC) detachInterfaceRename(netns string, ifName string, hostNa
 func (c *containerLXC) Start(stateful bool) error {
 	var ctxMap log.Ctx
 
-	// Setup a new operation
-	op, err := c.createOperation("start", false, false)
+	// Refuse to start containers in maintenance
+	err := containerProtectionStartCheck(c)
+	if err != nil {
+		return err
+	}
+
+	// Setup a new operation, restoring a stateful stop taking its own timeout
+	action := "start"
+	if stateful {
+		action = "restore"
+	}
+
+	op, err := c.createOperation(action, false, false)
 	if err != nil {
 		return errors.Wrap(err, "Create container start operation")
 	}
 	defer op.Done(nil)
 
+	emergencyShutdownOver()
+
 	err = setupSharedMounts()
 	if err != nil {
 		return fmt.Errorf("Daemon failed to setup shared mounts base: %s.\nDoes security.nesting need to be turned on?", err)
 	}
 
+	// Run the user pre-start hook, which can prevent the start
+	err = containerHookRun(c, "pre-start")
+	if err != nil {
+		return err
+	}
+
 	// Run the shared start code
 	configPath, postStartHooks, err := c.startCommon()
 	if err != nil {
+		containerStartErrorRecord(c, err)
 		return errors.Wrap(err, "Common start logic")
 	}
 
@@ -2843,7 +3495,7 @@ func (c *containerLXC) Start(stateful bool) error {
 			function:     "snapshot",
 			stop:         false,
 			actionScript: false,
-			dumpDir:      "",
+			dumpDir:      containerCheckpointDumpDir(c.StatePath()),
 			preDumpDir:   "",
 		}
 
@@ -2870,7 +3522,10 @@ func (c *containerLXC) Start(stateful bool) error {
 			return err
 		}
 
+		containerHookLog(c, "post-start")
+
 		logger.Info("Started container", ctxMap)
+		containerResumed(c, c.op, time.Time{})
 		return nil
 	} else if c.stateful {
 		/* stateless start required when we have state, let's delete it */
@@ -2889,39 +3544,15 @@ func (c *containerLXC) Start(stateful bool) error {
 	name := project.Prefix(c.Project(), c.name)
 
 	// Start the LXC container
-	_, err = shared.RunCommand(
-		c.state.OS.ExecPath,
-		"forkstart",
-		name,
-		c.state.OS.LxcPath,
-		configPath)
-	if err != nil && !c.IsRunning() {
-		// Attempt to extract the LXC errors
-		lxcLog := ""
-		logPath := filepath.Join(c.LogPath(), "lxc.log")
-		if shared.PathExists(logPath) {
-			logContent, err := ioutil.ReadFile(logPath)
-			if err == nil {
-				for _, line := range strings.Split(string(logContent), "\n") {
-					fields := strings.Fields(line)
-					if len(fields) < 4 {
-						continue
-					}
-
-					// We only care about errors
-					if fields[2] != "ERROR" {
-						continue
-					}
-
-					// Prepend the line break
-					if len(lxcLog) == 0 {
-						lxcLog += "\n"
-					}
+	startArgs := []string{"forkstart", name, c.state.OS.LxcPath, configPath}
+	if shared.IsTrue(c.expandedConfig["security.cpu.core_scheduling"]) {
+		startArgs = append(startArgs, "--core-scheduling")
+	}
 
-					lxcLog += fmt.Sprintf("  %s\n", strings.Join(fields[0:], " "))
-				}
-			}
-		}
+	_, err = shared.RunCommand(c.state.OS.ExecPath, startArgs...)
+	if err != nil && !c.IsRunning() {
+		// Keep the error along with the LXC errors for later debugging
+		containerStartErrorRecord(c, err)
 
 		logger.Error("Failed starting container", ctxMap)
 
@@ -2938,6 +3569,22 @@ func (c *containerLXC) Start(stateful bool) error {
 		return err
 	}
 
+	// Monitor the software watchdogs
+	containerWatchdogsStop(c)
+	containerWatchdogsSync(c)
+
+	// Watch the out of memory events
+	containerOOMWatchStart(c)
+
+	// Send its console output to the listeners of console events
+	eventsConsoleStart(c)
+
+	// Forget any previous failure to start
+	containerStartErrorClear(c)
+
+	// Run the user post-start hook
+	containerHookLog(c, "post-start")
+
 	logger.Info("Started container", ctxMap)
 	eventSendLifecycle(c.project, "container-started",
 		fmt.Sprintf("/1.0/containers/%s", c.name), nil)
@@ -2999,6 +3646,7 @@ func (c *containerLXC) OnStart() error {
 
 	// Trigger a rebalance
 	deviceTaskSchedulerTrigger("container", c.name, "started")
+	loadBalancersTrigger()
 
 	// Apply network priority
 	if c.expandedConfig["limits.network.priority"] != "" {
@@ -3011,6 +3659,14 @@ func (c *containerLXC) OnStart() error {
 		}(c)
 	}
 
+	// Apply connection tracking limit
+	if c.expandedConfig["limits.network.conntrack"] != "" {
+		err = c.setNetworkConntrack()
+		if err != nil {
+			logger.Error("Failed to apply connection tracking limit", log.Ctx{"container": c.name, "err": err})
+		}
+	}
+
 	// Database updates
 	err = c.state.Cluster.Transaction(func(tx *db.ClusterTx) error {
 		// Record current state
@@ -3060,6 +3716,9 @@ func (c *containerLXC) Stop(stateful bool) error {
 
 	logger.Info("Stopping container", ctxMap)
 
+	// Run the user pre-stop hook
+	containerHookLog(c, "pre-stop")
+
 	// Handle stateful stop
 	if stateful {
 		// Cleanup any existing state
@@ -3073,18 +3732,8 @@ func (c *containerLXC) Stop(stateful bool) error {
 			return err
 		}
 
-		criuMigrationArgs := CriuMigrationArgs{
-			cmd:          lxc.MIGRATE_DUMP,
-			stateDir:     stateDir,
-			function:     "snapshot",
-			stop:         true,
-			actionScript: false,
-			dumpDir:      "",
-			preDumpDir:   "",
-		}
-
-		// Checkpoint
-		err = c.Migrate(&criuMigrationArgs)
+		// Checkpoint, keeping the operation alive through the pre-dumps
+		err = containerCheckpoint(c, stateDir, true, func() { op.Reset() })
 		if err != nil {
 			op.Done(err)
 			logger.Error("Failed stopping container", ctxMap)
@@ -3105,6 +3754,8 @@ func (c *containerLXC) Stop(stateful bool) error {
 			return err
 		}
 
+		containerSuspended(c)
+
 		op.Done(nil)
 		logger.Info("Stopped container", ctxMap)
 		eventSendLifecycle(c.project, "container-stopped",
@@ -3185,6 +3836,9 @@ func (c *containerLXC) Shutdown(timeout time.Duration) error {
 
 	logger.Info("Shutting down container", ctxMap)
 
+	// Run the user pre-stop hook
+	containerHookLog(c, "pre-stop")
+
 	// Load the go-lxc struct
 	err = c.initLXC(false)
 	if err != nil {
@@ -3221,12 +3875,56 @@ func (c *containerLXC) OnStopNS(target string, netns string) error {
 		return fmt.Errorf("Invalid stop target: %s", target)
 	}
 
+	// Save the firewall rules while the network namespace is still around
+	if shared.IsTrue(c.expandedConfig["network.firewall.persist"]) && netns != "" {
+		err := c.firewallSave(netns)
+		if err != nil {
+			logger.Error("Failed to save the container firewall rules", log.Ctx{"container": c.Name(), "err": err})
+		}
+	}
+
 	// Clean up devices.
 	c.cleanupDevices(netns)
 
 	return nil
 }
 
+// OnStartHost is triggered by LXC's start-host hook once the container's namespaces are setup
+// and before its init is started.
+func (c *containerLXC) OnStartHost(pid int) error {
+	// Restore the firewall rules saved when the container last stopped
+	if shared.IsTrue(c.expandedConfig["network.firewall.persist"]) && shared.PathExists(c.firewallRulesPath()) {
+		_, err := shared.RunCommand(c.state.OS.ExecPath, "forknet", "firewall-restore", fmt.Sprintf("%d", pid), c.firewallRulesPath())
+		if err != nil {
+			return errors.Wrap(err, "Failed to restore the firewall rules")
+		}
+	}
+
+	// Mount the hugepages backed disks now that the init of the container
+	// is in its cgroups, the mounts propagating into the container
+	err := c.mountHugepagesDisks(pid)
+	if err != nil {
+		return errors.Wrap(err, "Failed to mount the hugepages backed disks")
+	}
+
+	return nil
+}
+
+// firewallRulesPath returns the path of the firewall rules saved for the container.
+func (c *containerLXC) firewallRulesPath() string {
+	return filepath.Join(c.Path(), "firewall.json")
+}
+
+// firewallSave saves the firewall rules of the network namespace of the container.
+func (c *containerLXC) firewallSave(netns string) error {
+	out, err := shared.RunCommand(c.state.OS.ExecPath, "forknet", "firewall-save", netns)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(c.firewallRulesPath(), []byte(out), 0600)
+}
+
 // OnStop is triggered by LXC's post-stop hook once a container is shutdown and after the
 // container's namespaces have been closed.
 func (c *containerLXC) OnStop(target string) error {
@@ -3293,6 +3991,28 @@ func (c *containerLXC) OnStop(target string) error {
 			logger.Error("Failed to destroy apparmor namespace", log.Ctx{"container": c.Name(), "err": err})
 		}
 
+		// Stop monitoring the software watchdogs
+		containerWatchdogsStop(c)
+
+		// Stop watching the out of memory events
+		containerOOMWatchStop(c)
+
+		// Stop sending its console output
+		eventsConsoleStop(c)
+
+		// Remove the secrets passed to it
+		err = containerSecretsRemove(c)
+		if err != nil {
+			logger.Error("Unable to remove secrets", log.Ctx{"container": c.Name(), "err": err})
+		}
+
+		// Forget the cgroup directories used to render the state
+		containerCGroupDirsForget(c.id)
+
+		// Stop waiting for hotplugged unix devices
+		deviceInotifyDelDevice(c.state, c.id, "")
+		hotplugIndexDel(c.id, "")
+
 		// Clean all the unix devices
 		err = c.removeUnixDevices()
 		if err != nil {
@@ -3305,8 +4025,24 @@ func (c *containerLXC) OnStop(target string) error {
 			logger.Error("Unable to remove disk devices", log.Ctx{"container": c.Name(), "err": err})
 		}
 
+		// Remove the connection tracking limits
+		err = c.removeNetworkConntrack()
+		if err != nil {
+			logger.Error("Unable to remove connection tracking limits", log.Ctx{"container": c.Name(), "err": err})
+		}
+
+		// Run the user post-stop hook
+		containerHookLog(c, "post-stop")
+
 		// Reboot the container
 		if target == "reboot" {
+			// Leave containers in maintenance stopped
+			err = containerProtectionStartCheck(c)
+			if err != nil {
+				logger.Warn("Not restarting rebooted container", log.Ctx{"container": c.Name(), "project": c.Project(), "err": err})
+				return
+			}
+
 			// Start the container again
 			err = c.Start(false)
 			return
@@ -3314,6 +4050,7 @@ func (c *containerLXC) OnStop(target string) error {
 
 		// Trigger a rebalance
 		deviceTaskSchedulerTrigger("container", c.name, "stopped")
+		loadBalancersTrigger()
 
 		// Destroy ephemeral containers
 		if c.ephemeral {
@@ -3381,6 +4118,9 @@ func (c *containerLXC) Freeze() error {
 		return err
 	}
 
+	containerSuspended(c)
+	containerFreezeTimeoutClear(c)
+
 	logger.Info("Froze container", ctxMap)
 	eventSendLifecycle(c.project, "container-paused",
 		fmt.Sprintf("/1.0/containers/%s", c.name), nil)
@@ -3424,12 +4164,17 @@ func (c *containerLXC) Unfreeze() error {
 	err = c.c.Unfreeze()
 	if err != nil {
 		logger.Error("Failed unfreezing container", ctxMap)
+		return err
 	}
 
+	containerFreezeTimeoutClear(c)
+
 	logger.Info("Unfroze container", ctxMap)
 	eventSendLifecycle(c.project, "container-resumed",
 		fmt.Sprintf("/1.0/containers/%s", c.name), nil)
 
+	containerResumed(c, c.op, time.Time{})
+
 	return err
 }
 
@@ -3517,6 +4262,11 @@ func (c *containerLXC) Render() (interface{}, interface{}, error) {
 	ct.Profiles = c.profiles
 	ct.Stateful = c.stateful
 
+	ct.Activity, err = containerActivityRender(c, c.activity)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Get container activity")
+	}
+
 	return &ct, etag, nil
 }
 
@@ -3541,9 +4291,12 @@ func (c *containerLXC) RenderFull() (*api.ContainerFull, interface{}, error) {
 	}
 
 	// Add the ContainerSnapshots
-	snaps, err := c.Snapshots()
-	if err != nil {
-		return nil, nil, err
+	snaps := c.snapshots
+	if snaps == nil {
+		snaps, err = c.Snapshots()
+		if err != nil {
+			return nil, nil, err
+		}
 	}
 
 	for _, snap := range snaps {
@@ -3560,9 +4313,12 @@ func (c *containerLXC) RenderFull() (*api.ContainerFull, interface{}, error) {
 	}
 
 	// Add the ContainerBackups
-	backups, err := c.Backups()
-	if err != nil {
-		return nil, nil, err
+	backups := c.backups
+	if backups == nil {
+		backups, err = c.Backups()
+		if err != nil {
+			return nil, nil, err
+		}
 	}
 
 	for _, backup := range backups {
@@ -3589,14 +4345,24 @@ func (c *containerLXC) RenderState() (*api.ContainerState, error) {
 		StatusCode: statusCode,
 	}
 
+	// The reason it last failed to start, until it starts again
+	status.StartError = containerStartErrorState(c.localConfig)
+
 	if c.IsRunning() {
 		pid := c.InitPID()
-		status.CPU = c.cpuState()
-		status.Disk = c.diskState()
-		status.Memory = c.memoryState()
+		cg := newContainerCGroupState(c)
+		status.CPU = c.cpuState(cg)
+		status.Disk = c.diskState(cg)
+		status.Memory = c.memoryState(cg)
 		status.Network = c.networkState()
 		status.Pid = int64(pid)
-		status.Processes = c.processesState()
+		status.Processes = c.processesState(cg)
+		status.ExecSessions = execSessionCount(c)
+
+		cookie, err := coreSchedulingCookie(pid)
+		if err == nil {
+			status.CoreScheduling = cookie != 0
+		}
 	}
 
 	return &status, nil
@@ -3630,23 +4396,32 @@ func (c *containerLXC) Snapshots() ([]container, error) {
 
 func (c *containerLXC) Backups() ([]backup, error) {
 	// Get all the backups
-	backupNames, err := c.state.Cluster.ContainerGetBackups(c.project, c.name)
+	backupArgs, err := c.state.Cluster.ContainerGetBackupsFull(c.project, c.name)
 	if err != nil {
 		return nil, err
 	}
 
-	// Build the backup list
-	backups := []backup{}
-	for _, backupName := range backupNames {
-		backup, err := backupLoadByName(c.state, c.project, backupName)
-		if err != nil {
-			return nil, err
-		}
+	return c.backupsFromArgs(backupArgs), nil
+}
 
-		backups = append(backups, *backup)
+// backupsFromArgs builds the backups of the container from their database
+// records.
+func (c *containerLXC) backupsFromArgs(backupArgs []db.ContainerBackupArgs) []backup {
+	backups := []backup{}
+	for _, args := range backupArgs {
+		backups = append(backups, backup{
+			state:            c.state,
+			container:        c,
+			id:               args.ID,
+			name:             args.Name,
+			creationDate:     args.CreationDate,
+			expiryDate:       args.ExpiryDate,
+			containerOnly:    args.ContainerOnly,
+			optimizedStorage: args.OptimizedStorage,
+		})
 	}
 
-	return backups, nil
+	return backups
 }
 
 func (c *containerLXC) Restore(sourceContainer container, stateful bool) error {
@@ -3739,7 +4514,8 @@ func (c *containerLXC) Restore(sourceContainer container, stateful bool) error {
 		return err
 	}
 
-	// Restore the configuration
+	// Restore the configuration, whose restricted keys were set by an
+	// administrator
 	args := db.ContainerArgs{
 		Architecture: sourceContainer.Architecture(),
 		Config:       sourceContainer.LocalConfig(),
@@ -3748,6 +4524,7 @@ func (c *containerLXC) Restore(sourceContainer container, stateful bool) error {
 		Ephemeral:    sourceContainer.IsEphemeral(),
 		Profiles:     sourceContainer.Profiles(),
 		Project:      sourceContainer.Project(),
+		Admin:        true,
 	}
 
 	err = c.Update(args, false)
@@ -3780,7 +4557,7 @@ func (c *containerLXC) Restore(sourceContainer container, stateful bool) error {
 			function:     "snapshot",
 			stop:         false,
 			actionScript: false,
-			dumpDir:      "",
+			dumpDir:      containerCheckpointDumpDir(c.StatePath()),
 			preDumpDir:   "",
 		}
 
@@ -3804,6 +4581,7 @@ func (c *containerLXC) Restore(sourceContainer container, stateful bool) error {
 
 		logger.Debug("Performed stateful restore", ctxMap)
 		logger.Info("Restored container", ctxMap)
+		containerResumed(c, c.op, sourceContainer.CreationDate())
 		return nil
 	}
 
@@ -3823,6 +4601,93 @@ func (c *containerLXC) Restore(sourceContainer container, stateful bool) error {
 	return nil
 }
 
+// Rebuild replaces the root filesystem of a stopped container with the one of
+// the given image. Its configuration, devices, MAC addresses and idmap are
+// kept, only the "image.*" keys being replaced by those of the new image.
+func (c *containerLXC) Rebuild(fingerprint string) error {
+	if c.IsRunning() {
+		return fmt.Errorf("The container must be stopped to be rebuilt")
+	}
+
+	_, img, err := c.state.Cluster.ImageGet(c.project, fingerprint, false, false)
+	if err != nil {
+		return errors.Wrapf(err, "Fetch image %s from database", fingerprint)
+	}
+
+	arch, err := osarch.ArchitectureId(img.Architecture)
+	if err != nil {
+		return err
+	}
+
+	if arch != c.architecture {
+		return fmt.Errorf("The image architecture (%s) doesn't match the container's", img.Architecture)
+	}
+
+	ctxMap := log.Ctx{
+		"project":   c.project,
+		"name":      c.name,
+		"image":     fingerprint,
+		"old_image": c.localConfig["volatile.base_image"]}
+
+	logger.Info("Rebuilding container", ctxMap)
+
+	err = c.initStorage()
+	if err != nil {
+		return err
+	}
+
+	err = containerRootfsReplace(c, fingerprint)
+	if err != nil {
+		return err
+	}
+
+	// Point the container to its new image. The new files are unshifted,
+	// which makes the next start shift them to the kept idmap.
+	args := db.ContainerArgs{
+		Architecture: c.architecture,
+		Config:       map[string]string{},
+		Description:  c.description,
+		Devices:      c.localDevices,
+		Ephemeral:    c.ephemeral,
+		Profiles:     c.profiles,
+		Project:      c.project,
+	}
+
+	for k, v := range c.localConfig {
+		if strings.HasPrefix(k, "image.") {
+			continue
+		}
+
+		args.Config[k] = v
+	}
+
+	for k, v := range img.Properties {
+		args.Config[fmt.Sprintf("image.%s", k)] = v
+	}
+
+	args.Config["volatile.base_image"] = fingerprint
+	args.Config["volatile.last_state.idmap"] = "[]"
+
+	err = c.Update(args, false)
+	if err != nil {
+		return errors.Wrap(err, "Update container configuration")
+	}
+
+	err = c.state.Cluster.ImageLastAccessUpdate(fingerprint, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	eventSendLifecycle(c.project, "container-rebuilt",
+		fmt.Sprintf("/1.0/containers/%s", c.name), map[string]interface{}{
+			"image": fingerprint,
+		})
+
+	logger.Info("Rebuilt container", ctxMap)
+
+	return nil
+}
+
 func (c *containerLXC) cleanup() {
 	// Unmount any leftovers
 	c.removeUnixDevices()
@@ -3832,6 +4697,9 @@ func (c *containerLXC) cleanup() {
 	AADeleteProfile(c)
 	SeccompDeleteProfile(c)
 
+	// Remove the secrets
+	containerSecretsRemove(c)
+
 	// Remove the devices path
 	os.Remove(c.DevicesPath())
 
@@ -3881,7 +4749,9 @@ func (c *containerLXC) Delete() error {
 	if c.IsSnapshot() {
 		// Remove the snapshot
 		if c.storage != nil && !isImport {
+			release := storagePoolOperationStart(c.storage, c)
 			err := c.storage.ContainerSnapshotDelete(c)
+			release()
 			if err != nil {
 				logger.Warn("Failed to delete snapshot", log.Ctx{"name": c.Name(), "err": err})
 				return err
@@ -3917,7 +4787,9 @@ func (c *containerLXC) Delete() error {
 			containerMountPoint := getContainerMountPoint(c.Project(), poolName, c.Name())
 			if shared.PathExists(c.Path()) ||
 				shared.PathExists(containerMountPoint) {
+				release := storagePoolOperationStart(c.storage, c)
 				err := c.storage.ContainerDelete(c)
+				release()
 				if err != nil {
 					logger.Error("Failed deleting container storage", log.Ctx{"name": c.Name(), "err": err})
 					return err
@@ -3925,11 +4797,10 @@ func (c *containerLXC) Delete() error {
 			}
 		}
 
-		// Delete the MAAS entry
+		// Delete the MAAS entry, retrying in the background if it's unavailable
 		err = c.maasDelete()
 		if err != nil {
-			logger.Error("Failed deleting container MAAS record", log.Ctx{"name": c.Name(), "err": err})
-			return err
+			maasSyncQueueAdd(project.Prefix(c.project, c.name), c.project, c.name, "delete", err)
 		}
 
 		// Remove devices from container.
@@ -3938,7 +4809,17 @@ func (c *containerLXC) Delete() error {
 			if err != nil && err != device.ErrUnsupportedDevType {
 				return errors.Wrapf(err, "Failed to remove device '%s'", k)
 			}
+
+			// Lift the size quota of directories
+			if m["type"] == "disk" && m["path"] != "/" && m["pool"] == "" && m["backing"] == "" && m["size"] != "" {
+				err = c.clearDiskDeviceQuota(m)
+				if err != nil {
+					logger.Warn("Failed to lift disk quota", log.Ctx{"name": c.Name(), "device": k, "err": err})
+				}
+			}
 		}
+
+		containerDevicesLogRemove(c)
 	}
 
 	// Remove the database record
@@ -4003,14 +4884,21 @@ func (c *containerLXC) Rename(newName string) error {
 		return fmt.Errorf("Renaming of running container not allowed")
 	}
 
+	// Don't overwrite the persistent state of devices such as TPMs left behind
+	newDevicesPath := shared.VarPath("devices", project.Prefix(c.Project(), newName))
+	if !c.IsSnapshot() && shared.PathExists(newDevicesPath) {
+		return fmt.Errorf("Devices path %q already exists", newDevicesPath)
+	}
+
 	// Clean things up
 	c.cleanup()
 
-	// Rename the MAAS entry
+	// Rename the MAAS entry, retrying in the background if it's unavailable
 	if !c.IsSnapshot() {
 		err = c.maasRename(newName)
 		if err != nil {
-			return err
+			maasSyncQueueAdd(project.Prefix(c.project, oldName), c.project, oldName, "delete", err)
+			maasSyncQueueAdd(project.Prefix(c.project, newName), c.project, newName, "update", err)
 		}
 	}
 
@@ -4024,6 +4912,18 @@ func (c *containerLXC) Rename(newName string) error {
 		}
 	}
 
+	// Rename the devices path, holding the persistent state of devices such as TPMs
+	devicesRenamed := false
+	if !c.IsSnapshot() && shared.PathExists(c.DevicesPath()) {
+		err := os.Rename(c.DevicesPath(), newDevicesPath)
+		if err != nil {
+			logger.Error("Failed renaming container", ctxMap)
+			return err
+		}
+
+		devicesRenamed = true
+	}
+
 	// Rename the storage entry
 	if c.IsSnapshot() {
 		err := c.storage.ContainerSnapshotRename(c, newName)
@@ -4035,6 +4935,12 @@ func (c *containerLXC) Rename(newName string) error {
 		err := c.storage.ContainerRename(c, newName)
 		if err != nil {
 			logger.Error("Failed renaming container", ctxMap)
+
+			// Keep the devices state with the container
+			if devicesRenamed {
+				os.Rename(newDevicesPath, c.DevicesPath())
+			}
+
 			return err
 		}
 	}
@@ -4137,6 +5043,32 @@ func (c *containerLXC) Rename(newName string) error {
 	return nil
 }
 
+// cgroupConfigSet sets a cgroup key in the LXC configuration, using its
+// unified hierarchy equivalent on hosts only having that one.
+func (c *containerLXC) cgroupConfigSet(cc *lxc.Container, key string, value string) error {
+	if !c.state.OS.CGroupV2 {
+		return lxcSetConfigItem(cc, fmt.Sprintf("lxc.cgroup.%s", key), value)
+	}
+
+	key, value, err := cGroupV2Translate(c.state.OS, key, value, func(key string) (string, error) {
+		value := cc.ConfigItem(fmt.Sprintf("lxc.cgroup2.%s", key))
+		if len(value) == 0 || value[0] == "" {
+			return "max", nil
+		}
+
+		return value[0], nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if key == "" {
+		return nil
+	}
+
+	return lxcSetConfigItem(cc, fmt.Sprintf("lxc.cgroup2.%s", key), value)
+}
+
 func (c *containerLXC) CGroupGet(key string) (string, error) {
 	// Load the go-lxc struct
 	err := c.initLXC(false)
@@ -4149,6 +5081,17 @@ func (c *containerLXC) CGroupGet(key string) (string, error) {
 		return "", fmt.Errorf("Can't get cgroups on a stopped container")
 	}
 
+	if c.state.OS.CGroupV2 {
+		return cGroupV2Read(key, func(key string) (string, error) {
+			value := c.c.CgroupItem(key)
+			if len(value) == 0 {
+				return "", fmt.Errorf("Failed to get cgroup %s", key)
+			}
+
+			return strings.Join(value, "\n"), nil
+		})
+	}
+
 	value := c.c.CgroupItem(key)
 	return strings.Join(value, "\n"), nil
 }
@@ -4165,6 +5108,24 @@ func (c *containerLXC) CGroupSet(key string, value string) error {
 		return fmt.Errorf("Can't set cgroups on a stopped container")
 	}
 
+	if c.state.OS.CGroupV2 {
+		key, value, err = cGroupV2Translate(c.state.OS, key, value, func(key string) (string, error) {
+			value := c.c.CgroupItem(key)
+			if len(value) == 0 {
+				return "", fmt.Errorf("Failed to get cgroup %s", key)
+			}
+
+			return value[0], nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if key == "" {
+			return nil
+		}
+	}
+
 	err = c.c.SetCgroupItem(key, value)
 	if err != nil {
 		return fmt.Errorf("Failed to set cgroup %s=\"%s\": %s", key, value, err)
@@ -4319,13 +5280,41 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 	}
 
 	// Validate the new config
-	err := containerValidConfig(c.state.OS, args.Config, false, false)
+	err := containerValidConfig(c.state.OS, args.Config, false, false, nil)
 	if err != nil {
 		return errors.Wrap(err, "Invalid config")
 	}
 
+	// Some keys run commands on the host, only administrators may change them
+	if !args.Admin {
+		err = containerConfigCheckAdminKeys(c.localConfig, args.Config)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Only check the changed keys against the protected keys of the project,
+	// so that existing overrides don't prevent unrelated updates
+	projConfig, err := projectConfig(c.state.Cluster, c.project)
+	if err != nil {
+		return err
+	}
+
+	changedLocalConfig := map[string]string{}
+	for k, v := range args.Config {
+		oldValue, ok := c.localConfig[k]
+		if !ok || oldValue != v {
+			changedLocalConfig[k] = v
+		}
+	}
+
+	err = projectDefaultsCheckConfig(projConfig, changedLocalConfig)
+	if err != nil {
+		return err
+	}
+
 	// Validate the new devices
-	err = containerValidDevices(c.state, c.state.Cluster, args.Devices, false, false)
+	err = containerValidDevices(c.state, c.state.Cluster, args.Devices, false, false, nil)
 	if err != nil {
 		return errors.Wrap(err, "Invalid devices")
 	}
@@ -4511,21 +5500,66 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 		}
 
 		// Legacy non-nic updatable fields.
-		return []string{"limits.max", "limits.read", "limits.write"}
+		updateFields := []string{"limits.max", "limits.read", "limits.write", "usage.warning", "io.threads"}
+
+		// Hugepages backed disks get mounted again to be resized
+		if oldDevice["backing"] == "" && newDevice["backing"] == "" {
+			updateFields = append(updateFields, "size")
+		}
+
+		return updateFields
 	})
 
 	// Do some validation of the config diff
-	err = containerValidConfig(c.state.OS, c.expandedConfig, false, true)
+	restrictions, err := projectRestrictions(c.state.Cluster, c.project)
+	if err != nil {
+		return errors.Wrap(err, "Failed to load project restrictions")
+	}
+
+	err = containerValidConfig(c.state.OS, c.expandedConfig, false, true, restrictions)
 	if err != nil {
 		return errors.Wrap(err, "Invalid expanded config")
 	}
 
 	// Do some validation of the devices diff
-	err = containerValidDevices(c.state, c.state.Cluster, c.expandedDevices, false, true)
+	err = containerValidDevices(c.state, c.state.Cluster, c.expandedDevices, false, true, restrictions)
 	if err != nil {
 		return errors.Wrap(err, "Invalid expanded devices")
 	}
 
+	// The new and changed devices of a running container get started, check
+	// that no other running container uses the same host devices
+	if c.IsRunning() {
+		claimDevices := config.Devices{}
+		for name, m := range addDevices {
+			claimDevices[name] = m
+		}
+
+		for name, m := range updateDevices {
+			claimDevices[name] = m
+		}
+
+		err = containerDeviceClaimsCheck(c.state, c, claimDevices)
+		if err != nil {
+			return err
+		}
+	}
+
+	// The raw liblxc options of NICs only get applied when the container
+	// starts, so they can't be given to NICs added to a running container
+	if c.IsRunning() {
+		for name, m := range addDevices {
+			if m["type"] == "nic" && m["raw.lxc"] != "" {
+				return fmt.Errorf("Device '%s': raw.lxc can't be set on a NIC added to a running container", name)
+			}
+		}
+	}
+
+	err = containerHugepagesCheckLimits(c.expandedConfig, c.expandedDevices)
+	if err != nil {
+		return errors.Wrap(err, "Invalid huge page limits")
+	}
+
 	// Run through initLXC to catch anything we missed
 	if c.c != nil {
 		c.c.Release()
@@ -4544,7 +5578,7 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 	}
 
 	// If apparmor changed, re-validate the apparmor profile
-	if shared.StringInSlice("raw.apparmor", changedConfig) || shared.StringInSlice("security.nesting", changedConfig) {
+	if shared.StringInSlice("raw.apparmor", changedConfig) || shared.StringInSlice("security.nesting", changedConfig) || shared.StringInSlice("security.apparmor.profile", changedConfig) {
 		err = AAParseProfile(c)
 		if err != nil {
 			return errors.Wrap(err, "Parse AppArmor profile")
@@ -4618,6 +5652,14 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 		return fmt.Errorf("The storage pool of the root disk can only be changed through move")
 	}
 
+	// Changes to the root disk must stick to the pools allowed in the project
+	if userRequested && !c.IsSnapshot() && !reflect.DeepEqual(oldExpandedDevices[oldRootDiskDeviceKey], c.expandedDevices[newRootDiskDeviceKey]) {
+		err = projectStoragePoolCheck(c.state.Cluster, c.project, newRootDiskDevicePool)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Deal with quota changes
 	oldRootDiskDeviceSize := oldExpandedDevices[oldRootDiskDeviceKey]["size"]
 	newRootDiskDeviceSize := c.expandedDevices[newRootDiskDeviceKey]["size"]
@@ -4625,6 +5667,19 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 	isRunning := c.IsRunning()
 	// Apply disk quota changes
 	if newRootDiskDeviceSize != oldRootDiskDeviceSize {
+		// Growing the root disk must stick to the policies of the pool
+		if userRequested && !c.IsSnapshot() {
+			_, pool, err := c.state.Cluster.StoragePoolGet(newRootDiskDevicePool)
+			if err != nil {
+				return err
+			}
+
+			err = storagePoolCommitCheck(c.state, c, c.expandedDevices[newRootDiskDeviceKey], pool)
+			if err != nil {
+				return err
+			}
+		}
+
 		storageTypeName := c.storage.GetStorageTypeName()
 		storageIsReady := c.storage.ContainerStorageReady(c)
 		if (storageTypeName == "lvm" || storageTypeName == "ceph") && isRunning || !storageIsReady {
@@ -4642,6 +5697,18 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 		}
 	}
 
+	// Lift the size quota of the directories no longer used as disks
+	for k, m := range removeDevices {
+		if m["type"] != "disk" || m["path"] == "/" || m["pool"] != "" || m["backing"] != "" || m["size"] == "" {
+			continue
+		}
+
+		err = c.clearDiskDeviceQuota(m)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to lift the size of disk device %s", k)
+		}
+	}
+
 	// Update MAAS
 	updateMAAS := false
 	for _, key := range []string{"maas.subnet.ipv4", "maas.subnet.ipv6", "ipv4.address", "ipv6.address"} {
@@ -4654,7 +5721,7 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 	if !c.IsSnapshot() && updateMAAS {
 		err = c.maasUpdate(oldExpandedDevices)
 		if err != nil {
-			return err
+			maasSyncQueueAdd(project.Prefix(c.project, c.name), c.project, c.name, "update", err)
 		}
 	}
 
@@ -4664,14 +5731,19 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 		return err
 	}
 
+	if isRunning {
+		containerWatchdogsSync(c)
+	}
+
 	// Apply the live changes
 	if isRunning {
 		// Live update the container config
 		for _, key := range changedConfig {
 			value := c.expandedConfig[key]
 
-			if key == "raw.apparmor" || key == "security.nesting" {
-				// Update the AppArmor profile
+			if key == "raw.apparmor" || key == "security.nesting" || key == "security.apparmor.profile" {
+				// Update the AppArmor profile, a new security.apparmor.profile
+				// confining the container from its next start
 				err = AALoadProfile(c)
 				if err != nil {
 					return err
@@ -4725,7 +5797,17 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 				if err != nil {
 					return err
 				}
-			} else if key == "limits.memory" || strings.HasPrefix(key, "limits.memory.") {
+			} else if key == "limits.disk.latency" {
+				// Skip if no io.latency support
+				if !c.state.OS.CGroupIOLatency {
+					continue
+				}
+
+				err = c.setDiskLatency(value)
+				if err != nil {
+					return err
+				}
+			} else if key == "limits.memory" || strings.HasPrefix(key, "limits.memory.") {
 				// Skip if no memory CGroup
 				if !c.state.OS.CGroupMemoryController {
 					continue
@@ -4885,10 +5967,64 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 				if err != nil {
 					return err
 				}
+			} else if key == "limits.network.conntrack" {
+				err := c.setNetworkConntrack()
+				if err != nil {
+					return err
+				}
+			} else if key == "limits.network.ingress" || key == "limits.network.egress" {
+				err := c.setNetworkLimits()
+				if err != nil {
+					return err
+				}
+			} else if key == "mounts.extra" {
+				err := c.updateExtraMounts(oldExpandedConfig[key], c.expandedConfig[key])
+				if err != nil {
+					return err
+				}
+			} else if strings.HasPrefix(key, "linux.sysctl.") {
+				// Unset keys keep their current value until the next
+				// restart, as do the sysctls outside of the network and
+				// IPC namespaces which would otherwise be set on the host
+				if !containerSysctlIsNamespaced(strings.TrimPrefix(key, "linux.sysctl.")) {
+					continue
+				}
+
+				err := c.setSysctl(map[string]string{key: c.expandedConfig[key]})
+				if err != nil {
+					return err
+				}
 			} else if key == "limits.cpu" {
 				// Trigger a scheduler re-run
 				deviceTaskSchedulerTrigger("container", c.name, "changed")
-			} else if key == "limits.cpu.priority" || key == "limits.cpu.allowance" {
+			} else if key == "limits.cpu.nodes" {
+				// Trigger a scheduler re-run to move the container to its new CPUs
+				deviceTaskSchedulerTrigger("container", c.name, "changed")
+
+				// Skip if no cpuset CGroup
+				if !c.state.OS.CGroupCPUsetController {
+					continue
+				}
+
+				nodes, err := c.memoryNodes()
+				if err != nil {
+					return err
+				}
+
+				mems := strings.Join(nodes, ",")
+				if mems == "" {
+					// Allow all the memory nodes of the host again
+					mems, err = cGroupGet("cpuset", "/", "cpuset.mems")
+					if err != nil {
+						return err
+					}
+				}
+
+				err = c.CGroupSet("cpuset.mems", mems)
+				if err != nil {
+					return err
+				}
+			} else if key == "limits.cpu.priority" || key == "limits.cpu.allowance" || key == "limits.cpu.allowance.burst" {
 				// Skip if no cpu CGroup
 				if !c.state.OS.CGroupCPUController {
 					continue
@@ -4900,6 +6036,19 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 					return err
 				}
 
+				cpuCfsBurst, err := deviceParseCPUBurst(c.expandedConfig["limits.cpu.allowance.burst"])
+				if err != nil {
+					return err
+				}
+
+				// The burst can't exceed the quota, so clear it before changing the quota
+				if c.state.OS.CGroupCPUBurst {
+					err = c.CGroupSet("cpu.cfs_burst_us", "0")
+					if err != nil {
+						return err
+					}
+				}
+
 				err = c.CGroupSet("cpu.shares", cpuShares)
 				if err != nil {
 					return err
@@ -4914,6 +6063,18 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 				if err != nil {
 					return err
 				}
+
+				if c.state.OS.CGroupCPUBurst {
+					err = c.CGroupSet("cpu.cfs_burst_us", cpuCfsBurst)
+					if err != nil {
+						return err
+					}
+				}
+			} else if strings.HasPrefix(key, "limits.hugepages.") {
+				err = c.setHugepagesLimits()
+				if err != nil {
+					return err
+				}
 			} else if key == "limits.processes" {
 				if !c.state.OS.CGroupPidsController {
 					continue
@@ -4939,10 +6100,13 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 		}
 
 		var usbs []usbDevice
+		var hotplugs []hotplugDevice
 
 		// Live update the devices
 		for k, m := range removeDevices {
 			if shared.StringInSlice(m["type"], []string{"unix-char", "unix-block"}) {
+				deviceInotifyDelDevice(c.state, c.id, k)
+
 				prefix := fmt.Sprintf("unix.%s", k)
 				destPath := m["path"]
 				if destPath == "" {
@@ -4954,11 +6118,13 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 				}
 
 				err = c.removeUnixDevice(fmt.Sprintf("unix.%s", k), m, true)
+				containerDevicesLogAdd(c, k, m, "remove", "config", err)
 				if err != nil {
 					return err
 				}
 			} else if m["type"] == "disk" && m["path"] != "/" {
 				err = c.removeDiskDevice(k, m)
+				containerDevicesLogAdd(c, k, m, "remove", "config", err)
 				if err != nil {
 					return err
 				}
@@ -4977,6 +6143,29 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 					}
 
 					err := c.removeUnixDeviceNum(fmt.Sprintf("unix.%s", k), m, usb.major, usb.minor, usb.path)
+					containerDevicesLogAdd(c, k, m, "remove", "config", err)
+					if err != nil {
+						return err
+					}
+				}
+			} else if m["type"] == "hotplug" {
+				hotplugIndexDel(c.id, k)
+
+				if hotplugs == nil {
+					hotplugs, err = deviceLoadHotplug()
+					if err != nil {
+						return err
+					}
+				}
+
+				for _, dev := range hotplugs {
+					prefix := fmt.Sprintf("unix.%s", k)
+					if !device.UnixDeviceExists(c.DevicesPath(), prefix, dev.path) {
+						continue
+					}
+
+					err := c.removeUnixDeviceNum(prefix, hotplugDeviceConfig(m, dev), dev.major, dev.minor, dev.path)
+					containerDevicesLogAdd(c, k, m, "remove", "config", err)
 					if err != nil {
 						return err
 					}
@@ -4984,15 +6173,44 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 			}
 		}
 
+		// The hugetlb limits account for the hugepages backed disks, so
+		// apply them before the added disks reserve their huge pages
+		hugepagesChanged := false
+		for _, devices := range []map[string]config.Device{removeDevices, addDevices, updateDevices} {
+			changedDevices := config.Devices{}
+			for k, m := range devices {
+				changedDevices[k] = m
+			}
+
+			if containerHugepagesHasDisks(changedDevices) {
+				hugepagesChanged = true
+			}
+		}
+
+		if hugepagesChanged {
+			err = c.setHugepagesLimits()
+			if err != nil {
+				return err
+			}
+		}
+
 		diskDevices := map[string]config.Device{}
 		for k, m := range addDevices {
 			if shared.StringInSlice(m["type"], []string{"unix-char", "unix-block"}) {
 				err = c.insertUnixDevice(fmt.Sprintf("unix.%s", k), m, true)
+				containerDevicesLogAdd(c, k, m, "add", "config", err)
 				if err != nil {
 					if m["required"] == "" || shared.IsTrue(m["required"]) {
 						return err
 					}
 				}
+
+				if deviceInotifyOptional(m) {
+					err = deviceInotifyAddDevice(c.state, c.id, k, deviceInotifyDevicePath(m))
+					if err != nil {
+						return err
+					}
+				}
 			} else if m["type"] == "disk" && m["path"] != "/" {
 				diskDevices[k] = m
 			} else if m["type"] == "usb" {
@@ -5009,22 +6227,63 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 					}
 
 					err = c.insertUnixDeviceNum(fmt.Sprintf("unix.%s", k), m, usb.major, usb.minor, usb.path, false)
+					containerDevicesLogAdd(c, k, m, "add", "config", err)
 					if err != nil {
 						logger.Error("Failed to insert usb device", log.Ctx{"err": err, "usb": usb, "container": c.Name()})
 					}
 				}
+			} else if m["type"] == "hotplug" {
+				hotplugIndexAdd(c.id, k)
+
+				if hotplugs == nil {
+					hotplugs, err = deviceLoadHotplug()
+					if err != nil {
+						return err
+					}
+				}
+
+				for _, dev := range hotplugs {
+					if !hotplugDeviceMatch(m, dev) {
+						continue
+					}
+
+					err = c.insertUnixDeviceNum(fmt.Sprintf("unix.%s", k), hotplugDeviceConfig(m, dev), dev.major, dev.minor, dev.path, false)
+					containerDevicesLogAdd(c, k, m, "add", "config", err)
+					if err != nil {
+						logger.Error("Failed to insert hotplug device", log.Ctx{"err": err, "path": dev.path, "container": c.Name()})
+					}
+				}
 			}
 		}
 
 		err = c.addDiskDevices(diskDevices, c.insertDiskDevice)
+		for k, m := range diskDevices {
+			containerDevicesLogAdd(c, k, m, "add", "config", err)
+		}
 		if err != nil {
 			return errors.Wrap(err, "Add disk devices")
 		}
 
 		updateDiskLimit := false
-		for _, m := range updateDevices {
+		for k, m := range updateDevices {
 			if m["type"] == "disk" {
 				updateDiskLimit = true
+
+				// Resize the disks other than the root one
+				if m["path"] != "/" && m["backing"] == "" && m["size"] != oldExpandedDevices[k]["size"] {
+					err = c.setDiskDeviceQuota(m)
+					if err != nil {
+						return errors.Wrapf(err, "Failed to set the size of disk device %s", k)
+					}
+				}
+
+				// Apply the new number of I/O threads of block devices
+				if m["path"] != "/" && m["io.threads"] != oldExpandedDevices[k]["io.threads"] {
+					err = c.setDiskDeviceIO(m, c.diskDevicePath(k, m), true)
+					if err != nil {
+						return errors.Wrapf(err, "Failed to set the I/O threads of disk device %s", k)
+					}
+				}
 			}
 		}
 
@@ -5057,6 +6316,44 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 				}
 			}
 		}
+
+		// So do the latency targets
+		if updateDiskLimit && c.expandedConfig["limits.disk.latency"] != "" && c.state.OS.CGroupIOLatency && !shared.StringInSlice("limits.disk.latency", changedConfig) {
+			err = c.setDiskLatency(c.expandedConfig["limits.disk.latency"])
+			if err != nil {
+				return err
+			}
+		}
+
+		// Connection tracking limits are per host interface, so re-apply them on NIC changes
+		if c.expandedConfig["limits.network.conntrack"] != "" && !shared.StringInSlice("limits.network.conntrack", changedConfig) {
+			updateConntrack := false
+			for _, m := range addDevices {
+				if m["type"] == "nic" {
+					updateConntrack = true
+				}
+			}
+
+			for _, m := range updateDevices {
+				if m["type"] == "nic" {
+					updateConntrack = true
+				}
+			}
+
+			// Removing a NIC shifts the zones of the others
+			for _, m := range removeDevices {
+				if m["type"] == "nic" {
+					updateConntrack = true
+				}
+			}
+
+			if updateConntrack {
+				err = c.setNetworkConntrack()
+				if err != nil {
+					return err
+				}
+			}
+		}
 	}
 
 	// Cleanup any leftover volatile entries
@@ -5080,8 +6377,8 @@ func (c *containerLXC) Update(args db.ContainerArgs, userRequested bool) error {
 			continue
 		}
 
-		// The only device keys we care about are name and hwaddr
-		if !shared.StringInSlice(fields[2], []string{"name", "hwaddr", "host_name"}) {
+		// The only device keys we care about are name, hwaddr, host_name and nic_index
+		if !shared.StringInSlice(fields[2], []string{"name", "hwaddr", "host_name", "nic_index"}) {
 			continue
 		}
 
@@ -5243,6 +6540,7 @@ func (c *containerLXC) updateDevices(removeDevices map[string]config.Device, add
 			if err == device.ErrUnsupportedDevType {
 				continue // No point in trying to remove device below.
 			} else if err != nil {
+				containerDevicesLogAdd(c, k, m, "remove", "config", err)
 				return errors.Wrapf(err, "Failed to stop device '%s'", k)
 			}
 		}
@@ -5251,6 +6549,10 @@ func (c *containerLXC) updateDevices(removeDevices map[string]config.Device, add
 		if err != nil && err != device.ErrUnsupportedDevType {
 			return errors.Wrapf(err, "Failed to remove device '%s'", k)
 		}
+
+		if isRunning {
+			containerDevicesLogAdd(c, k, m, "remove", "config", nil)
+		}
 	}
 
 	for k, m := range addDevices {
@@ -5264,22 +6566,36 @@ func (c *containerLXC) updateDevices(removeDevices map[string]config.Device, add
 		if isRunning {
 			_, err := c.deviceStart(k, m, isRunning)
 			if err != nil && err != device.ErrUnsupportedDevType {
+				containerDevicesLogAdd(c, k, m, "add", "config", err)
 				return errors.Wrapf(err, "Failed to start device '%s'", k)
 			}
+
+			containerDevicesLogAdd(c, k, m, "add", "config", nil)
 		}
 	}
 
 	for k, m := range updateDevices {
 		err := c.deviceUpdate(k, m, oldExpandedDevices[k], isRunning)
 		if err != nil && err != device.ErrUnsupportedDevType {
+			if isRunning {
+				containerDevicesLogAdd(c, k, m, "update", "config", err)
+			}
+
 			return errors.Wrapf(err, "Failed to update device '%s'", k)
 		}
+
+		if isRunning && err == nil {
+			containerDevicesLogAdd(c, k, m, "update", "config", nil)
+		}
 	}
 
 	return nil
 }
 
-func (c *containerLXC) Export(w io.Writer, properties map[string]string) error {
+// Export writes the container as an image tarball. When a parent image
+// fingerprint is given, only the files of the rootfs which changed since that
+// image are included, producing a delta image.
+func (c *containerLXC) Export(w io.Writer, properties map[string]string, parent string) error {
 	ctxMap := log.Ctx{
 		"project":   c.project,
 		"name":      c.name,
@@ -5303,13 +6619,24 @@ func (c *containerLXC) Export(w io.Writer, properties map[string]string) error {
 		defer c.StorageStop()
 	}
 
-	// Unshift the container
 	idmap, err := c.DiskIdmap()
 	if err != nil {
 		logger.Error("Failed exporting container", ctxMap)
 		return err
 	}
 
+	// Compare with the parent image, unshifting the ownership of the
+	// rootfs as stored in images
+	var delta *imageDelta
+	if parent != "" {
+		delta, err = imageDeltaNew(c.state, parent, c.RootfsPath(), idmap)
+		if err != nil {
+			logger.Error("Failed exporting container", ctxMap)
+			return err
+		}
+	}
+
+	// Unshift the container
 	if idmap != nil {
 		if !c.IsSnapshot() && shared.IsTrue(c.expandedConfig["security.protection.shift"]) {
 			return fmt.Errorf("Container is protected against filesystem shifting")
@@ -5347,11 +6674,18 @@ func (c *containerLXC) Export(w io.Writer, properties map[string]string) error {
 	// Path inside the tar image is the pathname starting after cDir
 	offset := len(cDir) + 1
 
+	rootfsPath := c.RootfsPath()
+
 	writeToTar := func(path string, fi os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 
+		// Skip the rootfs files which didn't change since the parent image
+		if delta != nil && strings.HasPrefix(path, rootfsPath+"/") && !delta.includes(path[len(rootfsPath)+1:]) {
+			return nil
+		}
+
 		err = ctw.WriteFile(offset, path, fi)
 		if err != nil {
 			logger.Debugf("Error tarring up %s: %s", path, err)
@@ -5500,8 +6834,48 @@ func (c *containerLXC) Export(w io.Writer, properties map[string]string) error {
 		}
 	}
 
+	// Describe the delta image
+	if delta != nil {
+		tempDir, err := ioutil.TempDir("", "lxd_lxd_delta_")
+		if err != nil {
+			ctw.Close()
+			logger.Error("Failed exporting container", ctxMap)
+			return err
+		}
+		defer os.RemoveAll(tempDir)
+
+		data, err := yaml.Marshal(delta)
+		if err != nil {
+			ctw.Close()
+			logger.Error("Failed exporting container", ctxMap)
+			return err
+		}
+
+		fnam = filepath.Join(tempDir, imageDeltaFile)
+		err = ioutil.WriteFile(fnam, data, 0644)
+		if err != nil {
+			ctw.Close()
+			logger.Error("Failed exporting container", ctxMap)
+			return err
+		}
+
+		fi, err := os.Lstat(fnam)
+		if err != nil {
+			ctw.Close()
+			logger.Error("Failed exporting container", ctxMap)
+			return err
+		}
+
+		err = ctw.WriteFile(len(tempDir)+1, fnam, fi)
+		if err != nil {
+			ctw.Close()
+			logger.Error("Failed exporting container", ctxMap)
+			return err
+		}
+	}
+
 	// Include all the rootfs files
-	fnam = c.RootfsPath()
+	fnam = rootfsPath
 	err = filepath.Walk(fnam, writeToTar)
 	if err != nil {
 		logger.Error("Failed exporting container", ctxMap)
@@ -5518,6 +6892,14 @@ func (c *containerLXC) Export(w io.Writer, properties map[string]string) error {
 		}
 	}
 
+	// Include the checksums manifest
+	err = ctw.WriteChecksums(shared.ChecksumsFile)
+	if err != nil {
+		ctw.Close()
+		logger.Error("Failed exporting container", ctxMap)
+		return err
+	}
+
 	err = ctw.Close()
 	if err != nil {
 		logger.Error("Failed exporting container", ctxMap)
@@ -5687,6 +7069,13 @@ func (c *containerLXC) Migrate(args *CriuMigrationArgs) error {
 				c.Stop(false)
 				return err
 			}
+
+			err = migrationHookRun(c, "post-restore", args.function, finalStateDir)
+			if err != nil {
+				// Attempt to stop container.
+				c.Stop(false)
+				return err
+			}
 		}
 	} else if args.cmd == lxc.MIGRATE_FEATURE_CHECK {
 		err := c.initLXC(true)
@@ -5743,6 +7132,13 @@ func (c *containerLXC) Migrate(args *CriuMigrationArgs) error {
 			args.stop = false
 		}
 
+		if args.cmd == lxc.MIGRATE_DUMP {
+			err := migrationHookRun(c, "pre-dump", args.function, finalStateDir)
+			if err != nil {
+				return err
+			}
+		}
+
 		migrateErr = c.c.Migrate(args.cmd, opts)
 	}
 
@@ -6376,7 +7772,7 @@ func (c *containerLXC) Exec(command []string, env map[string]string, stdin *os.F
 	return nil, 0, attachedPid, nil
 }
 
-func (c *containerLXC) cpuState() api.ContainerStateCPU {
+func (c *containerLXC) cpuState(cg *containerCGroupState) api.ContainerStateCPU {
 	cpu := api.ContainerStateCPU{}
 
 	if !c.state.OS.CGroupCPUacctController {
@@ -6384,7 +7780,7 @@ func (c *containerLXC) cpuState() api.ContainerStateCPU {
 	}
 
 	// CPU usage in seconds
-	value, err := c.CGroupGet("cpuacct.usage")
+	value, err := cg.Get("cpuacct.usage")
 	if err != nil {
 		cpu.Usage = -1
 		return cpu
@@ -6398,10 +7794,37 @@ func (c *containerLXC) cpuState() api.ContainerStateCPU {
 
 	cpu.Usage = valueInt
 
+	// CPU usage breakdown, the unified hierarchy has no per-CPU usage
+	cpu.User = -1
+	cpu.System = -1
+	cpu.PerCPU = []int64{}
+	if c.state.OS.CGroupV2 {
+		value, err = cg.Get("cpu.stat")
+	} else {
+		value, err = cg.Get("cpuacct.stat")
+	}
+	if err == nil {
+		user, system, err := cGroupParseCPUStat(value, c.state.OS.CGroupV2)
+		if err == nil {
+			cpu.User = user
+			cpu.System = system
+		}
+	}
+
+	if !c.state.OS.CGroupV2 {
+		value, err = cg.Get("cpuacct.usage_percpu")
+		if err == nil {
+			perCPU, err := cGroupParseCPUUsagePerCPU(value)
+			if err == nil {
+				cpu.PerCPU = perCPU
+			}
+		}
+	}
+
 	return cpu
 }
 
-func (c *containerLXC) diskState() map[string]api.ContainerStateDisk {
+func (c *containerLXC) diskState(cg *containerCGroupState) map[string]api.ContainerStateDisk {
 	disk := map[string]api.ContainerStateDisk{}
 
 	// Initialize storage interface for the container.
@@ -6410,43 +7833,125 @@ func (c *containerLXC) diskState() map[string]api.ContainerStateDisk {
 		return disk
 	}
 
+	// Lookup the filesystem usage from inside the container
+	var fsUsage map[string]*containerDiskUsage
+	if c.IsRunning() {
+		paths := []string{}
+		for _, name := range c.expandedDevices.DeviceNames() {
+			d := c.expandedDevices[name]
+			if d["type"] == "disk" {
+				paths = append(paths, d["path"])
+			}
+		}
+
+		fsUsage, err = containerDiskStatfs(c, paths)
+		if err != nil {
+			logger.Debug("Failed to get container filesystem usage", log.Ctx{"container": c.name, "err": err})
+		}
+	}
+
+	// Lookup the I/O latency of the block devices with a latency target
+	var ioStat map[string]map[string]int64
+	var validBlocks []string
+	latencyTarget := ""
+	if c.IsRunning() && c.expandedConfig["limits.disk.latency"] != "" && c.state.OS.CGroupIOLatency {
+		latencyTarget, err = deviceParseDiskLatency(c.expandedConfig["limits.disk.latency"])
+		if err == nil {
+			validBlocks, err = deviceValidBlocks()
+		}
+
+		if err == nil {
+			var stat string
+			stat, err = cg.Get("io.stat")
+			if err == nil {
+				ioStat = cGroupV2ParseIOStat(stat)
+			}
+		}
+
+		if err != nil {
+			logger.Debug("Failed to get container I/O latency", log.Ctx{"container": c.name, "err": err})
+		}
+	}
+
 	for _, name := range c.expandedDevices.DeviceNames() {
 		d := c.expandedDevices[name]
 		if d["type"] != "disk" {
 			continue
 		}
 
-		if d["path"] != "/" {
-			continue
+		state := api.ContainerStateDisk{Usage: -1}
+		if d["path"] == "/" {
+			usage, err := c.storage.ContainerGetUsage(c)
+			if err == nil {
+				state.Usage = usage
+			}
 		}
 
-		usage, err := c.storage.ContainerGetUsage(c)
-		if err != nil {
+		u, ok := fsUsage[d["path"]]
+		if ok {
+			if state.Usage < 0 {
+				state.Usage = u.used
+			}
+
+			state.Path = d["path"]
+			state.Total = u.total
+			state.Available = u.available
+			state.InodesUsage = u.inodesUsed
+			state.InodesTotal = u.inodesTotal
+
+			threshold, err := containerDiskUsageWarningParse(d["usage.warning"])
+			if err == nil {
+				state.Warning = u.percent() >= threshold
+			}
+		}
+
+		if ioStat != nil {
+			latency := api.ContainerStateDiskLatency{}
+			latency.Target, _ = strconv.ParseInt(latencyTarget, 10, 64)
+
+			for _, block := range c.getDiskBlocks(d, validBlocks) {
+				latency.Delay += ioStat[block]["delay_nsec"]
+				if ioStat[block]["avg_lat"] > latency.Average {
+					latency.Average = ioStat[block]["avg_lat"]
+				}
+			}
+
+			state.Latency = &latency
+		}
+
+		if state.Usage < 0 {
 			continue
 		}
 
-		disk[name] = api.ContainerStateDisk{Usage: usage}
+		disk[name] = state
 	}
 
 	return disk
 }
 
-func (c *containerLXC) memoryState() api.ContainerStateMemory {
+func (c *containerLXC) memoryState(cg *containerCGroupState) api.ContainerStateMemory {
 	memory := api.ContainerStateMemory{}
 
+	// Out of memory kills, recorded by the OOM watcher
+	memory.OOMKills, _ = strconv.ParseInt(c.localConfig["volatile.last_state.oom_kills"], 10, 64)
+	lastOOM, err := time.Parse(time.RFC3339, c.localConfig["volatile.last_state.oom"])
+	if err == nil {
+		memory.LastOOM = &lastOOM
+	}
+
 	if !c.state.OS.CGroupMemoryController {
 		return memory
 	}
 
 	// Memory in bytes
-	value, err := c.CGroupGet("memory.usage_in_bytes")
+	value, err := cg.Get("memory.usage_in_bytes")
 	valueInt, err1 := strconv.ParseInt(value, 10, 64)
 	if err == nil && err1 == nil {
 		memory.Usage = valueInt
 	}
 
 	// Memory peak in bytes
-	value, err = c.CGroupGet("memory.max_usage_in_bytes")
+	value, err = cg.Get("memory.max_usage_in_bytes")
 	valueInt, err1 = strconv.ParseInt(value, 10, 64)
 	if err == nil && err1 == nil {
 		memory.UsagePeak = valueInt
@@ -6455,7 +7960,7 @@ func (c *containerLXC) memoryState() api.ContainerStateMemory {
 	if c.state.OS.CGroupSwapAccounting {
 		// Swap in bytes
 		if memory.Usage > 0 {
-			value, err := c.CGroupGet("memory.memsw.usage_in_bytes")
+			value, err := cg.Get("memory.memsw.usage_in_bytes")
 			valueInt, err1 := strconv.ParseInt(value, 10, 64)
 			if err == nil && err1 == nil {
 				memory.SwapUsage = valueInt - memory.Usage
@@ -6464,7 +7969,7 @@ func (c *containerLXC) memoryState() api.ContainerStateMemory {
 
 		// Swap peak in bytes
 		if memory.UsagePeak > 0 {
-			value, err = c.CGroupGet("memory.memsw.max_usage_in_bytes")
+			value, err = cg.Get("memory.memsw.max_usage_in_bytes")
 			valueInt, err1 = strconv.ParseInt(value, 10, 64)
 			if err == nil && err1 == nil {
 				memory.SwapUsagePeak = valueInt - memory.UsagePeak
@@ -6530,10 +8035,57 @@ func (c *containerLXC) networkState() map[string]api.ContainerStateNetwork {
 		}
 	}
 
-	return result
-}
+	// Get the link details from a sysfs mounted in the network namespace of
+	// the container, as the one of the container itself may be anything.
+	out, err := shared.RunCommand(c.state.OS.ExecPath, "forknet", "link", fmt.Sprintf("%d", pid))
+	if err != nil {
+		logger.Debug("Failed to retrieve network link details", log.Ctx{"container": c.name, "err": err, "pid": pid})
+	} else {
+		links := map[string]api.ContainerStateNetwork{}
+		err = json.Unmarshal([]byte(out), &links)
+		if err != nil {
+			logger.Debug("Failed to parse network link details", log.Ctx{"container": c.name, "err": err})
+		}
 
-func (c *containerLXC) processesState() int64 {
+		for name, dev := range result {
+			link, ok := links[name]
+			if !ok {
+				continue
+			}
+
+			dev.Carrier = link.Carrier
+			dev.Speed = link.Speed
+			dev.Master = link.Master
+			dev.Bond = link.Bond
+			dev.Bridge = link.Bridge
+			result[name] = dev
+		}
+	}
+
+	// Get the connection tracking usage if limited.
+	if c.expandedConfig["limits.network.conntrack"] != "" {
+		for k, zone := range c.networkConntrackZones() {
+			name := c.expandedDevices[k]["name"]
+			dev, ok := result[name]
+			if !ok {
+				continue
+			}
+
+			count, err := networkConntrackCount(zone)
+			if err != nil {
+				logger.Debug("Failed to count connection tracking entries", log.Ctx{"container": c.name, "err": err})
+				break
+			}
+
+			dev.Conntrack = count
+			result[name] = dev
+		}
+	}
+
+	return result
+}
+
+func (c *containerLXC) processesState(cg *containerCGroupState) int64 {
 	// Return 0 if not running
 	pid := c.InitPID()
 	if pid == -1 {
@@ -6541,7 +8093,7 @@ func (c *containerLXC) processesState() int64 {
 	}
 
 	if c.state.OS.CGroupPidsController {
-		value, err := c.CGroupGet("pids.current")
+		value, err := cg.Get("pids.current")
 		if err != nil {
 			return -1
 		}
@@ -7201,9 +8753,7 @@ func (c *containerLXC) fillNetworkDevice(name string, m config.Device) (config.D
 // Disk device handling
 func (c *containerLXC) createDiskDevice(name string, m config.Device) (string, error) {
 	// source paths
-	relativeDestPath := strings.TrimPrefix(m["path"], "/")
-	devName := fmt.Sprintf("disk.%s.%s", strings.Replace(name, "/", "-", -1), strings.Replace(relativeDestPath, "/", "-", -1))
-	devPath := filepath.Join(c.DevicesPath(), devName)
+	devPath := c.diskDevicePath(name, m)
 	srcPath := shared.HostPath(m["source"])
 
 	// Check if read-only
@@ -7212,7 +8762,10 @@ func (c *containerLXC) createDiskDevice(name string, m config.Device) (string, e
 	isRecursive := shared.IsTrue(m["recursive"])
 
 	isFile := false
-	if m["pool"] == "" {
+	if m["backing"] != "" {
+		// Hugepages backed disks don't have a source
+		srcPath = ""
+	} else if m["pool"] == "" {
 		isFile = !shared.IsDir(srcPath) && !device.IsBlockdev(srcPath)
 	} else {
 		// Deal with mounting storage volumes created via the storage
@@ -7279,13 +8832,21 @@ func (c *containerLXC) createDiskDevice(name string, m config.Device) (string, e
 	}
 
 	// Check if the source exists
-	if !shared.PathExists(srcPath) {
+	if srcPath != "" && !shared.PathExists(srcPath) {
 		if isOptional {
 			return "", nil
 		}
 		return "", fmt.Errorf("Source path %s doesn't exist for device %s", srcPath, name)
 	}
 
+	// Apply the size quota
+	if m["size"] != "" && m["backing"] == "" {
+		err := c.setDiskDeviceQuota(m)
+		if err != nil {
+			return "", errors.Wrapf(err, "Failed to set the size of disk device %s", name)
+		}
+	}
+
 	// Create the devices directory if missing
 	if !shared.PathExists(c.DevicesPath()) {
 		err := os.Mkdir(c.DevicesPath(), 0711)
@@ -7317,15 +8878,194 @@ func (c *containerLXC) createDiskDevice(name string, m config.Device) (string, e
 		}
 	}
 
+	// Mount a hugetlbfs, from the start-host hook for starting containers
+	if m["backing"] == "hugepages" {
+		var err error
+		if c.IsRunning() {
+			err = c.mountHugepagesDisk(devPath, m, c.InitPID())
+		} else {
+			err = shareHugepagesDisk(devPath)
+		}
+		if err != nil {
+			os.Remove(devPath)
+			return "", err
+		}
+
+		return devPath, nil
+	}
+
 	// Mount the fs
 	err := device.DiskMount(srcPath, devPath, isReadOnly, isRecursive, m["propagation"])
 	if err != nil {
 		return "", err
 	}
 
+	// Apply the I/O options of block devices
+	err = c.setDiskDeviceIO(m, devPath, false)
+	if err != nil {
+		unix.Unmount(devPath, unix.MNT_DETACH)
+		os.Remove(devPath)
+		return "", err
+	}
+
 	return devPath, nil
 }
 
+// diskDevicePath returns the path on the host a disk device gets mounted on
+// before being passed to the container.
+func (c *containerLXC) diskDevicePath(name string, m config.Device) string {
+	relativeDestPath := strings.TrimPrefix(m["path"], "/")
+	devName := fmt.Sprintf("disk.%s.%s", strings.Replace(name, "/", "-", -1), strings.Replace(relativeDestPath, "/", "-", -1))
+	return filepath.Join(c.DevicesPath(), devName)
+}
+
+// setDiskDeviceIO applies the io.threads option of a disk device backed by a
+// block device and mounted at devPath, remounting its btrfs filesystem with
+// that many worker threads. Disks without it are left alone unless updated,
+// going back to the default number of threads.
+func (c *containerLXC) setDiskDeviceIO(m config.Device, devPath string, update bool) error {
+	if m["io.threads"] == "" && !update {
+		return nil
+	}
+
+	srcPath := shared.HostPath(m["source"])
+	if m["pool"] != "" || m["backing"] != "" || !device.IsBlockdev(srcPath) {
+		if m["io.threads"] != "" {
+			return fmt.Errorf("The number of I/O threads can only be set for block device disks")
+		}
+
+		return nil
+	}
+
+	threads := uint64(0)
+	if m["io.threads"] != "" {
+		var err error
+		threads, err = strconv.ParseUint(m["io.threads"], 10, 32)
+		if err != nil {
+			return err
+		}
+	}
+
+	return device.DiskRemountThreads(srcPath, devPath, shared.IsTrue(m["readonly"]), threads)
+}
+
+// setDiskDeviceQuota applies the size quota of a disk device other than the
+// root one. Storage volumes get their own size changed, for all the containers
+// using them to agree with the database, while host directories get a project
+// quota, if their filesystem supports them. Without a size, volumes are left
+// alone and directories have their quota lifted.
+func (c *containerLXC) setDiskDeviceQuota(m config.Device) error {
+	if m["pool"] != "" {
+		if m["size"] == "" {
+			return nil
+		}
+
+		volumeName := strings.TrimPrefix(filepath.Clean(m["source"]), fmt.Sprintf("%s/", storagePoolVolumeTypeNameCustom))
+		s, err := storagePoolVolumeInit(c.state, "default", m["pool"], volumeName, storagePoolVolumeTypeCustom)
+		if err != nil {
+			return err
+		}
+
+		writable := s.GetStoragePoolVolumeWritable()
+		if writable.Config["size"] == m["size"] {
+			return nil
+		}
+
+		volumeConfig := map[string]string{}
+		for k, v := range writable.Config {
+			volumeConfig[k] = v
+		}
+
+		volumeConfig["size"] = m["size"]
+
+		return storagePoolVolumeUpdate(c.state, m["pool"], volumeName, storagePoolVolumeTypeCustom, writable.Description, volumeConfig)
+	}
+
+	if m["size"] == "" {
+		return c.clearDiskDeviceQuota(m)
+	}
+
+	srcPath := shared.HostPath(m["source"])
+	ok, err := quota.Supported(srcPath)
+	if err != nil || !ok {
+		logger.Warnf("Skipping setting disk quota for '%s' as the underlying filesystem doesn't support them", srcPath)
+		return nil
+	}
+
+	size, err := units.ParseByteSizeString(m["size"])
+	if err != nil {
+		return err
+	}
+
+	// Directories keep their project ID so new files are accounted for
+	projectID, err := diskDeviceProjectID(srcPath)
+	if err != nil {
+		return err
+	}
+
+	currentID, err := quota.GetProject(srcPath)
+	if err != nil {
+		return err
+	}
+
+	if currentID != projectID {
+		err = quota.SetProjectRecursive(srcPath, projectID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return quota.SetProjectQuota(srcPath, projectID, size)
+}
+
+// clearDiskDeviceQuota lifts the project quota of a host directory used as a
+// disk and removes its project ID from the files below it.
+func (c *containerLXC) clearDiskDeviceQuota(m config.Device) error {
+	srcPath := shared.HostPath(m["source"])
+	if !shared.IsDir(srcPath) {
+		return nil
+	}
+
+	ok, err := quota.Supported(srcPath)
+	if err != nil || !ok {
+		return nil
+	}
+
+	projectID, err := diskDeviceProjectID(srcPath)
+	if err != nil {
+		return nil
+	}
+
+	currentID, err := quota.GetProject(srcPath)
+	if err != nil {
+		return err
+	}
+
+	// Not set by LXD
+	if currentID != projectID {
+		return nil
+	}
+
+	return quota.DeleteProjectRecursive(srcPath, projectID)
+}
+
+// diskDeviceProjectID returns the project quota ID of a host directory used as
+// a disk. It's derived from the inode number of the directory, which is unique
+// on its filesystem, above the range used by the storage volumes.
+func diskDeviceProjectID(path string) (uint32, error) {
+	var stat unix.Stat_t
+	err := unix.Stat(path, &stat)
+	if err != nil {
+		return 0, err
+	}
+
+	if stat.Ino >= 0x40000000 {
+		return 0, fmt.Errorf("The inode number of '%s' is too large for a project ID", path)
+	}
+
+	return uint32(0x40000000 | stat.Ino), nil
+}
+
 func (c *containerLXC) insertDiskDevice(name string, m config.Device) error {
 	// Check that the container is running
 	if !c.IsRunning() {
@@ -7370,8 +9110,9 @@ func (c *containerLXC) insertDiskDevice(name string, m config.Device) error {
 		}
 	}
 
+	// Idmapped mounts are only set up by liblxc at startup
 	if shift && !c.state.OS.Shiftfs {
-		return fmt.Errorf("shiftfs is required by disk entry '%s' but isn't supported on system", name)
+		return fmt.Errorf("shiftfs is required to hotplug disk entry '%s' but isn't supported on system", name)
 	}
 
 	// Bind-mount it into the container
@@ -7384,6 +9125,189 @@ func (c *containerLXC) insertDiskDevice(name string, m config.Device) error {
 	return nil
 }
 
+// extraMountSource returns the host path of the source of an extra mount,
+// making sure it doesn't resolve outside of the container's root filesystem.
+func (c *containerLXC) extraMountSource(m shared.ExtraMount) (string, error) {
+	rootfs, err := filepath.EvalSymlinks(c.RootfsPath())
+	if err != nil {
+		return "", err
+	}
+
+	source, err := filepath.EvalSymlinks(filepath.Join(rootfs, m.Source))
+	if err != nil {
+		return "", errors.Wrapf(err, "Failed to resolve source of mount '%s'", m.Target)
+	}
+
+	if source != rootfs && !strings.HasPrefix(source, rootfs+"/") {
+		return "", fmt.Errorf("Source of mount '%s' points outside of the container", m.Target)
+	}
+
+	return source, nil
+}
+
+// extraMountOpen opens the source of an extra mount, checking that what was
+// opened, rather than what the path pointed to earlier, is inside of the
+// container's root filesystem. The container can't swap it for a symlink
+// anymore once opened, and mounting /proc/self/fd/<fd> mounts that file.
+func (c *containerLXC) extraMountOpen(m shared.ExtraMount) (*os.File, error) {
+	source, err := c.extraMountSource(m)
+	if err != nil {
+		return nil, err
+	}
+
+	fd, err := unix.Open(source, unix.O_PATH|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to open source of mount '%s'", m.Target)
+	}
+
+	f := os.NewFile(uintptr(fd), source)
+
+	// The path was swapped for a symlink since it was resolved
+	var st unix.Stat_t
+	err = unix.Fstat(fd, &st)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if st.Mode&unix.S_IFMT == unix.S_IFLNK {
+		f.Close()
+		return nil, fmt.Errorf("Source of mount '%s' changed while being resolved", m.Target)
+	}
+
+	rootfs, err := filepath.EvalSymlinks(c.RootfsPath())
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	opened, err := os.Readlink(fmt.Sprintf("/proc/self/fd/%d", fd))
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if opened != rootfs && !strings.HasPrefix(opened, rootfs+"/") {
+		f.Close()
+		return nil, fmt.Errorf("Source of mount '%s' points outside of the container", m.Target)
+	}
+
+	return f, nil
+}
+
+// setupExtraMounts passes the mounts.extra entries to LXC.
+func (c *containerLXC) setupExtraMounts() error {
+	mounts, err := shared.ParseExtraMounts(c.expandedConfig["mounts.extra"])
+	if err != nil {
+		return err
+	}
+
+	for _, m := range mounts {
+		source, err := c.extraMountSource(m)
+		if err != nil {
+			if m.Optional {
+				continue
+			}
+
+			return err
+		}
+
+		options := []string{"bind"}
+		if m.Recursive {
+			options[0] = "rbind"
+		}
+
+		if m.ReadOnly {
+			options = append(options, "ro")
+		}
+
+		if shared.IsDir(source) {
+			options = append(options, "create=dir")
+		} else {
+			options = append(options, "create=file")
+		}
+
+		if m.Optional {
+			options = append(options, "optional")
+		}
+
+		err = lxcSetConfigItem(c.c, "lxc.mount.entry", fmt.Sprintf("%s %s none %s 0 0", shared.EscapePathFstab(source), shared.EscapePathFstab(strings.TrimPrefix(m.Target, "/")), strings.Join(options, ",")))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// updateExtraMounts applies changes of mounts.extra to the running container.
+// Read-only mounts can't be attached live and are only setup on next start.
+func (c *containerLXC) updateExtraMounts(oldValue string, newValue string) error {
+	oldMounts, err := shared.ParseExtraMounts(oldValue)
+	if err != nil {
+		return err
+	}
+
+	newMounts, err := shared.ParseExtraMounts(newValue)
+	if err != nil {
+		return err
+	}
+
+	current := map[string]shared.ExtraMount{}
+	for _, m := range newMounts {
+		current[m.Target] = m
+	}
+
+	previous := map[string]shared.ExtraMount{}
+	for _, m := range oldMounts {
+		previous[m.Target] = m
+
+		if current[m.Target] == m {
+			continue
+		}
+
+		err := c.removeMount(m.Target)
+		if err != nil {
+			logger.Warn("Failed to remove extra mount", log.Ctx{"container": c.Name(), "target": m.Target, "err": err})
+		}
+	}
+
+	for _, m := range newMounts {
+		if previous[m.Target] == m {
+			continue
+		}
+
+		if m.ReadOnly {
+			logger.Info("Read-only extra mount will be setup on next start", log.Ctx{"container": c.Name(), "target": m.Target})
+			continue
+		}
+
+		f, err := c.extraMountOpen(m)
+		if err != nil {
+			if m.Optional {
+				continue
+			}
+
+			return err
+		}
+
+		flags := unix.MS_BIND
+		if m.Recursive {
+			flags |= unix.MS_REC
+		}
+
+		// Mount through the file descriptor, which only LXD itself can
+		// do as LXC would get the path of another process
+		err = c.insertMountLXD(fmt.Sprintf("/proc/self/fd/%d", f.Fd()), m.Target, "none", flags, -1, false)
+		f.Close()
+		if err != nil {
+			return errors.Wrapf(err, "Failed to add mount '%s'", m.Target)
+		}
+	}
+
+	return nil
+}
+
 type byPath []config.Device
 
 func (a byPath) Len() int {
@@ -7454,6 +9378,12 @@ func (c *containerLXC) removeDiskDevice(name string, m config.Device) error {
 		return err
 	}
 
+	// Hugepages backed disks have their hugetlbfs stacked on a bind mount
+	// when mounted at start
+	if m["backing"] == "hugepages" {
+		_ = unix.Unmount(devPath, unix.MNT_DETACH)
+	}
+
 	// Check if pool-specific action should be taken
 	if m["pool"] != "" {
 		s, err := storagePoolVolumeInit(c.state, "default", m["pool"], m["source"], storagePoolVolumeTypeCustom)
@@ -7495,8 +9425,10 @@ func (c *containerLXC) removeDiskDevices() error {
 			continue
 		}
 
-		// Always try to unmount the host side
-		_ = unix.Unmount(filepath.Join(c.DevicesPath(), f.Name()), unix.MNT_DETACH)
+		// Always try to unmount the host side, including the mounts
+		// stacked on it
+		for unix.Unmount(filepath.Join(c.DevicesPath(), f.Name()), unix.MNT_DETACH) == nil {
+		}
 
 		// Remove the entry
 		diskPath := filepath.Join(c.DevicesPath(), f.Name())
@@ -7514,31 +9446,11 @@ func (c *containerLXC) getDiskLimits() (map[string]deviceBlockLimit, error) {
 	result := map[string]deviceBlockLimit{}
 
 	// Build a list of all valid block devices
-	validBlocks := []string{}
-
-	dents, err := ioutil.ReadDir("/sys/class/block/")
+	validBlocks, err := deviceValidBlocks()
 	if err != nil {
 		return nil, err
 	}
 
-	for _, f := range dents {
-		fPath := filepath.Join("/sys/class/block/", f.Name())
-		if shared.PathExists(fmt.Sprintf("%s/partition", fPath)) {
-			continue
-		}
-
-		if !shared.PathExists(fmt.Sprintf("%s/dev", fPath)) {
-			continue
-		}
-
-		block, err := ioutil.ReadFile(fmt.Sprintf("%s/dev", fPath))
-		if err != nil {
-			return nil, err
-		}
-
-		validBlocks = append(validBlocks, strings.TrimSuffix(string(block), "\n"))
-	}
-
 	// Process all the limits
 	blockLimits := map[string][]deviceBlockLimit{}
 	for _, k := range c.expandedDevices.DeviceNames() {
@@ -7583,20 +9495,7 @@ func (c *containerLXC) getDiskLimits() (map[string]deviceBlockLimit, error) {
 
 		device := deviceBlockLimit{readBps: readBps, readIops: readIops, writeBps: writeBps, writeIops: writeIops}
 		for _, block := range blocks {
-			blockStr := ""
-
-			if shared.StringInSlice(block, validBlocks) {
-				// Straightforward entry (full block device)
-				blockStr = block
-			} else {
-				// Attempt to deal with a partition (guess its parent)
-				fields := strings.SplitN(block, ":", 2)
-				fields[1] = "0"
-				if shared.StringInSlice(fmt.Sprintf("%s:%s", fields[0], fields[1]), validBlocks) {
-					blockStr = fmt.Sprintf("%s:%s", fields[0], fields[1])
-				}
-			}
-
+			blockStr := deviceValidBlock(block, validBlocks)
 			if blockStr == "" {
 				return nil, fmt.Errorf("Block device doesn't support quotas: %s", block)
 			}
@@ -7658,6 +9557,97 @@ func (c *containerLXC) getDiskLimits() (map[string]deviceBlockLimit, error) {
 	return result, nil
 }
 
+// getDiskBlocks returns the block devices backing a disk device which I/O
+// limits can be set on.
+func (c *containerLXC) getDiskBlocks(m config.Device, validBlocks []string) []string {
+	result := []string{}
+
+	// Hugepages backed disks don't have a block device
+	if m["backing"] != "" {
+		return result
+	}
+
+	source := shared.HostPath(m["source"])
+	if source == "" {
+		source = c.RootfsPath()
+	}
+
+	if !shared.PathExists(source) {
+		return result
+	}
+
+	blocks, err := deviceGetParentBlocks(source)
+	if err != nil {
+		return result
+	}
+
+	for _, block := range blocks {
+		blockStr := deviceValidBlock(block, validBlocks)
+		if blockStr != "" && !shared.StringInSlice(blockStr, result) {
+			result = append(result, blockStr)
+		}
+	}
+
+	return result
+}
+
+// getDiskLatency returns the io.latency entries setting the given latency
+// target on the block devices backing the disks of the container, or lifting
+// their targets if empty.
+func (c *containerLXC) getDiskLatency(diskLatency string) ([]string, error) {
+	target := "max"
+	if diskLatency != "" {
+		var err error
+		target, err = deviceParseDiskLatency(diskLatency)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	validBlocks, err := deviceValidBlocks()
+	if err != nil {
+		return nil, err
+	}
+
+	blocks := []string{}
+	for _, name := range c.expandedDevices.DeviceNames() {
+		m := c.expandedDevices[name]
+		if m["type"] != "disk" {
+			continue
+		}
+
+		for _, block := range c.getDiskBlocks(m, validBlocks) {
+			if !shared.StringInSlice(block, blocks) {
+				blocks = append(blocks, block)
+			}
+		}
+	}
+
+	entries := []string{}
+	for _, block := range blocks {
+		entries = append(entries, fmt.Sprintf("%s target=%s", block, target))
+	}
+
+	return entries, nil
+}
+
+// setDiskLatency applies the latency target of a running container.
+func (c *containerLXC) setDiskLatency(diskLatency string) error {
+	entries, err := c.getDiskLatency(diskLatency)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		err = c.CGroupSet("io.latency", entry)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // Network I/O limits
 func (c *containerLXC) setNetworkPriority() error {
 	// Check that the container is running
@@ -7706,6 +9696,166 @@ func (c *containerLXC) setNetworkPriority() error {
 	return nil
 }
 
+// Network bandwidth limits
+func (c *containerLXC) setNetworkLimits() error {
+	// Check that the container is running
+	if !c.IsRunning() {
+		return fmt.Errorf("Can't set network limits on stopped container")
+	}
+
+	// The limits are shaped on the host side veth of the devices, which
+	// re-apply them on update using the container's defaults.
+	for _, name := range c.expandedDevices.DeviceNames() {
+		m := c.expandedDevices[name]
+		if m["type"] != "nic" || !shared.StringInSlice(m["nictype"], []string{"bridged", "p2p"}) {
+			continue
+		}
+
+		oldConfig := config.Device{}
+		for k, v := range m {
+			oldConfig[k] = v
+		}
+
+		err := c.deviceUpdate(name, m, oldConfig, true)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to apply network limits to device '%s'", name)
+		}
+	}
+
+	return nil
+}
+
+// Network connection tracking limits
+func (c *containerLXC) setNetworkConntrack() error {
+	// Check that the container is running
+	if !c.IsRunning() {
+		return fmt.Errorf("Can't set connection tracking limit on stopped container")
+	}
+
+	// Remove any existing limits
+	err := c.removeNetworkConntrack()
+	if err != nil {
+		return err
+	}
+
+	limit := c.expandedConfig["limits.network.conntrack"]
+	if limit == "" {
+		return nil
+	}
+
+	// Limit the new connections coming from each of the host side interfaces
+	zones := c.networkConntrackZones()
+	for _, k := range c.expandedDevices.DeviceNames() {
+		m := c.expandedDevices[k]
+		if m["type"] != "nic" {
+			continue
+		}
+
+		hostName := c.localConfig[fmt.Sprintf("volatile.%s.host_name", k)]
+		if hostName == "" {
+			continue
+		}
+
+		// Traffic of bridged NICs comes in through the bridge, so match
+		// the bridge port it enters from instead.
+		match := []string{"-i", hostName}
+		if m["nictype"] == "bridged" {
+			match = []string{"-m", "physdev", "--physdev-in", hostName}
+		}
+
+		for _, protocol := range []string{"ipv4", "ipv6"} {
+			if protocol == "ipv6" && !shared.PathExists("/proc/sys/net/ipv6") {
+				continue
+			}
+
+			// Track the connections originating from the interface in
+			// its own zone so that their usage can be counted. Replies
+			// stay in the default zone.
+			rule := append([]string{}, match...)
+			rule = append(rule, "-j", "CT", "--zone-orig", strconv.Itoa(zones[k]))
+			err = iptables.ContainerPrepend(protocol, fmt.Sprintf("%s - conntrack", c.name), "raw", "PREROUTING", rule...)
+			if err != nil {
+				return err
+			}
+
+			for _, chain := range []string{"INPUT", "FORWARD"} {
+				rule := append([]string{}, match...)
+				rule = append(rule, "-m", "conntrack", "--ctstate", "NEW",
+					"-m", "connlimit", "--connlimit-above", limit, "--connlimit-mask", "0",
+					"-j", "REJECT")
+				err = iptables.ContainerPrepend(protocol, fmt.Sprintf("%s - conntrack", c.name), "filter", chain, rule...)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// networkConntrackZones returns the connection tracking zone of each NIC of
+// the container.
+func (c *containerLXC) networkConntrackZones() map[string]int {
+	zones := map[string]int{}
+	index := 0
+	for _, k := range c.expandedDevices.DeviceNames() {
+		if c.expandedDevices[k]["type"] != "nic" {
+			continue
+		}
+
+		zones[k] = networkConntrackZone(c.id, index)
+		index++
+	}
+
+	return zones
+}
+
+// Sysctls, set from the network and IPC namespaces of the container
+func (c *containerLXC) setSysctl(config map[string]string) error {
+	args := []string{}
+	for key, value := range config {
+		if !strings.HasPrefix(key, "linux.sysctl.") || value == "" {
+			continue
+		}
+
+		args = append(args, fmt.Sprintf("%s=%s", strings.TrimPrefix(key, "linux.sysctl."), value))
+	}
+
+	if len(args) == 0 {
+		return nil
+	}
+
+	// Check that the container is running
+	pid := c.InitPID()
+	if pid <= 0 {
+		return fmt.Errorf("Can't set sysctls on stopped container")
+	}
+
+	sort.Strings(args)
+	args = append([]string{"forknet", "sysctl", fmt.Sprintf("%d", pid)}, args...)
+
+	_, err := shared.RunCommand(c.state.OS.ExecPath, args...)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *containerLXC) removeNetworkConntrack() error {
+	for _, protocol := range []string{"ipv4", "ipv6"} {
+		for _, table := range []string{"filter", "raw"} {
+			err := iptables.ContainerClear(protocol, fmt.Sprintf("%s - conntrack", c.name), table)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
 // Various state query functions
 func (c *containerLXC) IsStateful() bool {
 	return c.stateful
@@ -7720,7 +9870,7 @@ func (c *containerLXC) IsFrozen() bool {
 }
 
 func (c *containerLXC) IsNesting() bool {
-	return shared.IsTrue(c.expandedConfig["security.nesting"])
+	return shared.IsTrue(c.expandedConfig["security.nesting"]) || containerNestingImplies(c.expandedConfig, "security.nesting")
 }
 
 func (c *containerLXC) isCurrentlyPrivileged() bool {
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/sys/unix"
+
+	"github.com/lxc/lxd/lxd/project"
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+var containerMonitorCmd = APIEndpoint{
+	Name: "containers/{name}/monitor",
+
+	Get: APIEndpointAction{Handler: containerMonitorGet, AccessHandler: AllowProjectPermission("containers", "view")},
+}
+
+// Number of consecutive sweeps a monitor must go without any child process
+// before it's considered orphaned and killed.
+const containerMonitorOrphanedSweeps = 2
+
+// containerMonitor tracks the LXC monitor process of a container.
+type containerMonitor struct {
+	pid       int
+	status    string
+	children  int
+	childless int
+	checkedAt time.Time
+}
+
+// The LXC monitors of this node, indexed by the LXC name of their container
+// (including the project prefix).
+var containerMonitorsLock sync.Mutex
+var containerMonitors = map[string]*containerMonitor{}
+
+// containerMonitorProcStat returns the state and parent PID of a process.
+func containerMonitorProcStat(pid int) (string, int, error) {
+	content, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return "", -1, err
+	}
+
+	// The command name may contain spaces and parentheses
+	i := strings.LastIndex(string(content), ")")
+	if i < 0 {
+		return "", -1, fmt.Errorf("Invalid stat of process %d", pid)
+	}
+
+	fields := strings.Fields(string(content)[i+1:])
+	if len(fields) < 2 {
+		return "", -1, fmt.Errorf("Invalid stat of process %d", pid)
+	}
+
+	ppid, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return "", -1, err
+	}
+
+	return fields[0], ppid, nil
+}
+
+// containerMonitorsScan looks for the LXC monitors of the containers in the
+// given path, returning their PIDs indexed by container name along with the
+// number of children of every process.
+func containerMonitorsScan(lxcPath string) (map[string]int, map[int]int, error) {
+	entries, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	monitors := map[string]int{}
+	children := map[int]int{}
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		// Processes may exit at any time, skip them
+		_, ppid, err := containerMonitorProcStat(pid)
+		if err != nil {
+			continue
+		}
+		children[ppid]++
+
+		cmdline, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+		if err != nil || !strings.HasPrefix(string(cmdline), "[lxc monitor] ") {
+			continue
+		}
+
+		// Container names can't have spaces
+		fields := strings.Fields(strings.Replace(string(cmdline), "\x00", " ", -1))
+		if len(fields) != 4 || fields[2] != lxcPath {
+			continue
+		}
+
+		monitors[fields[3]] = pid
+	}
+
+	return monitors, children, nil
+}
+
+// containerMonitorsSweep updates the state of the monitors of this node. The
+// monitors which are left without any container process are killed, and the
+// zombie ones are reaped when LXD is their parent.
+func containerMonitorsSweep(lxcPath string) {
+	monitors, children, err := containerMonitorsScan(lxcPath)
+	if err != nil {
+		logger.Error("Failed to look for the container monitors", log.Ctx{"err": err})
+		return
+	}
+
+	now := time.Now().UTC()
+	orphaned := map[string]int{}
+
+	containerMonitorsLock.Lock()
+	for name, pid := range monitors {
+		m := containerMonitors[name]
+		if m == nil || m.pid != pid {
+			m = &containerMonitor{pid: pid, status: "running"}
+			containerMonitors[name] = m
+		}
+
+		m.children = children[pid]
+		m.checkedAt = now
+		if m.children > 0 {
+			m.childless = 0
+			m.status = "running"
+			continue
+		}
+
+		m.childless++
+		if m.childless >= containerMonitorOrphanedSweeps {
+			m.status = "orphaned"
+			orphaned[name] = pid
+		}
+	}
+
+	for name, m := range containerMonitors {
+		_, ok := monitors[name]
+		if ok {
+			continue
+		}
+
+		state, ppid, err := containerMonitorProcStat(m.pid)
+		if err != nil || state != "Z" {
+			// The monitor is gone (its PID may have been reused)
+			delete(containerMonitors, name)
+			continue
+		}
+
+		m.status = "zombie"
+		m.children = 0
+		m.checkedAt = now
+
+		if ppid == os.Getpid() {
+			_, err := unix.Wait4(m.pid, nil, unix.WNOHANG, nil)
+			if err == nil {
+				logger.Info("Reaped zombie container monitor", log.Ctx{"container": name, "pid": m.pid})
+				delete(containerMonitors, name)
+			}
+		}
+	}
+	containerMonitorsLock.Unlock()
+
+	for name, pid := range orphaned {
+		logger.Warn("Killing orphaned container monitor", log.Ctx{"container": name, "pid": pid})
+
+		err := unix.Kill(pid, unix.SIGKILL)
+		if err != nil && err != unix.ESRCH {
+			logger.Error("Failed to kill orphaned container monitor", log.Ctx{"container": name, "pid": pid, "err": err})
+		}
+	}
+}
+
+func containerMonitorsTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		containerMonitorsSweep(d.os.LxcPath)
+	}
+
+	return f, task.Every(30 * time.Second)
+}
+
+func containerMonitorGet(d *Daemon, r *http.Request) Response {
+	projectName := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	// Handle requests targeted to a container on a different node
+	response, err := ForwardedResponseIfContainerIsRemote(d, r, projectName, name)
+	if err != nil {
+		return SmartError(err)
+	}
+	if response != nil {
+		return response
+	}
+
+	c, err := containerLoadByProjectAndName(d.State(), projectName, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	lxcName := project.Prefix(c.Project(), c.Name())
+
+	containerMonitorsLock.Lock()
+	m := containerMonitors[lxcName]
+	if m != nil {
+		monitor := api.ContainerMonitor{PID: m.pid, Status: m.status, Children: m.children, CheckedAt: m.checkedAt}
+		containerMonitorsLock.Unlock()
+		return SyncResponse(true, monitor)
+	}
+	containerMonitorsLock.Unlock()
+
+	// Not seen by the sweep yet, look it up now
+	monitors, children, err := containerMonitorsScan(d.os.LxcPath)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	monitor := api.ContainerMonitor{Status: "missing"}
+	pid, ok := monitors[lxcName]
+	if ok {
+		monitor.PID = pid
+		monitor.Status = "running"
+		monitor.Children = children[pid]
+	}
+
+	return SyncResponse(true, monitor)
+}
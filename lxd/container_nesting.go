@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// Kernel modules needed by the networking of a nested LXD.
+var containerNestingLXDKernelModules = []string{"ip_tables", "ip6_tables", "netlink_diag", "nf_nat", "overlay"}
+
+// containerNestingError lists the prerequisites of a nesting profile which
+// are missing.
+type containerNestingError struct {
+	profile string
+	missing []string
+}
+
+func (e containerNestingError) Error() string {
+	return fmt.Sprintf("Missing prerequisites for security.nesting.profile=%s: %s", e.profile, strings.Join(e.missing, "; "))
+}
+
+// containerNestingExpandConfig adds the configuration needed by the nesting
+// profile set in security.nesting.profile to the expanded configuration of a
+// container, leaving the keys which are set alone. It probes liblxc, so it's
+// only applied when the container starts.
+func containerNestingExpandConfig(s *state.State, config map[string]string) {
+	if config["security.nesting.profile"] != "lxd" {
+		return
+	}
+
+	defaults := map[string]string{
+		"security.nesting":         "true",
+		"security.nesting.cgroups": "full",
+	}
+
+	// Let the nested LXD create device nodes and set extended attributes
+	if lxcSupportSeccompNotify(s) {
+		defaults["security.syscalls.intercept.mknod"] = "true"
+		defaults["security.syscalls.intercept.setxattr"] = "true"
+	}
+
+	for k, v := range defaults {
+		_, ok := config[k]
+		if !ok {
+			config[k] = v
+		}
+	}
+
+	modules := []string{}
+	if config["linux.kernel_modules"] != "" {
+		for _, module := range strings.Split(config["linux.kernel_modules"], ",") {
+			modules = append(modules, strings.TrimSpace(module))
+		}
+	}
+
+	for _, module := range containerNestingLXDKernelModules {
+		if !shared.StringInSlice(module, modules) {
+			modules = append(modules, module)
+		}
+	}
+
+	config["linux.kernel_modules"] = strings.Join(modules, ",")
+}
+
+// containerNestingImplies returns whether the nesting profile of a container
+// enables the given boolean key, which isn't set otherwise. It lets the code
+// outside of the container start honor the profile without expanding it.
+func containerNestingImplies(config map[string]string, key string) bool {
+	if config["security.nesting.profile"] != "lxd" {
+		return false
+	}
+
+	_, ok := config[key]
+	return !ok
+}
+
+// containerNestingCheck validates that the host and the configuration of a
+// container with security.nesting.profile set provide everything the nested
+// software needs, listing all the missing pieces at once.
+func containerNestingCheck(c container) error {
+	config := c.ExpandedConfig()
+	profile := config["security.nesting.profile"]
+	if profile != "lxd" {
+		return nil
+	}
+
+	s := c.DaemonState()
+	missing := []string{}
+
+	if !shared.IsTrue(config["security.nesting"]) {
+		missing = append(missing, "security.nesting must be enabled")
+	}
+
+	if config["security.nesting.cgroups"] != "full" {
+		missing = append(missing, "security.nesting.cgroups must be set to full")
+	}
+
+	if !shared.PathExists("/proc/self/ns/cgroup") {
+		missing = append(missing, "the kernel doesn't support cgroup namespaces")
+	}
+
+	if s.OS.AppArmorAvailable && !s.OS.AppArmorStacking {
+		missing = append(missing, "the kernel doesn't support AppArmor stacking")
+	}
+
+	if !s.OS.CGroupDevicesController || !s.OS.CGroupPidsController || !s.OS.CGroupMemoryController {
+		missing = append(missing, "the devices, memory and pids cgroup controllers are required")
+	}
+
+	if len(missing) > 0 {
+		return containerNestingError{profile: profile, missing: missing}
+	}
+
+	// Without shiftfs or idmapped mounts, the nested containers' files get
+	// shifted when created
+	if !c.IsPrivileged() && !s.OS.Shiftfs && !s.OS.IdmappedMounts {
+		logger.Warn("Neither shiftfs nor idmapped mounts are available, the nested containers will be slower to create", log.Ctx{"container": c.Name(), "project": c.Project()})
+	}
+
+	return nil
+}
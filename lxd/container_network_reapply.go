@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/shared/api"
+)
+
+var containerNetworkReapplyCmd = APIEndpoint{
+	Name: "containers/{name}/network/reapply",
+
+	Post: APIEndpointAction{Handler: containerNetworkReapplyPost, AccessHandler: AllowProjectPermission("containers", "manage-containers")},
+}
+
+func containerNetworkReapplyPost(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	// Handle requests targeted to a container on a different node
+	response, err := ForwardedResponseIfContainerIsRemote(d, r, project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+	if response != nil {
+		return response
+	}
+
+	req := api.ContainerNetworkReapplyPost{}
+	if r.ContentLength != 0 {
+		err = json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			return BadRequest(err)
+		}
+	}
+
+	c, err := containerLoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if !c.IsRunning() {
+		return BadRequest(fmt.Errorf("The container isn't running"))
+	}
+
+	run := func(op *operation) error {
+		return c.NetworkReapply(req.Restart)
+	}
+
+	resources := map[string][]string{}
+	resources["containers"] = []string{name}
+
+	op, err := operationCreate(d.cluster, project, operationClassTask, db.OperationContainerNetworkReapply, resources, nil, run, nil, nil)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	return OperationResponse(op)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+)
+
+// networkStateLink completes the state of a network interface with its link
+// details, read from the given sysfs network class directory. It runs in
+// forknet, against a sysfs mounted in the network namespace of the container,
+// the counters coming from netlink.
+func networkStateLink(sysPath string, name string, dev *api.ContainerStateNetwork) {
+	path := filepath.Join(sysPath, name)
+	if !shared.PathExists(path) {
+		return
+	}
+
+	// Reading the carrier and speed of a down interface fails
+	carrier, err := networkStateReadInt(filepath.Join(path, "carrier"))
+	dev.Carrier = err == nil && carrier == 1
+
+	speed, err := networkStateReadInt(filepath.Join(path, "speed"))
+	if err == nil && speed > 0 {
+		dev.Speed = speed
+	}
+
+	master, err := os.Readlink(filepath.Join(path, "master"))
+	if err == nil {
+		dev.Master = filepath.Base(master)
+	}
+
+	// Bonds list their members, bridges have a directory of their ports
+	if shared.PathExists(filepath.Join(path, "bonding")) {
+		dev.Bond = &api.ContainerStateNetworkBond{Members: []string{}}
+
+		content, err := ioutil.ReadFile(filepath.Join(path, "bonding", "mode"))
+		if err == nil {
+			// e.g. "balance-rr 0"
+			fields := strings.Fields(string(content))
+			if len(fields) > 0 {
+				dev.Bond.Mode = fields[0]
+			}
+		}
+
+		content, err = ioutil.ReadFile(filepath.Join(path, "bonding", "slaves"))
+		if err == nil {
+			dev.Bond.Members = strings.Fields(string(content))
+			sort.Strings(dev.Bond.Members)
+		}
+	}
+
+	if shared.PathExists(filepath.Join(path, "bridge")) {
+		dev.Bridge = &api.ContainerStateNetworkBridge{Members: []string{}}
+
+		ents, err := ioutil.ReadDir(filepath.Join(path, "brif"))
+		if err == nil {
+			for _, ent := range ents {
+				dev.Bridge.Members = append(dev.Bridge.Members, ent.Name())
+			}
+		}
+	}
+}
+
+func networkStateReadInt(path string) (int64, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+func TestNetworkStateLink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd-network-state-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"eth0/carrier":           "1\n",
+		"eth0/speed":             "10000\n",
+		"bond0/carrier":          "0\n",
+		"bond0/speed":            "-1\n",
+		"bond0/bonding/mode":     "active-backup 1\n",
+		"bond0/bonding/slaves":   "eth2 eth1\n",
+		"br0/bridge/stp_state":   "0\n",
+		"br0/brif/veth0/port_no": "1\n",
+	}
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+
+	require.NoError(t, os.Symlink("../br0", filepath.Join(dir, "eth0", "master")))
+
+	eth0 := api.ContainerStateNetwork{}
+	networkStateLink(dir, "eth0", &eth0)
+	assert.True(t, eth0.Carrier)
+	assert.Equal(t, int64(10000), eth0.Speed)
+	assert.Equal(t, "br0", eth0.Master)
+	assert.Nil(t, eth0.Bond)
+	assert.Nil(t, eth0.Bridge)
+
+	bond0 := api.ContainerStateNetwork{}
+	networkStateLink(dir, "bond0", &bond0)
+	assert.False(t, bond0.Carrier)
+	assert.Equal(t, int64(0), bond0.Speed)
+	assert.Equal(t, &api.ContainerStateNetworkBond{Mode: "active-backup", Members: []string{"eth1", "eth2"}}, bond0.Bond)
+
+	br0 := api.ContainerStateNetwork{}
+	networkStateLink(dir, "br0", &br0)
+	assert.Equal(t, &api.ContainerStateNetworkBridge{Members: []string{"veth0"}}, br0.Bridge)
+
+	missing := api.ContainerStateNetwork{}
+	networkStateLink(dir, "eth9", &missing)
+	assert.Equal(t, api.ContainerStateNetwork{}, missing)
+}
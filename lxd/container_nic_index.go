@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/lxc/lxd/shared"
+)
+
+// nicIndexes returns the liblxc network index of each NIC of the container,
+// recording the ones of the new NICs, so that the order of the interfaces
+// inside the container is stable across configuration changes and restarts.
+func (c *containerLXC) nicIndexes() (map[string]int, error) {
+	names := []string{}
+	for _, k := range c.expandedDevices.DeviceNames() {
+		if shared.StringInSlice(c.expandedDevices[k]["type"], []string{"nic", "infiniband"}) {
+			names = append(names, k)
+		}
+	}
+
+	indexes, save := nicIndexesAllocate(names, c.localConfig)
+	if len(save) > 0 {
+		err := c.VolatileSet(save)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return indexes, nil
+}
+
+// nicIndexesAllocate returns the liblxc network index of each of the given
+// NICs. The indexes recorded in their volatile.<name>.nic_index keys are
+// kept, while the NICs without one, or whose index is invalid or already
+// taken, get the lowest free index. The volatile keys to record are returned
+// alongside.
+func nicIndexesAllocate(names []string, volatile map[string]string) (map[string]int, map[string]string) {
+	indexes := map[string]int{}
+	used := map[int]bool{}
+	pending := []string{}
+
+	for _, name := range names {
+		index, err := strconv.Atoi(volatile[fmt.Sprintf("volatile.%s.nic_index", name)])
+		if err != nil || index < 0 || used[index] {
+			pending = append(pending, name)
+			continue
+		}
+
+		indexes[name] = index
+		used[index] = true
+	}
+
+	save := map[string]string{}
+	next := 0
+	for _, name := range pending {
+		for used[next] {
+			next++
+		}
+
+		indexes[name] = next
+		used[next] = true
+		save[fmt.Sprintf("volatile.%s.nic_index", name)] = strconv.Itoa(next)
+	}
+
+	return indexes, save
+}
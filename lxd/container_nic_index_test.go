@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNICIndexesAllocate(t *testing.T) {
+	// New NICs are numbered in order.
+	indexes, save := nicIndexesAllocate([]string{"eth0", "eth1"}, map[string]string{})
+	assert.Equal(t, map[string]int{"eth0": 0, "eth1": 1}, indexes)
+	assert.Equal(t, map[string]string{"volatile.eth0.nic_index": "0", "volatile.eth1.nic_index": "1"}, save)
+
+	// Removing a NIC keeps the index of the others and a new NIC reuses the
+	// free index.
+	volatile := map[string]string{
+		"volatile.eth1.nic_index": "1",
+		"volatile.eth2.nic_index": "2",
+	}
+
+	indexes, save = nicIndexesAllocate([]string{"aaa", "eth1", "eth2"}, volatile)
+	assert.Equal(t, map[string]int{"aaa": 0, "eth1": 1, "eth2": 2}, indexes)
+	assert.Equal(t, map[string]string{"volatile.aaa.nic_index": "0"}, save)
+
+	// Invalid and duplicate indexes are replaced.
+	volatile = map[string]string{
+		"volatile.eth0.nic_index": "0",
+		"volatile.eth1.nic_index": "0",
+		"volatile.eth2.nic_index": "foo",
+	}
+
+	indexes, save = nicIndexesAllocate([]string{"eth0", "eth1", "eth2"}, volatile)
+	assert.Equal(t, map[string]int{"eth0": 0, "eth1": 1, "eth2": 2}, indexes)
+	assert.Equal(t, map[string]string{"volatile.eth1.nic_index": "1", "volatile.eth2.nic_index": "2"}, save)
+}
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// The restarts of containers running out of memory are delayed more each
+// time, up to a limit, until the container goes without running out of memory
+// for a while. Beyond the maximum number of restarts, the container is stopped.
+const containerOOMRestartDelay = 5 * time.Second
+const containerOOMRestartDelayMax = 5 * time.Minute
+const containerOOMRestartMax = 10
+const containerOOMRestartReset = 30 * time.Minute
+
+// The OOM watchers of the running containers, indexed by container ID.
+var containerOOMWatchersLock sync.Mutex
+var containerOOMWatchers = map[int]*containerOOMWatcher{}
+
+// containerOOMWatcher waits for the memory cgroup of a container to report
+// out of memory events, through an eventfd registered on memory.oom_control
+// with the legacy hierarchy and by watching memory.events with the unified
+// one.
+type containerOOMWatcher struct {
+	events *os.File
+	done   chan struct{}
+
+	// The file holding the oom_kill counter of the cgroup
+	counterPath string
+	kills       int64
+}
+
+// containerOOMWatchStart starts watching the out of memory events of a running
+// container, replacing any previous watcher.
+func containerOOMWatchStart(c container) {
+	containerOOMWatchStop(c)
+
+	s := c.DaemonState()
+	if !s.OS.CGroupMemoryController {
+		return
+	}
+
+	dir, err := containerOOMCgroupPath(c.InitPID(), s.OS.CGroupV2)
+	if err != nil {
+		logger.Error("Failed to find the memory cgroup of the container", log.Ctx{"container": c.Name(), "project": c.Project(), "err": err})
+		return
+	}
+
+	var w *containerOOMWatcher
+	if s.OS.CGroupV2 {
+		w, err = containerOOMWatchV2(dir)
+	} else {
+		w, err = containerOOMWatchV1(dir)
+	}
+	if err != nil {
+		logger.Error("Failed to watch the out of memory events of the container", log.Ctx{"container": c.Name(), "project": c.Project(), "err": err})
+		return
+	}
+
+	// Only report the events happening from now on
+	w.kills, _ = containerOOMCount(w.counterPath)
+
+	containerOOMWatchersLock.Lock()
+	containerOOMWatchers[c.Id()] = w
+	containerOOMWatchersLock.Unlock()
+
+	go w.run(c)
+}
+
+// containerOOMWatchStop stops watching the out of memory events of a
+// container.
+func containerOOMWatchStop(c container) {
+	containerOOMWatchersLock.Lock()
+	defer containerOOMWatchersLock.Unlock()
+
+	w, ok := containerOOMWatchers[c.Id()]
+	if !ok {
+		return
+	}
+
+	close(w.done)
+	w.events.Close()
+	delete(containerOOMWatchers, c.Id())
+}
+
+// containerOOMWatchV1 registers an eventfd notified of the out of memory
+// events of a legacy memory cgroup, as well as of its removal.
+func containerOOMWatchV1(dir string) (*containerOOMWatcher, error) {
+	efd, err := unix.Eventfd(0, unix.EFD_CLOEXEC|unix.EFD_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+
+	// Non-blocking so that closing it interrupts the reads
+	events := os.NewFile(uintptr(efd), "oom-eventfd")
+
+	control, err := os.Open(filepath.Join(dir, "memory.oom_control"))
+	if err != nil {
+		events.Close()
+		return nil, err
+	}
+	defer control.Close()
+
+	err = ioutil.WriteFile(filepath.Join(dir, "cgroup.event_control"), []byte(fmt.Sprintf("%d %d", efd, control.Fd())), 0)
+	if err != nil {
+		events.Close()
+		return nil, err
+	}
+
+	return &containerOOMWatcher{
+		events:      events,
+		done:        make(chan struct{}),
+		counterPath: control.Name(),
+	}, nil
+}
+
+// containerOOMWatchV2 watches the memory.events file of a unified memory
+// cgroup, which is modified on each out of memory event.
+func containerOOMWatchV2(dir string) (*containerOOMWatcher, error) {
+	ifd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+
+	events := os.NewFile(uintptr(ifd), "oom-inotify")
+	counterPath := filepath.Join(dir, "memory.events")
+
+	_, err = unix.InotifyAddWatch(ifd, counterPath, unix.IN_MODIFY)
+	if err != nil {
+		events.Close()
+		return nil, err
+	}
+
+	return &containerOOMWatcher{
+		events:      events,
+		done:        make(chan struct{}),
+		counterPath: counterPath,
+	}, nil
+}
+
+func (w *containerOOMWatcher) run(c container) {
+	buf := make([]byte, 4096)
+	for {
+		_, err := w.events.Read(buf)
+		if err != nil {
+			return
+		}
+
+		select {
+		case <-w.done:
+			return
+		default:
+		}
+
+		// Also notified when the cgroup goes away
+		if !shared.PathExists(w.counterPath) {
+			return
+		}
+
+		kills, ok := containerOOMCount(w.counterPath)
+		if !ok {
+			// Kernels before 4.13 don't count the kills, take the
+			// notification as one.
+			containerOOMNotify(c, 1)
+			continue
+		}
+
+		if kills <= w.kills {
+			continue
+		}
+
+		// Stopping or restarting closes the watcher, ending the loop
+		w.kills, kills = kills, kills-w.kills
+		containerOOMNotify(c, kills)
+	}
+}
+
+// containerOOMCount returns the oom_kill counter of a memory cgroup, read
+// from memory.oom_control or memory.events.
+func containerOOMCount(path string) (int64, bool) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+
+	return containerOOMCountParse(string(content))
+}
+
+func containerOOMCountParse(content string) (int64, bool) {
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || fields[0] != "oom_kill" {
+			continue
+		}
+
+		kills, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return 0, false
+		}
+
+		return kills, true
+	}
+
+	return 0, false
+}
+
+// containerOOMCgroupPath returns the memory cgroup directory of the given
+// process, being that of the container when it's its init process.
+func containerOOMCgroupPath(pid int, unified bool) (string, error) {
+	if pid < 1 {
+		return "", fmt.Errorf("The container isn't running")
+	}
+
+	f, err := os.Open(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	lines := []string{}
+	scan := bufio.NewScanner(f)
+	for scan.Scan() {
+		lines = append(lines, scan.Text())
+	}
+
+	err = scan.Err()
+	if err != nil {
+		return "", err
+	}
+
+	dir := containerOOMCgroupParse(lines, unified)
+	if dir == "" {
+		return "", fmt.Errorf("No memory cgroup found for process %d", pid)
+	}
+
+	return dir, nil
+}
+
+func containerOOMCgroupParse(lines []string, unified bool) string {
+	dirs := containerCGroupDirsParse(lines, unified)
+	if unified {
+		return dirs[""]
+	}
+
+	return dirs["memory"]
+}
+
+// containerOOMNotify records out of memory kills in a container, reports them
+// and applies its limits.memory.oom_policy.
+func containerOOMNotify(c container, kills int64) {
+	// Use a fresh copy of the container, its state may have changed
+	c, err := containerLoadByProjectAndName(c.DaemonState(), c.Project(), c.Name())
+	if err != nil {
+		return
+	}
+
+	policy := c.ExpandedConfig()["limits.memory.oom_policy"]
+	if policy == "" {
+		policy = "ignore"
+	}
+
+	total, _ := strconv.ParseInt(c.LocalConfig()["volatile.last_state.oom_kills"], 10, 64)
+	total += kills
+
+	// Only count the restarts of a container running out of memory again soon
+	restarts, _ := strconv.Atoi(c.LocalConfig()["volatile.last_state.oom_restarts"])
+	last, err := time.Parse(time.RFC3339, c.LocalConfig()["volatile.last_state.oom"])
+	if err != nil || time.Since(last) > containerOOMRestartReset {
+		restarts = 0
+	}
+
+	delay, ok := containerOOMRestartBackoff(restarts)
+	if policy == "restart" && !ok {
+		logger.Warn("Container ran out of memory too many times, stopping it", log.Ctx{"container": c.Name(), "project": c.Project(), "restarts": restarts})
+		policy = "stop"
+	}
+
+	volatile := map[string]string{
+		"volatile.last_state.oom_kills":    strconv.FormatInt(total, 10),
+		"volatile.last_state.oom":          time.Now().UTC().Format(time.RFC3339),
+		"volatile.last_state.oom_restarts": strconv.Itoa(restarts),
+	}
+
+	if policy == "restart" {
+		volatile["volatile.last_state.oom_restarts"] = strconv.Itoa(restarts + 1)
+	}
+
+	err = c.VolatileSet(volatile)
+	if err != nil {
+		logger.Error("Failed to record the out of memory event", log.Ctx{"container": c.Name(), "project": c.Project(), "err": err})
+	}
+
+	logger.Warn("Container ran out of memory", log.Ctx{"container": c.Name(), "project": c.Project(), "kills": kills, "policy": policy})
+	eventSendLifecycle(c.Project(), "container-oom",
+		fmt.Sprintf("/1.0/containers/%s", c.Name()), map[string]interface{}{"kills": kills, "policy": policy})
+
+	if !c.IsRunning() {
+		return
+	}
+
+	switch policy {
+	case "stop":
+		err = c.Stop(false)
+	case "restart":
+		err = c.Stop(false)
+		if err == nil {
+			err = containerOOMRestart(c, delay)
+		}
+	}
+
+	if err != nil {
+		logger.Error("Failed to apply the out of memory policy", log.Ctx{"container": c.Name(), "project": c.Project(), "policy": policy, "err": err})
+	}
+}
+
+// containerOOMRestartBackoff returns how long to wait before restarting a
+// container which ran out of memory after the given number of restarts, and
+// whether it should be restarted at all.
+func containerOOMRestartBackoff(restarts int) (time.Duration, bool) {
+	if restarts >= containerOOMRestartMax {
+		return 0, false
+	}
+
+	delay := containerOOMRestartDelay
+	for i := 0; i < restarts && delay < containerOOMRestartDelayMax; i++ {
+		delay *= 2
+	}
+
+	if delay > containerOOMRestartDelayMax {
+		delay = containerOOMRestartDelayMax
+	}
+
+	return delay, true
+}
+
+// containerOOMRestart starts a container stopped for running out of memory
+// once the delay is over, unless it was started or removed in the meantime.
+func containerOOMRestart(c container, delay time.Duration) error {
+	logger.Info("Restarting container after running out of memory", log.Ctx{"container": c.Name(), "project": c.Project(), "delay": delay})
+	time.Sleep(delay)
+
+	c, err := containerLoadByProjectAndName(c.DaemonState(), c.Project(), c.Name())
+	if err != nil {
+		return nil
+	}
+
+	if c.IsRunning() {
+		return nil
+	}
+
+	return c.Start(false)
+}
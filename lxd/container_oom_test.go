@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContainerOOMCountParse(t *testing.T) {
+	// Legacy memory.oom_control
+	kills, ok := containerOOMCountParse("oom_kill_disable 0\nunder_oom 0\noom_kill 3\n")
+	assert.True(t, ok)
+	assert.Equal(t, int64(3), kills)
+
+	// Unified memory.events
+	kills, ok = containerOOMCountParse("low 0\nhigh 0\nmax 12\noom 2\noom_kill 2\n")
+	assert.True(t, ok)
+	assert.Equal(t, int64(2), kills)
+
+	// Kernels not counting the kills
+	_, ok = containerOOMCountParse("oom_kill_disable 0\nunder_oom 1\n")
+	assert.False(t, ok)
+}
+
+func TestContainerOOMCgroupParse(t *testing.T) {
+	legacy := []string{
+		"12:pids:/lxc.payload/c1",
+		"5:memory:/lxc.payload/c1/init.scope",
+		"0::/lxc.payload/c1/init.scope",
+	}
+
+	assert.Equal(t, "/sys/fs/cgroup/memory/lxc.payload/c1", containerOOMCgroupParse(legacy, false))
+	assert.Equal(t, "/sys/fs/cgroup/lxc.payload/c1", containerOOMCgroupParse(legacy, true))
+	assert.Equal(t, "", containerOOMCgroupParse([]string{"12:pids:/lxc.payload/c1"}, false))
+}
+
+func TestContainerOOMRestartBackoff(t *testing.T) {
+	delay, ok := containerOOMRestartBackoff(0)
+	assert.True(t, ok)
+	assert.Equal(t, containerOOMRestartDelay, delay)
+
+	delay, ok = containerOOMRestartBackoff(2)
+	assert.True(t, ok)
+	assert.Equal(t, 4*containerOOMRestartDelay, delay)
+
+	// The delay is capped
+	delay, ok = containerOOMRestartBackoff(containerOOMRestartMax - 1)
+	assert.True(t, ok)
+	assert.Equal(t, containerOOMRestartDelayMax, delay)
+
+	// Until the container isn't restarted anymore
+	_, ok = containerOOMRestartBackoff(containerOOMRestartMax)
+	assert.False(t, ok)
+}
@@ -121,12 +121,16 @@ func containerPatch(d *Daemon, r *http.Request) Response {
 		Ephemeral:    req.Ephemeral,
 		Profiles:     req.Profiles,
 		Project:      project,
+		Admin:        d.userIsAdmin(r),
 	}
 
+	previous := containerRevisionCurrent(c)
 	err = c.Update(args, false)
 	if err != nil {
 		return SmartError(err)
 	}
 
+	containerRevisionRecord(d, c, previous, requestRequester(r))
+
 	return EmptySyncResponse
 }
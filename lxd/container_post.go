@@ -195,7 +195,7 @@ func containerPost(d *Daemon, r *http.Request) Response {
 			return containerPostClusteringMigrate(d, c, name, req.Name, targetNode)
 		}
 
-		ws, err := NewMigrationSource(c, stateful, req.ContainerOnly)
+		ws, err := NewMigrationSource(c, stateful, req.ContainerOnly, req.FreezeSync)
 		if err != nil {
 			return InternalError(err)
 		}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+var containerProcessesCmd = APIEndpoint{
+	Name: "containers/{name}/processes",
+
+	Get: APIEndpointAction{Handler: containerProcessesGet, AccessHandler: AllowProjectPermission("containers", "view")},
+}
+
+// Configuration keys which only administrators may change.
+var containerConfigAdminKeys = []string{"security.debug.host_pidns_view", "migration.hooks.pre-dump", "migration.hooks.post-restore", "security.apparmor.profile", "hooks.pre-start", "hooks.post-start", "hooks.pre-stop", "hooks.post-stop", "security.exec_record", "security.exec_record.transcript"}
+
+// The latest processes of the containers on this node which have
+// security.debug.host_pidns_view set, indexed by container ID.
+var containerProcessesLock sync.Mutex
+var containerProcesses = map[int]api.ContainerProcesses{}
+
+// containerConfigCheckAdmin returns an error if the request, coming from a
+// user who isn't an administrator, changes a key restricted to administrators.
+func containerConfigCheckAdmin(d *Daemon, r *http.Request, oldConfig map[string]string, newConfig map[string]string) error {
+	if d.userIsAdmin(r) {
+		return nil
+	}
+
+	return containerConfigCheckAdminKeys(oldConfig, newConfig)
+}
+
+// containerConfigCheckAdminKeys returns a permission error if the new config
+// changes a key restricted to administrators.
+func containerConfigCheckAdminKeys(oldConfig map[string]string, newConfig map[string]string) error {
+	for _, key := range containerConfigAdminKeys {
+		if oldConfig[key] != newConfig[key] {
+			return errors.Wrapf(os.ErrPermission, "Only administrators can change '%s'", key)
+		}
+	}
+
+	return nil
+}
+
+func containerProcessesTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		containers, err := containerLoadNodeAll(d.State())
+		if err != nil {
+			logger.Error("Failed to load containers for the processes lookup", log.Ctx{"err": err})
+			return
+		}
+
+		seen := map[int]bool{}
+		for _, c := range containers {
+			if c.IsSnapshot() || !shared.IsTrue(c.ExpandedConfig()["security.debug.host_pidns_view"]) || !c.IsRunning() {
+				continue
+			}
+
+			seen[c.Id()] = true
+			containerProcessesUpdate(c)
+		}
+
+		// Forget about the containers which are gone or not watched anymore
+		containerProcessesLock.Lock()
+		for id := range containerProcesses {
+			if !seen[id] {
+				delete(containerProcesses, id)
+			}
+		}
+		containerProcessesLock.Unlock()
+	}
+
+	return f, task.Every(5 * time.Second)
+}
+
+// containerProcessesUpdate records the current processes of a container.
+func containerProcessesUpdate(c container) (api.ContainerProcesses, error) {
+	processes := api.ContainerProcesses{UpdatedAt: time.Now().UTC()}
+
+	list, err := containerProcessesList(c.InitPID())
+	if err != nil {
+		logger.Warn("Failed to list container processes", log.Ctx{"container": c.Name(), "project": c.Project(), "err": err})
+		return processes, err
+	}
+	processes.Processes = list
+
+	containerProcessesLock.Lock()
+	containerProcesses[c.Id()] = processes
+	containerProcessesLock.Unlock()
+
+	return processes, nil
+}
+
+// containerProcessesList returns the processes sharing the PID namespace of
+// the given init process, with the PIDs and thread IDs seen from both the
+// container and the host.
+func containerProcessesList(initPID int) ([]api.ContainerProcess, error) {
+	if initPID <= 0 {
+		return nil, fmt.Errorf("The container isn't running")
+	}
+
+	pidns, err := os.Readlink(fmt.Sprintf("/proc/%d/ns/pid", initPID))
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+
+	processes := []api.ContainerProcess{}
+	hostParents := map[int]int{}
+	containerPIDs := map[int]int{}
+	for _, entry := range entries {
+		hostPID, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		// Processes may exit at any time, skip them
+		ns, err := os.Readlink(fmt.Sprintf("/proc/%d/ns/pid", hostPID))
+		if err != nil || ns != pidns {
+			continue
+		}
+
+		status, err := containerProcessStatus(fmt.Sprintf("/proc/%d/status", hostPID))
+		if err != nil {
+			continue
+		}
+
+		process := api.ContainerProcess{
+			PID:     status.nspid,
+			HostPID: hostPID,
+			Command: status.name,
+			Threads: []api.ContainerProcessThread{},
+		}
+
+		tasks, err := ioutil.ReadDir(fmt.Sprintf("/proc/%d/task", hostPID))
+		if err == nil {
+			for _, t := range tasks {
+				hostTID, err := strconv.Atoi(t.Name())
+				if err != nil {
+					continue
+				}
+
+				taskStatus, err := containerProcessStatus(fmt.Sprintf("/proc/%d/task/%d/status", hostPID, hostTID))
+				if err != nil {
+					continue
+				}
+
+				process.Threads = append(process.Threads, api.ContainerProcessThread{TID: taskStatus.nspid, HostTID: hostTID})
+			}
+		}
+
+		hostParents[hostPID] = status.ppid
+		containerPIDs[hostPID] = status.nspid
+		processes = append(processes, process)
+	}
+
+	// The parent of the container's init is outside of its namespace
+	for i := range processes {
+		processes[i].PPID = containerPIDs[hostParents[processes[i].HostPID]]
+	}
+
+	sort.Slice(processes, func(i, j int) bool { return processes[i].PID < processes[j].PID })
+
+	return processes, nil
+}
+
+type containerProcessStatusInfo struct {
+	name  string
+	ppid  int
+	nspid int
+}
+
+// containerProcessStatus parses the status file of a process or thread. The
+// last NSpid field is the ID in the innermost PID namespace.
+func containerProcessStatus(path string) (containerProcessStatusInfo, error) {
+	info := containerProcessStatusInfo{}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return info, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+
+		switch fields[0] {
+		case "Name:":
+			info.name = fields[1]
+		case "PPid:":
+			info.ppid, _ = strconv.Atoi(fields[1])
+		case "NSpid:":
+			info.nspid, _ = strconv.Atoi(fields[len(fields)-1])
+		}
+	}
+
+	if info.nspid == 0 {
+		return info, fmt.Errorf("No namespaced PID in %s", path)
+	}
+
+	return info, scanner.Err()
+}
+
+func containerProcessesGet(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	// Handle requests targeted to a container on a different node
+	response, err := ForwardedResponseIfContainerIsRemote(d, r, project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+	if response != nil {
+		return response
+	}
+
+	c, err := containerLoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if !shared.IsTrue(c.ExpandedConfig()["security.debug.host_pidns_view"]) {
+		return BadRequest(fmt.Errorf("The processes are only recorded when security.debug.host_pidns_view is set"))
+	}
+
+	if !c.IsRunning() {
+		return BadRequest(fmt.Errorf("The container isn't running"))
+	}
+
+	containerProcessesLock.Lock()
+	processes, ok := containerProcesses[c.Id()]
+	containerProcessesLock.Unlock()
+
+	// The key was just set, don't wait for the next lookup
+	if !ok {
+		processes, err = containerProcessesUpdate(c)
+		if err != nil {
+			return SmartError(err)
+		}
+	}
+
+	return SyncResponse(true, processes)
+}
@@ -59,6 +59,9 @@ func containerPut(d *Daemon, r *http.Request) Response {
 	var do func(*operation) error
 	var opType db.OperationType
 	if configRaw.Restore == "" {
+		requester := requestRequester(r)
+		admin := d.userIsAdmin(r)
+
 		// Update container configuration
 		do = func(op *operation) error {
 			args := db.ContainerArgs{
@@ -69,14 +72,18 @@ func containerPut(d *Daemon, r *http.Request) Response {
 				Ephemeral:    configRaw.Ephemeral,
 				Profiles:     configRaw.Profiles,
 				Project:      project,
+				Admin:        admin,
 			}
 
 			// FIXME: should set to true when not migrating
+			previous := containerRevisionCurrent(c)
 			err = c.Update(args, false)
 			if err != nil {
 				return err
 			}
 
+			containerRevisionRecord(d, c, previous, requester)
+
 			return nil
 		}
 
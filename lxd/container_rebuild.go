@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+)
+
+var containerRebuildCmd = APIEndpoint{
+	Name: "containers/{name}/rebuild",
+
+	Post: APIEndpointAction{Handler: containerRebuildPost, AccessHandler: AllowProjectPermission("containers", "manage-containers")},
+}
+
+func containerRebuildPost(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	// Handle requests targeted to a container on a different node
+	response, err := ForwardedResponseIfContainerIsRemote(d, r, project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+	if response != nil {
+		return response
+	}
+
+	req := api.ContainerRebuildPost{}
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	if req.Source.Type != "image" {
+		return BadRequest(fmt.Errorf("Containers can only be rebuilt from an image"))
+	}
+
+	var hash string
+	if req.Source.Fingerprint != "" {
+		hash = req.Source.Fingerprint
+	} else if req.Source.Alias != "" {
+		if req.Source.Server != "" {
+			hash = req.Source.Alias
+		} else {
+			_, alias, err := d.cluster.ImageAliasGet(project, req.Source.Alias, true)
+			if err != nil {
+				return SmartError(err)
+			}
+
+			hash = alias.Target
+		}
+	} else {
+		return BadRequest(fmt.Errorf("Must specify one of alias or fingerprint to rebuild from an image"))
+	}
+
+	c, err := containerLoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if c.IsRunning() {
+		return BadRequest(fmt.Errorf("The container must be stopped to be rebuilt"))
+	}
+
+	if shared.IsTrue(c.ExpandedConfig()["security.protection.delete"]) {
+		return BadRequest(fmt.Errorf("Container is protected"))
+	}
+
+	// The root filesystem is recreated from scratch, which the snapshots
+	// of most storage backends depend on.
+	snapshots, err := c.Snapshots()
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if len(snapshots) > 0 {
+		return BadRequest(fmt.Errorf("Containers with snapshots can't be rebuilt"))
+	}
+
+	run := func(op *operation) error {
+		var info *api.Image
+		if req.Source.Server != "" {
+			autoUpdate, err := cluster.ConfigGetBool(d.cluster, "images.auto_update_cached")
+			if err != nil {
+				return err
+			}
+
+			info, err = d.ImageDownload(
+				op, req.Source.Server, req.Source.Protocol, req.Source.Certificate,
+				req.Source.Secret, hash, true, autoUpdate, "", true, project)
+			if err != nil {
+				return err
+			}
+		} else {
+			_, info, err = d.cluster.ImageGet(project, hash, false, false)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = containerImageMakeLocal(d, project, info.Fingerprint)
+		if err != nil {
+			return err
+		}
+
+		return c.Rebuild(info.Fingerprint)
+	}
+
+	resources := map[string][]string{}
+	resources["containers"] = []string{name}
+
+	op, err := operationCreate(d.cluster, project, operationClassTask, db.OperationContainerRebuild, resources, nil, run, nil, nil)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	return OperationResponse(op)
+}
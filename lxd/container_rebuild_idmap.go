@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/db"
+)
+
+var containerRebuildIdmapCmd = APIEndpoint{
+	Name: "containers/{name}/rebuild-idmap",
+
+	Post: APIEndpointAction{Handler: containerRebuildIdmapPost, AccessHandler: AllowProjectPermission("containers", "manage-containers")},
+}
+
+func containerRebuildIdmapPost(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	// Handle requests targeted to a container on a different node
+	response, err := ForwardedResponseIfContainerIsRemote(d, r, project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+	if response != nil {
+		return response
+	}
+
+	c, err := containerLoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if c.IsRunning() {
+		return BadRequest(fmt.Errorf("The container must be stopped to be remapped"))
+	}
+
+	run := func(op *operation) error {
+		c.SetOperation(op)
+		return c.RemapRootfs()
+	}
+
+	resources := map[string][]string{}
+	resources["containers"] = []string{name}
+
+	op, err := operationCreate(d.cluster, project, operationClassTask, db.OperationContainerIdmapRebuild, resources, nil, run, nil, nil)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	return OperationResponse(op)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/state"
+)
+
+// renderWorkersDefault is the number of workers used when core.render_workers
+// can't be loaded.
+const renderWorkersDefault = 4
+
+// renderWorkersGet returns the number of workers to render containers with,
+// taken from core.render_workers.
+func renderWorkersGet(c *db.Cluster) int {
+	workers := int64(renderWorkersDefault)
+
+	err := c.Transaction(func(tx *db.ClusterTx) error {
+		config, err := cluster.ConfigLoad(tx)
+		if err != nil {
+			return err
+		}
+
+		workers = config.RenderWorkers()
+		return nil
+	})
+	if err != nil || workers < 1 {
+		return renderWorkersDefault
+	}
+
+	return int(workers)
+}
+
+// containerRenderPreload loads the snapshots and backups of the local
+// containers of a project at once, for them to be fully rendered without
+// queries of their own.
+func containerRenderPreload(s *state.State, project string, containers []container) error {
+	var snapArgs []db.Instance
+	var backupArgs map[int][]db.ContainerBackupArgs
+	err := s.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		snapArgs, err = tx.ContainerNodeProjectSnapshotList(project)
+		if err != nil {
+			return err
+		}
+
+		backupArgs, err = tx.ContainerProjectBackups(project)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	snaps, err := containerLoadAllInternal(snapArgs, s)
+	if err != nil {
+		return err
+	}
+
+	parentSnaps := map[string][]container{}
+	for _, snap := range snaps {
+		parentName, _, _ := containerGetParentAndSnapshotName(snap.Name())
+		parentSnaps[parentName] = append(parentSnaps[parentName], snap)
+	}
+
+	for _, c := range containers {
+		ct, ok := c.(*containerLXC)
+		if !ok {
+			continue
+		}
+
+		ct.snapshots = parentSnaps[c.Name()]
+		if ct.snapshots == nil {
+			ct.snapshots = []container{}
+		}
+
+		ct.backups = ct.backupsFromArgs(backupArgs[c.Id()])
+	}
+
+	return nil
+}
+
+// renderParallel calls render for each index in [0, count) from a pool of at
+// most workers goroutines, so that the callers can fill a pre-sized slice
+// while keeping their order. The first error is returned once all the workers
+// are done.
+func renderParallel(workers int, count int, render func(i int) error) error {
+	if workers > count {
+		workers = count
+	}
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	queue := make(chan int, workers)
+	wg := sync.WaitGroup{}
+	errMu := sync.Mutex{}
+	var renderErr error
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range queue {
+				err := render(i)
+				if err != nil {
+					errMu.Lock()
+					if renderErr == nil {
+						renderErr = err
+					}
+					errMu.Unlock()
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < count; i++ {
+		queue <- i
+	}
+
+	close(queue)
+	wg.Wait()
+
+	return renderErr
+}
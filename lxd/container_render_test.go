@@ -0,0 +1,51 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderParallel(t *testing.T) {
+	results := make([]int, 100)
+
+	err := renderParallel(8, len(results), func(i int) error {
+		results[i] = i * 2
+		return nil
+	})
+	assert.NoError(t, err)
+
+	for i, result := range results {
+		assert.Equal(t, i*2, result)
+	}
+
+	err = renderParallel(8, len(results), func(i int) error {
+		if i == 42 {
+			return fmt.Errorf("Failed to render %d", i)
+		}
+
+		return nil
+	})
+	assert.EqualError(t, err, "Failed to render 42")
+
+	assert.NoError(t, renderParallel(4, 0, func(i int) error { return nil }))
+}
+
+// Each render is simulated by hashing the same buffer, for the benchmarks to
+// only depend on the number of workers and of available CPUs.
+func benchmarkRenderParallel(b *testing.B, workers int) {
+	buf := make([]byte, 64*1024)
+
+	for n := 0; n < b.N; n++ {
+		renderParallel(workers, 100, func(i int) error {
+			sha256.Sum256(buf)
+			return nil
+		})
+	}
+}
+
+func BenchmarkRenderParallel1(b *testing.B)  { benchmarkRenderParallel(b, 1) }
+func BenchmarkRenderParallel4(b *testing.B)  { benchmarkRenderParallel(b, 4) }
+func BenchmarkRenderParallel16(b *testing.B) { benchmarkRenderParallel(b, 16) }
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	log "github.com/lxc/lxd/shared/log15"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+var containerRespawnCmd = APIEndpoint{
+	Name: "containers/{name}/respawn",
+
+	Post: APIEndpointAction{Handler: containerRespawnPost, AccessHandler: AllowProjectPermission("containers", "manage-containers")},
+}
+
+func containerRespawnPost(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	// Handle requests targeted to a container on a different node
+	response, err := ForwardedResponseIfContainerIsRemote(d, r, project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+	if response != nil {
+		return response
+	}
+
+	req := api.ContainerRespawnPost{}
+	if r.ContentLength != 0 {
+		err = json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			return BadRequest(err)
+		}
+	}
+
+	c, err := containerLoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if shared.IsTrue(c.ExpandedConfig()["security.protection.delete"]) {
+		return BadRequest(fmt.Errorf("Container is protected"))
+	}
+
+	// Stopping an ephemeral container deletes it
+	if c.IsEphemeral() {
+		return BadRequest(fmt.Errorf("Ephemeral containers can't be respawned"))
+	}
+
+	fingerprint := c.LocalConfig()["volatile.base_image"]
+	if fingerprint == "" {
+		return BadRequest(fmt.Errorf("The container wasn't created from an image"))
+	}
+
+	// The root filesystem is recreated from scratch, which the snapshots
+	// of most storage backends depend on.
+	snapshots, err := c.Snapshots()
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if len(snapshots) > 0 {
+		return BadRequest(fmt.Errorf("Containers with snapshots can't be respawned"))
+	}
+
+	run := func(op *operation) error {
+		return containerRespawn(d, c, fingerprint, req.Timeout)
+	}
+
+	resources := map[string][]string{}
+	resources["containers"] = []string{name}
+
+	op, err := operationCreate(d.cluster, project, operationClassTask, db.OperationContainerRespawn, resources, nil, run, nil, nil)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	return OperationResponse(op)
+}
+
+// containerRespawn stops the container, recreates its root filesystem from
+// its image, gives it a new identity and starts it again. Its volume and
+// snapshots are kept, making this much cheaper than deleting the container and
+// creating a new one.
+func containerRespawn(d *Daemon, c container, fingerprint string, timeout int) error {
+	// Stop the container, killing it unless a clean shutdown is requested
+	if c.IsRunning() {
+		var err error
+		if timeout > 0 {
+			err = c.Shutdown(time.Duration(timeout) * time.Second)
+		}
+
+		if timeout <= 0 || err != nil {
+			err = c.Stop(false)
+		}
+
+		if err != nil {
+			return errors.Wrap(err, "Stop container")
+		}
+	}
+
+	err := containerImageMakeLocal(d, c.Project(), fingerprint)
+	if err != nil {
+		return err
+	}
+
+	err = containerRootfsReplace(c, fingerprint)
+	if err != nil {
+		return err
+	}
+
+	// The new files are unshifted and the generated MAC addresses are
+	// replaced on next start.
+	changes := map[string]string{
+		"volatile.last_state.idmap": "[]",
+		"volatile.last_state.power": "",
+	}
+
+	for k := range c.LocalConfig() {
+		if !strings.HasPrefix(k, "volatile.") || !strings.HasSuffix(k, ".hwaddr") {
+			continue
+		}
+
+		devName := strings.TrimSuffix(strings.TrimPrefix(k, "volatile."), ".hwaddr")
+		if c.ExpandedDevices()[devName]["hwaddr"] == "" {
+			changes[k] = ""
+		}
+	}
+
+	err = c.VolatileSet(changes)
+	if err != nil {
+		return err
+	}
+
+	err = d.cluster.ImageLastAccessUpdate(fingerprint, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	return c.Start(false)
+}
+
+// containerRootfsReplace replaces the root filesystem and templates of a
+// stopped container with the ones of the given image. The image is unpacked
+// next to them on the container's volume and only swapped in once complete,
+// so a failure leaves the container as it was.
+func containerRootfsReplace(c container, fingerprint string) error {
+	release := storagePoolOperationStart(c.Storage(), c)
+	defer release()
+
+	ourStart, err := c.StorageStart()
+	if err != nil {
+		return err
+	}
+
+	if ourStart {
+		defer c.StorageStop()
+	}
+
+	// Clear the leftovers of an interrupted replacement
+	newPath := filepath.Join(c.Path(), ".rootfs-replace.new")
+	oldPath := filepath.Join(c.Path(), ".rootfs-replace.old")
+	for _, path := range []string{newPath, oldPath} {
+		err = os.RemoveAll(path)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = os.Mkdir(newPath, 0700)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(newPath)
+
+	err = os.Mkdir(oldPath, 0700)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(oldPath)
+
+	err = unpackImage(shared.VarPath("images", fingerprint), newPath, c.Storage().GetStorageType(), c.DaemonState().OS.RunningInUserNS, nil)
+	if err != nil {
+		return errors.Wrap(err, "Unpack image")
+	}
+
+	// Swap the files, putting the current ones back if any move fails
+	movedOut := []string{}
+	movedIn := []string{}
+	restore := func() {
+		for _, name := range movedIn {
+			os.RemoveAll(filepath.Join(c.Path(), name))
+		}
+
+		for _, name := range movedOut {
+			os.Rename(filepath.Join(oldPath, name), filepath.Join(c.Path(), name))
+		}
+	}
+
+	for _, name := range []string{"rootfs", "templates", "metadata.yaml"} {
+		if shared.PathExists(filepath.Join(c.Path(), name)) {
+			err = os.Rename(filepath.Join(c.Path(), name), filepath.Join(oldPath, name))
+			if err != nil {
+				restore()
+				return errors.Wrapf(err, "Move out %s", name)
+			}
+
+			movedOut = append(movedOut, name)
+		}
+
+		if shared.PathExists(filepath.Join(newPath, name)) {
+			err = os.Rename(filepath.Join(newPath, name), filepath.Join(c.Path(), name))
+			if err != nil {
+				restore()
+				return errors.Wrapf(err, "Move in %s", name)
+			}
+
+			movedIn = append(movedIn, name)
+		}
+	}
+
+	err = os.RemoveAll(oldPath)
+	if err != nil {
+		logger.Warn("Failed to remove the replaced root filesystem", log.Ctx{"project": c.Project(), "name": c.Name(), "err": err})
+	}
+
+	err = c.TemplateApply("create")
+	if err != nil {
+		return errors.Wrap(err, "Apply template")
+	}
+
+	err = containerConfigureInternal(c)
+	if err != nil {
+		return errors.Wrap(err, "Configure container")
+	}
+
+	return nil
+}
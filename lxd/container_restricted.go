@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// The GET endpoints whose raw content restricted users may retrieve, as it
+// comes from the container or image rather than from its configuration.
+var restrictedRawEndpoints = []string{
+	"containers/{name}/console",
+	"containers/{name}/files",
+	"containers/{name}/metadata/templates",
+	"images/{fingerprint}/export",
+}
+
+// Configuration keys left out for restricted users.
+var restrictedConfigPrefixes = []string{"environment.", "raw.", "user."}
+
+// Device keys left out for restricted users.
+var restrictedDeviceKeys = []string{"source"}
+
+// containerRestrictFields removes the sensitive configuration keys and device
+// source paths from decoded JSON, wherever they are nested, which covers the
+// containers as well as their snapshots, revisions, backups or operations.
+func containerRestrictFields(data interface{}) interface{} {
+	switch v := data.(type) {
+	case []interface{}:
+		for i := range v {
+			v[i] = containerRestrictFields(v[i])
+		}
+	case map[string]interface{}:
+		for k, value := range v {
+			switch k {
+			case "config", "expanded_config":
+				config, ok := value.(map[string]interface{})
+				if !ok {
+					continue
+				}
+
+				for key := range config {
+					for _, prefix := range restrictedConfigPrefixes {
+						if strings.HasPrefix(key, prefix) {
+							delete(config, key)
+							break
+						}
+					}
+				}
+			case "devices", "expanded_devices":
+				devices, ok := value.(map[string]interface{})
+				if !ok {
+					continue
+				}
+
+				for _, device := range devices {
+					m, ok := device.(map[string]interface{})
+					if !ok {
+						continue
+					}
+
+					for _, key := range restrictedDeviceKeys {
+						delete(m, key)
+					}
+				}
+			default:
+				v[k] = containerRestrictFields(value)
+			}
+		}
+	}
+
+	return data
+}
+
+// containerRestrictMetadata returns the metadata of a response without the
+// sensitive fields, going through its JSON form as it may be of any type.
+func containerRestrictMetadata(metadata interface{}) (interface{}, error) {
+	if metadata == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	var decoded interface{}
+	err = json.Unmarshal(data, &decoded)
+	if err != nil {
+		return nil, err
+	}
+
+	return containerRestrictFields(decoded), nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+func TestContainerRestrictFields(t *testing.T) {
+	input := `{
+  "name": "c1",
+  "status": "Running",
+  "config": {"limits.cpu": "2", "environment.TOKEN": "secret", "user.meta-data": "x"},
+  "expanded_config": {"raw.lxc": "lxc.aa_profile=unconfined", "limits.cpu": "2"},
+  "devices": {"data": {"type": "disk", "source": "/srv/data", "path": "/data"}},
+  "snapshots": [{"name": "snap0", "config": {"user.foo": "bar", "security.nesting": "true"}}]
+}`
+
+	var data interface{}
+	require.NoError(t, json.Unmarshal([]byte(input), &data))
+
+	ct := containerRestrictFields(data).(map[string]interface{})
+	assert.Equal(t, "c1", ct["name"])
+	assert.Equal(t, "Running", ct["status"])
+	assert.Equal(t, map[string]interface{}{"limits.cpu": "2"}, ct["config"])
+	assert.Equal(t, map[string]interface{}{"limits.cpu": "2"}, ct["expanded_config"])
+	assert.Equal(t, map[string]interface{}{"type": "disk", "path": "/data"}, ct["devices"].(map[string]interface{})["data"])
+
+	snapshot := ct["snapshots"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"security.nesting": "true"}, snapshot["config"])
+}
+
+func TestRestrictedResponse(t *testing.T) {
+	metadata := map[string]interface{}{
+		"id":     "op1",
+		"status": "Running",
+		"metadata": map[string]interface{}{
+			"config": map[string]string{"environment.TOKEN": "secret", "limits.cpu": "2"},
+		},
+	}
+
+	rec := httptest.NewRecorder()
+	require.NoError(t, RestrictedResponse(SyncResponseETag(true, metadata, "etag"), false).Render(rec))
+	assert.Empty(t, rec.Header().Get("ETag"))
+	assert.NotContains(t, rec.Body.String(), "secret")
+	assert.Contains(t, rec.Body.String(), "limits.cpu")
+
+	// Raw content is refused unless allowed
+	rec = httptest.NewRecorder()
+	require.NoError(t, RestrictedResponse(ChunkResponse("/nonexistent", 0, 1, ""), false).Render(rec))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestRestrictedResponseConfigSnapshots(t *testing.T) {
+	snapshots := []api.ContainerConfigSnapshot{{
+		Name:     "before-limits",
+		Revision: 3,
+		Config:   map[string]string{"environment.TOKEN": "secret", "raw.lxc": "lxc.aa_profile=unconfined", "limits.cpu": "2"},
+		Devices:  map[string]map[string]string{"data": {"type": "disk", "source": "/srv/data", "path": "/data"}},
+	}}
+
+	rec := httptest.NewRecorder()
+	require.NoError(t, RestrictedResponse(SyncResponse(true, snapshots), false).Render(rec))
+	assert.NotContains(t, rec.Body.String(), "secret")
+	assert.NotContains(t, rec.Body.String(), "raw.lxc")
+	assert.NotContains(t, rec.Body.String(), "/srv/data")
+	assert.Contains(t, rec.Body.String(), "limits.cpu")
+	assert.Contains(t, rec.Body.String(), "before-limits")
+}
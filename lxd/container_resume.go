@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// A containerResumeHook nudges the time-dependent software of a container
+// which was just unfrozen or restored, given how long it was suspended.
+type containerResumeHook func(c container, skew time.Duration) error
+
+// The hooks run, in order, after a container resumed.
+var containerResumeHooks = []containerResumeHook{
+	containerResumeNotify,
+	containerResumeCommand,
+}
+
+// containerSuspended records when a container got frozen or checkpointed.
+func containerSuspended(c container) {
+	err := c.VolatileSet(map[string]string{"volatile.last_state.suspended": time.Now().UTC().Format(time.RFC3339Nano)})
+	if err != nil {
+		logger.Warn("Failed to record container suspend time", log.Ctx{"container": c.Name(), "project": c.Project(), "err": err})
+	}
+}
+
+// containerResumed records the clock skew of a container which was just
+// resumed in the metadata of the operation, if any, and runs the resume
+// hooks. The skew is computed from the given time, or from the recorded
+// suspend time when it's zero.
+func containerResumed(c container, op *operation, since time.Time) {
+	value := c.LocalConfig()["volatile.last_state.suspended"]
+	if value != "" {
+		err := c.VolatileSet(map[string]string{"volatile.last_state.suspended": ""})
+		if err != nil {
+			logger.Warn("Failed to clear container suspend time", log.Ctx{"container": c.Name(), "project": c.Project(), "err": err})
+		}
+	}
+
+	if since.IsZero() {
+		if value == "" {
+			return
+		}
+
+		var err error
+		since, err = time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			logger.Warn("Invalid container suspend time", log.Ctx{"container": c.Name(), "project": c.Project(), "err": err})
+			return
+		}
+	}
+
+	skew := time.Since(since)
+	logger.Debug("Container resumed", log.Ctx{"container": c.Name(), "project": c.Project(), "skew": skew})
+
+	if op != nil {
+		metadata := op.metadata
+		if metadata == nil {
+			metadata = map[string]interface{}{}
+		}
+
+		metadata["clock_skew"] = skew.Seconds()
+		op.UpdateMetadata(metadata)
+	}
+
+	for _, hook := range containerResumeHooks {
+		err := hook(c, skew)
+		if err != nil {
+			logger.Warn("Failed to run container resume hook", log.Ctx{"container": c.Name(), "project": c.Project(), "err": err})
+		}
+	}
+}
+
+// containerResumeNotify sends a resume event with the skew to devlxd, when
+// boot.resume.notify is set.
+func containerResumeNotify(c container, skew time.Duration) error {
+	if !shared.IsTrue(c.ExpandedConfig()["boot.resume.notify"]) {
+		return nil
+	}
+
+	return devlxdEventSend(c, "resume", map[string]interface{}{"clock_skew": skew.Seconds()})
+}
+
+// containerResumeCommand runs boot.resume.command in the container, with the
+// skew in seconds in the LXD_CLOCK_SKEW environment variable. It isn't waited
+// for, only failures are logged.
+func containerResumeCommand(c container, skew time.Duration) error {
+	command := c.ExpandedConfig()["boot.resume.command"]
+	if command == "" {
+		return nil
+	}
+
+	devNull, err := os.OpenFile(os.DevNull, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+
+	args := []string{"sh", "-c", command}
+	env := execEnvironment(c, map[string]string{"LXD_CLOCK_SKEW": fmt.Sprintf("%.3f", skew.Seconds())}, 0)
+	cmd, _, _, err := c.Exec(args, env, devNull, devNull, devNull, false, "", 0, 0)
+	if err != nil {
+		devNull.Close()
+		return err
+	}
+
+	go func() {
+		defer devNull.Close()
+
+		ret, err := execWait(cmd)
+		if err == nil && ret != 0 {
+			err = fmt.Errorf("Command exited with status %d", ret)
+		}
+
+		if err != nil {
+			logger.Warn("Failed to run container resume command", log.Ctx{"container": c.Name(), "project": c.Project(), "err": err})
+		}
+	}()
+
+	return nil
+}
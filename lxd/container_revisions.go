@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/osarch"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+var containerRevisionsCmd = APIEndpoint{
+	Name: "containers/{name}/revisions",
+
+	Get:  APIEndpointAction{Handler: containerRevisionsGet, AccessHandler: AllowProjectPermission("containers", "view")},
+	Post: APIEndpointAction{Handler: containerRevisionsPost, AccessHandler: AllowProjectPermission("containers", "manage-containers")},
+}
+
+// Number of configuration revisions kept for each container.
+const containerRevisionsSize = 20
+
+// requestRequester returns who sent an API request, for the records.
+func requestRequester(r *http.Request) string {
+	username, _ := r.Context().Value("username").(string)
+	if username != "" {
+		return username
+	}
+
+	if r.RemoteAddr == "@" {
+		return "unix"
+	}
+
+	return r.RemoteAddr
+}
+
+// containerRevisionCurrent returns the current configuration of a container,
+// leaving out the volatile keys which LXD manages.
+func containerRevisionCurrent(c container) db.ContainerRevision {
+	localConfig := map[string]string{}
+	for k, v := range c.LocalConfig() {
+		if strings.HasPrefix(k, "volatile.") {
+			continue
+		}
+
+		localConfig[k] = v
+	}
+
+	devices := config.Devices{}
+	for name, m := range c.LocalDevices() {
+		devices[name] = config.Device{}
+		for k, v := range m {
+			devices[name][k] = v
+		}
+	}
+
+	return db.ContainerRevision{
+		Date:         time.Now().UTC(),
+		Architecture: c.Architecture(),
+		Description:  c.Description(),
+		Ephemeral:    c.IsEphemeral(),
+		Config:       localConfig,
+		Devices:      devices,
+		Profiles:     c.Profiles(),
+	}
+}
+
+// containerRevisionRecord records the configuration resulting from an update.
+// The configuration it replaced is recorded first when the container has no
+// revision yet, so that the first update can be rolled back too. Failures are
+// only logged, the update itself went through.
+func containerRevisionRecord(d *Daemon, c container, previous db.ContainerRevision, requester string) {
+	current := containerRevisionCurrent(c)
+	current.Requester = requester
+
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		revisions, err := tx.ContainerRevisions(c.Id())
+		if err != nil {
+			return err
+		}
+
+		if len(revisions) == 0 {
+			_, err = tx.ContainerRevisionAdd(c.Id(), previous, containerRevisionsSize)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.ContainerRevisionAdd(c.Id(), current, containerRevisionsSize)
+		return err
+	})
+	if err != nil {
+		logger.Warn("Failed to record container revision", log.Ctx{"container": c.Name(), "project": c.Project(), "err": err})
+	}
+}
+
+// containerRevisionApply applies a revision to a container like any other
+// update, recording the result as a new revision. Only administrators may
+// change the restricted keys.
+func containerRevisionApply(d *Daemon, c container, revision db.ContainerRevision, requester string, admin bool) error {
+	// The volatile keys aren't part of the revisions and are kept
+	newConfig := map[string]string{}
+	for k, v := range c.LocalConfig() {
+		if strings.HasPrefix(k, "volatile.") {
+			newConfig[k] = v
+		}
+	}
+
+	for k, v := range revision.Config {
+		newConfig[k] = v
+	}
+
+	args := db.ContainerArgs{
+		Architecture: revision.Architecture,
+		Config:       newConfig,
+		Description:  revision.Description,
+		Devices:      revision.Devices,
+		Ephemeral:    revision.Ephemeral,
+		Profiles:     revision.Profiles,
+		Project:      c.Project(),
+		Admin:        admin,
+	}
+
+	previous := containerRevisionCurrent(c)
+	err := c.Update(args, true)
+	if err != nil {
+		return err
+	}
+
+	containerRevisionRecord(d, c, previous, requester)
+	return nil
+}
+
+func containerRevisionsGet(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	c, err := containerLoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	var revisions []db.ContainerRevision
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		revisions, err = tx.ContainerRevisions(c.Id())
+		return err
+	})
+	if err != nil {
+		return SmartError(err)
+	}
+
+	result := []api.ContainerRevision{}
+	for _, revision := range revisions {
+		architecture, _ := osarch.ArchitectureName(revision.Architecture)
+
+		result = append(result, api.ContainerRevision{
+			Revision:     revision.Revision,
+			CreatedAt:    revision.Date,
+			Requester:    revision.Requester,
+			Architecture: architecture,
+			Description:  revision.Description,
+			Ephemeral:    revision.Ephemeral,
+			Config:       revision.Config,
+			Devices:      revision.Devices,
+			Profiles:     revision.Profiles,
+			Snapshot:     revision.Snapshot,
+		})
+	}
+
+	return SyncResponse(true, result)
+}
+
+func containerRevisionsPost(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	// Handle requests targeted to a container on a different node
+	response, err := ForwardedResponseIfContainerIsRemote(d, r, project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+	if response != nil {
+		return response
+	}
+
+	req := api.ContainerRevisionsPost{}
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	c, err := containerLoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	var revision db.ContainerRevision
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		revision, err = tx.ContainerRevisionGet(c.Id(), req.Revision)
+		return err
+	})
+	if err != nil {
+		if err == db.ErrNoSuchObject {
+			return NotFound(fmt.Errorf("Revision %d not found", req.Revision))
+		}
+
+		return SmartError(err)
+	}
+
+	requester := requestRequester(r)
+	admin := d.userIsAdmin(r)
+
+	run := func(op *operation) error {
+		return containerRevisionApply(d, c, revision, requester, admin)
+	}
+
+	resources := map[string][]string{}
+	resources["containers"] = []string{name}
+
+	op, err := operationCreate(d.cluster, project, operationClassTask, db.OperationContainerUpdate, resources, nil, run, nil, nil)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	return OperationResponse(op)
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
+
+	lxd "github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+)
+
+var containerSecretsCmd = APIEndpoint{
+	Name: "containers/{name}/secrets",
+
+	Get: APIEndpointAction{Handler: containerSecretsGet, AccessHandler: AllowProjectPermission("containers", "view")},
+}
+
+var containerSecretCmd = APIEndpoint{
+	Name: "containers/{name}/secrets/{secret}",
+
+	Delete: APIEndpointAction{Handler: containerSecretDelete, AccessHandler: AllowProjectPermission("containers", "manage-containers")},
+	Put:    APIEndpointAction{Handler: containerSecretPut, AccessHandler: AllowProjectPermission("containers", "manage-containers")},
+}
+
+var internalClusterSecretsKeyCmd = APIEndpoint{
+	Name: "cluster/secrets-key",
+
+	Get: APIEndpointAction{Handler: internalClusterSecretsKeyGet},
+}
+
+var containerSecretsKeyLock sync.Mutex
+
+// containerSecretsKey returns the key encrypting the secrets, which all the
+// members of a cluster share. A member missing it gets it from the others and
+// it's generated the first time none has it.
+func containerSecretsKey(s *state.State) ([]byte, error) {
+	containerSecretsKeyLock.Lock()
+	defer containerSecretsKeyLock.Unlock()
+
+	key, err := util.SecretKeyLoad(s.OS.VarDir)
+	if err == nil {
+		return key, nil
+	}
+
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	notifier, err := cluster.NewNotifier(s, s.Endpoints.NetworkCert(), cluster.NotifyAll)
+	if err != nil {
+		return nil, err
+	}
+
+	keyLock := sync.Mutex{}
+	err = notifier(func(client lxd.ContainerServer) error {
+		resp, _, err := client.RawQuery("GET", "/internal/cluster/secrets-key", nil, "")
+		if err != nil {
+			return err
+		}
+
+		var peerKey []byte
+		err = resp.MetadataAsStruct(&peerKey)
+		if err != nil {
+			return err
+		}
+
+		keyLock.Lock()
+		if peerKey != nil {
+			key = peerKey
+		}
+		keyLock.Unlock()
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if key == nil {
+		key, err = util.SecretKeyGenerate()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = util.SecretKeyWrite(s.OS.VarDir, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// internalClusterSecretsKeyGet returns the key encrypting the secrets to
+// another member of the cluster, none if this member doesn't have it yet.
+func internalClusterSecretsKeyGet(d *Daemon, r *http.Request) Response {
+	key, err := util.SecretKeyLoad(d.os.VarDir)
+	if err != nil && !os.IsNotExist(err) {
+		return SmartError(err)
+	}
+
+	return SyncResponse(true, key)
+}
+
+// containerSecretValidName checks that a secret can be passed to the
+// container as an environment variable.
+func containerSecretValidName(name string) error {
+	if name == "" || strings.ContainsAny(name, "=/ \t\n\x00") {
+		return fmt.Errorf("Invalid secret name %q", name)
+	}
+
+	return nil
+}
+
+// containerSecretsLoad returns the decrypted secrets of the container.
+func containerSecretsLoad(s *state.State, c container) (map[string]string, error) {
+	var secrets map[string]string
+	err := s.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		secrets, err = tx.ContainerSecrets(c.Id())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(secrets) == 0 {
+		return secrets, nil
+	}
+
+	key, err := containerSecretsKey(s)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, secret := range secrets {
+		secrets[name], err = util.SecretDecrypt(key, secret)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to load secret %q: %v", name, err)
+		}
+	}
+
+	return secrets, nil
+}
+
+// containerSecretsPath returns the path of the liblxc configuration file
+// passing the secrets to a running container.
+func containerSecretsPath(c container) string {
+	return filepath.Join(c.DevicesPath(), "secrets.conf")
+}
+
+// containerSecretsSetup writes the secrets of a starting container to a file
+// only readable by root, returning its path or an empty string if there are
+// none. It's included from the liblxc configuration, which is kept in the log
+// directory of the container and thus never holds their values.
+func containerSecretsSetup(s *state.State, c container) (string, error) {
+	err := containerSecretsRemove(c)
+	if err != nil {
+		return "", err
+	}
+
+	secrets, err := containerSecretsLoad(s, c)
+	if err != nil {
+		return "", err
+	}
+
+	if len(secrets) == 0 {
+		return "", nil
+	}
+
+	if !shared.PathExists(c.DevicesPath()) {
+		err := os.Mkdir(c.DevicesPath(), 0711)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	content := ""
+	for k, v := range secrets {
+		content += fmt.Sprintf("lxc.environment = %s=%s\n", k, v)
+	}
+
+	path := containerSecretsPath(c)
+	err = ioutil.WriteFile(path, []byte(content), 0600)
+	if err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// containerSecretsRemove removes the secrets of a container once stopped.
+func containerSecretsRemove(c container) error {
+	err := os.Remove(containerSecretsPath(c))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// containerSecretsGet only returns the names of the secrets, their values
+// never leave the daemon.
+func containerSecretsGet(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	var names []string
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		id, err := tx.InstanceID(project, name)
+		if err != nil {
+			return err
+		}
+
+		names, err = tx.ContainerSecretNames(int(id))
+		return err
+	})
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return SyncResponse(true, names)
+}
+
+// containerSecretPut sets or rotates a secret. The new value is only seen by
+// the container the next time it starts.
+func containerSecretPut(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+	secretName := mux.Vars(r)["secret"]
+
+	err := containerSecretValidName(secretName)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	req := api.ContainerSecretPut{}
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	// Each secret is a line of the liblxc configuration
+	if strings.ContainsAny(req.Value, "\n\x00") {
+		return BadRequest(fmt.Errorf("Secret values can't contain new lines"))
+	}
+
+	key, err := containerSecretsKey(d.State())
+	if err != nil {
+		return SmartError(err)
+	}
+
+	secret, err := util.SecretEncrypt(key, req.Value)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		id, err := tx.InstanceID(project, name)
+		if err != nil {
+			return err
+		}
+
+		return tx.ContainerSecretSet(int(id), secretName, secret)
+	})
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return EmptySyncResponse
+}
+
+func containerSecretDelete(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+	secretName := mux.Vars(r)["secret"]
+
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		id, err := tx.InstanceID(project, name)
+		if err != nil {
+			return err
+		}
+
+		return tx.ContainerSecretDelete(int(id), secretName)
+	})
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return EmptySyncResponse
+}
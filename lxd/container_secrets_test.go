@@ -0,0 +1,14 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContainerSecretValidName(t *testing.T) {
+	assert.NoError(t, containerSecretValidName("DB_PASSWORD"))
+	assert.Error(t, containerSecretValidName(""))
+	assert.Error(t, containerSecretValidName("A=B"))
+	assert.Error(t, containerSecretValidName("A B"))
+}
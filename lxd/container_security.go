@@ -0,0 +1,358 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/sys/unix"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+var containerSecurityTestCmd = APIEndpoint{
+	Name: "containers/{name}/security/test",
+
+	Post: APIEndpointAction{Handler: containerSecurityTestPost, AccessHandler: AllowProjectPermission("containers", "manage-containers")},
+}
+
+// Default and maximum number of seconds a policy is tested for.
+const containerSecurityTestDuration = 60
+const containerSecurityTestMaxDuration = 600
+
+// The containers currently running a policy test, indexed by ID.
+var containerSecurityTestsLock sync.Mutex
+var containerSecurityTests = map[int]bool{}
+
+// Fields of the audit messages.
+var securityAuditField = regexp.MustCompile(`(\w+)=("[^"]*"|\S+)`)
+
+// The deny rules which can be tested, being the ones of the classes of
+// accesses that the base profile allows unless explicitly denied. Auditing
+// them instead of denying them can't grant anything the container doesn't
+// already have.
+var securityTestRule = regexp.MustCompile(`^deny\s+((owner\s+)?(/|@\{|"/)|(capability|network|dbus)(\s|,))`)
+
+func containerSecurityTestPost(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	// Handle requests targeted to a container on a different node
+	response, err := ForwardedResponseIfContainerIsRemote(d, r, project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+	if response != nil {
+		return response
+	}
+
+	req := api.ContainerSecurityTestPost{}
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	// Seccomp filters can't be replaced on running processes, so only the
+	// AppArmor policy can be tried out.
+	for k := range req.Config {
+		if strings.HasPrefix(k, "security.syscalls.") {
+			return BadRequest(fmt.Errorf("Syscall policies can't be tested on a running container, '%s' only applies on start", k))
+		}
+
+		if k != "raw.apparmor" {
+			return BadRequest(fmt.Errorf("Only raw.apparmor can be tested, got '%s'", k))
+		}
+	}
+
+	if req.Duration == 0 {
+		req.Duration = containerSecurityTestDuration
+	}
+
+	if req.Duration < 0 || req.Duration > containerSecurityTestMaxDuration {
+		return BadRequest(fmt.Errorf("The duration must be between 1 and %d seconds", containerSecurityTestMaxDuration))
+	}
+
+	if !d.os.AppArmorAdmin {
+		return BadRequest(fmt.Errorf("AppArmor policies can't be managed on this system"))
+	}
+
+	c, err := containerLoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if !c.IsRunning() {
+		return BadRequest(fmt.Errorf("The container isn't running"))
+	}
+
+	if c.IsPrivileged() {
+		return BadRequest(fmt.Errorf("Policies can't be tested on privileged containers"))
+	}
+
+	if c.ExpandedConfig()["security.apparmor.profile"] != "" {
+		return BadRequest(fmt.Errorf("Containers using security.apparmor.profile can't test raw.apparmor"))
+	}
+
+	// Only the rules added by the proposed policy are tested
+	audited, err := containerSecurityTestRules(c.ExpandedConfig()["raw.apparmor"], req.Config["raw.apparmor"])
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	containerSecurityTestsLock.Lock()
+	if containerSecurityTests[c.Id()] {
+		containerSecurityTestsLock.Unlock()
+		return BadRequest(fmt.Errorf("A policy test is already running for this container"))
+	}
+	containerSecurityTests[c.Id()] = true
+	containerSecurityTestsLock.Unlock()
+
+	run := func(op *operation) error {
+		defer func() {
+			containerSecurityTestsLock.Lock()
+			delete(containerSecurityTests, c.Id())
+			containerSecurityTestsLock.Unlock()
+		}()
+
+		return containerSecurityTest(op, c, audited, time.Duration(req.Duration)*time.Second)
+	}
+
+	resources := map[string][]string{}
+	resources["containers"] = []string{name}
+
+	op, err := operationCreate(d.cluster, project, operationClassTask, db.OperationContainerSecurityTest, resources, nil, run, nil, nil)
+	if err != nil {
+		containerSecurityTestsLock.Lock()
+		delete(containerSecurityTests, c.Id())
+		containerSecurityTestsLock.Unlock()
+
+		return InternalError(err)
+	}
+
+	return OperationResponse(op)
+}
+
+// containerSecurityTest adds the given deny rules to the container's enforced
+// AppArmor policy as audit rules for the given duration, recording in the
+// operation metadata the accesses which they would have denied, and then puts
+// the enforced policy back in place.
+func containerSecurityTest(op *operation, c container, audited []string, duration time.Duration) error {
+	// Only look at the kernel messages logged from now on
+	fd, err := unix.Open("/dev/kmsg", unix.O_RDONLY|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("Failed to open the kernel log: %v", err)
+	}
+	defer unix.Close(fd)
+
+	_, err = unix.Seek(fd, 0, io.SeekEnd)
+	if err != nil {
+		return fmt.Errorf("Failed to seek the kernel log: %v", err)
+	}
+
+	err = AALoadTestProfile(c, audited)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		err := AAUnloadTestProfile(c)
+		if err != nil {
+			logger.Error("Failed to restore the container AppArmor policy", log.Ctx{"container": c.Name(), "project": c.Project(), "err": err})
+		}
+	}()
+
+	profile := AAProfileFull(c)
+	denials := []api.ContainerSecurityTestDenial{}
+	index := map[string]int{}
+
+	buf := make([]byte, 8192)
+	deadline := time.Now().Add(duration)
+	for time.Now().Before(deadline) {
+		n, err := unix.Read(fd, buf)
+		if err == unix.EAGAIN {
+			time.Sleep(500 * time.Millisecond)
+			continue
+		}
+
+		// Some messages were overwritten before we read them
+		if err == unix.EPIPE {
+			continue
+		}
+
+		if err != nil {
+			return fmt.Errorf("Failed to read the kernel log: %v", err)
+		}
+
+		denial, ok := containerSecurityTestParse(string(buf[:n]), profile)
+		if !ok {
+			continue
+		}
+
+		key := strings.Join([]string{denial.Operation, denial.Profile, denial.Name, denial.Mask, denial.Command}, "\x00")
+		i, ok := index[key]
+		if !ok {
+			i = len(denials)
+			index[key] = i
+			denials = append(denials, denial)
+		}
+
+		denials[i].Count++
+		op.UpdateMetadata(shared.Jmap{"denials": denials})
+	}
+
+	return op.UpdateMetadata(shared.Jmap{"denials": denials})
+}
+
+// containerSecurityTestRules returns the deny rules which the proposed
+// raw.apparmor adds to the current one, in the form of the rules to audit.
+// Removing rules or adding other ones can't be tested without loosening the
+// policy of the container.
+func containerSecurityTestRules(current string, proposed string) ([]string, error) {
+	lines := func(value string) []string {
+		result := []string{}
+		for _, line := range strings.Split(value, "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+
+			result = append(result, line)
+		}
+
+		return result
+	}
+
+	currentRules := lines(current)
+	proposedRules := lines(proposed)
+
+	for _, rule := range currentRules {
+		if strings.HasPrefix(rule, "audit") {
+			return nil, fmt.Errorf("Policies can't be tested while raw.apparmor audits accesses")
+		}
+
+		if !shared.StringInSlice(rule, proposedRules) {
+			return nil, fmt.Errorf("Only rules added to raw.apparmor can be tested, '%s' is removed", rule)
+		}
+	}
+
+	audited := []string{}
+	for _, rule := range proposedRules {
+		if shared.StringInSlice(rule, currentRules) {
+			continue
+		}
+
+		if !securityTestRule.MatchString(rule) {
+			return nil, fmt.Errorf("Only deny rules of file, capability, network or dbus accesses can be tested, got '%s'", rule)
+		}
+
+		audited = append(audited, strings.TrimSpace(strings.TrimPrefix(rule, "deny")))
+	}
+
+	if len(audited) == 0 {
+		return nil, fmt.Errorf("The proposed raw.apparmor doesn't add any rule")
+	}
+
+	return audited, nil
+}
+
+// containerSecurityTestParse extracts the access audited by the test rules
+// from a kernel log record, if it comes from the given profile or one of its
+// children.
+func containerSecurityTestParse(record string, profile string) (api.ContainerSecurityTestDenial, bool) {
+	denial := api.ContainerSecurityTestDenial{}
+
+	values := securityAuditFields(record)
+	if values == nil || values["apparmor"] != "AUDIT" {
+		return denial, false
+	}
+
+	if values["profile"] != profile && !strings.HasPrefix(values["profile"], profile+"//") {
+		return denial, false
+	}
+
+	denial.Operation = values["operation"]
+	denial.Profile = values["profile"]
+	denial.Name = securityAuditName(values)
+	denial.Mask = securityAuditMask(values)
+	denial.Command = values["comm"]
+
+	return denial, true
+}
+
+// containersSecurityTestReset puts the enforced AppArmor policy of the running
+// containers back in the kernel, in case LXD went away in the middle of a
+// policy test.
+func containersSecurityTestReset(s *state.State) {
+	if !s.OS.AppArmorAdmin {
+		return
+	}
+
+	containers, err := containerLoadNodeAll(s)
+	if err != nil {
+		logger.Error("Failed to load containers to reset their AppArmor policy", log.Ctx{"err": err})
+		return
+	}
+
+	for _, c := range containers {
+		if !c.IsRunning() {
+			continue
+		}
+
+		err := AAUnloadTestProfile(c)
+		if err != nil {
+			logger.Error("Failed to reset the container AppArmor policy", log.Ctx{"container": c.Name(), "project": c.Project(), "err": err})
+		}
+	}
+}
+
+// securityAuditFields returns the fields of the audit message held by a
+// kernel log record, or nil if it doesn't hold one. Records are made of a
+// header and the message, possibly followed by continuation lines.
+func securityAuditFields(record string) map[string]string {
+	fields := strings.SplitN(record, ";", 2)
+	if len(fields) != 2 {
+		return nil
+	}
+
+	message := strings.SplitN(fields[1], "\n", 2)[0]
+	if !strings.HasPrefix(message, "audit: ") {
+		return nil
+	}
+
+	values := map[string]string{}
+	for _, match := range securityAuditField.FindAllStringSubmatch(message, -1) {
+		values[match[1]] = strings.Trim(match[2], `"`)
+	}
+
+	return values
+}
+
+// securityAuditName returns the object of an AppArmor audit message.
+func securityAuditName(values map[string]string) string {
+	if values["name"] != "" {
+		return values["name"]
+	}
+
+	return values["capname"]
+}
+
+// securityAuditMask returns the permissions of an AppArmor audit message.
+func securityAuditMask(values map[string]string) string {
+	if values["denied_mask"] != "" {
+		return values["denied_mask"]
+	}
+
+	return values["requested_mask"]
+}
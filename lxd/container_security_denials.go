@@ -0,0 +1,307 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/sys/unix"
+
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+var containerSecurityDenialsCmd = APIEndpoint{
+	Name: "containers/{name}/security/denials",
+
+	Get: APIEndpointAction{Handler: containerSecurityDenialsGet, AccessHandler: AllowProjectPermission("containers", "view")},
+}
+
+// Number of recent denials kept for each container.
+const containerSecurityDenialsSize = 100
+
+// How often the same syscall of a container is logged by
+// security.syscalls.log, and the maximum size of its syscalls.log file.
+const securitySyscallLogInterval = 10 * time.Second
+const securitySyscallLogSize = 1024 * 1024
+
+// When the syscalls were last logged, indexed by container, architecture and
+// syscall number.
+var securitySyscallLoggedLock sync.Mutex
+var securitySyscallLogged = map[string]time.Time{}
+
+// The recent denials of the containers on this node, indexed by container ID.
+var containerSecurityDenialsLock sync.Mutex
+var containerSecurityDenials = map[int][]api.ContainerSecurityDenial{}
+
+// The containers on this node indexed by their AppArmor profile and
+// namespace, refreshed when an unknown profile shows up.
+type securityProfiles struct {
+	containers map[string]container
+	updated    time.Time
+}
+
+// securityDenialsWatch records the AppArmor and seccomp denials logged by the
+// kernel for the containers on this node.
+func securityDenialsWatch(d *Daemon) {
+	fd, err := unix.Open("/dev/kmsg", unix.O_RDONLY|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		logger.Warn("Failed to open the kernel log, security denials won't be recorded", log.Ctx{"err": err})
+		return
+	}
+	defer unix.Close(fd)
+
+	// Only look at the kernel messages logged from now on
+	_, err = unix.Seek(fd, 0, io.SeekEnd)
+	if err != nil {
+		logger.Warn("Failed to seek the kernel log, security denials won't be recorded", log.Ctx{"err": err})
+		return
+	}
+
+	profiles := &securityProfiles{}
+
+	buf := make([]byte, 8192)
+	for {
+		n, err := unix.Read(fd, buf)
+		if err == unix.EAGAIN {
+			_, err = unix.Poll([]unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}, -1)
+			if err != nil && err != unix.EINTR {
+				logger.Error("Failed to wait for the kernel log", log.Ctx{"err": err})
+				return
+			}
+
+			continue
+		}
+
+		// Some messages were overwritten before we read them
+		if err == unix.EPIPE {
+			continue
+		}
+
+		if err != nil {
+			logger.Error("Failed to read the kernel log", log.Ctx{"err": err})
+			return
+		}
+
+		values := securityAuditFields(string(buf[:n]))
+		if values == nil {
+			continue
+		}
+
+		securityDenialRecord(d, profiles, values)
+	}
+}
+
+// securityDenialRecord attributes a denial to its container and records it.
+func securityDenialRecord(d *Daemon, profiles *securityProfiles, values map[string]string) {
+	denial := api.ContainerSecurityDenial{
+		Timestamp: time.Now().UTC(),
+		Command:   values["comm"],
+	}
+
+	pid, err := strconv.Atoi(values["pid"])
+	if err == nil {
+		denial.PID = pid
+	}
+
+	var c container
+	switch {
+	case values["apparmor"] == "DENIED":
+		denial.Type = "apparmor"
+		denial.Operation = values["operation"]
+		denial.Profile = values["profile"]
+		denial.Name = securityAuditName(values)
+		denial.Mask = securityAuditMask(values)
+
+		c = profiles.lookup(d, strings.SplitN(values["profile"], "//", 2)[0], values["namespace"])
+	case values["type"] == "1326":
+		// Seccomp only logs the syscalls which are killed or explicitly logged
+		denial.Type = "seccomp"
+		denial.Operation = "syscall"
+		denial.Name = values["syscall"]
+
+		if denial.PID > 0 {
+			c, _ = findContainerForPid(int32(denial.PID), d)
+		}
+	default:
+		return
+	}
+
+	if c == nil {
+		return
+	}
+
+	// The killed syscalls are logged by security.syscalls.log too
+	if denial.Type == "seccomp" {
+		securitySyscallRecord(c, values)
+	}
+
+	containerSecurityDenialsLock.Lock()
+	denials := append(containerSecurityDenials[c.Id()], denial)
+	if len(denials) > containerSecurityDenialsSize {
+		denials = denials[len(denials)-containerSecurityDenialsSize:]
+	}
+	containerSecurityDenials[c.Id()] = denials
+	containerSecurityDenialsLock.Unlock()
+
+	if shared.IsTrue(c.ExpandedConfig()["security.denials.events"]) {
+		eventSendLifecycle(c.Project(), "container-security-denied", fmt.Sprintf("/1.0/containers/%s", c.Name()), map[string]interface{}{
+			"type":      denial.Type,
+			"operation": denial.Operation,
+			"profile":   denial.Profile,
+			"name":      denial.Name,
+			"mask":      denial.Mask,
+			"command":   denial.Command,
+			"pid":       denial.PID,
+		})
+	}
+}
+
+// securitySyscallRecord appends a syscall denied to a container with
+// security.syscalls.log set to its syscalls.log file and emits a lifecycle
+// event for it. The same syscall is recorded at most once per interval and the
+// file stops growing once it reaches its maximum size.
+func securitySyscallRecord(c container, values map[string]string) {
+	if !shared.IsTrue(c.ExpandedConfig()["security.syscalls.log"]) {
+		return
+	}
+
+	key := fmt.Sprintf("%d/%s/%s", c.Id(), values["arch"], values["syscall"])
+	if !securitySyscallLogAllowed(key, time.Now()) {
+		return
+	}
+
+	path := filepath.Join(c.LogPath(), "syscalls.log")
+	info, err := os.Stat(path)
+	if err != nil || info.Size() < securitySyscallLogSize {
+		line := fmt.Sprintf("%s syscall=%s arch=%s pid=%s comm=%q\n", time.Now().UTC().Format(time.RFC3339), values["syscall"], values["arch"], values["pid"], values["comm"])
+
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			logger.Error("Failed to open the syscall log", log.Ctx{"container": c.Name(), "project": c.Project(), "err": err})
+		} else {
+			_, err = f.WriteString(line)
+			f.Close()
+			if err != nil {
+				logger.Error("Failed to write the syscall log", log.Ctx{"container": c.Name(), "project": c.Project(), "err": err})
+			}
+		}
+	}
+
+	pid, _ := strconv.Atoi(values["pid"])
+	eventSendLifecycle(c.Project(), "container-syscall-logged", fmt.Sprintf("/1.0/containers/%s", c.Name()), map[string]interface{}{
+		"syscall": values["syscall"],
+		"arch":    values["arch"],
+		"command": values["comm"],
+		"pid":     pid,
+	})
+}
+
+// securitySyscallLogAllowed returns whether the syscall with the given key
+// wasn't logged within the last interval, marking it as logged if so.
+func securitySyscallLogAllowed(key string, now time.Time) bool {
+	securitySyscallLoggedLock.Lock()
+	defer securitySyscallLoggedLock.Unlock()
+
+	last, ok := securitySyscallLogged[key]
+	if ok && now.Sub(last) < securitySyscallLogInterval {
+		return false
+	}
+
+	// Forget about the syscalls which can be logged again
+	for k, t := range securitySyscallLogged {
+		if now.Sub(t) >= securitySyscallLogInterval {
+			delete(securitySyscallLogged, k)
+		}
+	}
+
+	securitySyscallLogged[key] = now
+	return true
+}
+
+// lookup returns the container using an AppArmor profile or namespace. The
+// containers are reloaded when none matches, at most every few seconds, and
+// when the known ones are getting old.
+func (p *securityProfiles) lookup(d *Daemon, profile string, namespace string) container {
+	find := func() container {
+		if p.containers[profile] != nil {
+			return p.containers[profile]
+		}
+
+		return p.containers[namespace]
+	}
+
+	age := time.Since(p.updated)
+	c := find()
+	if (c != nil && age < time.Minute) || (c == nil && age < 5*time.Second) {
+		return c
+	}
+
+	containers, err := containerLoadNodeAll(d.State())
+	if err != nil {
+		logger.Error("Failed to load containers for security denials", log.Ctx{"err": err})
+		return c
+	}
+
+	p.containers = map[string]container{}
+	p.updated = time.Now()
+
+	ids := map[int]bool{}
+	for _, c := range containers {
+		if c.IsSnapshot() {
+			continue
+		}
+
+		ids[c.Id()] = true
+		p.containers[AAProfileFull(c)] = c
+		p.containers[fmt.Sprintf("root//%s", AANamespace(c))] = c
+	}
+
+	// Forget about the containers which are gone
+	containerSecurityDenialsLock.Lock()
+	for id := range containerSecurityDenials {
+		if !ids[id] {
+			delete(containerSecurityDenials, id)
+		}
+	}
+	containerSecurityDenialsLock.Unlock()
+
+	return find()
+}
+
+func containerSecurityDenialsGet(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	// Handle requests targeted to a container on a different node
+	response, err := ForwardedResponseIfContainerIsRemote(d, r, project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+	if response != nil {
+		return response
+	}
+
+	c, err := containerLoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	containerSecurityDenialsLock.Lock()
+	defer containerSecurityDenialsLock.Unlock()
+
+	denials := []api.ContainerSecurityDenial{}
+	denials = append(denials, containerSecurityDenials[c.Id()]...)
+
+	return SyncResponse(true, denials)
+}
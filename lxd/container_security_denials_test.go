@@ -0,0 +1,18 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecuritySyscallLogAllowed(t *testing.T) {
+	now := time.Now()
+
+	assert.True(t, securitySyscallLogAllowed("1/c000003e/246", now))
+	assert.False(t, securitySyscallLogAllowed("1/c000003e/246", now.Add(time.Second)))
+	assert.True(t, securitySyscallLogAllowed("1/c000003e/304", now.Add(time.Second)))
+	assert.True(t, securitySyscallLogAllowed("2/c000003e/246", now.Add(time.Second)))
+	assert.True(t, securitySyscallLogAllowed("1/c000003e/246", now.Add(securitySyscallLogInterval)))
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContainerSecurityTestRules(t *testing.T) {
+	audited, err := containerSecurityTestRules("deny /root/** w,", "deny /root/** w,\n# Secrets\ndeny /etc/shadow r,\ndeny owner /home/*/.ssh/** rw,\ndeny capability sys_time,")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/etc/shadow r,", "owner /home/*/.ssh/** rw,", "capability sys_time,"}, audited)
+
+	// Removed rules
+	_, err = containerSecurityTestRules("deny /root/** w,", "deny /etc/shadow r,")
+	assert.Error(t, err)
+
+	// Allow rules
+	_, err = containerSecurityTestRules("", "mount fstype=nfs,")
+	assert.Error(t, err)
+
+	// Rules of accesses the base profile restricts
+	_, err = containerSecurityTestRules("", "deny ptrace,")
+	assert.Error(t, err)
+
+	// Nothing added
+	_, err = containerSecurityTestRules("deny /root/** w,", "deny /root/** w,")
+	assert.Error(t, err)
+
+	// Audited accesses
+	_, err = containerSecurityTestRules("audit /etc/** r,", "audit /etc/** r,\ndeny /etc/shadow r,")
+	assert.Error(t, err)
+}
+
+func TestContainerSecurityTestParse(t *testing.T) {
+	profile := "lxd-c1_</var/lib/lxd>"
+	record := `6,1234,5678,-;audit: type=1400 audit(1.2:3): apparmor="AUDIT" operation="open" profile="lxd-c1_</var/lib/lxd>" name="/etc/shadow" pid=42 comm="cat" requested_mask="r" fsuid=0 ouid=0`
+
+	denial, ok := containerSecurityTestParse(record, profile)
+	require.True(t, ok)
+	assert.Equal(t, "open", denial.Operation)
+	assert.Equal(t, "/etc/shadow", denial.Name)
+	assert.Equal(t, "r", denial.Mask)
+	assert.Equal(t, "cat", denial.Command)
+
+	// Denials of the enforced policy
+	_, ok = containerSecurityTestParse(strings.Replace(record, `"AUDIT"`, `"DENIED"`, 1), profile)
+	assert.False(t, ok)
+
+	// Other containers
+	_, ok = containerSecurityTestParse(record, "lxd-c2_</var/lib/lxd>")
+	assert.False(t, ok)
+}
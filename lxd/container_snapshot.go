@@ -129,7 +129,7 @@ func containerSnapshotsPost(d *Daemon, r *http.Request) Response {
 	if req.ExpiresAt != nil {
 		expiry = *req.ExpiresAt
 	} else {
-		expiry, err = shared.GetSnapshotExpiry(time.Now(), c.LocalConfig()["snapshots.expiry"])
+		expiry, err = shared.GetSnapshotExpiry(time.Now(), c.ExpandedConfig()["snapshots.expiry"])
 		if err != nil {
 			return BadRequest(err)
 		}
@@ -334,7 +334,7 @@ func snapshotPost(d *Daemon, r *http.Request, sc container, containerName string
 			}
 		}
 
-		ws, err := NewMigrationSource(sc, reqNew.Live, true)
+		ws, err := NewMigrationSource(sc, reqNew.Live, true, false)
 		if err != nil {
 			return SmartError(err)
 		}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// How many lxc.log error lines to keep with the last start error.
+const containerStartErrorLogLines = 20
+
+// containerStartErrorRecord keeps the reason the container failed to start,
+// along with the errors logged by liblxc, until it next starts.
+func containerStartErrorRecord(c container, startErr error) {
+	errorLog := []string{}
+
+	content, err := ioutil.ReadFile(filepath.Join(c.LogPath(), "lxc.log"))
+	if err == nil {
+		errorLog = lxcLogErrors(string(content))
+		if len(errorLog) > containerStartErrorLogLines {
+			errorLog = errorLog[len(errorLog)-containerStartErrorLogLines:]
+		}
+	}
+
+	err = c.VolatileSet(map[string]string{
+		"volatile.last_state.error":      startErr.Error(),
+		"volatile.last_state.error_log":  strings.Join(errorLog, "\n"),
+		"volatile.last_state.error_time": time.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		logger.Error("Failed to record the start error", log.Ctx{"container": c.Name(), "project": c.Project(), "err": err})
+	}
+}
+
+// containerStartErrorClear forgets the last start error of the container once
+// it started.
+func containerStartErrorClear(c container) {
+	if c.LocalConfig()["volatile.last_state.error"] == "" {
+		return
+	}
+
+	err := c.VolatileSet(map[string]string{
+		"volatile.last_state.error":      "",
+		"volatile.last_state.error_log":  "",
+		"volatile.last_state.error_time": "",
+	})
+	if err != nil {
+		logger.Error("Failed to clear the start error", log.Ctx{"container": c.Name(), "project": c.Project(), "err": err})
+	}
+}
+
+// containerStartErrorState returns the last start error recorded in the
+// local configuration of a container, if any.
+func containerStartErrorState(localConfig map[string]string) *api.ContainerStateStartError {
+	if localConfig["volatile.last_state.error"] == "" {
+		return nil
+	}
+
+	state := api.ContainerStateStartError{
+		Message: localConfig["volatile.last_state.error"],
+		Log:     []string{},
+	}
+
+	if localConfig["volatile.last_state.error_log"] != "" {
+		state.Log = strings.Split(localConfig["volatile.last_state.error_log"], "\n")
+	}
+
+	state.Time, _ = time.Parse(time.RFC3339, localConfig["volatile.last_state.error_time"])
+
+	return &state
+}
+
+// lxcLogErrors returns the ERROR lines of a liblxc log.
+func lxcLogErrors(content string) []string {
+	lines := []string{}
+
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+
+		// We only care about errors
+		if fields[2] != "ERROR" {
+			continue
+		}
+
+		lines = append(lines, strings.Join(fields, " "))
+	}
+
+	return lines
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+func TestLxcLogErrors(t *testing.T) {
+	content := `lxc c1 20191002091245.123 WARN     cgfsng - cgroups/cgfsng.c:chowmod:1525 - No such file or directory
+lxc c1 20191002091245.456 ERROR    start - start.c:lxc_spawn:1802 - Failed to setup cgroup limits
+lxc c1 20191002091245.789 ERROR    lxccontainer - lxccontainer.c:wait_on_daemonized_start:842 -   Received container state "ABORTING"
+short line
+`
+
+	assert.Equal(t, []string{
+		"lxc c1 20191002091245.456 ERROR start - start.c:lxc_spawn:1802 - Failed to setup cgroup limits",
+		`lxc c1 20191002091245.789 ERROR lxccontainer - lxccontainer.c:wait_on_daemonized_start:842 - Received container state "ABORTING"`,
+	}, lxcLogErrors(content))
+
+	assert.Equal(t, []string{}, lxcLogErrors(""))
+}
+
+func TestContainerStartErrorState(t *testing.T) {
+	assert.Nil(t, containerStartErrorState(map[string]string{}))
+
+	state := containerStartErrorState(map[string]string{
+		"volatile.last_state.error":      "Failed to run: forkstart",
+		"volatile.last_state.error_log":  "first\nsecond",
+		"volatile.last_state.error_time": "2019-10-02T09:12:45Z",
+	})
+	assert.Equal(t, &api.ContainerStateStartError{
+		Message: "Failed to run: forkstart",
+		Log:     []string{"first", "second"},
+		Time:    time.Date(2019, 10, 2, 9, 12, 45, 0, time.UTC),
+	}, state)
+
+	state = containerStartErrorState(map[string]string{"volatile.last_state.error": "Failed"})
+	assert.Equal(t, []string{}, state.Log)
+	assert.True(t, state.Time.IsZero())
+}
@@ -182,7 +182,21 @@ func containerStatePut(d *Daemon, r *http.Request) Response {
 		opType = db.OperationContainerFreeze
 		do = func(op *operation) error {
 			c.SetOperation(op)
-			return c.Freeze()
+			err := c.Freeze()
+			if err != nil {
+				return err
+			}
+
+			// Have the container unfrozen automatically after the timeout
+			if raw.FreezeTimeout > 0 {
+				err = containerFreezeTimeoutSet(c, time.Duration(raw.FreezeTimeout)*time.Second)
+				if err != nil {
+					c.Unfreeze()
+					return err
+				}
+			}
+
+			return nil
 		}
 	case shared.Unfreeze:
 		if !d.os.CGroupFreezerController {
@@ -208,3 +222,47 @@ func containerStatePut(d *Daemon, r *http.Request) Response {
 
 	return OperationResponse(op)
 }
+
+// containerRestart restarts a running container, killing it if it doesn't
+// shutdown within the timeout (immediately if zero). Ephemeral containers
+// are preserved.
+func containerRestart(c container, timeout time.Duration) error {
+	if c.IsEphemeral() {
+		// Unset the ephemeral flag so the container isn't deleted when
+		// stopped.
+		args := db.ContainerArgs{
+			Architecture: c.Architecture(),
+			Config:       c.LocalConfig(),
+			Description:  c.Description(),
+			Devices:      c.LocalDevices(),
+			Ephemeral:    false,
+			Profiles:     c.Profiles(),
+			Project:      c.Project(),
+		}
+
+		err := c.Update(args, false)
+		if err != nil {
+			return err
+		}
+
+		// On function return, set the flag back on
+		defer func() {
+			args.Ephemeral = true
+			c.Update(args, true)
+		}()
+	}
+
+	var err error
+	if timeout > 0 {
+		err = c.Shutdown(timeout)
+	}
+
+	if timeout == 0 || err != nil {
+		err = c.Stop(false)
+		if err != nil {
+			return err
+		}
+	}
+
+	return c.Start(false)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lxc/lxd/shared"
+)
+
+// The sysctls which only apply to the namespaces of the container, the only
+// ones unprivileged containers may set. Those ending in a dot are prefixes.
+var containerSysctlNamespaced = []string{
+	"net.",
+	"fs.mqueue.",
+	"kernel.msgmax",
+	"kernel.msgmnb",
+	"kernel.msgmni",
+	"kernel.sem",
+	"kernel.shmall",
+	"kernel.shmmax",
+	"kernel.shmmni",
+	"kernel.shm_rmid_forced",
+}
+
+// containerSysctlIsNamespaced returns whether a sysctl only applies to the
+// network or IPC namespace it's set from.
+func containerSysctlIsNamespaced(key string) bool {
+	for _, namespaced := range containerSysctlNamespaced {
+		if strings.HasSuffix(namespaced, ".") && strings.HasPrefix(key, namespaced) {
+			return true
+		}
+
+		if key == namespaced {
+			return true
+		}
+	}
+
+	return false
+}
+
+// containerSysctlValidate checks the linux.sysctl.* keys of a container,
+// refusing the sysctls which would affect the host unless it's privileged.
+func containerSysctlValidate(config map[string]string) error {
+	privileged := shared.IsTrue(config["security.privileged"])
+
+	for key := range config {
+		if !strings.HasPrefix(key, "linux.sysctl.") {
+			continue
+		}
+
+		sysctl := strings.TrimPrefix(key, "linux.sysctl.")
+		if strings.Contains(sysctl, "/") || strings.Contains(sysctl, "..") {
+			return fmt.Errorf("Invalid sysctl key: %s", key)
+		}
+
+		if !privileged && !containerSysctlIsNamespaced(sysctl) {
+			return fmt.Errorf("The %s sysctl isn't namespaced, only privileged containers may set it", sysctl)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContainerSysctlIsNamespaced(t *testing.T) {
+	assert.True(t, containerSysctlIsNamespaced("net.core.somaxconn"))
+	assert.True(t, containerSysctlIsNamespaced("fs.mqueue.msg_max"))
+	assert.True(t, containerSysctlIsNamespaced("kernel.shmmax"))
+	assert.False(t, containerSysctlIsNamespaced("kernel.shmmaxx"))
+	assert.False(t, containerSysctlIsNamespaced("kernel.pid_max"))
+	assert.False(t, containerSysctlIsNamespaced("vm.swappiness"))
+}
+
+func TestContainerSysctlValidate(t *testing.T) {
+	assert.NoError(t, containerSysctlValidate(map[string]string{"linux.sysctl.net.ipv4.ip_forward": "1", "limits.memory": "1GB"}))
+	assert.Error(t, containerSysctlValidate(map[string]string{"linux.sysctl.vm.swappiness": "10"}))
+	assert.NoError(t, containerSysctlValidate(map[string]string{"linux.sysctl.vm.swappiness": "10", "security.privileged": "true"}))
+	assert.Error(t, containerSysctlValidate(map[string]string{"linux.sysctl.net/../vm": "1", "security.privileged": "true"}))
+}
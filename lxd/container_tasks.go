@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	cron "gopkg.in/robfig/cron.v2"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/version"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+var containerTasksCmd = APIEndpoint{
+	Name: "containers/{name}/tasks",
+
+	Get: APIEndpointAction{Handler: containerTasksGet, AccessHandler: AllowProjectPermission("containers", "view")},
+}
+
+// Number of past runs kept for each task.
+const containerTaskHistorySize = 10
+
+// The state of the tasks which ran since LXD started, indexed by container ID
+// and then task name.
+var containerTasksLock sync.Mutex
+var containerTasks = map[int]map[string]*containerTaskState{}
+
+type containerTaskState struct {
+	running bool
+	history []api.ContainerTaskRun
+}
+
+// containerTasksConfig returns the command and schedule of each task defined
+// in the container configuration, indexed by task name.
+func containerTasksConfig(c container) map[string][2]string {
+	config := c.ExpandedConfig()
+
+	tasks := map[string][2]string{}
+	for k := range config {
+		if !strings.HasPrefix(k, "tasks.") || !strings.HasSuffix(k, ".command") {
+			continue
+		}
+
+		name := strings.TrimSuffix(strings.TrimPrefix(k, "tasks."), ".command")
+		if name == "" {
+			continue
+		}
+
+		tasks[name] = [2]string{config[k], config[fmt.Sprintf("tasks.%s.schedule", name)]}
+	}
+
+	return tasks
+}
+
+// containerTaskIsDue returns whether the cron schedule matches the current minute.
+func containerTaskIsDue(schedule string, now time.Time) bool {
+	if schedule == "" {
+		return false
+	}
+
+	// Extend our schedule to one that is accepted by the used cron parser
+	sched, err := cron.Parse(fmt.Sprintf("* %s", schedule))
+	if err != nil {
+		return false
+	}
+
+	// Compare at the minute, the parser works with seconds
+	now = now.Truncate(time.Minute)
+	return now.Equal(sched.Next(now).Truncate(time.Minute))
+}
+
+func containerTasksTask(d *Daemon) (task.Func, task.Schedule) {
+	f := func(ctx context.Context) {
+		containers, err := containerLoadNodeAll(d.State())
+		if err != nil {
+			logger.Error("Failed to load containers for scheduled tasks", log.Ctx{"err": err})
+			return
+		}
+
+		now := time.Now()
+		seen := map[int]bool{}
+		for _, c := range containers {
+			if c.IsSnapshot() {
+				continue
+			}
+
+			seen[c.Id()] = true
+
+			if !c.IsRunning() || c.IsFrozen() {
+				continue
+			}
+
+			for name, t := range containerTasksConfig(c) {
+				if t[0] == "" || !containerTaskIsDue(t[1], now) {
+					continue
+				}
+
+				go containerTaskRun(d, c, name, t[0])
+			}
+		}
+
+		// Forget about the containers which are gone
+		containerTasksLock.Lock()
+		for id := range containerTasks {
+			if !seen[id] {
+				delete(containerTasks, id)
+			}
+		}
+		containerTasksLock.Unlock()
+	}
+
+	first := true
+	schedule := func() (time.Duration, error) {
+		interval := time.Minute
+
+		if first {
+			first = false
+			return interval, task.ErrSkip
+		}
+
+		return interval, nil
+	}
+
+	return f, schedule
+}
+
+// containerTaskRun runs a task through an exec operation, recording its output
+// in the container logs and its outcome in the task history. A task is never
+// run again while the previous run is still going.
+func containerTaskRun(d *Daemon, c container, name string, command string) {
+	containerTasksLock.Lock()
+	if containerTasks[c.Id()] == nil {
+		containerTasks[c.Id()] = map[string]*containerTaskState{}
+	}
+
+	state := containerTasks[c.Id()][name]
+	if state == nil {
+		state = &containerTaskState{}
+		containerTasks[c.Id()][name] = state
+	}
+
+	if state.running {
+		containerTasksLock.Unlock()
+		logger.Warn("Skipping container task still running", log.Ctx{"container": c.Name(), "project": c.Project(), "task": name})
+		return
+	}
+
+	state.running = true
+	containerTasksLock.Unlock()
+
+	args := []string{"sh", "-c", command}
+
+	run := func(op *operation) error {
+		result := api.ContainerTaskRun{
+			Operation: op.id,
+			CreatedAt: time.Now().UTC(),
+			Return:    -1,
+		}
+
+		cmdErr := containerTaskExec(op, c, name, args, &result)
+		if cmdErr != nil {
+			result.Error = cmdErr.Error()
+		}
+		result.FinishedAt = time.Now().UTC()
+
+		containerTaskRecord(c, name, result)
+
+		if cmdErr == nil && result.Return != 0 {
+			logger.Warn("Container task failed", log.Ctx{"container": c.Name(), "project": c.Project(), "task": name, "return": result.Return})
+		}
+
+		return cmdErr
+	}
+
+	resources := map[string][]string{}
+	resources["containers"] = []string{c.Name()}
+
+	metadata := shared.Jmap{"task": name, "command": args}
+
+	op, err := operationCreate(d.cluster, c.Project(), operationClassTask, db.OperationCommandExec, resources, metadata, run, nil, nil)
+	if err == nil {
+		_, err = op.Run()
+	}
+
+	if err != nil {
+		logger.Error("Failed to run container task", log.Ctx{"container": c.Name(), "project": c.Project(), "task": name, "err": err})
+
+		containerTasksLock.Lock()
+		state.running = false
+		containerTasksLock.Unlock()
+	}
+}
+
+func containerTaskExec(op *operation, c container, name string, args []string, result *api.ContainerTaskRun) error {
+	stdout, err := os.OpenFile(filepath.Join(c.LogPath(), fmt.Sprintf("exec_%s.stdout", op.id)), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	defer stdout.Close()
+
+	stderr, err := os.OpenFile(filepath.Join(c.LogPath(), fmt.Sprintf("exec_%s.stderr", op.id)), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	defer stderr.Close()
+
+	result.Output = map[string]string{
+		"1": fmt.Sprintf("/%s/containers/%s/logs/%s", version.APIVersion, c.Name(), filepath.Base(stdout.Name())),
+		"2": fmt.Sprintf("/%s/containers/%s/logs/%s", version.APIVersion, c.Name(), filepath.Base(stderr.Name())),
+	}
+
+	cmd, _, attachedPid, err := c.Exec(args, execEnvironment(c, nil, 0), nil, stdout, stderr, false, "", 0, 0)
+	if err != nil {
+		return err
+	}
+
+	// Run the command in its own accounting session
+	session := execSessionStart(c, op.id, args, attachedPid)
+	session.watch(op, shared.Jmap{"task": name, "command": args})
+	defer session.stop()
+
+	result.Return, err = execWait(cmd)
+	return err
+}
+
+// containerTaskRecord adds a run to the task history, deleting the output of
+// the runs which don't fit anymore.
+func containerTaskRecord(c container, name string, result api.ContainerTaskRun) {
+	containerTasksLock.Lock()
+	defer containerTasksLock.Unlock()
+
+	if containerTasks[c.Id()] == nil {
+		containerTasks[c.Id()] = map[string]*containerTaskState{}
+	}
+
+	state := containerTasks[c.Id()][name]
+	if state == nil {
+		state = &containerTaskState{}
+		containerTasks[c.Id()][name] = state
+	}
+
+	state.running = false
+	state.history = append(state.history, result)
+
+	for len(state.history) > containerTaskHistorySize {
+		for _, path := range state.history[0].Output {
+			os.Remove(filepath.Join(c.LogPath(), filepath.Base(path)))
+		}
+
+		state.history = state.history[1:]
+	}
+}
+
+func containerTasksGet(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	// Handle requests targeted to a container on a different node
+	response, err := ForwardedResponseIfContainerIsRemote(d, r, project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+	if response != nil {
+		return response
+	}
+
+	c, err := containerLoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	config := containerTasksConfig(c)
+
+	names := []string{}
+	for taskName := range config {
+		names = append(names, taskName)
+	}
+	sort.Strings(names)
+
+	containerTasksLock.Lock()
+	defer containerTasksLock.Unlock()
+
+	tasks := []api.ContainerTask{}
+	for _, taskName := range names {
+		t := api.ContainerTask{
+			Name:     taskName,
+			Command:  config[taskName][0],
+			Schedule: config[taskName][1],
+			History:  []api.ContainerTaskRun{},
+		}
+
+		state := containerTasks[c.Id()][taskName]
+		if state != nil {
+			t.Running = state.running
+			t.History = append(t.History, state.history...)
+		}
+
+		tasks = append(tasks, t)
+	}
+
+	return SyncResponse(true, tasks)
+}
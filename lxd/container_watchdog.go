@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lxc/lxd/lxd/device"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// The software watchdogs being monitored, indexed by container ID and then
+// device name.
+var containerWatchdogsLock sync.Mutex
+var containerWatchdogs = map[int]map[string]*containerWatchdog{}
+
+// containerWatchdog reads the FIFO backing a software watchdog device. It's
+// armed by the first write, each write then resets its timer and writing the
+// magic character "V" disarms it until the next write.
+type containerWatchdog struct {
+	fifo *os.File
+	done chan struct{}
+
+	// Updated in place so the FIFO never goes without a reader
+	lock    sync.Mutex
+	timeout time.Duration
+	action  string
+}
+
+// containerWatchdogsSync starts monitoring the software watchdogs of a running
+// container which aren't yet, and stops those which were removed.
+func containerWatchdogsSync(c container) {
+	devices := c.ExpandedDevices()
+
+	containerWatchdogsLock.Lock()
+	defer containerWatchdogsLock.Unlock()
+
+	watchdogs := containerWatchdogs[c.Id()]
+	if watchdogs == nil {
+		watchdogs = map[string]*containerWatchdog{}
+	}
+
+	for name, w := range watchdogs {
+		m, ok := devices[name]
+		if !ok || m["type"] != "watchdog" || m["mode"] == "hardware" {
+			w.stop()
+			delete(watchdogs, name)
+		}
+	}
+
+	for _, name := range devices.DeviceNames() {
+		m := devices[name]
+		if m["type"] != "watchdog" || m["mode"] == "hardware" {
+			continue
+		}
+
+		timeout, action := containerWatchdogConfig(m)
+
+		w, ok := watchdogs[name]
+		if ok {
+			w.lock.Lock()
+			w.timeout = timeout
+			w.action = action
+			w.lock.Unlock()
+			continue
+		}
+
+		fifo, err := os.OpenFile(device.WatchdogFIFOPath(c.DevicesPath(), name), os.O_RDWR, 0)
+		if err != nil {
+			logger.Error("Failed to open watchdog", log.Ctx{"container": c.Name(), "project": c.Project(), "device": name, "err": err})
+			continue
+		}
+
+		w = &containerWatchdog{
+			fifo:    fifo,
+			done:    make(chan struct{}),
+			timeout: timeout,
+			action:  action,
+		}
+
+		watchdogs[name] = w
+		go w.run(c, name)
+	}
+
+	if len(watchdogs) > 0 {
+		containerWatchdogs[c.Id()] = watchdogs
+	} else {
+		delete(containerWatchdogs, c.Id())
+	}
+}
+
+// containerWatchdogsStop stops monitoring all the watchdogs of a container.
+func containerWatchdogsStop(c container) {
+	containerWatchdogsLock.Lock()
+	defer containerWatchdogsLock.Unlock()
+
+	for _, w := range containerWatchdogs[c.Id()] {
+		w.stop()
+	}
+
+	delete(containerWatchdogs, c.Id())
+}
+
+func containerWatchdogConfig(m map[string]string) (time.Duration, string) {
+	timeout := device.WatchdogDefaultTimeout
+	if m["timeout"] != "" {
+		value, err := strconv.Atoi(m["timeout"])
+		if err == nil && value > 0 {
+			timeout = value
+		}
+	}
+
+	action := m["action"]
+	if action == "" {
+		action = "restart"
+	}
+
+	return time.Duration(timeout) * time.Second, action
+}
+
+func (w *containerWatchdog) stop() {
+	close(w.done)
+	w.fifo.Close()
+}
+
+func (w *containerWatchdog) run(c container, name string) {
+	// Each read carries whether the watchdog was disarmed
+	keepalives := make(chan bool)
+	go func() {
+		buf := make([]byte, 64)
+		for {
+			n, err := w.fifo.Read(buf)
+			if err != nil {
+				return
+			}
+
+			if n == 0 {
+				continue
+			}
+
+			select {
+			case keepalives <- buf[n-1] == 'V':
+			case <-w.done:
+				return
+			}
+		}
+	}()
+
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			timer.Stop()
+			return
+		case disarm := <-keepalives:
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+
+			if !disarm {
+				w.lock.Lock()
+				timer.Reset(w.timeout)
+				w.lock.Unlock()
+			}
+		case <-timer.C:
+			w.lock.Lock()
+			action := w.action
+			w.lock.Unlock()
+
+			// Restarting or stopping recreates the FIFO, so this one is done
+			go containerWatchdogExpired(c, name, action)
+			if action != "event" {
+				return
+			}
+		}
+	}
+}
+
+// containerWatchdogExpired performs the action configured on a software
+// watchdog which wasn't written to in time.
+func containerWatchdogExpired(c container, name string, action string) {
+	// Use a fresh copy of the container, its state may have changed
+	c, err := containerLoadByProjectAndName(c.DaemonState(), c.Project(), c.Name())
+	if err != nil || !c.IsRunning() {
+		return
+	}
+
+	logger.Warn("Container watchdog expired", log.Ctx{"container": c.Name(), "project": c.Project(), "device": name, "action": action})
+	eventSendLifecycle(c.Project(), "container-watchdog-expired",
+		fmt.Sprintf("/1.0/containers/%s", c.Name()), map[string]interface{}{"device": name, "action": action})
+
+	switch action {
+	case "stop":
+		err = c.Stop(false)
+	case "restart":
+		err = c.Stop(false)
+		if err == nil {
+			err = c.Start(false)
+		}
+	}
+
+	if err != nil {
+		logger.Error("Failed to perform the watchdog action", log.Ctx{"container": c.Name(), "project": c.Project(), "device": name, "action": action, "err": err})
+	}
+}
@@ -6,8 +6,10 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/lxc/lxd/lxd/cluster"
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/state"
 	"github.com/lxc/lxd/shared"
@@ -155,6 +157,15 @@ func containersRestart(s *state.State) error {
 
 	// Restart the containers
 	for _, c := range containers {
+		// Resume monitoring the watchdogs and out of memory events of
+		// those still running, and the freeze timeouts of the frozen
+		// ones
+		if c.IsRunning() {
+			containerWatchdogsSync(c)
+			containerOOMWatchStart(c)
+			containerFreezeTimeoutRestore(c)
+		}
+
 		config := c.ExpandedConfig()
 		lastState := config["volatile.last_state.power"]
 
@@ -181,6 +192,47 @@ func containersRestart(s *state.State) error {
 	return nil
 }
 
+// containersEphemeralCleanup deletes the stopped ephemeral containers of this
+// node which were running when LXD went away, if ephemeral.cleanup is set.
+// Those normally get deleted as they stop, which a host crash prevents.
+func containersEphemeralCleanup(s *state.State) {
+	enabled, err := cluster.ConfigGetBool(s.Cluster, "ephemeral.cleanup")
+	if err != nil {
+		logger.Error("Failed to load the ephemeral cleanup configuration", log.Ctx{"err": err})
+		return
+	}
+
+	if !enabled {
+		return
+	}
+
+	containers, err := containerLoadNodeAll(s)
+	if err != nil {
+		logger.Error("Failed to load containers for ephemeral cleanup", log.Ctx{"err": err})
+		return
+	}
+
+	for _, c := range containers {
+		if c.IsSnapshot() || !c.IsEphemeral() || c.IsRunning() {
+			continue
+		}
+
+		// Containers which were already stopped, such as those just
+		// created or copied, are left alone
+		if c.LocalConfig()["volatile.last_state.power"] != "RUNNING" {
+			continue
+		}
+
+		logger.Info("Deleting stopped ephemeral container", log.Ctx{"container": c.Name(), "project": c.Project()})
+
+		// Deleting the container sends its lifecycle event
+		err := c.Delete()
+		if err != nil {
+			logger.Error("Failed to delete stopped ephemeral container", log.Ctx{"container": c.Name(), "project": c.Project(), "err": err})
+		}
+	}
+}
+
 type containerStopList []container
 
 func (slice containerStopList) Len() int {
@@ -250,13 +302,15 @@ func containersShutdown(s *state.State) error {
 			return err
 		}
 
+		// Without database, the containers are loaded without their
+		// profiles nor project defaults
 		for project, names := range cnames {
 			for _, name := range names {
 				c, err := containerLXCLoad(s, db.ContainerArgs{
 					Project: project,
 					Name:    name,
 					Config:  make(map[string]string),
-				}, nil)
+				}, nil, map[string]string{})
 				if err != nil {
 					return err
 				}
@@ -316,7 +370,7 @@ func containersShutdown(s *state.State) error {
 
 				wg.Done()
 			}(c, lastState)
-		} else {
+		} else if atomic.LoadInt32(&emergencyShutdownDone) == 0 {
 			c.VolatileSet(map[string]string{"volatile.last_state.power": lastState})
 		}
 	}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/node"
+	"github.com/lxc/lxd/lxd/project"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/lxd/task"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+var emergencyShutdownCmd = APIEndpoint{
+	Name: "emergency-shutdown",
+
+	Post: APIEndpointAction{Handler: emergencyShutdownPost},
+}
+
+// Set while an emergency shutdown sequence is in progress.
+var emergencyShutdownRunning int32
+
+// Set once an emergency shutdown sequence went through, so that the regular
+// daemon shutdown doesn't record the stopped containers as such. It's reset
+// when containers get started again.
+var emergencyShutdownDone int32
+
+// Set when the running emergency shutdown sequence gets cancelled.
+var emergencyShutdownCancelled int32
+
+var errEmergencyShutdownRunning = fmt.Errorf("An emergency shutdown is already in progress")
+
+func emergencyShutdownPost(d *Daemon, r *http.Request) Response {
+	op, err := emergencyShutdownStart(d.State())
+	if err == errEmergencyShutdownRunning {
+		return BadRequest(err)
+	}
+	if err != nil {
+		return SmartError(err)
+	}
+
+	return OperationResponse(op)
+}
+
+// emergencyShutdownStart starts the emergency shutdown of all the containers
+// running on this node as a background operation.
+func emergencyShutdownStart(s *state.State) (*operation, error) {
+	if !atomic.CompareAndSwapInt32(&emergencyShutdownRunning, 0, 1) {
+		return nil, errEmergencyShutdownRunning
+	}
+
+	atomic.StoreInt32(&emergencyShutdownCancelled, 0)
+
+	run := func(op *operation) error {
+		defer atomic.StoreInt32(&emergencyShutdownRunning, 0)
+
+		logger.Warn("Starting emergency shutdown of all containers")
+		atomic.StoreInt32(&emergencyShutdownDone, 1)
+		err := containersEmergencyShutdown(s, op)
+		if err != nil {
+			atomic.StoreInt32(&emergencyShutdownDone, 0)
+			logger.Error("Emergency shutdown failed", log.Ctx{"err": err})
+			return err
+		}
+
+		logger.Warn("Done with emergency shutdown of all containers")
+		return nil
+	}
+
+	cancel := func(op *operation) error {
+		atomic.StoreInt32(&emergencyShutdownCancelled, 1)
+		return nil
+	}
+
+	op, err := operationCreate(s.Cluster, "", operationClassTask, db.OperationContainersEmergencyShutdown, nil, nil, run, cancel, nil)
+	if err != nil {
+		atomic.StoreInt32(&emergencyShutdownRunning, 0)
+		return nil, err
+	}
+
+	_, err = op.Run()
+	if err != nil {
+		atomic.StoreInt32(&emergencyShutdownRunning, 0)
+		return nil, err
+	}
+
+	return op, nil
+}
+
+// containersEmergencyShutdown stops all running containers in their
+// boot.stop.priority order, using boot.emergency_shutdown_timeout rather than
+// boot.host_shutdown_timeout and attempting a stateful stop for the
+// containers which have boot.emergency_stateful set.
+func containersEmergencyShutdown(s *state.State, op *operation) error {
+	containers, err := containerLoadNodeAll(s)
+	if err != nil {
+		return err
+	}
+
+	running := []container{}
+	for _, c := range containers {
+		if c.IsRunning() {
+			running = append(running, c)
+		}
+	}
+
+	sort.Sort(containerStopList(running))
+
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	stopped := 0
+	failed := []string{}
+
+	updateProgress := func(priority int) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		op.UpdateMetadata(map[string]interface{}{
+			"priority": priority,
+			"stopped":  stopped,
+			"failed":   failed,
+			"total":    len(running),
+		})
+	}
+
+	var lastPriority int
+	if len(running) != 0 {
+		lastPriority, _ = strconv.Atoi(running[0].ExpandedConfig()["boot.stop.priority"])
+		updateProgress(lastPriority)
+	}
+
+	for _, c := range running {
+		priority, _ := strconv.Atoi(c.ExpandedConfig()["boot.stop.priority"])
+
+		// Enforce shutdown priority
+		if priority != lastPriority {
+			lastPriority = priority
+
+			// Wait for containers with higher priority to finish
+			wg.Wait()
+			updateProgress(priority)
+		}
+
+		// Leave the remaining containers running once cancelled
+		if atomic.LoadInt32(&emergencyShutdownCancelled) == 1 {
+			wg.Wait()
+			return fmt.Errorf("Emergency shutdown cancelled")
+		}
+
+		wg.Add(1)
+		go func(c container, priority int) {
+			defer wg.Done()
+
+			err := containerEmergencyStop(c)
+
+			lock.Lock()
+			stopped++
+			if err != nil {
+				logger.Error("Failed to stop container during emergency shutdown", log.Ctx{"container": c.Name(), "project": c.Project(), "err": err})
+				failed = append(failed, project.Prefix(c.Project(), c.Name()))
+			}
+			lock.Unlock()
+
+			updateProgress(priority)
+		}(c, priority)
+	}
+	wg.Wait()
+
+	return nil
+}
+
+// emergencyShutdownOver records that the containers stopped by an emergency
+// shutdown are being started again, the regular daemon shutdown recording
+// the stopped containers as such again.
+func emergencyShutdownOver() {
+	if atomic.LoadInt32(&emergencyShutdownRunning) == 1 {
+		return
+	}
+
+	atomic.StoreInt32(&emergencyShutdownDone, 0)
+}
+
+func containerEmergencyStop(c container) error {
+	// Restart the container when LXD next starts
+	err := c.VolatileSet(map[string]string{"volatile.last_state.power": "RUNNING"})
+	if err != nil {
+		return err
+	}
+
+	if shared.IsTrue(c.ExpandedConfig()["boot.emergency_stateful"]) {
+		err := c.Stop(true)
+		if err == nil {
+			return nil
+		}
+
+		logger.Warn("Stateful stop failed during emergency shutdown, falling back to a clean shutdown", log.Ctx{"container": c.Name(), "project": c.Project(), "err": err})
+	}
+
+	timeoutSeconds := 5
+	value, ok := c.ExpandedConfig()["boot.emergency_shutdown_timeout"]
+	if ok {
+		timeoutSeconds, _ = strconv.Atoi(value)
+	}
+
+	err = c.Shutdown(time.Second * time.Duration(timeoutSeconds))
+	if err == nil {
+		return nil
+	}
+
+	return c.Stop(false)
+}
+
+// emergencyShutdownTriggerTask watches for the file configured through
+// core.emergency_shutdown_trigger, typically created by a UPS or thermal
+// monitoring daemon, and starts an emergency shutdown when it shows up.
+func emergencyShutdownTriggerTask(d *Daemon) (task.Func, task.Schedule) {
+	first := true
+	triggered := false
+
+	f := func(ctx context.Context) {
+		var path string
+		err := d.db.Transaction(func(tx *db.NodeTx) error {
+			config, err := node.ConfigLoad(tx)
+			if err != nil {
+				return err
+			}
+
+			path = config.EmergencyShutdownTrigger()
+			return nil
+		})
+		if err != nil {
+			logger.Error("Failed to load emergency shutdown trigger", log.Ctx{"err": err})
+			return
+		}
+
+		if path == "" || !shared.PathExists(path) {
+			first = false
+			triggered = false
+			return
+		}
+
+		// Don't act on a trigger left over from before LXD started
+		if first {
+			logger.Warn("Ignoring emergency shutdown trigger present on startup", log.Ctx{"path": path})
+			first = false
+			triggered = true
+			return
+		}
+
+		// Only trigger once for as long as the file exists
+		if triggered {
+			return
+		}
+		triggered = true
+
+		logger.Warn("Emergency shutdown trigger detected", log.Ctx{"path": path})
+		_, err = emergencyShutdownStart(d.State())
+		if err != nil {
+			logger.Error("Failed to start emergency shutdown", log.Ctx{"err": err})
+		}
+	}
+
+	return f, task.Every(5 * time.Second)
+}
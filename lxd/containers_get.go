@@ -88,6 +88,18 @@ func doContainersGet(d *Daemon, r *http.Request) (interface{}, error) {
 		for _, ct := range cts {
 			nodeCts[ct.Name()] = ct
 		}
+
+		err = containerActivityPreload(d.State(), project, cts)
+		if err != nil {
+			return nil, err
+		}
+
+		if recursion > 1 {
+			err = containerRenderPreload(d.State(), project, cts)
+			if err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	// Append containers to list and handle errors
@@ -190,51 +202,35 @@ func doContainersGet(d *Daemon, r *http.Request) (interface{}, error) {
 				resultString = append(resultString, url)
 			}
 		} else {
-			threads := 4
-			if len(containers) < threads {
-				threads = len(containers)
-			}
-
-			queue := make(chan string, threads)
-
-			for i := 0; i < threads; i++ {
-				wg.Add(1)
-
-				go func() {
-					for {
-						container, more := <-queue
-						if !more {
-							break
-						}
-
-						if recursion == 1 {
-							c, _, err := nodeCts[container].Render()
-							if err != nil {
-								resultListAppend(container, api.Container{}, err)
-							} else {
-								resultListAppend(container, *c.(*api.Container), err)
-							}
+			wg.Add(1)
+			go func(containers []string) {
+				defer wg.Done()
 
-							continue
-						}
+				// Errors are reported per container
+				renderParallel(renderWorkersGet(d.cluster), len(containers), func(i int) error {
+					container := containers[i]
 
-						c, _, err := nodeCts[container].RenderFull()
+					if recursion == 1 {
+						c, _, err := nodeCts[container].Render()
 						if err != nil {
-							resultFullListAppend(container, api.ContainerFull{}, err)
+							resultListAppend(container, api.Container{}, err)
 						} else {
-							resultFullListAppend(container, *c, err)
+							resultListAppend(container, *c.(*api.Container), err)
 						}
-					}
 
-					wg.Done()
-				}()
-			}
+						return nil
+					}
 
-			for _, container := range containers {
-				queue <- container
-			}
+					c, _, err := nodeCts[container].RenderFull()
+					if err != nil {
+						resultFullListAppend(container, api.ContainerFull{}, err)
+					} else {
+						resultFullListAppend(container, *c, err)
+					}
 
-			close(queue)
+					return nil
+				})
+			}(containers)
 		}
 	}
 	wg.Wait()
@@ -11,6 +11,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/dustinkirkland/golang-petname"
@@ -300,7 +301,7 @@ func createFromMigration(d *Daemon, project string, req *api.ContainersPost) Res
 			}
 		} else {
 			// Retrieve the future storage pool
-			cM, err := containerLXCLoad(d.State(), args, nil)
+			cM, err := containerLXCLoad(d.State(), args, nil, nil)
 			if err != nil {
 				return InternalError(err)
 			}
@@ -432,6 +433,10 @@ func createFromCopy(d *Daemon, project string, req *api.ContainersPost) Response
 		return BadRequest(fmt.Errorf("must specify a source container"))
 	}
 
+	if req.Source.Cow && (req.Source.Refresh || req.Stateful) {
+		return BadRequest(fmt.Errorf("Copy-on-write copies can't be refreshed or stateful"))
+	}
+
 	sourceProject := req.Source.Project
 	if sourceProject == "" {
 		sourceProject = project
@@ -470,6 +475,10 @@ func createFromCopy(d *Daemon, project string, req *api.ContainersPost) Response
 				return resp
 			}
 
+			if req.Source.Cow && sourcePoolName != destPoolName {
+				return BadRequest(fmt.Errorf("Copy-on-write copies require the source and target to use the same storage pool"))
+			}
+
 			if sourcePoolName != destPoolName {
 				// Redirect to migration
 				return clusterCopyContainerInternal(d, source, project, req)
@@ -482,6 +491,10 @@ func createFromCopy(d *Daemon, project string, req *api.ContainersPost) Response
 			}
 
 			if pool.Driver != "ceph" {
+				if req.Source.Cow {
+					return BadRequest(fmt.Errorf("Copy-on-write copies require the source container to be on this node"))
+				}
+
 				// Redirect to migration
 				return clusterCopyContainerInternal(d, source, project, req)
 			}
@@ -566,6 +579,11 @@ func createFromCopy(d *Daemon, project string, req *api.ContainersPost) Response
 	}
 
 	run := func(op *operation) error {
+		if req.Source.Cow {
+			_, err := containerCreateAsClone(d.State(), args, source)
+			return err
+		}
+
 		_, err := containerCreateAsCopy(d.State(), args, source, req.Source.ContainerOnly, req.Source.Refresh)
 		if err != nil {
 			return err
@@ -584,7 +602,33 @@ func createFromCopy(d *Daemon, project string, req *api.ContainersPost) Response
 	return OperationResponse(op)
 }
 
-func createFromBackup(d *Daemon, project string, data io.Reader, pool string) Response {
+// createFromBackupCheckAdmin returns a permission error if the backup.yaml of
+// an imported container sets keys restricted to administrators, in the
+// container or in its snapshots.
+func createFromBackupCheckAdmin(path string) error {
+	backup, err := slurpBackupFile(path)
+	if err != nil {
+		return err
+	}
+
+	if backup.Container != nil {
+		err = containerConfigCheckAdminKeys(nil, backup.Container.Config)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, snap := range backup.Snapshots {
+		err = containerConfigCheckAdminKeys(nil, snap.Config)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func createFromBackup(d *Daemon, project string, data io.Reader, pool string, admin bool) Response {
 	// Write the data to a temp file
 	f, err := ioutil.TempFile("", "lxd_backup_")
 	if err != nil {
@@ -622,6 +666,15 @@ func createFromBackup(d *Daemon, project string, data io.Reader, pool string) Re
 			return errors.Wrap(err, "Create container from backup")
 		}
 
+		// Only administrators may import keys restricted to them
+		if !admin {
+			err = createFromBackupCheckAdmin(filepath.Join(getContainerMountPoint(project, bInfo.Pool, bInfo.Name), "backup.yaml"))
+			if err != nil {
+				cPool.ContainerDelete(&containerLXC{name: bInfo.Name, project: project})
+				return err
+			}
+		}
+
 		body, err := json.Marshal(&internalImportPost{
 			Name:  bInfo.Name,
 			Force: true,
@@ -675,7 +728,7 @@ func containersPost(d *Daemon, r *http.Request) Response {
 
 	// If we're getting binary content, process separately
 	if r.Header.Get("Content-Type") == "application/octet-stream" {
-		return createFromBackup(d, project, r.Body, r.Header.Get("X-LXD-pool"))
+		return createFromBackup(d, project, r.Body, r.Header.Get("X-LXD-pool"), d.userIsAdmin(r))
 	}
 
 	// Parse the request
@@ -684,6 +737,11 @@ func containersPost(d *Daemon, r *http.Request) Response {
 		return BadRequest(err)
 	}
 
+	err := containerConfigCheckAdmin(d, r, nil, req.Config)
+	if err != nil {
+		return Forbidden(err)
+	}
+
 	targetNode := queryParam(r, "target")
 	if targetNode == "" {
 		// If no target node was specified, pick the node with the
@@ -836,6 +894,16 @@ func containerFindStoragePool(d *Daemon, project string, req *api.ContainersPost
 		}
 	}
 
+	// Use the default pool of the project
+	if storagePool == "" {
+		defaultPool, _, err := projectStoragePools(d.cluster, project)
+		if err != nil {
+			return "", "", "", nil, SmartError(err)
+		}
+
+		storagePool = defaultPool
+	}
+
 	// If there is just a single pool in the database, use that
 	if storagePool == "" {
 		logger.Debugf("No valid storage pool in the container's local root disk device and profiles found")
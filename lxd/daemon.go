@@ -33,6 +33,7 @@ import (
 	"github.com/lxc/lxd/lxd/endpoints"
 	"github.com/lxc/lxd/lxd/maas"
 	"github.com/lxc/lxd/lxd/node"
+	"github.com/lxc/lxd/lxd/oidc"
 	"github.com/lxc/lxd/lxd/rbac"
 	"github.com/lxc/lxd/lxd/state"
 	"github.com/lxc/lxd/lxd/sys"
@@ -75,6 +76,7 @@ type Daemon struct {
 	proxy func(req *http.Request) (*url.URL, error)
 
 	externalAuth *externalAuth
+	oidc         *oidcAuth
 
 	// Stores last heartbeat node information to detect node changes.
 	lastNodeList *cluster.APIHeartbeat
@@ -86,6 +88,11 @@ type externalAuth struct {
 	bakery   *identchecker.Bakery
 }
 
+type oidcAuth struct {
+	verifier *oidc.Verifier
+	roles    *oidc.Roles
+}
+
 // DaemonConfig holds configuration values for Daemon.
 type DaemonConfig struct {
 	Group              string        // Group name the local unix socket should be chown'ed to
@@ -214,6 +221,13 @@ func (d *Daemon) checkTrustedClient(r *http.Request) error {
 //
 // This does not perform authorization, only validates authentication
 func (d *Daemon) Authenticate(r *http.Request) (bool, string, string, error) {
+	trusted, username, protocol, _, err := d.authenticate(r)
+	return trusted, username, protocol, err
+}
+
+// authenticate is Authenticate, also returning the identity of the OpenID
+// Connect users.
+func (d *Daemon) authenticate(r *http.Request) (bool, string, string, *oidc.Identity, error) {
 	// Allow internal cluster traffic
 	if r.TLS != nil {
 		cert, _ := x509.ParseCertificate(d.endpoints.NetworkCert().KeyPair().Certificate[0])
@@ -221,29 +235,29 @@ func (d *Daemon) Authenticate(r *http.Request) (bool, string, string, error) {
 		for i := range r.TLS.PeerCertificates {
 			trusted, _ := util.CheckTrustState(*r.TLS.PeerCertificates[i], clusterCerts)
 			if trusted {
-				return true, "", "cluster", nil
+				return true, "", "cluster", nil, nil
 			}
 		}
 	}
 
 	// Local unix socket queries
 	if r.RemoteAddr == "@" {
-		return true, "", "unix", nil
+		return true, "", "unix", nil, nil
 	}
 
 	// Devlxd unix socket credentials on main API
 	if r.RemoteAddr == "@devlxd" {
-		return false, "", "", fmt.Errorf("Main API query can't come from /dev/lxd socket")
+		return false, "", "", nil, fmt.Errorf("Main API query can't come from /dev/lxd socket")
 	}
 
 	// Cluster notification with wrong certificate
 	if isClusterNotification(r) {
-		return false, "", "", fmt.Errorf("Cluster notification isn't using cluster certificate")
+		return false, "", "", nil, fmt.Errorf("Cluster notification isn't using cluster certificate")
 	}
 
 	// Bad query, no TLS found
 	if r.TLS == nil {
-		return false, "", "", fmt.Errorf("Bad/missing TLS on network query")
+		return false, "", "", nil, fmt.Errorf("Bad/missing TLS on network query")
 	}
 
 	if d.externalAuth != nil && r.Header.Get(httpbakery.BakeryProtocolHeader) != "" {
@@ -259,28 +273,39 @@ func (d *Daemon) Authenticate(r *http.Request) (bool, string, string, error) {
 		info, err := authChecker.Allow(ctx, ops...)
 		if err != nil {
 			// Bad macaroon
-			return false, "", "", err
+			return false, "", "", nil, err
 		}
 
 		if info != nil && info.Identity != nil {
 			// Valid identity macaroon found
-			return true, info.Identity.Id(), "candid", nil
+			return true, info.Identity.Id(), "candid", nil, nil
 		}
 
 		// Valid macaroon with no identity information
-		return true, "", "candid", nil
+		return true, "", "candid", nil, nil
+	}
+
+	if d.oidc != nil && strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+		// Validate OpenID Connect bearer tokens
+		identity, err := d.oidc.verifier.Verify(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+		if err != nil {
+			logger.Debug("Rejecting invalid OpenID Connect token", log.Ctx{"ip": r.RemoteAddr, "err": err})
+			return false, "", "", nil, nil
+		}
+
+		return true, identity.Username, "oidc", identity, nil
 	}
 
 	// Validate normal TLS access
 	for i := range r.TLS.PeerCertificates {
 		trusted, username := util.CheckTrustState(*r.TLS.PeerCertificates[i], d.clientCerts)
 		if trusted {
-			return true, username, "tls", nil
+			return true, username, "tls", nil, nil
 		}
 	}
 
 	// Reject unauthorized
-	return false, "", "", nil
+	return false, "", "", nil, nil
 }
 
 func writeMacaroonsRequiredResponse(b *identchecker.Bakery, r *http.Request, w http.ResponseWriter, derr *bakery.DischargeRequiredError, expiry int64) {
@@ -357,7 +382,7 @@ func (d *Daemon) createCmd(restAPI *mux.Router, version string, c APIEndpoint) {
 		}
 
 		// Authentication
-		trusted, username, protocol, err := d.Authenticate(r)
+		trusted, username, protocol, identity, err := d.authenticate(r)
 		if err != nil {
 			// If not a macaroon discharge request, return the error
 			_, ok := err.(*bakery.DischargeRequiredError)
@@ -381,6 +406,10 @@ func (d *Daemon) createCmd(restAPI *mux.Router, version string, c APIEndpoint) {
 		if trusted {
 			logger.Debug("Handling", log.Ctx{"method": r.Method, "url": r.URL.RequestURI(), "ip": r.RemoteAddr, "user": username})
 			r = r.WithContext(context.WithValue(r.Context(), "username", username))
+			r = r.WithContext(context.WithValue(r.Context(), "protocol", protocol))
+			if identity != nil {
+				r = r.WithContext(context.WithValue(r.Context(), "oidc", identity))
+			}
 		} else if untrustedOk && r.Header.Get("X-LXD-authenticated") == "" {
 			logger.Debug(fmt.Sprintf("Allowing untrusted %s", r.Method), log.Ctx{"url": r.URL.RequestURI(), "ip": r.RemoteAddr})
 		} else if derr, ok := err.(*bakery.DischargeRequiredError); ok {
@@ -446,6 +475,11 @@ func (d *Daemon) createCmd(restAPI *mux.Router, version string, c APIEndpoint) {
 			resp = NotFound(fmt.Errorf("Method '%s' not found", r.Method))
 		}
 
+		// Leave out the sensitive container fields for restricted users
+		if r.Method == "GET" && d.userIsRestricted(r, projectParam(r)) {
+			resp = RestrictedResponse(resp, shared.StringInSlice(c.Name, restrictedRawEndpoints))
+		}
+
 		// Handle errors
 		if err := resp.Render(w); err != nil {
 			err := InternalError(err).Render(w)
@@ -560,6 +594,13 @@ func (d *Daemon) init() error {
 		logger.Infof(" - seccomp listener: no")
 	}
 
+	d.os.TimeNamespace = shared.PathExists("/proc/self/ns/time")
+	if d.os.TimeNamespace {
+		logger.Infof(" - time namespace: yes")
+	} else {
+		logger.Infof(" - time namespace: no")
+	}
+
 	/*
 	 * During daemon startup we're the only thread that touches VFS3Fscaps
 	 * so we don't need to bother with atomic.StoreInt32() when touching
@@ -590,11 +631,21 @@ func (d *Daemon) init() error {
 		"network_l2proxy",
 		"network_gateway_device_route",
 		"network_phys_macvlan_mtu",
+		"idmapped_mounts_v2",
+		"time_namespace",
 	}
 	for _, extension := range lxcExtensions {
 		d.os.LXCFeatures[extension] = lxc.HasApiExtension(extension)
 	}
 
+	// Idmapped mounts are set up by liblxc, which needs to support them too.
+	if CanUseIdmappedMounts() && d.os.LXCFeatures["idmapped_mounts_v2"] {
+		d.os.IdmappedMounts = true
+		logger.Infof(" - idmapped mounts support: yes")
+	} else {
+		logger.Infof(" - idmapped mounts support: no")
+	}
+
 	/* Initialize the database */
 	dump, err := initializeDbObject(d)
 	if err != nil {
@@ -813,6 +864,13 @@ func (d *Daemon) init() error {
 	rbacAgentPublicKey := ""
 	rbacExpiry := int64(0)
 
+	oidcIssuer := ""
+	oidcAudience := ""
+	oidcUsernameClaim := ""
+	oidcGroupsClaim := ""
+	oidcAdminGroups := ""
+	oidcProjectGroups := ""
+
 	maasAPIURL := ""
 	maasAPIKey := ""
 	maasMachine := ""
@@ -844,6 +902,7 @@ func (d *Daemon) init() error {
 		candidAPIURL, candidAPIKey, candidExpiry, candidDomains = config.CandidServer()
 		maasAPIURL, maasAPIKey = config.MAASController()
 		rbacAPIURL, rbacAPIKey, rbacExpiry, rbacAgentURL, rbacAgentUsername, rbacAgentPrivateKey, rbacAgentPublicKey = config.RBACServer()
+		oidcIssuer, oidcAudience, oidcUsernameClaim, oidcGroupsClaim, oidcAdminGroups, oidcProjectGroups = config.OIDCServer()
 
 		return nil
 	})
@@ -865,10 +924,26 @@ func (d *Daemon) init() error {
 		}
 	}
 
+	if oidcIssuer != "" {
+		err = d.setupOIDC(oidcIssuer, oidcAudience, oidcUsernameClaim, oidcGroupsClaim, oidcAdminGroups, oidcProjectGroups)
+		if err != nil {
+			return err
+		}
+	}
+
 	if !d.os.MockMode {
 		// Start the scheduler
 		go deviceEventListener(d.State())
 
+		// Start the load-balancers
+		go loadBalancersManager(d)
+
+		// Retry the failed MAAS updates
+		go maasSyncManager(d)
+
+		// Record the AppArmor and seccomp denials of the containers
+		go securityDenialsWatch(d)
+
 		// Setup inotify watches
 		_, err := deviceInotifyInit(d.State())
 		if err != nil {
@@ -876,6 +951,7 @@ func (d *Daemon) init() error {
 		}
 
 		deviceInotifyDirRescan(d.State())
+		deviceHotplugRescan(d.State())
 		go deviceInotifyHandler(d.State())
 
 		// Setup seccomp handler
@@ -976,6 +1052,30 @@ func (d *Daemon) Ready() error {
 
 		// Remove expired container snapshots (minutely)
 		d.tasks.Add(pruneExpiredContainerSnapshotsTask(d))
+
+		// Back up the cluster database (minutely check of configurable cron expression)
+		d.tasks.Add(autoCreateDatabaseBackupTask(d))
+
+		// Watch for the emergency shutdown trigger (every 5 seconds)
+		d.tasks.Add(emergencyShutdownTriggerTask(d))
+
+		// Update the containers following an image (every 15 minutes)
+		d.tasks.Add(containerImageFollowTask(d))
+
+		// Check the disk usage of running containers (every minute)
+		d.tasks.Add(containerDiskUsageTask(d))
+
+		// Run the periodic commands of running containers (minutely check of configurable cron expressions)
+		d.tasks.Add(containerTasksTask(d))
+
+		// Record the processes of the containers being debugged (every 5s)
+		d.tasks.Add(containerProcessesTask(d))
+
+		// Record the activity of the containers (every minute)
+		d.tasks.Add(containerActivityTask(d))
+
+		// Clean up the orphaned and zombie container monitors (every 30s)
+		d.tasks.Add(containerMonitorsTask(d))
 	}
 
 	// Start all background tasks
@@ -984,6 +1084,12 @@ func (d *Daemon) Ready() error {
 	// Get daemon state struct
 	s := d.State()
 
+	// Remove the ephemeral containers left behind by a crash
+	containersEphemeralCleanup(s)
+
+	// Put back the AppArmor policies left under test by a crash
+	containersSecurityTestReset(s)
+
 	// Restore containers
 	containersRestart(s)
 
@@ -1191,6 +1297,36 @@ func (d *Daemon) setupExternalAuthentication(authEndpoint string, authPubkey str
 	return nil
 }
 
+// Setup OpenID Connect authentication
+func (d *Daemon) setupOIDC(issuer string, audience string, usernameClaim string, groupsClaim string, adminGroups string, projectGroups string) error {
+	// Allow disable OpenID Connect authentication
+	if issuer == "" {
+		d.oidc = nil
+		return nil
+	}
+
+	if audience == "" {
+		return fmt.Errorf("The OpenID Connect audience must be set along with the issuer")
+	}
+
+	roles, err := oidc.ParseRoles(adminGroups, projectGroups)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{
+		Transport: &http.Transport{Proxy: d.proxy},
+		Timeout:   30 * time.Second,
+	}
+
+	d.oidc = &oidcAuth{
+		verifier: oidc.NewVerifier(issuer, audience, usernameClaim, groupsClaim, client),
+		roles:    roles,
+	}
+
+	return nil
+}
+
 // Setup RBAC
 func (d *Daemon) setupRBACServer(rbacURL string, rbacKey string, rbacExpiry int64, rbacAgentURL string, rbacAgentUsername string, rbacAgentPrivateKey string, rbacAgentPublicKey string) error {
 	if d.rbac != nil || rbacURL == "" || rbacAgentURL == "" || rbacAgentUsername == "" || rbacAgentPrivateKey == "" || rbacAgentPublicKey == "" {
@@ -1235,6 +1371,11 @@ func (d *Daemon) setupRBACServer(rbacURL string, rbacKey string, rbacExpiry int6
 }
 
 func (d *Daemon) userIsAdmin(r *http.Request) bool {
+	identity, ok := r.Context().Value("oidc").(*oidc.Identity)
+	if ok {
+		return d.oidc != nil && d.oidc.roles.IsAdmin(identity.Groups)
+	}
+
 	if d.externalAuth == nil || d.rbac == nil || r.RemoteAddr == "@" {
 		return true
 	}
@@ -1243,6 +1384,11 @@ func (d *Daemon) userIsAdmin(r *http.Request) bool {
 }
 
 func (d *Daemon) userHasPermission(r *http.Request, project string, permission string) bool {
+	identity, ok := r.Context().Value("oidc").(*oidc.Identity)
+	if ok {
+		return d.oidc != nil && d.oidc.roles.HasPermission(identity.Groups, project, permission)
+	}
+
 	if d.externalAuth == nil || d.rbac == nil || r.RemoteAddr == "@" {
 		return true
 	}
@@ -1250,6 +1396,26 @@ func (d *Daemon) userHasPermission(r *http.Request, project string, permission s
 	return d.rbac.HasPermission(r.Context().Value("username").(string), project, permission)
 }
 
+// userIsRestricted returns whether the container details returned to the user
+// should leave out the sensitive fields.
+func (d *Daemon) userIsRestricted(r *http.Request, project string) bool {
+	// OpenID Connect users need to be able to manage the containers to see everything
+	identity, ok := r.Context().Value("oidc").(*oidc.Identity)
+	if ok {
+		return d.oidc == nil || !d.oidc.roles.HasPermission(identity.Groups, project, "manage-containers")
+	}
+
+	if d.externalAuth == nil || d.rbac == nil || r.RemoteAddr == "@" {
+		return false
+	}
+
+	if d.userIsAdmin(r) {
+		return false
+	}
+
+	return d.rbac.HasPermission(r.Context().Value("username").(string), project, "view-restricted")
+}
+
 // Setup MAAS
 func (d *Daemon) setupMAASController(server string, key string, machine string) error {
 	var err error
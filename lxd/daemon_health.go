@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/shared/api"
+)
+
+// Maximum number of undelivered events before the event hub is reported as unhealthy.
+const healthEventsBacklogMax = 1000
+
+// How long the health reported to any client through /1.0/health is cached,
+// the checks hitting the database, the storage pools and the seccomp socket.
+const healthCacheInterval = 10 * time.Second
+
+var healthCache api.Health
+var healthCacheTime time.Time
+var healthCacheLock sync.Mutex
+
+var healthCmd = APIEndpoint{
+	Name: "health",
+
+	Get: APIEndpointAction{Handler: healthGet, AllowUntrusted: true},
+}
+
+var internalHealthCmd = APIEndpoint{
+	Name: "health",
+
+	Get: APIEndpointAction{Handler: internalHealthGet},
+}
+
+// /1.0/health
+// Get the overall health of the daemon, without any details
+func healthGet(d *Daemon, r *http.Request) Response {
+	health := daemonHealthCached(d)
+	if health.Status != "ok" {
+		return Unavailable(fmt.Errorf("LXD daemon is degraded"))
+	}
+
+	return SyncResponse(true, api.Health{Status: health.Status})
+}
+
+// /internal/health
+// Get the health of each of the daemon subsystems
+func internalHealthGet(d *Daemon, r *http.Request) Response {
+	return SyncResponse(true, daemonHealth(d))
+}
+
+// daemonHealth runs all the subsystem checks and aggregates their results.
+func daemonHealth(d *Daemon) api.Health {
+	health := api.Health{
+		Status: "ok",
+		Checks: map[string]api.HealthCheck{
+			"database":   healthCheckDatabase(d),
+			"storage":    healthCheckStorage(d),
+			"inotify":    healthCheckInotify(d),
+			"seccomp":    healthCheckSeccomp(d),
+			"events":     healthCheckEvents(d),
+			"devmonitor": healthCheckDevMonitor(d),
+		},
+	}
+
+	for _, check := range health.Checks {
+		if check.Status == "error" {
+			health.Status = "degraded"
+			break
+		}
+	}
+
+	return health
+}
+
+// daemonHealthCached returns the health of the daemon, running the checks at
+// most once per healthCacheInterval whatever the number of requests.
+func daemonHealthCached(d *Daemon) api.Health {
+	healthCacheLock.Lock()
+	defer healthCacheLock.Unlock()
+
+	if time.Since(healthCacheTime) >= healthCacheInterval {
+		healthCache = daemonHealth(d)
+		healthCacheTime = time.Now()
+	}
+
+	return healthCache
+}
+
+func healthResult(err error) api.HealthCheck {
+	if err != nil {
+		return api.HealthCheck{Status: "error", Message: err.Error()}
+	}
+
+	return api.HealthCheck{Status: "ok"}
+}
+
+func healthCheckDatabase(d *Daemon) api.HealthCheck {
+	if d.cluster == nil || d.db == nil {
+		return healthResult(fmt.Errorf("Database not initialized"))
+	}
+
+	err := d.db.Transaction(func(tx *db.NodeTx) error {
+		_, err := tx.RaftNodes()
+		return err
+	})
+	if err != nil {
+		return healthResult(fmt.Errorf("Local database: %v", err))
+	}
+
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		_, err := tx.NodesCount()
+		return err
+	})
+	if err != nil {
+		return healthResult(fmt.Errorf("Cluster database: %v", err))
+	}
+
+	return healthResult(nil)
+}
+
+func healthCheckStorage(d *Daemon) api.HealthCheck {
+	if d.cluster == nil {
+		return healthResult(fmt.Errorf("Database not initialized"))
+	}
+
+	pools, err := d.cluster.StoragePoolsNotPending()
+	if err == db.ErrNoSuchObject {
+		return api.HealthCheck{Status: "disabled"}
+	}
+
+	if err != nil {
+		return healthResult(err)
+	}
+
+	failures := []string{}
+	for _, pool := range pools {
+		s, err := storagePoolInit(d.State(), pool)
+		if err == nil {
+			err = s.StoragePoolCheck()
+		}
+
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", pool, err))
+		}
+	}
+
+	if len(failures) > 0 {
+		return healthResult(fmt.Errorf("Unavailable storage pools: %s", strings.Join(failures, ", ")))
+	}
+
+	return healthResult(nil)
+}
+
+func healthCheckInotify(d *Daemon) api.HealthCheck {
+	if d.os.MockMode {
+		return api.HealthCheck{Status: "disabled"}
+	}
+
+	d.os.InotifyWatch.RLock()
+	fd := d.os.InotifyWatch.Fd
+	d.os.InotifyWatch.RUnlock()
+
+	if fd < 0 {
+		return healthResult(fmt.Errorf("Inotify isn't initialized"))
+	}
+
+	if atomic.LoadInt32(&deviceInotifyHandlerRunning) == 0 {
+		return healthResult(fmt.Errorf("Inotify handler isn't running"))
+	}
+
+	return healthResult(nil)
+}
+
+func healthCheckSeccomp(d *Daemon) api.HealthCheck {
+	if d.seccomp == nil {
+		return api.HealthCheck{Status: "disabled"}
+	}
+
+	conn, err := net.DialTimeout("unixpacket", d.seccomp.path, 5*time.Second)
+	if err != nil {
+		return healthResult(fmt.Errorf("Seccomp socket isn't responding: %v", err))
+	}
+	conn.Close()
+
+	return healthResult(nil)
+}
+
+func healthCheckEvents(d *Daemon) api.HealthCheck {
+	pending := atomic.LoadInt64(&eventsPending)
+	if pending > healthEventsBacklogMax {
+		return healthResult(fmt.Errorf("%d events waiting to be delivered", pending))
+	}
+
+	return api.HealthCheck{Status: "ok", Message: fmt.Sprintf("%d events waiting to be delivered", pending)}
+}
+
+func healthCheckDevMonitor(d *Daemon) api.HealthCheck {
+	if d.os.MockMode {
+		return api.HealthCheck{Status: "disabled"}
+	}
+
+	if atomic.LoadInt32(&deviceEventListenerRunning) == 0 {
+		return healthResult(fmt.Errorf("Device event listener isn't running"))
+	}
+
+	return healthResult(nil)
+}
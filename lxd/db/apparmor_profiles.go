@@ -0,0 +1,159 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lxc/lxd/lxd/db/query"
+	"github.com/lxc/lxd/shared/version"
+	"github.com/pkg/errors"
+)
+
+// ApparmorProfile is a full AppArmor profile managed by LXD, which containers
+// use through security.apparmor.profile.
+type ApparmorProfile struct {
+	Name        string
+	Description string
+	Content     string
+}
+
+// ApparmorProfileNames returns the names of all the AppArmor profiles.
+func (c *ClusterTx) ApparmorProfileNames() ([]string, error) {
+	return query.SelectStrings(c.tx, "SELECT name FROM apparmor_profiles ORDER BY name")
+}
+
+// ApparmorProfileGet returns the AppArmor profile with the given name.
+func (c *ClusterTx) ApparmorProfileGet(name string) (ApparmorProfile, error) {
+	profile := ApparmorProfile{}
+
+	profiles := []ApparmorProfile{}
+	dest := func(i int) []interface{} {
+		profiles = append(profiles, ApparmorProfile{})
+		return []interface{}{&profiles[i].Name, &profiles[i].Description, &profiles[i].Content}
+	}
+
+	stmt, err := c.tx.Prepare("SELECT name, coalesce(description, ''), content FROM apparmor_profiles WHERE name=?")
+	if err != nil {
+		return profile, err
+	}
+	defer stmt.Close()
+
+	err = query.SelectObjects(stmt, dest, name)
+	if err != nil {
+		return profile, errors.Wrap(err, "Failed to fetch AppArmor profile")
+	}
+
+	if len(profiles) == 0 {
+		return profile, ErrNoSuchObject
+	}
+
+	return profiles[0], nil
+}
+
+// ApparmorProfileCreate adds a new AppArmor profile.
+func (c *ClusterTx) ApparmorProfileCreate(profile ApparmorProfile) error {
+	_, err := c.tx.Exec("INSERT INTO apparmor_profiles (name, description, content) VALUES (?, ?, ?)", profile.Name, profile.Description, profile.Content)
+	if err != nil {
+		return errors.Wrap(err, "Failed to create AppArmor profile")
+	}
+
+	return nil
+}
+
+// ApparmorProfileUpdate changes the description and content of the AppArmor
+// profile with the given name.
+func (c *ClusterTx) ApparmorProfileUpdate(name string, profile ApparmorProfile) error {
+	result, err := c.tx.Exec("UPDATE apparmor_profiles SET description=?, content=? WHERE name=?", profile.Description, profile.Content, name)
+	if err != nil {
+		return errors.Wrap(err, "Failed to update AppArmor profile")
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrNoSuchObject
+	}
+
+	return nil
+}
+
+// ApparmorProfileDelete deletes the AppArmor profile with the given name.
+func (c *ClusterTx) ApparmorProfileDelete(name string) error {
+	result, err := c.tx.Exec("DELETE FROM apparmor_profiles WHERE name=?", name)
+	if err != nil {
+		return errors.Wrap(err, "Failed to delete AppArmor profile")
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrNoSuchObject
+	}
+
+	return nil
+}
+
+// ApparmorProfileUsedBy returns the URLs of the containers, snapshots and
+// profiles of all projects setting security.apparmor.profile to the AppArmor
+// profile with the given name.
+func (c *ClusterTx) ApparmorProfileUsedBy(name string) ([]string, error) {
+	usedBy := []string{}
+
+	stmts := map[string]string{
+		"containers": `
+SELECT instances.name, projects.name
+  FROM instances_config
+  JOIN instances ON instances.id=instances_config.instance_id
+  JOIN projects ON projects.id=instances.project_id
+  WHERE instances_config.key='security.apparmor.profile' AND instances_config.value=?
+  ORDER BY projects.name, instances.name
+`,
+		"profiles": `
+SELECT profiles.name, projects.name
+  FROM profiles_config
+  JOIN profiles ON profiles.id=profiles_config.profile_id
+  JOIN projects ON projects.id=profiles.project_id
+  WHERE profiles_config.key='security.apparmor.profile' AND profiles_config.value=?
+  ORDER BY projects.name, profiles.name
+`,
+	}
+
+	for _, kind := range []string{"containers", "profiles"} {
+		rows := [][2]string{}
+		dest := func(i int) []interface{} {
+			rows = append(rows, [2]string{})
+			return []interface{}{&rows[i][0], &rows[i][1]}
+		}
+
+		stmt, err := c.tx.Prepare(stmts[kind])
+		if err != nil {
+			return nil, err
+		}
+
+		err = query.SelectObjects(stmt, dest, name)
+		stmt.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to fetch the %s using AppArmor profile %q", kind, name)
+		}
+
+		for _, row := range rows {
+			// Snapshots are stored as <container>/<snapshot>
+			entity := strings.Replace(row[0], "/", "/snapshots/", 1)
+
+			uri := fmt.Sprintf("/%s/%s/%s", version.APIVersion, kind, entity)
+			if row[1] != "default" {
+				uri += fmt.Sprintf("?project=%s", row[1])
+			}
+
+			usedBy = append(usedBy, uri)
+		}
+	}
+
+	return usedBy, nil
+}
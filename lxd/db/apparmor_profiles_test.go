@@ -0,0 +1,67 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApparmorProfiles(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	profile := db.ApparmorProfile{
+		Name:        "postgres",
+		Description: "PostgreSQL servers",
+		Content:     "profile postgres flags=(attach_disconnected,mediate_deleted) {\n}\n",
+	}
+
+	err := tx.ApparmorProfileCreate(profile)
+	require.NoError(t, err)
+
+	// Names are unique
+	err = tx.ApparmorProfileCreate(profile)
+	assert.Error(t, err)
+
+	names, err := tx.ApparmorProfileNames()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"postgres"}, names)
+
+	profile.Description = "PostgreSQL 12 servers"
+	err = tx.ApparmorProfileUpdate("postgres", profile)
+	require.NoError(t, err)
+
+	stored, err := tx.ApparmorProfileGet("postgres")
+	require.NoError(t, err)
+	assert.Equal(t, profile, stored)
+
+	// Containers, snapshots and profiles count as users
+	addContainer(t, tx, 1, "c1")
+	addContainerConfig(t, tx, "c1", "security.apparmor.profile", "postgres")
+	addSnapshot(t, tx, 1, "c1", 1)
+	addContainerConfig(t, tx, "c1/1", "security.apparmor.profile", "postgres")
+	addContainer(t, tx, 1, "c2")
+	addContainerConfig(t, tx, "c2", "security.apparmor.profile", "mysql")
+
+	_, err = tx.Tx().Exec("INSERT INTO profiles_config (profile_id, key, value) VALUES (1, 'security.apparmor.profile', 'postgres')")
+	require.NoError(t, err)
+
+	usedBy, err := tx.ApparmorProfileUsedBy("postgres")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"/1.0/containers/c1",
+		"/1.0/containers/c1/snapshots/1",
+		"/1.0/profiles/default",
+	}, usedBy)
+
+	err = tx.ApparmorProfileDelete("postgres")
+	require.NoError(t, err)
+
+	_, err = tx.ApparmorProfileGet("postgres")
+	assert.Equal(t, db.ErrNoSuchObject, err)
+
+	err = tx.ApparmorProfileDelete("postgres")
+	assert.Equal(t, db.ErrNoSuchObject, err)
+}
@@ -6,6 +6,13 @@ package cluster
 // modify the database schema, please add a new schema update to update.go
 // and the run 'make update-schema'.
 const freshSchema = `
+CREATE TABLE apparmor_profiles (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    name TEXT NOT NULL,
+    description TEXT,
+    content TEXT NOT NULL,
+    UNIQUE (name)
+);
 CREATE TABLE certificates (
     id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
     fingerprint TEXT NOT NULL,
@@ -91,6 +98,14 @@ CREATE TABLE "instances" (
     FOREIGN KEY (node_id) REFERENCES nodes (id) ON DELETE CASCADE,
     FOREIGN KEY (project_id) REFERENCES projects (id) ON DELETE CASCADE
 );
+CREATE TABLE instances_activity (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    instance_id INTEGER NOT NULL,
+    type TEXT NOT NULL,
+    date DATETIME NOT NULL,
+    UNIQUE (instance_id, type),
+    FOREIGN KEY (instance_id) REFERENCES instances (id) ON DELETE CASCADE
+);
 CREATE TABLE "instances_backups" (
     id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
     instance_id INTEGER NOT NULL,
@@ -102,6 +117,18 @@ CREATE TABLE "instances_backups" (
     FOREIGN KEY (instance_id) REFERENCES "instances" (id) ON DELETE CASCADE,
     UNIQUE (instance_id, name)
 );
+CREATE TABLE instances_checkpoints (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    instance_id INTEGER NOT NULL,
+    snapshot_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    date DATETIME NOT NULL,
+    description TEXT,
+    UNIQUE (instance_id, name),
+    UNIQUE (snapshot_id),
+    FOREIGN KEY (instance_id) REFERENCES instances (id) ON DELETE CASCADE,
+    FOREIGN KEY (snapshot_id) REFERENCES instances (id) ON DELETE CASCADE
+);
 CREATE TABLE "instances_config" (
     id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
     instance_id INTEGER NOT NULL,
@@ -193,13 +220,64 @@ CREATE INDEX instances_project_id_and_node_id_and_name_idx ON instances (project
 CREATE INDEX instances_project_id_and_node_id_idx ON instances (project_id,
     node_id);
 CREATE INDEX instances_project_id_idx ON instances (project_id);
+CREATE TABLE instances_revisions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    instance_id INTEGER NOT NULL,
+    revision INTEGER NOT NULL,
+    date DATETIME NOT NULL,
+    requester TEXT NOT NULL,
+    architecture INTEGER NOT NULL,
+    description TEXT,
+    ephemeral INTEGER NOT NULL DEFAULT 0,
+    config TEXT NOT NULL,
+    devices TEXT NOT NULL,
+    profiles TEXT NOT NULL,
+    snapshot TEXT,
+    snapshot_description TEXT,
+    UNIQUE (instance_id, revision),
+    FOREIGN KEY (instance_id) REFERENCES instances (id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX instances_revisions_instance_id_snapshot_idx ON instances_revisions (instance_id, snapshot);
+CREATE TABLE instances_secrets (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    instance_id INTEGER NOT NULL,
+    key TEXT NOT NULL,
+    value TEXT NOT NULL,
+    UNIQUE (instance_id, key),
+    FOREIGN KEY (instance_id) REFERENCES instances (id) ON DELETE CASCADE
+);
+CREATE TABLE load_balancers (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    name TEXT NOT NULL,
+    description TEXT,
+    UNIQUE (name)
+);
+CREATE TABLE load_balancers_config (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    load_balancer_id INTEGER NOT NULL,
+    key TEXT NOT NULL,
+    value TEXT,
+    UNIQUE (load_balancer_id, key),
+    FOREIGN KEY (load_balancer_id) REFERENCES load_balancers (id) ON DELETE CASCADE
+);
 CREATE TABLE networks (
     id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
     name TEXT NOT NULL,
     description TEXT,
     state INTEGER NOT NULL DEFAULT 0,
+    type INTEGER NOT NULL DEFAULT 0,
     UNIQUE (name)
 );
+CREATE TABLE networks_allocations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    network_id INTEGER NOT NULL,
+    instance_id INTEGER NOT NULL,
+    device TEXT NOT NULL,
+    address TEXT NOT NULL,
+    UNIQUE (network_id, address),
+    FOREIGN KEY (network_id) REFERENCES networks (id) ON DELETE CASCADE,
+    FOREIGN KEY (instance_id) REFERENCES instances (id) ON DELETE CASCADE
+);
 CREATE TABLE networks_config (
     id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
     network_id INTEGER NOT NULL,
@@ -405,5 +483,5 @@ CREATE TABLE storage_volumes_config (
     FOREIGN KEY (storage_volume_id) REFERENCES storage_volumes (id) ON DELETE CASCADE
 );
 
-INSERT INTO schema (version, updated_at) VALUES (15, strftime("%s"))
+INSERT INTO schema (version, updated_at) VALUES (23, strftime("%s"))
 `
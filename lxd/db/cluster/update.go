@@ -48,6 +48,157 @@ var updates = map[int]schema.Update{
 	13: updateFromV12,
 	14: updateFromV13,
 	15: updateFromV14,
+	16: updateFromV15,
+	17: updateFromV16,
+	18: updateFromV17,
+	19: updateFromV18,
+	20: updateFromV19,
+	21: updateFromV20,
+	22: updateFromV21,
+	23: updateFromV22,
+}
+
+// Add the instances_checkpoints table.
+func updateFromV22(tx *sql.Tx) error {
+	stmts := `
+CREATE TABLE instances_checkpoints (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    instance_id INTEGER NOT NULL,
+    snapshot_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    date DATETIME NOT NULL,
+    description TEXT,
+    UNIQUE (instance_id, name),
+    UNIQUE (snapshot_id),
+    FOREIGN KEY (instance_id) REFERENCES instances (id) ON DELETE CASCADE,
+    FOREIGN KEY (snapshot_id) REFERENCES instances (id) ON DELETE CASCADE
+);
+`
+	_, err := tx.Exec(stmts)
+	return err
+}
+
+// Add the apparmor_profiles table.
+func updateFromV21(tx *sql.Tx) error {
+	stmts := `
+CREATE TABLE apparmor_profiles (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    name TEXT NOT NULL,
+    description TEXT,
+    content TEXT NOT NULL,
+    UNIQUE (name)
+);
+`
+	_, err := tx.Exec(stmts)
+	return err
+}
+
+// Add the instances_secrets table.
+func updateFromV20(tx *sql.Tx) error {
+	stmts := `
+CREATE TABLE instances_secrets (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    instance_id INTEGER NOT NULL,
+    key TEXT NOT NULL,
+    value TEXT NOT NULL,
+    UNIQUE (instance_id, key),
+    FOREIGN KEY (instance_id) REFERENCES instances (id) ON DELETE CASCADE
+);
+`
+	_, err := tx.Exec(stmts)
+	return err
+}
+
+// Add the snapshot columns to instances_revisions, naming the revisions kept
+// as configuration snapshots.
+func updateFromV19(tx *sql.Tx) error {
+	stmts := `
+ALTER TABLE instances_revisions ADD COLUMN snapshot TEXT;
+ALTER TABLE instances_revisions ADD COLUMN snapshot_description TEXT;
+CREATE UNIQUE INDEX instances_revisions_instance_id_snapshot_idx ON instances_revisions (instance_id, snapshot);
+`
+	_, err := tx.Exec(stmts)
+	return err
+}
+
+// Add the instances_activity table.
+func updateFromV18(tx *sql.Tx) error {
+	stmts := `
+CREATE TABLE instances_activity (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    instance_id INTEGER NOT NULL,
+    type TEXT NOT NULL,
+    date DATETIME NOT NULL,
+    UNIQUE (instance_id, type),
+    FOREIGN KEY (instance_id) REFERENCES instances (id) ON DELETE CASCADE
+);
+`
+	_, err := tx.Exec(stmts)
+	return err
+}
+
+// Add the instances_revisions table.
+func updateFromV17(tx *sql.Tx) error {
+	stmts := `
+CREATE TABLE instances_revisions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    instance_id INTEGER NOT NULL,
+    revision INTEGER NOT NULL,
+    date DATETIME NOT NULL,
+    requester TEXT NOT NULL,
+    architecture INTEGER NOT NULL,
+    description TEXT,
+    ephemeral INTEGER NOT NULL DEFAULT 0,
+    config TEXT NOT NULL,
+    devices TEXT NOT NULL,
+    profiles TEXT NOT NULL,
+    UNIQUE (instance_id, revision),
+    FOREIGN KEY (instance_id) REFERENCES instances (id) ON DELETE CASCADE
+);
+`
+	_, err := tx.Exec(stmts)
+	return err
+}
+
+// Add the type column to networks and the networks_allocations table.
+func updateFromV16(tx *sql.Tx) error {
+	stmts := `
+ALTER TABLE networks ADD COLUMN type INTEGER NOT NULL DEFAULT 0;
+CREATE TABLE networks_allocations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    network_id INTEGER NOT NULL,
+    instance_id INTEGER NOT NULL,
+    device TEXT NOT NULL,
+    address TEXT NOT NULL,
+    UNIQUE (network_id, address),
+    FOREIGN KEY (network_id) REFERENCES networks (id) ON DELETE CASCADE,
+    FOREIGN KEY (instance_id) REFERENCES instances (id) ON DELETE CASCADE
+);
+`
+	_, err := tx.Exec(stmts)
+	return err
+}
+
+// Add the load_balancers and load_balancers_config tables.
+func updateFromV15(tx *sql.Tx) error {
+	stmts := `
+CREATE TABLE load_balancers (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    name TEXT NOT NULL,
+    description TEXT,
+    UNIQUE (name)
+);
+CREATE TABLE load_balancers_config (
+    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
+    load_balancer_id INTEGER NOT NULL,
+    key TEXT NOT NULL,
+    value TEXT,
+    UNIQUE (load_balancer_id, key),
+    FOREIGN KEY (load_balancer_id) REFERENCES load_balancers (id) ON DELETE CASCADE
+);
+`
+	_, err := tx.Exec(stmts)
+	return err
 }
 
 // Rename all containers* tables to instances*/
@@ -142,6 +142,10 @@ type ContainerArgs struct {
 	Profiles     []string
 	Stateful     bool
 	ExpiryDate   time.Time
+
+	// Update only, whether the keys restricted to administrators may be
+	// changed
+	Admin bool
 }
 
 // ContainerBackupArgs is a value object holding all db-related details
@@ -177,6 +181,44 @@ SELECT instances.name FROM instances
 	return query.SelectStrings(c.tx, stmt, project, CTypeRegular)
 }
 
+// ContainerIDsByName returns the IDs of all the containers of the given
+// project, by name.
+func (c *ClusterTx) ContainerIDsByName(project string) (map[string]int, error) {
+	objects := []struct {
+		ID   int
+		Name string
+	}{}
+	dest := func(i int) []interface{} {
+		objects = append(objects, struct {
+			ID   int
+			Name string
+		}{})
+		return []interface{}{&objects[i].ID, &objects[i].Name}
+	}
+
+	stmt, err := c.tx.Prepare(`
+SELECT instances.id, instances.name FROM instances
+  JOIN projects ON projects.id = instances.project_id
+  WHERE projects.name = ? AND instances.type = ?
+`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	err = query.SelectObjects(stmt, dest, project, CTypeRegular)
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]int{}
+	for _, object := range objects {
+		result[object.Name] = object.ID
+	}
+
+	return result, nil
+}
+
 // ContainerNodeAddress returns the address of the node hosting the container
 // with the given name in the given project.
 //
@@ -525,6 +567,29 @@ func (c *ClusterTx) ContainerNodeProjectList(project string) ([]Instance, error)
 	return c.InstanceList(filter)
 }
 
+// ContainerNodeProjectSnapshotList returns all snapshots of the containers of
+// the given project running on the local node, ordered by creation date.
+func (c *ClusterTx) ContainerNodeProjectSnapshotList(project string) ([]Instance, error) {
+	node, err := c.NodeName()
+	if err != nil {
+		return nil, errors.Wrap(err, "Local node name")
+	}
+	filter := InstanceFilter{
+		Project: project,
+		Node:    node,
+		Type:    int(CTypeSnapshot),
+	}
+
+	snapshots, err := c.InstanceList(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].CreationDate.Before(snapshots[j].CreationDate) })
+
+	return snapshots, nil
+}
+
 // ContainerConfigInsert inserts a new config for the container with the given ID.
 func (c *ClusterTx) ContainerConfigInsert(id int, config map[string]string) error {
 	return ContainerConfigInsert(c.tx, id, config)
@@ -1125,6 +1190,84 @@ WHERE projects.name=? AND instances.name=?`
 	return result, nil
 }
 
+// ContainerGetBackupsFull returns all backups of the container with the given
+// name, loaded with a single query.
+func (c *Cluster) ContainerGetBackupsFull(project, name string) ([]ContainerBackupArgs, error) {
+	var result []ContainerBackupArgs
+
+	err := c.Transaction(func(tx *ClusterTx) error {
+		var err error
+		result, err = tx.containerBackupsGet("projects.name=? AND instances.name=?", project, name)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// ContainerProjectBackups returns all backups of the containers of the given
+// project, by container ID.
+func (c *ClusterTx) ContainerProjectBackups(project string) (map[int][]ContainerBackupArgs, error) {
+	backups, err := c.containerBackupsGet("projects.name=?", project)
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[int][]ContainerBackupArgs{}
+	for _, args := range backups {
+		result[args.ContainerID] = append(result[args.ContainerID], args)
+	}
+
+	return result, nil
+}
+
+// Returns the backups of the containers matching the given condition.
+func (c *ClusterTx) containerBackupsGet(where string, args ...interface{}) ([]ContainerBackupArgs, error) {
+	result := []ContainerBackupArgs{}
+
+	q := fmt.Sprintf(`
+SELECT instances_backups.id, instances_backups.instance_id, instances_backups.name,
+       instances_backups.creation_date, instances_backups.expiry_date,
+       instances_backups.container_only, instances_backups.optimized_storage
+    FROM instances_backups
+    JOIN instances ON instances.id=instances_backups.instance_id
+    JOIN projects ON projects.id=instances.project_id
+    WHERE %s
+    ORDER BY instances_backups.id
+`, where)
+	rows, err := c.tx.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		backup := ContainerBackupArgs{}
+		containerOnlyInt := -1
+		optimizedStorageInt := -1
+
+		err := rows.Scan(&backup.ID, &backup.ContainerID, &backup.Name,
+			&backup.CreationDate, &backup.ExpiryDate, &containerOnlyInt,
+			&optimizedStorageInt)
+		if err != nil {
+			return nil, err
+		}
+
+		backup.ContainerOnly = containerOnlyInt == 1
+		backup.OptimizedStorage = optimizedStorageInt == 1
+		result = append(result, backup)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
 // ContainerBackupCreate creates a new backup
 func (c *Cluster) ContainerBackupCreate(args ContainerBackupArgs) error {
 	_, err := c.ContainerBackupID(args.Name)
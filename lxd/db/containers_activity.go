@@ -0,0 +1,106 @@
+package db
+
+import (
+	"time"
+
+	"github.com/lxc/lxd/lxd/db/query"
+	"github.com/pkg/errors"
+)
+
+// Types of container activity which get recorded.
+const (
+	ContainerActivityExec    = "exec"
+	ContainerActivityConsole = "console"
+	ContainerActivityFile    = "file"
+	ContainerActivityNetwork = "network"
+)
+
+// ContainerActivityGet returns the date of the latest activity of each type
+// recorded for the instance with the given ID.
+func (c *ClusterTx) ContainerActivityGet(instanceID int) (map[string]time.Time, error) {
+	type row struct {
+		kind string
+		date time.Time
+	}
+
+	rows := []row{}
+	dest := func(i int) []interface{} {
+		rows = append(rows, row{})
+		return []interface{}{&rows[i].kind, &rows[i].date}
+	}
+
+	stmt, err := c.tx.Prepare("SELECT type, date FROM instances_activity WHERE instance_id=?")
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	err = query.SelectObjects(stmt, dest, instanceID)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to fetch container activity")
+	}
+
+	activity := map[string]time.Time{}
+	for _, r := range rows {
+		activity[r.kind] = r.date
+	}
+
+	return activity, nil
+}
+
+// ContainerActivityGetProject returns the date of the latest activity of each
+// type recorded for the instances of the given project, by instance ID.
+func (c *ClusterTx) ContainerActivityGetProject(project string) (map[int]map[string]time.Time, error) {
+	type row struct {
+		id   int
+		kind string
+		date time.Time
+	}
+
+	rows := []row{}
+	dest := func(i int) []interface{} {
+		rows = append(rows, row{})
+		return []interface{}{&rows[i].id, &rows[i].kind, &rows[i].date}
+	}
+
+	stmt, err := c.tx.Prepare(`
+SELECT instances_activity.instance_id, instances_activity.type, instances_activity.date
+  FROM instances_activity
+  JOIN instances ON instances.id = instances_activity.instance_id
+  JOIN projects ON projects.id = instances.project_id
+ WHERE projects.name = ?
+`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	err = query.SelectObjects(stmt, dest, project)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to fetch container activity")
+	}
+
+	activity := map[int]map[string]time.Time{}
+	for _, r := range rows {
+		if activity[r.id] == nil {
+			activity[r.id] = map[string]time.Time{}
+		}
+
+		activity[r.id][r.kind] = r.date
+	}
+
+	return activity, nil
+}
+
+// ContainerActivityUpdate records the date of the latest activity of the
+// given type for the instance with the given ID.
+func (c *ClusterTx) ContainerActivityUpdate(instanceID int, kind string, date time.Time) error {
+	columns := []string{"instance_id", "type", "date"}
+	values := []interface{}{instanceID, kind, date}
+	_, err := query.UpsertObject(c.tx, "instances_activity", columns, values)
+	if err != nil {
+		return errors.Wrap(err, "Failed to record container activity")
+	}
+
+	return nil
+}
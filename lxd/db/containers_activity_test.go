@@ -0,0 +1,42 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContainerActivityGetProject(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	addContainer(t, tx, 1, "c1")
+	addContainer(t, tx, 1, "c2")
+	addSnapshot(t, tx, 1, "c1", 1)
+
+	id1 := getContainerID(t, tx, "c1")
+	id2 := getContainerID(t, tx, "c2")
+
+	ids, err := tx.ContainerIDsByName("default")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"c1": int(id1), "c2": int(id2)}, ids)
+
+	date := time.Date(2019, time.January, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, tx.ContainerActivityUpdate(int(id1), db.ContainerActivityExec, date))
+	require.NoError(t, tx.ContainerActivityUpdate(int(id1), db.ContainerActivityFile, date.Add(time.Hour)))
+	require.NoError(t, tx.ContainerActivityUpdate(int(id2), db.ContainerActivityConsole, date))
+
+	activity, err := tx.ContainerActivityGetProject("default")
+	require.NoError(t, err)
+	assert.Len(t, activity, 2)
+	assert.True(t, date.Equal(activity[int(id1)][db.ContainerActivityExec]))
+	assert.True(t, date.Add(time.Hour).Equal(activity[int(id1)][db.ContainerActivityFile]))
+	assert.True(t, date.Equal(activity[int(id2)][db.ContainerActivityConsole]))
+
+	activity, err = tx.ContainerActivityGetProject("other")
+	require.NoError(t, err)
+	assert.Len(t, activity, 0)
+}
@@ -0,0 +1,83 @@
+package db
+
+import (
+	"time"
+
+	"github.com/lxc/lxd/lxd/db/query"
+	"github.com/pkg/errors"
+)
+
+// ContainerCheckpoint is a named checkpoint of a container, referencing the
+// snapshot which holds its root filesystem and configuration.
+type ContainerCheckpoint struct {
+	Name        string
+	Date        time.Time
+	Description string
+	SnapshotID  int
+	Snapshot    string
+}
+
+// ContainerCheckpoints returns the checkpoints of the instance with the given
+// ID, oldest first.
+func (c *ClusterTx) ContainerCheckpoints(instanceID int) ([]ContainerCheckpoint, error) {
+	return c.containerCheckpointsSelect("instances_checkpoints.instance_id=? ORDER BY instances_checkpoints.date, instances_checkpoints.name", instanceID)
+}
+
+// ContainerCheckpointGet returns the checkpoint with the given name of the
+// instance with the given ID.
+func (c *ClusterTx) ContainerCheckpointGet(instanceID int, name string) (ContainerCheckpoint, error) {
+	checkpoints, err := c.containerCheckpointsSelect("instances_checkpoints.instance_id=? AND instances_checkpoints.name=?", instanceID, name)
+	if err != nil {
+		return ContainerCheckpoint{}, err
+	}
+
+	if len(checkpoints) == 0 {
+		return ContainerCheckpoint{}, ErrNoSuchObject
+	}
+
+	return checkpoints[0], nil
+}
+
+func (c *ClusterTx) containerCheckpointsSelect(where string, args ...interface{}) ([]ContainerCheckpoint, error) {
+	checkpoints := []ContainerCheckpoint{}
+	dest := func(i int) []interface{} {
+		checkpoints = append(checkpoints, ContainerCheckpoint{})
+		return []interface{}{
+			&checkpoints[i].Name, &checkpoints[i].Date, &checkpoints[i].Description,
+			&checkpoints[i].SnapshotID, &checkpoints[i].Snapshot,
+		}
+	}
+
+	stmt, err := c.tx.Prepare(`
+SELECT instances_checkpoints.name, instances_checkpoints.date, coalesce(instances_checkpoints.description, ''),
+       instances_checkpoints.snapshot_id, instances.name
+  FROM instances_checkpoints
+  JOIN instances ON instances.id=instances_checkpoints.snapshot_id
+  WHERE ` + where)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	err = query.SelectObjects(stmt, dest, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to fetch container checkpoints")
+	}
+
+	return checkpoints, nil
+}
+
+// ContainerCheckpointAdd records a new checkpoint of the instance with the
+// given ID. The checkpoint goes away with its snapshot.
+func (c *ClusterTx) ContainerCheckpointAdd(instanceID int, checkpoint ContainerCheckpoint) error {
+	stmt := `
+INSERT INTO instances_checkpoints (instance_id, snapshot_id, name, date, description)
+  VALUES (?, ?, ?, ?, ?)
+`
+	_, err := c.tx.Exec(stmt, instanceID, checkpoint.SnapshotID, checkpoint.Name, checkpoint.Date, checkpoint.Description)
+	if err != nil {
+		return errors.Wrap(err, "Failed to record container checkpoint")
+	}
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContainerCheckpoints(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	addContainer(t, tx, 1, "c1")
+	addSnapshot(t, tx, 1, "c1", 1)
+
+	id, err := tx.InstanceID("default", "c1")
+	require.NoError(t, err)
+
+	snapshotID, err := tx.InstanceID("default", "c1/1")
+	require.NoError(t, err)
+
+	checkpoint := db.ContainerCheckpoint{
+		Name:        "before-upgrade",
+		Date:        time.Now().UTC(),
+		Description: "Known good",
+		SnapshotID:  int(snapshotID),
+	}
+
+	err = tx.ContainerCheckpointAdd(int(id), checkpoint)
+	require.NoError(t, err)
+
+	// A snapshot is referenced by a single checkpoint
+	checkpoint.Name = "other"
+	err = tx.ContainerCheckpointAdd(int(id), checkpoint)
+	assert.Error(t, err)
+
+	checkpoints, err := tx.ContainerCheckpoints(int(id))
+	require.NoError(t, err)
+	require.Len(t, checkpoints, 1)
+
+	got, err := tx.ContainerCheckpointGet(int(id), "before-upgrade")
+	require.NoError(t, err)
+	assert.Equal(t, "Known good", got.Description)
+	assert.Equal(t, int(snapshotID), got.SnapshotID)
+	assert.Equal(t, "c1/1", got.Snapshot)
+
+	// The checkpoint goes away with its snapshot
+	_, err = tx.Tx().Exec("DELETE FROM instances WHERE id=?", snapshotID)
+	require.NoError(t, err)
+
+	_, err = tx.ContainerCheckpointGet(int(id), "before-upgrade")
+	assert.Equal(t, db.ErrNoSuchObject, err)
+}
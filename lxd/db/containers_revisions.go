@@ -0,0 +1,205 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/lxc/lxd/lxd/db/query"
+	"github.com/lxc/lxd/lxd/device/config"
+	"github.com/pkg/errors"
+)
+
+// ContainerRevision is the configuration of a container as set by an update.
+// A revision given a snapshot name is a configuration snapshot, which is kept
+// until deleted rather than pruned with the older revisions.
+type ContainerRevision struct {
+	Revision            int
+	Date                time.Time
+	Requester           string
+	Architecture        int
+	Description         string
+	Ephemeral           bool
+	Config              map[string]string
+	Devices             config.Devices
+	Profiles            []string
+	Snapshot            string
+	SnapshotDescription string
+}
+
+// ContainerRevisions returns the recorded revisions of the instance with the
+// given ID, oldest first.
+func (c *ClusterTx) ContainerRevisions(instanceID int) ([]ContainerRevision, error) {
+	return c.containerRevisionsSelect("instance_id=? ORDER BY revision", instanceID)
+}
+
+// ContainerRevisionGet returns a revision of the instance with the given ID.
+func (c *ClusterTx) ContainerRevisionGet(instanceID int, revision int) (ContainerRevision, error) {
+	revisions, err := c.containerRevisionsSelect("instance_id=? AND revision=?", instanceID, revision)
+	if err != nil {
+		return ContainerRevision{}, err
+	}
+
+	if len(revisions) == 0 {
+		return ContainerRevision{}, ErrNoSuchObject
+	}
+
+	return revisions[0], nil
+}
+
+// ContainerRevisionSnapshots returns the revisions of the instance with the
+// given ID which are kept as configuration snapshots, oldest first.
+func (c *ClusterTx) ContainerRevisionSnapshots(instanceID int) ([]ContainerRevision, error) {
+	return c.containerRevisionsSelect("instance_id=? AND snapshot IS NOT NULL ORDER BY revision", instanceID)
+}
+
+// ContainerRevisionSnapshotGet returns the revision of the instance with the
+// given ID which is kept as the configuration snapshot with the given name.
+func (c *ClusterTx) ContainerRevisionSnapshotGet(instanceID int, name string) (ContainerRevision, error) {
+	revisions, err := c.containerRevisionsSelect("instance_id=? AND snapshot=?", instanceID, name)
+	if err != nil {
+		return ContainerRevision{}, err
+	}
+
+	if len(revisions) == 0 {
+		return ContainerRevision{}, ErrNoSuchObject
+	}
+
+	return revisions[0], nil
+}
+
+// ContainerRevisionSnapshotDelete deletes the configuration snapshot with the
+// given name of the instance with the given ID. Its revision is left to be
+// pruned like any other.
+func (c *ClusterTx) ContainerRevisionSnapshotDelete(instanceID int, name string) error {
+	result, err := c.tx.Exec("UPDATE instances_revisions SET snapshot=NULL, snapshot_description=NULL WHERE instance_id=? AND snapshot=?", instanceID, name)
+	if err != nil {
+		return errors.Wrap(err, "Failed to delete container configuration snapshot")
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrNoSuchObject
+	}
+
+	return nil
+}
+
+func (c *ClusterTx) containerRevisionsSelect(where string, args ...interface{}) ([]ContainerRevision, error) {
+	type row struct {
+		revision  ContainerRevision
+		ephemeral int
+		config    string
+		devices   string
+		profiles  string
+		snapshot  sql.NullString
+	}
+
+	rows := []row{}
+	dest := func(i int) []interface{} {
+		rows = append(rows, row{})
+		r := &rows[len(rows)-1]
+		return []interface{}{
+			&r.revision.Revision, &r.revision.Date, &r.revision.Requester, &r.revision.Architecture,
+			&r.revision.Description, &r.ephemeral, &r.config, &r.devices, &r.profiles,
+			&r.snapshot, &r.revision.SnapshotDescription,
+		}
+	}
+
+	stmt, err := c.tx.Prepare(`
+SELECT revision, date, requester, architecture, coalesce(description, ''), ephemeral, config, devices, profiles,
+       snapshot, coalesce(snapshot_description, '')
+  FROM instances_revisions
+  WHERE ` + where)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	err = query.SelectObjects(stmt, dest, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to fetch container revisions")
+	}
+
+	revisions := make([]ContainerRevision, len(rows))
+	for i, r := range rows {
+		revisions[i] = r.revision
+		revisions[i].Ephemeral = r.ephemeral == 1
+		revisions[i].Snapshot = r.snapshot.String
+
+		err := json.Unmarshal([]byte(r.config), &revisions[i].Config)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to parse config of revision %d", r.revision.Revision)
+		}
+
+		err = json.Unmarshal([]byte(r.devices), &revisions[i].Devices)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to parse devices of revision %d", r.revision.Revision)
+		}
+
+		err = json.Unmarshal([]byte(r.profiles), &revisions[i].Profiles)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to parse profiles of revision %d", r.revision.Revision)
+		}
+	}
+
+	return revisions, nil
+}
+
+// ContainerRevisionAdd records a new revision of the instance with the given
+// ID, numbered after the latest one, and only keeps the given number of most
+// recent revisions besides the configuration snapshots. The number of the new
+// revision is returned.
+func (c *ClusterTx) ContainerRevisionAdd(instanceID int, revision ContainerRevision, keep int) (int, error) {
+	latest, err := query.SelectIntegers(c.tx, "SELECT coalesce(max(revision), 0) FROM instances_revisions WHERE instance_id=?", instanceID)
+	if err != nil {
+		return -1, err
+	}
+
+	revision.Revision = latest[0] + 1
+
+	configJSON, err := json.Marshal(revision.Config)
+	if err != nil {
+		return -1, err
+	}
+
+	devicesJSON, err := json.Marshal(revision.Devices)
+	if err != nil {
+		return -1, err
+	}
+
+	profilesJSON, err := json.Marshal(revision.Profiles)
+	if err != nil {
+		return -1, err
+	}
+
+	ephemeral := 0
+	if revision.Ephemeral {
+		ephemeral = 1
+	}
+
+	var snapshot interface{}
+	if revision.Snapshot != "" {
+		snapshot = revision.Snapshot
+	}
+
+	stmt := `
+INSERT INTO instances_revisions (instance_id, revision, date, requester, architecture, description, ephemeral, config, devices, profiles, snapshot, snapshot_description)
+  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+`
+	_, err = c.tx.Exec(stmt, instanceID, revision.Revision, revision.Date, revision.Requester, revision.Architecture, revision.Description, ephemeral, string(configJSON), string(devicesJSON), string(profilesJSON), snapshot, revision.SnapshotDescription)
+	if err != nil {
+		return -1, errors.Wrap(err, "Failed to record container revision")
+	}
+
+	_, err = c.tx.Exec("DELETE FROM instances_revisions WHERE instance_id=? AND revision<=? AND snapshot IS NULL", instanceID, revision.Revision-keep)
+	if err != nil {
+		return -1, errors.Wrap(err, "Failed to prune container revisions")
+	}
+
+	return revision.Revision, nil
+}
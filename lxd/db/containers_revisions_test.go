@@ -0,0 +1,117 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/device/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContainerRevisions(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	addContainer(t, tx, 1, "c1")
+
+	id, err := tx.InstanceID("default", "c1")
+	require.NoError(t, err)
+
+	revision := db.ContainerRevision{
+		Date:         time.Now().UTC(),
+		Requester:    "admin",
+		Architecture: 1,
+		Config:       map[string]string{"limits.cpu": "2"},
+		Devices:      config.Devices{"eth0": {"type": "nic", "nictype": "bridged", "parent": "lxdbr0"}},
+		Profiles:     []string{"default"},
+	}
+
+	for i := 1; i <= 4; i++ {
+		n, err := tx.ContainerRevisionAdd(int(id), revision, 3)
+		require.NoError(t, err)
+		assert.Equal(t, i, n)
+	}
+
+	// Only the 3 latest revisions are kept
+	revisions, err := tx.ContainerRevisions(int(id))
+	require.NoError(t, err)
+	require.Len(t, revisions, 3)
+	assert.Equal(t, 2, revisions[0].Revision)
+	assert.Equal(t, 4, revisions[2].Revision)
+
+	_, err = tx.ContainerRevisionGet(int(id), 1)
+	assert.Equal(t, db.ErrNoSuchObject, err)
+
+	got, err := tx.ContainerRevisionGet(int(id), 3)
+	require.NoError(t, err)
+	assert.Equal(t, "admin", got.Requester)
+	assert.Equal(t, revision.Config, got.Config)
+	assert.Equal(t, revision.Devices, got.Devices)
+	assert.Equal(t, revision.Profiles, got.Profiles)
+	assert.False(t, got.Ephemeral)
+}
+
+func TestContainerRevisionSnapshots(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	addContainer(t, tx, 1, "c1")
+
+	id, err := tx.InstanceID("default", "c1")
+	require.NoError(t, err)
+
+	revision := db.ContainerRevision{
+		Date:         time.Now().UTC(),
+		Requester:    "admin",
+		Architecture: 1,
+		Config:       map[string]string{"limits.cpu": "2"},
+		Devices:      config.Devices{"eth0": {"type": "nic", "nictype": "bridged", "parent": "lxdbr0"}},
+		Profiles:     []string{"default"},
+	}
+
+	snapshot := revision
+	snapshot.Snapshot = "before-limits"
+	snapshot.SnapshotDescription = "Known good"
+
+	n, err := tx.ContainerRevisionAdd(int(id), snapshot, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	// Names are unique per container
+	_, err = tx.ContainerRevisionAdd(int(id), snapshot, 2)
+	assert.Error(t, err)
+
+	// Configuration snapshots aren't pruned
+	for i := 0; i < 3; i++ {
+		_, err := tx.ContainerRevisionAdd(int(id), revision, 2)
+		require.NoError(t, err)
+	}
+
+	snapshots, err := tx.ContainerRevisionSnapshots(int(id))
+	require.NoError(t, err)
+	require.Len(t, snapshots, 1)
+	assert.Equal(t, 1, snapshots[0].Revision)
+
+	got, err := tx.ContainerRevisionSnapshotGet(int(id), "before-limits")
+	require.NoError(t, err)
+	assert.Equal(t, "Known good", got.SnapshotDescription)
+	assert.Equal(t, snapshot.Config, got.Config)
+
+	err = tx.ContainerRevisionSnapshotDelete(int(id), "before-limits")
+	require.NoError(t, err)
+
+	_, err = tx.ContainerRevisionSnapshotGet(int(id), "before-limits")
+	assert.Equal(t, db.ErrNoSuchObject, err)
+
+	err = tx.ContainerRevisionSnapshotDelete(int(id), "before-limits")
+	assert.Equal(t, db.ErrNoSuchObject, err)
+
+	// Once deleted, the snapshot is pruned like any other revision
+	_, err = tx.ContainerRevisionAdd(int(id), revision, 2)
+	require.NoError(t, err)
+
+	_, err = tx.ContainerRevisionGet(int(id), 1)
+	assert.Equal(t, db.ErrNoSuchObject, err)
+}
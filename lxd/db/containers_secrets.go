@@ -0,0 +1,67 @@
+package db
+
+import (
+	"sort"
+
+	"github.com/lxc/lxd/lxd/db/query"
+	"github.com/pkg/errors"
+)
+
+// ContainerSecrets returns the secrets of the instance with the given ID, as
+// a map of environment variable names to their encrypted values.
+func (c *ClusterTx) ContainerSecrets(instanceID int) (map[string]string, error) {
+	secrets, err := query.SelectConfig(c.tx, "instances_secrets", "instance_id=?", instanceID)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to fetch container secrets")
+	}
+
+	return secrets, nil
+}
+
+// ContainerSecretNames returns the sorted names of the secrets of the instance
+// with the given ID.
+func (c *ClusterTx) ContainerSecretNames(instanceID int) ([]string, error) {
+	names, err := query.SelectStrings(c.tx, "SELECT key FROM instances_secrets WHERE instance_id=?", instanceID)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to fetch container secret names")
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// ContainerSecretSet sets the encrypted value of the secret with the given
+// name of the instance with the given ID, replacing any previous value.
+func (c *ClusterTx) ContainerSecretSet(instanceID int, name string, value string) error {
+	stmt := `
+INSERT OR REPLACE INTO instances_secrets (instance_id, key, value)
+  VALUES (?, ?, ?)
+`
+	_, err := c.tx.Exec(stmt, instanceID, name, value)
+	if err != nil {
+		return errors.Wrap(err, "Failed to record container secret")
+	}
+
+	return nil
+}
+
+// ContainerSecretDelete deletes the secret with the given name of the instance
+// with the given ID.
+func (c *ClusterTx) ContainerSecretDelete(instanceID int, name string) error {
+	result, err := c.tx.Exec("DELETE FROM instances_secrets WHERE instance_id=? AND key=?", instanceID, name)
+	if err != nil {
+		return errors.Wrap(err, "Failed to delete container secret")
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrNoSuchObject
+	}
+
+	return nil
+}
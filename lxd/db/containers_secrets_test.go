@@ -0,0 +1,43 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContainerSecrets(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	addContainer(t, tx, 1, "c1")
+
+	id, err := tx.InstanceID("default", "c1")
+	require.NoError(t, err)
+
+	require.NoError(t, tx.ContainerSecretSet(int(id), "DB_PASSWORD", "encrypted-1"))
+	require.NoError(t, tx.ContainerSecretSet(int(id), "API_TOKEN", "encrypted-2"))
+
+	// Setting an existing secret rotates it
+	require.NoError(t, tx.ContainerSecretSet(int(id), "DB_PASSWORD", "encrypted-3"))
+
+	names, err := tx.ContainerSecretNames(int(id))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"API_TOKEN", "DB_PASSWORD"}, names)
+
+	secrets, err := tx.ContainerSecrets(int(id))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"API_TOKEN": "encrypted-2", "DB_PASSWORD": "encrypted-3"}, secrets)
+
+	err = tx.ContainerSecretDelete(int(id), "API_TOKEN")
+	require.NoError(t, err)
+
+	err = tx.ContainerSecretDelete(int(id), "API_TOKEN")
+	assert.Equal(t, db.ErrNoSuchObject, err)
+
+	names, err = tx.ContainerSecretNames(int(id))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"DB_PASSWORD"}, names)
+}
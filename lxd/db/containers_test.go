@@ -2,6 +2,7 @@ package db_test
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -405,6 +406,91 @@ func TestContainerPool(t *testing.T) {
 	assert.Equal(t, "default", poolName)
 }
 
+func TestContainerGetBackupsFull(t *testing.T) {
+	cluster, cleanup := db.NewTestCluster(t)
+	defer cleanup()
+
+	var id int64
+	err := cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		id, err = tx.InstanceCreate(db.Instance{Project: "default", Name: "c1", Node: "none"})
+		return err
+	})
+	require.NoError(t, err)
+
+	now := time.Now()
+	for _, name := range []string{"c1/backup0", "c1/backup1"} {
+		err = cluster.ContainerBackupCreate(db.ContainerBackupArgs{
+			ContainerID:   int(id),
+			Name:          name,
+			CreationDate:  now,
+			ExpiryDate:    now.Add(time.Hour),
+			ContainerOnly: name == "c1/backup1",
+		})
+		require.NoError(t, err)
+	}
+
+	backups, err := cluster.ContainerGetBackupsFull("default", "c1")
+	require.NoError(t, err)
+	require.Len(t, backups, 2)
+
+	assert.Equal(t, "c1/backup0", backups[0].Name)
+	assert.Equal(t, int(id), backups[0].ContainerID)
+	assert.False(t, backups[0].ContainerOnly)
+	assert.Equal(t, now.Unix(), backups[0].CreationDate.Unix())
+	assert.Equal(t, "c1/backup1", backups[1].Name)
+	assert.True(t, backups[1].ContainerOnly)
+
+	backups, err = cluster.ContainerGetBackupsFull("default", "c2")
+	require.NoError(t, err)
+	assert.Len(t, backups, 0)
+}
+
+func TestContainerProjectBackups(t *testing.T) {
+	cluster, cleanup := db.NewTestCluster(t)
+	defer cleanup()
+
+	ids := map[string]int{}
+	err := cluster.Transaction(func(tx *db.ClusterTx) error {
+		for _, name := range []string{"c1", "c2"} {
+			id, err := tx.InstanceCreate(db.Instance{Project: "default", Name: name, Node: "none"})
+			if err != nil {
+				return err
+			}
+
+			ids[name] = int(id)
+		}
+
+		return nil
+	})
+	require.NoError(t, err)
+
+	now := time.Now()
+	for _, name := range []string{"c1/backup0", "c1/backup1", "c2/backup0"} {
+		err = cluster.ContainerBackupCreate(db.ContainerBackupArgs{
+			ContainerID:  ids[strings.Split(name, "/")[0]],
+			Name:         name,
+			CreationDate: now,
+			ExpiryDate:   now.Add(time.Hour),
+		})
+		require.NoError(t, err)
+	}
+
+	var backups map[int][]db.ContainerBackupArgs
+	err = cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		backups, err = tx.ContainerProjectBackups("default")
+		return err
+	})
+	require.NoError(t, err)
+
+	require.Len(t, backups[ids["c1"]], 2)
+	assert.Equal(t, "c1/backup0", backups[ids["c1"]][0].Name)
+	assert.Equal(t, "c1/backup1", backups[ids["c1"]][1].Name)
+	require.Len(t, backups[ids["c2"]], 1)
+	assert.Equal(t, "c2/backup0", backups[ids["c2"]][0].Name)
+}
+
 // Only containers running on the local node are returned.
 func TestContainersNodeList(t *testing.T) {
 	cluster, cleanup := db.NewTestCluster(t)
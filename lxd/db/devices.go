@@ -27,6 +27,14 @@ func dbDeviceTypeToString(t int) (string, error) {
 		return "infiniband", nil
 	case 8:
 		return "proxy", nil
+	case 9:
+		return "watchdog", nil
+	case 10:
+		return "entropy", nil
+	case 11:
+		return "hotplug", nil
+	case 12:
+		return "tpm", nil
 	default:
 		return "", fmt.Errorf("Invalid device type %d", t)
 	}
@@ -52,6 +60,14 @@ func dbDeviceTypeToInt(t string) (int, error) {
 		return 7, nil
 	case "proxy":
 		return 8, nil
+	case "watchdog":
+		return 9, nil
+	case "entropy":
+		return 10, nil
+	case "hotplug":
+		return 11, nil
+	case "tpm":
+		return 12, nil
 	default:
 		return -1, fmt.Errorf("Invalid device type %s", t)
 	}
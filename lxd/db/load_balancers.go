@@ -0,0 +1,149 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+// LoadBalancers returns the names of all load-balancers.
+func (c *Cluster) LoadBalancers() ([]string, error) {
+	var name string
+	q := "SELECT name FROM load_balancers ORDER BY name"
+	result, err := queryScan(c.db, q, nil, []interface{}{name})
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, r := range result {
+		names = append(names, r[0].(string))
+	}
+
+	return names, nil
+}
+
+// LoadBalancerGet returns the load-balancer with the given name.
+func (c *Cluster) LoadBalancerGet(name string) (int64, *api.LoadBalancer, error) {
+	description := sql.NullString{}
+	id := int64(-1)
+
+	q := "SELECT id, description FROM load_balancers WHERE name=?"
+	arg1 := []interface{}{name}
+	arg2 := []interface{}{&id, &description}
+	err := dbQueryRowScan(c.db, q, arg1, arg2)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return -1, nil, ErrNoSuchObject
+		}
+
+		return -1, nil, err
+	}
+
+	config, err := c.loadBalancerConfigGet(id)
+	if err != nil {
+		return -1, nil, err
+	}
+
+	lb := api.LoadBalancer{
+		Name:    name,
+		Members: []api.LoadBalancerMember{},
+	}
+	lb.Description = description.String
+	lb.Config = config
+
+	return id, &lb, nil
+}
+
+func (c *Cluster) loadBalancerConfigGet(id int64) (map[string]string, error) {
+	var key, value string
+	q := "SELECT key, value FROM load_balancers_config WHERE load_balancer_id=?"
+	results, err := queryScan(c.db, q, []interface{}{id}, []interface{}{key, value})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get load-balancer '%d'", id)
+	}
+
+	config := map[string]string{}
+	for _, r := range results {
+		config[r[0].(string)] = r[1].(string)
+	}
+
+	return config, nil
+}
+
+// LoadBalancerCreate creates a new load-balancer.
+func (c *Cluster) LoadBalancerCreate(name, description string, config map[string]string) (int64, error) {
+	var id int64
+	err := c.Transaction(func(tx *ClusterTx) error {
+		result, err := tx.tx.Exec("INSERT INTO load_balancers (name, description) VALUES (?, ?)", name, description)
+		if err != nil {
+			return err
+		}
+
+		id, err = result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		return loadBalancerConfigAdd(tx.tx, id, config)
+	})
+	if err != nil {
+		id = -1
+	}
+
+	return id, err
+}
+
+// LoadBalancerUpdate updates the load-balancer with the given name.
+func (c *Cluster) LoadBalancerUpdate(name, description string, config map[string]string) error {
+	id, _, err := c.LoadBalancerGet(name)
+	if err != nil {
+		return err
+	}
+
+	return c.Transaction(func(tx *ClusterTx) error {
+		_, err := tx.tx.Exec("UPDATE load_balancers SET description=? WHERE id=?", description, id)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.tx.Exec("DELETE FROM load_balancers_config WHERE load_balancer_id=?", id)
+		if err != nil {
+			return err
+		}
+
+		return loadBalancerConfigAdd(tx.tx, id, config)
+	})
+}
+
+func loadBalancerConfigAdd(tx *sql.Tx, id int64, config map[string]string) error {
+	stmt, err := tx.Prepare("INSERT INTO load_balancers_config (load_balancer_id, key, value) VALUES(?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for k, v := range config {
+		if v == "" {
+			continue
+		}
+
+		_, err = stmt.Exec(id, k, v)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// LoadBalancerDelete deletes the load-balancer with the given name.
+func (c *Cluster) LoadBalancerDelete(name string) error {
+	id, _, err := c.LoadBalancerGet(name)
+	if err != nil {
+		return err
+	}
+
+	return exec(c.db, "DELETE FROM load_balancers WHERE id=?", id)
+}
@@ -0,0 +1,40 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadBalancers(t *testing.T) {
+	cluster, cleanup := db.NewTestCluster(t)
+	defer cleanup()
+
+	_, err := cluster.LoadBalancerCreate("web", "Web servers", map[string]string{
+		"listen":      "0.0.0.0:80",
+		"target.port": "8080",
+	})
+	require.NoError(t, err)
+
+	names, err := cluster.LoadBalancers()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"web"}, names)
+
+	err = cluster.LoadBalancerUpdate("web", "", map[string]string{
+		"listen":      "0.0.0.0:443",
+		"target.port": "8443",
+	})
+	require.NoError(t, err)
+
+	_, lb, err := cluster.LoadBalancerGet("web")
+	require.NoError(t, err)
+	assert.Equal(t, "", lb.Description)
+	assert.Equal(t, map[string]string{"listen": "0.0.0.0:443", "target.port": "8443"}, lb.Config)
+
+	require.NoError(t, cluster.LoadBalancerDelete("web"))
+
+	_, _, err = cluster.LoadBalancerGet("web")
+	assert.Equal(t, db.ErrNoSuchObject, err)
+}
@@ -223,6 +223,22 @@ func (c *ClusterTx) NetworkCreatePending(node, name string, conf map[string]stri
 	return nil
 }
 
+// NetworkTypeSet sets the type of the given network.
+func (c *ClusterTx) NetworkTypeSet(name string, networkType int) error {
+	result, err := c.tx.Exec("UPDATE networks SET type=? WHERE name=?", networkType, name)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n != 1 {
+		return ErrNoSuchObject
+	}
+	return nil
+}
+
 // NetworkCreated sets the state of the given network to "Created".
 func (c *ClusterTx) NetworkCreated(name string) error {
 	return c.networkState(name, networkCreated)
@@ -294,15 +310,28 @@ const (
 	networkErrored            // Network creation failed on some nodes
 )
 
+// Network types.
+const (
+	NetworkTypeBridge   int = iota // Bridge created and managed by LXD.
+	NetworkTypeExternal            // Existing VLAN or subnet, only used for IPAM.
+)
+
+// NetworkTypeNames associates a network type code to its name.
+var NetworkTypeNames = map[int]string{
+	NetworkTypeBridge:   "bridge",
+	NetworkTypeExternal: "external",
+}
+
 // NetworkGet returns the network with the given name.
 func (c *Cluster) NetworkGet(name string) (int64, *api.Network, error) {
 	description := sql.NullString{}
 	id := int64(-1)
 	state := 0
+	networkType := 0
 
-	q := "SELECT id, description, state FROM networks WHERE name=?"
+	q := "SELECT id, description, state, type FROM networks WHERE name=?"
 	arg1 := []interface{}{name}
-	arg2 := []interface{}{&id, &description, &state}
+	arg2 := []interface{}{&id, &description, &state, &networkType}
 	err := dbQueryRowScan(c.db, q, arg1, arg2)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -320,7 +349,7 @@ func (c *Cluster) NetworkGet(name string) (int64, *api.Network, error) {
 	network := api.Network{
 		Name:    name,
 		Managed: true,
-		Type:    "bridge",
+		Type:    NetworkTypeNames[networkType],
 	}
 	network.Description = description.String
 	network.Config = config
@@ -596,4 +625,5 @@ func (c *Cluster) NetworkRename(oldName string, newName string) error {
 // NetworkNodeConfigKeys lists all network config keys which are node-specific.
 var NetworkNodeConfigKeys = []string{
 	"bridge.external_interfaces",
+	"parent",
 }
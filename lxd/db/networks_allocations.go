@@ -0,0 +1,81 @@
+package db
+
+import (
+	"github.com/lxc/lxd/lxd/db/query"
+	"github.com/pkg/errors"
+)
+
+// NetworkAllocation is an address of an external network assigned to the
+// NIC of an instance.
+type NetworkAllocation struct {
+	Address  string
+	Project  string
+	Instance string
+	Device   string
+	Node     string
+}
+
+// NetworkAllocations returns the addresses allocated on the network with the
+// given ID.
+func (c *ClusterTx) NetworkAllocations(networkID int64) ([]NetworkAllocation, error) {
+	allocations := []NetworkAllocation{}
+	dest := func(i int) []interface{} {
+		allocations = append(allocations, NetworkAllocation{})
+		a := &allocations[len(allocations)-1]
+		return []interface{}{&a.Address, &a.Project, &a.Instance, &a.Device, &a.Node}
+	}
+
+	stmt, err := c.tx.Prepare(`
+SELECT networks_allocations.address, projects.name, instances.name, networks_allocations.device, nodes.name
+  FROM networks_allocations
+  JOIN instances ON instances.id = networks_allocations.instance_id
+  JOIN projects ON projects.id = instances.project_id
+  JOIN nodes ON nodes.id = instances.node_id
+  WHERE networks_allocations.network_id = ?
+  ORDER BY networks_allocations.id
+`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	err = query.SelectObjects(stmt, dest, networkID)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to fetch network allocations")
+	}
+
+	return allocations, nil
+}
+
+// NetworkAllocationsForDevice returns the addresses allocated on the network
+// with the given ID to a NIC of an instance.
+func (c *ClusterTx) NetworkAllocationsForDevice(networkID int64, instanceID int64, device string) ([]string, error) {
+	stmt := "SELECT address FROM networks_allocations WHERE network_id=? AND instance_id=? AND device=? ORDER BY id"
+	return query.SelectStrings(c.tx, stmt, networkID, instanceID, device)
+}
+
+// NetworkAllocationAdd assigns an address of the network with the given ID to
+// a NIC of an instance. ErrAlreadyDefined is returned if the address is
+// already in use.
+func (c *ClusterTx) NetworkAllocationAdd(networkID int64, instanceID int64, device string, address string) error {
+	count, err := query.Count(c.tx, "networks_allocations", "network_id=? AND address=?", networkID, address)
+	if err != nil {
+		return err
+	}
+
+	if count != 0 {
+		return ErrAlreadyDefined
+	}
+
+	columns := []string{"network_id", "instance_id", "device", "address"}
+	values := []interface{}{networkID, instanceID, device, address}
+	_, err = query.UpsertObject(c.tx, "networks_allocations", columns, values)
+	return err
+}
+
+// NetworkAllocationsDelete releases all the addresses allocated to a NIC of an
+// instance.
+func (c *ClusterTx) NetworkAllocationsDelete(instanceID int64, device string) error {
+	_, err := c.tx.Exec("DELETE FROM networks_allocations WHERE instance_id=? AND device=?", instanceID, device)
+	return err
+}
@@ -0,0 +1,56 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNetworkAllocations(t *testing.T) {
+	tx, cleanup := db.NewTestClusterTx(t)
+	defer cleanup()
+
+	err := tx.NetworkCreatePending("none", "dc0", map[string]string{"parent": "eth0"})
+	require.NoError(t, err)
+	require.NoError(t, tx.NetworkTypeSet("dc0", db.NetworkTypeExternal))
+
+	networkID, err := tx.NetworkID("dc0")
+	require.NoError(t, err)
+
+	addContainer(t, tx, 1, "c1")
+	addContainer(t, tx, 1, "c2")
+
+	c1, err := tx.InstanceID("default", "c1")
+	require.NoError(t, err)
+	c2, err := tx.InstanceID("default", "c2")
+	require.NoError(t, err)
+
+	require.NoError(t, tx.NetworkAllocationAdd(networkID, c1, "eth0", "192.0.2.10"))
+	require.NoError(t, tx.NetworkAllocationAdd(networkID, c1, "eth0", "2001:db8::10"))
+
+	// The same address can't be given twice
+	err = tx.NetworkAllocationAdd(networkID, c2, "eth0", "192.0.2.10")
+	assert.Equal(t, db.ErrAlreadyDefined, err)
+
+	require.NoError(t, tx.NetworkAllocationAdd(networkID, c2, "eth0", "192.0.2.11"))
+
+	addresses, err := tx.NetworkAllocationsForDevice(networkID, c1, "eth0")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"192.0.2.10", "2001:db8::10"}, addresses)
+
+	allocations, err := tx.NetworkAllocations(networkID)
+	require.NoError(t, err)
+	require.Len(t, allocations, 3)
+	assert.Equal(t, db.NetworkAllocation{Address: "192.0.2.11", Project: "default", Instance: "c2", Device: "eth0", Node: "none"}, allocations[2])
+
+	require.NoError(t, tx.NetworkAllocationsDelete(c1, "eth0"))
+
+	addresses, err = tx.NetworkAllocationsForDevice(networkID, c1, "eth0")
+	require.NoError(t, err)
+	assert.Len(t, addresses, 0)
+
+	// The released address can be given again
+	require.NoError(t, tx.NetworkAllocationAdd(networkID, c2, "eth1", "192.0.2.10"))
+}
@@ -62,6 +62,22 @@ const (
 	OperationInstanceTypesUpdate
 	OperationBackupsExpire
 	OperationSnapshotsExpire
+	OperationContainersEmergencyShutdown
+	OperationDatabaseBackupCreate
+	OperationProfileContainersRestart
+	OperationMAASReconcile
+	OperationContainersImageFollow
+	OperationContainerApply
+	OperationContainerFilesSync
+	OperationContainerSecurityTest
+	OperationContainerRespawn
+	OperationImagesImport
+	OperationContainerNetworkReapply
+	OperationContainerRebuild
+	OperationContainerIdmapRebuild
+	OperationCheckpointCreate
+	OperationCheckpointRestore
+	OperationCheckpointDelete
 )
 
 // Description return a human-readable description of the operation type.
@@ -157,6 +173,38 @@ func (t OperationType) Description() string {
 		return "Cleaning up expired backups"
 	case OperationSnapshotsExpire:
 		return "Cleaning up expired snapshots"
+	case OperationContainersEmergencyShutdown:
+		return "Emergency shutdown of containers"
+	case OperationDatabaseBackupCreate:
+		return "Creating database backup"
+	case OperationProfileContainersRestart:
+		return "Restarting profile containers"
+	case OperationMAASReconcile:
+		return "Reconciling MAAS records"
+	case OperationContainersImageFollow:
+		return "Updating containers from followed images"
+	case OperationContainerApply:
+		return "Applying container state"
+	case OperationContainerFilesSync:
+		return "Syncing container files"
+	case OperationContainerSecurityTest:
+		return "Testing container security policy"
+	case OperationContainerRespawn:
+		return "Respawning container"
+	case OperationImagesImport:
+		return "Importing images"
+	case OperationContainerNetworkReapply:
+		return "Re-applying container network"
+	case OperationContainerRebuild:
+		return "Rebuilding container"
+	case OperationContainerIdmapRebuild:
+		return "Remapping container filesystem"
+	case OperationCheckpointCreate:
+		return "Creating checkpoint"
+	case OperationCheckpointRestore:
+		return "Restoring checkpoint"
+	case OperationCheckpointDelete:
+		return "Deleting checkpoint"
 	default:
 		return "Executing operation"
 	}
@@ -167,6 +215,8 @@ func (t OperationType) Permission() string {
 	switch t {
 	case OperationBackupCreate:
 		return "operate-containers"
+	case OperationProfileContainersRestart:
+		return "operate-containers"
 	case OperationBackupRename:
 		return "operate-containers"
 	case OperationBackupRestore:
@@ -197,6 +247,10 @@ func (t OperationType) Permission() string {
 		return "operate-containers"
 	case OperationSnapshotDelete:
 		return "operate-containers"
+	case OperationCheckpointCreate:
+		return "operate-containers"
+	case OperationCheckpointDelete:
+		return "operate-containers"
 
 	case OperationContainerCreate:
 		return "manage-containers"
@@ -210,8 +264,18 @@ func (t OperationType) Permission() string {
 		return "manage-containers"
 	case OperationContainerDelete:
 		return "manage-containers"
+	case OperationContainerRespawn:
+		return "manage-containers"
+	case OperationContainerNetworkReapply:
+		return "manage-containers"
+	case OperationContainerRebuild:
+		return "manage-containers"
+	case OperationContainerIdmapRebuild:
+		return "manage-containers"
 	case OperationSnapshotRestore:
 		return "manage-containers"
+	case OperationCheckpointRestore:
+		return "manage-containers"
 
 	case OperationImageDownload:
 		return "manage-images"
@@ -225,6 +289,8 @@ func (t OperationType) Permission() string {
 		return "manage-images"
 	case OperationImagesSynchronize:
 		return "manage-images"
+	case OperationImagesImport:
+		return "manage-images"
 	}
 
 	return ""
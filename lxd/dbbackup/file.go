@@ -0,0 +1,68 @@
+package dbbackup
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// fileTarget stores backups in a local directory, typically a network mount.
+type fileTarget struct {
+	path string
+}
+
+func (t *fileTarget) Put(name string, r io.Reader) error {
+	err := os.MkdirAll(t.path, 0700)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so a partial backup never shows up
+	f, err := ioutil.TempFile(t.path, ".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	_, err = io.Copy(f, r)
+	if err != nil {
+		return err
+	}
+
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), filepath.Join(t.path, name))
+}
+
+func (t *fileTarget) Get(name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(t.path, name))
+}
+
+func (t *fileTarget) List() ([]string, error) {
+	entries, err := ioutil.ReadDir(t.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+
+		return nil, err
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		if entry.Mode().IsRegular() {
+			names = append(names, entry.Name())
+		}
+	}
+
+	return filterNames(names), nil
+}
+
+func (t *fileTarget) Delete(name string) error {
+	return os.Remove(filepath.Join(t.path, name))
+}
@@ -0,0 +1,210 @@
+package dbbackup
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// s3Target stores backups in an S3 compatible object store, using path-style
+// requests signed with AWS signature version 4.
+type s3Target struct {
+	host      string
+	bucket    string
+	prefix    string
+	region    string
+	accessKey string
+	secretKey string
+}
+
+func newS3Target(u *url.URL, secretKey string) *s3Target {
+	fields := strings.SplitN(strings.Trim(u.Path, "/"), "/", 2)
+
+	t := &s3Target{
+		host:      u.Host,
+		bucket:    fields[0],
+		region:    u.Query().Get("region"),
+		accessKey: u.User.Username(),
+		secretKey: secretKey,
+	}
+
+	if len(fields) > 1 {
+		t.prefix = fields[1] + "/"
+	}
+
+	if t.region == "" {
+		t.region = "us-east-1"
+	}
+
+	return t
+}
+
+func s3Hash(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+func s3HMAC(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// Encode a query string the way signature version 4 expects it.
+func s3EncodeQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := []string{}
+	for _, key := range keys {
+		for _, value := range query[key] {
+			parts = append(parts, fmt.Sprintf("%s=%s", s3Escape(key), s3Escape(value)))
+		}
+	}
+
+	return strings.Join(parts, "&")
+}
+
+func s3Escape(value string) string {
+	return strings.Replace(url.QueryEscape(value), "+", "%20", -1)
+}
+
+func (t *s3Target) request(method string, key string, query url.Values, body []byte) (*http.Response, error) {
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := s3Hash(body)
+
+	uri := path.Join("/", t.bucket, key)
+	rawQuery := s3EncodeQuery(query)
+
+	// Sign the request
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		method,
+		uri,
+		rawQuery,
+		fmt.Sprintf("host:%s\nx-amz-content-sha256:%s\nx-amz-date:%s\n", t.host, payloadHash, amzDate),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", date, t.region)
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		s3Hash([]byte(canonicalRequest)),
+	}, "\n")
+
+	signingKey := s3HMAC(s3HMAC(s3HMAC(s3HMAC([]byte("AWS4"+t.secretKey), date), t.region), "s3"), "aws4_request")
+	signature := hex.EncodeToString(s3HMAC(signingKey, stringToSign))
+
+	u := url.URL{Scheme: "https", Host: t.host, Path: uri, RawQuery: rawQuery}
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", t.accessKey, scope, signedHeaders, signature))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("S3 %s request on %s failed: %s", method, u.String(), resp.Status)
+	}
+
+	return resp, nil
+}
+
+func (t *s3Target) Put(name string, r io.Reader) error {
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	resp, err := t.request("PUT", t.prefix+name, nil, body)
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+func (t *s3Target) Get(name string) (io.ReadCloser, error) {
+	resp, err := t.request("GET", t.prefix+name, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
+
+func (t *s3Target) List() ([]string, error) {
+	query := url.Values{}
+	query.Set("list-type", "2")
+	query.Set("prefix", t.prefix+namePrefix)
+
+	names := []string{}
+	for {
+		resp, err := t.request("GET", "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		result := struct {
+			Contents []struct {
+				Key string `xml:"Key"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}{}
+
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, object := range result.Contents {
+			names = append(names, strings.TrimPrefix(object.Key, t.prefix))
+		}
+
+		if !result.IsTruncated {
+			break
+		}
+
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+
+	return filterNames(names), nil
+}
+
+func (t *s3Target) Delete(name string) error {
+	resp, err := t.request("DELETE", t.prefix+name, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
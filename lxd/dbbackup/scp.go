@@ -0,0 +1,94 @@
+package dbbackup
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os/exec"
+	"path"
+	"strings"
+)
+
+// scpTarget stores backups on a remote host over SSH, using the SSH keys of
+// the user LXD runs as.
+type scpTarget struct {
+	url *url.URL
+}
+
+// Quote a string for the remote shell.
+func shellQuote(value string) string {
+	return fmt.Sprintf("'%s'", strings.Replace(value, "'", `'\''`, -1))
+}
+
+func (t *scpTarget) command(remoteCommand string) *exec.Cmd {
+	args := []string{"-o", "BatchMode=yes"}
+	if t.url.Port() != "" {
+		args = append(args, "-p", t.url.Port())
+	}
+
+	host := t.url.Hostname()
+	if t.url.User != nil {
+		host = fmt.Sprintf("%s@%s", t.url.User.Username(), host)
+	}
+
+	args = append(args, "--", host, remoteCommand)
+	return exec.Command("ssh", args...)
+}
+
+func (t *scpTarget) run(remoteCommand string, stdin io.Reader) ([]byte, error) {
+	cmd := t.command(remoteCommand)
+	cmd.Stdin = stdin
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to run %q on %s: %v (%s)", remoteCommand, t.url.Host, err, strings.TrimSpace(stderr.String()))
+	}
+
+	return out, nil
+}
+
+func (t *scpTarget) dir() string {
+	if t.url.Path == "" {
+		return "."
+	}
+
+	return t.url.Path
+}
+
+func (t *scpTarget) Put(name string, r io.Reader) error {
+	dir := shellQuote(t.dir())
+	tmp := shellQuote(path.Join(t.dir(), "."+name))
+	dst := shellQuote(path.Join(t.dir(), name))
+
+	_, err := t.run(fmt.Sprintf("mkdir -p %s && cat > %s && mv %s %s", dir, tmp, tmp, dst), r)
+	return err
+}
+
+func (t *scpTarget) Get(name string) (io.ReadCloser, error) {
+	out, err := t.run(fmt.Sprintf("cat %s", shellQuote(path.Join(t.dir(), name))), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.NopCloser(bytes.NewReader(out)), nil
+}
+
+func (t *scpTarget) List() ([]string, error) {
+	dir := shellQuote(t.dir())
+	out, err := t.run(fmt.Sprintf("mkdir -p %s && ls -1 %s", dir, dir), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return filterNames(strings.Split(strings.TrimSpace(string(out)), "\n")), nil
+}
+
+func (t *scpTarget) Delete(name string) error {
+	_, err := t.run(fmt.Sprintf("rm -f %s", shellQuote(path.Join(t.dir(), name))), nil)
+	return err
+}
@@ -0,0 +1,129 @@
+package dbbackup
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Target represents an external location database backups are stored in.
+type Target interface {
+	Put(name string, r io.Reader) error
+	Get(name string) (io.ReadCloser, error)
+	List() ([]string, error)
+	Delete(name string) error
+}
+
+// Prefix and suffix of the backup names.
+const (
+	namePrefix = "lxd-database-"
+	nameSuffix = ".tar.gz"
+)
+
+// NewName returns the name to use for a backup taken at the given time.
+// Names sort chronologically.
+func NewName(t time.Time) string {
+	return fmt.Sprintf("%s%s%s", namePrefix, t.UTC().Format("20060102T150405Z"), nameSuffix)
+}
+
+// IsName returns whether the given file name is the one of a database backup.
+func IsName(name string) bool {
+	return strings.HasPrefix(name, namePrefix) && strings.HasSuffix(name, nameSuffix) && !strings.Contains(name, "/")
+}
+
+// ValidateTarget checks that the given target URL is supported.
+func ValidateTarget(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	_, err := NewTarget(value, "")
+	return err
+}
+
+// NewTarget returns the Target matching the given URL. The password is used
+// for webdav authentication and as the S3 secret key.
+//
+// Supported URLs are:
+//   - file:///path
+//   - scp://[user@]host[:port]/path
+//   - webdav://[user@]host[:port]/path and webdavs://[user@]host[:port]/path
+//   - s3://access-key@host[:port]/bucket[/prefix][?region=REGION]
+func NewTarget(target string, password string) (Target, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "file":
+		if u.Host != "" || u.Path == "" {
+			return nil, fmt.Errorf("Invalid file target, expected file:///path")
+		}
+
+		return &fileTarget{path: u.Path}, nil
+	case "scp":
+		if u.Host == "" {
+			return nil, fmt.Errorf("Invalid scp target, expected scp://[user@]host[:port]/path")
+		}
+
+		// Keep the host from being taken for an ssh option
+		if strings.HasPrefix(u.Hostname(), "-") || (u.User != nil && strings.HasPrefix(u.User.Username(), "-")) {
+			return nil, fmt.Errorf("Invalid scp target host: %s", u.Host)
+		}
+
+		return &scpTarget{url: u}, nil
+	case "webdav", "webdavs":
+		if u.Host == "" {
+			return nil, fmt.Errorf("Invalid webdav target, expected %s://[user@]host[:port]/path", u.Scheme)
+		}
+
+		return &webdavTarget{url: u, password: password}, nil
+	case "s3":
+		if u.Host == "" || u.User == nil || strings.Trim(u.Path, "/") == "" {
+			return nil, fmt.Errorf("Invalid s3 target, expected s3://access-key@host[:port]/bucket[/prefix]")
+		}
+
+		return newS3Target(u, password), nil
+	}
+
+	return nil, fmt.Errorf("Unsupported backup target type: %s", u.Scheme)
+}
+
+// Prune removes all but the most recent retention backups from the target.
+func Prune(target Target, retention int) error {
+	names, err := target.List()
+	if err != nil {
+		return err
+	}
+
+	if retention < 1 || len(names) <= retention {
+		return nil
+	}
+
+	sort.Strings(names)
+	for _, name := range names[:len(names)-retention] {
+		err := target.Delete(name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Only keep the backup names out of a directory listing.
+func filterNames(names []string) []string {
+	backups := []string{}
+	for _, name := range names {
+		if IsName(name) {
+			backups = append(backups, name)
+		}
+	}
+
+	sort.Strings(backups)
+	return backups
+}
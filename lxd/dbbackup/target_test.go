@@ -0,0 +1,83 @@
+package dbbackup
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTarget(t *testing.T) {
+	valid := []string{
+		"file:///srv/backups",
+		"scp://backup@host.example.net:2222/srv/lxd",
+		"webdavs://lxd@dav.example.net/backups",
+		"s3://AKIAEXAMPLE@s3.example.net/bucket/lxd?region=eu-west-1",
+	}
+
+	for _, target := range valid {
+		_, err := NewTarget(target, "secret")
+		assert.NoError(t, err, target)
+	}
+
+	invalid := []string{
+		"file://host/path",
+		"ftp://host/path",
+		"scp://-oProxyCommand=id/srv/lxd",
+		"scp://-oProxyCommand=id@host/srv/lxd",
+		"s3://s3.example.net/bucket",
+		"s3://AKIAEXAMPLE@s3.example.net/",
+	}
+
+	for _, target := range invalid {
+		_, err := NewTarget(target, "")
+		assert.Error(t, err, target)
+	}
+}
+
+func TestFileTarget(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd-dbbackup-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	target, err := NewTarget("file://"+dir, "")
+	require.NoError(t, err)
+
+	now := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		name := NewName(now.Add(time.Duration(i) * time.Hour))
+		require.NoError(t, target.Put(name, bytes.NewBufferString(name)))
+	}
+
+	// Unrelated files are ignored.
+	require.NoError(t, ioutil.WriteFile(dir+"/other", []byte{}, 0600))
+
+	names, err := target.List()
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"lxd-database-20191001T120000Z.tar.gz",
+		"lxd-database-20191001T130000Z.tar.gz",
+		"lxd-database-20191001T140000Z.tar.gz",
+	}, names)
+
+	require.NoError(t, Prune(target, 2))
+
+	names, err = target.List()
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"lxd-database-20191001T130000Z.tar.gz",
+		"lxd-database-20191001T140000Z.tar.gz",
+	}, names)
+
+	r, err := target.Get(names[0])
+	require.NoError(t, err)
+	defer r.Close()
+
+	content, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, names[0], string(content))
+}
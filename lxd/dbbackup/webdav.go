@@ -0,0 +1,113 @@
+package dbbackup
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// webdavTarget stores backups on a WebDAV server.
+type webdavTarget struct {
+	url      *url.URL
+	password string
+}
+
+func (t *webdavTarget) request(method string, name string, body io.Reader) (*http.Response, error) {
+	u := *t.url
+	u.Scheme = "http"
+	if t.url.Scheme == "webdavs" {
+		u.Scheme = "https"
+	}
+	u.User = nil
+	u.Path = path.Join("/", t.url.Path, name)
+	if name == "" {
+		u.Path += "/"
+	}
+
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.url.User != nil {
+		req.SetBasicAuth(t.url.User.Username(), t.password)
+	}
+
+	if method == "PROPFIND" {
+		req.Header.Set("Depth", "1")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("WebDAV %s request on %s failed: %s", method, u.String(), resp.Status)
+	}
+
+	return resp, nil
+}
+
+func (t *webdavTarget) Put(name string, r io.Reader) error {
+	resp, err := t.request("PUT", name, r)
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
+
+func (t *webdavTarget) Get(name string) (io.ReadCloser, error) {
+	resp, err := t.request("GET", name, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
+
+func (t *webdavTarget) List() ([]string, error) {
+	resp, err := t.request("PROPFIND", "", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	multistatus := struct {
+		Responses []struct {
+			Href string `xml:"href"`
+		} `xml:"response"`
+	}{}
+
+	err = xml.NewDecoder(resp.Body).Decode(&multistatus)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, response := range multistatus.Responses {
+		href, err := url.PathUnescape(response.Href)
+		if err != nil {
+			continue
+		}
+
+		names = append(names, path.Base(strings.TrimSuffix(href, "/")))
+	}
+
+	return filterNames(names), nil
+}
+
+func (t *webdavTarget) Delete(name string) error {
+	resp, err := t.request("DELETE", name, nil)
+	if err != nil {
+		return err
+	}
+
+	return resp.Body.Close()
+}
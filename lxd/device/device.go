@@ -13,6 +13,9 @@ var devTypes = map[string]func(config.Device) device{
 	"infiniband": infinibandLoadByType,
 	"proxy":      func(c config.Device) device { return &proxy{} },
 	"gpu":        func(c config.Device) device { return &gpu{} },
+	"watchdog":   func(c config.Device) device { return &watchdog{} },
+	"entropy":    func(c config.Device) device { return &entropy{} },
+	"tpm":        func(c config.Device) device { return &tpm{} },
 }
 
 // VolatileSetter is a function that accepts one or more key/value strings to save into the LXD
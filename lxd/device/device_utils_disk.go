@@ -2,6 +2,7 @@ package device
 
 import (
 	"fmt"
+	"runtime"
 	"strings"
 
 	"golang.org/x/sys/unix"
@@ -37,6 +38,38 @@ func IsBlockdev(path string) bool {
 	return false
 }
 
+// DiskRemountThreads remounts the btrfs filesystem of a block device with the given number of
+// worker threads, the kernel default being used when zero.
+func DiskRemountThreads(srcPath string, path string, readonly bool, threads uint64) error {
+	fstype, err := BlockFsDetect(srcPath)
+	if err != nil {
+		return err
+	}
+
+	if fstype != "btrfs" {
+		return fmt.Errorf("The number of I/O threads can only be set for btrfs filesystems, not %s", fstype)
+	}
+
+	if threads == 0 {
+		threads = uint64(runtime.NumCPU()) + 2
+		if threads > 8 {
+			threads = 8
+		}
+	}
+
+	flags := unix.MS_REMOUNT
+	if readonly {
+		flags |= unix.MS_RDONLY
+	}
+
+	err = unix.Mount("", path, "", uintptr(flags), fmt.Sprintf("thread_pool=%d", threads))
+	if err != nil {
+		return fmt.Errorf("Unable to remount %s: %s", path, err)
+	}
+
+	return nil
+}
+
 // DiskMount mounts a disk device.
 func DiskMount(srcPath string, dstPath string, readonly bool, recursive bool, propagation string) error {
 	var err error
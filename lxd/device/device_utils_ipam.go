@@ -0,0 +1,246 @@
+package device
+
+import (
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/lxd/state"
+)
+
+// Maximum number of addresses looked at when searching a free one in a subnet without ranges.
+const networkExternalMaxScan = 65536
+
+// networkExternalLoad returns the ID and config of the external network with the given name.
+func networkExternalLoad(s *state.State, name string) (int64, map[string]string, error) {
+	networkID, network, err := s.Cluster.NetworkGet(name)
+	if err != nil {
+		return -1, nil, fmt.Errorf("Failed to load network '%s': %v", name, err)
+	}
+
+	if network.Type != db.NetworkTypeNames[db.NetworkTypeExternal] {
+		return -1, nil, fmt.Errorf("Network '%s' isn't an external network", name)
+	}
+
+	return networkID, network.Config, nil
+}
+
+// networkExternalAllocate returns the addresses of the external network assigned to a NIC,
+// allocating them if needed, in CIDR notation and indexed by ipv4 or ipv6. The addresses requested
+// through ipv4.address and ipv6.address are used when set, otherwise the previously assigned ones
+// are kept or the first free ones in the network ranges are picked. The cluster database ensures
+// that no address is assigned twice, whatever the node.
+func networkExternalAllocate(s *state.State, instance InstanceIdentifier, deviceName string, m config.Device) (map[string]string, error) {
+	networkID, netConfig, err := networkExternalLoad(s, m["network"])
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := map[string]string{}
+	err = s.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		instanceID, err := tx.InstanceID(instance.Project(), instance.Name())
+		if err != nil {
+			return err
+		}
+
+		previous, err := tx.NetworkAllocationsForDevice(networkID, instanceID, deviceName)
+		if err != nil {
+			return err
+		}
+
+		err = tx.NetworkAllocationsDelete(instanceID, deviceName)
+		if err != nil {
+			return err
+		}
+
+		allocations, err := tx.NetworkAllocations(networkID)
+		if err != nil {
+			return err
+		}
+
+		used := map[string]bool{}
+		for _, allocation := range allocations {
+			used[allocation.Address] = true
+		}
+
+		for _, family := range []string{"ipv4", "ipv6"} {
+			gateway, subnet, err := networkExternalSubnet(netConfig, family)
+			if err != nil {
+				return err
+			}
+
+			if subnet == nil {
+				if m[fmt.Sprintf("%s.address", family)] != "" {
+					return fmt.Errorf("Network '%s' has no %s subnet", m["network"], family)
+				}
+
+				continue
+			}
+
+			var ip net.IP
+			if m[fmt.Sprintf("%s.address", family)] != "" {
+				ip = net.ParseIP(m[fmt.Sprintf("%s.address", family)])
+				if !subnet.Contains(ip) || ip.Equal(gateway) {
+					return fmt.Errorf("Address %s isn't usable on network '%s'", ip, m["network"])
+				}
+
+				if used[ip.String()] {
+					return fmt.Errorf("Address %s is already in use on network '%s'", ip, m["network"])
+				}
+			} else {
+				for _, address := range previous {
+					candidate := net.ParseIP(address)
+					if candidate != nil && subnet.Contains(candidate) && !used[candidate.String()] {
+						ip = candidate
+						break
+					}
+				}
+
+				if ip == nil {
+					ip, err = networkExternalFreeAddress(netConfig, family, subnet, gateway, used)
+					if err != nil {
+						return fmt.Errorf("Failed to allocate an address on network '%s': %v", m["network"], err)
+					}
+				}
+			}
+
+			err = tx.NetworkAllocationAdd(networkID, instanceID, deviceName, ip.String())
+			if err != nil {
+				return err
+			}
+
+			used[ip.String()] = true
+			prefix, _ := subnet.Mask.Size()
+			addresses[family] = fmt.Sprintf("%s/%d", ip, prefix)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return addresses, nil
+}
+
+// networkExternalRelease releases the addresses assigned to a NIC.
+func networkExternalRelease(s *state.State, instance InstanceIdentifier, deviceName string) error {
+	return s.Cluster.Transaction(func(tx *db.ClusterTx) error {
+		instanceID, err := tx.InstanceID(instance.Project(), instance.Name())
+		if err != nil {
+			// The addresses went away along with the instance.
+			if err == db.ErrNoSuchObject {
+				return nil
+			}
+
+			return err
+		}
+
+		return tx.NetworkAllocationsDelete(instanceID, deviceName)
+	})
+}
+
+// networkExternalSubnet returns the gateway and subnet of an address family of an external network,
+// both nil when the family isn't configured.
+func networkExternalSubnet(netConfig map[string]string, family string) (net.IP, *net.IPNet, error) {
+	value := netConfig[fmt.Sprintf("%s.address", family)]
+	if value == "" || value == "none" {
+		return nil, nil, nil
+	}
+
+	gateway, subnet, err := net.ParseCIDR(value)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return gateway, subnet, nil
+}
+
+// networkExternalFreeAddress returns the first address of the network ranges, or of the whole
+// subnet if no range is set, which is neither used nor the gateway.
+func networkExternalFreeAddress(netConfig map[string]string, family string, subnet *net.IPNet, gateway net.IP, used map[string]bool) (net.IP, error) {
+	ranges, err := networkExternalRanges(netConfig[fmt.Sprintf("%s.ranges", family)])
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ranges) == 0 {
+		// Skip the network address, and the broadcast one for IPv4.
+		first := networkIPAdd(subnet.IP, 1)
+		last := make(net.IP, len(subnet.IP))
+		for i := range subnet.IP {
+			last[i] = subnet.IP[i] | ^subnet.Mask[i]
+		}
+
+		if family == "ipv4" {
+			last = networkIPAdd(last, -1)
+		}
+
+		ranges = [][2]net.IP{{first, last}}
+	}
+
+	scanned := 0
+	for _, r := range ranges {
+		for ip := r[0]; networkIPCompare(ip, r[1]) <= 0; ip = networkIPAdd(ip, 1) {
+			scanned++
+			if scanned > networkExternalMaxScan {
+				return nil, fmt.Errorf("No free address found in the first %d", networkExternalMaxScan)
+			}
+
+			if !subnet.Contains(ip) || ip.Equal(gateway) || used[ip.String()] {
+				continue
+			}
+
+			return ip, nil
+		}
+	}
+
+	return nil, fmt.Errorf("No free address left")
+}
+
+// networkExternalRanges parses a comma separated list of address ranges (first-last).
+func networkExternalRanges(value string) ([][2]net.IP, error) {
+	ranges := [][2]net.IP{}
+	if value == "" {
+		return ranges, nil
+	}
+
+	for _, entry := range strings.Split(value, ",") {
+		fields := strings.SplitN(strings.TrimSpace(entry), "-", 2)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("Invalid address range '%s'", entry)
+		}
+
+		first := net.ParseIP(strings.TrimSpace(fields[0]))
+		last := net.ParseIP(strings.TrimSpace(fields[1]))
+		if first == nil || last == nil || networkIPCompare(first, last) > 0 {
+			return nil, fmt.Errorf("Invalid address range '%s'", entry)
+		}
+
+		ranges = append(ranges, [2]net.IP{first, last})
+	}
+
+	return ranges, nil
+}
+
+// networkIPAdd returns the address at the given offset from another one.
+func networkIPAdd(ip net.IP, offset int64) net.IP {
+	n := big.NewInt(0).SetBytes(ip.To16())
+	n.Add(n, big.NewInt(offset))
+
+	buf := n.Bytes()
+	result := make(net.IP, net.IPv6len)
+	if len(buf) <= net.IPv6len {
+		copy(result[net.IPv6len-len(buf):], buf)
+	}
+
+	return result
+}
+
+// networkIPCompare compares two addresses, returning -1, 0 or 1.
+func networkIPCompare(a net.IP, b net.IP) int {
+	return big.NewInt(0).SetBytes(a.To16()).Cmp(big.NewInt(0).SetBytes(b.To16()))
+}
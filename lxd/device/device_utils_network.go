@@ -386,7 +386,7 @@ func networkSetVethRoutes(m config.Device) error {
 	if m["ipv4.routes"] != "" {
 		for _, route := range strings.Split(m["ipv4.routes"], ",") {
 			route = strings.TrimSpace(route)
-			_, err := shared.RunCommand("ip", "-4", "route", "add", route, "dev", routeDev, "proto", "boot")
+			_, err := shared.RunCommand("ip", networkVethRouteArgs(m, "-4", "add", route, routeDev)...)
 			if err != nil {
 				return err
 			}
@@ -397,7 +397,7 @@ func networkSetVethRoutes(m config.Device) error {
 	if m["ipv6.routes"] != "" {
 		for _, route := range strings.Split(m["ipv6.routes"], ",") {
 			route = strings.TrimSpace(route)
-			_, err := shared.RunCommand("ip", "-6", "route", "add", route, "dev", routeDev, "proto", "boot")
+			_, err := shared.RunCommand("ip", networkVethRouteArgs(m, "-6", "add", route, routeDev)...)
 			if err != nil {
 				return err
 			}
@@ -431,7 +431,7 @@ func networkRemoveVethRoutes(m config.Device) {
 	if m["ipv4.routes"] != "" {
 		for _, route := range strings.Split(m["ipv4.routes"], ",") {
 			route = strings.TrimSpace(route)
-			_, err := shared.RunCommand("ip", "-4", "route", "flush", route, "dev", routeDev, "proto", "boot")
+			_, err := shared.RunCommand("ip", networkVethRouteArgs(m, "-4", "flush", route, routeDev)...)
 			if err != nil {
 				logger.Errorf("Failed to remove static route: %s to %s: %s", route, routeDev, err)
 			}
@@ -442,7 +442,7 @@ func networkRemoveVethRoutes(m config.Device) {
 	if m["ipv6.routes"] != "" {
 		for _, route := range strings.Split(m["ipv6.routes"], ",") {
 			route = strings.TrimSpace(route)
-			_, err := shared.RunCommand("ip", "-6", "route", "flush", route, "dev", routeDev, "proto", "boot")
+			_, err := shared.RunCommand("ip", networkVethRouteArgs(m, "-6", "flush", route, routeDev)...)
 			if err != nil {
 				logger.Errorf("Failed to remove static route: %s to %s: %s", route, routeDev, err)
 			}
@@ -450,6 +450,41 @@ func networkRemoveVethRoutes(m config.Device) {
 	}
 }
 
+// networkVethRouteArgs returns the ip command arguments to add or flush a static route to the
+// container nic, in the table of the VRF of the device if any.
+func networkVethRouteArgs(m config.Device, family string, action string, route string, routeDev string) []string {
+	args := []string{family, "route", action, route, "dev", routeDev, "proto", "boot"}
+	if m["vrf"] != "" {
+		args = append(args, "vrf", m["vrf"])
+	}
+
+	return args
+}
+
+// networkIsVRF returns whether the named interface is a VRF device.
+func networkIsVRF(name string) bool {
+	content, err := ioutil.ReadFile(fmt.Sprintf("/sys/class/net/%s/uevent", name))
+	if err != nil {
+		return false
+	}
+
+	return shared.StringInSlice("DEVTYPE=vrf", strings.Split(string(content), "\n"))
+}
+
+// networkFillVethLimits sets the network rate limits of the instance as the default ones of the
+// device, for the directions the device doesn't limit itself.
+func networkFillVethLimits(m config.Device, instanceConfig map[string]string) {
+	if m["limits.max"] != "" {
+		return
+	}
+
+	for _, direction := range []string{"ingress", "egress"} {
+		if m[fmt.Sprintf("limits.%s", direction)] == "" {
+			m[fmt.Sprintf("limits.%s", direction)] = instanceConfig[fmt.Sprintf("limits.network.%s", direction)]
+		}
+	}
+}
+
 // networkSetVethLimits applies any network rate limits to the veth device specified in the config.
 func networkSetVethLimits(m config.Device) error {
 	var err error
@@ -519,6 +554,46 @@ func networkSetVethLimits(m config.Device) error {
 	return nil
 }
 
+// NetworkValidDNSName validates a DNS host name. If string is empty, returns valid.
+func NetworkValidDNSName(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	if !shared.ValidHostname(value) {
+		return fmt.Errorf("Not a valid DNS name: %s", value)
+	}
+
+	return nil
+}
+
+// networkValidInterfaceName validates the name of a host network interface. If string is empty,
+// returns valid.
+func networkValidInterfaceName(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	if len(value) > 15 {
+		return fmt.Errorf("Interface name is too long (maximum 15 characters): %s", value)
+	}
+
+	if value == "." || value == ".." || strings.ContainsAny(value, "/: \t\n\x00") {
+		return fmt.Errorf("Not a valid interface name: %s", value)
+	}
+
+	return nil
+}
+
+// NetworkValidDHCPClientID validates a DHCP client identifier. If string is empty, returns valid.
+func NetworkValidDHCPClientID(value string) error {
+	if strings.ContainsAny(value, ", \t\n") {
+		return fmt.Errorf("DHCP client identifier cannot contain commas or whitespace: %s", value)
+	}
+
+	return nil
+}
+
 // NetworkValidAddress validates an IP address string. If string is empty, returns valid.
 func NetworkValidAddress(value string) error {
 	if value == "" {
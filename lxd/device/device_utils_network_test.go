@@ -0,0 +1,17 @@
+package device
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNetworkValidInterfaceName(t *testing.T) {
+	for _, name := range []string{"", "vrf-blue", "eth0.100", "br_0"} {
+		assert.NoError(t, networkValidInterfaceName(name), name)
+	}
+
+	for _, name := range []string{".", "..", "../../etc", "vrf blue", "vrf:0", "vrf-blue-and-green"} {
+		assert.Error(t, networkValidInterfaceName(name), name)
+	}
+}
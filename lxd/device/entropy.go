@@ -0,0 +1,225 @@
+package device
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/shared"
+)
+
+// Size in bytes of the random seed kept for an instance.
+const entropySeedSize = 512
+
+type entropy struct {
+	deviceCommon
+}
+
+// validateConfig checks the supplied config for correctness.
+func (d *entropy) validateConfig() error {
+	if d.instance.Type() != instance.TypeContainer {
+		return ErrUnsupportedDevType
+	}
+
+	rules := map[string]func(string) error{
+		"random": shared.IsBool,
+		"seed":   shared.IsBool,
+		"seed.path": func(value string) error {
+			if value != "" && !filepath.IsAbs(value) {
+				return fmt.Errorf("The seed path must be absolute")
+			}
+
+			return nil
+		},
+	}
+
+	err := config.ValidateDevice(rules, d.config)
+	if err != nil {
+		return err
+	}
+
+	if d.config["seed.path"] != "" && !shared.IsTrue(d.config["seed"]) {
+		return fmt.Errorf("The seed path can only be set when seed is enabled")
+	}
+
+	return nil
+}
+
+// Start is run when the device is added to the container.
+func (d *entropy) Start() (*RunConfig, error) {
+	runConf := RunConfig{}
+
+	// Back /dev/random with the host's /dev/urandom so reads never block.
+	if d.wantsRandom() {
+		m := config.Device{"mode": "0666"}
+
+		err := unixDeviceSetupCharNum(d.state, d.instance.DevicesPath(), "unix", d.name, m, 1, 9, "/dev/random", false, &runConf)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if shared.IsTrue(d.config["seed"]) {
+		err := d.seedSetup(&runConf)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &runConf, nil
+}
+
+// seedSetup writes the instance's random seed to the host file which is then bind-mounted in
+// the instance. The stored seed is mixed with fresh randomness so it's never handed out twice.
+func (d *entropy) seedSetup(runConf *RunConfig) error {
+	seed := make([]byte, entropySeedSize)
+	_, err := rand.Read(seed)
+	if err != nil {
+		return err
+	}
+
+	previous, err := entropySeedRead(d.seedStatePath())
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("Failed to read random seed for device '%s': %v", d.name, err)
+	}
+
+	for i := 0; i < len(previous) && i < len(seed); i++ {
+		seed[i] ^= previous[i]
+	}
+
+	if !shared.PathExists(d.instance.DevicesPath()) {
+		err := os.Mkdir(d.instance.DevicesPath(), 0711)
+		if err != nil {
+			return fmt.Errorf("Failed to create devices path: %s", err)
+		}
+	}
+
+	seedPath := d.seedHostPath()
+	err = ioutil.WriteFile(seedPath, seed, 0600)
+	if err != nil {
+		return fmt.Errorf("Failed to write random seed %s: %s", seedPath, err)
+	}
+
+	runConf.Mounts = append(runConf.Mounts, MountEntryItem{
+		DevPath:    seedPath,
+		TargetPath: strings.TrimPrefix(d.seedPath(), "/"),
+		FSType:     "none",
+		Opts:       []string{"bind", "create=file"},
+	})
+
+	return nil
+}
+
+// Stop is run when the device is removed from the instance.
+func (d *entropy) Stop() (*RunConfig, error) {
+	runConf := RunConfig{
+		PostHooks: []func() error{d.postStop},
+	}
+
+	if d.wantsRandom() {
+		err := unixDeviceRemove(d.instance.DevicesPath(), "unix", d.name, &runConf)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if shared.IsTrue(d.config["seed"]) {
+		runConf.Mounts = append(runConf.Mounts, MountEntryItem{
+			TargetPath: strings.TrimPrefix(d.seedPath(), "/"),
+		})
+	}
+
+	return &runConf, nil
+}
+
+// postStop is run after the device is removed from the instance. The seed left by the instance
+// (usually refreshed by its init system on shutdown) is kept for the next start.
+func (d *entropy) postStop() error {
+	if d.wantsRandom() {
+		err := unixDeviceDeleteFiles(d.state, d.instance.DevicesPath(), "unix", d.name)
+		if err != nil {
+			return fmt.Errorf("Failed to delete files for device '%s': %v", d.name, err)
+		}
+	}
+
+	seedPath := d.seedHostPath()
+	if !shared.PathExists(seedPath) {
+		return nil
+	}
+
+	seed, err := entropySeedRead(seedPath)
+	if err == nil && len(seed) > 0 {
+		err = ioutil.WriteFile(d.seedStatePath(), seed, 0600)
+		if err != nil {
+			return fmt.Errorf("Failed to save random seed for device '%s': %v", d.name, err)
+		}
+	}
+
+	err = os.Remove(seedPath)
+	if err != nil {
+		return fmt.Errorf("Failed to delete random seed for device '%s': %v", d.name, err)
+	}
+
+	return nil
+}
+
+// Remove is run when the device is removed from the instance or the instance is deleted, dropping
+// the kept seed.
+func (d *entropy) Remove() error {
+	err := os.Remove(d.seedStatePath())
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	empty, _ := shared.PathIsEmpty(d.instance.DevicesPath())
+	if empty {
+		os.Remove(d.instance.DevicesPath())
+	}
+
+	return nil
+}
+
+// wantsRandom returns whether /dev/random should be backed by /dev/urandom, the default.
+func (d *entropy) wantsRandom() bool {
+	return d.config["random"] == "" || shared.IsTrue(d.config["random"])
+}
+
+// seedPath returns the path of the seed file inside the instance.
+func (d *entropy) seedPath() string {
+	if d.config["seed.path"] != "" {
+		return d.config["seed.path"]
+	}
+
+	return "/var/lib/systemd/random-seed"
+}
+
+// seedHostPath returns the path of the seed file on the host.
+func (d *entropy) seedHostPath() string {
+	return filepath.Join(d.instance.DevicesPath(), fmt.Sprintf("entropy.%s.seed", unixDeviceEncode(d.name)))
+}
+
+// seedStatePath returns the path of the file keeping the seed on the host while the instance is
+// stopped, which the instance doesn't see.
+func (d *entropy) seedStatePath() string {
+	return filepath.Join(d.instance.DevicesPath(), fmt.Sprintf("entropy.%s.state", unixDeviceEncode(d.name)))
+}
+
+// entropySeedRead reads at most entropySeedSize bytes of a seed file, which the instance may have
+// replaced with anything.
+func entropySeedRead(path string) ([]byte, error) {
+	f, err := os.OpenFile(path, os.O_RDONLY|unix.O_NOFOLLOW, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ioutil.ReadAll(io.LimitReader(f, entropySeedSize))
+}
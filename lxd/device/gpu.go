@@ -13,6 +13,7 @@ import (
 	"strings"
 
 	"github.com/jaypipes/pcidb"
+	"github.com/pborman/uuid"
 	"golang.org/x/sys/unix"
 
 	"github.com/lxc/lxd/lxd/device/config"
@@ -20,6 +21,9 @@ import (
 	"github.com/lxc/lxd/shared"
 )
 
+// gpuPCIAddressRegexp matches a full PCI address, as used in the sysfs paths of the devices.
+var gpuPCIAddressRegexp = regexp.MustCompile(`^[0-9a-fA-F]{4}:[0-9a-fA-F]{2}:[0-9a-fA-F]{2}\.[0-7]$`)
+
 type gpu struct {
 	deviceCommon
 }
@@ -116,6 +120,7 @@ func (d *gpu) validateConfig() error {
 		"uid":       shared.IsUnixUserID,
 		"gid":       shared.IsUnixUserID,
 		"mode":      shared.IsOctalFileMode,
+		"mdev":      shared.IsAny,
 	}
 
 	err := config.ValidateDevice(rules, d.config)
@@ -131,6 +136,27 @@ func (d *gpu) validateConfig() error {
 		return fmt.Errorf("Cannot use pci, productid or vendorid when id is set")
 	}
 
+	if d.config["mdev"] != "" {
+		if d.config["id"] != "" || d.config["productid"] != "" || d.config["vendorid"] != "" {
+			return fmt.Errorf("Cannot use id, productid or vendorid when mdev is set")
+		}
+
+		// Mediated devices are created from the mdev types of a parent device.
+		if uuid.Parse(d.config["mdev"]) == nil {
+			if d.config["pci"] == "" {
+				return fmt.Errorf("The pci property is required when mdev is a mediated device type")
+			}
+
+			if !gpuPCIAddressRegexp.MatchString(d.config["pci"]) {
+				return fmt.Errorf("Invalid PCI address %q, expected a full address like 0000:00:02.0", d.config["pci"])
+			}
+
+			if strings.ContainsAny(d.config["mdev"], "/\x00") || d.config["mdev"] == "." || d.config["mdev"] == ".." {
+				return fmt.Errorf("Invalid mediated device type %q", d.config["mdev"])
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -140,6 +166,10 @@ func (d *gpu) validateEnvironment() error {
 		return fmt.Errorf("Invalid PCI address (no device found): %s", d.config["pci"])
 	}
 
+	if d.config["mdev"] != "" && uuid.Parse(d.config["mdev"]) != nil && !shared.PathExists(gpuMdevPath(d.config["mdev"])) {
+		return fmt.Errorf("Invalid mediated device (no device found): %s", d.config["mdev"])
+	}
+
 	return nil
 }
 
@@ -152,6 +182,15 @@ func (d *gpu) Start() (*RunConfig, error) {
 
 	runConf := RunConfig{}
 
+	if d.config["mdev"] != "" {
+		err = d.startMdev(&runConf)
+		if err != nil {
+			return nil, err
+		}
+
+		return &runConf, nil
+	}
+
 	allGpus := d.deviceWantsAllGPUs(d.config)
 	gpus, nvidiaDevices, err := d.deviceLoadGpu(allGpus)
 	if err != nil {
@@ -243,9 +282,138 @@ func (d *gpu) postStop() error {
 		return fmt.Errorf("Failed to delete files for device '%s': %v", d.name, err)
 	}
 
+	// Remove the mediated device created when starting this device.
+	mdevUUID := d.volatileGet()["mdev"]
+	if mdevUUID != "" {
+		// Only ever touch a mediated device by its UUID.
+		if uuid.Parse(mdevUUID) == nil {
+			return fmt.Errorf("Invalid mediated device UUID '%s'", mdevUUID)
+		}
+
+		err = gpuMdevRemove(mdevUUID)
+		if err != nil {
+			return fmt.Errorf("Failed to remove mediated device '%s': %v", mdevUUID, err)
+		}
+
+		err = d.volatileSet(map[string]string{"mdev": ""})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// startMdev passes the VFIO group of a mediated device through to the instance, first creating
+// the mediated device if the mdev property is a mediated device type rather than a UUID.
+func (d *gpu) startMdev(runConf *RunConfig) error {
+	mdevUUID := d.config["mdev"]
+	if uuid.Parse(mdevUUID) == nil {
+		// Reuse the mediated device of a previous start if it's still around.
+		mdevUUID = d.volatileGet()["mdev"]
+		if uuid.Parse(mdevUUID) == nil || !shared.PathExists(gpuMdevPath(mdevUUID)) {
+			mdevUUID = uuid.NewRandom().String()
+
+			err := gpuMdevCreate(d.config["pci"], d.config["mdev"], mdevUUID)
+			if err != nil {
+				return err
+			}
+		}
+
+		err := d.volatileSet(map[string]string{"mdev": mdevUUID})
+		if err != nil {
+			return err
+		}
+	}
+
+	group, err := os.Readlink(filepath.Join(gpuMdevPath(mdevUUID), "iommu_group"))
+	if err != nil {
+		return fmt.Errorf("Failed to find the IOMMU group of mediated device '%s': %v", mdevUUID, err)
+	}
+
+	// The container needs both the VFIO container device and the group of the mediated device.
+	for _, path := range []string{"/dev/vfio/vfio", filepath.Join("/dev/vfio", filepath.Base(group))} {
+		stat := unix.Stat_t{}
+		err = unix.Stat(path, &stat)
+		if err != nil {
+			return fmt.Errorf("Failed to find VFIO device %q: %v", path, err)
+		}
+
+		err = unixDeviceSetupCharNum(d.state, d.instance.DevicesPath(), "unix", d.name, d.config, unix.Major(stat.Rdev), unix.Minor(stat.Rdev), path, false, runConf)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+// gpuMdevPath returns the sysfs path of a mediated device.
+func gpuMdevPath(mdevUUID string) string {
+	return filepath.Join("/sys/bus/mdev/devices", mdevUUID)
+}
+
+// gpuMdevTypes returns the mediated device types supported by a PCI device, given its sysfs path.
+func gpuMdevTypes(devicePath string) ([]string, error) {
+	entries, err := ioutil.ReadDir(filepath.Join(devicePath, "mdev_supported_types"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+
+		return nil, err
+	}
+
+	types := []string{}
+	for _, entry := range entries {
+		types = append(types, entry.Name())
+	}
+
+	return types, nil
+}
+
+// gpuMdevCreate creates a mediated device of the given type on a parent PCI device.
+func gpuMdevCreate(pci string, mdevType string, mdevUUID string) error {
+	// Both end up in the sysfs path written to.
+	if !gpuPCIAddressRegexp.MatchString(pci) {
+		return fmt.Errorf("Invalid PCI address '%s'", pci)
+	}
+
+	devicePath := filepath.Join("/sys/bus/pci/devices", pci)
+	types, err := gpuMdevTypes(devicePath)
+	if err != nil {
+		return fmt.Errorf("Failed to list the mediated device types of '%s': %v", pci, err)
+	}
+
+	if !shared.StringInSlice(mdevType, types) {
+		return fmt.Errorf("Mediated device type '%s' isn't supported by '%s'", mdevType, pci)
+	}
+
+	typePath := filepath.Join(devicePath, "mdev_supported_types", mdevType)
+
+	available, err := shared.ParseNumberFromFile(filepath.Join(typePath, "available_instances"))
+	if err == nil && available < 1 {
+		return fmt.Errorf("No mediated device of type '%s' available on '%s'", mdevType, pci)
+	}
+
+	err = ioutil.WriteFile(filepath.Join(typePath, "create"), []byte(mdevUUID), 0200)
+	if err != nil {
+		return fmt.Errorf("Failed to create mediated device of type '%s' on '%s': %v", mdevType, pci, err)
+	}
+
+	return nil
+}
+
+// gpuMdevRemove removes a mediated device, if it still exists.
+func gpuMdevRemove(mdevUUID string) error {
+	path := gpuMdevPath(mdevUUID)
+	if !shared.PathExists(path) {
+		return nil
+	}
+
+	return ioutil.WriteFile(filepath.Join(path, "remove"), []byte("1"), 0200)
+}
+
 // deviceWantsAllGPUs whether the LXD device wants to passthrough all GPUs on the host.
 func (d *gpu) deviceWantsAllGPUs(m map[string]string) bool {
 	return m["vendorid"] == "" && m["productid"] == "" && m["id"] == "" && m["pci"] == ""
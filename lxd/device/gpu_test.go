@@ -0,0 +1,45 @@
+package device
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGPUPCIAddressRegexp(t *testing.T) {
+	for _, address := range []string{"0000:00:02.0", "0000:af:00.7", "0001:3B:1F.1"} {
+		assert.True(t, gpuPCIAddressRegexp.MatchString(address), address)
+	}
+
+	for _, address := range []string{"", "00:02.0", "0000:00:02.8", "0000:00:02", "../../../../tmp", "0000:00:02.0/../x"} {
+		assert.False(t, gpuPCIAddressRegexp.MatchString(address), address)
+	}
+}
+
+func TestGPUMdevTypes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd-gpu-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// No mediated device support
+	types, err := gpuMdevTypes(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{}, types)
+
+	for _, name := range []string{"i915-GVTg_V5_4", "i915-GVTg_V5_8"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "mdev_supported_types", name), 0755))
+	}
+
+	types, err = gpuMdevTypes(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"i915-GVTg_V5_4", "i915-GVTg_V5_8"}, types)
+}
+
+func TestGPUMdevCreateInvalid(t *testing.T) {
+	err := gpuMdevCreate("../../../../tmp", "i915-GVTg_V5_4", "6b9e2a8c-6d3c-4c4a-9f2e-2f0c3b1d4e5f")
+	assert.EqualError(t, err, "Invalid PCI address '../../../../tmp'")
+}
@@ -1,13 +1,38 @@
 package device
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/lxc/lxd/lxd/device/config"
 	"github.com/lxc/lxd/shared"
 )
 
+// nicRawLXCKeys are the liblxc keys of a network interface which can be set
+// through raw.lxc. Those LXD sets itself, such as the type, link, name or
+// hwaddr, and the scripts run on the host are left out.
+var nicRawLXCKeys = []string{
+	"flags",
+	"ipv4.address",
+	"ipv4.gateway",
+	"ipv6.address",
+	"ipv6.gateway",
+	"ipvlan.isolation",
+	"ipvlan.mode",
+	"l2proxy",
+	"macvlan.mode",
+	"veth.ipv4.route",
+	"veth.ipv6.route",
+	"veth.mode",
+	"veth.vlan.id",
+	"veth.vlan.tagged",
+	"vlan.id",
+}
+
 // nicTypes defines the supported nic type devices and defines their creation functions.
 var nicTypes = map[string]func() device{
 	"physical": func() device { return &nicPhysical{} },
+	"bonded":   func() device { return &nicBonded{} },
 	"ipvlan":   func() device { return &nicIPVLAN{} },
 	"p2p":      func() device { return &nicP2P{} },
 	"bridged":  func() device { return &nicBridged{} },
@@ -34,6 +59,7 @@ func nicValidationRules(requiredFields []string, optionalFields []string) map[st
 		"vlan":                    shared.IsAny,
 		"hwaddr":                  shared.IsAny,
 		"host_name":               shared.IsAny,
+		"network":                 shared.IsAny,
 		"limits.ingress":          shared.IsAny,
 		"limits.egress":           shared.IsAny,
 		"limits.max":              shared.IsAny,
@@ -46,6 +72,17 @@ func nicValidationRules(requiredFields []string, optionalFields []string) map[st
 		"ipv6.address":            NetworkValidAddressV6,
 		"ipv4.routes":             NetworkValidNetworkV4List,
 		"ipv6.routes":             NetworkValidNetworkV6List,
+		"dns.name":                NetworkValidDNSName,
+		"dhcp.client-id":          NetworkValidDHCPClientID,
+		"vrf":                     networkValidInterfaceName,
+		"bond.mode": func(value string) error {
+			return shared.IsOneOf(value, nicBondModes)
+		},
+		"bond.miimon": shared.IsUint32,
+		"raw.lxc": func(value string) error {
+			_, err := NICRawLXCConfig(value)
+			return err
+		},
 	}
 
 	validators := map[string]func(value string) error{}
@@ -91,3 +128,35 @@ func nicValidationRules(requiredFields []string, optionalFields []string) map[st
 
 	return validators
 }
+
+// NICRawLXCConfig parses the raw.lxc setting of a NIC device, made of
+// "key = value" lines whose keys are relative to the lxc.net.<index> prefix
+// of the interface, e.g. "veth.ipv4.route = 192.0.2.0/24".
+func NICRawLXCConfig(value string) ([]RunConfigItem, error) {
+	items := []RunConfigItem{}
+
+	for _, line := range strings.Split(value, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.SplitN(line, "=", 2)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("Invalid raw.lxc line: %s", line)
+		}
+
+		key := strings.ToLower(strings.TrimSpace(fields[0]))
+		if strings.HasPrefix(key, "lxc.") {
+			return nil, fmt.Errorf("The raw.lxc keys of a NIC are relative to its lxc.net.<index> prefix: %s", key)
+		}
+
+		if !shared.StringInSlice(key, nicRawLXCKeys) {
+			return nil, fmt.Errorf("The %s key of a NIC can't be set through raw.lxc", key)
+		}
+
+		items = append(items, RunConfigItem{Key: key, Value: strings.TrimSpace(fields[1])})
+	}
+
+	return items, nil
+}
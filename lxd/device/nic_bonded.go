@@ -0,0 +1,298 @@
+package device
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/shared"
+)
+
+// nicBondModes are the bonding modes supported by the kernel.
+var nicBondModes = []string{"balance-rr", "active-backup", "balance-xor", "broadcast", "802.3ad", "balance-tlb", "balance-alb"}
+
+type nicBonded struct {
+	deviceCommon
+}
+
+// NICBondedParents returns the host interfaces listed in the parent property of a bonded NIC.
+func NICBondedParents(parent string) []string {
+	parents := []string{}
+	for _, name := range strings.Split(parent, ",") {
+		name = strings.TrimSpace(name)
+		if name != "" {
+			parents = append(parents, name)
+		}
+	}
+
+	return parents
+}
+
+// validateConfig checks the supplied config for correctness.
+func (d *nicBonded) validateConfig() error {
+	if d.instance.Type() != instance.TypeContainer {
+		return ErrUnsupportedDevType
+	}
+
+	requiredFields := []string{"parent"}
+	optionalFields := []string{
+		"name",
+		"mtu",
+		"hwaddr",
+		"bond.mode",
+		"bond.miimon",
+		"maas.subnet.ipv4",
+		"maas.subnet.ipv6",
+		"raw.lxc",
+	}
+	err := config.ValidateDevice(nicValidationRules(requiredFields, optionalFields), d.config)
+	if err != nil {
+		return err
+	}
+
+	parents := NICBondedParents(d.config["parent"])
+	if len(parents) == 0 {
+		return fmt.Errorf("Requires at least one parent device")
+	}
+
+	for i, parent := range parents {
+		if shared.StringInSlice(parent, parents[i+1:]) {
+			return fmt.Errorf("Parent device '%s' is listed more than once", parent)
+		}
+	}
+
+	return nil
+}
+
+// validateEnvironment checks the runtime environment for correctness.
+func (d *nicBonded) validateEnvironment() error {
+	if d.config["name"] == "" {
+		return fmt.Errorf("Requires name property to start")
+	}
+
+	if !shared.PathExists("/sys/class/net/bonding_masters") {
+		return fmt.Errorf("The bonding kernel module isn't loaded")
+	}
+
+	for _, parent := range NICBondedParents(d.config["parent"]) {
+		if !shared.PathExists(fmt.Sprintf("/sys/class/net/%s", parent)) {
+			return fmt.Errorf("Parent device '%s' doesn't exist", parent)
+		}
+
+		if shared.PathExists(fmt.Sprintf("/sys/class/net/%s/master", parent)) {
+			return fmt.Errorf("Parent device '%s' is already part of a bridge or bond", parent)
+		}
+	}
+
+	return nil
+}
+
+// Start is run when the device is added to a running instance or instance is starting up.
+func (d *nicBonded) Start() (*RunConfig, error) {
+	err := d.validateEnvironment()
+	if err != nil {
+		return nil, err
+	}
+
+	saveData := make(map[string]string)
+	saveData["last_state.bond.host_name"] = NetworkRandomDevName("bond")
+
+	mode := d.config["bond.mode"]
+	if mode == "" {
+		mode = "active-backup"
+	}
+
+	miimon := d.config["bond.miimon"]
+	if miimon == "" {
+		miimon = "100"
+	}
+
+	// Bonds can't change network namespace, the bond stays on the host
+	// and the container gets a macvlan interface on top of it.
+	bondName := saveData["last_state.bond.host_name"]
+	_, err = shared.RunCommand("ip", "link", "add", "dev", bondName, "type", "bond", "mode", mode, "miimon", miimon)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create the bond: %s", err)
+	}
+
+	// If we return from this function with an error, ensure we release the parents.
+	parents := NICBondedParents(d.config["parent"])
+	defer func() {
+		if err != nil {
+			if saveData["host_name"] != "" {
+				NetworkRemoveInterface(saveData["host_name"])
+			}
+			NetworkRemoveInterface(bondName)
+			d.restoreParents(parents, saveData)
+		}
+	}()
+
+	// The parents must be down to be enslaved, their MTU and state are
+	// restored when released.
+	for _, parent := range parents {
+		var mtu uint64
+		mtu, err = NetworkGetDevMTU(parent)
+		if err != nil {
+			return nil, err
+		}
+		saveData[fmt.Sprintf("last_state.%s.mtu", parent)] = fmt.Sprintf("%d", mtu)
+
+		var iface *net.Interface
+		iface, err = net.InterfaceByName(parent)
+		if err != nil {
+			return nil, err
+		}
+		saveData[fmt.Sprintf("last_state.%s.up", parent)] = fmt.Sprintf("%t", iface.Flags&net.FlagUp != 0)
+
+		_, err = shared.RunCommand("ip", "link", "set", "dev", parent, "down")
+		if err != nil {
+			return nil, fmt.Errorf("Failed to bring down \"%s\": %s", parent, err)
+		}
+
+		_, err = shared.RunCommand("ip", "link", "set", "dev", parent, "master", bondName)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to add \"%s\" to the bond: %s", parent, err)
+		}
+	}
+
+	// Set the MTU, which the bond applies to its parents.
+	if d.config["mtu"] != "" {
+		_, err = shared.RunCommand("ip", "link", "set", "dev", bondName, "mtu", d.config["mtu"])
+		if err != nil {
+			return nil, fmt.Errorf("Failed to set the MTU: %s", err)
+		}
+	}
+
+	_, err = shared.RunCommand("ip", "link", "set", "dev", bondName, "up")
+	if err != nil {
+		return nil, fmt.Errorf("Failed to bring up the bond: %s", err)
+	}
+
+	// Create the interface passed to the container.
+	saveData["host_name"] = NetworkRandomDevName("mac")
+	_, err = shared.RunCommand("ip", "link", "add", "dev", saveData["host_name"], "link", bondName, "type", "macvlan", "mode", "bridge")
+	if err != nil {
+		return nil, err
+	}
+
+	// Set the MAC address.
+	if d.config["hwaddr"] != "" {
+		_, err = shared.RunCommand("ip", "link", "set", "dev", saveData["host_name"], "address", d.config["hwaddr"])
+		if err != nil {
+			return nil, fmt.Errorf("Failed to set the MAC address: %s", err)
+		}
+	}
+
+	if d.config["mtu"] != "" {
+		_, err = shared.RunCommand("ip", "link", "set", "dev", saveData["host_name"], "mtu", d.config["mtu"])
+		if err != nil {
+			return nil, fmt.Errorf("Failed to set the MTU: %s", err)
+		}
+	}
+
+	err = d.volatileSet(saveData)
+	if err != nil {
+		return nil, err
+	}
+
+	runConf := RunConfig{}
+	runConf.NetworkInterface = []RunConfigItem{
+		{Key: "name", Value: d.config["name"]},
+		{Key: "type", Value: "phys"},
+		{Key: "flags", Value: "up"},
+		{Key: "link", Value: saveData["host_name"]},
+	}
+
+	return &runConf, nil
+}
+
+// Stop is run when the device is removed from the instance.
+func (d *nicBonded) Stop() (*RunConfig, error) {
+	v := d.volatileGet()
+	runConf := RunConfig{
+		PostHooks: []func() error{d.postStop},
+		NetworkInterface: []RunConfigItem{
+			{Key: "link", Value: v["host_name"]},
+		},
+	}
+
+	return &runConf, nil
+}
+
+// postStop is run after the device is removed from the instance.
+func (d *nicBonded) postStop() error {
+	v := d.volatileGet()
+	parents := NICBondedParents(d.config["parent"])
+
+	cleared := map[string]string{"host_name": "", "last_state.bond.host_name": ""}
+	for _, parent := range parents {
+		cleared[fmt.Sprintf("last_state.%s.mtu", parent)] = ""
+		cleared[fmt.Sprintf("last_state.%s.up", parent)] = ""
+	}
+	defer d.volatileSet(cleared)
+
+	errs := []error{}
+
+	// Delete the detached device.
+	if v["host_name"] != "" && shared.PathExists(fmt.Sprintf("/sys/class/net/%s", v["host_name"])) {
+		err := NetworkRemoveInterface(v["host_name"])
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	// Removing the bond releases its parents.
+	if v["last_state.bond.host_name"] != "" && shared.PathExists(fmt.Sprintf("/sys/class/net/%s", v["last_state.bond.host_name"])) {
+		err := NetworkRemoveInterface(v["last_state.bond.host_name"])
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	err := d.restoreParents(parents, v)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%v", errs)
+	}
+
+	return nil
+}
+
+// restoreParents restores the MTU and state the parents had before being enslaved.
+func (d *nicBonded) restoreParents(parents []string, volatile map[string]string) error {
+	for _, parent := range parents {
+		value := volatile[fmt.Sprintf("last_state.%s.mtu", parent)]
+		if value != "" {
+			mtu, err := strconv.ParseUint(value, 10, 32)
+			if err != nil {
+				return fmt.Errorf("Failed to convert mtu for \"%s\" mtu \"%s\": %v", parent, value, err)
+			}
+
+			err = NetworkSetDevMTU(parent, mtu)
+			if err != nil {
+				return fmt.Errorf("Failed to restore physical dev \"%s\" mtu to \"%d\": %v", parent, mtu, err)
+			}
+		}
+
+		value = volatile[fmt.Sprintf("last_state.%s.up", parent)]
+		if value != "" {
+			state := "down"
+			if shared.IsTrue(value) {
+				state = "up"
+			}
+
+			_, err := shared.RunCommand("ip", "link", "set", "dev", parent, state)
+			if err != nil {
+				return fmt.Errorf("Failed to bring \"%s\" %s: %v", parent, state, err)
+			}
+		}
+	}
+
+	return nil
+}
@@ -56,6 +56,7 @@ func (d *nicBridged) validateConfig() error {
 		"mtu",
 		"hwaddr",
 		"host_name",
+		"raw.lxc",
 		"limits.ingress",
 		"limits.egress",
 		"limits.max",
@@ -68,6 +69,8 @@ func (d *nicBridged) validateConfig() error {
 		"security.ipv6_filtering",
 		"maas.subnet.ipv4",
 		"maas.subnet.ipv6",
+		"dns.name",
+		"dhcp.client-id",
 	}
 	err := config.ValidateDevice(nicValidationRules(requiredFields, optionalFields), d.config)
 	if err != nil {
@@ -93,7 +96,7 @@ func (d *nicBridged) validateEnvironment() error {
 // CanHotPlug returns whether the device can be managed whilst the instance is running, it also
 // returns a list of fields that can be updated without triggering a device remove & add.
 func (d *nicBridged) CanHotPlug() (bool, []string) {
-	return true, []string{"limits.ingress", "limits.egress", "limits.max", "ipv4.routes", "ipv6.routes", "ipv4.address", "ipv6.address", "security.mac_filtering", "security.ipv4_filtering", "security.ipv6_filtering"}
+	return true, []string{"limits.ingress", "limits.egress", "limits.max", "ipv4.routes", "ipv6.routes", "ipv4.address", "ipv6.address", "security.mac_filtering", "security.ipv4_filtering", "security.ipv6_filtering", "dns.name", "dhcp.client-id"}
 }
 
 // Add is run when a device is added to an instance whether or not the instance is running.
@@ -127,6 +130,7 @@ func (d *nicBridged) Start() (*RunConfig, error) {
 	}
 
 	// Apply and host-side limits and routes.
+	networkFillVethLimits(d.config, d.instance.ExpandedConfig())
 	err = networkSetupHostVethDevice(d.config, nil, saveData)
 	if err != nil {
 		NetworkRemoveInterface(saveData["host_name"])
@@ -193,11 +197,18 @@ func (d *nicBridged) Update(oldConfig config.Device, isRunning bool) error {
 		}
 
 		// Apply and host-side limits and routes.
+		networkFillVethLimits(d.config, d.instance.ExpandedConfig())
 		err = networkSetupHostVethDevice(d.config, oldConfig, v)
 		if err != nil {
 			return err
 		}
 
+		// Attach the host side veth interface to the bridge again, in case it was recreated.
+		err = NetworkAttachInterface(d.config["parent"], d.config["host_name"])
+		if err != nil {
+			return err
+		}
+
 		// Apply and host-side network filters (uses enriched host_name from networkSetupHostVethDevice).
 		err = d.setupHostFilters(oldConfig)
 		if err != nil {
@@ -331,7 +342,7 @@ func (d *nicBridged) rebuildDnsmasqEntry() error {
 		}
 	}
 
-	err = dnsmasq.UpdateStaticEntry(d.config["parent"], d.instance.Project(), d.instance.Name(), netConfig, d.config["hwaddr"], ipv4Address, ipv6Address)
+	err = dnsmasq.UpdateStaticEntry(d.config["parent"], d.instance.Project(), d.instance.Name(), netConfig, d.config, d.config["hwaddr"], ipv4Address, ipv6Address)
 	if err != nil {
 		return err
 	}
@@ -443,6 +454,11 @@ func (d *nicBridged) getDHCPStaticIPs(network string, instanceName string) (dhcp
 	for scanner.Scan() {
 		fields := strings.SplitN(scanner.Text(), ",", -1)
 		for _, field := range fields {
+			// Skip the DHCP client identifier.
+			if strings.HasPrefix(field, "id:") {
+				continue
+			}
+
 			// Check if field is IPv4 or IPv6 address.
 			if strings.Count(field, ".") == 3 {
 				IP := net.ParseIP(field)
@@ -713,7 +729,7 @@ func (d *nicBridged) allocateFilterIPs() (net.IP, net.IP, error) {
 			IPv6Str = IPv6.String()
 		}
 
-		err = dnsmasq.UpdateStaticEntry(d.config["parent"], d.instance.Project(), d.instance.Name(), netConfig, d.config["hwaddr"], IPv4Str, IPv6Str)
+		err = dnsmasq.UpdateStaticEntry(d.config["parent"], d.instance.Project(), d.instance.Name(), netConfig, d.config, d.config["hwaddr"], IPv4Str, IPv6Str)
 		if err != nil {
 			return nil, nil, err
 		}
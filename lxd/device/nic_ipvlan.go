@@ -5,7 +5,9 @@ import (
 	"strings"
 
 	"github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/lxd/dnsmasq"
 	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/lxd/project"
 	"github.com/lxc/lxd/shared"
 )
 
@@ -30,6 +32,8 @@ func (d *nicIPVLAN) validateConfig() error {
 		"hwaddr",
 		"host_name",
 		"vlan",
+		"raw.lxc",
+		"dns.name",
 	}
 
 	rules := nicValidationRules(requiredFields, optionalFields)
@@ -140,6 +144,11 @@ func (d *nicIPVLAN) Start() (*RunConfig, error) {
 		return nil, err
 	}
 
+	err = d.setupDNSEntry()
+	if err != nil {
+		return nil, err
+	}
+
 	runConf := RunConfig{}
 	nic := []RunConfigItem{
 		{Key: "name", Value: d.config["name"]},
@@ -208,6 +217,66 @@ func (d *nicIPVLAN) setupParentSysctls(parentName string) error {
 	return nil
 }
 
+// setupDNSEntry registers the DNS name of the device with its addresses if its parent is an LXD
+// managed network and reloads dnsmasq.
+func (d *nicIPVLAN) setupDNSEntry() error {
+	if d.config["vlan"] != "" || !shared.PathExists(shared.VarPath("networks", d.config["parent"], "dnsmasq.pid")) {
+		return nil
+	}
+
+	addresses := []string{}
+	for _, key := range []string{"ipv4.address", "ipv6.address"} {
+		if d.config[key] == "" {
+			continue
+		}
+
+		for _, addr := range strings.Split(d.config[key], ",") {
+			addresses = append(addresses, strings.TrimSpace(addr))
+		}
+	}
+
+	if len(addresses) == 0 {
+		return nil
+	}
+
+	dnsmasq.ConfigMutex.Lock()
+	defer dnsmasq.ConfigMutex.Unlock()
+
+	_, dbInfo, err := d.state.Cluster.NetworkGet(d.config["parent"])
+	if err != nil {
+		return err
+	}
+
+	err = dnsmasq.UpdateDNSEntry(d.config["parent"], d.instance.Project(), d.instance.Name(), dbInfo.Config, d.config, addresses)
+	if err != nil {
+		return err
+	}
+
+	return dnsmasq.Kill(d.config["parent"], true)
+}
+
+// removeDNSEntry unregisters the DNS name of the device if its parent is an LXD managed network
+// and reloads dnsmasq.
+func (d *nicIPVLAN) removeDNSEntry() error {
+	if !shared.PathExists(shared.VarPath("networks", d.config["parent"], "dnsmasq.dns", project.Prefix(d.instance.Project(), d.instance.Name()))) {
+		return nil
+	}
+
+	dnsmasq.ConfigMutex.Lock()
+	defer dnsmasq.ConfigMutex.Unlock()
+
+	err := dnsmasq.RemoveDNSEntry(d.config["parent"], d.instance.Project(), d.instance.Name())
+	if err != nil {
+		return err
+	}
+
+	if !shared.PathExists(shared.VarPath("networks", d.config["parent"], "dnsmasq.pid")) {
+		return nil
+	}
+
+	return dnsmasq.Kill(d.config["parent"], true)
+}
+
 // Stop is run when the device is removed from the instance.
 func (d *nicIPVLAN) Stop() (*RunConfig, error) {
 	runConf := RunConfig{
@@ -225,10 +294,15 @@ func (d *nicIPVLAN) postStop() error {
 
 	v := d.volatileGet()
 
+	err := d.removeDNSEntry()
+	if err != nil {
+		return err
+	}
+
 	// This will delete the parent interface if we created it for VLAN parent.
 	if shared.IsTrue(v["last_state.created"]) {
 		parentName := NetworkGetHostDevice(d.config["parent"], d.config["vlan"])
-		err := NetworkRemoveInterface(parentName)
+		err = NetworkRemoveInterface(parentName)
 		if err != nil {
 			return err
 		}
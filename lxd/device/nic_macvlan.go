@@ -19,15 +19,53 @@ func (d *nicMACVLAN) validateConfig() error {
 	}
 
 	requiredFields := []string{"parent"}
-	optionalFields := []string{"name", "mtu", "hwaddr", "vlan", "maas.subnet.ipv4", "maas.subnet.ipv6"}
+	optionalFields := []string{"name", "mtu", "hwaddr", "vlan", "maas.subnet.ipv4", "maas.subnet.ipv6", "raw.lxc"}
+
+	// External networks provide the parent, VLAN and MTU, along with the addresses.
+	if d.config["network"] != "" {
+		requiredFields = []string{"network"}
+		optionalFields = []string{"name", "hwaddr", "ipv4.address", "ipv6.address", "maas.subnet.ipv4", "maas.subnet.ipv6", "raw.lxc"}
+	}
+
 	err := config.ValidateDevice(nicValidationRules(requiredFields, optionalFields), d.config)
 	if err != nil {
 		return err
 	}
 
+	if d.config["network"] != "" {
+		_, netConfig, err := networkExternalLoad(d.state, d.config["network"])
+		if err != nil {
+			return err
+		}
+
+		d.config["parent"] = netConfig["parent"]
+		d.config["vlan"] = netConfig["vlan"]
+		d.config["mtu"] = netConfig["mtu"]
+	}
+
 	return nil
 }
 
+// Add is run when the device is added to the instance, assigning its addresses on external networks.
+func (d *nicMACVLAN) Add() error {
+	if d.config["network"] == "" {
+		return nil
+	}
+
+	_, err := networkExternalAllocate(d.state, d.instance, d.name, d.config)
+	return err
+}
+
+// Remove is run when the device is removed from the instance, releasing its addresses on external
+// networks.
+func (d *nicMACVLAN) Remove() error {
+	if d.config["network"] == "" {
+		return nil
+	}
+
+	return networkExternalRelease(d.state, d.instance, d.name)
+}
+
 // validateEnvironment checks the runtime environment for correctness.
 func (d *nicMACVLAN) validateEnvironment() error {
 	if d.config["name"] == "" {
@@ -106,9 +144,53 @@ func (d *nicMACVLAN) Start() (*RunConfig, error) {
 		{Key: "link", Value: saveData["host_name"]},
 	}
 
+	// Have LXC configure the addresses and gateways of external networks.
+	if d.config["network"] != "" {
+		items, err := d.externalAddresses()
+		if err != nil {
+			NetworkRemoveInterface(saveData["host_name"])
+			if createdDev {
+				NetworkRemoveInterface(parentName)
+			}
+			return nil, err
+		}
+
+		runConf.NetworkInterface = append(runConf.NetworkInterface, items...)
+	}
+
 	return &runConf, nil
 }
 
+// externalAddresses returns the LXC configuration of the addresses assigned on the external
+// network, allocating them if needed (e.g. for devices coming from a profile updated since).
+func (d *nicMACVLAN) externalAddresses() ([]RunConfigItem, error) {
+	addresses, err := networkExternalAllocate(d.state, d.instance, d.name, d.config)
+	if err != nil {
+		return nil, err
+	}
+
+	_, netConfig, err := networkExternalLoad(d.state, d.config["network"])
+	if err != nil {
+		return nil, err
+	}
+
+	items := []RunConfigItem{}
+	for _, family := range []string{"ipv4", "ipv6"} {
+		if addresses[family] == "" {
+			continue
+		}
+
+		items = append(items, RunConfigItem{Key: fmt.Sprintf("%s.address", family), Value: addresses[family]})
+
+		gateway, _, err := networkExternalSubnet(netConfig, family)
+		if err == nil && gateway != nil {
+			items = append(items, RunConfigItem{Key: fmt.Sprintf("%s.gateway", family), Value: gateway.String()})
+		}
+	}
+
+	return items, nil
+}
+
 // Stop is run when the device is removed from the instance.
 func (d *nicMACVLAN) Stop() (*RunConfig, error) {
 	v := d.volatileGet()
@@ -23,11 +23,13 @@ func (d *nicP2P) validateConfig() error {
 		"mtu",
 		"hwaddr",
 		"host_name",
+		"raw.lxc",
 		"limits.ingress",
 		"limits.egress",
 		"limits.max",
 		"ipv4.routes",
 		"ipv6.routes",
+		"vrf",
 	}
 	err := config.ValidateDevice(nicValidationRules([]string{}, optionalFields), d.config)
 	if err != nil {
@@ -43,6 +45,10 @@ func (d *nicP2P) validateEnvironment() error {
 		return fmt.Errorf("Requires name property to start")
 	}
 
+	if d.config["vrf"] != "" && !networkIsVRF(d.config["vrf"]) {
+		return fmt.Errorf("Invalid VRF (no VRF device found): %s", d.config["vrf"])
+	}
+
 	return nil
 }
 
@@ -71,7 +77,17 @@ func (d *nicP2P) Start() (*RunConfig, error) {
 		return nil, err
 	}
 
+	// Place the host-side interface into its VRF before adding the routes to its table.
+	if d.config["vrf"] != "" {
+		_, err = shared.RunCommand("ip", "link", "set", "dev", saveData["host_name"], "master", d.config["vrf"])
+		if err != nil {
+			NetworkRemoveInterface(saveData["host_name"])
+			return nil, err
+		}
+	}
+
 	// Apply and host-side limits and routes.
+	networkFillVethLimits(d.config, d.instance.ExpandedConfig())
 	err = networkSetupHostVethDevice(d.config, nil, saveData)
 	if err != nil {
 		NetworkRemoveInterface(saveData["host_name"])
@@ -108,6 +124,7 @@ func (d *nicP2P) Update(oldConfig config.Device, isRunning bool) error {
 	v := d.volatileGet()
 
 	// Apply and host-side limits and routes.
+	networkFillVethLimits(d.config, d.instance.ExpandedConfig())
 	err = networkSetupHostVethDevice(d.config, oldConfig, v)
 	if err != nil {
 		return err
@@ -26,6 +26,7 @@ func (d *nicPhysical) validateConfig() error {
 		"vlan",
 		"maas.subnet.ipv4",
 		"maas.subnet.ipv6",
+		"raw.lxc",
 	}
 	err := config.ValidateDevice(nicValidationRules(requiredFields, optionalFields), d.config)
 	if err != nil {
@@ -36,6 +36,7 @@ func (d *nicSRIOV) validateConfig() error {
 		"security.mac_filtering",
 		"maas.subnet.ipv4",
 		"maas.subnet.ipv6",
+		"raw.lxc",
 	}
 	err := config.ValidateDevice(nicValidationRules(requiredFields, optionalFields), d.config)
 	if err != nil {
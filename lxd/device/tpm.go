@@ -0,0 +1,312 @@
+package device
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/shared"
+)
+
+// tpmStopTimeout is how long swtpm is given to save the state of the TPM and exit.
+const tpmStopTimeout = 10 * time.Second
+
+// tpmDeviceRegexp matches the line printed by swtpm once it created the TPM device on the host.
+var tpmDeviceRegexp = regexp.MustCompile(`New TPM device: (/dev/tpm[0-9]+) \(major/minor = ([0-9]+)/([0-9]+)\)`)
+
+type tpm struct {
+	deviceCommon
+}
+
+// validateConfig checks the supplied config for correctness.
+func (d *tpm) validateConfig() error {
+	if d.instance.Type() != instance.TypeContainer {
+		return ErrUnsupportedDevType
+	}
+
+	rules := map[string]func(string) error{
+		"path":   shared.IsAny,
+		"pathrm": shared.IsAny,
+	}
+
+	err := config.ValidateDevice(rules, d.config)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// validateEnvironment checks the runtime environment for correctness.
+func (d *tpm) validateEnvironment() error {
+	_, err := exec.LookPath("swtpm")
+	if err != nil {
+		return fmt.Errorf("The swtpm tool is required for TPM devices")
+	}
+
+	if !shared.PathExists("/dev/vtpmx") {
+		return fmt.Errorf("The tpm_vtpm_proxy kernel module isn't loaded")
+	}
+
+	return nil
+}
+
+// Start is run when the device is added to the container.
+func (d *tpm) Start() (*RunConfig, error) {
+	err := d.validateEnvironment()
+	if err != nil {
+		return nil, err
+	}
+
+	if !shared.PathExists(d.instance.DevicesPath()) {
+		err := os.Mkdir(d.instance.DevicesPath(), 0711)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create devices path: %s", err)
+		}
+	}
+
+	// The state of the TPM is kept across restarts, until the device is removed.
+	statePath := TPMStatePath(d.instance.DevicesPath(), d.name)
+	err = os.MkdirAll(statePath, 0700)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create the TPM state directory: %s", err)
+	}
+
+	out, err := shared.RunCommand(
+		"swtpm", "chardev",
+		"--vtpm-proxy",
+		"--tpm2",
+		"--tpmstate", fmt.Sprintf("dir=%s", statePath),
+		"--pid", fmt.Sprintf("file=%s", d.pidPath()),
+		"--log", fmt.Sprintf("file=%s", filepath.Join(d.instance.LogPath(), fmt.Sprintf("tpm.%s.log", d.name))),
+		"--daemon",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to start swtpm: %s", err)
+	}
+
+	// If we return from this function with an error, ensure we stop swtpm.
+	defer func() {
+		if err != nil {
+			d.killSwtpm()
+		}
+	}()
+
+	match := tpmDeviceRegexp.FindStringSubmatch(out)
+	if match == nil {
+		err = fmt.Errorf("Failed to find the TPM device created by swtpm: %s", strings.TrimSpace(out))
+		return nil, err
+	}
+
+	major, err := strconv.ParseUint(match[2], 10, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	minor, err := strconv.ParseUint(match[3], 10, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	runConf := RunConfig{}
+
+	err = unixDeviceSetupCharNum(d.state, d.instance.DevicesPath(), "unix", d.name, d.config, uint32(major), uint32(minor), d.instancePath(), true, &runConf)
+	if err != nil {
+		return nil, err
+	}
+
+	// The kernel also creates the resource manager device of the TPM.
+	rmName := strings.Replace(filepath.Base(match[1]), "tpm", "tpmrm", 1)
+	rmMajor, rmMinor, rmErr := tpmDeviceNumbers(fmt.Sprintf("/sys/class/tpmrm/%s/dev", rmName))
+	if rmErr == nil {
+		err = unixDeviceSetupCharNum(d.state, d.instance.DevicesPath(), "unix", d.name, d.config, rmMajor, rmMinor, d.instancePathRM(), true, &runConf)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &runConf, nil
+}
+
+// Stop is run when the device is removed from the instance.
+func (d *tpm) Stop() (*RunConfig, error) {
+	runConf := RunConfig{
+		PostHooks: []func() error{d.postStop},
+	}
+
+	err := unixDeviceRemove(d.instance.DevicesPath(), "unix", d.name, &runConf)
+	if err != nil {
+		return nil, err
+	}
+
+	return &runConf, nil
+}
+
+// postStop is run after the device is removed from the instance.
+func (d *tpm) postStop() error {
+	err := d.killSwtpm()
+	if err != nil {
+		return fmt.Errorf("Failed to stop swtpm for device '%s': %v", d.name, err)
+	}
+
+	err = unixDeviceDeleteFiles(d.state, d.instance.DevicesPath(), "unix", d.name)
+	if err != nil {
+		return fmt.Errorf("Failed to delete files for device '%s': %v", d.name, err)
+	}
+
+	return nil
+}
+
+// Remove is run when the device is removed from the instance or the instance is deleted, dropping
+// the state of the TPM.
+func (d *tpm) Remove() error {
+	err := os.RemoveAll(TPMStatePath(d.instance.DevicesPath(), d.name))
+	if err != nil {
+		return err
+	}
+
+	// The devices path is left behind when the TPM state was in it as the instance got deleted.
+	empty, _ := shared.PathIsEmpty(d.instance.DevicesPath())
+	if empty {
+		os.Remove(d.instance.DevicesPath())
+	}
+
+	return nil
+}
+
+// killSwtpm stops the swtpm process of the device, which saves the state of the TPM. The pid file is
+// only removed once the process exited, so that a new swtpm never uses the state at the same time.
+func (d *tpm) killSwtpm() error {
+	contents, err := ioutil.ReadFile(d.pidPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(contents)))
+	if err != nil {
+		return err
+	}
+
+	// Check it's still swtpm
+	cmdline, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+	if err == nil {
+		cmdFields := strings.Split(string(bytes.TrimRight(cmdline, "\x00")), "\x00")
+		if filepath.Base(cmdFields[0]) == "swtpm" {
+			err = tpmStopProcess(pid)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return os.Remove(d.pidPath())
+}
+
+// tpmStopProcess sends SIGTERM to a swtpm process and waits for it to exit, killing it if it
+// doesn't in time.
+func tpmStopProcess(pid int) error {
+	err := unix.Kill(pid, unix.SIGTERM)
+	if err != nil && err != unix.ESRCH {
+		return err
+	}
+
+	if tpmWaitExit(pid, tpmStopTimeout) {
+		return nil
+	}
+
+	err = unix.Kill(pid, unix.SIGKILL)
+	if err != nil && err != unix.ESRCH {
+		return err
+	}
+
+	if !tpmWaitExit(pid, tpmStopTimeout) {
+		return fmt.Errorf("swtpm process %d didn't exit", pid)
+	}
+
+	return nil
+}
+
+// tpmWaitExit waits for a process to exit, returning whether it did before the timeout.
+func tpmWaitExit(pid int, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		err := unix.Kill(pid, 0)
+		if err == unix.ESRCH {
+			return true
+		}
+
+		if time.Now().After(deadline) {
+			return false
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// tpmDeviceNumbers returns the major and minor numbers of a device from its sysfs dev file.
+func tpmDeviceNumbers(path string) (uint32, uint32, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	fields := strings.SplitN(strings.TrimSpace(string(content)), ":", 2)
+	if len(fields) != 2 {
+		return 0, 0, fmt.Errorf("Invalid device numbers %q", content)
+	}
+
+	major, err := strconv.ParseUint(fields[0], 10, 32)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	minor, err := strconv.ParseUint(fields[1], 10, 32)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return uint32(major), uint32(minor), nil
+}
+
+// pidPath returns the path of the pid file of the swtpm process.
+func (d *tpm) pidPath() string {
+	return filepath.Join(d.instance.DevicesPath(), fmt.Sprintf("tpm.%s.pid", unixDeviceEncode(d.name)))
+}
+
+// instancePath returns the path of the TPM inside the instance.
+func (d *tpm) instancePath() string {
+	if d.config["path"] != "" {
+		return d.config["path"]
+	}
+
+	return "/dev/tpm0"
+}
+
+// instancePathRM returns the path of the TPM resource manager inside the instance.
+func (d *tpm) instancePathRM() string {
+	if d.config["pathrm"] != "" {
+		return d.config["pathrm"]
+	}
+
+	return "/dev/tpmrm0"
+}
+
+// TPMStatePath returns the host path of the directory holding the state of a TPM device.
+func TPMStatePath(devicesPath string, deviceName string) string {
+	return filepath.Join(devicesPath, fmt.Sprintf("tpm.%s", unixDeviceEncode(deviceName)))
+}
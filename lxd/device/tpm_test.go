@@ -0,0 +1,46 @@
+package device
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTPMDeviceRegexp(t *testing.T) {
+	out := "swtpm: New TPM device: /dev/tpm1 (major/minor = 253/65537)\n"
+
+	match := tpmDeviceRegexp.FindStringSubmatch(out)
+	require.NotNil(t, match)
+	assert.Equal(t, []string{"/dev/tpm1", "253", "65537"}, match[1:])
+
+	assert.Nil(t, tpmDeviceRegexp.FindStringSubmatch("swtpm: Could not open /dev/vtpmx\n"))
+}
+
+func TestTPMDeviceNumbers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd-tpm-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "dev")
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("253:65536\n"), 0644))
+	major, minor, err := tpmDeviceNumbers(path)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(253), major)
+	assert.Equal(t, uint32(65536), minor)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("253\n"), 0644))
+	_, _, err = tpmDeviceNumbers(path)
+	assert.Error(t, err)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("a:b\n"), 0644))
+	_, _, err = tpmDeviceNumbers(path)
+	assert.Error(t, err)
+
+	_, _, err = tpmDeviceNumbers(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,197 @@
+package device
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/lxd/instance"
+	"github.com/lxc/lxd/shared"
+)
+
+// WatchdogDefaultTimeout is the number of seconds after which a software watchdog fires if it
+// isn't written to.
+const WatchdogDefaultTimeout = 60
+
+type watchdog struct {
+	deviceCommon
+}
+
+// validateConfig checks the supplied config for correctness.
+func (d *watchdog) validateConfig() error {
+	if d.instance.Type() != instance.TypeContainer {
+		return ErrUnsupportedDevType
+	}
+
+	rules := map[string]func(string) error{
+		"mode": func(value string) error {
+			return shared.IsOneOf(value, []string{"hardware", "software"})
+		},
+		"source": shared.IsAny,
+		"path":   shared.IsAny,
+		"timeout": func(value string) error {
+			if value == "0" {
+				return fmt.Errorf("The timeout must be at least one second")
+			}
+
+			return shared.IsUint32(value)
+		},
+		"action": func(value string) error {
+			return shared.IsOneOf(value, []string{"event", "restart", "stop"})
+		},
+	}
+
+	err := config.ValidateDevice(rules, d.config)
+	if err != nil {
+		return err
+	}
+
+	if d.config["mode"] == "hardware" {
+		if d.config["timeout"] != "" || d.config["action"] != "" {
+			return fmt.Errorf("The timeout and action properties only apply to software watchdogs")
+		}
+	} else if d.config["source"] != "" {
+		return fmt.Errorf("The source property only applies to hardware watchdogs")
+	}
+
+	return nil
+}
+
+// validateEnvironment checks the runtime environment for correctness.
+func (d *watchdog) validateEnvironment() error {
+	if d.config["mode"] == "hardware" && !shared.PathExists(d.hostSource()) {
+		return fmt.Errorf("The host watchdog %q doesn't exist", d.hostSource())
+	}
+
+	return nil
+}
+
+// CanHotPlug returns whether the device can be managed whilst the instance is running. The
+// timeout and action of software watchdogs are read by LXD each time it arms the watchdog.
+func (d *watchdog) CanHotPlug() (bool, []string) {
+	return true, []string{"timeout", "action"}
+}
+
+// Update is run when the timeout or action changes, which apply on the next keepalive.
+func (d *watchdog) Update(oldConfig config.Device, isRunning bool) error {
+	return nil
+}
+
+// Start is run when the device is added to the container.
+func (d *watchdog) Start() (*RunConfig, error) {
+	err := d.validateEnvironment()
+	if err != nil {
+		return nil, err
+	}
+
+	runConf := RunConfig{}
+
+	if d.config["mode"] == "hardware" {
+		m := config.Device{
+			"type":   "unix-char",
+			"source": d.hostSource(),
+			"path":   d.instancePath(),
+			"mode":   "0600",
+		}
+
+		err = unixDeviceSetup(d.state, d.instance.DevicesPath(), "unix", d.name, m, false, &runConf)
+		if err != nil {
+			return nil, err
+		}
+
+		return &runConf, nil
+	}
+
+	// Software watchdogs are a FIFO read by LXD on the host.
+	if !shared.PathExists(d.instance.DevicesPath()) {
+		err := os.Mkdir(d.instance.DevicesPath(), 0711)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to create devices path: %s", err)
+		}
+	}
+
+	fifoPath := WatchdogFIFOPath(d.instance.DevicesPath(), d.name)
+	os.Remove(fifoPath)
+
+	err = unix.Mkfifo(fifoPath, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create watchdog %s: %s", fifoPath, err)
+	}
+
+	runConf.Mounts = append(runConf.Mounts, MountEntryItem{
+		DevPath:    fifoPath,
+		TargetPath: strings.TrimPrefix(d.instancePath(), "/"),
+		FSType:     "none",
+		Opts:       []string{"bind", "create=file"},
+	})
+
+	return &runConf, nil
+}
+
+// Stop is run when the device is removed from the instance.
+func (d *watchdog) Stop() (*RunConfig, error) {
+	runConf := RunConfig{
+		PostHooks: []func() error{d.postStop},
+	}
+
+	if d.config["mode"] == "hardware" {
+		err := unixDeviceRemove(d.instance.DevicesPath(), "unix", d.name, &runConf)
+		if err != nil {
+			return nil, err
+		}
+
+		return &runConf, nil
+	}
+
+	runConf.Mounts = append(runConf.Mounts, MountEntryItem{
+		TargetPath: strings.TrimPrefix(d.instancePath(), "/"),
+	})
+
+	return &runConf, nil
+}
+
+// postStop is run after the device is removed from the instance.
+func (d *watchdog) postStop() error {
+	if d.config["mode"] == "hardware" {
+		err := unixDeviceDeleteFiles(d.state, d.instance.DevicesPath(), "unix", d.name)
+		if err != nil {
+			return fmt.Errorf("Failed to delete files for device '%s': %v", d.name, err)
+		}
+
+		return nil
+	}
+
+	err := os.Remove(WatchdogFIFOPath(d.instance.DevicesPath(), d.name))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("Failed to delete watchdog for device '%s': %v", d.name, err)
+	}
+
+	return nil
+}
+
+// hostSource returns the host watchdog passed through in hardware mode.
+func (d *watchdog) hostSource() string {
+	if d.config["source"] != "" {
+		return d.config["source"]
+	}
+
+	return "/dev/watchdog"
+}
+
+// instancePath returns the path of the watchdog inside the instance.
+func (d *watchdog) instancePath() string {
+	if d.config["path"] != "" {
+		return d.config["path"]
+	}
+
+	return "/dev/watchdog"
+}
+
+// WatchdogFIFOPath returns the host path of the FIFO backing a software watchdog device.
+func WatchdogFIFOPath(devicesPath string, deviceName string) string {
+	return filepath.Join(devicesPath, fmt.Sprintf("watchdog.%s", unixDeviceEncode(deviceName)))
+}
@@ -13,11 +13,13 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"unsafe"
 
 	"golang.org/x/sys/unix"
 
 	"github.com/lxc/lxd/lxd/device"
+	"github.com/lxc/lxd/lxd/device/config"
 	"github.com/lxc/lxd/lxd/state"
 	"github.com/lxc/lxd/lxd/sys"
 	"github.com/lxc/lxd/lxd/util"
@@ -30,6 +32,10 @@ import (
 
 var deviceSchedRebalance = make(chan []string, 2)
 
+// Liveness of the device event listener and inotify handler, used by the health checks
+var deviceEventListenerRunning int32
+var deviceInotifyHandlerRunning int32
+
 type deviceBlockLimit struct {
 	readBps   int64
 	readIops  int64
@@ -102,7 +108,7 @@ func createUSBDevice(action string, vendor string, product string, major string,
 	}, nil
 }
 
-func deviceNetlinkListener() (chan []string, chan []string, chan usbDevice, error) {
+func deviceNetlinkListener() (chan []string, chan []string, chan usbDevice, chan hotplugDevice, error) {
 	NETLINK_KOBJECT_UEVENT := 15
 	UEVENT_BUFFER_SIZE := 2048
 
@@ -111,7 +117,7 @@ func deviceNetlinkListener() (chan []string, chan []string, chan usbDevice, erro
 		NETLINK_KOBJECT_UEVENT,
 	)
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, nil, nil, nil, err
 	}
 
 	nl := unix.SockaddrNetlink{
@@ -122,14 +128,15 @@ func deviceNetlinkListener() (chan []string, chan []string, chan usbDevice, erro
 
 	err = unix.Bind(fd, &nl)
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, nil, nil, nil, err
 	}
 
 	chCPU := make(chan []string, 1)
 	chNetwork := make(chan []string, 0)
 	chUSB := make(chan usbDevice)
+	chHotplug := make(chan hotplugDevice)
 
-	go func(chCPU chan []string, chNetwork chan []string, chUSB chan usbDevice) {
+	go func(chCPU chan []string, chNetwork chan []string, chUSB chan usbDevice, chHotplug chan hotplugDevice) {
 		b := make([]byte, UEVENT_BUFFER_SIZE*2)
 		for {
 			r, err := unix.Read(fd, b)
@@ -159,6 +166,16 @@ func deviceNetlinkListener() (chan []string, chan []string, chan usbDevice, erro
 
 			ueventLen--
 
+			// Unix devices coming and going, for the hotplug rules
+			if (props["ACTION"] == "add" || props["ACTION"] == "remove") && props["MAJOR"] != "" && props["MINOR"] != "" && props["DEVNAME"] != "" {
+				dev, err := createHotplugDevice(props, ueventParts[:len(ueventParts)-1], ueventLen)
+				if err != nil {
+					logger.Error("Error reading hotplug device", log.Ctx{"err": err, "path": props["DEVPATH"]})
+				} else {
+					chHotplug <- dev
+				}
+			}
+
 			if props["SUBSYSTEM"] == "cpu" {
 				if props["DRIVER"] != "processor" {
 					continue
@@ -249,9 +266,9 @@ func deviceNetlinkListener() (chan []string, chan []string, chan usbDevice, erro
 			}
 
 		}
-	}(chCPU, chNetwork, chUSB)
+	}(chCPU, chNetwork, chUSB, chHotplug)
 
-	return chCPU, chNetwork, chUSB, nil
+	return chCPU, chNetwork, chUSB, chHotplug, nil
 }
 
 func parseCpuset(cpu string) ([]int, error) {
@@ -350,9 +367,13 @@ func deviceTaskBalance(s *state.State) {
 
 	effectiveCpus = strings.Join(effectiveCpusSlice, ",")
 
-	err = cGroupSet("cpuset", "/lxc", "cpuset.cpus", effectiveCpus)
-	if err != nil && shared.PathExists("/sys/fs/cgroup/cpuset/lxc") {
-		logger.Warn("Error setting lxd's cpuset.cpus", log.Ctx{"err": err})
+	// The unified hierarchy has no shared lxc cgroup, the containers'
+	// cpusets following the CPUs of the host until set
+	if !s.OS.CGroupV2 {
+		err = cGroupSet("cpuset", "/lxc", "cpuset.cpus", effectiveCpus)
+		if err != nil && shared.PathExists("/sys/fs/cgroup/cpuset/lxc") {
+			logger.Warn("Error setting lxd's cpuset.cpus", log.Ctx{"err": err})
+		}
 	}
 	cpus, err := parseCpuset(effectiveCpus)
 	if err != nil {
@@ -360,6 +381,20 @@ func deviceTaskBalance(s *state.State) {
 		return
 	}
 
+	// Group the CPUs by NUMA node
+	numaNodes, err := deviceNumaNodes()
+	if err != nil {
+		logger.Warn("Error reading host's NUMA nodes", log.Ctx{"err": err})
+		numaNodes = map[int][]int{}
+	}
+
+	cpuNodes := map[int]int{}
+	for node, nodeCpus := range numaNodes {
+		for _, id := range nodeCpus {
+			cpuNodes[id] = node
+		}
+	}
+
 	// Iterate through the containers
 	containers, err := containerLoadNodeAll(s)
 	if err != nil {
@@ -369,6 +404,7 @@ func deviceTaskBalance(s *state.State) {
 
 	fixedContainers := map[int][]container{}
 	balancedContainers := map[container]int{}
+	balancedCpus := map[container][]int{}
 	for _, c := range containers {
 		conf := c.ExpandedConfig()
 		cpulimit, ok := conf["limits.cpu"]
@@ -380,11 +416,27 @@ func deviceTaskBalance(s *state.State) {
 			continue
 		}
 
+		// Restrict the container to the CPUs of its NUMA nodes, all of
+		// them being used unless a number of CPUs is set
+		availableCpus := cpus
+		if conf["limits.cpu.nodes"] != "" {
+			availableCpus, err = deviceNumaCpus(numaNodes, conf["limits.cpu.nodes"], cpus)
+			if err != nil {
+				logger.Error("balance: Unable to use NUMA nodes", log.Ctx{"name": c.Name(), "err": err})
+				continue
+			}
+
+			if !ok || conf["limits.cpu"] == "" {
+				cpulimit = fmt.Sprintf("%d", len(availableCpus))
+			}
+		}
+
 		count, err := strconv.Atoi(cpulimit)
 		if err == nil {
 			// Load-balance
-			count = min(count, len(cpus))
+			count = min(count, len(availableCpus))
 			balancedContainers[c] = count
+			balancedCpus[c] = availableCpus
 		} else {
 			// Pinned
 			containerCpus, err := parseCpuset(cpulimit)
@@ -438,19 +490,13 @@ func deviceTaskBalance(s *state.State) {
 		}
 	}
 
-	sortedUsage := make(deviceTaskCPUs, 0)
-	for _, value := range usage {
-		sortedUsage = append(sortedUsage, value)
-	}
-
 	for ctn, count := range balancedContainers {
-		sort.Sort(sortedUsage)
-		for _, cpu := range sortedUsage {
-			if count == 0 {
-				break
-			}
-			count -= 1
+		candidates := deviceTaskCPUs{}
+		for _, id := range balancedCpus[ctn] {
+			candidates = append(candidates, usage[id])
+		}
 
+		for _, cpu := range deviceTaskPick(candidates, cpuNodes, count) {
 			id := cpu.strId
 			_, ok := pinning[ctn]
 			if ok {
@@ -477,6 +523,120 @@ func deviceTaskBalance(s *state.State) {
 	}
 }
 
+// deviceTaskPick returns the count least used CPUs among the candidates. They
+// are taken from the least used NUMA node which has enough of them, so that
+// the container doesn't have to reach for its memory across nodes.
+func deviceTaskPick(candidates deviceTaskCPUs, cpuNodes map[int]int, count int) deviceTaskCPUs {
+	groups := map[int]deviceTaskCPUs{}
+	nodes := []int{}
+	for _, cpu := range candidates {
+		node := cpuNodes[cpu.id]
+		if groups[node] == nil {
+			nodes = append(nodes, node)
+		}
+
+		groups[node] = append(groups[node], cpu)
+	}
+
+	if len(groups) > 1 {
+		sort.Ints(nodes)
+
+		var best deviceTaskCPUs
+		bestUsage := 0
+		for _, node := range nodes {
+			group := groups[node]
+			if len(group) < count {
+				continue
+			}
+
+			usage := 0
+			for _, cpu := range group {
+				usage += *cpu.count
+			}
+
+			// Compare the average usage of the CPUs of the nodes
+			if best == nil || usage*len(best) < bestUsage*len(group) {
+				best = group
+				bestUsage = usage
+			}
+		}
+
+		if best != nil {
+			candidates = best
+		}
+	}
+
+	sort.Sort(candidates)
+	return candidates[:count]
+}
+
+// deviceNumaNodes returns the online CPUs of each NUMA node of the host, or
+// an empty map if the kernel doesn't expose them.
+func deviceNumaNodes() (map[int][]int, error) {
+	nodes := map[int][]int{}
+
+	paths, err := filepath.Glob("/sys/devices/system/node/node[0-9]*")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range paths {
+		node, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(path), "node"))
+		if err != nil {
+			continue
+		}
+
+		content, err := ioutil.ReadFile(filepath.Join(path, "cpulist"))
+		if err != nil {
+			return nil, err
+		}
+
+		nodes[node] = []int{}
+
+		cpuList := strings.TrimSpace(string(content))
+		if cpuList == "" {
+			// Memory only node
+			continue
+		}
+
+		nodes[node], err = parseCpuset(cpuList)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return nodes, nil
+}
+
+// deviceNumaCpus returns the CPUs among the given ones which belong to the
+// NUMA nodes listed in a limits.cpu.nodes value.
+func deviceNumaCpus(numaNodes map[int][]int, value string, cpus []int) ([]int, error) {
+	ids, err := parseCpuset(value)
+	if err != nil {
+		return nil, err
+	}
+
+	nodeCpus := []int{}
+	for _, id := range ids {
+		node, ok := numaNodes[id]
+		if !ok {
+			return nil, fmt.Errorf("NUMA node %d doesn't exist", id)
+		}
+
+		for _, cpu := range node {
+			if shared.IntInSlice(cpu, cpus) {
+				nodeCpus = append(nodeCpus, cpu)
+			}
+		}
+	}
+
+	if len(nodeCpus) == 0 {
+		return nil, fmt.Errorf("No CPU available on NUMA nodes %s", value)
+	}
+
+	return nodeCpus, nil
+}
+
 func deviceNetworkPriority(s *state.State, netif string) {
 	// Don't bother running when CGroup support isn't there
 	if !s.OS.CGroupNetPrioController {
@@ -538,12 +698,14 @@ func deviceUSBEvent(s *state.State, usb usbDevice) {
 
 			if usb.action == "add" {
 				err := c.insertUnixDeviceNum(fmt.Sprintf("unix.%s", name), m, usb.major, usb.minor, usb.path, false)
+				containerDevicesLogAdd(c, name, m, "add", "hotplug", err)
 				if err != nil {
 					logger.Error("Failed to create usb device", log.Ctx{"err": err, "usb": usb, "container": c.Name()})
 					return
 				}
 			} else if usb.action == "remove" {
 				err := c.removeUnixDeviceNum(fmt.Sprintf("unix.%s", name), m, usb.major, usb.minor, usb.path)
+				containerDevicesLogAdd(c, name, m, "remove", "hotplug", err)
 				if err != nil {
 					logger.Error("Failed to remove usb device", log.Ctx{"err": err, "usb": usb, "container": c.Name()})
 					return
@@ -562,12 +724,15 @@ func deviceUSBEvent(s *state.State, usb usbDevice) {
 }
 
 func deviceEventListener(s *state.State) {
-	chNetlinkCPU, chNetlinkNetwork, chUSB, err := deviceNetlinkListener()
+	chNetlinkCPU, chNetlinkNetwork, chUSB, chHotplug, err := deviceNetlinkListener()
 	if err != nil {
 		logger.Errorf("scheduler: Couldn't setup netlink listener: %v", err)
 		return
 	}
 
+	atomic.StoreInt32(&deviceEventListenerRunning, 1)
+	defer atomic.StoreInt32(&deviceEventListenerRunning, 0)
+
 	for {
 		select {
 		case e := <-chNetlinkCPU:
@@ -597,6 +762,8 @@ func deviceEventListener(s *state.State) {
 			networkAutoAttach(s.Cluster, e[0])
 		case e := <-chUSB:
 			deviceUSBEvent(s, e)
+		case e := <-chHotplug:
+			deviceHotplugEvent(s, e)
 		case e := <-deviceSchedRebalance:
 			if len(e) != 3 {
 				logger.Errorf("Scheduler: received an invalid rebalance event")
@@ -702,6 +869,24 @@ func deviceParseCPU(cpuAllowance string, cpuPriority string) (string, string, st
 	return fmt.Sprintf("%d", cpuShares), cpuCfsQuota, cpuCfsPeriod, nil
 }
 
+func deviceParseCPUBurst(cpuBurst string) (string, error) {
+	if cpuBurst == "" {
+		return "0", nil
+	}
+
+	burst, err := strconv.Atoi(strings.TrimSuffix(cpuBurst, "ms"))
+	if err != nil {
+		return "", err
+	}
+
+	if burst < 0 {
+		return "", fmt.Errorf("Invalid CPU burst: %s", cpuBurst)
+	}
+
+	// Set burst in ms
+	return fmt.Sprintf("%d", burst*1000), nil
+}
+
 func deviceGetParentBlocks(path string) ([]string, error) {
 	var devices []string
 	var dev []string
@@ -848,6 +1033,75 @@ func deviceGetParentBlocks(path string) ([]string, error) {
 	return devices, nil
 }
 
+// deviceValidBlocks returns the major:minor of the whole block devices of
+// the host, those I/O limits can be set on.
+func deviceValidBlocks() ([]string, error) {
+	validBlocks := []string{}
+
+	dents, err := ioutil.ReadDir("/sys/class/block/")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range dents {
+		fPath := filepath.Join("/sys/class/block/", f.Name())
+		if shared.PathExists(fmt.Sprintf("%s/partition", fPath)) {
+			continue
+		}
+
+		if !shared.PathExists(fmt.Sprintf("%s/dev", fPath)) {
+			continue
+		}
+
+		block, err := ioutil.ReadFile(fmt.Sprintf("%s/dev", fPath))
+		if err != nil {
+			return nil, err
+		}
+
+		validBlocks = append(validBlocks, strings.TrimSuffix(string(block), "\n"))
+	}
+
+	return validBlocks, nil
+}
+
+// deviceValidBlock returns the whole block device among validBlocks which I/O
+// limits must be set on for the given block device, or an empty string if
+// there is none.
+func deviceValidBlock(block string, validBlocks []string) string {
+	if shared.StringInSlice(block, validBlocks) {
+		// Straightforward entry (full block device)
+		return block
+	}
+
+	// Attempt to deal with a partition (guess its parent)
+	fields := strings.SplitN(block, ":", 2)
+	if len(fields) != 2 {
+		return ""
+	}
+
+	parent := fmt.Sprintf("%s:0", fields[0])
+	if shared.StringInSlice(parent, validBlocks) {
+		return parent
+	}
+
+	return ""
+}
+
+// deviceParseDiskLatency returns the io.latency target of the given
+// limits.disk.latency, in microseconds.
+func deviceParseDiskLatency(diskLatency string) (string, error) {
+	latency, err := strconv.Atoi(strings.TrimSuffix(diskLatency, "ms"))
+	if err != nil {
+		return "", err
+	}
+
+	if latency < 1 {
+		return "", fmt.Errorf("Invalid disk latency target: %s", diskLatency)
+	}
+
+	return fmt.Sprintf("%d", latency*1000), nil
+}
+
 func deviceParseDiskLimit(readSpeed string, writeSpeed string) (int64, int64, int64, int64, error) {
 	parseValue := func(value string) (int64, int64, error) {
 		var err error
@@ -1139,229 +1393,264 @@ func deviceInotifyDelWatcher(s *state.State, path string) error {
 	return nil
 }
 
-func createAncestorPaths(cleanPath string) []string {
-	components := strings.Split(cleanPath, "/")
-	ancestors := []string{}
-	newPath := "/"
-	ancestors = append(ancestors, newPath)
-	for _, v := range components[1:] {
-		newPath = filepath.Join(newPath, v)
-		ancestors = append(ancestors, newPath)
+// deviceInotifyDevicePath returns the host path a hotplugged unix device waits
+// for.
+func deviceInotifyDevicePath(m config.Device) string {
+	srcPath := m["source"]
+	if srcPath == "" {
+		srcPath = m["path"]
 	}
 
-	return ancestors
+	return filepath.Clean(shared.HostPath(srcPath))
 }
 
-func deviceInotifyEvent(s *state.State, target *sys.InotifyTargetInfo) {
-	if (target.Mask & unix.IN_ISDIR) > 0 {
-		if (target.Mask & unix.IN_CREATE) > 0 {
-			deviceInotifyDirCreateEvent(s, target)
-		} else if (target.Mask & unix.IN_DELETE) > 0 {
-			deviceInotifyDirDeleteEvent(s, target)
-		}
-		deviceInotifyDirRescan(s)
-	} else if (target.Mask & unix.IN_DELETE_SELF) > 0 {
-		deviceInotifyDirDeleteEvent(s, target)
-		deviceInotifyDirRescan(s)
-	} else {
-		deviceInotifyFileEvent(s, target)
+// deviceInotifyOptional returns whether a device is a unix device which may be
+// hotplugged.
+func deviceInotifyOptional(m config.Device) bool {
+	if !shared.StringInSlice(m["type"], []string{"unix-char", "unix-block"}) {
+		return false
 	}
+
+	return m["required"] != "" && !shared.IsTrue(m["required"])
 }
 
-func deviceInotifyDirDeleteEvent(s *state.State, target *sys.InotifyTargetInfo) {
-	parentKey := fmt.Sprintf("\000:%d", target.Wd)
-	s.OS.InotifyWatch.RLock()
-	parent, ok := s.OS.InotifyWatch.Targets[parentKey]
-	s.OS.InotifyWatch.RUnlock()
-	if !ok {
-		return
-	}
+// deviceInotifyPathsUnder returns the indexed paths which are the given path or
+// below it.
+func deviceInotifyPathsUnder(index map[string][]sys.InotifyWatcher, path string) []string {
+	prefix := strings.TrimSuffix(path, "/") + "/"
 
-	// The absolute path of the file for which we received an event?
-	targetName := filepath.Join(parent.Path, target.Path)
-	targetName = filepath.Clean(targetName)
-	err := deviceInotifyDelWatcher(s, targetName)
-	if err != nil {
-		logger.Errorf("Failed to remove \"%s\" from inotify targets: %s", targetName, err)
-	} else {
-		logger.Errorf("Removed \"%s\" from inotify targets", targetName)
+	paths := []string{}
+	for indexPath := range index {
+		if indexPath == path || strings.HasPrefix(indexPath, prefix) {
+			paths = append(paths, indexPath)
+		}
 	}
+
+	sort.Strings(paths)
+	return paths
 }
 
-func deviceInotifyDirRescan(s *state.State) {
-	containers, err := containerLoadNodeAll(s)
-	if err != nil {
-		logger.Errorf("Failed to load containers: %s", err)
-		return
+// deviceInotifyIndexAdd adds a watcher for the given path to the index, unless
+// it's already there.
+func deviceInotifyIndexAdd(index map[string][]sys.InotifyWatcher, path string, watcher sys.InotifyWatcher) {
+	for _, w := range index[path] {
+		if w == watcher {
+			return
+		}
 	}
 
-	for _, containerIf := range containers {
-		c, ok := containerIf.(*containerLXC)
-		if !ok {
-			logger.Errorf("Received device event on non-LXC container")
-			return
+	index[path] = append(index[path], watcher)
+}
+
+// deviceInotifyIndexDel removes the watchers matching the filter from the
+// index, along with the paths nothing waits for anymore, which it returns.
+func deviceInotifyIndexDel(index map[string][]sys.InotifyWatcher, filter func(sys.InotifyWatcher) bool) []string {
+	removed := []string{}
+	for path, watchers := range index {
+		kept := []sys.InotifyWatcher{}
+		for _, w := range watchers {
+			if !filter(w) {
+				kept = append(kept, w)
+			}
 		}
 
-		if !c.IsRunning() {
-			continue
+		if len(kept) == 0 {
+			delete(index, path)
+			removed = append(removed, path)
+		} else {
+			index[path] = kept
 		}
+	}
 
-		devices := c.ExpandedDevices()
-		for _, name := range devices.DeviceNames() {
-			m := devices[name]
-			if !shared.StringInSlice(m["type"], []string{"unix-char", "unix-block"}) {
-				continue
-			}
+	sort.Strings(removed)
+	return removed
+}
 
-			if m["required"] == "" || shared.IsTrue(m["required"]) {
-				continue
-			}
+// deviceInotifyAddDevice registers a unix device of a container as waiting for
+// the given path, and watches the closest existing directory above it.
+func deviceInotifyAddDevice(s *state.State, id int, name string, path string) error {
+	cleanPath := filepath.Clean(path)
 
-			cmp := m["source"]
-			if cmp == "" {
-				cmp = m["path"]
-			}
-			cleanDevPath := filepath.Clean(cmp)
-			if shared.PathExists(cleanDevPath) {
-				c.insertUnixDevice(fmt.Sprintf("unix.%s", name), m, false)
-			} else {
-				c.removeUnixDevice(fmt.Sprintf("unix.%s", name), m, true)
-			}
+	s.OS.InotifyWatch.Lock()
+	deviceInotifyIndexAdd(s.OS.InotifyWatch.Devices, cleanPath, sys.InotifyWatcher{ContainerID: id, Device: name})
+	s.OS.InotifyWatch.Unlock()
 
-			// and add its nearest existing ancestor.
-			err = deviceInotifyAddClosestLivingAncestor(s, cleanDevPath)
-			if err != nil {
-				logger.Errorf("Failed to add \"%s\" to inotify targets: %s", cleanDevPath, err)
-			} else {
-				logger.Debugf("Added \"%s\" to inotify targets", cleanDevPath)
-			}
+	return deviceInotifyAddClosestLivingAncestor(s, filepath.Dir(cleanPath))
+}
+
+// deviceInotifyDelDevice forgets a unix device of a container. An empty name
+// forgets all the devices of the container. The directories watched for the
+// paths nothing waits for anymore stop being watched.
+func deviceInotifyDelDevice(s *state.State, id int, name string) {
+	s.OS.InotifyWatch.Lock()
+	removed := deviceInotifyIndexDel(s.OS.InotifyWatch.Devices, func(w sys.InotifyWatcher) bool {
+		return w.ContainerID == id && (name == "" || w.Device == name)
+	})
+	s.OS.InotifyWatch.Unlock()
+
+	dirs := []string{}
+	for _, path := range removed {
+		exists, dir := findClosestLivingAncestor(filepath.Dir(path))
+		if exists && !shared.StringInSlice(dir, dirs) {
+			dirs = append(dirs, dir)
 		}
 	}
+
+	for _, dir := range dirs {
+		deviceInotifyPrune(s, dir)
+	}
 }
 
-func deviceInotifyDirCreateEvent(s *state.State, target *sys.InotifyTargetInfo) {
-	parentKey := fmt.Sprintf("\000:%d", target.Wd)
+// deviceInotifyWatchers returns a copy of the watchers waiting for a path.
+func deviceInotifyWatchers(s *state.State, path string) []sys.InotifyWatcher {
 	s.OS.InotifyWatch.RLock()
-	parent, ok := s.OS.InotifyWatch.Targets[parentKey]
-	s.OS.InotifyWatch.RUnlock()
-	if !ok {
-		return
-	}
+	defer s.OS.InotifyWatch.RUnlock()
 
-	containers, err := containerLoadNodeAll(s)
-	if err != nil {
-		logger.Errorf("Failed to load containers: %s", err)
-		return
-	}
+	return append([]sys.InotifyWatcher{}, s.OS.InotifyWatch.Devices[path]...)
+}
 
-	// The absolute path of the file for which we received an event?
-	targetName := filepath.Join(parent.Path, target.Path)
-	targetName = filepath.Clean(targetName)
+// deviceInotifyDispatch inserts or removes the unix devices waiting for a path
+// in their containers, leaving all other containers alone.
+func deviceInotifyDispatch(s *state.State, path string, add bool) {
+	for _, watcher := range deviceInotifyWatchers(s, path) {
+		containerIf, err := containerLoadById(s, watcher.ContainerID)
+		if err != nil {
+			// The container is gone
+			deviceInotifyDelDevice(s, watcher.ContainerID, "")
+			continue
+		}
 
-	// ancestors
-	del := createAncestorPaths(targetName)
-	keep := []string{}
-	for _, containerIf := range containers {
 		c, ok := containerIf.(*containerLXC)
 		if !ok {
 			logger.Errorf("Received device event on non-LXC container")
-			return
+			deviceInotifyDelDevice(s, watcher.ContainerID, "")
+			continue
 		}
 
 		if !c.IsRunning() {
+			deviceInotifyDelDevice(s, watcher.ContainerID, "")
 			continue
 		}
 
-		devices := c.ExpandedDevices()
-		for _, name := range devices.DeviceNames() {
-			m := devices[name]
-			if !shared.StringInSlice(m["type"], []string{"unix-char", "unix-block"}) {
-				continue
-			}
+		// The device may have changed since it was registered
+		m, ok := c.ExpandedDevices()[watcher.Device]
+		if !ok || !deviceInotifyOptional(m) || deviceInotifyDevicePath(m) != path {
+			deviceInotifyDelDevice(s, watcher.ContainerID, watcher.Device)
+			continue
+		}
 
-			if m["required"] == "" || shared.IsTrue(m["required"]) {
-				continue
-			}
+		prefix := fmt.Sprintf("unix.%s", watcher.Device)
+		destPath := m["path"]
+		if destPath == "" {
+			destPath = m["source"]
+		}
 
-			cmp := m["source"]
-			if cmp == "" {
-				cmp = m["path"]
+		exists := device.UnixDeviceExists(c.DevicesPath(), prefix, destPath)
+		if add && !exists {
+			err := c.insertUnixDevice(prefix, m, false)
+			containerDevicesLogAdd(c, watcher.Device, m, "add", "hotplug", err)
+			if err != nil {
+				logger.Error("Failed to create unix device", log.Ctx{"err": err, "dev": m, "container": c.Name()})
 			}
-			cleanDevPath := filepath.Clean(cmp)
-
-			for i := len(del) - 1; i >= 0; i-- {
-				// Only keep paths that can be deleted.
-				if strings.HasPrefix(cleanDevPath, del[i]) {
-					if shared.StringInSlice(del[i], keep) {
-						break
-					}
-
-					keep = append(keep, del[i])
-					break
-				}
+		} else if !add && exists {
+			err := c.removeUnixDevice(prefix, m, true)
+			containerDevicesLogAdd(c, watcher.Device, m, "remove", "hotplug", err)
+			if err != nil {
+				logger.Error("Failed to remove unix device", log.Ctx{"err": err, "dev": m, "container": c.Name()})
 			}
 		}
 	}
+}
 
-	for i, v := range del {
-		if shared.StringInSlice(v, keep) {
-			del[i] = ""
+// deviceInotifyPrune stops watching a directory when no registered device
+// waits for a path whose closest existing directory it is.
+func deviceInotifyPrune(s *state.State, dir string) {
+	s.OS.InotifyWatch.RLock()
+	paths := deviceInotifyPathsUnder(s.OS.InotifyWatch.Devices, dir)
+	s.OS.InotifyWatch.RUnlock()
+
+	for _, path := range paths {
+		_, ancestor := findClosestLivingAncestor(filepath.Dir(path))
+		if ancestor == dir {
+			return
 		}
 	}
 
-	for _, v := range del {
-		if v == "" {
-			continue
-		}
+	err := deviceInotifyDelWatcher(s, dir)
+	if err != nil {
+		logger.Errorf("Failed to remove \"%s\" from inotify targets: %s", dir, err)
+	} else {
+		logger.Debugf("Removed \"%s\" from inotify targets", dir)
+	}
+}
 
-		err := deviceInotifyDelWatcher(s, v)
-		if err != nil {
-			logger.Errorf("Failed to remove \"%s\" from inotify targets: %s", v, err)
-		} else {
-			logger.Debugf("Removed \"%s\" from inotify targets", v)
-		}
+func deviceInotifyEvent(s *state.State, target *sys.InotifyTargetInfo) {
+	parentKey := fmt.Sprintf("\000:%d", target.Wd)
+	s.OS.InotifyWatch.RLock()
+	parent, ok := s.OS.InotifyWatch.Targets[parentKey]
+	s.OS.InotifyWatch.RUnlock()
+	if !ok {
+		return
 	}
 
-	for _, v := range keep {
-		if v == "" {
-			continue
-		}
+	// The absolute path of the file for which we received an event?
+	targetName := filepath.Clean(filepath.Join(parent.Path, target.Path))
+
+	if (target.Mask&unix.IN_ISDIR) > 0 || (target.Mask&unix.IN_DELETE_SELF) > 0 {
+		deviceInotifyDirEvent(s, parent.Path, targetName, target.Mask)
+	} else if (target.Mask & unix.IN_CREATE) > 0 {
+		deviceInotifyDispatch(s, targetName, true)
+	} else if (target.Mask & unix.IN_DELETE) > 0 {
+		deviceInotifyDispatch(s, targetName, false)
+	}
+}
 
-		err = deviceInotifyAddClosestLivingAncestor(s, v)
+// deviceInotifyDirEvent moves the watches of the devices below a directory
+// which got created or deleted, and catches up with the devices which appeared
+// or disappeared along with it.
+func deviceInotifyDirEvent(s *state.State, parent string, dir string, mask uint32) {
+	if (mask&unix.IN_DELETE) > 0 || (mask&unix.IN_DELETE_SELF) > 0 {
+		err := deviceInotifyDelWatcher(s, dir)
 		if err != nil {
-			logger.Errorf("Failed to add \"%s\" to inotify targets: %s", v, err)
+			logger.Errorf("Failed to remove \"%s\" from inotify targets: %s", dir, err)
 		} else {
-			logger.Debugf("Added \"%s\" to inotify targets", v)
+			logger.Debugf("Removed \"%s\" from inotify targets", dir)
 		}
 	}
-}
 
-func deviceInotifyFileEvent(s *state.State, target *sys.InotifyTargetInfo) {
-	parentKey := fmt.Sprintf("\000:%d", target.Wd)
 	s.OS.InotifyWatch.RLock()
-	parent, ok := s.OS.InotifyWatch.Targets[parentKey]
+	paths := deviceInotifyPathsUnder(s.OS.InotifyWatch.Devices, dir)
 	s.OS.InotifyWatch.RUnlock()
-	if !ok {
+
+	if len(paths) == 0 {
 		return
 	}
 
+	for _, path := range paths {
+		err := deviceInotifyAddClosestLivingAncestor(s, filepath.Dir(path))
+		if err != nil {
+			logger.Errorf("Failed to add \"%s\" to inotify targets: %s", path, err)
+		}
+
+		// Creating the watch races with what happens in the directory
+		deviceInotifyDispatch(s, path, shared.PathExists(path))
+	}
+
+	if (mask & unix.IN_CREATE) > 0 {
+		deviceInotifyPrune(s, parent)
+	}
+}
+
+// deviceInotifyDirRescan registers the hotplugged unix devices of all the
+// running containers, which is only needed when LXD starts.
+func deviceInotifyDirRescan(s *state.State) {
 	containers, err := containerLoadNodeAll(s)
 	if err != nil {
 		logger.Errorf("Failed to load containers: %s", err)
 		return
 	}
 
-	// Does the current file have watchers?
-	hasWatchers := false
-	// The absolute path of the file for which we received an event?
-	targetName := filepath.Join(parent.Path, target.Path)
-	for _, containerIf := range containers {
-		c, ok := containerIf.(*containerLXC)
-		if !ok {
-			logger.Errorf("Received device event on non-LXC container")
-			return
-		}
-
+	for _, c := range containers {
 		if !c.IsRunning() {
 			continue
 		}
@@ -1369,53 +1658,18 @@ func deviceInotifyFileEvent(s *state.State, target *sys.InotifyTargetInfo) {
 		devices := c.ExpandedDevices()
 		for _, name := range devices.DeviceNames() {
 			m := devices[name]
-			if !shared.StringInSlice(m["type"], []string{"unix-char", "unix-block"}) {
-				continue
-			}
-
-			cmp := m["source"]
-			if cmp == "" {
-				cmp = m["path"]
-			}
-
-			if m["required"] == "" || shared.IsTrue(m["required"]) {
+			if !deviceInotifyOptional(m) {
 				continue
 			}
 
-			cleanDevPath := filepath.Clean(cmp)
-			cleanInotPath := filepath.Clean(targetName)
-			if !hasWatchers && strings.HasPrefix(cleanDevPath, cleanInotPath) {
-				hasWatchers = true
-			}
-
-			if cleanDevPath != cleanInotPath {
+			path := deviceInotifyDevicePath(m)
+			err = deviceInotifyAddDevice(s, c.Id(), name, path)
+			if err != nil {
+				logger.Errorf("Failed to add \"%s\" to inotify targets: %s", path, err)
 				continue
 			}
 
-			if (target.Mask & unix.IN_CREATE) > 0 {
-				err := c.insertUnixDevice(fmt.Sprintf("unix.%s", name), m, false)
-				if err != nil {
-					logger.Error("Failed to create unix device", log.Ctx{"err": err, "dev": m, "container": c.Name()})
-					continue
-				}
-			} else if (target.Mask & unix.IN_DELETE) > 0 {
-				err := c.removeUnixDevice(fmt.Sprintf("unix.%s", name), m, true)
-				if err != nil {
-					logger.Error("Failed to remove unix device", log.Ctx{"err": err, "dev": m, "container": c.Name()})
-					continue
-				}
-			} else {
-				logger.Error("Uknown action for unix device", log.Ctx{"dev": m, "container": c.Name()})
-			}
-		}
-	}
-
-	if !hasWatchers {
-		err := deviceInotifyDelWatcher(s, targetName)
-		if err != nil {
-			logger.Errorf("Failed to remove \"%s\" from inotify targets: %s", targetName, err)
-		} else {
-			logger.Debugf("Removed \"%s\" from inotify targets", targetName)
+			deviceInotifyDispatch(s, path, shared.PathExists(path))
 		}
 	}
 }
@@ -1426,6 +1680,9 @@ func deviceInotifyHandler(s *state.State) {
 		return
 	}
 
+	atomic.StoreInt32(&deviceInotifyHandlerRunning, 1)
+	defer atomic.StoreInt32(&deviceInotifyHandlerRunning, 0)
+
 	for {
 		select {
 		case v := <-watchChan:
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/lxc/lxd/lxd/device"
+	"github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// The hotplug devices of the running containers, by container ID, so that the
+// uevents only load the containers interested in them.
+var hotplugIndex = map[int][]string{}
+var hotplugIndexLock sync.Mutex
+
+// The keys of a hotplug device matched against the host devices, as shell
+// patterns.
+var hotplugMatchKeys = []string{"subsystem", "vendorid", "productid", "serial", "devname"}
+
+// hotplugDevice is a host unix device, as reported by a uevent or found in
+// sysfs. The vendor, product and serial are those of the closest USB or PCI
+// device it belongs to, they're unknown on removal.
+type hotplugDevice struct {
+	action string
+
+	subsystem string
+	vendor    string
+	product   string
+	serial    string
+	devname   string
+
+	path        string
+	block       bool
+	major       int
+	minor       int
+	ueventParts []string
+	ueventLen   int
+}
+
+func createHotplugDevice(props map[string]string, ueventParts []string, ueventLen int) (hotplugDevice, error) {
+	major, err := strconv.Atoi(props["MAJOR"])
+	if err != nil {
+		return hotplugDevice{}, err
+	}
+
+	minor, err := strconv.Atoi(props["MINOR"])
+	if err != nil {
+		return hotplugDevice{}, err
+	}
+
+	dev := hotplugDevice{
+		action:      props["ACTION"],
+		subsystem:   props["SUBSYSTEM"],
+		devname:     props["DEVNAME"],
+		path:        props["DEVNAME"],
+		block:       props["SUBSYSTEM"] == "block",
+		major:       major,
+		minor:       minor,
+		ueventParts: ueventParts,
+		ueventLen:   ueventLen,
+	}
+
+	if !filepath.IsAbs(dev.path) {
+		dev.path = fmt.Sprintf("/dev/%s", dev.devname)
+	} else {
+		dev.devname = strings.TrimPrefix(dev.path, "/dev/")
+	}
+
+	if dev.action != "remove" && props["DEVPATH"] != "" {
+		dev.vendor, dev.product, dev.serial = hotplugDeviceAttributes(filepath.Join("/sys", props["DEVPATH"]))
+	}
+
+	return dev, nil
+}
+
+// hotplugDeviceAttributes looks for the vendor, product and serial of a device
+// in its sysfs directory and those of its parents.
+func hotplugDeviceAttributes(sysPath string) (string, string, string) {
+	read := func(dir string, name string) string {
+		content, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return ""
+		}
+
+		return strings.TrimPrefix(strings.TrimSpace(string(content)), "0x")
+	}
+
+	for dir := sysPath; strings.HasPrefix(dir, "/sys/devices/"); dir = filepath.Dir(dir) {
+		// USB devices
+		if shared.PathExists(filepath.Join(dir, "idVendor")) {
+			return read(dir, "idVendor"), read(dir, "idProduct"), read(dir, "serial")
+		}
+
+		// PCI devices
+		if shared.PathExists(filepath.Join(dir, "vendor")) && shared.PathExists(filepath.Join(dir, "device")) {
+			return read(dir, "vendor"), read(dir, "device"), ""
+		}
+	}
+
+	return "", "", ""
+}
+
+// hotplugDeviceMatch returns whether a host device matches all the patterns
+// of a hotplug device.
+func hotplugDeviceMatch(m config.Device, dev hotplugDevice) bool {
+	values := map[string]string{
+		"subsystem": dev.subsystem,
+		"vendorid":  dev.vendor,
+		"productid": dev.product,
+		"serial":    dev.serial,
+		"devname":   dev.devname,
+	}
+
+	for _, key := range hotplugMatchKeys {
+		if m[key] == "" {
+			continue
+		}
+
+		match, err := filepath.Match(m[key], values[key])
+		if err != nil || !match {
+			return false
+		}
+	}
+
+	return true
+}
+
+// hotplugDeviceValidate checks the patterns of a hotplug device.
+func hotplugDeviceValidate(m config.Device) error {
+	empty := true
+	for _, key := range hotplugMatchKeys {
+		if m[key] == "" {
+			continue
+		}
+
+		empty = false
+		_, err := filepath.Match(m[key], "")
+		if err != nil {
+			return fmt.Errorf("Invalid pattern for %s: %s", key, m[key])
+		}
+	}
+
+	if empty {
+		return fmt.Errorf("Hotplug devices require at least one of %s", strings.Join(hotplugMatchKeys, ", "))
+	}
+
+	return nil
+}
+
+// hotplugDeviceConfig returns the config of the unix device to create in a
+// container for a matched host device.
+func hotplugDeviceConfig(m config.Device, dev hotplugDevice) config.Device {
+	unixDev := config.Device{"type": "unix-char"}
+	if dev.block {
+		unixDev["type"] = "unix-block"
+	}
+
+	for _, key := range []string{"mode", "uid", "gid"} {
+		if m[key] != "" {
+			unixDev[key] = m[key]
+		}
+	}
+
+	return unixDev
+}
+
+// deviceLoadHotplug lists the unix devices of the host.
+func deviceLoadHotplug() ([]hotplugDevice, error) {
+	result := []hotplugDevice{}
+
+	for _, kind := range []string{"char", "block"} {
+		ents, err := ioutil.ReadDir(filepath.Join("/sys/dev", kind))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		for _, ent := range ents {
+			sysPath, err := filepath.EvalSymlinks(filepath.Join("/sys/dev", kind, ent.Name()))
+			if err != nil {
+				continue
+			}
+
+			content, err := ioutil.ReadFile(filepath.Join(sysPath, "uevent"))
+			if err != nil {
+				continue
+			}
+
+			props := map[string]string{"ACTION": "add"}
+			for _, line := range strings.Split(string(content), "\n") {
+				fields := strings.SplitN(line, "=", 2)
+				if len(fields) == 2 {
+					props[fields[0]] = fields[1]
+				}
+			}
+
+			if props["DEVNAME"] == "" {
+				continue
+			}
+
+			subsystem, err := os.Readlink(filepath.Join(sysPath, "subsystem"))
+			if err == nil {
+				props["SUBSYSTEM"] = filepath.Base(subsystem)
+			}
+
+			props["DEVPATH"] = strings.TrimPrefix(sysPath, "/sys")
+
+			dev, err := createHotplugDevice(props, []string{}, 0)
+			if err != nil {
+				continue
+			}
+
+			result = append(result, dev)
+		}
+	}
+
+	return result, nil
+}
+
+// hotplugIndexAdd registers a hotplug device of a running container.
+func hotplugIndexAdd(id int, name string) {
+	hotplugIndexLock.Lock()
+	defer hotplugIndexLock.Unlock()
+
+	if !shared.StringInSlice(name, hotplugIndex[id]) {
+		hotplugIndex[id] = append(hotplugIndex[id], name)
+	}
+}
+
+// hotplugIndexDel forgets a hotplug device of a container. An empty name
+// forgets all the devices of the container.
+func hotplugIndexDel(id int, name string) {
+	hotplugIndexLock.Lock()
+	defer hotplugIndexLock.Unlock()
+
+	kept := []string{}
+	for _, n := range hotplugIndex[id] {
+		if name != "" && n != name {
+			kept = append(kept, n)
+		}
+	}
+
+	if len(kept) == 0 {
+		delete(hotplugIndex, id)
+	} else {
+		hotplugIndex[id] = kept
+	}
+}
+
+// hotplugIndexGet returns a copy of the index.
+func hotplugIndexGet() map[int][]string {
+	hotplugIndexLock.Lock()
+	defer hotplugIndexLock.Unlock()
+
+	index := map[int][]string{}
+	for id, names := range hotplugIndex {
+		index[id] = append([]string{}, names...)
+	}
+
+	return index
+}
+
+// deviceHotplugRescan registers the hotplug devices of all the running
+// containers, which is only needed when LXD starts.
+func deviceHotplugRescan(s *state.State) {
+	containers, err := containerLoadNodeAll(s)
+	if err != nil {
+		logger.Errorf("Failed to load containers: %s", err)
+		return
+	}
+
+	for _, c := range containers {
+		if !c.IsRunning() {
+			continue
+		}
+
+		devices := c.ExpandedDevices()
+		for _, name := range devices.DeviceNames() {
+			if devices[name]["type"] == "hotplug" {
+				hotplugIndexAdd(c.Id(), name)
+			}
+		}
+	}
+}
+
+func deviceHotplugEvent(s *state.State, dev hotplugDevice) {
+	index := hotplugIndexGet()
+
+	ids := []int{}
+	for id := range index {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	for _, id := range ids {
+		containerIf, err := containerLoadById(s, id)
+		if err != nil {
+			// The container is gone
+			hotplugIndexDel(id, "")
+			continue
+		}
+
+		c, ok := containerIf.(*containerLXC)
+		if !ok {
+			logger.Errorf("Got device event on non-LXC container?")
+			hotplugIndexDel(id, "")
+			continue
+		}
+
+		// The container may still be starting, it's forgotten when it stops
+		if !c.IsRunning() {
+			continue
+		}
+
+		devices := c.ExpandedDevices()
+		for _, name := range index[id] {
+			// The device may have changed since it was registered
+			m, ok := devices[name]
+			if !ok || m["type"] != "hotplug" {
+				hotplugIndexDel(id, name)
+				continue
+			}
+
+			prefix := fmt.Sprintf("unix.%s", name)
+
+			if dev.action == "add" {
+				if !hotplugDeviceMatch(m, dev) {
+					continue
+				}
+
+				err := c.insertUnixDeviceNum(prefix, hotplugDeviceConfig(m, dev), dev.major, dev.minor, dev.path, false)
+				containerDevicesLogAdd(c, name, m, "add", "hotplug", err)
+				if err != nil {
+					logger.Error("Failed to create hotplug device", log.Ctx{"err": err, "path": dev.path, "container": c.Name()})
+					continue
+				}
+			} else if dev.action == "remove" {
+				// The device is gone, so rely on what was inserted
+				if !device.UnixDeviceExists(c.DevicesPath(), prefix, dev.path) {
+					continue
+				}
+
+				err := c.removeUnixDeviceNum(prefix, hotplugDeviceConfig(m, dev), dev.major, dev.minor, dev.path)
+				containerDevicesLogAdd(c, name, m, "remove", "hotplug", err)
+				if err != nil {
+					logger.Error("Failed to remove hotplug device", log.Ctx{"err": err, "path": dev.path, "container": c.Name()})
+					continue
+				}
+			}
+
+			ueventArray := make([]string, 4)
+			ueventArray[0] = "forkuevent"
+			ueventArray[1] = "inject"
+			ueventArray[2] = fmt.Sprintf("%d", c.InitPID())
+			ueventArray[3] = fmt.Sprintf("%d", dev.ueventLen)
+			ueventArray = append(ueventArray, dev.ueventParts...)
+			shared.RunCommand(s.OS.ExecPath, ueventArray...)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lxc/lxd/lxd/device/config"
+)
+
+func TestHotplugDeviceMatch(t *testing.T) {
+	dev := hotplugDevice{
+		action:    "add",
+		subsystem: "tty",
+		vendor:    "0403",
+		product:   "6001",
+		serial:    "A50285BI",
+		devname:   "ttyUSB0",
+		path:      "/dev/ttyUSB0",
+	}
+
+	cases := []struct {
+		rule  config.Device
+		match bool
+	}{
+		{config.Device{"subsystem": "tty"}, true},
+		{config.Device{"subsystem": "tty", "vendorid": "0403", "productid": "6001"}, true},
+		{config.Device{"devname": "ttyUSB*"}, true},
+		{config.Device{"devname": "ttyACM*"}, false},
+		{config.Device{"vendorid": "0403", "serial": "A50285BI"}, true},
+		{config.Device{"vendorid": "0403", "serial": "OTHER"}, false},
+		{config.Device{"subsystem": "block", "vendorid": "0403"}, false},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.match, hotplugDeviceMatch(c.rule, dev), "rule %v", c.rule)
+	}
+
+	assert.Error(t, hotplugDeviceValidate(config.Device{"type": "hotplug"}))
+	assert.Error(t, hotplugDeviceValidate(config.Device{"type": "hotplug", "devname": "tty[USB"}))
+	assert.NoError(t, hotplugDeviceValidate(config.Device{"type": "hotplug", "devname": "ttyUSB*"}))
+}
+
+func TestHotplugIndex(t *testing.T) {
+	defer hotplugIndexDel(1, "")
+	defer hotplugIndexDel(2, "")
+
+	hotplugIndexAdd(1, "serial")
+	hotplugIndexAdd(1, "serial")
+	hotplugIndexAdd(1, "disks")
+	hotplugIndexAdd(2, "serial")
+	assert.Equal(t, map[int][]string{1: {"serial", "disks"}, 2: {"serial"}}, hotplugIndexGet())
+
+	hotplugIndexDel(1, "serial")
+	assert.Equal(t, map[int][]string{1: {"disks"}, 2: {"serial"}}, hotplugIndexGet())
+
+	hotplugIndexDel(2, "")
+	hotplugIndexDel(1, "disks")
+	assert.Equal(t, map[int][]string{}, hotplugIndexGet())
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/lxd/sys"
+)
+
+func TestDeviceInotifyIndex(t *testing.T) {
+	index := map[string][]sys.InotifyWatcher{}
+
+	c1tty := sys.InotifyWatcher{ContainerID: 1, Device: "tty"}
+	c1dri := sys.InotifyWatcher{ContainerID: 1, Device: "dri"}
+	c2tty := sys.InotifyWatcher{ContainerID: 2, Device: "tty"}
+
+	deviceInotifyIndexAdd(index, "/dev/ttyUSB0", c1tty)
+	deviceInotifyIndexAdd(index, "/dev/ttyUSB0", c1tty)
+	deviceInotifyIndexAdd(index, "/dev/ttyUSB0", c2tty)
+	deviceInotifyIndexAdd(index, "/dev/dri/card0", c1dri)
+	assert.Equal(t, []sys.InotifyWatcher{c1tty, c2tty}, index["/dev/ttyUSB0"])
+
+	assert.Equal(t, []string{"/dev/dri/card0", "/dev/ttyUSB0"}, deviceInotifyPathsUnder(index, "/dev"))
+	assert.Equal(t, []string{"/dev/dri/card0"}, deviceInotifyPathsUnder(index, "/dev/dri"))
+	assert.Equal(t, []string{"/dev/dri/card0", "/dev/ttyUSB0"}, deviceInotifyPathsUnder(index, "/"))
+	assert.Equal(t, []string{}, deviceInotifyPathsUnder(index, "/dev/dr"))
+
+	removed := deviceInotifyIndexDel(index, func(w sys.InotifyWatcher) bool {
+		return w.ContainerID == 1
+	})
+	assert.Equal(t, []string{"/dev/dri/card0"}, removed)
+	assert.Equal(t, map[string][]sys.InotifyWatcher{"/dev/ttyUSB0": {c2tty}}, index)
+
+	removed = deviceInotifyIndexDel(index, func(w sys.InotifyWatcher) bool {
+		return w.ContainerID == 2
+	})
+	assert.Equal(t, []string{"/dev/ttyUSB0"}, removed)
+	assert.Equal(t, map[string][]sys.InotifyWatcher{}, index)
+}
+
+func TestDeviceInotifyOptional(t *testing.T) {
+	assert.True(t, deviceInotifyOptional(config.Device{"type": "unix-char", "path": "/dev/ttyUSB0", "required": "false"}))
+	assert.False(t, deviceInotifyOptional(config.Device{"type": "unix-char", "path": "/dev/ttyUSB0"}))
+	assert.False(t, deviceInotifyOptional(config.Device{"type": "unix-block", "path": "/dev/sdb", "required": "true"}))
+	assert.False(t, deviceInotifyOptional(config.Device{"type": "disk", "path": "/mnt", "required": "false"}))
+
+	assert.Equal(t, "/dev/ttyUSB0", deviceInotifyDevicePath(config.Device{"source": "/dev/ttyUSB0/", "path": "/dev/ttyS0"}))
+	assert.Equal(t, "/dev/ttyS0", deviceInotifyDevicePath(config.Device{"path": "/dev/ttyS0"}))
+}
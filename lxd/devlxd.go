@@ -19,8 +19,10 @@ import (
 	"github.com/gorilla/websocket"
 	"github.com/pborman/uuid"
 
+	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/util"
 	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/logger"
 	"github.com/lxc/lxd/shared/version"
 )
@@ -97,6 +99,165 @@ var devlxdImageExport = devLxdHandler{"/1.0/images/{fingerprint}/export", func(d
 	return &devLxdResponse{"", http.StatusOK, "raw"}
 }}
 
+// devlxdManagementAllowed checks whether the container may manage itself
+// through devlxd.
+func devlxdManagementAllowed(c container) *devLxdResponse {
+	if !shared.IsTrue(c.ExpandedConfig()["security.devlxd.management"]) {
+		return &devLxdResponse{"not authorized", http.StatusForbidden, "raw"}
+	}
+
+	return nil
+}
+
+var devlxdSnapshots = devLxdHandler{"/1.0/snapshots", func(d *Daemon, c container, w http.ResponseWriter, r *http.Request) *devLxdResponse {
+	resp := devlxdManagementAllowed(c)
+	if resp != nil {
+		return resp
+	}
+
+	switch r.Method {
+	case "GET":
+		snaps, err := c.Snapshots()
+		if err != nil {
+			return &devLxdResponse{"internal server error", http.StatusInternalServerError, "raw"}
+		}
+
+		names := []string{}
+		for _, snap := range snaps {
+			_, snapName, _ := containerGetParentAndSnapshotName(snap.Name())
+			names = append(names, fmt.Sprintf("/1.0/snapshots/%s", snapName))
+		}
+
+		return okResponse(names, "json")
+	case "POST":
+		req := api.ContainerSnapshotsPost{}
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			return &devLxdResponse{"bad request", http.StatusBadRequest, "raw"}
+		}
+
+		if req.Name == "" {
+			req.Name, err = containerDetermineNextSnapshotName(d, c, "snap%d")
+			if err != nil {
+				return &devLxdResponse{"internal server error", http.StatusInternalServerError, "raw"}
+			}
+		}
+
+		if strings.Contains(req.Name, "/") {
+			return &devLxdResponse{"snapshot names may not contain slashes", http.StatusBadRequest, "raw"}
+		}
+
+		expiry, err := shared.GetSnapshotExpiry(time.Now(), c.ExpandedConfig()["snapshots.expiry"])
+		if err != nil {
+			return &devLxdResponse{"internal server error", http.StatusInternalServerError, "raw"}
+		}
+
+		snapshot := func(op *operation) error {
+			args := db.ContainerArgs{
+				Project:      c.Project(),
+				Architecture: c.Architecture(),
+				Config:       c.LocalConfig(),
+				Ctype:        db.CTypeSnapshot,
+				Devices:      c.LocalDevices(),
+				Ephemeral:    c.IsEphemeral(),
+				Name:         c.Name() + shared.SnapshotDelimiter + req.Name,
+				Profiles:     c.Profiles(),
+				Stateful:     req.Stateful,
+				ExpiryDate:   expiry,
+			}
+
+			_, err := containerCreateAsSnapshot(d.State(), args, c)
+			return err
+		}
+
+		// Wait for the snapshot so the workload knows when it's safe to
+		// resume writing.
+		err = devlxdRunOperation(d, c, db.OperationSnapshotCreate, snapshot, true)
+		if err != nil {
+			return &devLxdResponse{err.Error(), http.StatusInternalServerError, "raw"}
+		}
+
+		return okResponse(fmt.Sprintf("/1.0/snapshots/%s", req.Name), "raw")
+	}
+
+	return &devLxdResponse{"method not allowed", http.StatusMethodNotAllowed, "raw"}
+}}
+
+var devlxdSnapshotRestore = devLxdHandler{"/1.0/snapshots/{name}/restore", func(d *Daemon, c container, w http.ResponseWriter, r *http.Request) *devLxdResponse {
+	resp := devlxdManagementAllowed(c)
+	if resp != nil {
+		return resp
+	}
+
+	if r.Method != "POST" {
+		return &devLxdResponse{"method not allowed", http.StatusMethodNotAllowed, "raw"}
+	}
+
+	name := mux.Vars(r)["name"]
+	_, err := containerLoadByProjectAndName(d.State(), c.Project(), c.Name()+shared.SnapshotDelimiter+name)
+	if err != nil {
+		return &devLxdResponse{"not found", http.StatusNotFound, "raw"}
+	}
+
+	restore := func(op *operation) error {
+		return containerSnapRestore(d.State(), c.Project(), c.Name(), name, false)
+	}
+
+	// Restoring stops the container, so don't wait for it to complete
+	err = devlxdRunOperation(d, c, db.OperationSnapshotRestore, restore, false)
+	if err != nil {
+		return &devLxdResponse{"internal server error", http.StatusInternalServerError, "raw"}
+	}
+
+	return okResponse("", "raw")
+}}
+
+var devlxdRestart = devLxdHandler{"/1.0/restart", func(d *Daemon, c container, w http.ResponseWriter, r *http.Request) *devLxdResponse {
+	resp := devlxdManagementAllowed(c)
+	if resp != nil {
+		return resp
+	}
+
+	if r.Method != "POST" {
+		return &devLxdResponse{"method not allowed", http.StatusMethodNotAllowed, "raw"}
+	}
+
+	restart := func(op *operation) error {
+		c.SetOperation(op)
+		return containerRestart(c, 30*time.Second)
+	}
+
+	err := devlxdRunOperation(d, c, db.OperationContainerRestart, restart, false)
+	if err != nil {
+		return &devLxdResponse{"internal server error", http.StatusInternalServerError, "raw"}
+	}
+
+	return okResponse("", "raw")
+}}
+
+// devlxdRunOperation runs a task operation against the container, so that it
+// shows up on the host like any other, optionally waiting for its completion.
+func devlxdRunOperation(d *Daemon, c container, opType db.OperationType, run func(op *operation) error, wait bool) error {
+	resources := map[string][]string{}
+	resources["containers"] = []string{c.Name()}
+
+	op, err := operationCreate(d.cluster, c.Project(), operationClassTask, opType, resources, nil, run, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	chanErr, err := op.Run()
+	if err != nil {
+		return err
+	}
+
+	if !wait {
+		return nil
+	}
+
+	return <-chanErr
+}
+
 var devlxdMetadataGet = devLxdHandler{"/1.0/meta-data", func(d *Daemon, c container, w http.ResponseWriter, r *http.Request) *devLxdResponse {
 	value := c.ExpandedConfig()["user.meta-data"]
 	return okResponse(fmt.Sprintf("#cloud-config\ninstance-id: %s\nlocal-hostname: %s\n%s", c.Name(), c.Name(), value), "raw")
@@ -108,7 +269,7 @@ var devlxdEventListeners map[int]map[string]*eventListener = make(map[int]map[st
 var devlxdEventsGet = devLxdHandler{"/1.0/events", func(d *Daemon, c container, w http.ResponseWriter, r *http.Request) *devLxdResponse {
 	typeStr := r.FormValue("type")
 	if typeStr == "" {
-		typeStr = "config,device"
+		typeStr = "config,device,resume"
 	}
 
 	conn, err := shared.WebsocketUpgrader.Upgrade(w, r, nil)
@@ -211,6 +372,9 @@ var handlers = []devLxdHandler{
 	devlxdMetadataGet,
 	devlxdEventsGet,
 	devlxdImageExport,
+	devlxdSnapshots,
+	devlxdSnapshotRestore,
+	devlxdRestart,
 }
 
 func hoistReq(f func(*Daemon, container, http.ResponseWriter, *http.Request) *devLxdResponse, d *Daemon) func(http.ResponseWriter, *http.Request) {
@@ -31,10 +31,14 @@ type DHCPAllocation struct {
 var ConfigMutex sync.Mutex
 
 // UpdateStaticEntry writes a single dhcp-host line for a network/instance combination.
-func UpdateStaticEntry(network string, projectName string, instanceName string, netConfig map[string]string, hwaddr string, ipv4Address string, ipv6Address string) error {
+func UpdateStaticEntry(network string, projectName string, instanceName string, netConfig map[string]string, deviceConfig map[string]string, hwaddr string, ipv4Address string, ipv6Address string) error {
 	line := hwaddr
 
 	// Generate the dhcp-host line
+	if deviceConfig["dhcp.client-id"] != "" {
+		line += fmt.Sprintf(",id:%s", deviceConfig["dhcp.client-id"])
+	}
+
 	if ipv4Address != "" {
 		line += fmt.Sprintf(",%s", ipv4Address)
 	}
@@ -44,7 +48,13 @@ func UpdateStaticEntry(network string, projectName string, instanceName string,
 	}
 
 	if netConfig["dns.mode"] == "" || netConfig["dns.mode"] == "managed" {
-		line += fmt.Sprintf(",%s", instanceName)
+		// Register the device's DNS name rather than the one provided by the guest
+		dnsName := instanceName
+		if deviceConfig["dns.name"] != "" {
+			dnsName = deviceConfig["dns.name"]
+		}
+
+		line += fmt.Sprintf(",%s", dnsName)
 	}
 
 	if line == hwaddr {
@@ -69,6 +79,55 @@ func RemoveStaticEntry(network string, projectName string, instanceName string)
 	return nil
 }
 
+// UpdateDNSEntry writes the hosts lines registering the DNS name of a network/instance combination
+// with addresses which aren't handed out by dnsmasq.
+func UpdateDNSEntry(network string, projectName string, instanceName string, netConfig map[string]string, deviceConfig map[string]string, addresses []string) error {
+	if netConfig["dns.mode"] != "" && netConfig["dns.mode"] != "managed" {
+		return nil
+	}
+
+	dnsName := instanceName
+	if deviceConfig["dns.name"] != "" {
+		dnsName = deviceConfig["dns.name"]
+	}
+
+	dnsDomain := netConfig["dns.domain"]
+	if dnsDomain == "" {
+		dnsDomain = "lxd"
+	}
+
+	content := ""
+	for _, address := range addresses {
+		content += fmt.Sprintf("%s %s.%s %s\n", address, dnsName, dnsDomain, dnsName)
+	}
+
+	if content == "" {
+		return nil
+	}
+
+	err := os.MkdirAll(shared.VarPath("networks", network, "dnsmasq.dns"), 0755)
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(shared.VarPath("networks", network, "dnsmasq.dns", project.Prefix(projectName, instanceName)), []byte(content), 0644)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RemoveDNSEntry removes the hosts lines of a network/instance combination.
+func RemoveDNSEntry(network string, projectName string, instanceName string) error {
+	err := os.Remove(shared.VarPath("networks", network, "dnsmasq.dns", project.Prefix(projectName, instanceName)))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
 // Kill kills dnsmasq for a particular network (or optionally reloads it).
 func Kill(name string, reload bool) error {
 	// Check if we have a running dnsmasq at all
@@ -180,6 +239,11 @@ func DHCPStaticIPs(network string, containerName string) (DHCPAllocation, DHCPAl
 	for scanner.Scan() {
 		fields := strings.SplitN(scanner.Text(), ",", -1)
 		for _, field := range fields {
+			// Skip the DHCP client identifier.
+			if strings.HasPrefix(field, "id:") {
+				continue
+			}
+
 			// Check if field is IPv4 or IPv6 address.
 			if strings.Count(field, ".") == 3 {
 				IP := net.ParseIP(field)
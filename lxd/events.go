@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -63,6 +64,9 @@ func eventSendLifecycle(project, action, source string,
 var eventsLock sync.Mutex
 var eventListeners map[string]*eventListener = make(map[string]*eventListener)
 
+// Number of events currently waiting to be sent to a listener
+var eventsPending int64
+
 type eventListener struct {
 	project      string
 	connection   *websocket.Conn
@@ -72,6 +76,7 @@ type eventListener struct {
 	lock         sync.Mutex
 	done         bool
 	location     string
+	closed       chan struct{}
 
 	// If true, this listener won't get events forwarded from other
 	// nodes. It only used by listeners created internally by LXD nodes
@@ -124,6 +129,7 @@ func eventsSocket(d *Daemon, r *http.Request, w http.ResponseWriter) error {
 		id:           uuid.NewRandom().String(),
 		messageTypes: strings.Split(typeStr, ","),
 		location:     serverName,
+		closed:       make(chan struct{}),
 	}
 
 	// If this request is an internal one initiated by another node wanting
@@ -137,12 +143,51 @@ func eventsSocket(d *Daemon, r *http.Request, w http.ResponseWriter) error {
 
 	logger.Debugf("New event listener: %s", listener.id)
 
+	// Resources events are only generated for the listeners asking for them
+	if shared.StringInSlice("resources", listener.messageTypes) {
+		containers, interval, err := eventsResourcesParams(r)
+		if err == nil {
+			go eventsResourcesWatch(d, &listener, containers, interval)
+		}
+	}
+
+	// So are the console events
+	if shared.StringInSlice("console", listener.messageTypes) {
+		containers, err := eventsConsoleParams(r)
+		if err == nil {
+			err = eventsConsoleWatch(d, &listener, containers)
+		}
+
+		if err != nil {
+			eventsLock.Lock()
+			delete(eventListeners, listener.id)
+			eventsLock.Unlock()
+
+			c.Close()
+			return err
+		}
+	}
+
 	<-listener.active
 
 	return nil
 }
 
 func eventsGet(d *Daemon, r *http.Request) Response {
+	if shared.StringInSlice("resources", strings.Split(r.FormValue("type"), ",")) {
+		_, _, err := eventsResourcesParams(r)
+		if err != nil {
+			return BadRequest(err)
+		}
+	}
+
+	if shared.StringInSlice("console", strings.Split(r.FormValue("type"), ",")) {
+		_, err := eventsConsoleParams(r)
+		if err != nil {
+			return BadRequest(err)
+		}
+	}
+
 	return &eventsServe{req: r, d: d}
 }
 
@@ -176,56 +221,65 @@ func eventBroadcast(project string, event api.Event, isForward bool) error {
 			continue
 		}
 
+		atomic.AddInt64(&eventsPending, 1)
 		go func(listener *eventListener, event api.Event) {
+			defer atomic.AddInt64(&eventsPending, -1)
+
 			// Check that the listener still exists
 			if listener == nil {
 				return
 			}
 
-			// Ensure there is only a single even going out at the time
-			listener.lock.Lock()
-			defer listener.lock.Unlock()
+			listener.send(event)
+		}(listener, event)
+	}
+	eventsLock.Unlock()
 
-			// Make sure we're not done already
-			if listener.done {
-				return
-			}
+	return nil
+}
 
-			// Set the Location to the expected serverName
-			if event.Location == "" {
-				eventCopy := api.Event{}
-				err := shared.DeepCopy(&event, &eventCopy)
-				if err != nil {
-					return
-				}
-				eventCopy.Location = listener.location
+// send writes an event to the listener, disconnecting it on failure.
+func (listener *eventListener) send(event api.Event) {
+	// Ensure there is only a single even going out at the time
+	listener.lock.Lock()
+	defer listener.lock.Unlock()
 
-				event = eventCopy
-			}
+	// Make sure we're not done already
+	if listener.done {
+		return
+	}
 
-			body, err := json.Marshal(event)
-			if err != nil {
-				return
-			}
+	// Set the Location to the expected serverName
+	if event.Location == "" {
+		eventCopy := api.Event{}
+		err := shared.DeepCopy(&event, &eventCopy)
+		if err != nil {
+			return
+		}
+		eventCopy.Location = listener.location
 
-			err = listener.connection.WriteMessage(websocket.TextMessage, body)
-			if err != nil {
-				// Remove the listener from the list
-				eventsLock.Lock()
-				delete(eventListeners, listener.id)
-				eventsLock.Unlock()
-
-				// Disconnect the listener
-				listener.connection.Close()
-				listener.active <- false
-				listener.done = true
-				logger.Debugf("Disconnected event listener: %s", listener.id)
-			}
-		}(listener, event)
+		event = eventCopy
 	}
-	eventsLock.Unlock()
 
-	return nil
+	body, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+
+	err = listener.connection.WriteMessage(websocket.TextMessage, body)
+	if err != nil {
+		// Remove the listener from the list
+		eventsLock.Lock()
+		delete(eventListeners, listener.id)
+		eventsLock.Unlock()
+
+		// Disconnect the listener
+		listener.connection.Close()
+		listener.active <- false
+		listener.done = true
+		close(listener.closed)
+		logger.Debugf("Disconnected event listener: %s", listener.id)
+	}
 }
 
 // Forward to the local events dispatcher an event received from another node .
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sys/unix"
+	"gopkg.in/lxc/go-lxc.v2"
+
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	log "github.com/lxc/lxd/shared/log15"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// Interval at which the console ring buffers are checked for new output.
+const eventsConsoleInterval = time.Second
+
+// The console ring buffers are read by a single watcher, running while some
+// listeners want console events. It follows the running containers through
+// their start and stop rather than looking them up on every check, and sends
+// each new output to the listeners interested in it.
+var eventsConsoleLock sync.Mutex
+var eventsConsoleListeners = map[*eventListener][]string{}
+var eventsConsoleContainers = map[string]*eventsConsoleContainer{}
+var eventsConsoleDone chan struct{}
+
+// eventsConsoleContainer is a running container whose console is watched.
+type eventsConsoleContainer struct {
+	container container
+	buffer    string
+}
+
+// eventsConsoleParams returns the containers whose console output is
+// requested by an events listener, all of them if none is given.
+func eventsConsoleParams(r *http.Request) ([]string, error) {
+	if !util.RuntimeLiblxcVersionAtLeast(3, 0, 0) {
+		return nil, fmt.Errorf("Console events require liblxc >= 3.0")
+	}
+
+	containers := []string{}
+	for _, name := range strings.Split(r.FormValue("containers"), ",") {
+		name = strings.TrimSpace(name)
+		if name != "" {
+			containers = append(containers, name)
+		}
+	}
+
+	return containers, nil
+}
+
+// eventsConsoleWatch sends to the listener the output written to the console
+// of the subscribed containers, until it disconnects. The output already in
+// the ring buffers when the listener connects isn't sent, that of containers
+// starting afterwards is sent in full. Only the running containers of this
+// node are reported.
+func eventsConsoleWatch(d *Daemon, listener *eventListener, containers []string) error {
+	eventsConsoleLock.Lock()
+	if eventsConsoleDone == nil {
+		cts, err := containerLoadNodeAll(d.State())
+		if err != nil {
+			eventsConsoleLock.Unlock()
+			return err
+		}
+
+		for _, c := range cts {
+			if !c.IsRunning() {
+				continue
+			}
+
+			buffer, err := eventsConsoleBuffer(c)
+			if err != nil {
+				logger.Debug("Failed to read console buffer", log.Ctx{"project": c.Project(), "name": c.Name(), "err": err})
+			}
+
+			eventsConsoleContainers[eventsConsoleKey(c)] = &eventsConsoleContainer{container: c, buffer: buffer}
+		}
+
+		eventsConsoleDone = make(chan struct{})
+		go eventsConsoleRun(eventsConsoleDone)
+	}
+
+	eventsConsoleListeners[listener] = containers
+	eventsConsoleLock.Unlock()
+
+	go func() {
+		<-listener.closed
+
+		eventsConsoleLock.Lock()
+		defer eventsConsoleLock.Unlock()
+
+		delete(eventsConsoleListeners, listener)
+		if len(eventsConsoleListeners) == 0 {
+			close(eventsConsoleDone)
+			eventsConsoleDone = nil
+			eventsConsoleContainers = map[string]*eventsConsoleContainer{}
+		}
+	}()
+
+	return nil
+}
+
+// eventsConsoleStart starts watching the console of a container which just
+// started, if some listeners want console events.
+func eventsConsoleStart(c container) {
+	eventsConsoleLock.Lock()
+	defer eventsConsoleLock.Unlock()
+
+	if eventsConsoleDone == nil {
+		return
+	}
+
+	eventsConsoleContainers[eventsConsoleKey(c)] = &eventsConsoleContainer{container: c}
+}
+
+// eventsConsoleStop stops watching the console of a container which stopped,
+// so its output is sent in full when it starts again.
+func eventsConsoleStop(c container) {
+	eventsConsoleLock.Lock()
+	defer eventsConsoleLock.Unlock()
+
+	delete(eventsConsoleContainers, eventsConsoleKey(c))
+}
+
+func eventsConsoleKey(c container) string {
+	return fmt.Sprintf("%s/%s", c.Project(), c.Name())
+}
+
+// eventsConsoleRun checks the console ring buffers of the watched containers
+// for new output until done is closed.
+func eventsConsoleRun(done chan struct{}) {
+	ticker := time.NewTicker(eventsConsoleInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		// The buffers are read without holding the lock, for containers
+		// to start and stop meanwhile.
+		eventsConsoleLock.Lock()
+		watched := make([]*eventsConsoleContainer, 0, len(eventsConsoleContainers))
+		for _, entry := range eventsConsoleContainers {
+			watched = append(watched, entry)
+		}
+		eventsConsoleLock.Unlock()
+
+		for _, entry := range watched {
+			c := entry.container
+
+			current, err := eventsConsoleBuffer(c)
+			if err != nil {
+				logger.Debug("Failed to read console buffer", log.Ctx{"project": c.Project(), "name": c.Name(), "err": err})
+				continue
+			}
+
+			output := eventsConsoleNewOutput(entry.buffer, current)
+			entry.buffer = current
+			if output == "" {
+				continue
+			}
+
+			err = eventsConsoleSend(c, output)
+			if err != nil {
+				logger.Debug("Failed to send console event", log.Ctx{"project": c.Project(), "name": c.Name(), "err": err})
+			}
+		}
+	}
+}
+
+// eventsConsoleSend sends new console output of a container to the listeners
+// interested in it.
+func eventsConsoleSend(c container, output string) error {
+	metadata, err := json.Marshal(api.EventConsole{
+		Project:   c.Project(),
+		Container: c.Name(),
+		Output:    output,
+	})
+	if err != nil {
+		return err
+	}
+
+	event := api.Event{
+		Type:      "console",
+		Timestamp: time.Now(),
+		Metadata:  metadata,
+	}
+
+	eventsConsoleLock.Lock()
+	defer eventsConsoleLock.Unlock()
+
+	for listener, names := range eventsConsoleListeners {
+		if listener.project != "*" && listener.project != c.Project() {
+			continue
+		}
+
+		if len(names) > 0 && !shared.StringInSlice(c.Name(), names) {
+			continue
+		}
+
+		atomic.AddInt64(&eventsPending, 1)
+		go func(listener *eventListener) {
+			defer atomic.AddInt64(&eventsPending, -1)
+			listener.send(event)
+		}(listener)
+	}
+
+	return nil
+}
+
+// eventsConsoleBuffer returns the content of the console ring buffer of a
+// running container, leaving it untouched.
+func eventsConsoleBuffer(c container) (string, error) {
+	console := lxc.ConsoleLogOptions{
+		ClearLog:       false,
+		ReadLog:        true,
+		ReadMax:        0,
+		WriteToLogFile: false,
+	}
+
+	content, err := c.ConsoleLog(console)
+	if err != nil {
+		errno, isErrno := shared.GetErrno(err)
+		if isErrno && errno == unix.ENODATA {
+			return "", nil
+		}
+
+		return "", err
+	}
+
+	return content, nil
+}
+
+// eventsConsoleNewOutput returns what was written to a console ring buffer
+// between two reads of it. Once the buffer is full, its oldest content goes
+// away as new output comes in, so the longest end of the previous content
+// found at the start of the current one gets skipped. The whole current
+// content is returned if none is left, e.g. when the buffer was cleared.
+func eventsConsoleNewOutput(previous string, current string) string {
+	if current == "" {
+		return ""
+	}
+
+	// Knuth-Morris-Pratt failure function of the current content
+	failure := make([]int, len(current))
+	k := 0
+	for i := 1; i < len(current); i++ {
+		for k > 0 && current[i] != current[k] {
+			k = failure[k-1]
+		}
+
+		if current[i] == current[k] {
+			k++
+		}
+
+		failure[i] = k
+	}
+
+	// Match the previous content against it, ending with the length of the
+	// longest end of the previous content starting the current one
+	k = 0
+	for i := 0; i < len(previous); i++ {
+		for k > 0 && (k == len(current) || previous[i] != current[k]) {
+			k = failure[k-1]
+		}
+
+		if k < len(current) && previous[i] == current[k] {
+			k++
+		}
+	}
+
+	return current[k:]
+}
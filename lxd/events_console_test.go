@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventsConsoleNewOutput(t *testing.T) {
+	cases := []struct {
+		previous string
+		current  string
+		output   string
+	}{
+		{"", "boot\n", "boot\n"},
+		{"boot\n", "boot\n", ""},
+		{"boot\n", "boot\nlogin: ", "login: "},
+		{"abcdef", "cdefgh", "gh"},
+		{"abcdef", "xyz", "xyz"},
+		{"aaaa", "aaab", "b"},
+		{"abab", "ababab", "ab"},
+		{"boot\n", "", ""},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.output, eventsConsoleNewOutput(c.previous, c.current), "previous %q, current %q", c.previous, c.current)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+// Default number of seconds between two resources events for a container.
+const eventsResourcesInterval = 10
+
+// Usage counters of a container at the time of its previous resources event.
+type eventsResourcesSample struct {
+	time          time.Time
+	cpuUsage      int64
+	bytesReceived int64
+	bytesSent     int64
+}
+
+// eventsResourcesParams returns the containers subscribed to and the interval
+// of the resources events requested by an events listener.
+func eventsResourcesParams(r *http.Request) ([]string, time.Duration, error) {
+	containers := []string{}
+	for _, name := range strings.Split(r.FormValue("containers"), ",") {
+		name = strings.TrimSpace(name)
+		if name != "" {
+			containers = append(containers, name)
+		}
+	}
+
+	if len(containers) == 0 {
+		return nil, 0, fmt.Errorf("Resources events require a list of containers")
+	}
+
+	interval := eventsResourcesInterval
+	if r.FormValue("interval") != "" {
+		value, err := strconv.Atoi(r.FormValue("interval"))
+		if err != nil || value < 1 {
+			return nil, 0, fmt.Errorf("Invalid resources events interval %q", r.FormValue("interval"))
+		}
+
+		interval = value
+	}
+
+	return containers, time.Duration(interval) * time.Second, nil
+}
+
+// eventsResourcesWatch periodically sends to the listener the resources used
+// by the subscribed containers since the previous event, until it disconnects.
+// Only the running containers of this node are reported.
+func eventsResourcesWatch(d *Daemon, listener *eventListener, containers []string, interval time.Duration) {
+	samples := map[string]*eventsResourcesSample{}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for _, name := range containers {
+			resources, ok := eventsResourcesSampleContainer(d, listener.project, name, samples)
+			if !ok {
+				continue
+			}
+
+			metadata, err := json.Marshal(resources)
+			if err != nil {
+				continue
+			}
+
+			listener.send(api.Event{
+				Type:      "resources",
+				Timestamp: time.Now(),
+				Metadata:  metadata,
+			})
+		}
+
+		select {
+		case <-listener.closed:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// eventsResourcesSampleContainer reads the usage counters of a container and
+// returns the difference with its previous sample. Nothing is returned for
+// the first sample or when the container isn't running on this node.
+func eventsResourcesSampleContainer(d *Daemon, project string, name string, samples map[string]*eventsResourcesSample) (api.EventResources, bool) {
+	resources := api.EventResources{
+		Project:   project,
+		Container: name,
+	}
+
+	c, err := containerLoadByProjectAndName(d.State(), project, name)
+	if err != nil || !c.IsRunning() {
+		delete(samples, name)
+		return resources, false
+	}
+
+	ct, ok := c.(*containerLXC)
+	if !ok {
+		return resources, false
+	}
+
+	cg := newContainerCGroupState(ct)
+	sample := &eventsResourcesSample{
+		time:     time.Now(),
+		cpuUsage: ct.cpuState(cg).Usage,
+	}
+
+	for iface, network := range ct.networkState() {
+		if iface == "lo" {
+			continue
+		}
+
+		sample.bytesReceived += network.Counters.BytesReceived
+		sample.bytesSent += network.Counters.BytesSent
+	}
+
+	previous := samples[name]
+	samples[name] = sample
+	if previous == nil {
+		return resources, false
+	}
+
+	resources.Interval = sample.time.Sub(previous.time).Nanoseconds()
+	resources.CPUUsage = eventsResourcesDelta(previous.cpuUsage, sample.cpuUsage)
+	resources.MemoryUsage = ct.memoryState(cg).Usage
+	resources.NetworkBytesReceived = eventsResourcesDelta(previous.bytesReceived, sample.bytesReceived)
+	resources.NetworkBytesSent = eventsResourcesDelta(previous.bytesSent, sample.bytesSent)
+
+	return resources, true
+}
+
+// eventsResourcesDelta returns the increase of a counter, or zero if it was
+// reset (e.g. the container was restarted) or couldn't be read.
+func eventsResourcesDelta(previous int64, current int64) int64 {
+	if previous < 0 || current < previous {
+		return 0
+	}
+
+	return current - previous
+}
@@ -128,28 +128,88 @@ func unpackImage(imagefname string, destpath string, sType storageType, runningI
 		}
 	}
 
+	// Rebuild delta images on top of their parent
+	deltaPath := filepath.Join(destpath, imageDeltaFile)
+	isDelta := shared.PathExists(deltaPath)
+	if isDelta {
+		err = imageDeltaRebuild(destpath, sType, runningInUserns)
+		if err != nil {
+			return errors.Wrapf(err, "Failed to rebuild delta image %s", imagefname)
+		}
+	}
+
 	if !shared.PathExists(rootfsPath) {
 		return fmt.Errorf("Image is missing a rootfs: %s", imagefname)
 	}
 
+	// The image is already verified through its fingerprint, so drop the
+	// checksums manifest rather than hashing the whole rootfs again.
+	checksumsPath := filepath.Join(destpath, shared.ChecksumsFile)
+	if shared.PathExists(checksumsPath) {
+		err = os.Remove(checksumsPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	if isDelta {
+		err = os.Remove(deltaPath)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
 func compressFile(compress string, infile io.Reader, outfile io.Writer) error {
-	reproducible := []string{"gzip"}
-
-	args := []string{"-c"}
-	if shared.StringInSlice(compress, reproducible) {
-		args = append(args, "-n")
+	name, args, err := util.CompressionCommand(compress)
+	if err != nil {
+		return err
 	}
 
-	cmd := exec.Command(compress, args...)
+	cmd := exec.Command(name, args...)
 	cmd.Stdin = infile
 	cmd.Stdout = outfile
 
 	return cmd.Run()
 }
 
+// imageContainerProfile returns the profile to create from the expanded
+// configuration and devices of a container, leaving out the image and volatile
+// keys, so that it can be combined with the image published from the same
+// container to create an equivalent container. The root disk is left out too,
+// the containers using the profile getting theirs from their own profiles.
+func imageContainerProfile(d *Daemon, project string, name string, profileName string) (api.ProfilesPost, error) {
+	profile := api.ProfilesPost{Name: profileName}
+	profile.Description = fmt.Sprintf("Generated from container %s", name)
+	profile.Config = map[string]string{}
+	profile.Devices = map[string]map[string]string{}
+
+	c, err := containerLoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return profile, err
+	}
+
+	for k, v := range c.ExpandedConfig() {
+		if strings.HasPrefix(k, "volatile.") || strings.HasPrefix(k, "image.") {
+			continue
+		}
+
+		profile.Config[k] = v
+	}
+
+	for k, v := range c.ExpandedDevices() {
+		if shared.IsRootDiskDevice(v) {
+			continue
+		}
+
+		profile.Devices[k] = v
+	}
+
+	return profile, nil
+}
+
 /*
  * This function takes a container or snapshot from the local image server and
  * exports it as an image.
@@ -190,6 +250,17 @@ func imgPostContInfo(d *Daemon, r *http.Request, req api.ImagesPost, op *operati
 		return nil, err
 	}
 
+	// Resolve the parent image of a delta image
+	parent := ""
+	if req.Parent != "" {
+		_, parentInfo, err := d.cluster.ImageGet(project, req.Parent, false, false)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Fetch parent image %q", req.Parent)
+		}
+
+		parent = parentInfo.Fingerprint
+	}
+
 	// Build the actual image file
 	imageFile, err := ioutil.TempFile(builddir, "lxd_build_image_")
 	if err != nil {
@@ -265,7 +336,7 @@ func imgPostContInfo(d *Daemon, r *http.Request, req api.ImagesPost, op *operati
 		writer = io.MultiWriter(imageProgressWriter, sha256)
 	}
 
-	err = c.Export(writer, req.Properties)
+	err = c.Export(writer, req.Properties, parent)
 	// When compression is used, Close on imageProgressWriter/tarWriter
 	// is required for compressFile/gzip to know it is finished.
 	// Otherwise It is equivalent to imageFile.Close.
@@ -733,6 +804,46 @@ func imagesPost(d *Daemon, r *http.Request) Response {
 		}
 	}
 
+	// Sanity checks for the generated profile
+	var profile api.ProfilesPost
+	if !imageUpload && req.Profile != "" {
+		if !shared.StringInSlice(req.Source.Type, []string{"container", "snapshot"}) {
+			cleanup(builddir, post)
+			return BadRequest(fmt.Errorf("Profiles can only be generated when publishing containers"))
+		}
+
+		// Check the profile like if it was created by the user
+		if !d.userHasPermission(r, project, "manage-profiles") {
+			cleanup(builddir, post)
+			return Forbidden(nil)
+		}
+
+		profile, err = imageContainerProfile(d, project, req.Source.Name, req.Profile)
+		if err != nil {
+			cleanup(builddir, post)
+			return SmartError(err)
+		}
+
+		response := profilesPostValidate(d, r, project, profile)
+		if response != nil {
+			cleanup(builddir, post)
+			return response
+		}
+	}
+
+	if !imageUpload && req.Parent != "" && !shared.StringInSlice(req.Source.Type, []string{"container", "snapshot"}) {
+		cleanup(builddir, post)
+		return BadRequest(fmt.Errorf("Delta images can only be generated when publishing containers"))
+	}
+
+	if !imageUpload && req.CompressionAlgorithm != "" {
+		err = util.ValidateCompression(req.CompressionAlgorithm)
+		if err != nil {
+			cleanup(builddir, post)
+			return BadRequest(err)
+		}
+	}
+
 	// Begin background operation
 	run := func(op *operation) error {
 		var err error
@@ -741,6 +852,35 @@ func imagesPost(d *Daemon, r *http.Request) Response {
 		// Setup the cleanup function
 		defer cleanup(builddir, post)
 
+		// Generate a profile holding the container's configuration and
+		// devices first, so that an existing one fails the publication
+		// before any image is created
+		success := false
+		if !imageUpload && req.Profile != "" {
+			profileProject := project
+			err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+				var err error
+				profileProject, err = profileCreate(tx, project, profile)
+				return err
+			})
+			if err != nil {
+				return errors.Wrapf(err, "Create profile %q", req.Profile)
+			}
+
+			defer func() {
+				if success {
+					return
+				}
+
+				err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+					return tx.ProfileDelete(profileProject, req.Profile)
+				})
+				if err != nil {
+					logger.Error("Failed to delete generated profile", log.Ctx{"project": profileProject, "profile": req.Profile, "err": err})
+				}
+			}()
+		}
+
 		if imageUpload {
 			/* Processing image upload */
 			info, err = getImgPostInfo(d, r, builddir, project, post)
@@ -759,8 +899,8 @@ func imagesPost(d *Daemon, r *http.Request) Response {
 			}
 		}
 		// Set the metadata if possible, even if there is an error
+		metadata := make(map[string]string)
 		if info != nil {
-			metadata := make(map[string]string)
 			metadata["fingerprint"] = info.Fingerprint
 			metadata["size"] = strconv.FormatInt(info.Size, 10)
 			op.UpdateMetadata(metadata)
@@ -797,6 +937,12 @@ func imagesPost(d *Daemon, r *http.Request) Response {
 			return errors.Wrapf(err, "Image sync between nodes")
 		}
 
+		if !imageUpload && req.Profile != "" {
+			metadata["profile"] = req.Profile
+			op.UpdateMetadata(metadata)
+		}
+
+		success = true
 		return nil
 	}
 
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	lxd "github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/version"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+var imagesCatalogCmd = APIEndpoint{
+	Name: "images/catalog",
+
+	Get:  APIEndpointAction{Handler: imagesCatalogGet, AccessHandler: AllowProjectPermission("images", "view")},
+	Post: APIEndpointAction{Handler: imagesCatalogPost, AccessHandler: AllowProjectPermission("images", "manage-images")},
+}
+
+// imagesCatalogMatch returns whether the fingerprint starts with one of the
+// given prefixes, or true if there are none.
+func imagesCatalogMatch(fingerprint string, prefixes []string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(fingerprint, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func imagesCatalogGet(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+
+	prefixes := []string{}
+	for _, prefix := range strings.Split(r.FormValue("fingerprints"), ",") {
+		prefix = strings.TrimSpace(prefix)
+		if prefix != "" {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+
+	fingerprints, err := d.cluster.ImagesGet(project, false)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	catalog := api.ImageCatalog{Images: []api.Image{}}
+	for _, fingerprint := range fingerprints {
+		if !imagesCatalogMatch(fingerprint, prefixes) {
+			continue
+		}
+
+		image, response := doImageGet(d.cluster, project, fingerprint, false)
+		if response != nil {
+			return response
+		}
+
+		catalog.Images = append(catalog.Images, *image)
+	}
+
+	return SyncResponse(true, catalog)
+}
+
+func imagesCatalogPost(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+
+	req := api.ImageCatalogPost{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	if req.Server == "" {
+		return BadRequest(fmt.Errorf("The source server must be specified"))
+	}
+
+	run := func(op *operation) error {
+		return imagesCatalogImport(d, op, project, req)
+	}
+
+	op, err := operationCreate(d.cluster, project, operationClassTask, db.OperationImagesImport, nil, nil, run, nil, nil)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	return OperationResponse(op)
+}
+
+// imagesCatalogImport copies the images of another server which aren't here
+// yet, along with their aliases, properties and flags. The images which were
+// already imported are skipped, so an interrupted import can be resumed by
+// running it again.
+func imagesCatalogImport(d *Daemon, op *operation, project string, req api.ImageCatalogPost) error {
+	cert := d.endpoints.NetworkCert()
+	source, err := lxd.ConnectLXD(req.Server, &lxd.ConnectionArgs{
+		TLSServerCert: req.Certificate,
+		TLSClientCert: string(cert.PublicKey()),
+		TLSClientKey:  string(cert.PrivateKey()),
+		UserAgent:     version.UserAgent,
+		Proxy:         d.proxy,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "Failed to connect to %q", req.Server)
+	}
+
+	if req.Project != "" {
+		source = source.UseProject(req.Project)
+	}
+
+	catalog, err := source.GetImageCatalog(req.Fingerprints)
+	if err != nil {
+		return errors.Wrap(err, "Failed to fetch the image catalog")
+	}
+
+	imported := []string{}
+	skipped := []string{}
+	conflicts := []string{}
+	updateMetadata := func() {
+		op.UpdateMetadata(map[string]interface{}{
+			"total":           len(catalog.Images),
+			"imported":        imported,
+			"skipped":         skipped,
+			"alias_conflicts": conflicts,
+		})
+	}
+
+	for _, image := range catalog.Images {
+		exists, err := d.cluster.ImageExists(project, image.Fingerprint)
+		if err != nil {
+			return err
+		}
+
+		if exists {
+			skipped = append(skipped, image.Fingerprint)
+		} else {
+			err = imagesCatalogImportImage(d, source, project, image)
+			if err != nil {
+				return errors.Wrapf(err, "Failed to import image %q", image.Fingerprint)
+			}
+
+			imported = append(imported, image.Fingerprint)
+		}
+
+		// Restore the aliases, leaving those which point to another image alone
+		id, _, err := d.cluster.ImageGet(project, image.Fingerprint, false, true)
+		if err != nil {
+			return errors.Wrapf(err, "Fetch image %q", image.Fingerprint)
+		}
+
+		for _, alias := range image.Aliases {
+			_, entry, err := d.cluster.ImageAliasGet(project, alias.Name, true)
+			if err == nil {
+				if entry.Target != image.Fingerprint {
+					conflicts = append(conflicts, alias.Name)
+				}
+
+				continue
+			}
+
+			if err != db.ErrNoSuchObject {
+				return errors.Wrapf(err, "Fetch image alias %q", alias.Name)
+			}
+
+			err = d.cluster.ImageAliasAdd(project, alias.Name, id, alias.Description)
+			if err != nil {
+				return errors.Wrapf(err, "Add image alias %q", alias.Name)
+			}
+		}
+
+		updateMetadata()
+	}
+
+	if len(conflicts) > 0 {
+		logger.Warn("Image aliases already pointing to other images weren't imported", log.Ctx{"server": req.Server, "aliases": conflicts})
+	}
+
+	return nil
+}
+
+// imagesCatalogImportImage downloads an image from the source server and
+// records it with the properties and flags it has there.
+func imagesCatalogImportImage(d *Daemon, source lxd.ContainerServer, project string, image api.Image) error {
+	err := imageImportFromNode(shared.VarPath("images"), source, image.Fingerprint)
+	if err != nil {
+		return err
+	}
+
+	err = d.cluster.ImageInsert(project, image.Fingerprint, image.Filename, image.Size, image.Public, image.AutoUpdate, image.Architecture, image.CreatedAt, image.ExpiresAt, image.Properties)
+	if err != nil {
+		imageDeleteFromDisk(image.Fingerprint)
+		return err
+	}
+
+	id, _, err := d.cluster.ImageGet(project, image.Fingerprint, false, true)
+	if err != nil {
+		return err
+	}
+
+	if image.UpdateSource != nil {
+		err = d.cluster.ImageSourceInsert(id, image.UpdateSource.Server, image.UpdateSource.Protocol, image.UpdateSource.Certificate, image.UpdateSource.Alias)
+		if err != nil {
+			return err
+		}
+	}
+
+	if image.Cached {
+		err = d.cluster.ImageLastAccessInit(image.Fingerprint)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Sync the images between each node in the cluster on demand
+	return imageSyncBetweenNodes(d, project, image.Fingerprint)
+}
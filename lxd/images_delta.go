@@ -0,0 +1,358 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/idmap"
+)
+
+// imageDeltaFile describes a delta image, which only holds the rootfs files
+// changed since its parent image along with the paths removed since then.
+const imageDeltaFile = "delta.yaml"
+
+// Prefix of the directories a delta image is staged into while its parent
+// gets unpacked.
+const imageDeltaStagingPrefix = ".lxd_delta_"
+
+type imageDelta struct {
+	Parent  string   `yaml:"parent"`
+	Deleted []string `yaml:"deleted"`
+
+	// Paths relative to the rootfs to include in the delta image
+	changed map[string]bool
+}
+
+// imageDeltaNew computes the changes of a rootfs since the given parent image,
+// which is unpacked to a temporary directory for comparison. The ownership of
+// the rootfs is unshifted through the given idmap, if any, to compare it with
+// the one of the image.
+func imageDeltaNew(s *state.State, parent string, rootfs string, idmapSet *idmap.IdmapSet) (*imageDelta, error) {
+	parentPath := shared.VarPath("images", parent)
+	if !shared.PathExists(parentPath) {
+		return nil, fmt.Errorf("Parent image %s isn't available", parent)
+	}
+
+	tempDir, err := ioutil.TempDir(shared.VarPath("images"), "lxd_delta_")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tempDir)
+
+	err = unpackImage(parentPath, tempDir, storageTypeDir, s.OS.RunningInUserNS, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to unpack parent image %s", parent)
+	}
+
+	changed, deleted, err := imageDeltaDiff(filepath.Join(tempDir, "rootfs"), rootfs, idmapSet)
+	if err != nil {
+		return nil, err
+	}
+
+	return &imageDelta{Parent: parent, Deleted: deleted, changed: changed}, nil
+}
+
+// includes returns whether the given path, relative to the rootfs, must be
+// part of the delta image.
+func (delta *imageDelta) includes(path string) bool {
+	return delta.changed[path]
+}
+
+// imageDeltaDiff compares two trees rsync-style, through the type, mode,
+// ownership, size, modification time and target of their entries. It returns
+// the paths of root which are new or differ from parentRoot, along with their
+// parent directories so those keep their metadata when unpacked, and the
+// paths of parentRoot which are gone from root. The ownership of the entries
+// of root is unshifted through idmapSet, if any, before being compared.
+func imageDeltaDiff(parentRoot string, root string, idmapSet *idmap.IdmapSet) (map[string]bool, []string, error) {
+	changed := map[string]bool{}
+	deleted := []string{}
+
+	err := filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		if relPath == "." {
+			return nil
+		}
+
+		parentPath := filepath.Join(parentRoot, relPath)
+		parentFi, err := os.Lstat(parentPath)
+		if err == nil && imageDeltaSame(parentPath, parentFi, path, fi, idmapSet) {
+			return nil
+		}
+
+		for dir := relPath; dir != "."; dir = filepath.Dir(dir) {
+			changed[dir] = true
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = filepath.Walk(parentRoot, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relPath, err := filepath.Rel(parentRoot, path)
+		if err != nil {
+			return err
+		}
+
+		if relPath == "." {
+			return nil
+		}
+
+		_, err = os.Lstat(filepath.Join(root, relPath))
+		if err == nil {
+			return nil
+		}
+
+		if !os.IsNotExist(err) {
+			return err
+		}
+
+		deleted = append(deleted, relPath)
+
+		// The whole directory goes away
+		if fi.IsDir() {
+			return filepath.SkipDir
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return changed, deleted, nil
+}
+
+// imageDeltaSame returns whether the entry b, whose ownership is unshifted
+// through idmapSet if any, is the same as the entry a.
+func imageDeltaSame(pathA string, a os.FileInfo, pathB string, b os.FileInfo, idmapSet *idmap.IdmapSet) bool {
+	if a.Mode() != b.Mode() || a.ModTime().Unix() != b.ModTime().Unix() {
+		return false
+	}
+
+	statA, okA := a.Sys().(*syscall.Stat_t)
+	statB, okB := b.Sys().(*syscall.Stat_t)
+	if !okA || !okB || statA.Rdev != statB.Rdev {
+		return false
+	}
+
+	uid, gid := int64(statB.Uid), int64(statB.Gid)
+	if idmapSet != nil {
+		uid, gid = idmapSet.ShiftFromNs(uid, gid)
+	}
+
+	if int64(statA.Uid) != uid || int64(statA.Gid) != gid {
+		return false
+	}
+
+	if a.Mode().IsRegular() && a.Size() != b.Size() {
+		return false
+	}
+
+	if a.Mode()&os.ModeSymlink != 0 {
+		targetA, err := os.Readlink(pathA)
+		if err != nil {
+			return false
+		}
+
+		targetB, err := os.Readlink(pathB)
+		if err != nil || targetA != targetB {
+			return false
+		}
+	}
+
+	return true
+}
+
+// imageDeltaRebuild turns a delta image unpacked to destpath into a full one,
+// by unpacking its parent image below it.
+func imageDeltaRebuild(destpath string, sType storageType, runningInUserns bool) error {
+	content, err := ioutil.ReadFile(filepath.Join(destpath, imageDeltaFile))
+	if err != nil {
+		return err
+	}
+
+	delta := imageDelta{}
+	err = yaml.Unmarshal(content, &delta)
+	if err != nil {
+		return errors.Wrap(err, "Invalid delta image description")
+	}
+
+	_, err = hex.DecodeString(delta.Parent)
+	if err != nil || len(delta.Parent) != 64 {
+		return fmt.Errorf("Invalid parent image fingerprint: %s", delta.Parent)
+	}
+
+	for _, path := range delta.Deleted {
+		if filepath.IsAbs(path) || path == ".." || strings.HasPrefix(filepath.Clean(path), "../") {
+			return fmt.Errorf("Invalid deleted path in delta image: %s", path)
+		}
+	}
+
+	parentPath := shared.VarPath("images", delta.Parent)
+	if !shared.PathExists(parentPath) {
+		return fmt.Errorf("Parent image %s of the delta image isn't available", delta.Parent)
+	}
+
+	// Move the delta out of the way, staying on the same filesystem, so
+	// that its files end up on top of those of the parent.
+	stagingDir, err := ioutil.TempDir(destpath, imageDeltaStagingPrefix)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(stagingDir)
+
+	entries, err := ioutil.ReadDir(destpath)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), imageDeltaStagingPrefix) {
+			continue
+		}
+
+		err = os.Rename(filepath.Join(destpath, entry.Name()), filepath.Join(stagingDir, entry.Name()))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = unpackImage(parentPath, destpath, sType, runningInUserns, nil)
+	if err != nil {
+		return errors.Wrapf(err, "Failed to unpack parent image %s", delta.Parent)
+	}
+
+	for _, path := range delta.Deleted {
+		fullPath, err := imageDeltaResolve(filepath.Join(destpath, "rootfs"), path)
+		if err != nil {
+			return err
+		}
+
+		if fullPath == "" {
+			continue
+		}
+
+		err = os.RemoveAll(fullPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	return imageDeltaMerge(stagingDir, destpath)
+}
+
+// imageDeltaResolve returns the path of an entry of the given rootfs, making
+// sure that none of its parent directories is a symlink which could lead out
+// of it. An empty path is returned if one of them doesn't exist.
+func imageDeltaResolve(rootfs string, path string) (string, error) {
+	parts := strings.Split(filepath.Clean(path), "/")
+
+	fullPath := rootfs
+	for i, part := range parts {
+		if part == "" || part == "." || part == ".." {
+			return "", fmt.Errorf("Invalid path in delta image: %s", path)
+		}
+
+		fullPath = filepath.Join(fullPath, part)
+		if i == len(parts)-1 {
+			break
+		}
+
+		fi, err := os.Lstat(fullPath)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return "", nil
+			}
+
+			return "", err
+		}
+
+		if !fi.IsDir() {
+			return "", fmt.Errorf("Path in delta image goes through a non-directory: %s", path)
+		}
+	}
+
+	return fullPath, nil
+}
+
+// imageDeltaMerge moves the content of src over dst, replacing the existing
+// entries but those which are directories on both sides, which get merged and
+// take the metadata of the one from src.
+func imageDeltaMerge(src string, dst string) error {
+	entries, err := ioutil.ReadDir(src)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		srcPath := filepath.Join(src, entry.Name())
+		dstPath := filepath.Join(dst, entry.Name())
+
+		dstFi, err := os.Lstat(dstPath)
+		if err == nil && entry.IsDir() && dstFi.IsDir() {
+			err = imageDeltaMerge(srcPath, dstPath)
+			if err != nil {
+				return err
+			}
+
+			stat, ok := entry.Sys().(*syscall.Stat_t)
+			if ok {
+				err = os.Lchown(dstPath, int(stat.Uid), int(stat.Gid))
+				if err != nil {
+					return err
+				}
+			}
+
+			err = os.Chmod(dstPath, entry.Mode())
+			if err != nil {
+				return err
+			}
+
+			err = os.Chtimes(dstPath, entry.ModTime(), entry.ModTime())
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if err == nil {
+			err = os.RemoveAll(dstPath)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = os.Rename(srcPath, dstPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
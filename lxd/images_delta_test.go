@@ -0,0 +1,136 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/shared/idmap"
+)
+
+func TestImageDelta(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd_image_delta_")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	parent := filepath.Join(dir, "parent")
+	root := filepath.Join(dir, "root")
+	mtime := time.Unix(1500000000, 0)
+
+	// Both trees start identical
+	for _, tree := range []string{parent, root} {
+		require.NoError(t, os.MkdirAll(filepath.Join(tree, "etc/app"), 0755))
+		require.NoError(t, os.MkdirAll(filepath.Join(tree, "var/cache"), 0755))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(tree, "etc/hostname"), []byte("c1\n"), 0644))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(tree, "etc/app/config"), []byte("a=1\n"), 0644))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(tree, "var/cache/data"), []byte("data"), 0644))
+		require.NoError(t, os.Symlink("hostname", filepath.Join(tree, "etc/name")))
+
+		for _, path := range []string{"etc/hostname", "etc/app/config", "var/cache/data", "etc/app", "var/cache", "etc", "var"} {
+			require.NoError(t, os.Chtimes(filepath.Join(tree, path), mtime, mtime))
+		}
+	}
+
+	// Then the rootfs changes
+	require.NoError(t, ioutil.WriteFile(filepath.Join(root, "etc/app/config"), []byte("a=2\n"), 0644))
+	require.NoError(t, os.Chtimes(filepath.Join(root, "etc/app"), mtime, mtime))
+	require.NoError(t, os.Chtimes(filepath.Join(root, "etc"), mtime, mtime))
+	require.NoError(t, os.RemoveAll(filepath.Join(root, "var/cache")))
+	require.NoError(t, os.Chtimes(filepath.Join(root, "var"), mtime, mtime))
+	require.NoError(t, os.Remove(filepath.Join(root, "etc/name")))
+	require.NoError(t, os.Symlink("app/config", filepath.Join(root, "etc/name")))
+
+	changed, deleted, err := imageDeltaDiff(parent, root, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]bool{
+		"etc":            true,
+		"etc/app":        true,
+		"etc/app/config": true,
+		"etc/name":       true,
+	}, changed)
+	assert.Equal(t, []string{"var/cache"}, deleted)
+
+	// Merging the changes on top of the parent gives back the rootfs
+	delta := filepath.Join(dir, "delta")
+	require.NoError(t, os.MkdirAll(filepath.Join(delta, "etc/app"), 0700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(delta, "etc/app/config"), []byte("a=2\n"), 0644))
+	require.NoError(t, os.Symlink("app/config", filepath.Join(delta, "etc/name")))
+
+	require.NoError(t, imageDeltaMerge(delta, parent))
+
+	content, err := ioutil.ReadFile(filepath.Join(parent, "etc/app/config"))
+	require.NoError(t, err)
+	assert.Equal(t, "a=2\n", string(content))
+
+	target, err := os.Readlink(filepath.Join(parent, "etc/name"))
+	require.NoError(t, err)
+	assert.Equal(t, "app/config", target)
+
+	// Untouched files stay, merged directories take the delta's metadata
+	_, err = os.Stat(filepath.Join(parent, "etc/hostname"))
+	require.NoError(t, err)
+
+	fi, err := os.Stat(filepath.Join(parent, "etc/app"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0700), fi.Mode().Perm())
+}
+
+func TestImageDeltaSameShifted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd_image_delta_")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "file")
+	require.NoError(t, ioutil.WriteFile(path, []byte("data"), 0644))
+
+	fi, err := os.Lstat(path)
+	require.NoError(t, err)
+
+	// The same file unshifted to itself through an identity map, not
+	// through one mapping its owner elsewhere
+	uid := int64(os.Getuid())
+	identity := &idmap.IdmapSet{Idmap: []idmap.IdmapEntry{
+		{Isuid: true, Isgid: true, Hostid: 0, Nsid: 0, Maprange: 1 << 32},
+	}}
+	shifted := &idmap.IdmapSet{Idmap: []idmap.IdmapEntry{
+		{Isuid: true, Isgid: true, Hostid: uid, Nsid: uid + 1000, Maprange: 1},
+	}}
+
+	assert.True(t, imageDeltaSame(path, fi, path, fi, nil))
+	assert.True(t, imageDeltaSame(path, fi, path, fi, identity))
+	assert.False(t, imageDeltaSame(path, fi, path, fi, shifted))
+}
+
+func TestImageDeltaResolve(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd_image_delta_")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	rootfs := filepath.Join(dir, "rootfs")
+	outside := filepath.Join(dir, "outside")
+	require.NoError(t, os.MkdirAll(filepath.Join(rootfs, "etc"), 0755))
+	require.NoError(t, os.MkdirAll(outside, 0755))
+	require.NoError(t, os.Symlink(outside, filepath.Join(rootfs, "lib")))
+
+	path, err := imageDeltaResolve(rootfs, "etc/hostname")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(rootfs, "etc/hostname"), path)
+
+	// A symlink itself can be removed, but not followed
+	path, err = imageDeltaResolve(rootfs, "lib")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(rootfs, "lib"), path)
+
+	_, err = imageDeltaResolve(rootfs, "lib/file")
+	assert.Error(t, err)
+
+	path, err = imageDeltaResolve(rootfs, "var/cache")
+	require.NoError(t, err)
+	assert.Equal(t, "", path)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+
+	lxd "github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+var imageDownloadsCmd = APIEndpoint{
+	Name: "images/downloads",
+
+	Get: APIEndpointAction{Handler: imageDownloadsGet},
+}
+
+// The statistics of the image downloads of this node since it started.
+var imageDownloadsLock sync.Mutex
+var imageDownloads = api.ImageDownloads{}
+
+// imageDownloadsRecord adds an image download from a remote server to the
+// statistics.
+func imageDownloadsRecord(fingerprint string, resp *lxd.ImageFileResponse) {
+	imageDownloadsLock.Lock()
+	defer imageDownloadsLock.Unlock()
+
+	imageDownloads.Downloads++
+	imageDownloads.BytesDownloaded += resp.MetaSize
+
+	if resp.RootfsDeltaBase == "" {
+		imageDownloads.BytesDownloaded += resp.RootfsSize
+		return
+	}
+
+	imageDownloads.DeltaDownloads++
+	imageDownloads.BytesDownloaded += resp.RootfsDeltaSize
+	imageDownloads.BytesSaved += resp.RootfsSize - resp.RootfsDeltaSize
+
+	logger.Info("Downloaded image rootfs delta", log.Ctx{"fingerprint": fingerprint, "base": resp.RootfsDeltaBase, "size": resp.RootfsDeltaSize, "saved": resp.RootfsSize - resp.RootfsDeltaSize})
+}
+
+func imageDownloadsGet(d *Daemon, r *http.Request) Response {
+	imageDownloadsLock.Lock()
+	downloads := imageDownloads
+	imageDownloadsLock.Unlock()
+
+	return SyncResponse(true, downloads)
+}
@@ -0,0 +1,798 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/sys/unix"
+
+	lxd "github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/loadbalancer"
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/version"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+var loadBalancersCmd = APIEndpoint{
+	Name: "load-balancers",
+
+	Get:  APIEndpointAction{Handler: loadBalancersGet},
+	Post: APIEndpointAction{Handler: loadBalancersPost},
+}
+
+var loadBalancerCmd = APIEndpoint{
+	Name: "load-balancers/{name}",
+
+	Delete: APIEndpointAction{Handler: loadBalancerDelete},
+	Get:    APIEndpointAction{Handler: loadBalancerGet},
+	Patch:  APIEndpointAction{Handler: loadBalancerPatch},
+	Put:    APIEndpointAction{Handler: loadBalancerPut},
+}
+
+var loadBalancerConfigKeys = map[string]func(value string) error{
+	"listen":          loadBalancerValidListen,
+	"target.port":     networkValidPort,
+	"members":         shared.IsAny,
+	"members.profile": shared.IsAny,
+	"members.project": shared.IsAny,
+	"healthcheck":     shared.IsBool,
+}
+
+func loadBalancerValidListen(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	host, port, err := net.SplitHostPort(value)
+	if err != nil {
+		return err
+	}
+
+	if host != "" && net.ParseIP(host) == nil {
+		return fmt.Errorf("Invalid listen address: %s", host)
+	}
+
+	return networkValidPort(port)
+}
+
+func loadBalancerValidateConfig(config map[string]string) error {
+	for key, value := range config {
+		// weight.<container>
+		if strings.HasPrefix(key, "weight.") {
+			_, err := strconv.ParseUint(value, 10, 16)
+			if err != nil {
+				return fmt.Errorf("Invalid value for %s: %s", key, value)
+			}
+
+			continue
+		}
+
+		validator, ok := loadBalancerConfigKeys[key]
+		if !ok {
+			return fmt.Errorf("Invalid load-balancer configuration key: %s", key)
+		}
+
+		err := validator(value)
+		if err != nil {
+			return fmt.Errorf("Invalid value for %s: %v", key, err)
+		}
+	}
+
+	if config["listen"] == "" {
+		return fmt.Errorf("A listen address is required")
+	}
+
+	if config["target.port"] == "" {
+		return fmt.Errorf("A target port is required")
+	}
+
+	return nil
+}
+
+func loadBalancersGet(d *Daemon, r *http.Request) Response {
+	recursion := util.IsRecursionRequest(r)
+
+	names, err := d.cluster.LoadBalancers()
+	if err != nil {
+		return SmartError(err)
+	}
+
+	resultString := []string{}
+	resultMap := []api.LoadBalancer{}
+	for _, name := range names {
+		if !recursion {
+			resultString = append(resultString, fmt.Sprintf("/%s/load-balancers/%s", version.APIVersion, name))
+		} else {
+			_, lb, err := d.cluster.LoadBalancerGet(name)
+			if err != nil {
+				continue
+			}
+
+			loadBalancerFillMembers(lb)
+			resultMap = append(resultMap, *lb)
+		}
+	}
+
+	if !recursion {
+		return SyncResponse(true, resultString)
+	}
+
+	return SyncResponse(true, resultMap)
+}
+
+func loadBalancersPost(d *Daemon, r *http.Request) Response {
+	req := api.LoadBalancersPost{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	if req.Name == "" {
+		return BadRequest(fmt.Errorf("No name provided"))
+	}
+
+	if strings.Contains(req.Name, "/") {
+		return BadRequest(fmt.Errorf("Load-balancer names may not contain slashes"))
+	}
+
+	if req.Config == nil {
+		req.Config = map[string]string{}
+	}
+
+	err = loadBalancerValidateConfig(req.Config)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	_, _, err = d.cluster.LoadBalancerGet(req.Name)
+	if err == nil {
+		return BadRequest(fmt.Errorf("The load-balancer already exists"))
+	}
+
+	_, err = d.cluster.LoadBalancerCreate(req.Name, req.Description, req.Config)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	loadBalancersTrigger()
+
+	return SyncResponseLocation(true, nil, fmt.Sprintf("/%s/load-balancers/%s", version.APIVersion, req.Name))
+}
+
+func loadBalancerGet(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	_, lb, err := d.cluster.LoadBalancerGet(name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	loadBalancerFillMembers(lb)
+
+	etag := []interface{}{lb.Name, lb.Description, lb.Config}
+	return SyncResponseETag(true, lb, etag)
+}
+
+func loadBalancerPut(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	_, lb, err := d.cluster.LoadBalancerGet(name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	// Validate the ETag
+	etag := []interface{}{lb.Name, lb.Description, lb.Config}
+	err = util.EtagCheck(r, etag)
+	if err != nil {
+		return PreconditionFailed(err)
+	}
+
+	req := api.LoadBalancerPut{}
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	return doLoadBalancerUpdate(d, name, req)
+}
+
+func loadBalancerPatch(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	_, lb, err := d.cluster.LoadBalancerGet(name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	// Validate the ETag
+	etag := []interface{}{lb.Name, lb.Description, lb.Config}
+	err = util.EtagCheck(r, etag)
+	if err != nil {
+		return PreconditionFailed(err)
+	}
+
+	req := api.LoadBalancerPut{}
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	// Merge with the current config
+	if req.Config == nil {
+		req.Config = map[string]string{}
+	}
+
+	for k, v := range lb.Config {
+		_, ok := req.Config[k]
+		if !ok {
+			req.Config[k] = v
+		}
+	}
+
+	return doLoadBalancerUpdate(d, name, req)
+}
+
+func doLoadBalancerUpdate(d *Daemon, name string, req api.LoadBalancerPut) Response {
+	if req.Config == nil {
+		req.Config = map[string]string{}
+	}
+
+	err := loadBalancerValidateConfig(req.Config)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	err = d.cluster.LoadBalancerUpdate(name, req.Description, req.Config)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	loadBalancersTrigger()
+
+	return EmptySyncResponse
+}
+
+func loadBalancerDelete(d *Daemon, r *http.Request) Response {
+	name := mux.Vars(r)["name"]
+
+	err := d.cluster.LoadBalancerDelete(name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	loadBalancersTrigger()
+
+	return EmptySyncResponse
+}
+
+// The state of the load-balancers listening on this node.
+type loadBalancerRuntime struct {
+	members []api.LoadBalancerMember
+
+	// Backends waiting for a health check
+	pending     []loadbalancer.Backend
+	changed     bool
+	healthcheck bool
+	checking    bool
+}
+
+var loadBalancersLock sync.Mutex
+var loadBalancersRunning = map[string]*loadBalancerRuntime{}
+var loadBalancersRefresh = make(chan struct{}, 1)
+
+// loadBalancersTrigger requests the load-balancers to be refreshed, following
+// a configuration change or a container starting or stopping.
+func loadBalancersTrigger() {
+	select {
+	case loadBalancersRefresh <- struct{}{}:
+	default:
+		// A refresh is already pending
+	}
+}
+
+// Fill the members as seen by this node.
+func loadBalancerFillMembers(lb *api.LoadBalancer) {
+	loadBalancersLock.Lock()
+	defer loadBalancersLock.Unlock()
+
+	rt, ok := loadBalancersRunning[lb.Name]
+	if ok {
+		lb.Members = rt.members
+	}
+}
+
+// loadBalancersManager refreshes the load-balancers every 10 seconds or when
+// triggered, which also picks up changes to containers on other nodes.
+func loadBalancersManager(d *Daemon) {
+	for {
+		loadBalancersUpdate(d)
+
+		select {
+		case <-time.After(10 * time.Second):
+		case <-loadBalancersRefresh:
+		}
+	}
+}
+
+func loadBalancersUpdate(d *Daemon) {
+	names, err := d.cluster.LoadBalancers()
+	if err != nil {
+		logger.Warn("Failed to load the load-balancers", log.Ctx{"err": err})
+		return
+	}
+
+	resolver := &loadBalancerResolver{d: d}
+	for _, name := range names {
+		_, lb, err := d.cluster.LoadBalancerGet(name)
+		if err != nil {
+			continue
+		}
+
+		err = loadBalancerApply(d, name, lb.Config, resolver)
+		if err != nil {
+			// The address may only exist on another node
+			logger.Debug("Load-balancer not running on this node", log.Ctx{"name": name, "err": err})
+		}
+	}
+
+	// Stop the deleted load-balancers
+	entries, err := ioutil.ReadDir(shared.VarPath("load-balancers"))
+	if err != nil && !os.IsNotExist(err) {
+		logger.Warn("Failed to list the running load-balancers", log.Ctx{"err": err})
+	}
+
+	for _, entry := range entries {
+		if shared.StringInSlice(entry.Name(), names) {
+			continue
+		}
+
+		err := loadBalancerStop(entry.Name())
+		if err != nil {
+			logger.Warn("Failed to stop the load-balancer", log.Ctx{"name": entry.Name(), "err": err})
+			continue
+		}
+
+		os.Remove(shared.VarPath("load-balancers", entry.Name(), "backends.conf"))
+		os.Remove(shared.VarPath("load-balancers", entry.Name()))
+	}
+
+	loadBalancersLock.Lock()
+	for name := range loadBalancersRunning {
+		if !shared.StringInSlice(name, names) {
+			delete(loadBalancersRunning, name)
+		}
+	}
+	loadBalancersLock.Unlock()
+}
+
+// loadBalancerApply makes sure the load-balancer listens on its address and
+// schedules a health check of its members, which then get the connections.
+func loadBalancerApply(d *Daemon, name string, config map[string]string, resolver *loadBalancerResolver) error {
+	if loadBalancerListening(name) != config["listen"] {
+		err := loadBalancerStop(name)
+		if err != nil {
+			return err
+		}
+
+		err = loadBalancerStart(d, name, config["listen"])
+		if err != nil {
+			return err
+		}
+	}
+
+	backends, err := resolver.backends(config)
+	if err != nil {
+		return err
+	}
+
+	loadBalancersLock.Lock()
+	defer loadBalancersLock.Unlock()
+
+	rt, ok := loadBalancersRunning[name]
+	if !ok {
+		rt = &loadBalancerRuntime{}
+		loadBalancersRunning[name] = rt
+	}
+
+	rt.pending = backends
+	rt.changed = true
+	rt.healthcheck = config["healthcheck"] == "" || shared.IsTrue(config["healthcheck"])
+
+	// Health checks run in the background, a single one at a time for
+	// each load-balancer, so that slow members don't hold the others.
+	if !rt.checking {
+		rt.checking = true
+		go loadBalancerCheck(name, rt)
+	}
+
+	return nil
+}
+
+// loadBalancerCheck health checks the pending backends of a load-balancer
+// concurrently and hands the healthy ones to its process.
+func loadBalancerCheck(name string, rt *loadBalancerRuntime) {
+	for {
+		loadBalancersLock.Lock()
+		if !rt.changed {
+			rt.checking = false
+			loadBalancersLock.Unlock()
+			return
+		}
+
+		backends := rt.pending
+		healthcheck := rt.healthcheck
+		rt.changed = false
+		loadBalancersLock.Unlock()
+
+		healthy := make([]bool, len(backends))
+		wg := sync.WaitGroup{}
+		for i, backend := range backends {
+			if !healthcheck {
+				healthy[i] = true
+				continue
+			}
+
+			wg.Add(1)
+			go func(i int, address string) {
+				defer wg.Done()
+				healthy[i] = loadbalancer.Check(address, 2*time.Second)
+			}(i, backend.Address)
+		}
+		wg.Wait()
+
+		active := []loadbalancer.Backend{}
+		members := []api.LoadBalancerMember{}
+		for i, backend := range backends {
+			if healthy[i] {
+				active = append(active, backend)
+			}
+
+			members = append(members, api.LoadBalancerMember{
+				Name:    backend.Name,
+				Address: backend.Address,
+				Weight:  backend.Weight,
+				Healthy: healthy[i],
+			})
+		}
+
+		err := loadBalancerWriteBackends(name, active)
+		if err != nil {
+			logger.Warn("Failed to update the load-balancer backends", log.Ctx{"name": name, "err": err})
+		}
+
+		loadBalancersLock.Lock()
+		rt.members = members
+		loadBalancersLock.Unlock()
+	}
+}
+
+// loadBalancerWriteBackends replaces the backends file watched by the process
+// of the load-balancer, if they changed.
+func loadBalancerWriteBackends(name string, backends []loadbalancer.Backend) error {
+	buf := bytes.Buffer{}
+	err := loadbalancer.WriteBackends(&buf, backends)
+	if err != nil {
+		return err
+	}
+
+	path := shared.VarPath("load-balancers", name, "backends.conf")
+	current, err := ioutil.ReadFile(path)
+	if err == nil && bytes.Equal(current, buf.Bytes()) {
+		return nil
+	}
+
+	// Atomically rename the file into place so the process picks it up whole.
+	err = ioutil.WriteFile(path+".tmp", buf.Bytes(), 0600)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(path+".tmp", path)
+}
+
+// loadBalancerStart spawns the process of the load-balancer. It runs outside
+// of the daemon, so the established connections survive LXD restarts.
+func loadBalancerStart(d *Daemon, name string, listen string) error {
+	// Only the nodes which have the address run the load-balancer
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		return err
+	}
+	listener.Close()
+
+	err = os.MkdirAll(shared.VarPath("load-balancers", name), 0700)
+	if err != nil {
+		return err
+	}
+
+	backendsPath := shared.VarPath("load-balancers", name, "backends.conf")
+	if !shared.PathExists(backendsPath) {
+		err = ioutil.WriteFile(backendsPath, []byte{}, 0600)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = shared.RunCommand(
+		d.os.ExecPath,
+		"forkloadbalancer",
+		shared.LogPath(fmt.Sprintf("forkloadbalancer.%s.log", name)),
+		shared.VarPath("load-balancers", name, "forkloadbalancer.pid"),
+		listen,
+		backendsPath,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// loadBalancerProcess returns the pid and the command line of the process of
+// the load-balancer, or -1 if it isn't running.
+func loadBalancerProcess(name string) (int, []string) {
+	pidPath := shared.VarPath("load-balancers", name, "forkloadbalancer.pid")
+
+	content, err := ioutil.ReadFile(pidPath)
+	if err != nil {
+		return -1, nil
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil {
+		os.Remove(pidPath)
+		return -1, nil
+	}
+
+	// Check that it's still the load-balancer
+	cmdArgs, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+	if err != nil {
+		os.Remove(pidPath)
+		return -1, nil
+	}
+
+	cmdFields := strings.Split(string(bytes.TrimRight(cmdArgs, string("\x00"))), string(byte(0)))
+	if len(cmdFields) < 6 || cmdFields[1] != "forkloadbalancer" {
+		os.Remove(pidPath)
+		return -1, nil
+	}
+
+	return pid, cmdFields
+}
+
+// loadBalancerListening returns the address the process of the load-balancer
+// listens on, or an empty string if it isn't running.
+func loadBalancerListening(name string) string {
+	pid, cmdFields := loadBalancerProcess(name)
+	if pid < 0 {
+		return ""
+	}
+
+	return cmdFields[4]
+}
+
+// loadBalancerStop kills the process of the load-balancer.
+func loadBalancerStop(name string) error {
+	pid, _ := loadBalancerProcess(name)
+	if pid < 0 {
+		return nil
+	}
+
+	err := unix.Kill(pid, unix.SIGKILL)
+	if err != nil {
+		return err
+	}
+
+	os.Remove(shared.VarPath("load-balancers", name, "forkloadbalancer.pid"))
+	return nil
+}
+
+// loadBalancerResolver resolves the members of the load-balancers to
+// addresses from the database, loading it once per refresh.
+type loadBalancerResolver struct {
+	d *Daemon
+
+	loaded    bool
+	instances map[string]db.Instance
+	nodes     map[string]string
+	localNode string
+
+	// Dynamic leases by node and network
+	leases map[string][]api.NetworkLease
+}
+
+func (r *loadBalancerResolver) load() error {
+	if r.loaded {
+		return nil
+	}
+
+	r.instances = map[string]db.Instance{}
+	r.nodes = map[string]string{}
+	r.leases = map[string][]api.NetworkLease{}
+
+	err := r.d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		instances, err := tx.ContainerListExpanded()
+		if err != nil {
+			return err
+		}
+
+		for _, instance := range instances {
+			r.instances[fmt.Sprintf("%s/%s", instance.Project, instance.Name)] = instance
+		}
+
+		nodes, err := tx.Nodes()
+		if err != nil {
+			return err
+		}
+
+		for _, node := range nodes {
+			r.nodes[node.Name] = node.Address
+		}
+
+		r.localNode, err = tx.NodeName()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	r.loaded = true
+	return nil
+}
+
+// backends resolves the running members of the load-balancer, whether listed
+// by name or through a profile, to addresses.
+func (r *loadBalancerResolver) backends(config map[string]string) ([]loadbalancer.Backend, error) {
+	err := r.load()
+	if err != nil {
+		return nil, err
+	}
+
+	project := config["members.project"]
+	if project == "" {
+		project = "default"
+	}
+
+	names := []string{}
+	for _, name := range strings.Split(config["members"], ",") {
+		name = strings.TrimSpace(name)
+		if name != "" && !shared.StringInSlice(name, names) {
+			names = append(names, name)
+		}
+	}
+
+	if config["members.profile"] != "" {
+		for _, instance := range r.instances {
+			if instance.Project == project && shared.StringInSlice(config["members.profile"], instance.Profiles) && !shared.StringInSlice(instance.Name, names) {
+				names = append(names, instance.Name)
+			}
+		}
+	}
+
+	sort.Strings(names)
+
+	backends := []loadbalancer.Backend{}
+	for _, name := range names {
+		address := r.memberAddress(project, name)
+		if address == "" {
+			continue
+		}
+
+		weight := 1
+		if config[fmt.Sprintf("weight.%s", name)] != "" {
+			weight, _ = strconv.Atoi(config[fmt.Sprintf("weight.%s", name)])
+		}
+
+		backends = append(backends, loadbalancer.Backend{
+			Name:    name,
+			Address: net.JoinHostPort(address, config["target.port"]),
+			Weight:  weight,
+		})
+	}
+
+	return backends, nil
+}
+
+// memberAddress returns the first address of a running container, preferring
+// IPv4, or an empty string if it isn't running or has no known address. The
+// addresses are the static ones of its NICs or, for bridged NICs without one,
+// those handed out by the dnsmasq of their network.
+func (r *loadBalancerResolver) memberAddress(project string, name string) string {
+	instance, ok := r.instances[fmt.Sprintf("%s/%s", project, name)]
+	if !ok || instance.Config["volatile.last_state.power"] != "RUNNING" {
+		return ""
+	}
+
+	devNames := []string{}
+	for devName, m := range instance.Devices {
+		if m["type"] == "nic" {
+			devNames = append(devNames, devName)
+		}
+	}
+	sort.Strings(devNames)
+
+	addresses := []string{}
+	for _, devName := range devNames {
+		m := instance.Devices[devName]
+		for _, key := range []string{"ipv4.address", "ipv6.address"} {
+			for _, address := range strings.Split(m[key], ",") {
+				address = strings.TrimSpace(address)
+				if address != "" {
+					addresses = append(addresses, address)
+				}
+			}
+		}
+
+		if m["nictype"] != "bridged" || m["ipv4.address"] != "" {
+			continue
+		}
+
+		hwaddr := m["hwaddr"]
+		if hwaddr == "" {
+			hwaddr = instance.Config[fmt.Sprintf("volatile.%s.hwaddr", devName)]
+		}
+
+		for _, lease := range r.networkLeases(instance.Node, m["parent"]) {
+			if hwaddr != "" && strings.EqualFold(lease.Hwaddr, hwaddr) {
+				addresses = append(addresses, lease.Address)
+			}
+		}
+	}
+
+	for _, v4 := range []bool{true, false} {
+		for _, address := range addresses {
+			ip := net.ParseIP(address)
+			if ip != nil && (ip.To4() != nil) == v4 {
+				return address
+			}
+		}
+	}
+
+	return ""
+}
+
+// networkLeases returns the dynamic leases of a network on a node, fetching
+// them once per refresh.
+func (r *loadBalancerResolver) networkLeases(node string, network string) []api.NetworkLease {
+	key := fmt.Sprintf("%s/%s", node, network)
+	leases, ok := r.leases[key]
+	if ok {
+		return leases
+	}
+
+	var err error
+	if node == r.localNode {
+		leases, err = networkDynamicLeases(network, node)
+	} else {
+		var client lxd.ContainerServer
+		client, err = cluster.Connect(r.nodes[node], r.d.endpoints.NetworkCert(), true)
+		if err == nil {
+			leases, err = client.GetNetworkLeases(network)
+		}
+	}
+	if err != nil {
+		logger.Debug("Failed to get the network leases", log.Ctx{"node": node, "network": network, "err": err})
+		leases = []api.NetworkLease{}
+	}
+
+	r.leases[key] = leases
+	return leases
+}
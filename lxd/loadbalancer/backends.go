@@ -0,0 +1,54 @@
+package loadbalancer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// WriteBackends writes the backends, one "<name> <address> <weight>" line
+// each.
+func WriteBackends(w io.Writer, backends []Backend) error {
+	for _, be := range backends {
+		_, err := fmt.Fprintf(w, "%s %s %d\n", be.Name, be.Address, be.Weight)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ParseBackends reads backends written by WriteBackends.
+func ParseBackends(r io.Reader) ([]Backend, error) {
+	backends := []Backend{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("Invalid backend entry: %s", line)
+		}
+
+		weight, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid backend weight: %s", line)
+		}
+
+		backends = append(backends, Backend{Name: fields[0], Address: fields[1], Weight: weight})
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return backends, nil
+}
@@ -0,0 +1,189 @@
+package loadbalancer
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrNoBackend is returned when no backend is available to handle a
+// connection.
+var ErrNoBackend = fmt.Errorf("No backend available")
+
+// Backend represents a destination connections get forwarded to.
+type Backend struct {
+	Name    string
+	Address string
+	Weight  int
+}
+
+type backend struct {
+	Backend
+
+	// Current weight used by the smooth weighted round-robin selection
+	current int
+}
+
+// Balancer distributes the TCP connections it accepts across its backends,
+// using smooth weighted round-robin.
+type Balancer struct {
+	listener net.Listener
+
+	mu       sync.Mutex
+	backends []*backend
+}
+
+// Listen returns a new Balancer accepting connections on the given address.
+// It has no backend, connections being dropped until SetBackends is called.
+func Listen(address string) (*Balancer, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Balancer{listener: listener}
+	go b.serve()
+
+	return b, nil
+}
+
+// Address returns the address the balancer is listening on.
+func (b *Balancer) Address() string {
+	return b.listener.Addr().String()
+}
+
+// Close stops accepting new connections. Established ones are kept.
+func (b *Balancer) Close() error {
+	return b.listener.Close()
+}
+
+// SetBackends replaces the backends of the balancer, preserving the
+// selection state of the ones which are still present.
+func (b *Balancer) SetBackends(backends []Backend) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	current := map[string]int{}
+	for _, be := range b.backends {
+		current[be.Address] = be.current
+	}
+
+	b.backends = make([]*backend, len(backends))
+	for i, be := range backends {
+		b.backends[i] = &backend{Backend: be, current: current[be.Address]}
+	}
+}
+
+// Backends returns the current backends of the balancer.
+func (b *Balancer) Backends() []Backend {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	backends := make([]Backend, len(b.backends))
+	for i, be := range b.backends {
+		backends[i] = be.Backend
+	}
+
+	return backends
+}
+
+// Next returns the address of the backend which should handle the next
+// connection. Backends with a weight of zero are never picked.
+func (b *Balancer) Next() (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	total := 0
+	var best *backend
+	for _, be := range b.backends {
+		if be.Weight <= 0 {
+			continue
+		}
+
+		be.current += be.Weight
+		total += be.Weight
+
+		if best == nil || be.current > best.current {
+			best = be
+		}
+	}
+
+	if best == nil {
+		return "", ErrNoBackend
+	}
+
+	best.current -= total
+	return best.Address, nil
+}
+
+func (b *Balancer) serve() {
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			netErr, ok := err.(net.Error)
+			if ok && netErr.Temporary() {
+				time.Sleep(100 * time.Millisecond)
+				continue
+			}
+
+			// Listener closed
+			return
+		}
+
+		go b.forward(conn)
+	}
+}
+
+func (b *Balancer) forward(conn net.Conn) {
+	defer conn.Close()
+
+	address, err := b.Next()
+	if err != nil {
+		return
+	}
+
+	upstream, err := net.DialTimeout("tcp", address, 5*time.Second)
+	if err != nil {
+		return
+	}
+	defer upstream.Close()
+
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		io.Copy(upstream, conn)
+		closeWrite(upstream)
+	}()
+
+	go func() {
+		defer wg.Done()
+		io.Copy(conn, upstream)
+		closeWrite(conn)
+	}()
+
+	wg.Wait()
+}
+
+// Propagate the end of one direction of the stream to the other side.
+func closeWrite(conn net.Conn) {
+	tcpConn, ok := conn.(*net.TCPConn)
+	if ok {
+		tcpConn.CloseWrite()
+	}
+}
+
+// Check returns whether a TCP connection can be established to the given
+// address within the timeout.
+func Check(address string, timeout time.Duration) bool {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return false
+	}
+
+	conn.Close()
+	return true
+}
@@ -0,0 +1,93 @@
+package loadbalancer
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBalancer_Next(t *testing.T) {
+	b := &Balancer{}
+	b.SetBackends([]Backend{
+		{Name: "a", Address: "a:80", Weight: 5},
+		{Name: "b", Address: "b:80", Weight: 1},
+		{Name: "c", Address: "c:80", Weight: 1},
+		{Name: "d", Address: "d:80", Weight: 0},
+	})
+
+	picks := []string{}
+	for i := 0; i < 7; i++ {
+		address, err := b.Next()
+		require.NoError(t, err)
+		picks = append(picks, address)
+	}
+
+	// Smooth weighted round-robin interleaves the backends.
+	assert.Equal(t, []string{"a:80", "a:80", "b:80", "a:80", "c:80", "a:80", "a:80"}, picks)
+}
+
+func TestBalancer_NextNoBackend(t *testing.T) {
+	b := &Balancer{}
+	b.SetBackends([]Backend{{Name: "a", Address: "a:80", Weight: 0}})
+
+	_, err := b.Next()
+	assert.Equal(t, ErrNoBackend, err)
+}
+
+func TestBalancer_Forward(t *testing.T) {
+	backend, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer backend.Close()
+
+	go func() {
+		for {
+			conn, err := backend.Accept()
+			if err != nil {
+				return
+			}
+
+			conn.Write([]byte("hello"))
+			conn.Close()
+		}
+	}()
+
+	b, err := Listen("127.0.0.1:0")
+	require.NoError(t, err)
+	defer b.Close()
+
+	b.SetBackends([]Backend{{Name: "backend", Address: backend.Addr().String(), Weight: 1}})
+	assert.True(t, Check(backend.Addr().String(), time.Second))
+
+	conn, err := net.Dial("tcp", b.Address())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	content, err := ioutil.ReadAll(conn)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(content))
+}
+
+func TestBackends_WriteParse(t *testing.T) {
+	backends := []Backend{
+		{Name: "a", Address: "10.0.0.2:80", Weight: 5},
+		{Name: "b", Address: "[fd42::2]:80", Weight: 0},
+	}
+
+	buf := bytes.Buffer{}
+	require.NoError(t, WriteBackends(&buf, backends))
+	assert.Equal(t, "a 10.0.0.2:80 5\nb [fd42::2]:80 0\n", buf.String())
+
+	parsed, err := ParseBackends(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, backends, parsed)
+
+	_, err = ParseBackends(strings.NewReader("a 10.0.0.2:80\n"))
+	assert.Error(t, err)
+}
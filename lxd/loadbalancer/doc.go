@@ -0,0 +1,3 @@
+// Package loadbalancer implements a TCP load-balancer distributing
+// connections across a set of weighted backends.
+package loadbalancer
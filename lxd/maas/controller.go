@@ -241,6 +241,21 @@ func (c *Controller) DefinedContainer(name string) (bool, error) {
 	return false, nil
 }
 
+// DefinedContainers returns the names of all the devices defined in MAAS for the machine
+func (c *Controller) DefinedContainers() ([]string, error) {
+	devs, err := c.machine.Devices(gomaasapi.DevicesArgs{})
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, dev := range devs {
+		names = append(names, dev.Hostname())
+	}
+
+	return names, nil
+}
+
 // UpdateContainer updates the MAAS device's interfaces with the new provided state
 func (c *Controller) UpdateContainer(name string, interfaces []ContainerInterface) error {
 	// Parse the provided interfaces
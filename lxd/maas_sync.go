@@ -0,0 +1,339 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/project"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+var maasSyncCmd = APIEndpoint{
+	Name: "maas/sync",
+
+	Get: APIEndpointAction{Handler: maasSyncGet},
+}
+
+var maasReconcileCmd = APIEndpoint{
+	Name: "maas/reconcile",
+
+	Post: APIEndpointAction{Handler: maasReconcilePost},
+}
+
+// Delay before retrying a failed MAAS sync, doubled after each failure.
+const maasSyncRetryMin = 30 * time.Second
+const maasSyncRetryMax = time.Hour
+
+// Number of failed attempts after which a record is reported as persistently
+// out of sync.
+const maasSyncWarnAttempts = 5
+
+type maasSyncEntry struct {
+	project   string
+	container string
+
+	// Either "update" (create or update the record) or "delete"
+	action string
+
+	attempts    int
+	lastError   string
+	lastAttempt time.Time
+	nextAttempt time.Time
+}
+
+// The failed syncs, indexed by MAAS record name. A newer failure for the same
+// record replaces the pending one.
+var maasSyncLock sync.Mutex
+var maasSyncQueue = map[string]*maasSyncEntry{}
+var maasSyncTrigger = make(chan struct{}, 1)
+
+// maasSyncQueueAdd queues a MAAS record for synchronization in the background.
+// It's used when MAAS couldn't be updated as part of a container change.
+func maasSyncQueueAdd(name string, projectName string, containerName string, action string, err error) {
+	entry := &maasSyncEntry{
+		project:     projectName,
+		container:   containerName,
+		action:      action,
+		nextAttempt: time.Now().Add(maasSyncRetryMin),
+	}
+
+	if err != nil {
+		entry.lastError = err.Error()
+		entry.lastAttempt = time.Now()
+		entry.attempts = 1
+	} else {
+		entry.nextAttempt = time.Now()
+	}
+
+	maasSyncLock.Lock()
+	maasSyncQueue[name] = entry
+	maasSyncLock.Unlock()
+
+	if err != nil {
+		logger.Warn("Failed to update MAAS record, will retry", log.Ctx{"name": name, "action": action, "err": err})
+	}
+
+	select {
+	case maasSyncTrigger <- struct{}{}:
+	default:
+	}
+}
+
+// maasSyncManager retries the failed MAAS syncs with an exponential back-off.
+func maasSyncManager(d *Daemon) {
+	for {
+		select {
+		case <-time.After(maasSyncRetryMin):
+		case <-maasSyncTrigger:
+		}
+
+		maasSyncRun(d.State())
+	}
+}
+
+func maasSyncRun(s *state.State) {
+	now := time.Now()
+
+	maasSyncLock.Lock()
+	due := map[string]maasSyncEntry{}
+	for name, entry := range maasSyncQueue {
+		if !entry.nextAttempt.After(now) {
+			due[name] = *entry
+		}
+	}
+	maasSyncLock.Unlock()
+
+	for name, entry := range due {
+		err := maasSyncApply(s, name, entry)
+
+		maasSyncLock.Lock()
+		current, ok := maasSyncQueue[name]
+		if !ok || current.action != entry.action || current.lastAttempt != entry.lastAttempt {
+			// Replaced while being applied
+			maasSyncLock.Unlock()
+			continue
+		}
+
+		if err == nil {
+			delete(maasSyncQueue, name)
+			maasSyncLock.Unlock()
+			logger.Info("Synchronized MAAS record", log.Ctx{"name": name, "action": entry.action})
+			continue
+		}
+
+		current.attempts++
+		current.lastError = err.Error()
+		current.lastAttempt = time.Now()
+
+		delay := maasSyncRetryMax
+		if current.attempts < 8 {
+			delay = maasSyncRetryMin * time.Duration(1<<uint(current.attempts-1))
+			if delay > maasSyncRetryMax {
+				delay = maasSyncRetryMax
+			}
+		}
+		current.nextAttempt = current.lastAttempt.Add(delay)
+		attempts := current.attempts
+		maasSyncLock.Unlock()
+
+		if attempts == maasSyncWarnAttempts {
+			logger.Warn("MAAS record is persistently out of sync", log.Ctx{"name": name, "action": entry.action, "attempts": attempts, "err": err})
+		} else {
+			logger.Debug("Failed to synchronize MAAS record", log.Ctx{"name": name, "action": entry.action, "attempts": attempts, "err": err})
+		}
+	}
+}
+
+func maasSyncApply(s *state.State, name string, entry maasSyncEntry) error {
+	// MAAS may have been disabled in the meantime
+	maasURL, err := cluster.ConfigGetString(s.Cluster, "maas.api.url")
+	if err != nil {
+		return err
+	}
+
+	if maasURL == "" {
+		return nil
+	}
+
+	if entry.action == "update" {
+		c, err := containerLoadByProjectAndName(s, entry.project, entry.container)
+		if err != nil {
+			if errors.Cause(err) == db.ErrNoSuchObject {
+				// The container is gone, nothing to sync anymore
+				return nil
+			}
+
+			return err
+		}
+
+		ct, ok := c.(*containerLXC)
+		if !ok {
+			return fmt.Errorf("Unsupported container type")
+		}
+
+		interfaces, err := ct.maasInterfaces(ct.expandedDevices)
+		if err != nil {
+			return err
+		}
+
+		// Fallback to removing the record if MAAS isn't used anymore
+		if len(interfaces) > 0 {
+			return ct.maasUpdate(nil)
+		}
+	}
+
+	if s.MAAS == nil {
+		return fmt.Errorf("Can't perform the operation because MAAS is currently unavailable")
+	}
+
+	exists, err := s.MAAS.DefinedContainer(name)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return nil
+	}
+
+	return s.MAAS.DeleteContainer(name)
+}
+
+func maasSyncGet(d *Daemon, r *http.Request) Response {
+	maasSyncLock.Lock()
+	entries := []api.MAASSyncEntry{}
+	for name, entry := range maasSyncQueue {
+		entries = append(entries, api.MAASSyncEntry{
+			Name:        name,
+			Project:     entry.project,
+			Container:   entry.container,
+			Action:      entry.action,
+			Attempts:    entry.attempts,
+			LastError:   entry.lastError,
+			LastAttempt: entry.lastAttempt,
+			NextAttempt: entry.nextAttempt,
+			Persistent:  entry.attempts >= maasSyncWarnAttempts,
+		})
+	}
+	maasSyncLock.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+
+	return SyncResponse(true, entries)
+}
+
+func maasReconcilePost(d *Daemon, r *http.Request) Response {
+	req := api.MAASReconcilePost{}
+
+	// Parse the request, an empty body is allowed
+	if r.ContentLength != 0 {
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			return BadRequest(err)
+		}
+	}
+
+	maasURL, err := cluster.ConfigGetString(d.cluster, "maas.api.url")
+	if err != nil {
+		return SmartError(err)
+	}
+
+	if maasURL == "" {
+		return BadRequest(fmt.Errorf("MAAS isn't configured"))
+	}
+
+	run := func(op *operation) error {
+		mismatches, err := maasReconcile(d.State())
+		if err != nil {
+			return err
+		}
+
+		if req.Fix {
+			for _, mismatch := range mismatches {
+				if mismatch.Issue == "missing" {
+					maasSyncQueueAdd(mismatch.Name, mismatch.Project, mismatch.Container, "update", nil)
+				} else {
+					maasSyncQueueAdd(mismatch.Name, "", "", "delete", nil)
+				}
+			}
+		}
+
+		return op.UpdateMetadata(shared.Jmap{"mismatches": mismatches})
+	}
+
+	op, err := operationCreate(d.cluster, "", operationClassTask, db.OperationMAASReconcile, nil, nil, run, nil, nil)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	return OperationResponse(op)
+}
+
+// maasReconcile compares the MAAS devices of this node's machine with the
+// local containers having MAAS connected interfaces.
+func maasReconcile(s *state.State) ([]api.MAASMismatch, error) {
+	if s.MAAS == nil {
+		return nil, fmt.Errorf("Can't perform the operation because MAAS is currently unavailable")
+	}
+
+	defined, err := s.MAAS.DefinedContainers()
+	if err != nil {
+		return nil, err
+	}
+
+	containers, err := containerLoadNodeAll(s)
+	if err != nil {
+		return nil, err
+	}
+
+	mismatches := []api.MAASMismatch{}
+	expected := []string{}
+	for _, c := range containers {
+		ct, ok := c.(*containerLXC)
+		if !ok {
+			continue
+		}
+
+		interfaces, err := ct.maasInterfaces(ct.expandedDevices)
+		if err != nil || len(interfaces) == 0 {
+			continue
+		}
+
+		name := project.Prefix(c.Project(), c.Name())
+		expected = append(expected, name)
+
+		if !shared.StringInSlice(name, defined) {
+			mismatches = append(mismatches, api.MAASMismatch{
+				Name:      name,
+				Project:   c.Project(),
+				Container: c.Name(),
+				Issue:     "missing",
+			})
+		}
+	}
+
+	for _, name := range defined {
+		if !shared.StringInSlice(name, expected) {
+			mismatches = append(mismatches, api.MAASMismatch{
+				Name:  name,
+				Issue: "stale",
+			})
+		}
+	}
+
+	return mismatches, nil
+}
@@ -104,6 +104,14 @@ func main() {
 	forkfileCmd := cmdForkfile{global: &globalCmd}
 	app.AddCommand(forkfileCmd.Command())
 
+	// forkhugetlbfs sub-command
+	forkhugetlbfsCmd := cmdForkHugetlbfs{global: &globalCmd}
+	app.AddCommand(forkhugetlbfsCmd.Command())
+
+	// forkloadbalancer sub-command
+	forkLoadBalancerCmd := cmdForkLoadBalancer{global: &globalCmd}
+	app.AddCommand(forkLoadBalancerCmd.Command())
+
 	// forkmigrate sub-command
 	forkmigrateCmd := cmdForkmigrate{global: &globalCmd}
 	app.AddCommand(forkmigrateCmd.Command())
@@ -23,7 +23,10 @@ func (c *cmdCallhook) Command() *cobra.Command {
   Call container lifecycle hook in LXD
 
   This internal command notifies LXD about a container lifecycle event
-  (start, stopns, stop, restart) and blocks until LXD has processed it.
+  (start, starthost, stopns, stop, restart) and blocks until LXD has processed it.
+
+  Hooks taking a while can call it with the keepalive event to extend the
+  timeout of the running start, stop or restore operation.
 `
 	cmd.RunE = c.Run
 	cmd.Hidden = true
@@ -82,6 +85,8 @@ func (c *cmdCallhook) Run(cmd *cobra.Command, args []string) error {
 			target = "unknown"
 		}
 		url = fmt.Sprintf("%s?target=%s", url, target)
+	} else if state == "starthost" {
+		url = fmt.Sprintf("%s?pid=%s", url, os.Getenv("LXC_PID"))
 	} else if state == "network-up" {
 		url = fmt.Sprintf("%s?device=%s&host_name=%s", url, args[3], os.Getenv("LXC_NET_PEER"))
 	}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"unsafe"
+
 	"github.com/lxc/lxd/shared/logger"
 )
 
@@ -30,6 +32,7 @@ import (
 bool netnsid_aware = false;
 bool uevent_aware = false;
 bool seccomp_notify_aware = false;
+bool idmapped_mounts_aware = false;
 char errbuf[4096];
 
 extern int can_inject_uevent(const char *uevent, size_t len);
@@ -146,6 +149,135 @@ void is_seccomp_notify_aware(void)
 
 }
 
+#ifndef __NR_open_tree
+#define __NR_open_tree 428
+#endif
+
+#ifndef __NR_mount_setattr
+#define __NR_mount_setattr 442
+#endif
+
+#ifndef OPEN_TREE_CLONE
+#define OPEN_TREE_CLONE 1
+#endif
+
+#ifndef MOUNT_ATTR_IDMAP
+#define MOUNT_ATTR_IDMAP 0x00100000
+#endif
+
+#ifndef AT_EMPTY_PATH
+#define AT_EMPTY_PATH 0x1000
+#endif
+
+struct lxd_mount_attr {
+	__u64 attr_set;
+	__u64 attr_clr;
+	__u64 propagation;
+	__u64 userns_fd;
+};
+
+static int write_id_map(pid_t pid, const char *file, const char *map)
+{
+	__do_close_prot_errno int fd = -EBADF;
+	char path[64];
+
+	(void)snprintf(path, sizeof(path), "/proc/%d/%s", pid, file);
+	fd = open(path, O_WRONLY | O_CLOEXEC);
+	if (fd < 0)
+		return -1;
+
+	if (write(fd, map, strlen(map)) != (ssize_t)strlen(map))
+		return -1;
+
+	return 0;
+}
+
+// Get a file descriptor to a new user namespace with a single mapped id,
+// kept alive by a child process until it's opened.
+static int get_userns_fd(void)
+{
+	int ready[2], hold[2];
+	int userns_fd = -EBADF;
+	char path[64], c = 0;
+	pid_t pid;
+
+	if (pipe2(ready, O_CLOEXEC) < 0)
+		return -1;
+
+	if (pipe2(hold, O_CLOEXEC) < 0) {
+		close(ready[0]);
+		close(ready[1]);
+		return -1;
+	}
+
+	pid = fork();
+	if (pid < 0) {
+		close(ready[0]);
+		close(ready[1]);
+		close(hold[0]);
+		close(hold[1]);
+		return -1;
+	}
+
+	if (pid == 0) {
+		close(ready[0]);
+		close(hold[1]);
+
+		if (unshare(CLONE_NEWUSER) < 0)
+			_exit(EXIT_FAILURE);
+
+		if (write(ready[1], &c, 1) != 1)
+			_exit(EXIT_FAILURE);
+
+		// Wait for the parent to be done with the namespace.
+		(void)read(hold[0], &c, 1);
+		_exit(EXIT_SUCCESS);
+	}
+
+	close(ready[1]);
+	close(hold[0]);
+
+	if (read(ready[0], &c, 1) == 1 &&
+	    write_id_map(pid, "uid_map", "0 0 1") == 0 &&
+	    write_id_map(pid, "gid_map", "0 0 1") == 0) {
+		(void)snprintf(path, sizeof(path), "/proc/%d/ns/user", pid);
+		userns_fd = open(path, O_RDONLY | O_CLOEXEC);
+	}
+
+	close(ready[0]);
+	close(hold[1]);
+	(void)waitpid(pid, NULL, 0);
+
+	return userns_fd;
+}
+
+static bool path_idmapped_mounts_aware(const char *path)
+{
+	__do_close_prot_errno int tree_fd = -EBADF, userns_fd = -EBADF;
+	struct lxd_mount_attr attr = {
+		.attr_set = MOUNT_ATTR_IDMAP,
+	};
+
+	tree_fd = syscall(__NR_open_tree, -EBADF, path, OPEN_TREE_CLONE | O_CLOEXEC);
+	if (tree_fd < 0)
+		return false;
+
+	userns_fd = get_userns_fd();
+	if (userns_fd < 0)
+		return false;
+
+	attr.userns_fd = userns_fd;
+	if (syscall(__NR_mount_setattr, tree_fd, "", AT_EMPTY_PATH, &attr, sizeof(attr)) < 0)
+		return false;
+
+	return true;
+}
+
+void is_idmapped_mounts_aware(void)
+{
+	idmapped_mounts_aware = path_idmapped_mounts_aware("/");
+}
+
 void checkfeature()
 {
 	__do_close_prot_errno int hostnetns_fd = -EBADF, newnetns_fd = -EBADF;
@@ -153,6 +285,7 @@ void checkfeature()
 	is_netnsid_aware(&hostnetns_fd, &newnetns_fd);
 	is_uevent_aware();
 	is_seccomp_notify_aware();
+	is_idmapped_mounts_aware();
 
 	if (setns(hostnetns_fd, CLONE_NEWNET) < 0)
 		(void)sprintf(errbuf, "%s", "Failed to attach to host network namespace");
@@ -182,3 +315,16 @@ func CanUseUeventInjection() bool {
 func CanUseSeccompListener() bool {
 	return bool(C.seccomp_notify_aware)
 }
+
+func CanUseIdmappedMounts() bool {
+	return bool(C.idmapped_mounts_aware)
+}
+
+// CanIdmapPath returns whether the filesystem a path is on supports idmapped
+// mounts, which depends on the filesystem type as much as on the kernel.
+func CanIdmapPath(path string) bool {
+	cPath := C.CString(path)
+	defer C.free(unsafe.Pointer(cPath))
+
+	return bool(C.path_idmapped_mounts_aware(cPath))
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"runtime"
 	"strconv"
 	"strings"
 
@@ -123,6 +124,18 @@ func (c *cmdForkexec) Run(cmd *cobra.Command, args []string) error {
 		opts.Cwd = cwd
 	}
 
+	// Join the core scheduling group of the container, if any. The cookie
+	// is per thread and inherited by the attached process, so the command
+	// must be spawned from the same thread.
+	runtime.LockOSThread()
+	cookie, err := coreSchedulingCookie(d.InitPid())
+	if err == nil && cookie != 0 {
+		err = coreSchedulingShareFrom(d.InitPid())
+		if err != nil {
+			return fmt.Errorf("Failed to join the core scheduling group: %q", err)
+		}
+	}
+
 	// Exec the command
 	status, err := d.RunCommandNoWait(command, opts)
 	if err != nil {
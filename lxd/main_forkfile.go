@@ -16,6 +16,7 @@ import (
 #include <stdlib.h>
 #include <string.h>
 #include <sys/stat.h>
+#include <sys/statvfs.h>
 #include <unistd.h>
 #include <limits.h>
 
@@ -401,6 +402,47 @@ void forkremovefile(char *rootfs, pid_t pid) {
 	_exit(0);
 }
 
+void forkstatfs(char *rootfs, pid_t pid) {
+	char *path = NULL;
+	struct statvfs sb;
+
+	if (pid > 0) {
+		attach_userns(pid);
+
+		if (dosetns(pid, "mnt") < 0) {
+			error("error: setns");
+			_exit(1);
+		}
+	} else {
+		if (chroot(rootfs) < 0) {
+			error("error: chroot");
+			_exit(1);
+		}
+
+		if (chdir("/") < 0) {
+			error("error: chdir");
+			_exit(1);
+		}
+	}
+
+	// One line per path, in the order they were passed
+	while ((path = advance_arg(false)) != NULL) {
+		if (statvfs(path, &sb) < 0) {
+			printf("error: %s\n", strerror(errno));
+			continue;
+		}
+
+		printf("%llu %llu %llu %llu %llu\n",
+		       (unsigned long long)sb.f_blocks * sb.f_frsize,
+		       (unsigned long long)sb.f_bfree * sb.f_frsize,
+		       (unsigned long long)sb.f_bavail * sb.f_frsize,
+		       (unsigned long long)sb.f_files,
+		       (unsigned long long)sb.f_ffree);
+	}
+
+	_exit(0);
+}
+
 void forkfile() {
 	char *command = NULL;
 	char *rootfs = NULL;
@@ -436,6 +478,8 @@ void forkfile() {
 		forkcheckfile(rootfs, pid);
 	} else if (strcmp(command, "remove") == 0) {
 		forkremovefile(rootfs, pid);
+	} else if (strcmp(command, "statfs") == 0) {
+		forkstatfs(rootfs, pid);
 	}
 }
 */
@@ -488,6 +532,13 @@ func (c *cmdForkfile) Command() *cobra.Command {
 	cmdRemove.RunE = c.Run
 	cmd.AddCommand(cmdRemove)
 
+	// statfs
+	cmdStatfs := &cobra.Command{}
+	cmdStatfs.Use = "statfs <rootfs> <PID> <path>..."
+	cmdStatfs.Args = cobra.MinimumNArgs(3)
+	cmdStatfs.RunE = c.Run
+	cmd.AddCommand(cmdStatfs)
+
 	return cmd
 }
 
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sys/unix"
+
+	"github.com/lxc/lxd/shared"
+)
+
+type cmdForkHugetlbfs struct {
+	global *cmdGlobal
+}
+
+func (c *cmdForkHugetlbfs) Command() *cobra.Command {
+	// Main subcommand
+	cmd := &cobra.Command{}
+	cmd.Use = "forkhugetlbfs <PID> <path> <options>"
+	cmd.Short = "Mount hugetlbfs from the hugetlb cgroup of a container"
+	cmd.Long = `Description:
+  Mount hugetlbfs from the hugetlb cgroup of a container
+
+  This internal command is used to mount hugepages backed disks, the huge
+  pages reserved by the mount being charged to the container rather than
+  to LXD.
+`
+	cmd.RunE = c.Run
+	cmd.Hidden = true
+
+	return cmd
+}
+
+func (c *cmdForkHugetlbfs) Run(cmd *cobra.Command, args []string) error {
+	// Sanity checks
+	if len(args) != 3 {
+		cmd.Help()
+
+		if len(args) == 0 {
+			return nil
+		}
+
+		return fmt.Errorf("Missing required arguments")
+	}
+
+	// Only root should run this
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("This must be run as root")
+	}
+
+	pid, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("Invalid PID %q", args[0])
+	}
+
+	// Join the hugetlb cgroup of the container
+	path, err := c.hugetlbCGroup(pid)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(filepath.Join(path, "cgroup.procs"), os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.WriteString(fmt.Sprintf("%d\n", os.Getpid()))
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("Failed to join the hugetlb cgroup of PID %d: %v", pid, err)
+	}
+
+	// The pages reserved by the mount are charged to the cgroup
+	err = unix.Mount("hugetlbfs", args[1], "hugetlbfs", 0, args[2])
+	if err != nil {
+		return fmt.Errorf("Failed to mount hugetlbfs on %s: %v", args[1], err)
+	}
+
+	return nil
+}
+
+// hugetlbCGroup returns the path of the hugetlb cgroup of a process.
+func (c *cmdForkHugetlbfs) hugetlbCGroup(pid int) (string, error) {
+	f, err := os.Open(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	unified := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+
+		if fields[0] == "0" && fields[1] == "" {
+			unified = fields[2]
+			continue
+		}
+
+		for _, controller := range strings.Split(fields[1], ",") {
+			if controller == "hugetlb" {
+				return filepath.Join("/sys/fs/cgroup/hugetlb", fields[2]), nil
+			}
+		}
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return "", err
+	}
+
+	// Hosts only having the unified hierarchy
+	if unified != "" && shared.PathExists("/sys/fs/cgroup/cgroup.controllers") {
+		return filepath.Join("/sys/fs/cgroup", unified), nil
+	}
+
+	return "", fmt.Errorf("No hugetlb cgroup for PID %d", pid)
+}
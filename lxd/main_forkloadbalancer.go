@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/fsnotify.v0"
+
+	"github.com/lxc/lxd/lxd/loadbalancer"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/logging"
+)
+
+/*
+extern void forkdns();
+
+// forkloadbalancer daemonizes the same way as forkdns, using the log and pid
+// paths from its arguments.
+void forkloadbalancer()
+{
+	forkdns();
+}
+*/
+// #cgo CFLAGS: -std=gnu11 -Wvla
+import "C"
+
+type cmdForkLoadBalancer struct {
+	global *cmdGlobal
+}
+
+func (c *cmdForkLoadBalancer) Command() *cobra.Command {
+	// Main subcommand
+	cmd := &cobra.Command{}
+	cmd.Use = "forkloadbalancer <log path> <pid path> <listen address> <backends path>"
+	cmd.Short = "Internal TCP load-balancer"
+	cmd.Long = `Description:
+  Distributes the TCP connections made to the listen address across the backends listed in the
+  backends file, reloading them whenever the file changes.
+  It runs outside of the daemon so that the established connections survive LXD restarts.
+`
+	cmd.RunE = c.Run
+	cmd.Hidden = true
+
+	return cmd
+}
+
+func (c *cmdForkLoadBalancer) Run(cmd *cobra.Command, args []string) error {
+	// Sanity checks
+	if len(args) < 4 {
+		cmd.Help()
+
+		if len(args) == 0 {
+			return nil
+		}
+
+		return fmt.Errorf("Missing required arguments")
+	}
+
+	log, err := logging.GetLogger("lxd-forkloadbalancer", "", false, false, eventsHandler{})
+	if err != nil {
+		return err
+	}
+	logger.Log = log
+
+	// Setup watcher on the backends file.
+	backendsPath := filepath.Clean(args[3])
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("Unable to setup fsnotify: %s", err)
+	}
+
+	err = watcher.Watch(filepath.Dir(backendsPath))
+	if err != nil {
+		return fmt.Errorf("Unable to setup fsnotify watch on %s: %s", filepath.Dir(backendsPath), err)
+	}
+
+	balancer, err := loadbalancer.Listen(args[2])
+	if err != nil {
+		return fmt.Errorf("Failed to listen on %s: %v", args[2], err)
+	}
+
+	os.Stdin.Close()
+	os.Stderr.Close()
+	os.Stdout.Close()
+
+	forkLoadBalancerReload(balancer, backendsPath)
+
+	logger.Info("Started")
+
+	for {
+		select {
+		case ev := <-watcher.Event:
+			// Ignore files events that dont concern the backends file.
+			if ev.Name != backendsPath {
+				continue
+			}
+
+			forkLoadBalancerReload(balancer, backendsPath)
+		case err := <-watcher.Error:
+			logger.Errorf("Inotify error: %v", err)
+		}
+	}
+}
+
+// forkLoadBalancerReload applies the backends listed in the backends file.
+func forkLoadBalancerReload(balancer *loadbalancer.Balancer, path string) {
+	f, err := os.Open(path)
+	if err != nil {
+		logger.Errorf("Backends load error: %v", err)
+		return
+	}
+	defer f.Close()
+
+	backends, err := loadbalancer.ParseBackends(f)
+	if err != nil {
+		logger.Errorf("Backends load error: %v", err)
+		return
+	}
+
+	balancer.SetBackends(backends)
+	logger.Infof("Backends loaded: %v", backends)
+}
@@ -3,9 +3,14 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
 
 	"github.com/spf13/cobra"
 
+	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/netutils"
 )
 
@@ -16,6 +21,7 @@ import (
 #include <stdio.h>
 #include <stdlib.h>
 #include <string.h>
+#include <sys/mount.h>
 #include <sys/types.h>
 #include <unistd.h>
 
@@ -32,6 +38,45 @@ void forkdonetinfo(pid_t pid) {
 	// Jump back to Go for the rest
 }
 
+void forkdonetlink(pid_t pid) {
+	if (dosetns(pid, "net") < 0) {
+		fprintf(stderr, "Failed setns to container network namespace: %s\n", strerror(errno));
+		_exit(1);
+	}
+
+	// A sysfs mounted from within the network namespace shows its interfaces
+	if (unshare(CLONE_NEWNS) < 0) {
+		fprintf(stderr, "Failed to unshare mount namespace: %s\n", strerror(errno));
+		_exit(1);
+	}
+
+	if (mount(NULL, "/", NULL, MS_REC | MS_PRIVATE, NULL) < 0) {
+		fprintf(stderr, "Failed to make / private: %s\n", strerror(errno));
+		_exit(1);
+	}
+
+	if (mount("sysfs", "/sys", "sysfs", MS_NOSUID | MS_NODEV | MS_NOEXEC, NULL) < 0) {
+		fprintf(stderr, "Failed to mount sysfs: %s\n", strerror(errno));
+		_exit(1);
+	}
+
+	// Jump back to Go for the rest
+}
+
+void forkdosysctl(pid_t pid) {
+	if (dosetns(pid, "net") < 0) {
+		fprintf(stderr, "Failed setns to container network namespace: %s\n", strerror(errno));
+		_exit(1);
+	}
+
+	if (dosetns(pid, "ipc") < 0) {
+		fprintf(stderr, "Failed setns to container IPC namespace: %s\n", strerror(errno));
+		_exit(1);
+	}
+
+	// Jump back to Go for the rest
+}
+
 void forkdonetdetach(char *file) {
 	if (dosetns_file(file, "net") < 0) {
 		fprintf(stderr, "Failed setns to container network namespace: %s\n", strerror(errno));
@@ -66,12 +111,22 @@ void forknet() {
 	}
 
 	// Call the subcommands
-	if (strcmp(command, "info") == 0) {
+	if (strcmp(command, "info") == 0 || strcmp(command, "firewall-restore") == 0) {
 		pid = atoi(cur);
 		forkdonetinfo(pid);
 	}
 
-	if (strcmp(command, "detach") == 0)
+	if (strcmp(command, "link") == 0) {
+		pid = atoi(cur);
+		forkdonetlink(pid);
+	}
+
+	if (strcmp(command, "sysctl") == 0) {
+		pid = atoi(cur);
+		forkdosysctl(pid);
+	}
+
+	if (strcmp(command, "detach") == 0 || strcmp(command, "firewall-save") == 0)
 		forkdonetdetach(cur);
 }
 */
@@ -103,6 +158,13 @@ func (c *cmdForknet) Command() *cobra.Command {
 	cmdInfo.RunE = c.RunInfo
 	cmd.AddCommand(cmdInfo)
 
+	// link
+	cmdLink := &cobra.Command{}
+	cmdLink.Use = "link <PID>"
+	cmdLink.Args = cobra.ExactArgs(1)
+	cmdLink.RunE = c.RunLink
+	cmd.AddCommand(cmdLink)
+
 	// detach
 	cmdDetach := &cobra.Command{}
 	cmdDetach.Use = "detach <netns file> <LXD PID> <ifname> <hostname>"
@@ -110,6 +172,27 @@ func (c *cmdForknet) Command() *cobra.Command {
 	cmdDetach.RunE = c.RunDetach
 	cmd.AddCommand(cmdDetach)
 
+	// sysctl
+	cmdSysctl := &cobra.Command{}
+	cmdSysctl.Use = "sysctl <PID> <key>=<value>..."
+	cmdSysctl.Args = cobra.MinimumNArgs(2)
+	cmdSysctl.RunE = c.RunSysctl
+	cmd.AddCommand(cmdSysctl)
+
+	// firewall-save
+	cmdFirewallSave := &cobra.Command{}
+	cmdFirewallSave.Use = "firewall-save <netns file>"
+	cmdFirewallSave.Args = cobra.ExactArgs(1)
+	cmdFirewallSave.RunE = c.RunFirewallSave
+	cmd.AddCommand(cmdFirewallSave)
+
+	// firewall-restore
+	cmdFirewallRestore := &cobra.Command{}
+	cmdFirewallRestore.Use = "firewall-restore <PID> <rules file>"
+	cmdFirewallRestore.Args = cobra.ExactArgs(2)
+	cmdFirewallRestore.RunE = c.RunFirewallRestore
+	cmd.AddCommand(cmdFirewallRestore)
+
 	return cmd
 }
 
@@ -129,6 +212,29 @@ func (c *cmdForknet) RunInfo(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+func (c *cmdForknet) RunLink(cmd *cobra.Command, args []string) error {
+	ents, err := ioutil.ReadDir("/sys/class/net")
+	if err != nil {
+		return err
+	}
+
+	networks := map[string]api.ContainerStateNetwork{}
+	for _, ent := range ents {
+		dev := api.ContainerStateNetwork{}
+		networkStateLink("/sys/class/net", ent.Name(), &dev)
+		networks[ent.Name()] = dev
+	}
+
+	buf, err := json.Marshal(networks)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s\n", buf)
+
+	return nil
+}
+
 func (c *cmdForknet) RunDetach(cmd *cobra.Command, args []string) error {
 	lxdPID := args[1]
 	ifName := args[2]
@@ -161,3 +267,98 @@ func (c *cmdForknet) RunDetach(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+func (c *cmdForknet) RunSysctl(cmd *cobra.Command, args []string) error {
+	for _, arg := range args[1:] {
+		fields := strings.SplitN(arg, "=", 2)
+		if len(fields) != 2 {
+			return fmt.Errorf("Invalid sysctl: %s", arg)
+		}
+
+		// The sysctls were validated by LXD, only make sure they stay in /proc/sys
+		if strings.Contains(fields[0], "/") {
+			return fmt.Errorf("Invalid sysctl key: %s", fields[0])
+		}
+
+		path := filepath.Join("/proc/sys", strings.Replace(fields[0], ".", "/", -1))
+		err := ioutil.WriteFile(path, []byte(fields[1]), 0)
+		if err != nil {
+			return fmt.Errorf("Failed to set sysctl %s: %v", fields[0], err)
+		}
+	}
+
+	return nil
+}
+
+// The tools used to save and restore each firewall ruleset, nftables being
+// restored first so that the iptables tables it shares end up as saved by
+// the iptables tools.
+var forknetFirewallTools = []struct {
+	name    string
+	save    []string
+	restore []string
+}{
+	{"nftables", []string{"nft", "list", "ruleset"}, []string{"nft", "-f", "-"}},
+	{"iptables", []string{"iptables-save"}, []string{"iptables-restore"}},
+	{"ip6tables", []string{"ip6tables-save"}, []string{"ip6tables-restore"}},
+}
+
+func (c *cmdForknet) RunFirewallSave(cmd *cobra.Command, args []string) error {
+	rulesets := map[string]string{}
+	for _, tool := range forknetFirewallTools {
+		_, err := exec.LookPath(tool.save[0])
+		if err != nil {
+			continue
+		}
+
+		out, err := shared.RunCommand(tool.save[0], tool.save[1:]...)
+		if err != nil {
+			return fmt.Errorf("Failed to save the %s rules: %v", tool.name, err)
+		}
+
+		rulesets[tool.name] = out
+	}
+
+	buf, err := json.Marshal(rulesets)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s\n", buf)
+
+	return nil
+}
+
+func (c *cmdForknet) RunFirewallRestore(cmd *cobra.Command, args []string) error {
+	content, err := ioutil.ReadFile(args[1])
+	if err != nil {
+		return err
+	}
+
+	rulesets := map[string]string{}
+	err = json.Unmarshal(content, &rulesets)
+	if err != nil {
+		return err
+	}
+
+	for _, tool := range forknetFirewallTools {
+		rules := rulesets[tool.name]
+		if strings.TrimSpace(rules) == "" {
+			continue
+		}
+
+		_, err := exec.LookPath(tool.restore[0])
+		if err != nil {
+			return fmt.Errorf("Can't restore the %s rules, %s is missing", tool.name, tool.restore[0])
+		}
+
+		restore := exec.Command(tool.restore[0], tool.restore[1:]...)
+		restore.Stdin = strings.NewReader(rules)
+		out, err := restore.CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("Failed to restore the %s rules: %s", tool.name, strings.TrimSpace(string(out)))
+		}
+	}
+
+	return nil
+}
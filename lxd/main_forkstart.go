@@ -13,6 +13,8 @@ import (
 
 type cmdForkstart struct {
 	global *cmdGlobal
+
+	flagCoreScheduling bool
 }
 
 func (c *cmdForkstart) Command() *cobra.Command {
@@ -28,6 +30,7 @@ func (c *cmdForkstart) Command() *cobra.Command {
 `
 	cmd.RunE = c.Run
 	cmd.Hidden = true
+	cmd.Flags().BoolVar(&c.flagCoreScheduling, "core-scheduling", false, "Isolate the container in its own core scheduling group")
 
 	return cmd
 }
@@ -63,6 +66,14 @@ func (c *cmdForkstart) Run(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("Error opening startup config file: %q", err)
 	}
 
+	// The container's init inherits the core scheduling cookie
+	if c.flagCoreScheduling {
+		err = coreSchedulingCreate()
+		if err != nil {
+			return fmt.Errorf("Failed to setup core scheduling: %q", err)
+		}
+	}
+
 	/* due to https://github.com/golang/go/issues/13155 and the
 	 * CollectOutput call we make for the forkstart process, we need to
 	 * close our stdin/stdout/stderr here. Collecting some of the logs is
@@ -42,6 +42,7 @@ extern void forksyscall();
 extern void forkmount();
 extern void forknet();
 extern void forkdns();
+extern void forkloadbalancer();
 extern void forkproxy();
 extern void forkuevent();
 
@@ -292,6 +293,8 @@ __attribute__((constructor)) void init(void) {
 		forknet();
 	else if (strcmp(cmdline_cur, "forkdns") == 0)
 		forkdns();
+	else if (strcmp(cmdline_cur, "forkloadbalancer") == 0)
+		forkloadbalancer();
 	else if (strcmp(cmdline_cur, "forkproxy") == 0)
 		forkproxy();
 	else if (strcmp(cmdline_cur, "forkuevent") == 0)
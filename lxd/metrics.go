@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// The metrics of the containers of this node, in the Prometheus text
+// exposition format. Each cluster node exports its own containers.
+var metricsCmd = APIEndpoint{
+	Name: "metrics",
+
+	Get: APIEndpointAction{Handler: metricsGet},
+}
+
+// How long the sampled container states are reused for, so that several
+// scrapers don't each gather them.
+const metricsCacheDuration = 10 * time.Second
+
+// metricsContainer is the state of a container sampled for the metrics, nil
+// if it isn't running or couldn't be gathered.
+type metricsContainer struct {
+	project string
+	name    string
+	running bool
+	state   *api.ContainerState
+}
+
+var metricsCache struct {
+	sync.Mutex
+
+	containers []metricsContainer
+	sampled    time.Time
+}
+
+func metricsGet(d *Daemon, r *http.Request) Response {
+	containers, err := metricsSample(d.State())
+	if err != nil {
+		return SmartError(err)
+	}
+
+	// Only the containers of the given project, if any
+	project := r.FormValue("project")
+	if project != "" {
+		filtered := []metricsContainer{}
+		for _, c := range containers {
+			if c.project == project {
+				filtered = append(filtered, c)
+			}
+		}
+
+		containers = filtered
+	}
+
+	return &metricsResponse{data: metricsRender(containers)}
+}
+
+// metricsSample returns the states of the containers of this node, sampling
+// them again if the cached ones are too old.
+func metricsSample(s *state.State) ([]metricsContainer, error) {
+	metricsCache.Lock()
+	defer metricsCache.Unlock()
+
+	if metricsCache.containers != nil && time.Since(metricsCache.sampled) < metricsCacheDuration {
+		return metricsCache.containers, nil
+	}
+
+	all, err := containerLoadNodeAll(s)
+	if err != nil {
+		return nil, err
+	}
+
+	loaded := []container{}
+	for _, c := range all {
+		if c.IsSnapshot() {
+			continue
+		}
+
+		loaded = append(loaded, c)
+	}
+
+	sort.Slice(loaded, func(i, j int) bool {
+		if loaded[i].Project() != loaded[j].Project() {
+			return loaded[i].Project() < loaded[j].Project()
+		}
+
+		return loaded[i].Name() < loaded[j].Name()
+	})
+
+	// Gathering the state of a container forks, do it in parallel
+	containers := make([]metricsContainer, len(loaded))
+	wg := sync.WaitGroup{}
+	for i, c := range loaded {
+		containers[i] = metricsContainer{project: c.Project(), name: c.Name(), running: c.IsRunning()}
+		if !containers[i].running {
+			continue
+		}
+
+		wg.Add(1)
+		go func(c container, entry *metricsContainer) {
+			defer wg.Done()
+
+			cState, err := c.RenderState()
+			if err != nil {
+				logger.Warn("Failed to get the container state for the metrics", log.Ctx{"container": c.Name(), "project": c.Project(), "err": err})
+				return
+			}
+
+			entry.state = cState
+		}(c, &containers[i])
+	}
+	wg.Wait()
+
+	metricsCache.containers = containers
+	metricsCache.sampled = time.Now()
+
+	return containers, nil
+}
+
+// metricsFamily is a metric along with its samples.
+type metricsFamily struct {
+	name    string
+	kind    string
+	help    string
+	samples []string
+}
+
+// metricsRender renders the metrics of the given containers.
+func metricsRender(containers []metricsContainer) []byte {
+	families := []*metricsFamily{}
+	byName := map[string]*metricsFamily{}
+
+	add := func(name string, kind string, help string, value float64, labels map[string]string) {
+		family, ok := byName[name]
+		if !ok {
+			family = &metricsFamily{name: name, kind: kind, help: help}
+			byName[name] = family
+			families = append(families, family)
+		}
+
+		family.samples = append(family.samples, fmt.Sprintf("%s%s %v", name, metricsLabels(labels), value))
+	}
+
+	for _, c := range containers {
+		labels := func(extra ...string) map[string]string {
+			result := map[string]string{"project": c.project, "name": c.name}
+			for i := 0; i+1 < len(extra); i += 2 {
+				result[extra[i]] = extra[i+1]
+			}
+
+			return result
+		}
+
+		running := 0.0
+		if c.running {
+			running = 1
+		}
+		add("lxd_container_running", "gauge", "Whether the container is running.", running, labels())
+
+		// Only the state is missing when it couldn't be gathered
+		if c.state == nil {
+			continue
+		}
+
+		// Negative values are the ones which couldn't be gathered
+		cpu := c.state.CPU
+		if cpu.Usage >= 0 {
+			add("lxd_container_cpu_seconds_total", "counter", "CPU time used by the container in seconds.", float64(cpu.Usage)/1e9, labels())
+		}
+
+		if cpu.User >= 0 {
+			add("lxd_container_cpu_mode_seconds_total", "counter", "CPU time used by the container in seconds, by mode.", float64(cpu.User)/1e9, labels("mode", "user"))
+		}
+
+		if cpu.System >= 0 {
+			add("lxd_container_cpu_mode_seconds_total", "counter", "CPU time used by the container in seconds, by mode.", float64(cpu.System)/1e9, labels("mode", "system"))
+		}
+
+		for i, usage := range cpu.PerCPU {
+			add("lxd_container_cpu_per_cpu_seconds_total", "counter", "CPU time used by the container in seconds, by CPU.", float64(usage)/1e9, labels("cpu", fmt.Sprintf("%d", i)))
+		}
+
+		memory := c.state.Memory
+		if memory.Usage >= 0 {
+			add("lxd_container_memory_usage_bytes", "gauge", "Memory used by the container.", float64(memory.Usage), labels())
+		}
+
+		if memory.UsagePeak >= 0 {
+			add("lxd_container_memory_usage_peak_bytes", "gauge", "Highest memory use of the container.", float64(memory.UsagePeak), labels())
+		}
+
+		if memory.SwapUsage >= 0 {
+			add("lxd_container_memory_swap_usage_bytes", "gauge", "Swap used by the container.", float64(memory.SwapUsage), labels())
+		}
+
+		for _, name := range metricsSortedKeys(c.state.Disk) {
+			if c.state.Disk[name].Usage >= 0 {
+				add("lxd_container_disk_usage_bytes", "gauge", "Space used by the disks of the container.", float64(c.state.Disk[name].Usage), labels("device", name))
+			}
+		}
+
+		for _, name := range metricsSortedKeys(c.state.Network) {
+			counters := c.state.Network[name].Counters
+			add("lxd_container_network_receive_bytes_total", "counter", "Bytes received by the interfaces of the container.", float64(counters.BytesReceived), labels("interface", name))
+			add("lxd_container_network_transmit_bytes_total", "counter", "Bytes sent by the interfaces of the container.", float64(counters.BytesSent), labels("interface", name))
+			add("lxd_container_network_receive_packets_total", "counter", "Packets received by the interfaces of the container.", float64(counters.PacketsReceived), labels("interface", name))
+			add("lxd_container_network_transmit_packets_total", "counter", "Packets sent by the interfaces of the container.", float64(counters.PacketsSent), labels("interface", name))
+		}
+
+		if c.state.Processes >= 0 {
+			add("lxd_container_processes", "gauge", "Number of processes in the container.", float64(c.state.Processes), labels())
+		}
+	}
+
+	buf := bytes.Buffer{}
+	for _, family := range families {
+		fmt.Fprintf(&buf, "# HELP %s %s\n", family.name, family.help)
+		fmt.Fprintf(&buf, "# TYPE %s %s\n", family.name, family.kind)
+		for _, sample := range family.samples {
+			fmt.Fprintf(&buf, "%s\n", sample)
+		}
+	}
+
+	return buf.Bytes()
+}
+
+// metricsLabels renders a set of labels, sorted by name.
+func metricsLabels(labels map[string]string) string {
+	names := []string{}
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fields := []string{}
+	for _, name := range names {
+		value := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(labels[name])
+		fields = append(fields, fmt.Sprintf(`%s="%s"`, name, value))
+	}
+
+	return fmt.Sprintf("{%s}", strings.Join(fields, ","))
+}
+
+// metricsSortedKeys returns the device names of a state section, sorted.
+func metricsSortedKeys(devices interface{}) []string {
+	names := []string{}
+	switch devices := devices.(type) {
+	case map[string]api.ContainerStateDisk:
+		for name := range devices {
+			names = append(names, name)
+		}
+	case map[string]api.ContainerStateNetwork:
+		for name := range devices {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Metrics response, in the Prometheus text exposition format
+type metricsResponse struct {
+	data []byte
+}
+
+func (r *metricsResponse) Render(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", len(r.data)))
+
+	_, err := w.Write(r.data)
+	return err
+}
+
+func (r *metricsResponse) String() string {
+	return "metrics"
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lxc/lxd/shared/api"
+)
+
+func TestMetricsRender(t *testing.T) {
+	state := &api.ContainerState{
+		CPU: api.ContainerStateCPU{Usage: 1500000000, User: 500000000, System: -1, PerCPU: []int64{}},
+		Memory: api.ContainerStateMemory{
+			Usage:     2048,
+			UsagePeak: -1,
+			SwapUsage: -1,
+		},
+		Network: map[string]api.ContainerStateNetwork{
+			"eth0": {Counters: api.ContainerStateNetworkCounters{BytesReceived: 10, BytesSent: 20}},
+		},
+		Processes: 12,
+	}
+
+	containers := []metricsContainer{
+		{project: "default", name: "c1", running: true, state: state},
+		{project: "foo", name: "c2"},
+		{project: "foo", name: "c3", running: true},
+	}
+
+	assert.Equal(t, `# HELP lxd_container_running Whether the container is running.
+# TYPE lxd_container_running gauge
+lxd_container_running{name="c1",project="default"} 1
+lxd_container_running{name="c2",project="foo"} 0
+lxd_container_running{name="c3",project="foo"} 1
+# HELP lxd_container_cpu_seconds_total CPU time used by the container in seconds.
+# TYPE lxd_container_cpu_seconds_total counter
+lxd_container_cpu_seconds_total{name="c1",project="default"} 1.5
+# HELP lxd_container_cpu_mode_seconds_total CPU time used by the container in seconds, by mode.
+# TYPE lxd_container_cpu_mode_seconds_total counter
+lxd_container_cpu_mode_seconds_total{mode="user",name="c1",project="default"} 0.5
+# HELP lxd_container_memory_usage_bytes Memory used by the container.
+# TYPE lxd_container_memory_usage_bytes gauge
+lxd_container_memory_usage_bytes{name="c1",project="default"} 2048
+# HELP lxd_container_network_receive_bytes_total Bytes received by the interfaces of the container.
+# TYPE lxd_container_network_receive_bytes_total counter
+lxd_container_network_receive_bytes_total{interface="eth0",name="c1",project="default"} 10
+# HELP lxd_container_network_transmit_bytes_total Bytes sent by the interfaces of the container.
+# TYPE lxd_container_network_transmit_bytes_total counter
+lxd_container_network_transmit_bytes_total{interface="eth0",name="c1",project="default"} 20
+# HELP lxd_container_network_receive_packets_total Packets received by the interfaces of the container.
+# TYPE lxd_container_network_receive_packets_total counter
+lxd_container_network_receive_packets_total{interface="eth0",name="c1",project="default"} 0
+# HELP lxd_container_network_transmit_packets_total Packets sent by the interfaces of the container.
+# TYPE lxd_container_network_transmit_packets_total counter
+lxd_container_network_transmit_packets_total{interface="eth0",name="c1",project="default"} 0
+# HELP lxd_container_processes Number of processes in the container.
+# TYPE lxd_container_processes gauge
+lxd_container_processes{name="c1",project="default"} 12
+`, string(metricsRender(containers)))
+}
+
+func TestMetricsLabels(t *testing.T) {
+	assert.Equal(t, `{a="1",b="x\"y\\z"}`, metricsLabels(map[string]string{"b": `x"y\z`, "a": "1"}))
+}
@@ -40,6 +40,12 @@ type migrationFields struct {
 	containerOnly bool
 	container     container
 
+	// freezeSync is set when a live migration can't use CRIU, the
+	// container is then frozen for the final filesystem sync and
+	// restarted on the target, if allowFreezeSync was requested.
+	freezeSync      bool
+	allowFreezeSync bool
+
 	// storage specific fields
 	storage    storage
 	volumeOnly bool
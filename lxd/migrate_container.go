@@ -22,11 +22,14 @@ import (
 	"github.com/lxc/lxd/shared/api"
 	"github.com/lxc/lxd/shared/idmap"
 	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
 )
 
-func NewMigrationSource(c container, stateful bool, containerOnly bool) (*migrationSourceWs, error) {
+func NewMigrationSource(c container, stateful bool, containerOnly bool, freezeSync bool) (*migrationSourceWs, error) {
 	ret := migrationSourceWs{migrationFields{container: c}, make(chan bool, 1)}
 	ret.containerOnly = containerOnly
+	ret.allowFreezeSync = freezeSync
 
 	var err error
 	ret.controlSecret, err = shared.RandomCryptoString()
@@ -42,7 +45,12 @@ func NewMigrationSource(c container, stateful bool, containerOnly bool) (*migrat
 	if stateful && c.IsRunning() {
 		_, err := exec.LookPath("criu")
 		if err != nil {
-			return nil, fmt.Errorf("Unable to perform container live migration. CRIU isn't installed on the source server")
+			if !freezeSync {
+				return nil, fmt.Errorf("Unable to perform container live migration. CRIU isn't installed on the source server")
+			}
+
+			logger.Warn("CRIU isn't installed on the source server, falling back to a freeze and sync migration", log.Ctx{"container": c.Name(), "project": c.Project()})
+			ret.freezeSync = true
 		}
 
 		ret.live = true
@@ -214,6 +222,47 @@ func readCriuStatsDump(path string) (uint64, uint64, error) {
 	return written, skipped, nil
 }
 
+// The function preDumpGoalReached() returns whether the pre-dump in dumpPath
+// skipped enough memory pages already written by the previous one for the
+// migration.incremental.memory.goal of the container to be reached.
+func preDumpGoalReached(c container, dumpPath string) (bool, error) {
+	// Read the CRIU's 'stats-dump' file
+	written, skipped_parent, err := readCriuStatsDump(dumpPath)
+	if err != nil {
+		return false, err
+	}
+
+	logger.Debugf("CRIU pages written %d", written)
+	logger.Debugf("CRIU pages skipped %d", skipped_parent)
+
+	total_pages := written + skipped_parent
+	if total_pages == 0 {
+		return true, nil
+	}
+
+	percentage_skipped := int(100 - ((100 * written) / total_pages))
+
+	logger.Debugf("CRIU pages skipped percentage %d%%", percentage_skipped)
+
+	// threshold is the percentage of memory pages that needs
+	// to be pre-copied for the pre-copy to stop.
+	var threshold int
+	tmp := c.ExpandedConfig()["migration.incremental.memory.goal"]
+	if tmp != "" {
+		threshold, _ = strconv.Atoi(tmp)
+	} else {
+		// defaults to 70%
+		threshold = 70
+	}
+
+	if percentage_skipped > threshold {
+		logger.Debugf("Memory pages skipped (%d%%) due to pre-copy is larger than threshold (%d%%)", percentage_skipped, threshold)
+		return true, nil
+	}
+
+	return false, nil
+}
+
 type preDumpLoopArgs struct {
 	checkpointDir string
 	bwlimit       string
@@ -256,36 +305,14 @@ func (s *migrationSourceWs) preDumpLoop(args *preDumpLoopArgs) (bool, error) {
 		return final, err
 	}
 
-	// Read the CRIU's 'stats-dump' file
 	dumpPath := shared.AddSlash(args.checkpointDir)
 	dumpPath += shared.AddSlash(args.dumpDir)
-	written, skipped_parent, err := readCriuStatsDump(dumpPath)
+	goalReached, err := preDumpGoalReached(s.container, dumpPath)
 	if err != nil {
 		return final, err
 	}
 
-	logger.Debugf("CRIU pages written %d", written)
-	logger.Debugf("CRIU pages skipped %d", skipped_parent)
-
-	total_pages := written + skipped_parent
-
-	percentage_skipped := int(100 - ((100 * written) / total_pages))
-
-	logger.Debugf("CRIU pages skipped percentage %d%%", percentage_skipped)
-
-	// threshold is the percentage of memory pages that needs
-	// to be pre-copied for the pre-copy migration to stop.
-	var threshold int
-	tmp := s.container.ExpandedConfig()["migration.incremental.memory.goal"]
-	if tmp != "" {
-		threshold, _ = strconv.Atoi(tmp)
-	} else {
-		// defaults to 70%
-		threshold = 70
-	}
-
-	if percentage_skipped > threshold {
-		logger.Debugf("Memory pages skipped (%d%%) due to pre-copy is larger than threshold (%d%%)", percentage_skipped, threshold)
+	if goalReached {
 		logger.Debugf("This was the last pre-dump; next dump is the final dump")
 		final = true
 	}
@@ -323,6 +350,8 @@ func (s *migrationSourceWs) Do(migrateOp *operation) error {
 		if s.container.IsRunning() {
 			criuType = migration.CRIUType_NONE.Enum()
 		}
+	} else if s.freezeSync {
+		criuType = migration.CRIUType_FREEZE_SYNC.Enum()
 	}
 
 	// Storage needs to start unconditionally now, since we need to
@@ -371,7 +400,7 @@ func (s *migrationSourceWs) Do(migrateOp *operation) error {
 
 	use_pre_dumps := false
 	max_iterations := 0
-	if s.live {
+	if s.live && !s.freezeSync {
 		use_pre_dumps, max_iterations = s.checkForPreDumpSupport()
 	}
 
@@ -386,6 +415,7 @@ func (s *migrationSourceWs) Do(migrateOp *operation) error {
 		SnapshotNames: snapshotNames,
 		Snapshots:     snapshots,
 		Predump:       proto.Bool(use_pre_dumps),
+		FreezeSync:    proto.Bool(s.live && s.allowFreezeSync),
 		RsyncFeatures: &migration.RsyncFeatures{
 			Xattrs:        &hasFeature,
 			Delete:        &hasFeature,
@@ -472,12 +502,29 @@ func (s *migrationSourceWs) Do(migrateOp *operation) error {
 	// of ":=".  Capturing err in a closure for use in defer would be fragile, which defeats
 	// the purpose of using defer.  An abort function reduces the odds of mishandling errors
 	// without introducing the fragility of closing on err.
+	frozen := false
+	stopped := false
 	abort := func(err error) error {
+		if frozen {
+			s.container.Unfreeze()
+		} else if stopped {
+			s.container.Start(false)
+		}
+
 		driver.Cleanup()
 		go s.sendControl(err)
 		return err
 	}
 
+	// The target falls back to a freeze and sync migration when it
+	// doesn't have CRIU.
+	if s.live {
+		s.freezeSync, err = migrationSourceFreezeSync(header.Criu, s.freezeSync, s.allowFreezeSync)
+		if err != nil {
+			return abort(err)
+		}
+	}
+
 	err = driver.SendWhileRunning(s.fsConn, migrateOp, bwlimit, s.containerOnly)
 	if err != nil {
 		return abort(err)
@@ -486,13 +533,16 @@ func (s *migrationSourceWs) Do(migrateOp *operation) error {
 	restoreSuccess := make(chan bool, 1)
 	dumpSuccess := make(chan error, 1)
 
-	if s.live {
-		if header.Criu == nil {
-			return abort(fmt.Errorf("Got no CRIU socket type for live migration"))
-		} else if *header.Criu != migration.CRIUType_CRIU_RSYNC {
-			return abort(fmt.Errorf("Formats other than criu rsync not understood"))
+	if s.live && s.freezeSync {
+		// Stop the processes in place for the final sync, they're
+		// restarted on the target once it's done.
+		err = s.container.Freeze()
+		if err != nil {
+			return abort(err)
 		}
 
+		frozen = true
+	} else if s.live {
 		checkpointDir, err := ioutil.TempDir("", "lxd_checkpoint_")
 		if err != nil {
 			return abort(err)
@@ -672,16 +722,42 @@ func (s *migrationSourceWs) Do(migrateOp *operation) error {
 		}
 	}
 
+	// Stop the container before the target starts it, so that both don't
+	// run with the same addresses, and let the target know.
+	if frozen {
+		err = s.container.Stop(false)
+		if err != nil {
+			return abort(err)
+		}
+
+		frozen = false
+		stopped = true
+
+		data, err := proto.Marshal(&migration.MigrationControl{Success: proto.Bool(true)})
+		if err != nil {
+			return abort(err)
+		}
+
+		err = s.criuConn.WriteMessage(websocket.BinaryMessage, data)
+		if err != nil {
+			return abort(err)
+		}
+	}
+
 	driver.Cleanup()
 
 	msg := migration.MigrationControl{}
 	err = s.recv(&msg)
 	if err != nil {
+		if stopped {
+			s.container.Start(false)
+		}
+
 		s.disconnect()
 		return err
 	}
 
-	if s.live {
+	if s.live && !s.freezeSync {
 		restoreSuccess <- *msg.Success
 		err := <-dumpSuccess
 		if err != nil {
@@ -690,12 +766,55 @@ func (s *migrationSourceWs) Do(migrateOp *operation) error {
 	}
 
 	if !*msg.Success {
+		if stopped {
+			s.container.Start(false)
+		}
+
 		return fmt.Errorf(*msg.Message)
 	}
 
 	return nil
 }
 
+// migrationSourceFreezeSync returns whether a live migration falls back to a
+// freeze and sync migration, given the criu type picked by the target.
+func migrationSourceFreezeSync(criuType *migration.CRIUType, freezeSync bool, allowFreezeSync bool) (bool, error) {
+	if criuType == nil {
+		return false, fmt.Errorf("Got no CRIU socket type for live migration")
+	}
+
+	switch *criuType {
+	case migration.CRIUType_FREEZE_SYNC:
+		if !allowFreezeSync {
+			return false, fmt.Errorf("Unable to perform container live migration. CRIU isn't installed on the destination server")
+		}
+
+		return true, nil
+	case migration.CRIUType_CRIU_RSYNC:
+		if freezeSync {
+			return false, fmt.Errorf("Unable to perform container live migration. CRIU isn't installed on the source server and the target doesn't support freeze and sync migration")
+		}
+
+		return false, nil
+	}
+
+	return false, fmt.Errorf("Formats other than criu rsync not understood")
+}
+
+// migrationSinkFreezeSync returns whether the target of a live migration
+// falls back to a freeze and sync migration, which the source must allow.
+func migrationSinkFreezeSync(header *migration.MigrationHeader, hasCriu bool) (bool, error) {
+	if header.GetCriu() == migration.CRIUType_FREEZE_SYNC || !hasCriu {
+		if !header.GetFreezeSync() {
+			return false, fmt.Errorf("Unable to perform container live migration. CRIU isn't installed on the destination server")
+		}
+
+		return true, nil
+	}
+
+	return false, nil
+}
+
 func NewMigrationSink(args *MigrationSinkArgs) (*migrationSink, error) {
 	sink := migrationSink{
 		src:     migrationFields{container: args.Container, containerOnly: args.ContainerOnly},
@@ -745,13 +864,8 @@ func NewMigrationSink(args *MigrationSinkArgs) (*migrationSink, error) {
 		sink.src.live = ok
 	}
 
-	_, err = exec.LookPath("criu")
-	if sink.push && sink.dest.live && err != nil {
-		return nil, fmt.Errorf("Unable to perform container live migration. CRIU isn't installed on the destination server")
-	} else if sink.src.live && err != nil {
-		return nil, fmt.Errorf("Unable to perform container live migration. CRIU isn't installed on the destination server")
-	}
-
+	// Without CRIU, live migrations fall back to freezing the container
+	// and restarting it here, if the source supports it.
 	return &sink, nil
 }
 
@@ -829,6 +943,23 @@ func (c *migrationSink) Do(migrateOp *operation) error {
 		}
 	}
 
+	// Fall back to a freeze and sync migration if either side doesn't
+	// have CRIU and the source allows it.
+	if live {
+		_, err := exec.LookPath("criu")
+		fallback, err := migrationSinkFreezeSync(&header, err == nil)
+		if err != nil {
+			controller(err)
+			return err
+		}
+
+		if fallback {
+			criuType = migration.CRIUType_FREEZE_SYNC.Enum()
+		}
+	}
+
+	freezeSync := criuType != nil && *criuType == migration.CRIUType_FREEZE_SYNC
+
 	mySink := c.src.container.Storage().MigrationSink
 	if c.refresh {
 		mySink = rsyncMigrationSink
@@ -914,7 +1045,7 @@ func (c *migrationSink) Do(migrateOp *operation) error {
 		resp.Fs = &myType
 	}
 
-	if header.GetPredump() == true {
+	if header.GetPredump() == true && !freezeSync {
 		// If the other side wants pre-dump and if
 		// this side supports it, let's use it.
 		resp.Predump = proto.Bool(true)
@@ -993,7 +1124,9 @@ func (c *migrationSink) Do(migrateOp *operation) error {
 				Snapshots:     snapshots,
 			}
 
+			release := storagePoolOperationStart(c.src.container.Storage(), c.src.container)
 			err = mySink(fsConn, migrateOp, args)
+			release()
 			if err != nil {
 				fsTransfer <- err
 				return
@@ -1008,7 +1141,7 @@ func (c *migrationSink) Do(migrateOp *operation) error {
 			fsTransfer <- nil
 		}()
 
-		if live {
+		if live && !freezeSync {
 			var err error
 			imagesDir, err = ioutil.TempDir("", "lxd_restore_")
 			if err != nil {
@@ -1074,7 +1207,41 @@ func (c *migrationSink) Do(migrateOp *operation) error {
 			return
 		}
 
-		if live {
+		if live && freezeSync {
+			// Wait for the source to stop the container, so
+			// that both don't run with the same addresses.
+			var criuConn *websocket.Conn
+			if c.push {
+				criuConn = c.dest.criuConn
+			} else {
+				criuConn = c.src.criuConn
+			}
+
+			_, data, err := criuConn.ReadMessage()
+			if err != nil {
+				restore <- err
+				return
+			}
+
+			stopped := migration.MigrationControl{}
+			err = proto.Unmarshal(data, &stopped)
+			if err != nil {
+				restore <- err
+				return
+			}
+
+			if !stopped.GetSuccess() {
+				restore <- fmt.Errorf("The source failed to stop the container: %s", stopped.GetMessage())
+				return
+			}
+
+			// The workload restarts from the synced filesystem
+			err = c.src.container.Start(false)
+			if err != nil {
+				restore <- err
+				return
+			}
+		} else if live {
 			criuMigrationArgs := CriuMigrationArgs{
 				cmd:          lxc.MIGRATE_RESTORE,
 				stateDir:     imagesDir,
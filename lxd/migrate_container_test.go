@@ -0,0 +1,78 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lxc/lxd/lxd/migration"
+)
+
+func TestMigrationSourceFreezeSync(t *testing.T) {
+	cases := []struct {
+		criuType        *migration.CRIUType
+		freezeSync      bool
+		allowFreezeSync bool
+		result          bool
+		fails           bool
+	}{
+		{nil, false, true, false, true},
+		{migration.CRIUType_CRIU_RSYNC.Enum(), false, false, false, false},
+		{migration.CRIUType_CRIU_RSYNC.Enum(), false, true, false, false},
+		{migration.CRIUType_CRIU_RSYNC.Enum(), true, true, false, true},
+		{migration.CRIUType_FREEZE_SYNC.Enum(), false, true, true, false},
+		{migration.CRIUType_FREEZE_SYNC.Enum(), true, true, true, false},
+		{migration.CRIUType_FREEZE_SYNC.Enum(), false, false, false, true},
+		{migration.CRIUType_PHAUL.Enum(), false, true, false, true},
+	}
+
+	for i, c := range cases {
+		result, err := migrationSourceFreezeSync(c.criuType, c.freezeSync, c.allowFreezeSync)
+		if c.fails {
+			assert.Error(t, err, "case %d", i)
+			continue
+		}
+
+		assert.NoError(t, err, "case %d", i)
+		assert.Equal(t, c.result, result, "case %d", i)
+	}
+}
+
+func TestMigrationSinkFreezeSync(t *testing.T) {
+	cases := []struct {
+		criuType   *migration.CRIUType
+		freezeSync bool
+		hasCriu    bool
+		result     bool
+		fails      bool
+	}{
+		// Both sides have CRIU
+		{migration.CRIUType_CRIU_RSYNC.Enum(), false, true, false, false},
+		{migration.CRIUType_CRIU_RSYNC.Enum(), true, true, false, false},
+
+		// The target doesn't, which requires the user to allow falling back
+		{migration.CRIUType_CRIU_RSYNC.Enum(), false, false, false, true},
+		{migration.CRIUType_CRIU_RSYNC.Enum(), true, false, true, false},
+
+		// The source doesn't
+		{migration.CRIUType_FREEZE_SYNC.Enum(), true, true, true, false},
+		{migration.CRIUType_FREEZE_SYNC.Enum(), false, true, false, true},
+	}
+
+	for i, c := range cases {
+		header := &migration.MigrationHeader{
+			Criu:       c.criuType,
+			FreezeSync: proto.Bool(c.freezeSync),
+		}
+
+		result, err := migrationSinkFreezeSync(header, c.hasCriu)
+		if c.fails {
+			assert.Error(t, err, "case %d", i)
+			continue
+		}
+
+		assert.NoError(t, err, "case %d", i)
+		assert.Equal(t, c.result, result, "case %d", i)
+	}
+}
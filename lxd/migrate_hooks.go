@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// migrationHookRun runs the host script set in migration.hooks.<hook>, if
+// any, giving it the container as JSON on stdin and the details of the CRIU
+// operation in its environment.
+func migrationHookRun(c container, hook string, function string, stateDir string) error {
+	path := c.ExpandedConfig()[fmt.Sprintf("migration.hooks.%s", hook)]
+	if path == "" {
+		return nil
+	}
+
+	logger.Debug("Running migration hook", log.Ctx{"container": c.Name(), "project": c.Project(), "hook": hook, "path": path})
+
+	ctx, cancel := context.WithTimeout(context.Background(), containerHookTimeout)
+	defer cancel()
+
+	cmd, err := hostScriptCommand(ctx, c, path,
+		fmt.Sprintf("LXD_MIGRATION_HOOK=%s", hook),
+		fmt.Sprintf("LXD_MIGRATION_FUNCTION=%s", function),
+		fmt.Sprintf("LXD_MIGRATION_STATE_DIR=%s", stateDir))
+	if err != nil {
+		return err
+	}
+
+	stop := hostScriptKeepAlive(c)
+	output, err := cmd.CombinedOutput()
+	stop()
+
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("Timed out after %s", containerHookTimeout)
+	}
+
+	if err != nil {
+		return fmt.Errorf("Migration hook %s failed: %v: %s", hook, err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+// hostScriptCommand prepares the command running a host script set in the
+// configuration of a container, such as migration.hooks.* or hooks.*, giving
+// it the container as JSON on stdin along with its name and project and the
+// given variables in its environment.
+func hostScriptCommand(ctx context.Context, c container, path string, env ...string) (*exec.Cmd, error) {
+	render, _, err := c.Render()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(render)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Env = append(os.Environ(),
+		fmt.Sprintf("LXD_CONTAINER_NAME=%s", c.Name()),
+		fmt.Sprintf("LXD_CONTAINER_PROJECT=%s", c.Project()))
+	cmd.Env = append(cmd.Env, env...)
+	cmd.Stdin = bytes.NewReader(data)
+
+	return cmd, nil
+}
+
+// hostScriptKeepAlive keeps the operation of the container alive while a host
+// script runs, until the returned function is called.
+func hostScriptKeepAlive(c container) func() {
+	done := make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(containerHookKeepAliveInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				containerOperationKeepAlive(c.Id())
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
@@ -5,9 +5,11 @@
 Package migration is a generated protocol buffer package.
 
 It is generated from these files:
+
 	lxd/migration/migrate.proto
 
 It has these top-level messages:
+
 	IDMapType
 	Config
 	Device
@@ -81,20 +83,23 @@ func (MigrationFSType) EnumDescriptor() ([]byte, []int) { return fileDescriptor0
 type CRIUType int32
 
 const (
-	CRIUType_CRIU_RSYNC CRIUType = 0
-	CRIUType_PHAUL      CRIUType = 1
-	CRIUType_NONE       CRIUType = 2
+	CRIUType_CRIU_RSYNC  CRIUType = 0
+	CRIUType_PHAUL       CRIUType = 1
+	CRIUType_NONE        CRIUType = 2
+	CRIUType_FREEZE_SYNC CRIUType = 3
 )
 
 var CRIUType_name = map[int32]string{
 	0: "CRIU_RSYNC",
 	1: "PHAUL",
 	2: "NONE",
+	3: "FREEZE_SYNC",
 }
 var CRIUType_value = map[string]int32{
-	"CRIU_RSYNC": 0,
-	"PHAUL":      1,
-	"NONE":       2,
+	"CRIU_RSYNC":  0,
+	"PHAUL":       1,
+	"NONE":        2,
+	"FREEZE_SYNC": 3,
 }
 
 func (x CRIUType) Enum() *CRIUType {
@@ -363,6 +368,7 @@ type MigrationHeader struct {
 	RsyncFeatures    *RsyncFeatures   `protobuf:"bytes,8,opt,name=rsyncFeatures" json:"rsyncFeatures,omitempty"`
 	Refresh          *bool            `protobuf:"varint,9,opt,name=refresh" json:"refresh,omitempty"`
 	ZfsFeatures      *ZfsFeatures     `protobuf:"bytes,10,opt,name=zfsFeatures" json:"zfsFeatures,omitempty"`
+	FreezeSync       *bool            `protobuf:"varint,11,opt,name=freezeSync" json:"freezeSync,omitempty"`
 	XXX_unrecognized []byte           `json:"-"`
 }
 
@@ -434,6 +440,13 @@ func (m *MigrationHeader) GetZfsFeatures() *ZfsFeatures {
 	return nil
 }
 
+func (m *MigrationHeader) GetFreezeSync() bool {
+	if m != nil && m.FreezeSync != nil {
+		return *m.FreezeSync
+	}
+	return false
+}
+
 type MigrationControl struct {
 	Success *bool `protobuf:"varint,1,req,name=success" json:"success,omitempty"`
 	// optional failure message if sending a failure
@@ -669,71 +682,72 @@ func init() {
 func init() { proto.RegisterFile("lxd/migration/migrate.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 1047 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x84, 0x55, 0xdb, 0x6e, 0xdb, 0x46,
-	0x10, 0xad, 0x6e, 0xb6, 0x38, 0x92, 0x1c, 0x65, 0x63, 0x04, 0x44, 0xd2, 0x8b, 0xca, 0xa4, 0xa8,
-	0xe2, 0x07, 0x3b, 0x55, 0x50, 0x20, 0x7d, 0x29, 0x50, 0xcb, 0x75, 0x13, 0x20, 0x71, 0x8d, 0x95,
-	0x8d, 0xa2, 0x7d, 0x21, 0x36, 0xe4, 0x50, 0x5e, 0x98, 0x37, 0xec, 0x52, 0xb6, 0xe5, 0x97, 0xa2,
-	0x9f, 0xd1, 0x0f, 0xe8, 0xf7, 0xf4, 0xa9, 0xff, 0x53, 0xec, 0x2c, 0x49, 0x53, 0x4e, 0x81, 0xbe,
-	0xed, 0x9c, 0x39, 0x3c, 0xb3, 0x3b, 0x37, 0xc2, 0xd3, 0xf8, 0x26, 0x3c, 0x48, 0xe4, 0x52, 0x89,
-	0x42, 0x66, 0x69, 0x79, 0xc2, 0xfd, 0x5c, 0x65, 0x45, 0xc6, 0x9c, 0xda, 0xe1, 0xfd, 0x0e, 0xce,
-	0xdb, 0xa3, 0xf7, 0x22, 0x3f, 0x5b, 0xe7, 0xc8, 0x76, 0xa1, 0x27, 0xf5, 0x4a, 0x86, 0x6e, 0x6b,
-	0xd2, 0x9e, 0xf6, 0xb9, 0x35, 0x2c, 0xba, 0x94, 0xa1, 0xdb, 0xae, 0xd0, 0xa5, 0x0c, 0xd9, 0x63,
-	0xd8, 0xba, 0xc8, 0x74, 0x21, 0x43, 0xb7, 0x33, 0x69, 0x4f, 0x7b, 0xbc, 0xb4, 0x18, 0x83, 0x6e,
-	0xaa, 0x65, 0xe8, 0x76, 0x09, 0xa5, 0x33, 0x7b, 0x02, 0xfd, 0x44, 0xe4, 0x4a, 0xa4, 0x4b, 0x74,
-	0x7b, 0x84, 0xd7, 0xb6, 0xf7, 0x12, 0xb6, 0xe6, 0x59, 0x1a, 0xc9, 0x25, 0x1b, 0x43, 0xe7, 0x12,
-	0xd7, 0x14, 0xdb, 0xe1, 0xe6, 0x68, 0x22, 0x5f, 0x89, 0x78, 0x85, 0x14, 0xd9, 0xe1, 0xd6, 0xf0,
-	0x7e, 0x82, 0xad, 0x23, 0xbc, 0x92, 0x01, 0x52, 0x2c, 0x91, 0x60, 0xf9, 0x09, 0x9d, 0xd9, 0x0b,
-	0xd8, 0x0a, 0x48, 0xcf, 0x6d, 0x4f, 0x3a, 0xd3, 0xc1, 0xec, 0xe1, 0x7e, 0xfd, 0xd8, 0x7d, 0x1b,
-	0x88, 0x97, 0x04, 0xef, 0xef, 0x36, 0xf4, 0x17, 0xa9, 0xc8, 0xf5, 0x45, 0x56, 0xfc, 0xa7, 0xd6,
-	0x2b, 0x18, 0xc4, 0x59, 0x20, 0xe2, 0xf9, 0xff, 0x08, 0x36, 0x59, 0xe6, 0xb1, 0xb9, 0xca, 0x22,
-	0x19, 0xa3, 0x76, 0x3b, 0x93, 0xce, 0xd4, 0xe1, 0xb5, 0xcd, 0x3e, 0x05, 0x07, 0xf3, 0x0b, 0x4c,
-	0x50, 0x89, 0x98, 0x32, 0xd4, 0xe7, 0x77, 0x00, 0xfb, 0x16, 0x86, 0x24, 0x64, 0x5f, 0xa7, 0xdd,
-	0xde, 0x47, 0xf1, 0xac, 0x87, 0x6f, 0xd0, 0x98, 0x07, 0x43, 0xa1, 0x82, 0x0b, 0x59, 0x60, 0x50,
-	0xac, 0x14, 0xba, 0x5b, 0x94, 0xe1, 0x0d, 0xcc, 0x5c, 0x4a, 0x17, 0xa2, 0xc0, 0x68, 0x15, 0xbb,
-	0xdb, 0x14, 0xb7, 0xb6, 0xd9, 0x33, 0x18, 0x05, 0x0a, 0x29, 0x80, 0x1f, 0x8a, 0x02, 0xdd, 0xfe,
-	0xa4, 0x35, 0xed, 0xf0, 0x61, 0x05, 0x1e, 0x89, 0x02, 0xd9, 0x73, 0xd8, 0x89, 0x85, 0x2e, 0xfc,
-	0x95, 0xc6, 0xd0, 0xb2, 0x1c, 0xcb, 0x32, 0xe8, 0xb9, 0xc6, 0xd0, 0xb0, 0xbc, 0x3f, 0x5a, 0x30,
-	0x52, 0x7a, 0x9d, 0x06, 0xc7, 0x28, 0x4c, 0x5c, 0x6d, 0xda, 0xe4, 0x46, 0x14, 0x85, 0xd2, 0x6e,
-	0x6b, 0xd2, 0x9a, 0xf6, 0x79, 0x69, 0x19, 0x3c, 0xc4, 0x18, 0x0b, 0x53, 0x5b, 0xc2, 0xad, 0x65,
-	0x2e, 0x1a, 0x64, 0x49, 0xae, 0x50, 0x9b, 0xec, 0x19, 0x4f, 0x6d, 0xb3, 0xe7, 0x30, 0xfa, 0x20,
-	0x43, 0xa9, 0x30, 0x30, 0xd7, 0xa2, 0x0c, 0x1a, 0xc2, 0x26, 0xe8, 0xbd, 0x80, 0xc1, 0x6d, 0xa4,
-	0xeb, 0x0b, 0x34, 0x05, 0x5b, 0x9b, 0x82, 0xde, 0x9f, 0x1d, 0x78, 0xf0, 0xbe, 0x4a, 0xee, 0x1b,
-	0x14, 0x21, 0x2a, 0xb6, 0x07, 0xed, 0x48, 0x53, 0x17, 0xec, 0xcc, 0x9e, 0x34, 0x52, 0x5f, 0xf3,
-	0x8e, 0x17, 0x66, 0x56, 0x78, 0x3b, 0xd2, 0xec, 0x6b, 0xe8, 0x06, 0x4a, 0xae, 0xe8, 0x09, 0x3b,
-	0xb3, 0x47, 0xcd, 0xc6, 0xe0, 0x6f, 0xcf, 0x89, 0x46, 0x04, 0xb6, 0x07, 0x3d, 0x19, 0x26, 0x22,
-	0xa7, 0x86, 0x18, 0xcc, 0x76, 0x1b, 0xcc, 0x7a, 0xfa, 0xb8, 0xa5, 0x98, 0x57, 0xea, 0xb2, 0x29,
-	0x4f, 0x44, 0x82, 0xda, 0xed, 0x52, 0x13, 0x6d, 0x82, 0xec, 0x1b, 0x70, 0x2a, 0xa0, 0x6a, 0x94,
-	0x66, 0xfc, 0xaa, 0xad, 0xf9, 0x1d, 0x8b, 0xb9, 0xb0, 0x9d, 0x2b, 0x0c, 0x57, 0x49, 0xee, 0x6e,
-	0x53, 0x22, 0x2a, 0x93, 0x7d, 0x7f, 0xaf, 0x6a, 0xd4, 0x01, 0x83, 0x99, 0xdb, 0x10, 0xdc, 0xf0,
-	0xf3, 0x7b, 0x45, 0x76, 0x61, 0x5b, 0x61, 0xa4, 0x50, 0x5f, 0x50, 0x57, 0xf4, 0x79, 0x65, 0xb2,
-	0xd7, 0x1b, 0xc5, 0x70, 0x81, 0x74, 0x1f, 0x37, 0x74, 0x1b, 0x5e, 0xde, 0xa4, 0x7a, 0xc7, 0x30,
-	0xae, 0x53, 0x3e, 0xcf, 0xd2, 0x42, 0x65, 0xb1, 0x89, 0xa3, 0x57, 0x41, 0x60, 0x4b, 0x69, 0x9a,
-	0xb8, 0x32, 0x8d, 0x27, 0x41, 0xad, 0xc5, 0xd2, 0xf6, 0x93, 0xc3, 0x2b, 0xd3, 0x7b, 0x05, 0xa3,
-	0x5a, 0x67, 0xb1, 0x4e, 0x03, 0x33, 0x2e, 0x91, 0x4c, 0x45, 0x7c, 0xaa, 0xf0, 0xc8, 0xe4, 0xc2,
-	0x2a, 0x6d, 0x60, 0xde, 0x5f, 0x1d, 0x18, 0x9b, 0xcc, 0xf8, 0x66, 0x48, 0xb4, 0x8f, 0x69, 0xa1,
-	0xd6, 0x66, 0x4e, 0x22, 0x85, 0x78, 0x2b, 0xd3, 0xa5, 0x5f, 0xc8, 0x72, 0x55, 0x8c, 0xf8, 0xb0,
-	0x02, 0xcf, 0x64, 0x82, 0xec, 0x0b, 0x18, 0x44, 0x2a, 0xbb, 0xc5, 0xd4, 0x52, 0xda, 0x44, 0x01,
-	0x0b, 0x11, 0xe1, 0x4b, 0x18, 0x26, 0x98, 0x90, 0x38, 0x31, 0x3a, 0xc4, 0x18, 0x94, 0x18, 0x51,
-	0x9e, 0xc1, 0x28, 0xc1, 0xe4, 0x5a, 0xc9, 0x02, 0x2d, 0xa7, 0x6b, 0x03, 0x55, 0x60, 0x45, 0xca,
-	0xc5, 0x12, 0xb5, 0xaf, 0x03, 0x91, 0xa6, 0x18, 0xd2, 0x62, 0xed, 0xf2, 0x21, 0x81, 0x0b, 0x8b,
-	0xb1, 0x97, 0xb0, 0x5b, 0x92, 0x2e, 0x65, 0x9e, 0x63, 0xe8, 0xe7, 0x42, 0x61, 0x5a, 0xd0, 0x8a,
-	0xe8, 0x72, 0x66, 0xb9, 0xd6, 0x75, 0x4a, 0x9e, 0x3b, 0x59, 0x13, 0xa9, 0xc0, 0x94, 0xb6, 0x45,
-	0x25, 0xfb, 0x8b, 0xc5, 0x0c, 0x49, 0xaa, 0x44, 0xe4, 0xbe, 0x42, 0x9d, 0xc5, 0x57, 0x76, 0x63,
-	0x8c, 0xf8, 0x90, 0x40, 0x6e, 0x31, 0xf6, 0x19, 0x80, 0x55, 0x8a, 0xc5, 0xed, 0xda, 0x75, 0x48,
-	0xc6, 0x21, 0xe4, 0x9d, 0xb8, 0x5d, 0x57, 0x6e, 0x3f, 0x97, 0x79, 0xd9, 0x18, 0xa5, 0xfb, 0xd4,
-	0x00, 0x66, 0xdf, 0xd4, 0x6e, 0xff, 0xc3, 0x2a, 0xd2, 0xee, 0x80, 0x28, 0xc3, 0x8a, 0x72, 0xb8,
-	0x8a, 0xb4, 0xf7, 0x4f, 0x0b, 0x1e, 0x29, 0xd4, 0x45, 0xa6, 0x70, 0xa3, 0x54, 0x5f, 0xd9, 0xaf,
-	0xb5, 0x6f, 0x46, 0x5d, 0x28, 0xb4, 0x7f, 0xb4, 0x2e, 0xb7, 0x6f, 0x9b, 0x97, 0x20, 0xdb, 0x83,
-	0x87, 0x9b, 0xe9, 0x09, 0xb2, 0x6b, 0x2a, 0x59, 0x97, 0x3f, 0x68, 0xe6, 0x66, 0x9e, 0x5d, 0x9b,
-	0xba, 0x45, 0x99, 0xba, 0xac, 0x8b, 0x5f, 0xd6, 0xad, 0xc4, 0xaa, 0xd2, 0x56, 0x97, 0x69, 0x94,
-	0x6d, 0x50, 0x62, 0x44, 0xa9, 0x2f, 0x56, 0x82, 0xa6, 0x6c, 0xad, 0xfa, 0x62, 0xbc, 0x04, 0xbd,
-	0x1b, 0x18, 0x34, 0x9f, 0x73, 0x00, 0xdd, 0xd0, 0xb6, 0xaa, 0x19, 0x9f, 0xa7, 0x8d, 0xf1, 0xb9,
-	0xdf, 0xa4, 0x9c, 0x88, 0xec, 0xb5, 0x19, 0x48, 0xd2, 0xa2, 0x71, 0x18, 0xcc, 0x3e, 0x6f, 0x8e,
-	0xf2, 0xc7, 0x09, 0xe3, 0x15, 0x7d, 0xef, 0xbb, 0xc6, 0x46, 0xb4, 0x9b, 0x8e, 0x39, 0xd0, 0xe3,
-	0x8b, 0x5f, 0x4f, 0xe6, 0xe3, 0x4f, 0xcc, 0xf1, 0xf0, 0x8c, 0x1f, 0x2f, 0xc6, 0x2d, 0xb6, 0x0d,
-	0x9d, 0xdf, 0x8e, 0x17, 0xe3, 0xb6, 0x39, 0xf0, 0xc3, 0xa3, 0x71, 0x67, 0xef, 0x00, 0xfa, 0xd5,
-	0xda, 0x63, 0x3b, 0x00, 0xe6, 0xec, 0x37, 0x3e, 0x3c, 0x7d, 0xf3, 0xc3, 0xf9, 0xbb, 0x71, 0x8b,
-	0xf5, 0xa1, 0x7b, 0xf2, 0xf3, 0xc9, 0x8f, 0xe3, 0xf6, 0xbf, 0x01, 0x00, 0x00, 0xff, 0xff, 0xcf,
-	0xd6, 0xdc, 0x0b, 0xa4, 0x08, 0x00, 0x00,
+	// 1069 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x84, 0x55, 0xdd, 0x8e, 0xd3, 0x46,
+	0x14, 0x6e, 0xe2, 0xec, 0x6e, 0x7c, 0x9c, 0xec, 0x86, 0x01, 0x21, 0x0b, 0x5a, 0xba, 0x35, 0x54,
+	0x0d, 0x7b, 0x01, 0x34, 0xa8, 0x12, 0xbd, 0xa9, 0x5a, 0xb2, 0x9b, 0x82, 0x04, 0xdb, 0xd5, 0x04,
+	0x54, 0x95, 0x1b, 0x6b, 0xb0, 0x8f, 0xb3, 0x23, 0xfc, 0xa7, 0x19, 0x07, 0xc8, 0xde, 0x54, 0x7d,
+	0x98, 0x3e, 0x44, 0x9f, 0xa2, 0x57, 0x7d, 0x9f, 0x6a, 0xce, 0xd8, 0x5e, 0x67, 0xa9, 0xd4, 0xbb,
+	0x39, 0xdf, 0xf9, 0xfc, 0x9d, 0x33, 0xe7, 0x67, 0x0c, 0xb7, 0xd3, 0x8f, 0xf1, 0xc3, 0x4c, 0xae,
+	0x94, 0xa8, 0x64, 0x91, 0xd7, 0x27, 0x7c, 0x50, 0xaa, 0xa2, 0x2a, 0x98, 0xdb, 0x3a, 0x82, 0xdf,
+	0xc1, 0x7d, 0x7e, 0xfc, 0x52, 0x94, 0xaf, 0x36, 0x25, 0xb2, 0x1b, 0xb0, 0x23, 0xf5, 0x5a, 0xc6,
+	0x7e, 0xef, 0xb0, 0x3f, 0x1d, 0x72, 0x6b, 0x58, 0x74, 0x25, 0x63, 0xbf, 0xdf, 0xa0, 0x2b, 0x19,
+	0xb3, 0x9b, 0xb0, 0x7b, 0x5e, 0xe8, 0x4a, 0xc6, 0xbe, 0x73, 0xd8, 0x9f, 0xee, 0xf0, 0xda, 0x62,
+	0x0c, 0x06, 0xb9, 0x96, 0xb1, 0x3f, 0x20, 0x94, 0xce, 0xec, 0x16, 0x0c, 0x33, 0x51, 0x2a, 0x91,
+	0xaf, 0xd0, 0xdf, 0x21, 0xbc, 0xb5, 0x83, 0x47, 0xb0, 0x3b, 0x2f, 0xf2, 0x44, 0xae, 0xd8, 0x04,
+	0x9c, 0x77, 0xb8, 0xa1, 0xd8, 0x2e, 0x37, 0x47, 0x13, 0xf9, 0xbd, 0x48, 0xd7, 0x48, 0x91, 0x5d,
+	0x6e, 0x8d, 0xe0, 0x67, 0xd8, 0x3d, 0xc6, 0xf7, 0x32, 0x42, 0x8a, 0x25, 0x32, 0xac, 0x3f, 0xa1,
+	0x33, 0xbb, 0x0f, 0xbb, 0x11, 0xe9, 0xf9, 0xfd, 0x43, 0x67, 0xea, 0xcd, 0xae, 0x3d, 0x68, 0x2f,
+	0xfb, 0xc0, 0x06, 0xe2, 0x35, 0x21, 0xf8, 0xbb, 0x0f, 0xc3, 0x65, 0x2e, 0x4a, 0x7d, 0x5e, 0x54,
+	0xff, 0xa9, 0xf5, 0x18, 0xbc, 0xb4, 0x88, 0x44, 0x3a, 0xff, 0x1f, 0xc1, 0x2e, 0xcb, 0x5c, 0xb6,
+	0x54, 0x45, 0x22, 0x53, 0xd4, 0xbe, 0x73, 0xe8, 0x4c, 0x5d, 0xde, 0xda, 0xec, 0x73, 0x70, 0xb1,
+	0x3c, 0xc7, 0x0c, 0x95, 0x48, 0xa9, 0x42, 0x43, 0x7e, 0x09, 0xb0, 0xef, 0x60, 0x44, 0x42, 0xf6,
+	0x76, 0xda, 0xdf, 0xf9, 0x24, 0x9e, 0xf5, 0xf0, 0x2d, 0x1a, 0x0b, 0x60, 0x24, 0x54, 0x74, 0x2e,
+	0x2b, 0x8c, 0xaa, 0xb5, 0x42, 0x7f, 0x97, 0x2a, 0xbc, 0x85, 0x99, 0xa4, 0x74, 0x25, 0x2a, 0x4c,
+	0xd6, 0xa9, 0xbf, 0x47, 0x71, 0x5b, 0x9b, 0xdd, 0x85, 0x71, 0xa4, 0x90, 0x02, 0x84, 0xb1, 0xa8,
+	0xd0, 0x1f, 0x1e, 0xf6, 0xa6, 0x0e, 0x1f, 0x35, 0xe0, 0xb1, 0xa8, 0x90, 0xdd, 0x83, 0xfd, 0x54,
+	0xe8, 0x2a, 0x5c, 0x6b, 0x8c, 0x2d, 0xcb, 0xb5, 0x2c, 0x83, 0xbe, 0xd6, 0x18, 0x1b, 0x56, 0xf0,
+	0x47, 0x0f, 0xc6, 0x4a, 0x6f, 0xf2, 0x68, 0x81, 0xc2, 0xc4, 0xd5, 0x66, 0x4c, 0x3e, 0x8a, 0xaa,
+	0x52, 0xda, 0xef, 0x1d, 0xf6, 0xa6, 0x43, 0x5e, 0x5b, 0x06, 0x8f, 0x31, 0xc5, 0xca, 0xf4, 0x96,
+	0x70, 0x6b, 0x99, 0x44, 0xa3, 0x22, 0x2b, 0x15, 0x6a, 0x53, 0x3d, 0xe3, 0x69, 0x6d, 0x76, 0x0f,
+	0xc6, 0x6f, 0x65, 0x2c, 0x15, 0x46, 0x26, 0x2d, 0xaa, 0xa0, 0x21, 0x6c, 0x83, 0xc1, 0x7d, 0xf0,
+	0x2e, 0x12, 0xdd, 0x26, 0xd0, 0x15, 0xec, 0x6d, 0x0b, 0x06, 0x7f, 0x39, 0x70, 0xf0, 0xb2, 0x29,
+	0xee, 0x33, 0x14, 0x31, 0x2a, 0x76, 0x04, 0xfd, 0x44, 0xd3, 0x14, 0xec, 0xcf, 0x6e, 0x75, 0x4a,
+	0xdf, 0xf2, 0x16, 0x4b, 0xb3, 0x2b, 0xbc, 0x9f, 0x68, 0xf6, 0x0d, 0x0c, 0x22, 0x25, 0xd7, 0x74,
+	0x85, 0xfd, 0xd9, 0xf5, 0xee, 0x60, 0xf0, 0xe7, 0xaf, 0x89, 0x46, 0x04, 0x76, 0x04, 0x3b, 0x32,
+	0xce, 0x44, 0x49, 0x03, 0xe1, 0xcd, 0x6e, 0x74, 0x98, 0xed, 0xf6, 0x71, 0x4b, 0x31, 0xb7, 0xd4,
+	0xf5, 0x50, 0x9e, 0x8a, 0x0c, 0xb5, 0x3f, 0xa0, 0x21, 0xda, 0x06, 0xd9, 0xb7, 0xe0, 0x36, 0x40,
+	0x33, 0x28, 0xdd, 0xf8, 0xcd, 0x58, 0xf3, 0x4b, 0x16, 0xf3, 0x61, 0xaf, 0x54, 0x18, 0xaf, 0xb3,
+	0xd2, 0xdf, 0xa3, 0x42, 0x34, 0x26, 0xfb, 0xe1, 0x4a, 0xd7, 0x68, 0x02, 0xbc, 0x99, 0xdf, 0x11,
+	0xdc, 0xf2, 0xf3, 0x2b, 0x4d, 0xf6, 0x61, 0x4f, 0x61, 0xa2, 0x50, 0x9f, 0xd3, 0x54, 0x0c, 0x79,
+	0x63, 0xb2, 0x27, 0x5b, 0xcd, 0xf0, 0x81, 0x74, 0x6f, 0x76, 0x74, 0x3b, 0x5e, 0xbe, 0xd5, 0xb7,
+	0x3b, 0x00, 0x89, 0x42, 0xbc, 0xc0, 0xe5, 0x26, 0x8f, 0x7c, 0x8f, 0x64, 0x3b, 0x48, 0xb0, 0x80,
+	0x49, 0xdb, 0x92, 0x79, 0x91, 0x57, 0xaa, 0x48, 0x4d, 0x1e, 0x7a, 0x1d, 0x45, 0xb6, 0xd5, 0x66,
+	0xc8, 0x1b, 0xd3, 0x78, 0x32, 0xd4, 0x5a, 0xac, 0xec, 0xbc, 0xb9, 0xbc, 0x31, 0x83, 0xc7, 0x30,
+	0x6e, 0x75, 0x8c, 0xb0, 0x59, 0xa7, 0x44, 0xe6, 0x22, 0x3d, 0x53, 0x78, 0x6c, 0x6a, 0x65, 0x95,
+	0xb6, 0xb0, 0xe0, 0x4f, 0x07, 0x26, 0xa6, 0x72, 0xa1, 0x59, 0x22, 0x1d, 0x62, 0x5e, 0xa9, 0x8d,
+	0xd9, 0x23, 0xca, 0x4f, 0xe6, 0xab, 0xb0, 0x92, 0xf5, 0x53, 0x32, 0xe6, 0xa3, 0x06, 0x7c, 0x25,
+	0x33, 0x64, 0x5f, 0x82, 0x97, 0xa8, 0xe2, 0x02, 0x73, 0x4b, 0xe9, 0x13, 0x05, 0x2c, 0x44, 0x84,
+	0xaf, 0x60, 0x94, 0x61, 0x46, 0xe2, 0xc4, 0x70, 0x88, 0xe1, 0xd5, 0x18, 0x51, 0xee, 0xc2, 0x38,
+	0xc3, 0xec, 0x83, 0x92, 0x15, 0x5a, 0xce, 0xc0, 0x06, 0x6a, 0xc0, 0x86, 0x54, 0x8a, 0x15, 0xea,
+	0x50, 0x47, 0x22, 0xcf, 0x31, 0xa6, 0x87, 0x77, 0xc0, 0x47, 0x04, 0x2e, 0x2d, 0xc6, 0x1e, 0xc1,
+	0x8d, 0x9a, 0xf4, 0x4e, 0x96, 0x25, 0xc6, 0x61, 0x29, 0x14, 0xe6, 0x15, 0x3d, 0x21, 0x03, 0xce,
+	0x2c, 0xd7, 0xba, 0xce, 0xc8, 0x73, 0x29, 0x6b, 0x22, 0x55, 0x98, 0xfb, 0x7b, 0x1d, 0xd9, 0x5f,
+	0x2d, 0x66, 0x48, 0x52, 0x65, 0xa2, 0x0c, 0x15, 0xea, 0x22, 0x7d, 0x6f, 0x5f, 0x94, 0x31, 0x1f,
+	0x11, 0xc8, 0x2d, 0xc6, 0xbe, 0x00, 0xb0, 0x4a, 0xa9, 0xb8, 0xd8, 0xf8, 0x2e, 0xc9, 0xb8, 0x84,
+	0xbc, 0x10, 0x17, 0x9b, 0xc6, 0x1d, 0x96, 0xb2, 0xac, 0x07, 0xa7, 0x76, 0x9f, 0x19, 0xc0, 0xbc,
+	0x47, 0xad, 0x3b, 0x7c, 0xbb, 0x4e, 0x34, 0x8d, 0x48, 0x9d, 0x88, 0xa1, 0x3c, 0x5d, 0x27, 0x3a,
+	0xf8, 0xa7, 0x07, 0xd7, 0x15, 0xea, 0xaa, 0x50, 0xb8, 0xd5, 0xaa, 0xaf, 0xed, 0xd7, 0x3a, 0x34,
+	0x4f, 0x81, 0x50, 0x68, 0xff, 0x78, 0x03, 0x6e, 0xef, 0x36, 0xaf, 0x41, 0x76, 0x04, 0xd7, 0xb6,
+	0xcb, 0x13, 0x15, 0x1f, 0xa8, 0x65, 0x03, 0x7e, 0xd0, 0xad, 0xcd, 0xbc, 0xf8, 0x60, 0xfa, 0x96,
+	0x14, 0xea, 0x5d, 0xdb, 0xfc, 0xba, 0x6f, 0x35, 0xd6, 0xb4, 0xb6, 0x49, 0xa6, 0xd3, 0x36, 0xaf,
+	0xc6, 0x88, 0xd2, 0x26, 0x56, 0x83, 0xa6, 0x6d, 0xbd, 0x36, 0x31, 0x5e, 0x83, 0xc1, 0x47, 0xf0,
+	0xba, 0xd7, 0x79, 0x08, 0x83, 0xd8, 0x8e, 0xaa, 0x59, 0xaf, 0xdb, 0x9d, 0xf5, 0xba, 0x3a, 0xa4,
+	0x9c, 0x88, 0xec, 0x89, 0x59, 0x58, 0xd2, 0xa2, 0x75, 0xf0, 0x66, 0x77, 0xba, 0xab, 0xfe, 0x69,
+	0xc1, 0x78, 0x43, 0x3f, 0xfa, 0x1e, 0x0e, 0xae, 0xbc, 0x84, 0xcc, 0x85, 0x1d, 0xbe, 0xfc, 0xed,
+	0x74, 0x3e, 0xf9, 0xcc, 0x1c, 0x9f, 0xbe, 0xe2, 0x8b, 0xe5, 0xa4, 0xc7, 0xf6, 0xc0, 0x79, 0xb3,
+	0x58, 0x4e, 0xfa, 0xe6, 0xc0, 0x9f, 0x1e, 0x4f, 0x9c, 0xa3, 0x1f, 0x61, 0xd8, 0x3c, 0x8b, 0x6c,
+	0x1f, 0xc0, 0x9c, 0xc3, 0xce, 0x87, 0x67, 0xcf, 0x7e, 0x7a, 0xfd, 0x62, 0xd2, 0x63, 0x43, 0x18,
+	0x9c, 0xfe, 0x72, 0x7a, 0x32, 0xe9, 0xb3, 0x03, 0xf0, 0x16, 0xfc, 0xe4, 0xe4, 0xcd, 0x49, 0x48,
+	0x2c, 0xe7, 0xdf, 0x01, 0x00, 0x6b, 0x84, 0x5c, 0x07, 0xd5, 0x08, 0x00, 0x00,
 }
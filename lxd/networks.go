@@ -116,15 +116,19 @@ func networksPost(d *Daemon, r *http.Request) Response {
 		return BadRequest(err)
 	}
 
-	if req.Type != "" && req.Type != "bridge" {
-		return BadRequest(fmt.Errorf("Only 'bridge' type networks can be created"))
+	if req.Type == "" {
+		req.Type = "bridge"
+	}
+
+	if !shared.StringInSlice(req.Type, []string{"bridge", "external"}) {
+		return BadRequest(fmt.Errorf("Only 'bridge' and 'external' type networks can be created"))
 	}
 
 	if req.Config == nil {
 		req.Config = map[string]string{}
 	}
 
-	err = networkValidateConfig(req.Name, req.Config)
+	err = networkValidateConfig(req.Name, req.Type, req.Config)
 	if err != nil {
 		return BadRequest(err)
 	}
@@ -154,7 +158,12 @@ func networksPost(d *Daemon, r *http.Request) Response {
 			}
 		}
 		err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
-			return tx.NetworkCreatePending(targetNode, req.Name, req.Config)
+			err := tx.NetworkCreatePending(targetNode, req.Name, req.Config)
+			if err != nil {
+				return err
+			}
+
+			return tx.NetworkTypeSet(req.Name, networkTypeCode(req.Type))
 		})
 		if err != nil {
 			if err == db.ErrAlreadyDefined {
@@ -165,9 +174,11 @@ func networksPost(d *Daemon, r *http.Request) Response {
 		return response
 	}
 
-	err = networkFillConfig(&req)
-	if err != nil {
-		return SmartError(err)
+	if req.Type == "bridge" {
+		err = networkFillConfig(&req)
+		if err != nil {
+			return SmartError(err)
+		}
 	}
 
 	// Check if we're clustered
@@ -202,6 +213,13 @@ func networksPost(d *Daemon, r *http.Request) Response {
 		return SmartError(fmt.Errorf("Error inserting %s into database: %s", req.Name, err))
 	}
 
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		return tx.NetworkTypeSet(req.Name, networkTypeCode(req.Type))
+	})
+	if err != nil {
+		return SmartError(err)
+	}
+
 	err = doNetworksCreate(d, req, true)
 	if err != nil {
 		return SmartError(err)
@@ -241,6 +259,12 @@ func networksPostCluster(d *Daemon, req api.NetworksPost) error {
 			return err
 		}
 
+		// Record the network type.
+		err = tx.NetworkTypeSet(req.Name, networkTypeCode(req.Type))
+		if err != nil {
+			return err
+		}
+
 		// Insert the global config keys.
 		return tx.NetworkConfigAdd(networkID, 0, req.Config)
 	})
@@ -412,6 +436,9 @@ func doNetworkGet(d *Daemon, name string) (api.Network, error) {
 		}
 
 		n.Type = "bridge"
+		if dbInfo != nil && dbInfo.Type != "" {
+			n.Type = dbInfo.Type
+		}
 	} else if shared.PathExists(fmt.Sprintf("/proc/net/vlan/%s", n.Name)) {
 		n.Type = "vlan"
 	} else if shared.PathExists(fmt.Sprintf("/sys/class/net/%s/device", n.Name)) {
@@ -665,8 +692,14 @@ func networkPatch(d *Daemon, r *http.Request) Response {
 }
 
 func doNetworkUpdate(d *Daemon, name string, oldConfig map[string]string, req api.NetworkPut) Response {
+	// Load the network
+	n, err := networkLoadByName(d.State(), name)
+	if err != nil {
+		return NotFound(err)
+	}
+
 	// Validate the configuration
-	err := networkValidateConfig(name, req.Config)
+	err = networkValidateConfig(name, n.netType, req.Config)
 	if err != nil {
 		return BadRequest(err)
 	}
@@ -678,18 +711,50 @@ func doNetworkUpdate(d *Daemon, name string, oldConfig map[string]string, req ap
 		}
 	}
 
-	// Load the network
-	n, err := networkLoadByName(d.State(), name)
+	err = n.Update(req)
 	if err != nil {
-		return NotFound(err)
+		return SmartError(err)
 	}
 
-	err = n.Update(req)
+	return EmptySyncResponse
+}
+
+// networkExternalLeasesGet lists the addresses of an external network which
+// are allocated to the containers of a project, whatever their node.
+func networkExternalLeasesGet(d *Daemon, name string, project string) Response {
+	leases := []api.NetworkLease{}
+
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		networkID, err := tx.NetworkID(name)
+		if err != nil {
+			return err
+		}
+
+		allocations, err := tx.NetworkAllocations(networkID)
+		if err != nil {
+			return err
+		}
+
+		for _, allocation := range allocations {
+			if allocation.Project != project {
+				continue
+			}
+
+			leases = append(leases, api.NetworkLease{
+				Hostname: allocation.Instance,
+				Address:  allocation.Address,
+				Type:     "allocated",
+				Location: allocation.Node,
+			})
+		}
+
+		return nil
+	})
 	if err != nil {
 		return SmartError(err)
 	}
 
-	return EmptySyncResponse
+	return SyncResponse(true, leases)
 }
 
 func networkLeasesGet(d *Daemon, r *http.Request) Response {
@@ -702,6 +767,11 @@ func networkLeasesGet(d *Daemon, r *http.Request) Response {
 		return SmartError(err)
 	}
 
+	// External networks hand out addresses from the database
+	if n.Managed && n.Type == "external" {
+		return networkExternalLeasesGet(d, name, project)
+	}
+
 	// Validate that we do have leases for it
 	if !n.Managed || n.Type != "bridge" {
 		return NotFound(errors.New("Leases not found"))
@@ -772,49 +842,27 @@ func networkLeasesGet(d *Daemon, r *http.Request) Response {
 	}
 
 	// Get dynamic leases
-	leaseFile := shared.VarPath("networks", name, "dnsmasq.leases")
-	if !shared.PathExists(leaseFile) {
-		return SyncResponse(true, leases)
-	}
-
-	content, err := ioutil.ReadFile(leaseFile)
+	dynamicLeases, err := networkDynamicLeases(name, serverName)
 	if err != nil {
 		return SmartError(err)
 	}
 
-	for _, lease := range strings.Split(string(content), "\n") {
-		fields := strings.Fields(lease)
-		if len(fields) >= 5 {
-			// Parse the MAC
-			mac := networkGetMacSlice(fields[1])
-			macStr := strings.Join(mac, ":")
-
-			if len(macStr) < 17 && fields[4] != "" {
-				macStr = fields[4][len(fields[4])-17:]
-			}
-
-			// Look for an existing static entry
-			found := false
-			for _, entry := range leases {
-				if entry.Hwaddr == macStr && entry.Address == fields[2] {
-					found = true
-					break
-				}
-			}
-
-			if found {
-				continue
+	for _, lease := range dynamicLeases {
+		// Look for an existing static entry
+		found := false
+		for _, entry := range leases {
+			if entry.Hwaddr == lease.Hwaddr && entry.Address == lease.Address {
+				found = true
+				break
 			}
+		}
 
-			// Add the lease to the list
-			leases = append(leases, api.NetworkLease{
-				Hostname: fields[3],
-				Address:  fields[2],
-				Hwaddr:   macStr,
-				Type:     "dynamic",
-				Location: serverName,
-			})
+		if found {
+			continue
 		}
+
+		// Add the lease to the list
+		leases = append(leases, lease)
 	}
 
 	// Collect leases from other servers
@@ -853,6 +901,45 @@ func networkLeasesGet(d *Daemon, r *http.Request) Response {
 	return SyncResponse(true, leases)
 }
 
+// networkDynamicLeases returns the leases handed out by the dnsmasq instance of
+// a network on this node.
+func networkDynamicLeases(name string, location string) ([]api.NetworkLease, error) {
+	leases := []api.NetworkLease{}
+
+	leaseFile := shared.VarPath("networks", name, "dnsmasq.leases")
+	if !shared.PathExists(leaseFile) {
+		return leases, nil
+	}
+
+	content, err := ioutil.ReadFile(leaseFile)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, lease := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(lease)
+		if len(fields) >= 5 {
+			// Parse the MAC
+			mac := networkGetMacSlice(fields[1])
+			macStr := strings.Join(mac, ":")
+
+			if len(macStr) < 17 && fields[4] != "" {
+				macStr = fields[4][len(fields[4])-17:]
+			}
+
+			leases = append(leases, api.NetworkLease{
+				Hostname: fields[3],
+				Address:  fields[2],
+				Hwaddr:   macStr,
+				Type:     "dynamic",
+				Location: location,
+			})
+		}
+	}
+
+	return leases, nil
+}
+
 // The network structs and functions
 func networkLoadByName(s *state.State, name string) (*network, error) {
 	id, dbInfo, err := s.Cluster.NetworkGet(name)
@@ -860,7 +947,7 @@ func networkLoadByName(s *state.State, name string) (*network, error) {
 		return nil, err
 	}
 
-	n := network{state: s, id: id, name: name, description: dbInfo.Description, config: dbInfo.Config}
+	n := network{state: s, id: id, name: name, netType: dbInfo.Type, description: dbInfo.Description, config: dbInfo.Config}
 
 	return &n, nil
 }
@@ -941,6 +1028,7 @@ type network struct {
 	state       *state.State
 	id          int64
 	name        string
+	netType     string
 	description string
 
 	// config
@@ -952,6 +1040,11 @@ func (n *network) Config() map[string]string {
 }
 
 func (n *network) IsRunning() bool {
+	// External networks have no interface of their own
+	if n.netType == "external" {
+		return false
+	}
+
 	return shared.PathExists(fmt.Sprintf("/sys/class/net/%s", n.name))
 }
 
@@ -1051,6 +1144,15 @@ func (n *network) Start() error {
 		return nil
 	}
 
+	// External networks only need their parent interface to be present
+	if n.netType == "external" {
+		if n.config["parent"] != "" && !shared.PathExists(fmt.Sprintf("/sys/class/net/%s", n.config["parent"])) {
+			return fmt.Errorf("Parent interface '%s' doesn't exist", n.config["parent"])
+		}
+
+		return nil
+	}
+
 	// Create directory
 	if !shared.PathExists(shared.VarPath("networks", n.name)) {
 		err := os.MkdirAll(shared.VarPath("networks", n.name), 0711)
@@ -1842,6 +1944,9 @@ func (n *network) Start() error {
 			} else {
 				dnsmasqCmd = append(dnsmasqCmd, []string{"-s", dnsDomain, "-S", fmt.Sprintf("/%s/", dnsDomain)}...)
 			}
+
+			// Names of the devices whose addresses aren't handed out by dnsmasq
+			dnsmasqCmd = append(dnsmasqCmd, fmt.Sprintf("--hostsdir=%s", shared.VarPath("networks", n.name, "dnsmasq.dns")))
 		}
 
 		// Create a config file to contain additional config (and to prevent dnsmasq from reading /etc/dnsmasq.conf)
@@ -1870,6 +1975,14 @@ func (n *network) Start() error {
 			}
 		}
 
+		// Create DNS hosts directory
+		if !shared.PathExists(shared.VarPath("networks", n.name, "dnsmasq.dns")) {
+			err = os.MkdirAll(shared.VarPath("networks", n.name, "dnsmasq.dns"), 0755)
+			if err != nil {
+				return err
+			}
+		}
+
 		// Check for dnsmasq
 		_, err := exec.LookPath("dnsmasq")
 		if err != nil {
@@ -1990,6 +2103,10 @@ func (n *network) Stop() error {
 }
 
 func (n *network) Update(newNetwork api.NetworkPut) error {
+	if n.netType == "external" {
+		return n.updateExternal(newNetwork)
+	}
+
 	err := networkFillAuto(newNetwork.Config)
 	if err != nil {
 		return err
@@ -2111,6 +2228,26 @@ func (n *network) Update(newNetwork api.NetworkPut) error {
 	return nil
 }
 
+// updateExternal applies a new configuration to an external network. The
+// subnets can't be changed while addresses are handed out from them.
+func (n *network) updateExternal(newNetwork api.NetworkPut) error {
+	for _, key := range []string{"ipv4.address", "ipv6.address"} {
+		if n.config[key] != newNetwork.Config[key] && n.IsUsed() {
+			return fmt.Errorf("The %s key can't be changed while the network is in use", key)
+		}
+	}
+
+	err := n.state.Cluster.NetworkUpdate(n.name, newNetwork.Description, newNetwork.Config)
+	if err != nil {
+		return err
+	}
+
+	n.config = newNetwork.Config
+	n.description = newNetwork.Description
+
+	return nil
+}
+
 func (n *network) spawnForkDNS(listenAddress string) error {
 	// Setup the dnsmasq domain
 	dnsDomain := n.config["dns.domain"]
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"net"
 	"strconv"
 	"strings"
 
@@ -9,102 +10,394 @@ import (
 	"github.com/lxc/lxd/shared"
 )
 
-var networkConfigKeys = map[string]func(value string) error{
-	"bridge.driver": func(value string) error {
-		return shared.IsOneOf(value, []string{"native", "openvswitch"})
+var networkConfigKeys = map[string]shared.ConfigKey{
+	"bridge.driver": {
+		Type:        "string",
+		Condition:   "-",
+		Default:     "native",
+		Description: "Bridge driver (\"native\" or \"openvswitch\")",
+		Validator: func(value string) error {
+			return shared.IsOneOf(value, []string{"native", "openvswitch"})
+		},
 	},
-	"bridge.external_interfaces": func(value string) error {
-		if value == "" {
+	"bridge.external_interfaces": {
+		Type:        "string",
+		Condition:   "-",
+		Default:     "-",
+		Description: "Comma separate list of unconfigured network interfaces to include in the bridge",
+		Validator: func(value string) error {
+			if value == "" {
+				return nil
+			}
+
+			for _, entry := range strings.Split(value, ",") {
+				entry = strings.TrimSpace(entry)
+				if networkValidName(entry) != nil {
+					return fmt.Errorf("Invalid interface name '%s'", entry)
+				}
+			}
+
 			return nil
-		}
+		},
+	},
+	"bridge.hwaddr": {
+		Type:        "string",
+		Condition:   "-",
+		Default:     "-",
+		Description: "MAC address for the bridge",
+		Validator:   shared.IsAny,
+	},
+	"bridge.mtu": {
+		Type:        "integer",
+		Condition:   "-",
+		Default:     "1500",
+		Description: "Bridge MTU (default varies if tunnel or fan setup)",
+		Validator:   shared.IsInt64,
+	},
+	"bridge.mode": {
+		Type:        "string",
+		Condition:   "-",
+		Default:     "standard",
+		Description: "Bridge operation mode (\"standard\" or \"fan\")",
+		Validator: func(value string) error {
+			return shared.IsOneOf(value, []string{"standard", "fan"})
+		},
+	},
 
-		for _, entry := range strings.Split(value, ",") {
-			entry = strings.TrimSpace(entry)
-			if networkValidName(entry) != nil {
-				return fmt.Errorf("Invalid interface name '%s'", entry)
+	"fan.overlay_subnet": {
+		Type:        "string",
+		Condition:   "fan mode",
+		Default:     "240.0.0.0/8",
+		Description: "Subnet to use as the overlay for the FAN (CIDR notation)",
+		Validator:   device.NetworkValidNetworkV4,
+	},
+	"fan.underlay_subnet": {
+		Type:        "string",
+		Condition:   "fan mode",
+		Default:     "default gateway subnet",
+		Description: "Subnet to use as the underlay for the FAN (CIDR notation)",
+		Validator: func(value string) error {
+			if value == "auto" {
+				return nil
 			}
-		}
 
-		return nil
+			return device.NetworkValidNetworkV4(value)
+		},
 	},
-	"bridge.hwaddr": shared.IsAny,
-	"bridge.mtu":    shared.IsInt64,
-	"bridge.mode": func(value string) error {
-		return shared.IsOneOf(value, []string{"standard", "fan"})
+	"fan.type": {
+		Type:        "string",
+		Condition:   "fan mode",
+		Default:     "vxlan",
+		Description: "The tunneling type for the FAN (\"vxlan\" or \"ipip\")",
+		Validator: func(value string) error {
+			return shared.IsOneOf(value, []string{"vxlan", "ipip"})
+		},
 	},
 
-	"fan.overlay_subnet": device.NetworkValidNetworkV4,
-	"fan.underlay_subnet": func(value string) error {
-		if value == "auto" {
+	"tunnel.TARGET.protocol": {
+		Type:        "string",
+		Condition:   "standard mode",
+		Default:     "-",
+		Description: "Tunneling protocol (\"vxlan\" or \"gre\")",
+		Validator: func(value string) error {
+			return shared.IsOneOf(value, []string{"gre", "vxlan"})
+		},
+	},
+	"tunnel.TARGET.local": {
+		Type:        "string",
+		Condition:   "gre or vxlan",
+		Default:     "-",
+		Description: "Local address for the tunnel (not necessary for multicast vxlan)",
+		Validator:   device.NetworkValidAddress,
+	},
+	"tunnel.TARGET.remote": {
+		Type:        "string",
+		Condition:   "gre or vxlan",
+		Default:     "-",
+		Description: "Remote address for the tunnel (not necessary for multicast vxlan)",
+		Validator:   device.NetworkValidAddress,
+	},
+	"tunnel.TARGET.port": {
+		Type:        "integer",
+		Condition:   "vxlan",
+		Default:     "0",
+		Description: "Specific port to use for the vxlan tunnel",
+		Validator:   networkValidPort,
+	},
+	"tunnel.TARGET.group": {
+		Type:        "string",
+		Condition:   "vxlan",
+		Default:     "239.0.0.1",
+		Description: "Multicast address for vxlan (used if local and remote aren't set)",
+		Validator:   device.NetworkValidAddress,
+	},
+	"tunnel.TARGET.id": {
+		Type:        "integer",
+		Condition:   "vxlan",
+		Default:     "0",
+		Description: "Specific tunnel ID to use for the vxlan tunnel",
+		Validator:   shared.IsInt64,
+	},
+	"tunnel.TARGET.interface": {
+		Type:        "string",
+		Condition:   "vxlan",
+		Default:     "-",
+		Description: "Specific host interface to use for the tunnel",
+		Validator:   networkValidName,
+	},
+	"tunnel.TARGET.ttl": {
+		Type:        "integer",
+		Condition:   "vxlan",
+		Default:     "1",
+		Description: "Specific TTL to use for multicast routing topologies",
+		Validator:   shared.IsUint8,
+	},
+
+	"ipv4.address": {
+		Type:        "string",
+		Condition:   "standard mode",
+		Default:     "random unused subnet",
+		Description: "IPv4 address for the bridge (CIDR notation). Use \"none\" to turn off IPv4 or \"auto\" to generate a new one",
+		Validator: func(value string) error {
+			if shared.IsOneOf(value, []string{"none", "auto"}) == nil {
+				return nil
+			}
+
+			return networkValidAddressCIDRV4(value)
+		},
+	},
+	"ipv4.firewall": {
+		Type:        "boolean",
+		Condition:   "ipv4 address",
+		Default:     "true",
+		Description: "Whether to generate filtering firewall rules for this network",
+		Validator:   shared.IsBool,
+	},
+	"ipv4.nat": {
+		Type:        "boolean",
+		Condition:   "ipv4 address",
+		Default:     "false",
+		Description: "Whether to NAT (will default to true if unset and a random ipv4.address is generated)",
+		Validator:   shared.IsBool,
+	},
+	"ipv4.nat.order": {
+		Type:        "string",
+		Condition:   "ipv4 address",
+		Default:     "before",
+		Description: "Whether to add the required NAT rules before or after any pre-existing rules",
+		Validator: func(value string) error {
+			return shared.IsOneOf(value, []string{"before", "after"})
+		},
+	},
+	"ipv4.nat.address": {
+		Type:        "string",
+		Condition:   "ipv4 address",
+		Default:     "-",
+		Description: "The source address used for outbound traffic from the bridge",
+		Validator:   device.NetworkValidAddressV4,
+	},
+	"ipv4.dhcp": {
+		Type:        "boolean",
+		Condition:   "ipv4 address",
+		Default:     "true",
+		Description: "Whether to allocate addresses using DHCP",
+		Validator:   shared.IsBool,
+	},
+	"ipv4.dhcp.gateway": {
+		Type:        "string",
+		Condition:   "ipv4 dhcp",
+		Default:     "ipv4.address",
+		Description: "Address of the gateway for the subnet",
+		Validator:   device.NetworkValidAddressV4,
+	},
+	"ipv4.dhcp.expiry": {
+		Type:        "string",
+		Condition:   "ipv4 dhcp",
+		Default:     "1h",
+		Description: "When to expire DHCP leases",
+		Validator:   shared.IsAny,
+	},
+	"ipv4.dhcp.ranges": {
+		Type:        "string",
+		Condition:   "ipv4 dhcp",
+		Default:     "all addresses",
+		Description: "Comma separated list of IP ranges to use for DHCP (FIRST-LAST format)",
+		Validator:   shared.IsAny,
+	},
+	"ipv4.routes": {
+		Type:        "string",
+		Condition:   "ipv4 address",
+		Default:     "-",
+		Description: "Comma separated list of additional IPv4 CIDR subnets to route to the bridge",
+		Validator:   shared.IsAny,
+	},
+	"ipv4.routing": {
+		Type:        "boolean",
+		Condition:   "ipv4 address",
+		Default:     "true",
+		Description: "Whether to route traffic in and out of the bridge",
+		Validator:   shared.IsBool,
+	},
+
+	"ipv6.address": {
+		Type:        "string",
+		Condition:   "standard mode",
+		Default:     "random unused subnet",
+		Description: "IPv6 address for the bridge (CIDR notation). Use \"none\" to turn off IPv6 or \"auto\" to generate a new one",
+		Validator: func(value string) error {
+			if shared.IsOneOf(value, []string{"none", "auto"}) == nil {
+				return nil
+			}
+
+			return networkValidAddressCIDRV6(value)
+		},
+	},
+	"ipv6.firewall": {
+		Type:        "boolean",
+		Condition:   "ipv6 address",
+		Default:     "true",
+		Description: "Whether to generate filtering firewall rules for this network",
+		Validator:   shared.IsBool,
+	},
+	"ipv6.nat": {
+		Type:        "boolean",
+		Condition:   "ipv6 address",
+		Default:     "false",
+		Description: "Whether to NAT (will default to true if unset and a random ipv6.address is generated)",
+		Validator:   shared.IsBool,
+	},
+	"ipv6.nat.order": {
+		Type:        "string",
+		Condition:   "ipv6 address",
+		Default:     "before",
+		Description: "Whether to add the required NAT rules before or after any pre-existing rules",
+		Validator: func(value string) error {
+			return shared.IsOneOf(value, []string{"before", "after"})
+		},
+	},
+	"ipv6.nat.address": {
+		Type:        "string",
+		Condition:   "ipv6 address",
+		Default:     "-",
+		Description: "The source address used for outbound traffic from the bridge",
+		Validator:   device.NetworkValidAddressV6,
+	},
+	"ipv6.dhcp": {
+		Type:        "boolean",
+		Condition:   "ipv6 address",
+		Default:     "true",
+		Description: "Whether to provide additional network configuration over DHCP",
+		Validator:   shared.IsBool,
+	},
+	"ipv6.dhcp.expiry": {
+		Type:        "string",
+		Condition:   "ipv6 dhcp",
+		Default:     "1h",
+		Description: "When to expire DHCP leases",
+		Validator:   shared.IsAny,
+	},
+	"ipv6.dhcp.stateful": {
+		Type:        "boolean",
+		Condition:   "ipv6 dhcp",
+		Default:     "false",
+		Description: "Whether to allocate addresses using DHCP",
+		Validator:   shared.IsBool,
+	},
+	"ipv6.dhcp.ranges": {
+		Type:        "string",
+		Condition:   "ipv6 stateful dhcp",
+		Default:     "all addresses",
+		Description: "Comma separated list of IPv6 ranges to use for DHCP (FIRST-LAST format)",
+		Validator:   shared.IsAny,
+	},
+	"ipv6.routes": {
+		Type:        "string",
+		Condition:   "ipv6 address",
+		Default:     "-",
+		Description: "Comma separated list of additional IPv6 CIDR subnets to route to the bridge",
+		Validator:   shared.IsAny,
+	},
+	"ipv6.routing": {
+		Type:        "boolean",
+		Condition:   "ipv6 address",
+		Default:     "true",
+		Description: "Whether to route traffic in and out of the bridge",
+		Validator:   shared.IsBool,
+	},
+
+	"dns.domain": {
+		Type:        "string",
+		Condition:   "-",
+		Default:     "lxd",
+		Description: "Domain to advertise to DHCP clients and use for DNS resolution",
+		Validator:   shared.IsAny,
+	},
+	"dns.mode": {
+		Type:        "string",
+		Condition:   "-",
+		Default:     "managed",
+		Description: "DNS registration mode (\"none\" for no DNS record, \"managed\" for LXD generated static records or \"dynamic\" for client generated records)",
+		Validator: func(value string) error {
+			return shared.IsOneOf(value, []string{"dynamic", "managed", "none"})
+		},
+	},
+
+	"raw.dnsmasq": {
+		Type:        "string",
+		Condition:   "-",
+		Default:     "-",
+		Description: "Additional dnsmasq configuration to append to the configuration",
+		Validator:   shared.IsAny,
+	},
+}
+
+// networkExternalConfigKeys lists the configuration keys of external networks.
+var networkExternalConfigKeys = map[string]func(value string) error{
+	"parent": func(value string) error {
+		if value == "" {
 			return nil
 		}
 
-		return device.NetworkValidNetworkV4(value)
-	},
-	"fan.type": func(value string) error {
-		return shared.IsOneOf(value, []string{"vxlan", "ipip"})
+		return networkValidName(value)
 	},
+	"vlan": func(value string) error {
+		if value == "" {
+			return nil
+		}
 
-	"tunnel.TARGET.protocol": func(value string) error {
-		return shared.IsOneOf(value, []string{"gre", "vxlan"})
+		vlan, err := strconv.Atoi(value)
+		if err != nil || vlan < 1 || vlan > 4094 {
+			return fmt.Errorf("Invalid VLAN ID: %s", value)
+		}
+
+		return nil
 	},
-	"tunnel.TARGET.local":     device.NetworkValidAddress,
-	"tunnel.TARGET.remote":    device.NetworkValidAddress,
-	"tunnel.TARGET.port":      networkValidPort,
-	"tunnel.TARGET.group":     device.NetworkValidAddress,
-	"tunnel.TARGET.id":        shared.IsInt64,
-	"tunnel.TARGET.interface": networkValidName,
-	"tunnel.TARGET.ttl":       shared.IsUint8,
+	"mtu": shared.IsInt64,
 
 	"ipv4.address": func(value string) error {
-		if shared.IsOneOf(value, []string{"none", "auto"}) == nil {
+		if shared.IsOneOf(value, []string{"", "none"}) == nil {
 			return nil
 		}
 
 		return networkValidAddressCIDRV4(value)
 	},
-	"ipv4.firewall": shared.IsBool,
-	"ipv4.nat":      shared.IsBool,
-	"ipv4.nat.order": func(value string) error {
-		return shared.IsOneOf(value, []string{"before", "after"})
-	},
-	"ipv4.nat.address":  device.NetworkValidAddressV4,
-	"ipv4.dhcp":         shared.IsBool,
-	"ipv4.dhcp.gateway": device.NetworkValidAddressV4,
-	"ipv4.dhcp.expiry":  shared.IsAny,
-	"ipv4.dhcp.ranges":  shared.IsAny,
-	"ipv4.routes":       shared.IsAny,
-	"ipv4.routing":      shared.IsBool,
+	"ipv4.ranges": networkValidRanges,
 
 	"ipv6.address": func(value string) error {
-		if shared.IsOneOf(value, []string{"none", "auto"}) == nil {
+		if shared.IsOneOf(value, []string{"", "none"}) == nil {
 			return nil
 		}
 
 		return networkValidAddressCIDRV6(value)
 	},
-	"ipv6.firewall": shared.IsBool,
-	"ipv6.nat":      shared.IsBool,
-	"ipv6.nat.order": func(value string) error {
-		return shared.IsOneOf(value, []string{"before", "after"})
-	},
-	"ipv6.nat.address":   device.NetworkValidAddressV6,
-	"ipv6.dhcp":          shared.IsBool,
-	"ipv6.dhcp.expiry":   shared.IsAny,
-	"ipv6.dhcp.stateful": shared.IsBool,
-	"ipv6.dhcp.ranges":   shared.IsAny,
-	"ipv6.routes":        shared.IsAny,
-	"ipv6.routing":       shared.IsBool,
-
-	"dns.domain": shared.IsAny,
-	"dns.mode": func(value string) error {
-		return shared.IsOneOf(value, []string{"dynamic", "managed", "none"})
-	},
-
-	"raw.dnsmasq": shared.IsAny,
+	"ipv6.ranges": networkValidRanges,
 }
 
-func networkValidateConfig(name string, config map[string]string) error {
+func networkValidateConfig(name string, networkType string, config map[string]string) error {
+	if networkType == "external" {
+		return networkExternalValidateConfig(config)
+	}
+
 	bridgeMode := config["bridge.mode"]
 
 	if bridgeMode == "fan" && len(name) > 11 {
@@ -134,12 +427,12 @@ func networkValidateConfig(name string, config map[string]string) error {
 		}
 
 		// Then validate
-		validator, ok := networkConfigKeys[key]
+		configKey, ok := networkConfigKeys[key]
 		if !ok {
 			return fmt.Errorf("Invalid network configuration key: %s", k)
 		}
 
-		err := validator(v)
+		err := configKey.Validator(v)
 		if err != nil {
 			return err
 		}
@@ -191,6 +484,63 @@ func networkValidateConfig(name string, config map[string]string) error {
 	return nil
 }
 
+// networkExternalValidateConfig checks the configuration of an external network.
+func networkExternalValidateConfig(config map[string]string) error {
+	for k, v := range config {
+		// User keys are free for all
+		if strings.HasPrefix(k, "user.") {
+			continue
+		}
+
+		validator, ok := networkExternalConfigKeys[k]
+		if !ok {
+			return fmt.Errorf("Invalid network configuration key: %s", k)
+		}
+
+		err := configKey.Validator(v)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, family := range []string{"ipv4", "ipv6"} {
+		if config[family+".ranges"] == "" {
+			continue
+		}
+
+		_, subnet, err := net.ParseCIDR(config[family+".address"])
+		if err != nil {
+			return fmt.Errorf("The %s ranges require %s.address to be set", family, family)
+		}
+
+		for _, r := range strings.Split(config[family+".ranges"], ",") {
+			for _, address := range strings.SplitN(strings.TrimSpace(r), "-", 2) {
+				if !subnet.Contains(net.ParseIP(strings.TrimSpace(address))) {
+					return fmt.Errorf("Range %s isn't within %s", strings.TrimSpace(r), subnet)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// networkValidRanges checks a comma separated list of address ranges (first-last).
+func networkValidRanges(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	for _, r := range strings.Split(value, ",") {
+		fields := strings.SplitN(strings.TrimSpace(r), "-", 2)
+		if len(fields) != 2 || net.ParseIP(strings.TrimSpace(fields[0])) == nil || net.ParseIP(strings.TrimSpace(fields[1])) == nil {
+			return fmt.Errorf("Invalid address range: %s", r)
+		}
+	}
+
+	return nil
+}
+
 func networkFillAuto(config map[string]string) error {
 	if config["ipv4.address"] == "auto" {
 		subnet, err := networkRandomSubnetV4()
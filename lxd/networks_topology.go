@@ -0,0 +1,247 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+)
+
+var networkTopologyCmd = APIEndpoint{
+	Name: "networks/topology",
+
+	Get: APIEndpointAction{Handler: networkTopologyGet, AccessHandler: AllowAuthenticated},
+}
+
+// networkTopologyBuilder collects the elements of the topology, skipping the
+// ones which were already added.
+type networkTopologyBuilder struct {
+	nodes map[string]api.NetworkTopologyNode
+	edges map[[2]string]api.NetworkTopologyEdge
+}
+
+func (b *networkTopologyBuilder) addNode(node api.NetworkTopologyNode) {
+	_, ok := b.nodes[node.ID]
+	if ok {
+		return
+	}
+
+	if node.Config == nil {
+		node.Config = map[string]string{}
+	}
+
+	if node.Addresses == nil {
+		node.Addresses = []string{}
+	}
+
+	b.nodes[node.ID] = node
+}
+
+func (b *networkTopologyBuilder) addEdge(source string, target string, edgeType string) {
+	b.edges[[2]string{source, target}] = api.NetworkTopologyEdge{Source: source, Target: target, Type: edgeType}
+}
+
+// topology returns the collected elements, sorted by ID.
+func (b *networkTopologyBuilder) topology() api.NetworkTopology {
+	topology := api.NetworkTopology{
+		Nodes: []api.NetworkTopologyNode{},
+		Edges: []api.NetworkTopologyEdge{},
+	}
+
+	for _, node := range b.nodes {
+		topology.Nodes = append(topology.Nodes, node)
+	}
+
+	for _, edge := range b.edges {
+		topology.Edges = append(topology.Edges, edge)
+	}
+
+	sort.Slice(topology.Nodes, func(i, j int) bool { return topology.Nodes[i].ID < topology.Nodes[j].ID })
+	sort.Slice(topology.Edges, func(i, j int) bool {
+		if topology.Edges[i].Source != topology.Edges[j].Source {
+			return topology.Edges[i].Source < topology.Edges[j].Source
+		}
+
+		return topology.Edges[i].Target < topology.Edges[j].Target
+	})
+
+	return topology
+}
+
+func networkTopologyGet(d *Daemon, r *http.Request) Response {
+	var containers []db.Instance
+	var members []db.NodeInfo
+	allocations := map[string][]string{}
+
+	names, err := d.cluster.Networks()
+	if err != nil {
+		return SmartError(err)
+	}
+
+	networks := map[string]*api.Network{}
+	for _, name := range names {
+		_, network, err := d.cluster.NetworkGet(name)
+		if err != nil {
+			return SmartError(err)
+		}
+
+		networks[name] = network
+	}
+
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		containers, err = tx.ContainerListExpanded()
+		if err != nil {
+			return err
+		}
+
+		members, err = tx.Nodes()
+		if err != nil {
+			return err
+		}
+
+		// The addresses handed out by external networks
+		for name, network := range networks {
+			if network.Type != db.NetworkTypeNames[db.NetworkTypeExternal] {
+				continue
+			}
+
+			networkID, err := tx.NetworkID(name)
+			if err != nil {
+				return err
+			}
+
+			entries, err := tx.NetworkAllocations(networkID)
+			if err != nil {
+				return err
+			}
+
+			for _, entry := range entries {
+				key := fmt.Sprintf("%s/%s/%s", entry.Project, entry.Instance, entry.Device)
+				allocations[key] = append(allocations[key], entry.Address)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return SmartError(err)
+	}
+
+	// Only show the containers in projects the user can see
+	visible := []db.Instance{}
+	for _, c := range containers {
+		if c.Type != int(db.CTypeRegular) {
+			continue
+		}
+
+		if !d.userHasPermission(r, c.Project, "view") {
+			continue
+		}
+
+		visible = append(visible, c)
+	}
+
+	leases, err := searchLeases(d, visible)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	b := &networkTopologyBuilder{
+		nodes: map[string]api.NetworkTopologyNode{},
+		edges: map[[2]string]api.NetworkTopologyEdge{},
+	}
+
+	for _, member := range members {
+		b.addNode(api.NetworkTopologyNode{
+			ID:   fmt.Sprintf("member/%s", member.Name),
+			Type: "member",
+			Name: member.Name,
+		})
+	}
+
+	for name, network := range networks {
+		id := fmt.Sprintf("network/%s", name)
+		b.addNode(api.NetworkTopologyNode{
+			ID:     id,
+			Type:   "network",
+			Name:   name,
+			Config: network.Config,
+		})
+
+		for _, location := range network.Locations {
+			b.addEdge(id, fmt.Sprintf("member/%s", location), "available-on")
+		}
+	}
+
+	for _, c := range visible {
+		containerID := fmt.Sprintf("container/%s/%s", c.Project, c.Name)
+		b.addNode(api.NetworkTopologyNode{
+			ID:       containerID,
+			Type:     "container",
+			Name:     c.Name,
+			Project:  c.Project,
+			Location: c.Node,
+		})
+		b.addEdge(containerID, fmt.Sprintf("member/%s", c.Node), "runs-on")
+
+		for _, iface := range searchInterfaces(c, leases) {
+			m := c.Devices[iface.Name]
+
+			config := map[string]string{}
+			for k, v := range m {
+				config[k] = v
+			}
+			config["hwaddr"] = iface.Hwaddr
+
+			addresses := iface.Addresses
+			for _, address := range allocations[fmt.Sprintf("%s/%s/%s", c.Project, c.Name, iface.Name)] {
+				if !shared.StringInSlice(address, addresses) {
+					addresses = append(addresses, address)
+				}
+			}
+
+			nicID := fmt.Sprintf("nic/%s/%s/%s", c.Project, c.Name, iface.Name)
+			b.addNode(api.NetworkTopologyNode{
+				ID:        nicID,
+				Type:      "nic",
+				Name:      iface.Name,
+				Project:   c.Project,
+				Location:  c.Node,
+				Config:    config,
+				Addresses: addresses,
+			})
+			b.addEdge(containerID, nicID, "has")
+
+			// NICs are attached to managed networks or to host interfaces
+			network := m["network"]
+			if network == "" && networks[m["parent"]] != nil {
+				network = m["parent"]
+			}
+
+			if network != "" {
+				b.addEdge(nicID, fmt.Sprintf("network/%s", network), "connects-to")
+				continue
+			}
+
+			if m["parent"] == "" {
+				continue
+			}
+
+			interfaceID := fmt.Sprintf("interface/%s/%s", c.Node, m["parent"])
+			b.addNode(api.NetworkTopologyNode{
+				ID:       interfaceID,
+				Type:     "interface",
+				Name:     m["parent"],
+				Location: c.Node,
+			})
+			b.addEdge(nicID, interfaceID, "connects-to")
+			b.addEdge(interfaceID, fmt.Sprintf("member/%s", c.Node), "available-on")
+		}
+	}
+
+	return SyncResponse(true, b.topology())
+}
@@ -99,6 +99,14 @@ func networkIsInUse(c container, name string) bool {
 			continue
 		}
 
+		if d["network"] != "" {
+			if d["network"] == name {
+				return true
+			}
+
+			continue
+		}
+
 		if d["parent"] == "" {
 			continue
 		}
@@ -111,6 +119,17 @@ func networkIsInUse(c container, name string) bool {
 	return false
 }
 
+// networkTypeCode returns the database code of a network type name.
+func networkTypeCode(name string) int {
+	for code, typeName := range db.NetworkTypeNames {
+		if typeName == name {
+			return code
+		}
+	}
+
+	return db.NetworkTypeBridge
+}
+
 func networkGetIP(subnet *net.IPNet, host int64) net.IP {
 	// Convert IP to a big int
 	bigIP := big.NewInt(0)
@@ -680,7 +699,7 @@ func networkUpdateStatic(s *state.State, networkName string) error {
 				}
 			}
 
-			entries[d["parent"]] = append(entries[d["parent"]], []string{d["hwaddr"], c.Project(), c.Name(), d["ipv4.address"], d["ipv6.address"]})
+			entries[d["parent"]] = append(entries[d["parent"]], []string{d["hwaddr"], c.Project(), c.Name(), d["ipv4.address"], d["ipv6.address"], d["dns.name"], d["dhcp.client-id"]})
 		}
 	}
 
@@ -755,7 +774,12 @@ func networkUpdateStatic(s *state.State, networkName string) error {
 			}
 
 			// Generate the dhcp-host line
-			err := dnsmasq.UpdateStaticEntry(network, projectName, cName, config, hwaddr, ipv4Address, ipv6Address)
+			deviceConfig := map[string]string{
+				"dns.name":       entry[5],
+				"dhcp.client-id": entry[6],
+			}
+
+			err := dnsmasq.UpdateStaticEntry(network, projectName, cName, config, deviceConfig, hwaddr, ipv4Address, ipv6Address)
 			if err != nil {
 				return err
 			}
@@ -1014,3 +1038,28 @@ func networkApplyBootRoutesV6(devName string, routes []string) error {
 
 	return nil
 }
+
+// networkConntrackZone returns the connection tracking zone of the index-th
+// NIC of a container. Zone 0 is the default one and zones are 16bit, so they
+// are shared again past 4096 containers.
+func networkConntrackZone(id int, index int) int {
+	return (id*16+index%16)%65535 + 1
+}
+
+// networkConntrackCount returns the number of connection tracking entries in
+// a zone.
+func networkConntrackCount(zone int) (int64, error) {
+	output, err := shared.RunCommand("conntrack", "-L", "--zone", strconv.Itoa(zone))
+	if err != nil {
+		return -1, err
+	}
+
+	count := int64(0)
+	for _, line := range strings.Split(output, "\n") {
+		if strings.TrimSpace(line) != "" {
+			count++
+		}
+	}
+
+	return count, nil
+}
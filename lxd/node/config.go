@@ -2,6 +2,8 @@ package node
 
 import (
 	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/lxc/lxd/lxd/config"
 	"github.com/lxc/lxd/lxd/db"
@@ -64,6 +66,23 @@ func (c *Config) StorageImagesVolume() string {
 	return c.m.GetString("storage.images_volume")
 }
 
+// EmergencyShutdownTrigger returns the path of the file which triggers an
+// emergency shutdown of all containers when created.
+func (c *Config) EmergencyShutdownTrigger() string {
+	return c.m.GetString("core.emergency_shutdown_trigger")
+}
+
+// OperationTimeout returns how long a start, stop or restore of a container
+// may go without progress before being failed.
+func (c *Config) OperationTimeout(action string) time.Duration {
+	return time.Duration(c.m.GetInt64(fmt.Sprintf("core.operation_timeout.%s", action))) * time.Second
+}
+
+// ProtectionStart returns whether this node refuses to start containers.
+func (c *Config) ProtectionStart() bool {
+	return c.m.GetBool("security.protection.start")
+}
+
 // Dump current configuration keys and their values. Keys with values matching
 // their defaults are omitted.
 func (c *Config) Dump() map[string]interface{} {
@@ -158,6 +177,17 @@ var ConfigSchema = config.Schema{
 	// Network address for the debug server
 	"core.debug_address": {},
 
+	// File triggering an emergency shutdown of all containers
+	"core.emergency_shutdown_trigger": {},
+
+	// Seconds a container operation may go without progress
+	"core.operation_timeout.restore": {Type: config.Int64, Default: "30", Validator: operationTimeoutValidator},
+	"core.operation_timeout.start":   {Type: config.Int64, Default: "30", Validator: operationTimeoutValidator},
+	"core.operation_timeout.stop":    {Type: config.Int64, Default: "30", Validator: operationTimeoutValidator},
+
+	// Whether to refuse to start containers, e.g. during maintenance
+	"security.protection.start": {Type: config.Bool},
+
 	// MAAS machine this LXD instance is associated with
 	"maas.machine": {},
 
@@ -165,3 +195,16 @@ var ConfigSchema = config.Schema{
 	"storage.backups_volume": {},
 	"storage.images_volume":  {},
 }
+
+func operationTimeoutValidator(value string) error {
+	timeout, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("Operation timeout is not a number")
+	}
+
+	if timeout < 1 {
+		return fmt.Errorf("Operation timeout must be at least 1 second")
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package node_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/lxc/lxd/lxd/db"
 	"github.com/lxc/lxd/lxd/node"
@@ -142,3 +143,21 @@ func TestClusterAddress(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, "127.0.0.1:666", address)
 }
+
+// The container operation timeouts default to 30 seconds and must be positive.
+func TestOperationTimeout(t *testing.T) {
+	tx, cleanup := db.NewTestNodeTx(t)
+	defer cleanup()
+
+	config, err := node.ConfigLoad(tx)
+	require.NoError(t, err)
+	assert.Equal(t, 30*time.Second, config.OperationTimeout("start"))
+
+	_, err = config.Patch(map[string]interface{}{"core.operation_timeout.stop": "120"})
+	require.NoError(t, err)
+	assert.Equal(t, 120*time.Second, config.OperationTimeout("stop"))
+	assert.Equal(t, 30*time.Second, config.OperationTimeout("restore"))
+
+	_, err = config.Patch(map[string]interface{}{"core.operation_timeout.start": "0"})
+	assert.Error(t, err)
+}
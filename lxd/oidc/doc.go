@@ -0,0 +1,4 @@
+// Package oidc implements the verification of OpenID Connect bearer tokens
+// against the signing keys published by their issuer, and the mapping of the
+// groups of their holder to LXD permissions.
+package oidc
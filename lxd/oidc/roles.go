@@ -0,0 +1,92 @@
+package oidc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The permissions granted by each role on a project.
+var rolePermissions = map[string][]string{
+	"view":     {"view"},
+	"operator": {"view", "operate-containers"},
+	"manager":  {"view", "operate-containers", "manage-containers", "manage-images", "manage-profiles", "manage-storage-volumes"},
+}
+
+// Roles maps the groups of the token holders to LXD permissions.
+type Roles struct {
+	admins   []string
+	projects map[string]map[string][]string // Maps group to project to permissions
+}
+
+// ParseRoles returns the Roles described by a comma separated list of admin
+// groups and a comma separated list of "<group>:<project>:<role>" entries,
+// the role being "view", "operator" or "manager".
+func ParseRoles(admins string, projects string) (*Roles, error) {
+	r := &Roles{
+		admins:   []string{},
+		projects: map[string]map[string][]string{},
+	}
+
+	for _, group := range strings.Split(admins, ",") {
+		group = strings.TrimSpace(group)
+		if group != "" {
+			r.admins = append(r.admins, group)
+		}
+	}
+
+	for _, entry := range strings.Split(projects, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		fields := strings.Split(entry, ":")
+		if len(fields) != 3 || fields[0] == "" || fields[1] == "" {
+			return nil, fmt.Errorf("Invalid group mapping %q, must be <group>:<project>:<role>", entry)
+		}
+
+		permissions, ok := rolePermissions[fields[2]]
+		if !ok {
+			return nil, fmt.Errorf("Invalid role %q, must be view, operator or manager", fields[2])
+		}
+
+		if r.projects[fields[0]] == nil {
+			r.projects[fields[0]] = map[string][]string{}
+		}
+
+		r.projects[fields[0]][fields[1]] = append(r.projects[fields[0]][fields[1]], permissions...)
+	}
+
+	return r, nil
+}
+
+// IsAdmin returns whether one of the groups has full access.
+func (r *Roles) IsAdmin(groups []string) bool {
+	for _, group := range groups {
+		for _, admin := range r.admins {
+			if group == admin {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// HasPermission returns whether one of the groups has the given permission on
+// the project.
+func (r *Roles) HasPermission(groups []string, project string, permission string) bool {
+	if r.IsAdmin(groups) {
+		return true
+	}
+
+	for _, group := range groups {
+		for _, p := range r.projects[group][project] {
+			if p == permission {
+				return true
+			}
+		}
+	}
+
+	return false
+}
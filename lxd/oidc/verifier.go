@@ -0,0 +1,431 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256" // Register the SHA-256 hash used by RS256 and ES256
+	_ "crypto/sha512" // Register the SHA-384 and SHA-512 hashes
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Leeway allowed on the expiry and start dates of the tokens, to cope with
+// clock differences between LXD and the issuer.
+const leeway = time.Minute
+
+// Minimum delay between two fetches of the signing keys, successful or not,
+// so that tokens signed with unknown keys can't be used to hammer the issuer.
+const keysRefreshInterval = 5 * time.Minute
+
+// Identity is the holder of a verified token.
+type Identity struct {
+	Username string
+	Groups   []string
+	Claims   map[string]interface{}
+}
+
+// Verifier checks bearer tokens issued by an OpenID Connect provider.
+type Verifier struct {
+	issuer        string
+	audience      string
+	usernameClaim string
+	groupsClaim   string
+	client        *http.Client
+
+	keys        map[string]crypto.PublicKey
+	keysFetched time.Time
+	keysLock    sync.Mutex
+
+	// Serializes the fetches of the keys, which are done without holding
+	// keysLock so that the tokens signed with known keys can still be
+	// verified meanwhile.
+	fetchLock sync.Mutex
+}
+
+// NewVerifier returns a Verifier accepting the tokens of the given issuer
+// which are meant for the given audience. The username and groups of their
+// holder are taken from the given claims.
+func NewVerifier(issuer string, audience string, usernameClaim string, groupsClaim string, client *http.Client) *Verifier {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	return &Verifier{
+		issuer:        strings.TrimSuffix(issuer, "/"),
+		audience:      audience,
+		usernameClaim: usernameClaim,
+		groupsClaim:   groupsClaim,
+		client:        client,
+		keys:          map[string]crypto.PublicKey{},
+	}
+}
+
+// Verify checks the signature, issuer, audience and validity dates of a token
+// and returns the identity of its holder.
+func (v *Verifier) Verify(token string) (*Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("Malformed token")
+	}
+
+	header := struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}{}
+
+	err := decodeSegment(parts[0], &header)
+	if err != nil {
+		return nil, fmt.Errorf("Malformed token header: %v", err)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("Malformed token signature: %v", err)
+	}
+
+	claims := map[string]interface{}{}
+	err = decodeSegment(parts[1], &claims)
+	if err != nil {
+		return nil, fmt.Errorf("Malformed token claims: %v", err)
+	}
+
+	// Don't go looking for the keys of tokens from other issuers
+	err = v.checkIssuer(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := v.key(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	err = verifySignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), signature)
+	if err != nil {
+		return nil, err
+	}
+
+	err = v.checkClaims(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	username, _ := claims[v.usernameClaim].(string)
+	if username == "" {
+		return nil, fmt.Errorf("Token has no %q claim", v.usernameClaim)
+	}
+
+	return &Identity{
+		Username: username,
+		Groups:   ClaimStrings(claims, v.groupsClaim),
+		Claims:   claims,
+	}, nil
+}
+
+// checkIssuer checks that a token was issued by the issuer of the verifier.
+func (v *Verifier) checkIssuer(claims map[string]interface{}) error {
+	issuer, _ := claims["iss"].(string)
+	if issuer == "" {
+		return fmt.Errorf("Token has no issuer")
+	}
+
+	if strings.TrimSuffix(issuer, "/") != v.issuer {
+		return fmt.Errorf("Token issued by %q", issuer)
+	}
+
+	return nil
+}
+
+// checkClaims checks the registered claims of a token.
+func (v *Verifier) checkClaims(claims map[string]interface{}) error {
+	err := v.checkIssuer(claims)
+	if err != nil {
+		return err
+	}
+
+	audiences := ClaimStrings(claims, "aud")
+	found := false
+	for _, audience := range audiences {
+		if audience == v.audience {
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("Token not meant for %q", v.audience)
+	}
+
+	now := time.Now()
+
+	expiry, ok := claims["exp"].(float64)
+	if !ok {
+		return fmt.Errorf("Token has no expiry date")
+	}
+
+	if now.After(time.Unix(int64(expiry), 0).Add(leeway)) {
+		return fmt.Errorf("Token expired")
+	}
+
+	notBefore, ok := claims["nbf"].(float64)
+	if ok && now.Add(leeway).Before(time.Unix(int64(notBefore), 0)) {
+		return fmt.Errorf("Token not valid yet")
+	}
+
+	return nil
+}
+
+// key returns the signing key with the given ID, fetching the keys of the
+// issuer again if it's unknown.
+func (v *Verifier) key(id string) (crypto.PublicKey, error) {
+	key, refresh := v.cachedKey(id)
+	if key != nil {
+		return key, nil
+	}
+
+	if !refresh {
+		return nil, fmt.Errorf("Unknown signing key %q", id)
+	}
+
+	v.fetchLock.Lock()
+	defer v.fetchLock.Unlock()
+
+	// The keys may have been fetched while waiting
+	key, refresh = v.cachedKey(id)
+	if key != nil {
+		return key, nil
+	}
+
+	if !refresh {
+		return nil, fmt.Errorf("Unknown signing key %q", id)
+	}
+
+	// Failed fetches are rate limited too
+	v.keysLock.Lock()
+	v.keysFetched = time.Now()
+	v.keysLock.Unlock()
+
+	keys, err := v.fetchKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	v.keysLock.Lock()
+	v.keys = keys
+	v.keysLock.Unlock()
+
+	key, ok := keys[id]
+	if !ok {
+		return nil, fmt.Errorf("Unknown signing key %q", id)
+	}
+
+	return key, nil
+}
+
+// cachedKey returns the known signing key with the given ID, if any, and
+// whether the keys of the issuer may be fetched again.
+func (v *Verifier) cachedKey(id string) (crypto.PublicKey, bool) {
+	v.keysLock.Lock()
+	defer v.keysLock.Unlock()
+
+	return v.keys[id], time.Since(v.keysFetched) >= keysRefreshInterval
+}
+
+// fetchKeys returns the signing keys published by the issuer, indexed by ID.
+func (v *Verifier) fetchKeys() (map[string]crypto.PublicKey, error) {
+	discovery := struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}{}
+
+	err := v.getJSON(v.issuer+"/.well-known/openid-configuration", &discovery)
+	if err != nil {
+		return nil, err
+	}
+
+	// The provider must be the issuer it's configured as
+	if strings.TrimSuffix(discovery.Issuer, "/") != v.issuer {
+		return nil, fmt.Errorf("The OpenID Connect provider identifies as %q", discovery.Issuer)
+	}
+
+	if discovery.JWKSURI == "" {
+		return nil, fmt.Errorf("The OpenID Connect provider doesn't publish its keys")
+	}
+
+	set := struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}{}
+
+	err = v.getJSON(discovery.JWKSURI, &set)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := map[string]crypto.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		switch k.Kty {
+		case "RSA":
+			n, err := decodeInt(k.N)
+			if err != nil {
+				return nil, err
+			}
+
+			e, err := decodeInt(k.E)
+			if err != nil {
+				return nil, err
+			}
+
+			keys[k.Kid] = &rsa.PublicKey{N: n, E: int(e.Int64())}
+		case "EC":
+			var curve elliptic.Curve
+			switch k.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			case "P-521":
+				curve = elliptic.P521()
+			default:
+				continue
+			}
+
+			x, err := decodeInt(k.X)
+			if err != nil {
+				return nil, err
+			}
+
+			y, err := decodeInt(k.Y)
+			if err != nil {
+				return nil, err
+			}
+
+			keys[k.Kid] = &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+		}
+	}
+
+	return keys, nil
+}
+
+func (v *Verifier) getJSON(url string, target interface{}) error {
+	resp, err := v.client.Get(url)
+	if err != nil {
+		return fmt.Errorf("Failed to reach the OpenID Connect provider: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Failed to fetch %q: %s", url, resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(target)
+}
+
+// verifySignature checks the signature of a token with the given algorithm.
+func verifySignature(alg string, key crypto.PublicKey, signed []byte, signature []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512", "ES512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("Unsupported token signing algorithm %q", alg)
+	}
+
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") {
+			return fmt.Errorf("Signing algorithm %q doesn't match the key", alg)
+		}
+
+		err := rsa.VerifyPKCS1v15(k, hash, digest, signature)
+		if err != nil {
+			return fmt.Errorf("Invalid token signature")
+		}
+	case *ecdsa.PublicKey:
+		if !strings.HasPrefix(alg, "ES") {
+			return fmt.Errorf("Signing algorithm %q doesn't match the key", alg)
+		}
+
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return fmt.Errorf("Invalid token signature")
+		}
+
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return fmt.Errorf("Invalid token signature")
+		}
+	default:
+		return fmt.Errorf("Unsupported signing key")
+	}
+
+	return nil
+}
+
+// ClaimStrings returns the values of a claim which can either be a string or
+// a list of strings.
+func ClaimStrings(claims map[string]interface{}, name string) []string {
+	switch value := claims[name].(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		values := []string{}
+		for _, v := range value {
+			s, ok := v.(string)
+			if ok {
+				values = append(values, s)
+			}
+		}
+
+		return values
+	}
+
+	return []string{}
+}
+
+func decodeSegment(segment string, target interface{}) error {
+	content, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(content, target)
+}
+
+func decodeInt(value string) (*big.Int, error) {
+	content, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("Malformed signing key: %v", err)
+	}
+
+	return new(big.Int).SetBytes(content), nil
+}
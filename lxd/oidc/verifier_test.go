@@ -0,0 +1,154 @@
+package oidc_test
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/lxd/oidc"
+)
+
+func TestVerifier(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(map[string]string{"issuer": server.URL, "jwks_uri": server.URL + "/keys"})
+		case "/keys":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"keys": []map[string]string{{
+					"kty": "RSA",
+					"kid": "k1",
+					"use": "sig",
+					"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+					"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+				}},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	sign := func(kid string, claims map[string]interface{}) string {
+		header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": kid})
+		payload, _ := json.Marshal(claims)
+		signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+		digest := sha256.Sum256([]byte(signed))
+		signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+
+		return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+	}
+
+	claims := func() map[string]interface{} {
+		return map[string]interface{}{
+			"iss":    server.URL,
+			"aud":    []string{"lxd"},
+			"exp":    time.Now().Add(time.Hour).Unix(),
+			"email":  "jdoe@example.com",
+			"groups": []string{"dev"},
+		}
+	}
+
+	verifier := oidc.NewVerifier(server.URL, "lxd", "email", "groups", nil)
+
+	identity, err := verifier.Verify(sign("k1", claims()))
+	require.NoError(t, err)
+	assert.Equal(t, "jdoe@example.com", identity.Username)
+	assert.Equal(t, []string{"dev"}, identity.Groups)
+
+	expired := claims()
+	expired["exp"] = time.Now().Add(-time.Hour).Unix()
+	_, err = verifier.Verify(sign("k1", expired))
+	assert.EqualError(t, err, "Token expired")
+
+	audience := claims()
+	audience["aud"] = "other"
+	_, err = verifier.Verify(sign("k1", audience))
+	assert.EqualError(t, err, `Token not meant for "lxd"`)
+
+	_, err = verifier.Verify(sign("k2", claims()))
+	assert.EqualError(t, err, `Unknown signing key "k2"`)
+
+	// Claims changed after signing
+	parts := strings.Split(sign("k1", claims()), ".")
+	forged := claims()
+	forged["email"] = "root@example.com"
+	payload, _ := json.Marshal(forged)
+	parts[1] = base64.RawURLEncoding.EncodeToString(payload)
+	_, err = verifier.Verify(strings.Join(parts, "."))
+	assert.EqualError(t, err, "Invalid token signature")
+
+	// Tokens from other issuers don't cause the keys to be fetched
+	issuer := claims()
+	issuer["iss"] = "https://other.example.com"
+	_, err = verifier.Verify(sign("k1", issuer))
+	assert.EqualError(t, err, `Token issued by "https://other.example.com"`)
+}
+
+func TestVerifier_FetchFailure(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	var fetches int32
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+
+		// The provider claims to be another issuer
+		json.NewEncoder(w).Encode(map[string]string{"issuer": "https://other.example.com", "jwks_uri": server.URL + "/keys"})
+	}))
+	defer server.Close()
+
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "k1"})
+	payload, _ := json.Marshal(map[string]interface{}{"iss": server.URL, "aud": "lxd", "exp": time.Now().Add(time.Hour).Unix()})
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	require.NoError(t, err)
+	token := signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+
+	verifier := oidc.NewVerifier(server.URL, "lxd", "email", "groups", nil)
+
+	_, err = verifier.Verify(token)
+	assert.EqualError(t, err, `The OpenID Connect provider identifies as "https://other.example.com"`)
+
+	// The failed fetch isn't retried right away
+	_, err = verifier.Verify(token)
+	assert.EqualError(t, err, `Unknown signing key "k1"`)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&fetches))
+}
+
+func TestRoles(t *testing.T) {
+	_, err := oidc.ParseRoles("", "dev:default:owner")
+	assert.Error(t, err)
+
+	roles, err := oidc.ParseRoles("lxd-admins", "dev:default:operator, qa:default:view")
+	require.NoError(t, err)
+
+	assert.True(t, roles.IsAdmin([]string{"dev", "lxd-admins"}))
+	assert.False(t, roles.IsAdmin([]string{"dev"}))
+	assert.True(t, roles.HasPermission([]string{"dev"}, "default", "operate-containers"))
+	assert.False(t, roles.HasPermission([]string{"dev"}, "default", "manage-containers"))
+	assert.False(t, roles.HasPermission([]string{"dev"}, "other", "view"))
+	assert.True(t, roles.HasPermission([]string{"qa"}, "default", "view"))
+	assert.True(t, roles.HasPermission([]string{"lxd-admins"}, "other", "manage-images"))
+}
@@ -87,6 +87,33 @@ func profilesPost(d *Daemon, r *http.Request) Response {
 		return BadRequest(err)
 	}
 
+	response := profilesPostValidate(d, r, project, req)
+	if response != nil {
+		return response
+	}
+
+	// Update DB entry
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		project, err = profileCreate(tx, project, req)
+		return err
+	})
+	if err != nil {
+		return SmartError(
+			fmt.Errorf("Error inserting %s into database: %s", req.Name, err))
+	}
+
+	return SyncResponseLocation(true, nil, fmt.Sprintf("/%s/profiles/%s", version.APIVersion, req.Name))
+}
+
+// profilesPostValidate checks that a user may create the given profile in a
+// project, returning the error response if not.
+func profilesPostValidate(d *Daemon, r *http.Request, project string, req api.ProfilesPost) Response {
+	err := containerConfigCheckAdmin(d, r, nil, req.Config)
+	if err != nil {
+		return Forbidden(err)
+	}
+
 	// Sanity checks
 	if req.Name == "" {
 		return BadRequest(fmt.Errorf("No name provided"))
@@ -100,48 +127,66 @@ func profilesPost(d *Daemon, r *http.Request) Response {
 		return BadRequest(fmt.Errorf("Invalid profile name '%s'", req.Name))
 	}
 
-	err := containerValidConfig(d.os, req.Config, true, false)
+	restrictions, err := projectRestrictions(d.cluster, project)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	err = containerValidConfig(d.os, req.Config, true, false, restrictions)
 	if err != nil {
 		return BadRequest(err)
 	}
 
-	err = containerValidDevices(d.State(), d.cluster, req.Devices, true, false)
+	err = containerValidDevices(d.State(), d.cluster, req.Devices, true, false, restrictions)
 	if err != nil {
 		return BadRequest(err)
 	}
 
-	// Update DB entry
-	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
-		hasProfiles, err := tx.ProjectHasProfiles(project)
-		if err != nil {
-			return errors.Wrap(err, "Check project features")
-		}
+	projConfig, err := projectConfig(d.cluster, project)
+	if err != nil {
+		return SmartError(err)
+	}
 
-		if !hasProfiles {
-			project = "default"
-		}
+	err = projectDefaultsCheckConfig(projConfig, req.Config)
+	if err != nil {
+		return BadRequest(err)
+	}
 
-		current, _ := tx.ProfileGet(project, req.Name)
-		if current != nil {
-			return fmt.Errorf("The profile already exists")
-		}
+	return nil
+}
 
-		profile := db.Profile{
-			Project:     project,
-			Name:        req.Name,
-			Description: req.Description,
-			Config:      req.Config,
-			Devices:     req.Devices,
-		}
-		_, err = tx.ProfileCreate(profile)
-		return err
-	})
+// profileCreate inserts a validated profile in a project, or in the default
+// project if the project doesn't have its own profiles. It returns the project
+// the profile was created in.
+func profileCreate(tx *db.ClusterTx, project string, req api.ProfilesPost) (string, error) {
+	hasProfiles, err := tx.ProjectHasProfiles(project)
 	if err != nil {
-		return SmartError(
-			fmt.Errorf("Error inserting %s into database: %s", req.Name, err))
+		return "", errors.Wrap(err, "Check project features")
 	}
 
-	return SyncResponseLocation(true, nil, fmt.Sprintf("/%s/profiles/%s", version.APIVersion, req.Name))
+	if !hasProfiles {
+		project = "default"
+	}
+
+	current, _ := tx.ProfileGet(project, req.Name)
+	if current != nil {
+		return "", fmt.Errorf("The profile already exists")
+	}
+
+	profile := db.Profile{
+		Project:     project,
+		Name:        req.Name,
+		Description: req.Description,
+		Config:      req.Config,
+		Devices:     req.Devices,
+	}
+
+	_, err = tx.ProfileCreate(profile)
+	if err != nil {
+		return "", err
+	}
+
+	return project, nil
 }
 
 func profileGet(d *Daemon, r *http.Request) Response {
@@ -246,6 +291,11 @@ func profilePut(d *Daemon, r *http.Request) Response {
 		return BadRequest(err)
 	}
 
+	err = containerConfigCheckAdmin(d, r, profile.Config, req.Config)
+	if err != nil {
+		return Forbidden(err)
+	}
+
 	err = doProfileUpdate(d, project, name, id, profile, req)
 
 	if err == nil && !isClusterNotification(r) {
@@ -343,6 +393,11 @@ func profilePatch(d *Daemon, r *http.Request) Response {
 		}
 	}
 
+	err = containerConfigCheckAdmin(d, r, profile.Config, req.Config)
+	if err != nil {
+		return Forbidden(err)
+	}
+
 	// Get Devices
 	if req.Devices == nil {
 		req.Devices = profile.Devices
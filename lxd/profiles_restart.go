@@ -0,0 +1,270 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+
+	lxd "github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/shared/api"
+)
+
+var profileInstancesRestartCmd = APIEndpoint{
+	Name: "profiles/{name}/instances/restart",
+
+	Post: APIEndpointAction{Handler: profileInstancesRestartPost, AccessHandler: AllowProjectPermission("containers", "operate-containers")},
+}
+
+func profileInstancesRestartPost(d *Daemon, r *http.Request) Response {
+	project := projectParam(r)
+	name := mux.Vars(r)["name"]
+
+	req := api.ProfileInstancesRestartPost{}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		return BadRequest(err)
+	}
+
+	if req.Concurrency < 0 || req.Delay < 0 || req.Timeout < 0 || req.HealthCheckTimeout < 0 {
+		return BadRequest(fmt.Errorf("Concurrency, delay and timeouts can't be negative"))
+	}
+
+	if req.Concurrency == 0 {
+		req.Concurrency = 1
+	}
+
+	if req.HealthCheckTimeout == 0 {
+		req.HealthCheckTimeout = 60
+	}
+
+	profileProject := project
+	err = d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		hasProfiles, err := tx.ProjectHasProfiles(project)
+		if err != nil {
+			return errors.Wrap(err, "Check project features")
+		}
+
+		if !hasProfiles {
+			profileProject = "default"
+		}
+
+		_, err = tx.ProfileGet(profileProject, name)
+		return err
+	})
+	if err != nil {
+		return SmartError(err)
+	}
+
+	// Only consider the containers of the current project
+	names, err := d.cluster.ProfileContainersGet(profileProject, name)
+	if err != nil {
+		return SmartError(err)
+	}
+
+	containers := names[project]
+	sort.Strings(containers)
+
+	run := func(op *operation) error {
+		return profileInstancesRestart(d, op, project, containers, req)
+	}
+
+	resources := map[string][]string{}
+	resources["profiles"] = []string{name}
+	resources["containers"] = containers
+
+	op, err := operationCreate(d.cluster, project, operationClassTask, db.OperationProfileContainersRestart, resources, nil, run, nil, nil)
+	if err != nil {
+		return InternalError(err)
+	}
+
+	return OperationResponse(op)
+}
+
+// profileInstancesRestart restarts the running containers in batches,
+// waiting for each batch to pass the health check before moving on to the
+// next one. It stops at the first failure.
+func profileInstancesRestart(d *Daemon, op *operation, project string, names []string, req api.ProfileInstancesRestartPost) error {
+	running := []string{}
+	for _, name := range names {
+		isRunning, err := profileRestartIsRunning(d, project, name)
+		if err != nil {
+			return err
+		}
+
+		if isRunning {
+			running = append(running, name)
+		}
+	}
+
+	restarted := []string{}
+	for start := 0; start < len(running); start += req.Concurrency {
+		if start > 0 && req.Delay > 0 {
+			time.Sleep(time.Duration(req.Delay) * time.Second)
+		}
+
+		end := start + req.Concurrency
+		if end > len(running) {
+			end = len(running)
+		}
+
+		batch := running[start:end]
+		errs := make([]error, len(batch))
+
+		wg := sync.WaitGroup{}
+		for i, name := range batch {
+			wg.Add(1)
+			go func(i int, name string) {
+				defer wg.Done()
+				errs[i] = profileRestartContainer(d, project, name, req)
+			}(i, name)
+		}
+		wg.Wait()
+
+		for i, err := range errs {
+			if err != nil {
+				return errors.Wrapf(err, "Failed to restart container '%s' (%d containers left untouched)", batch[i], len(running)-end)
+			}
+		}
+
+		restarted = append(restarted, batch...)
+		op.UpdateMetadata(map[string]interface{}{
+			"restarted": restarted,
+			"total":     len(running),
+		})
+	}
+
+	return nil
+}
+
+func profileRestartIsRunning(d *Daemon, project string, name string) (bool, error) {
+	client, err := cluster.ConnectIfContainerIsRemote(d.cluster, project, name, d.endpoints.NetworkCert())
+	if err != nil {
+		return false, err
+	}
+
+	if client != nil {
+		state, _, err := client.UseProject(project).GetContainerState(name)
+		if err != nil {
+			return false, err
+		}
+
+		return state.StatusCode == api.Running, nil
+	}
+
+	c, err := containerLoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return false, err
+	}
+
+	return c.IsRunning(), nil
+}
+
+// profileRestartContainer restarts a single container, through the node it's
+// located on if remote, then runs the health check.
+func profileRestartContainer(d *Daemon, project string, name string, req api.ProfileInstancesRestartPost) error {
+	timeout := time.Duration(req.Timeout) * time.Second
+	if req.Force {
+		timeout = 0
+	}
+
+	client, err := cluster.ConnectIfContainerIsRemote(d.cluster, project, name, d.endpoints.NetworkCert())
+	if err != nil {
+		return err
+	}
+
+	if client != nil {
+		return profileRestartRemoteContainer(client.UseProject(project), name, req)
+	}
+
+	c, err := containerLoadByProjectAndName(d.State(), project, name)
+	if err != nil {
+		return err
+	}
+
+	err = containerRestart(c, timeout)
+	if err != nil {
+		return err
+	}
+
+	if len(req.HealthCheck) == 0 {
+		return nil
+	}
+
+	return profileRestartHealthCheck(req.HealthCheckTimeout, func() (int, error) {
+		devNull, err := os.OpenFile(os.DevNull, os.O_RDWR, 0)
+		if err != nil {
+			return -1, err
+		}
+		defer devNull.Close()
+
+		_, ret, _, err := c.Exec(req.HealthCheck, nil, devNull, devNull, devNull, true, "", 0, 0)
+		return ret, err
+	})
+}
+
+func profileRestartRemoteContainer(client lxd.ContainerServer, name string, req api.ProfileInstancesRestartPost) error {
+	op, err := client.UpdateContainerState(name, api.ContainerStatePut{Action: "restart", Timeout: req.Timeout, Force: req.Force}, "")
+	if err != nil {
+		return err
+	}
+
+	err = op.Wait()
+	if err != nil {
+		return err
+	}
+
+	if len(req.HealthCheck) == 0 {
+		return nil
+	}
+
+	return profileRestartHealthCheck(req.HealthCheckTimeout, func() (int, error) {
+		op, err := client.ExecContainer(name, api.ContainerExecPost{Command: req.HealthCheck}, nil)
+		if err != nil {
+			return -1, err
+		}
+
+		err = op.Wait()
+		if err != nil {
+			return -1, err
+		}
+
+		ret, ok := op.Get().Metadata["return"].(float64)
+		if !ok {
+			return -1, fmt.Errorf("Missing return code of the health check")
+		}
+
+		return int(ret), nil
+	})
+}
+
+// profileRestartHealthCheck retries the check every second until it exits
+// with 0 or the timeout is reached.
+func profileRestartHealthCheck(timeout int, check func() (int, error)) error {
+	deadline := time.Now().Add(time.Duration(timeout) * time.Second)
+
+	for {
+		ret, err := check()
+		if err == nil && ret == 0 {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			if err != nil {
+				return errors.Wrap(err, "Health check failed")
+			}
+
+			return fmt.Errorf("Health check failed with exit code %d", ret)
+		}
+
+		time.Sleep(time.Second)
+	}
+}
@@ -13,12 +13,27 @@ import (
 
 func doProfileUpdate(d *Daemon, project, name string, id int64, profile *api.Profile, req api.ProfilePut) error {
 	// Sanity checks
-	err := containerValidConfig(d.os, req.Config, true, false)
+	restrictions, err := projectRestrictions(d.cluster, project)
 	if err != nil {
 		return err
 	}
 
-	err = containerValidDevices(d.State(), d.cluster, req.Devices, true, false)
+	err = containerValidConfig(d.os, req.Config, true, false, restrictions)
+	if err != nil {
+		return err
+	}
+
+	err = containerValidDevices(d.State(), d.cluster, req.Devices, true, false, restrictions)
+	if err != nil {
+		return err
+	}
+
+	projConfig, err := projectConfig(d.cluster, project)
+	if err != nil {
+		return err
+	}
+
+	err = projectDefaultsCheckConfig(projConfig, req.Config)
 	if err != nil {
 		return err
 	}
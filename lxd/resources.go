@@ -64,5 +64,8 @@ func storagePoolResourcesGet(d *Daemon, r *http.Request) Response {
 		return InternalError(err)
 	}
 
+	operations := storagePoolOperationsState(s.GetStoragePool())
+	res.Operations = &operations
+
 	return SyncResponse(true, &res)
 }
@@ -151,6 +151,106 @@ func (r *forwardedResponse) String() string {
 	return fmt.Sprintf("request to %s", r.request.URL)
 }
 
+// bufferedResponseWriter keeps what a response renders, for it to be altered.
+type bufferedResponseWriter struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func (w *bufferedResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferedResponseWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *bufferedResponseWriter) WriteHeader(code int) {
+	w.code = code
+}
+
+type restrictedResponse struct {
+	resp     Response
+	allowRaw bool
+}
+
+func (r *restrictedResponse) Render(w http.ResponseWriter) error {
+	switch resp := r.resp.(type) {
+	case *errorResponse:
+		return resp.Render(w)
+	case *syncResponse:
+		metadata, err := containerRestrictMetadata(resp.metadata)
+		if err != nil {
+			return err
+		}
+
+		// The ETag is computed from the full configuration
+		restricted := *resp
+		restricted.metadata = metadata
+		restricted.etag = nil
+
+		return restricted.Render(w)
+	case *eventsServe, *operationWebSocket, *forwardedOperationWebSocket, *metricsResponse:
+		// Streams and metrics carry no configuration
+		return resp.Render(w)
+	case *fileResponse, *chunkResponse:
+		// Raw content, such as a backup or lxc.conf, can't be redacted
+		if !r.allowRaw {
+			return Forbidden(fmt.Errorf("Restricted users can't retrieve this content")).Render(w)
+		}
+
+		return resp.Render(w)
+	}
+
+	// Responses rendered elsewhere, like those of other nodes, are
+	// redacted once rendered.
+	buffered := &bufferedResponseWriter{header: http.Header{}, code: http.StatusOK}
+	err := r.resp.Render(buffered)
+	if err != nil {
+		return err
+	}
+
+	body := buffered.body.Bytes()
+
+	resp := api.ResponseRaw{}
+	err = json.Unmarshal(body, &resp)
+	if err == nil && resp.Type != "" {
+		resp.Metadata = containerRestrictFields(resp.Metadata)
+
+		body, err = json.Marshal(resp)
+		if err != nil {
+			return err
+		}
+	} else if !r.allowRaw {
+		return Forbidden(fmt.Errorf("Restricted users can't retrieve this content")).Render(w)
+	}
+
+	for key, values := range buffered.header {
+		w.Header()[key] = values
+	}
+
+	// The ETag is computed from the full configuration and the length
+	// doesn't match anymore.
+	w.Header().Del("ETag")
+	w.Header().Del("Content-Length")
+
+	w.WriteHeader(buffered.code)
+	_, err = w.Write(body)
+	return err
+}
+
+func (r *restrictedResponse) String() string {
+	return r.resp.String()
+}
+
+// RestrictedResponse renders the given response leaving out the sensitive
+// container fields wherever they are, see containerRestrictFields. Raw
+// content is refused unless allowRaw is set.
+func RestrictedResponse(resp Response, allowRaw bool) Response {
+	return &restrictedResponse{resp: resp, allowRaw: allowRaw}
+}
+
 // ForwardedResponse takes a request directed to a node and forwards it to
 // another node, writing back the response it gegs.
 func ForwardedResponse(client lxd.ContainerServer, request *http.Request) Response {
@@ -502,6 +602,14 @@ func Unavailable(err error) Response {
 	return &errorResponse{http.StatusServiceUnavailable, message}
 }
 
+func TooManyRequests(err error) Response {
+	message := "too many requests"
+	if err != nil {
+		message = err.Error()
+	}
+	return &errorResponse{http.StatusTooManyRequests, message}
+}
+
 func BadRequest(err error) Response {
 	return &errorResponse{http.StatusBadRequest, err.Error()}
 }
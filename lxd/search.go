@@ -0,0 +1,252 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	lxd "github.com/lxc/lxd/client"
+	"github.com/lxc/lxd/lxd/cluster"
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+)
+
+var searchCmd = APIEndpoint{
+	Name: "search",
+
+	Get: APIEndpointAction{Handler: searchGet, AccessHandler: AllowAuthenticated},
+}
+
+// /1.0/search
+// Find the containers matching a name, IP address or MAC address across all
+// the projects and cluster members.
+func searchGet(d *Daemon, r *http.Request) Response {
+	query := strings.ToLower(strings.TrimSpace(r.FormValue("q")))
+	if query == "" {
+		return BadRequest(fmt.Errorf("A search query is required"))
+	}
+
+	var containers []db.Instance
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		containers, err = tx.ContainerListExpanded()
+		return err
+	})
+	if err != nil {
+		return SmartError(err)
+	}
+
+	// Only look at the containers in projects the user can see
+	visible := []db.Instance{}
+	for _, c := range containers {
+		if c.Type != int(db.CTypeRegular) {
+			continue
+		}
+
+		if !d.userHasPermission(r, c.Project, "view") {
+			continue
+		}
+
+		visible = append(visible, c)
+	}
+
+	// Addresses are only looked up from the leases when searching for one
+	leases := map[string][]string{}
+	if net.ParseIP(query) != nil {
+		leases, err = searchLeases(d, visible)
+		if err != nil {
+			return SmartError(err)
+		}
+	}
+
+	results := []api.SearchResult{}
+	for _, c := range visible {
+		result := api.SearchResult{
+			Project:    c.Project,
+			Name:       c.Name,
+			Location:   c.Node,
+			Interfaces: searchInterfaces(c, leases),
+		}
+
+		if strings.Contains(strings.ToLower(c.Name), query) {
+			result.Match = "name"
+		}
+
+		for _, iface := range result.Interfaces {
+			if result.Match != "" {
+				break
+			}
+
+			if strings.ToLower(iface.Hwaddr) == query {
+				result.Match = "hwaddr"
+				break
+			}
+
+			for _, address := range iface.Addresses {
+				if searchAddressMatches(address, query) {
+					result.Match = "address"
+					break
+				}
+			}
+		}
+
+		if result.Match == "" {
+			continue
+		}
+
+		results = append(results, result)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Project != results[j].Project {
+			return results[i].Project < results[j].Project
+		}
+
+		return results[i].Name < results[j].Name
+	})
+
+	return SyncResponse(true, results)
+}
+
+// searchInterfaces returns the network interfaces of a container, along with
+// their static addresses and the ones found in the DHCP leases.
+func searchInterfaces(c db.Instance, leases map[string][]string) []api.SearchResultInterface {
+	names := []string{}
+	for name, m := range c.Devices {
+		if m["type"] == "nic" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	ifaces := []api.SearchResultInterface{}
+	for _, name := range names {
+		m := c.Devices[name]
+
+		hwaddr := m["hwaddr"]
+		if hwaddr == "" {
+			hwaddr = c.Config[fmt.Sprintf("volatile.%s.hwaddr", name)]
+		}
+		hwaddr = strings.ToLower(hwaddr)
+
+		addresses := []string{}
+		for _, key := range []string{"ipv4.address", "ipv6.address"} {
+			if m[key] != "" {
+				addresses = append(addresses, m[key])
+			}
+		}
+
+		for _, address := range leases[hwaddr] {
+			if !shared.StringInSlice(address, addresses) {
+				addresses = append(addresses, address)
+			}
+		}
+
+		ifaces = append(ifaces, api.SearchResultInterface{
+			Name:      name,
+			Network:   m["parent"],
+			Hwaddr:    hwaddr,
+			Addresses: addresses,
+		})
+	}
+
+	return ifaces
+}
+
+// searchLeases collects the dynamic leases of the managed bridges used by the
+// containers on all the cluster members, indexed by MAC address.
+func searchLeases(d *Daemon, containers []db.Instance) (map[string][]string, error) {
+	leases := map[string][]string{}
+
+	networks := []string{}
+	for _, c := range containers {
+		for _, m := range c.Devices {
+			if m["type"] != "nic" || m["nictype"] != "bridged" || shared.StringInSlice(m["parent"], networks) {
+				continue
+			}
+
+			_, network, err := d.cluster.NetworkGet(m["parent"])
+			if err != nil || !network.Managed {
+				continue
+			}
+
+			networks = append(networks, m["parent"])
+		}
+	}
+
+	if len(networks) == 0 {
+		return leases, nil
+	}
+
+	var serverName string
+	err := d.cluster.Transaction(func(tx *db.ClusterTx) error {
+		var err error
+		serverName, err = tx.NodeName()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The members are notified concurrently
+	var leasesLock sync.Mutex
+	addLeases := func(entries []api.NetworkLease) {
+		leasesLock.Lock()
+		defer leasesLock.Unlock()
+
+		for _, lease := range entries {
+			hwaddr := strings.ToLower(lease.Hwaddr)
+			if !shared.StringInSlice(lease.Address, leases[hwaddr]) {
+				leases[hwaddr] = append(leases[hwaddr], lease.Address)
+			}
+		}
+	}
+
+	for _, name := range networks {
+		entries, err := networkDynamicLeases(name, serverName)
+		if err != nil {
+			return nil, err
+		}
+
+		addLeases(entries)
+	}
+
+	// Other members only report the leases of their own dnsmasq when notified
+	notifier, err := cluster.NewNotifier(d.State(), d.endpoints.NetworkCert(), cluster.NotifyAlive)
+	if err != nil {
+		return nil, err
+	}
+
+	err = notifier(func(client lxd.ContainerServer) error {
+		for _, name := range networks {
+			entries, err := client.GetNetworkLeases(name)
+			if err != nil {
+				return err
+			}
+
+			addLeases(entries)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return leases, nil
+}
+
+// searchAddressMatches returns whether an address, possibly in CIDR notation,
+// is the one searched for.
+func searchAddressMatches(address string, query string) bool {
+	ip := net.ParseIP(strings.SplitN(address, "/", 2)[0])
+	if ip == nil {
+		return false
+	}
+
+	return ip.Equal(net.ParseIP(query))
+}
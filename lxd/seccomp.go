@@ -142,6 +142,9 @@ static int device_allowed(dev_t dev, mode_t mode)
 
 #include <linux/audit.h>
 
+// From libseccomp, whose architecture tokens are the audit ones.
+extern char *seccomp_syscall_resolve_num_arch(uint32_t arch_token, int num);
+
 struct lxd_seccomp_data_arch {
 	int arch;
 	int nr_mknod;
@@ -270,6 +273,7 @@ static void prepare_seccomp_iovec(struct iovec *iov,
 #endif // SECCOMP_RET_USER_NOTIF
 */
 // #cgo CFLAGS: -std=gnu11 -Wvla
+// #cgo LDFLAGS: -lseccomp
 import "C"
 
 const LxdSeccompNotifyMknod = C.LXD_SECCOMP_NOTIFY_MKNOD
@@ -361,6 +365,7 @@ func seccompContainerNeedsPolicy(c container) bool {
 		"security.syscalls.blacklist_compat",
 		"security.syscalls.intercept.mknod",
 		"security.syscalls.intercept.setxattr",
+		"security.syscalls.log",
 	}
 
 	for _, k := range keys {
@@ -400,6 +405,7 @@ func seccompContainerNeedsIntercept(c container) (bool, error) {
 	keys := []string{
 		"security.syscalls.intercept.mknod",
 		"security.syscalls.intercept.setxattr",
+		"security.syscalls.log",
 	}
 
 	needed := false
@@ -460,7 +466,7 @@ func seccompGetPolicyContent(c container) (string, error) {
 	}
 
 	if whitelist != "" {
-		return policy, nil
+		return seccompContainerLogPolicy(c, policy)
 	}
 
 	// Additional blacklist entries
@@ -478,7 +484,100 @@ func seccompGetPolicyContent(c container) (string, error) {
 		policy += blacklist
 	}
 
-	return policy, nil
+	return seccompContainerLogPolicy(c, policy)
+}
+
+// seccompContainerLogPolicy makes the policy of a container with
+// security.syscalls.log set log the syscalls it denies with an errno.
+func seccompContainerLogPolicy(c container, policy string) (string, error) {
+	if !shared.IsTrue(c.ExpandedConfig()["security.syscalls.log"]) {
+		return policy, nil
+	}
+
+	// The syscalls are logged by LXD as they get intercepted, the ones
+	// killing their process are logged by the kernel.
+	ok, err := seccompContainerNeedsIntercept(c)
+	if err != nil {
+		return "", err
+	}
+
+	if !ok {
+		return policy, nil
+	}
+
+	return seccompLogPolicy(policy), nil
+}
+
+// seccompLogPolicy returns a policy intercepting the syscalls which the given
+// one denies with an errno, keeping the errno in a comment so that they can be
+// denied the same way once logged. All the other rules are left alone.
+func seccompLogPolicy(policy string) string {
+	lines := []string{}
+
+	for i, line := range strings.Split(policy, "\n") {
+		fields := strings.Fields(line)
+		if i == 0 || len(fields) == 0 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], "[") {
+			lines = append(lines, line)
+			continue
+		}
+
+		if shared.StringInSlice(fields[0], []string{"whitelist", "blacklist", "reject_force_umount"}) {
+			lines = append(lines, line)
+			continue
+		}
+
+		action := ""
+		value := ""
+		args := []string{}
+		for _, field := range fields[1:] {
+			if strings.HasPrefix(field, "#") {
+				break
+			}
+
+			if strings.HasPrefix(field, "[") {
+				args = append(args, field)
+			} else if action == "" {
+				action = field
+			} else if value == "" {
+				value = field
+			}
+		}
+
+		if action != "errno" || value == "" {
+			lines = append(lines, line)
+			continue
+		}
+
+		rule := append([]string{fields[0], "notify"}, args...)
+		lines = append(lines, fmt.Sprintf("%s # errno %s", strings.Join(rule, " "), value))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// seccompLogErrnos returns the errno of the syscalls intercepted for
+// logging by a policy generated by seccompLogPolicy, indexed by name.
+func seccompLogErrnos(policy string) map[string]int {
+	errnos := map[string]int{}
+
+	for _, line := range strings.Split(policy, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 || fields[1] != "notify" || fields[len(fields)-3] != "#" || fields[len(fields)-2] != "errno" {
+			continue
+		}
+
+		errno, err := strconv.Atoi(fields[len(fields)-1])
+		if err != nil {
+			continue
+		}
+
+		_, ok := errnos[fields[0]]
+		if !ok {
+			errnos[fields[0]] = errno
+		}
+	}
+
+	return errnos
 }
 
 func SeccompCreateProfile(c container) error {
@@ -709,6 +808,7 @@ func NewSeccompServer(d *Daemon, path string) (*SeccompServer, error) {
 					bytes, err := siov.ReceiveSeccompIovec(int(unixFile.Fd()))
 					if err != nil {
 						logger.Debugf("Disconnected from seccomp socket after failed receive: pid=%v, err=%s", ucred.pid, err)
+						unixFile.Close()
 						c.Close()
 						return
 					}
@@ -1056,14 +1156,65 @@ func (s *SeccompServer) HandleSetxattrSyscall(c container, siov *SeccompIovec) i
 	return 0
 }
 
+// HandleLoggedSyscall records a syscall intercepted for security.syscalls.log
+// and denies it with the errno of its rule.
+func (s *SeccompServer) HandleLoggedSyscall(c container, siov *SeccompIovec) int {
+	arch := uint32(siov.req.data.arch)
+	nr := int(siov.req.data.nr)
+
+	errno := int(C.EPERM)
+	cName := C.seccomp_syscall_resolve_num_arch(C.uint32_t(arch), C.int(nr))
+	if cName != nil {
+		name := C.GoString(cName)
+		C.free(unsafe.Pointer(cName))
+
+		policy, err := ioutil.ReadFile(SeccompProfilePath(c))
+		if err == nil {
+			value, ok := seccompLogErrnos(string(policy))[name]
+			if ok {
+				errno = value
+			}
+		}
+	}
+
+	comm, _ := ioutil.ReadFile(fmt.Sprintf("/proc/%d/comm", siov.req.pid))
+	securitySyscallRecord(c, map[string]string{
+		"syscall": fmt.Sprintf("%d", nr),
+		"arch":    fmt.Sprintf("%x", arch),
+		"pid":     fmt.Sprintf("%d", siov.req.pid),
+		"comm":    strings.TrimSpace(string(comm)),
+	})
+
+	return -errno
+}
+
 func (s *SeccompServer) HandleSyscall(c container, siov *SeccompIovec) int {
+	config := c.ExpandedConfig()
+
+	// The nesting profile only enabled the interception if the container
+	// got the notification, so liblxc supports it
+	intercept := func(key string) bool {
+		return shared.IsTrue(config[key]) || containerNestingImplies(config, key)
+	}
+
 	switch int(C.seccomp_notify_get_syscall(siov.req, siov.resp)) {
 	case LxdSeccompNotifyMknod:
-		return s.HandleMknodSyscall(c, siov)
+		if intercept("security.syscalls.intercept.mknod") {
+			return s.HandleMknodSyscall(c, siov)
+		}
 	case LxdSeccompNotifyMknodat:
-		return s.HandleMknodatSyscall(c, siov)
+		if intercept("security.syscalls.intercept.mknod") {
+			return s.HandleMknodatSyscall(c, siov)
+		}
 	case LxdSeccompNotifySetxattr:
-		return s.HandleSetxattrSyscall(c, siov)
+		if intercept("security.syscalls.intercept.setxattr") {
+			return s.HandleSetxattrSyscall(c, siov)
+		}
+	}
+
+	// Any other syscall was intercepted to be logged
+	if shared.IsTrue(config["security.syscalls.log"]) {
+		return s.HandleLoggedSyscall(c, siov)
 	}
 
 	return int(-C.EINVAL)
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeccompLogPolicy(t *testing.T) {
+	policy := `2
+blacklist
+reject_force_umount  # comment this to allow umount -f;  not recommended
+[all]
+kexec_load errno 38
+mknod notify [1,8192,SCMP_CMP_MASKED_EQ,61440]
+setns
+open_by_handle_at errno 1 [1,0,SCMP_CMP_EQ] # no handles
+`
+
+	expected := `2
+blacklist
+reject_force_umount  # comment this to allow umount -f;  not recommended
+[all]
+kexec_load notify # errno 38
+mknod notify [1,8192,SCMP_CMP_MASKED_EQ,61440]
+setns
+open_by_handle_at notify [1,0,SCMP_CMP_EQ] # errno 1
+`
+
+	assert.Equal(t, expected, seccompLogPolicy(policy))
+	assert.Equal(t, map[string]int{"kexec_load": 38, "open_by_handle_at": 1}, seccompLogErrnos(seccompLogPolicy(policy)))
+
+	policy = `2
+whitelist
+[all]
+read
+write
+ptrace errno 1
+`
+
+	expected = `2
+whitelist
+[all]
+read
+write
+ptrace notify # errno 1
+`
+
+	assert.Equal(t, expected, seccompLogPolicy(policy))
+	assert.Equal(t, map[string]int{"ptrace": 1}, seccompLogErrnos(seccompLogPolicy(policy)))
+}
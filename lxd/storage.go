@@ -185,6 +185,10 @@ type storage interface {
 	ContainerDelete(c container) error
 	ContainerCopy(target container, source container, containerOnly bool) error
 	ContainerRefresh(target container, source container, snapshots []container) error
+
+	// ContainerClone creates the target as an instant copy-on-write copy
+	// of the source, without its snapshots. Both are on the same pool.
+	ContainerClone(target container, source container) error
 	ContainerMount(c container) (bool, error)
 	ContainerUmount(c container, path string) (bool, error)
 	ContainerRename(container container, newName string) error
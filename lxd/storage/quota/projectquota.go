@@ -4,7 +4,9 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
+	"syscall"
 	"unsafe"
 
 	"golang.org/x/sys/unix"
@@ -22,6 +24,7 @@ import (
 #include <fcntl.h>
 #include <stdint.h>
 #include <stdlib.h>
+#include <unistd.h>
 
 #ifndef FS_XFLAG_PROJINHERIT
 struct fsxattr {
@@ -120,6 +123,7 @@ int quota_set_path(char *path, uint32_t id) {
 
 	ret = ioctl(fd, FS_IOC_FSGETXATTR, &attr);
 	if (ret < 0) {
+		close(fd);
 		return -1;
 	}
 
@@ -127,6 +131,7 @@ int quota_set_path(char *path, uint32_t id) {
 	attr.fsx_projid = id;
 
 	ret = ioctl(fd, FS_IOC_FSSETXATTR, &attr);
+	close(fd);
 	if (ret < 0) {
 		return -1;
 	}
@@ -144,6 +149,7 @@ int32_t quota_get_path(char *path) {
 		return -1;
 
 	ret = ioctl(fd, FS_IOC_FSGETXATTR, &attr);
+	close(fd);
 	if (ret < 0) {
 		return -1;
 	}
@@ -235,6 +241,71 @@ func SetProject(path string, id uint32) error {
 	return nil
 }
 
+// SetProjectRecursive sets the project quota ID for the given path and all
+// the directories and regular files below it, so that their existing content
+// is accounted for in the project
+func SetProjectRecursive(path string, id uint32) error {
+	return walkProject(path, func(path string) error {
+		return SetProject(path, id)
+	})
+}
+
+// DeleteProjectRecursive unsets the project id from the path and the
+// directories and regular files below it still having it, and clears the
+// quota for the project id
+func DeleteProjectRecursive(path string, id uint32) error {
+	err := walkProject(path, func(path string) error {
+		current, err := GetProject(path)
+		if err != nil {
+			return err
+		}
+
+		if current != id {
+			return nil
+		}
+
+		return SetProject(path, 0)
+	})
+	if err != nil {
+		return err
+	}
+
+	return SetProjectQuota(path, id, 0)
+}
+
+// walkProject calls fn on the path and the directories and regular files
+// below it, staying on the filesystem of the path
+func walkProject(path string, fn func(path string) error) error {
+	var root unix.Stat_t
+	err := unix.Stat(path, &root)
+	if err != nil {
+		return err
+	}
+
+	return filepath.Walk(path, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Opening other kinds of files could block or have side effects
+		if !fi.IsDir() && !fi.Mode().IsRegular() {
+			return nil
+		}
+
+		// Project IDs only make sense on the filesystem of the quota
+		stat, ok := fi.Sys().(*syscall.Stat_t)
+		if ok && uint64(stat.Dev) != uint64(root.Dev) {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		return fn(path)
+	})
+}
+
 // DeleteProject unsets the project id from the path and clears the quota for the project id
 func DeleteProject(path string, id uint32) error {
 	// Unset the project from the path
@@ -1207,6 +1207,32 @@ func (s *storageBtrfs) ContainerRefresh(target container, source container, snap
 	return s.doCrossPoolContainerCopy(target, source, len(snapshots) == 0, true, snapshots)
 }
 
+func (s *storageBtrfs) ContainerClone(target container, source container) error {
+	logger.Debugf("Cloning BTRFS container storage %s to %s", source.Name(), target.Name())
+
+	// The storage pool needs to be mounted.
+	_, err := s.StoragePoolMount()
+	if err != nil {
+		return err
+	}
+
+	ourStart, err := source.StorageStart()
+	if err != nil {
+		return err
+	}
+	if ourStart {
+		defer source.StorageStop()
+	}
+
+	err = s.copyContainer(target, source)
+	if err != nil {
+		return err
+	}
+
+	logger.Debugf("Cloned BTRFS container storage %s to %s", source.Name(), target.Name())
+	return nil
+}
+
 func (s *storageBtrfs) ContainerMount(c container) (bool, error) {
 	logger.Debugf("Mounting BTRFS storage volume for container \"%s\" on storage pool \"%s\"", s.volume.Name, s.pool.Name)
 
@@ -1590,28 +1616,15 @@ func (s *storageBtrfs) ContainerSnapshotCreateEmpty(snapshotContainer container)
 	return nil
 }
 
-func (s *storageBtrfs) doBtrfsBackup(cur string, prev string, target string) error {
+func (s *storageBtrfs) doBtrfsBackup(cur string, prev string, target string, compress string) error {
 	args := []string{"send"}
 	if prev != "" {
 		args = append(args, "-p", prev)
 	}
 	args = append(args, cur)
 
-	eater, err := os.OpenFile(target, os.O_RDWR|os.O_CREATE, 0644)
-	if err != nil {
-		return err
-	}
-	defer eater.Close()
-
 	btrfsSendCmd := exec.Command("btrfs", args...)
-	btrfsSendCmd.Stdout = eater
-
-	err = btrfsSendCmd.Run()
-	if err != nil {
-		return err
-	}
-
-	return err
+	return backupSendStream(btrfsSendCmd, target, compress)
 }
 
 func (s *storageBtrfs) doContainerBackupCreateOptimized(tmpPath string, backup backup, source container) error {
@@ -1647,7 +1660,7 @@ func (s *storageBtrfs) doContainerBackupCreateOptimized(tmpPath string, backup b
 
 			// Make a binary btrfs backup
 			target := fmt.Sprintf("%s/%s.bin", snapshotsPath, snapName)
-			err := s.doBtrfsBackup(cur, prev, target)
+			err := s.doBtrfsBackup(cur, prev, target, backup.compressionAlgorithm)
 			if err != nil {
 				return err
 			}
@@ -1679,7 +1692,7 @@ func (s *storageBtrfs) doContainerBackupCreateOptimized(tmpPath string, backup b
 
 	// Dump the container to a file
 	fsDump := fmt.Sprintf("%s/container.bin", tmpPath)
-	err = s.doBtrfsBackup(targetVolume, finalParent, fsDump)
+	err = s.doBtrfsBackup(targetVolume, finalParent, fsDump, backup.compressionAlgorithm)
 	if err != nil {
 		return err
 	}
@@ -1793,6 +1806,10 @@ func (s *storageBtrfs) ContainerBackupCreate(backup backup, source container) er
 		if err != nil {
 			return err
 		}
+
+		if backup.compressionAlgorithm != "none" {
+			backup.streamCompression = backup.compressionAlgorithm
+		}
 	} else {
 		err := s.doContainerBackupCreateVanilla(tmpPath, backup, source)
 		if err != nil {
@@ -1847,10 +1864,6 @@ func (s *storageBtrfs) doContainerBackupLoadOptimized(info backupInfo, data io.R
 
 	for _, snapshotOnlyName := range info.Snapshots {
 		snapshotBackup := fmt.Sprintf("%s/snapshots/%s.bin", unpackPath, snapshotOnlyName)
-		feeder, err := os.Open(snapshotBackup)
-		if err != nil {
-			return err
-		}
 
 		// create mountpoint
 		snapshotMntPoint := getSnapshotMountPoint(info.Project, s.pool.Name, containerName)
@@ -1858,15 +1871,12 @@ func (s *storageBtrfs) doContainerBackupLoadOptimized(info backupInfo, data io.R
 		snapshotMntPointSymlink := shared.VarPath("snapshots", project.Prefix(info.Project, containerName))
 		err = createSnapshotMountpoint(snapshotMntPoint, snapshotMntPointSymlinkTarget, snapshotMntPointSymlink)
 		if err != nil {
-			feeder.Close()
 			return err
 		}
 
 		// /var/lib/lxd/storage-pools/<pool>/snapshots/<container>/
 		btrfsRecvCmd := exec.Command("btrfs", "receive", "-e", snapshotMntPoint)
-		btrfsRecvCmd.Stdin = feeder
-		msg, err := btrfsRecvCmd.CombinedOutput()
-		feeder.Close()
+		msg, err := backupReceiveStream(btrfsRecvCmd, snapshotBackup, info.Compression)
 		if err != nil {
 			logger.Errorf("Failed to receive contents of btrfs backup \"%s\": %s", snapshotBackup, string(msg))
 			return err
@@ -1874,16 +1884,10 @@ func (s *storageBtrfs) doContainerBackupLoadOptimized(info backupInfo, data io.R
 	}
 
 	containerBackupFile := fmt.Sprintf("%s/container.bin", unpackPath)
-	feeder, err := os.Open(containerBackupFile)
-	if err != nil {
-		return err
-	}
-	defer feeder.Close()
 
 	// /var/lib/lxd/storage-pools/<pool>/containers/
 	btrfsRecvCmd := exec.Command("btrfs", "receive", "-vv", "-e", unpackDir)
-	btrfsRecvCmd.Stdin = feeder
-	msg, err := btrfsRecvCmd.CombinedOutput()
+	msg, err := backupReceiveStream(btrfsRecvCmd, containerBackupFile, info.Compression)
 	if err != nil {
 		logger.Errorf("Failed to receive contents of btrfs backup \"%s\": %s", containerBackupFile, string(msg))
 		return err
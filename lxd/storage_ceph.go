@@ -1325,6 +1325,19 @@ func (s *storageCeph) ContainerRefresh(target container, source container, snaps
 	return s.doCrossPoolContainerCopy(target, source, len(snapshots) == 0, true, snapshots)
 }
 
+func (s *storageCeph) ContainerClone(target container, source container) error {
+	logger.Debugf(`Cloning RBD container storage %s to %s`, source.Name(), target.Name())
+
+	// Always clone, regardless of ceph.rbd.clone_copy.
+	err := s.copyWithoutSnapshotsSparse(target, source)
+	if err != nil {
+		return err
+	}
+
+	logger.Debugf(`Cloned RBD container storage %s to %s`, source.Name(), target.Name())
+	return nil
+}
+
 func (s *storageCeph) ContainerMount(c container) (bool, error) {
 	logger.Debugf("Mounting RBD storage volume for container \"%s\" on storage pool \"%s\"", s.volume.Name, s.pool.Name)
 
@@ -647,6 +647,10 @@ func (s *storageCephFs) ContainerRefresh(target container, source container, sna
 	return fmt.Errorf("CEPHFS cannot be used for containers")
 }
 
+func (s *storageCephFs) ContainerClone(target container, source container) error {
+	return fmt.Errorf("CEPHFS cannot be used for containers")
+}
+
 func (s *storageCephFs) ContainerMount(c container) (bool, error) {
 	return false, fmt.Errorf("CEPHFS cannot be used for containers")
 }
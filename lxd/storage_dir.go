@@ -20,6 +20,8 @@ import (
 	"github.com/lxc/lxd/shared/ioprogress"
 	"github.com/lxc/lxd/shared/logger"
 	"github.com/lxc/lxd/shared/units"
+
+	log "github.com/lxc/lxd/shared/log15"
 )
 
 type storageDir struct {
@@ -127,6 +129,14 @@ func (s *storageDir) StoragePoolCreate() error {
 		return err
 	}
 
+	if shared.IsTrue(s.pool.Config["dir.dedup"]) {
+		err = s.dedupCheck()
+		if err != nil {
+			os.RemoveAll(s.dedupObjectsPath())
+			return err
+		}
+	}
+
 	revert = false
 
 	logger.Infof("Created DIR storage pool \"%s\"", s.pool.Name)
@@ -302,6 +312,13 @@ func (s *storageDir) StoragePoolUpdate(writable *api.StoragePoolPut, changedConf
 		return updateStoragePoolError(unchangeable, "dir")
 	}
 
+	if shared.StringInSlice("dir.dedup", changedConfig) && shared.IsTrue(writable.Config["dir.dedup"]) {
+		err = s.dedupCheck()
+		if err != nil {
+			return err
+		}
+	}
+
 	// "rsync.bwlimit" requires no on-disk modifications.
 
 	logger.Infof(`Updated DIR storage pool "%s"`, s.pool.Name)
@@ -579,6 +596,11 @@ func (s *storageDir) ContainerCreateFromImage(container container, imageFingerpr
 		return errors.Wrap(err, "Apply template")
 	}
 
+	err = s.dedupContainer(container)
+	if err != nil {
+		logger.Warn("Failed to deduplicate container files", log.Ctx{"container": containerName, "pool": s.pool.Name, "err": err})
+	}
+
 	revert = false
 
 	logger.Debugf("Created DIR storage volume for container \"%s\" on storage pool \"%s\"", s.volume.Name, s.pool.Name)
@@ -624,6 +646,12 @@ func (s *storageDir) ContainerDelete(container container) error {
 		return err
 	}
 
+	// Release the deduplicated objects
+	err = s.dedupRemoveContainer(container)
+	if err != nil {
+		return err
+	}
+
 	// Delete potential leftover snapshot mountpoints.
 	snapshotMntPoint := getSnapshotMountPoint(container.Project(), s.pool.Name, container.Name())
 	if shared.PathExists(snapshotMntPoint) {
@@ -677,6 +705,13 @@ func (s *storageDir) copyContainer(target container, source container) error {
 		return err
 	}
 
+	if !target.IsSnapshot() {
+		err = s.dedupContainer(target)
+		if err != nil {
+			logger.Warn("Failed to deduplicate container files", log.Ctx{"container": target.Name(), "pool": s.pool.Name, "err": err})
+		}
+	}
+
 	return nil
 }
 
@@ -815,6 +850,10 @@ func (s *storageDir) ContainerRefresh(target container, source container, snapsh
 	return nil
 }
 
+func (s *storageDir) ContainerClone(target container, source container) error {
+	return fmt.Errorf("DIR storage pools don't support copy-on-write copies")
+}
+
 func (s *storageDir) ContainerMount(c container) (bool, error) {
 	return s.StoragePoolMount()
 }
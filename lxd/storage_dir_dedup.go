@@ -0,0 +1,267 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/logger"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// FICLONE from linux/fs.h.
+const dirDedupFICLONE = 0x40049409
+
+// Files smaller than this aren't worth deduplicating.
+const dirDedupMinSize = 4096
+
+// Serializes reference changes against garbage collection.
+var dirDedupLock sync.Mutex
+
+// The content-addressed object store of a DIR storage pool lives under
+// ${POOL}/objects/<sha256[0:2]>/<sha256>. Files are only ever shared through
+// reflinks (copy-on-write extents), never through hardlinks, so a container
+// modifying or shifting one of its files can't affect any other container,
+// privileged or not.
+//
+// Each container using an object holds a hardlink to it under
+// ${POOL}/objects/refs/<container id>/<sha256>, the link count of an object
+// therefore being its reference count.
+func (s *storageDir) dedupObjectsPath() string {
+	return filepath.Join(getStoragePoolMountPoint(s.pool.Name), "objects")
+}
+
+func (s *storageDir) dedupRefsPath(c container) string {
+	return filepath.Join(s.dedupObjectsPath(), "refs", fmt.Sprintf("%d", c.Id()))
+}
+
+// dirDedupClone replaces the content of dst with a reflink of src.
+func dirDedupClone(dst *os.File, src *os.File) error {
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, dst.Fd(), dirDedupFICLONE, src.Fd())
+	if errno != 0 {
+		return errno
+	}
+
+	return nil
+}
+
+// dedupCheck makes sure the filesystem backing the pool supports reflinks.
+func (s *storageDir) dedupCheck() error {
+	objectsPath := s.dedupObjectsPath()
+	err := os.MkdirAll(objectsPath, 0700)
+	if err != nil {
+		return err
+	}
+
+	src, err := ioutil.TempFile(objectsPath, ".check")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(src.Name())
+	defer src.Close()
+
+	dst, err := ioutil.TempFile(objectsPath, ".check")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(dst.Name())
+	defer dst.Close()
+
+	err = dirDedupClone(dst, src)
+	if err != nil {
+		return fmt.Errorf("The filesystem backing the storage pool doesn't support reflinks: %v", err)
+	}
+
+	return nil
+}
+
+// dedupContainer deduplicates the regular files of the container against the
+// pool's object store.
+func (s *storageDir) dedupContainer(c container) error {
+	if !shared.IsTrue(s.pool.Config["dir.dedup"]) {
+		return nil
+	}
+
+	dirDedupLock.Lock()
+	defer dirDedupLock.Unlock()
+
+	objectsPath := s.dedupObjectsPath()
+	refsPath := s.dedupRefsPath(c)
+
+	err := os.MkdirAll(refsPath, 0700)
+	if err != nil {
+		return err
+	}
+
+	rootfsPath := filepath.Join(getContainerMountPoint(c.Project(), s.pool.Name, c.Name()), "rootfs")
+
+	var saved int64
+	err = filepath.Walk(rootfsPath, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !fi.Mode().IsRegular() || fi.Size() < dirDedupMinSize {
+			return nil
+		}
+
+		// Leave hardlinked files alone
+		stat, ok := fi.Sys().(*syscall.Stat_t)
+		if !ok || stat.Nlink > 1 {
+			return nil
+		}
+
+		reused, err := s.dedupFile(path, fi, objectsPath, refsPath)
+		if err != nil {
+			return err
+		}
+
+		if reused {
+			saved += fi.Size()
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Debug("Deduplicated container files", log.Ctx{"container": c.Name(), "pool": s.pool.Name, "bytes": saved})
+	return nil
+}
+
+// dedupFile links the file with the object store, returning whether an
+// existing object was re-used.
+func (s *storageDir) dedupFile(path string, fi os.FileInfo, objectsPath string, refsPath string) (bool, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, f)
+	if err != nil {
+		return false, err
+	}
+
+	fingerprint := fmt.Sprintf("%x", hash.Sum(nil))
+	objectPath := filepath.Join(objectsPath, fingerprint[0:2], fingerprint)
+
+	found := shared.PathExists(objectPath)
+	if found {
+		// Replace the file content with the object's, preserving its metadata
+		object, err := os.Open(objectPath)
+		if err != nil {
+			return false, err
+		}
+		defer object.Close()
+
+		err = dirDedupClone(f, object)
+		if err != nil {
+			return false, err
+		}
+
+		err = os.Chtimes(path, fi.ModTime(), fi.ModTime())
+		if err != nil {
+			return false, err
+		}
+	} else {
+		// Add the file to the store
+		err = os.MkdirAll(filepath.Dir(objectPath), 0700)
+		if err != nil {
+			return false, err
+		}
+
+		object, err := ioutil.TempFile(filepath.Dir(objectPath), ".tmp")
+		if err != nil {
+			return false, err
+		}
+		defer object.Close()
+
+		err = dirDedupClone(object, f)
+		if err == nil {
+			err = os.Rename(object.Name(), objectPath)
+		}
+		if err != nil {
+			os.Remove(object.Name())
+			return false, err
+		}
+	}
+
+	// Record the reference
+	refPath := filepath.Join(refsPath, fingerprint)
+	if !shared.PathExists(refPath) {
+		err = os.Link(objectPath, refPath)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return found, nil
+}
+
+// dedupRemoveContainer drops the container's references and garbage collects
+// the objects which are no longer referenced.
+func (s *storageDir) dedupRemoveContainer(c container) error {
+	dirDedupLock.Lock()
+	defer dirDedupLock.Unlock()
+
+	refsPath := s.dedupRefsPath(c)
+	if !shared.PathExists(refsPath) {
+		return nil
+	}
+
+	err := os.RemoveAll(refsPath)
+	if err != nil {
+		return err
+	}
+
+	return s.dedupPrune()
+}
+
+// dedupPrune removes all the objects which aren't referenced anymore.
+func (s *storageDir) dedupPrune() error {
+	objectsPath := s.dedupObjectsPath()
+	refsRoot := filepath.Join(objectsPath, "refs")
+
+	return filepath.Walk(objectsPath, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+
+			return err
+		}
+
+		if fi.IsDir() {
+			if path == refsRoot {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		// Skip leftovers of interrupted operations
+		if strings.HasPrefix(fi.Name(), ".") {
+			return nil
+		}
+
+		stat, ok := fi.Sys().(*syscall.Stat_t)
+		if !ok || stat.Nlink > 1 {
+			return nil
+		}
+
+		return os.Remove(path)
+	})
+}
@@ -1230,6 +1230,30 @@ func (s *storageLvm) ContainerRefresh(target container, source container, snapsh
 	return nil
 }
 
+func (s *storageLvm) ContainerClone(target container, source container) error {
+	logger.Debugf("Cloning LVM container storage for container %s to %s", source.Name(), target.Name())
+
+	if !s.useThinpool {
+		return fmt.Errorf("LVM storage pools without a thinpool don't support copy-on-write copies")
+	}
+
+	ourStart, err := source.StorageStart()
+	if err != nil {
+		return err
+	}
+	if ourStart {
+		defer source.StorageStop()
+	}
+
+	err = s.copyContainer(target, source, false)
+	if err != nil {
+		return err
+	}
+
+	logger.Debugf("Cloned LVM container storage for container %s to %s", source.Name(), target.Name())
+	return nil
+}
+
 func (s *storageLvm) ContainerMount(c container) (bool, error) {
 	return s.doContainerMount(c.Project(), c.Name(), false)
 }
@@ -135,6 +135,10 @@ func (s *storageMock) ContainerRefresh(target container, source container, snaps
 	return nil
 }
 
+func (s *storageMock) ContainerClone(target container, source container) error {
+	return nil
+}
+
 func (s *storageMock) ContainerMount(c container) (bool, error) {
 	return true, nil
 }
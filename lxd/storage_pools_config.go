@@ -18,112 +18,383 @@ func updateStoragePoolError(unchangeable []string, driverName string) error {
 
 var changeableStoragePoolProperties = map[string][]string{
 	"btrfs": {
+		"operations.concurrency",
+		"pool.overcommit.ratio",
 		"rsync.bwlimit",
-		"btrfs.mount_options"},
+		"btrfs.mount_options",
+		"volume.size.max"},
 
 	"ceph": {
+		"operations.concurrency",
+		"pool.overcommit.ratio",
 		"volume.block.filesystem",
 		"volume.block.mount_options",
-		"volume.size"},
+		"volume.size",
+		"volume.size.max"},
 
 	"cephfs": {
-		"rsync.bwlimit"},
+		"operations.concurrency",
+		"pool.overcommit.ratio",
+		"rsync.bwlimit",
+		"volume.size.max"},
 
 	"dir": {
-		"rsync.bwlimit"},
+		"dir.dedup",
+		"operations.concurrency",
+		"pool.overcommit.ratio",
+		"rsync.bwlimit",
+		"volume.size.max"},
 
 	"lvm": {
 		"lvm.thinpool_name",
 		"lvm.vg_name",
+		"operations.concurrency",
+		"pool.overcommit.ratio",
 		"volume.block.filesystem",
 		"volume.block.mount_options",
-		"volume.size"},
+		"volume.size",
+		"volume.size.max"},
 
 	"zfs": {
+		"operations.concurrency",
+		"pool.overcommit.ratio",
 		"rsync_bwlimit",
+		"volume.size.max",
 		"volume.zfs.remove_snapshots",
 		"volume.zfs.use_refquota",
 		"zfs.clone_copy"},
 }
 
-var storagePoolConfigKeys = map[string]func(value string) error{
+var storagePoolConfigKeys = map[string]shared.ConfigKey{
 	// valid drivers: btrfs
 	// (Note, that we can't be smart in detecting mount options since a lot
 	// of filesystems come with their own additional ones (e.g.
 	// "user_subvol_rm_allowed" for btrfs or "zfsutils" for zfs). So
 	// shared.IsAny() must do.)
-	"btrfs.mount_options": shared.IsAny,
+	"btrfs.mount_options": {
+		Type:         "string",
+		Condition:    "btrfs driver",
+		Default:      "user_subvol_rm_allowed",
+		APIExtension: "storage_btrfs_mount_options",
+		Description:  "Mount options for block devices",
+		Validator:    shared.IsAny,
+	},
 
 	// valid drivers: ceph
-	"ceph.cluster_name":    shared.IsAny,
-	"ceph.osd.force_reuse": shared.IsBool,
-	"ceph.osd.pool_name":   shared.IsAny,
-	"ceph.osd.pg_num": func(value string) error {
-		if value == "" {
-			return nil
-		}
+	"ceph.cluster_name": {
+		Type:         "string",
+		Condition:    "ceph driver",
+		Default:      "ceph",
+		APIExtension: "storage_driver_ceph",
+		Description:  "Name of the ceph cluster in which to create new storage pools.",
+		Validator:    shared.IsAny,
+	},
+	"ceph.osd.force_reuse": {
+		Type:         "bool",
+		Condition:    "ceph driver",
+		Default:      "false",
+		APIExtension: "storage_ceph_force_osd_reuse",
+		Description:  "Force using an osd storage pool that is already in use by another LXD instance.",
+		Validator:    shared.IsBool,
+	},
+	"ceph.osd.pool_name": {
+		Type:         "string",
+		Condition:    "ceph driver",
+		Default:      "name of the pool",
+		APIExtension: "storage_driver_ceph",
+		Description:  "Name of the osd storage pool.",
+		Validator:    shared.IsAny,
+	},
+	"ceph.osd.pg_num": {
+		Type:         "string",
+		Condition:    "ceph driver",
+		Default:      "32",
+		APIExtension: "storage_driver_ceph",
+		Description:  "Number of placement groups for the osd storage pool.",
+		Validator: func(value string) error {
+			if value == "" {
+				return nil
+			}
 
-		_, err := units.ParseByteSizeString(value)
-		return err
+			_, err := units.ParseByteSizeString(value)
+			return err
+		},
+	},
+	"ceph.rbd.clone_copy": {
+		Type:         "string",
+		Condition:    "ceph driver",
+		Default:      "true",
+		APIExtension: "storage_driver_ceph",
+		Description:  "Whether to use RBD lightweight clones rather than full dataset copies.",
+		Validator:    shared.IsBool,
+	},
+	"ceph.user.name": {
+		Type:         "string",
+		Condition:    "ceph driver",
+		Default:      "admin",
+		APIExtension: "storage_ceph_user_name",
+		Description:  "The ceph user to use when creating storage pools and volumes.",
+		Validator:    shared.IsAny,
 	},
-	"ceph.rbd.clone_copy": shared.IsBool,
-	"ceph.user.name":      shared.IsAny,
 
 	// valid drivers: cephfs
-	"cephfs.cluster_name": shared.IsAny,
-	"cephfs.path":         shared.IsAny,
-	"cephfs.user.name":    shared.IsAny,
+	"cephfs.cluster_name": {
+		Type:         "string",
+		Condition:    "cephfs driver",
+		Default:      "ceph",
+		APIExtension: "storage_driver_cephfs",
+		Description:  "Name of the ceph cluster in which to create new storage pools.",
+		Validator:    shared.IsAny,
+	},
+	"cephfs.path": {
+		Type:         "string",
+		Condition:    "cephfs driver",
+		Default:      "/",
+		APIExtension: "storage_driver_cephfs",
+		Description:  "The base path for the CEPHFS mount",
+		Validator:    shared.IsAny,
+	},
+	"cephfs.user.name": {
+		Type:         "string",
+		Condition:    "cephfs driver",
+		Default:      "admin",
+		APIExtension: "storage_driver_cephfs",
+		Description:  "The ceph user to use when creating storage pools and volumes.",
+		Validator:    shared.IsAny,
+	},
+
+	// valid drivers: dir
+	"dir.dedup": {
+		Type:         "bool",
+		Condition:    "dir driver",
+		Default:      "false",
+		APIExtension: "storage_dir_dedup",
+		Description:  "Deduplicate identical container files through reflinks to a content-addressed store (requires a filesystem supporting reflinks)",
+		Validator:    shared.IsBool,
+	},
 
 	// valid drivers: lvm
-	"lvm.thinpool_name": shared.IsAny,
-	"lvm.use_thinpool":  shared.IsBool,
-	"lvm.vg_name":       shared.IsAny,
+	"lvm.thinpool_name": {
+		Type:         "string",
+		Condition:    "lvm driver",
+		Default:      "LXDThinPool",
+		APIExtension: "storage",
+		Description:  "Thin pool where images and containers are created.",
+		Validator:    shared.IsAny,
+	},
+	"lvm.use_thinpool": {
+		Type:         "bool",
+		Condition:    "lvm driver",
+		Default:      "true",
+		APIExtension: "storage_lvm_use_thinpool",
+		Description:  "Whether the storage pool uses a thinpool for logical volumes.",
+		Validator:    shared.IsBool,
+	},
+	"lvm.vg_name": {
+		Type:         "string",
+		Condition:    "lvm driver",
+		Default:      "name of the pool",
+		APIExtension: "storage",
+		Description:  "Name of the volume group to create.",
+		Validator:    shared.IsAny,
+	},
+
+	// valid drivers: all
+	"operations.concurrency": {
+		Type:         "integer",
+		Condition:    "-",
+		Default:      "0 (no limit)",
+		APIExtension: "storage_pool_operations_limit",
+		Description:  "Maximum number of concurrent storage operations (container creation, copy, snapshot, deletion and migration) on the pool. Further operations are queued.",
+		Validator: func(value string) error {
+			if value == "" {
+				return nil
+			}
+
+			limit, err := strconv.Atoi(value)
+			if err != nil {
+				return err
+			}
+
+			if limit < 0 {
+				return fmt.Errorf("Invalid value for an integer: %s", value)
+			}
 
-	// valid drivers: btrfs, lvm, zfs
-	"size": func(value string) error {
-		if value == "" {
 			return nil
-		}
+		},
+	},
 
-		_, err := units.ParseByteSizeString(value)
-		return err
+	// valid drivers: all
+	"pool.overcommit.ratio": {
+		Type:         "string",
+		Condition:    "-",
+		Default:      "- (no limit)",
+		APIExtension: "storage_pool_overcommit",
+		Description:  "Maximum ratio between the sum of the root disk sizes of the containers and of the custom volume sizes on the pool and the pool capacity (e.g. 1.5)",
+		Validator: func(value string) error {
+			if value == "" {
+				return nil
+			}
+
+			ratio, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return err
+			}
+
+			if ratio <= 0 {
+				return fmt.Errorf("Invalid value for a positive number: %s", value)
+			}
+
+			return nil
+		},
+	},
+
+	// valid drivers: btrfs, lvm, zfs
+	"size": {
+		Type:         "string",
+		Condition:    "appropriate driver and source",
+		Default:      "0",
+		APIExtension: "storage",
+		Description:  "Size of the storage pool in bytes (suffixes supported). (Currently valid for loop based pools and zfs.)",
+		Validator: func(value string) error {
+			if value == "" {
+				return nil
+			}
+
+			_, err := units.ParseByteSizeString(value)
+			return err
+		},
 	},
 
 	// valid drivers: btrfs, dir, lvm, zfs
-	"source": shared.IsAny,
+	"source": {
+		Type:         "string",
+		Condition:    "-",
+		Default:      "-",
+		APIExtension: "storage",
+		Description:  "Path to block device or loop file or filesystem entry",
+		Validator:    shared.IsAny,
+	},
 
 	// Using it as an indicator whether we created the pool or are just
 	// re-using it. Note that the valid drivers only list ceph for now. This
 	// approach is however generalizable. It's just that we currently don't
 	// really need it for the other drivers.
 	// valid drivers: ceph
-	"volatile.pool.pristine":  shared.IsAny,
-	"volatile.initial_source": shared.IsAny,
+	"volatile.pool.pristine": {
+		Type:         "string",
+		Condition:    "-",
+		Default:      "true",
+		APIExtension: "storage_driver_ceph",
+		Description:  "Whether the pool has been empty on creation time.",
+		Validator:    shared.IsAny,
+	},
+	"volatile.initial_source": {
+		Type:         "string",
+		Condition:    "-",
+		Default:      "-",
+		APIExtension: "storage_volatile_initial_source",
+		Description:  "Records the actual source passed during creating (e.g. /dev/sdb).",
+		Validator:    shared.IsAny,
+	},
 
 	// valid drivers: ceph, lvm
-	"volume.block.filesystem": func(value string) error {
-		return shared.IsOneOf(value, []string{"btrfs", "ext4", "xfs"})
+	"volume.block.filesystem": {
+		Type:         "string",
+		Condition:    "block based driver (lvm)",
+		Default:      "ext4",
+		APIExtension: "storage",
+		Description:  "Filesystem to use for new volumes",
+		Validator: func(value string) error {
+			return shared.IsOneOf(value, []string{"btrfs", "ext4", "xfs"})
+		},
+	},
+	"volume.block.mount_options": {
+		Type:         "string",
+		Condition:    "block based driver (lvm)",
+		Default:      "discard",
+		APIExtension: "storage",
+		Description:  "Mount options for block devices",
+		Validator:    shared.IsAny,
 	},
-	"volume.block.mount_options": shared.IsAny,
 
 	// valid drivers: ceph, lvm
-	"volume.size": func(value string) error {
-		if value == "" {
-			return nil
-		}
+	"volume.size": {
+		Type:         "string",
+		Condition:    "appropriate driver",
+		Default:      "0",
+		APIExtension: "storage",
+		Description:  "Default volume size",
+		Validator: func(value string) error {
+			if value == "" {
+				return nil
+			}
 
-		_, err := units.ParseByteSizeString(value)
-		return err
+			_, err := units.ParseByteSizeString(value)
+			return err
+		},
+	},
+
+	// valid drivers: all
+	"volume.size.max": {
+		Type:         "string",
+		Condition:    "-",
+		Default:      "- (no limit)",
+		APIExtension: "storage_pool_overcommit",
+		Description:  "Maximum size of the root disk of a container on the pool",
+		Validator: func(value string) error {
+			if value == "" {
+				return nil
+			}
+
+			_, err := units.ParseByteSizeString(value)
+			return err
+		},
 	},
 
 	// valid drivers: zfs
-	"volume.zfs.remove_snapshots": shared.IsBool,
-	"volume.zfs.use_refquota":     shared.IsBool,
+	"volume.zfs.remove_snapshots": {
+		Type:         "bool",
+		Condition:    "zfs driver",
+		Default:      "false",
+		APIExtension: "storage",
+		Description:  "Remove snapshots as needed",
+		Validator:    shared.IsBool,
+	},
+	"volume.zfs.use_refquota": {
+		Type:         "bool",
+		Condition:    "zfs driver",
+		Default:      "false",
+		APIExtension: "storage",
+		Description:  "Use refquota instead of quota for space.",
+		Validator:    shared.IsBool,
+	},
 
 	// valid drivers: zfs
-	"zfs.clone_copy": shared.IsBool,
-	"zfs.pool_name":  shared.IsAny,
-	"rsync.bwlimit":  shared.IsAny,
+	"zfs.clone_copy": {
+		Type:         "bool",
+		Condition:    "zfs driver",
+		Default:      "true",
+		APIExtension: "storage_zfs_clone_copy",
+		Description:  "Whether to use ZFS lightweight clones rather than full dataset copies.",
+		Validator:    shared.IsBool,
+	},
+	"zfs.pool_name": {
+		Type:         "string",
+		Condition:    "zfs driver",
+		Default:      "name of the pool",
+		APIExtension: "storage",
+		Description:  "Name of the zpool",
+		Validator:    shared.IsAny,
+	},
+	"rsync.bwlimit": {
+		Type:         "string",
+		Condition:    "-",
+		Default:      "0 (no limit)",
+		APIExtension: "storage_rsync_bwlimit",
+		Description:  "Specifies the upper limit to be placed on the socket I/O whenever rsync has to be used to transfer storage entities.",
+		Validator:    shared.IsAny,
+	},
 }
 
 func storagePoolValidateConfig(name string, driver string, config map[string]string, oldConfig map[string]string) error {
@@ -169,6 +440,12 @@ func storagePoolValidateConfig(name string, driver string, config map[string]str
 			}
 		}
 
+		if driver != "dir" {
+			if prfx(key, "dir.") {
+				return fmt.Errorf("the key %s cannot be used with %s storage pools", key, strings.ToUpper(driver))
+			}
+		}
+
 		if driver != "lvm" {
 			if prfx(key, "lvm.") {
 				return fmt.Errorf("the key %s cannot be used with %s storage pools", key, strings.ToUpper(driver))
@@ -188,12 +465,12 @@ func storagePoolValidateConfig(name string, driver string, config map[string]str
 		}
 
 		// Validate storage pool config keys.
-		validator, ok := storagePoolConfigKeys[key]
+		configKey, ok := storagePoolConfigKeys[key]
 		if !ok {
 			return fmt.Errorf("Invalid storage pool configuration key: %s", key)
 		}
 
-		err := validator(val)
+		err := configKey.Validator(val)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"sync"
+
+	log "github.com/lxc/lxd/shared/log15"
+	"github.com/lxc/lxd/shared/logger"
+)
+
+// Whether the filesystems of the storage pools support idmapped mounts, by
+// pool name. Not all filesystems do, even when the kernel does.
+var storagePoolsIdmap = map[string]bool{}
+var storagePoolsIdmapLock sync.Mutex
+
+// storagePoolIdmappable returns whether the volumes of a storage pool can be
+// idmapped when mounted in containers. The first call for a pool probes the
+// given path, which must be a mounted volume of that pool.
+func storagePoolIdmappable(poolName string, path string) bool {
+	storagePoolsIdmapLock.Lock()
+	defer storagePoolsIdmapLock.Unlock()
+
+	idmappable, ok := storagePoolsIdmap[poolName]
+	if ok {
+		return idmappable
+	}
+
+	idmappable = CanIdmapPath(path)
+	if !idmappable {
+		logger.Info("Storage pool doesn't support idmapped mounts", log.Ctx{"pool": poolName})
+	}
+
+	storagePoolsIdmap[poolName] = idmappable
+	return idmappable
+}
+
+// storagePoolIdmapKnown returns whether the storage pool was already probed
+// for idmapped mounts.
+func storagePoolIdmapKnown(poolName string) bool {
+	storagePoolsIdmapLock.Lock()
+	defer storagePoolsIdmapLock.Unlock()
+
+	_, ok := storagePoolsIdmap[poolName]
+	return ok
+}
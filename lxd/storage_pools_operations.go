@@ -0,0 +1,116 @@
+package main
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/lxc/lxd/lxd/project"
+	"github.com/lxc/lxd/shared/api"
+)
+
+// storagePoolOperations tracks the storage operations running on a pool, and
+// those waiting for one of the slots allowed by operations.concurrency. The
+// slots are held per container, so that the operations nested in another one
+// on the same container, like creating the snapshots received by a migration
+// sink, don't wait for the slot held by the outer one.
+type storagePoolOperations struct {
+	lock    sync.Mutex
+	cond    *sync.Cond
+	running int
+	queued  int
+	holders map[string]int
+}
+
+var storagePoolOperationsLock sync.Mutex
+var storagePoolOperationsByPool = map[string]*storagePoolOperations{}
+
+func storagePoolOperationsGet(poolName string) *storagePoolOperations {
+	storagePoolOperationsLock.Lock()
+	defer storagePoolOperationsLock.Unlock()
+
+	ops, ok := storagePoolOperationsByPool[poolName]
+	if !ok {
+		ops = &storagePoolOperations{holders: map[string]int{}}
+		ops.cond = sync.NewCond(&ops.lock)
+		storagePoolOperationsByPool[poolName] = ops
+	}
+
+	return ops
+}
+
+// storagePoolOperationsLimit returns the maximum number of concurrent
+// operations allowed on the pool, 0 meaning unlimited.
+func storagePoolOperationsLimit(pool *api.StoragePool) int {
+	if pool == nil {
+		return 0
+	}
+
+	limit, err := strconv.Atoi(pool.Config["operations.concurrency"])
+	if err != nil || limit < 0 {
+		return 0
+	}
+
+	return limit
+}
+
+// storagePoolOperationStart waits until the pool of the given storage allows
+// one more operation for the container and returns the function to call once
+// it's done. The function must be called before any cleanup involving the same
+// pool and another container, to not deadlock on a limit of 1.
+func storagePoolOperationStart(s storage, c container) func() {
+	if s == nil {
+		return func() {}
+	}
+
+	pool := s.GetStoragePool()
+	if pool == nil {
+		return func() {}
+	}
+
+	// Snapshots share the slot of their container
+	name, _, _ := containerGetParentAndSnapshotName(c.Name())
+	owner := project.Prefix(c.Project(), name)
+
+	return storagePoolOperationsGet(pool.Name).start(storagePoolOperationsLimit(pool), owner)
+}
+
+// start takes a slot for the owner, unless it already holds one, and returns
+// the function releasing it.
+func (ops *storagePoolOperations) start(limit int, owner string) func() {
+	ops.lock.Lock()
+	if ops.holders[owner] == 0 {
+		ops.queued++
+		for limit > 0 && ops.running >= limit {
+			ops.cond.Wait()
+		}
+		ops.queued--
+		ops.running++
+	}
+	ops.holders[owner]++
+	ops.lock.Unlock()
+
+	return func() {
+		ops.lock.Lock()
+		ops.holders[owner]--
+		if ops.holders[owner] == 0 {
+			delete(ops.holders, owner)
+			ops.running--
+			ops.cond.Broadcast()
+		}
+		ops.lock.Unlock()
+	}
+}
+
+// storagePoolOperationsState returns the current operation counts of a pool.
+func storagePoolOperationsState(pool *api.StoragePool) api.ResourcesStoragePoolOperations {
+	ops := storagePoolOperationsGet(pool.Name)
+
+	ops.lock.Lock()
+	defer ops.lock.Unlock()
+
+	return api.ResourcesStoragePoolOperations{
+		Running: uint64(ops.running),
+		Queued:  uint64(ops.queued),
+		Limit:   uint64(storagePoolOperationsLimit(pool)),
+	}
+}
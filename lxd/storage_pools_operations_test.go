@@ -0,0 +1,40 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoragePoolOperationsReentrant(t *testing.T) {
+	ops := &storagePoolOperations{holders: map[string]int{}}
+	ops.cond = sync.NewCond(&ops.lock)
+
+	// Nested operations on the same container share its slot
+	release := ops.start(1, "c1")
+	releaseNested := ops.start(1, "c1")
+	assert.Equal(t, 1, ops.running)
+
+	done := make(chan struct{})
+	go func() {
+		ops.start(1, "c2")()
+		close(done)
+	}()
+
+	releaseNested()
+
+	select {
+	case <-done:
+		t.Fatal("Another container got a slot while the outer operation runs")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	release()
+	<-done
+
+	assert.Equal(t, 0, ops.running)
+	assert.Equal(t, 0, ops.queued)
+	assert.Empty(t, ops.holders)
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/lxc/lxd/lxd/db"
+	"github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/lxd/state"
+	"github.com/lxc/lxd/shared"
+	"github.com/lxc/lxd/shared/api"
+	"github.com/lxc/lxd/shared/logger"
+	"github.com/lxc/lxd/shared/units"
+
+	log "github.com/lxc/lxd/shared/log15"
+)
+
+// storagePoolRootDiskSize returns the size a root disk commits on its pool, or
+// -1 if it isn't bounded.
+func storagePoolRootDiskSize(rootDisk config.Device, pool *api.StoragePool) (int64, error) {
+	return storagePoolVolumeSize(rootDisk["size"], pool)
+}
+
+// storagePoolVolumeSize returns the size a volume of the given configured size
+// commits on its pool, or -1 if it isn't bounded.
+func storagePoolVolumeSize(size string, pool *api.StoragePool) (int64, error) {
+	// Block based drivers always give volumes a size
+	if size == "" && (pool.Driver == "lvm" || pool.Driver == "ceph") {
+		size = pool.Config["volume.size"]
+		if size == "" || size == "0" {
+			size = "10GB"
+		}
+	}
+
+	if size == "" {
+		return -1, nil
+	}
+
+	return units.ParseByteSizeString(size)
+}
+
+// storagePoolOvercommitted returns whether committing the given size on a pool
+// of the given capacity breaks its overcommit ratio.
+func storagePoolOvercommitted(committed int64, capacity int64, ratio float64) bool {
+	return float64(committed) > float64(capacity)*ratio
+}
+
+// storagePoolCommitCheck applies the volume.size.max and pool.overcommit.ratio
+// policies of a pool to the root disk of a container being created or
+// resized. Root disks without a size can't be accounted for and only get a
+// warning.
+func storagePoolCommitCheck(s *state.State, c container, rootDisk config.Device, pool *api.StoragePool) error {
+	if pool.Config["volume.size.max"] == "" && pool.Config["pool.overcommit.ratio"] == "" {
+		return nil
+	}
+
+	size, err := storagePoolRootDiskSize(rootDisk, pool)
+	if err != nil {
+		return err
+	}
+
+	if size < 0 {
+		logger.Warn("Root disk without a size escapes the storage pool policies", log.Ctx{"container": c.Name(), "project": c.Project(), "pool": pool.Name})
+		return nil
+	}
+
+	if pool.Config["volume.size.max"] != "" {
+		maxSize, err := units.ParseByteSizeString(pool.Config["volume.size.max"])
+		if err != nil {
+			return err
+		}
+
+		if size > maxSize {
+			return fmt.Errorf("The root disk size %s exceeds the maximum volume size %s of storage pool %q", units.GetByteSizeString(size, 2), pool.Config["volume.size.max"], pool.Name)
+		}
+	}
+
+	if pool.Config["pool.overcommit.ratio"] == "" {
+		return nil
+	}
+
+	ratio, err := strconv.ParseFloat(pool.Config["pool.overcommit.ratio"], 64)
+	if err != nil {
+		return err
+	}
+
+	capacity, err := storagePoolCapacity(s, pool)
+	if err != nil || capacity <= 0 {
+		logger.Warn("Unable to get the storage pool capacity, skipping the overcommit check", log.Ctx{"pool": pool.Name, "err": err})
+		return nil
+	}
+
+	committed, err := storagePoolCommitted(s, c, pool)
+	if err != nil {
+		return err
+	}
+
+	if storagePoolOvercommitted(committed+size, capacity, ratio) {
+		return fmt.Errorf("The root disk size %s would commit %s of storage pool %q, over its %s capacity times its overcommit ratio of %s", units.GetByteSizeString(size, 2), units.GetByteSizeString(committed+size, 2), pool.Name, units.GetByteSizeString(capacity, 2), pool.Config["pool.overcommit.ratio"])
+	}
+
+	return nil
+}
+
+// storagePoolCapacity returns the total space of a pool.
+func storagePoolCapacity(s *state.State, pool *api.StoragePool) (int64, error) {
+	st, err := storagePoolInit(s, pool.Name)
+	if err != nil {
+		return -1, err
+	}
+
+	err = st.StoragePoolCheck()
+	if err != nil {
+		return -1, err
+	}
+
+	res, err := st.StoragePoolResources()
+	if err != nil {
+		return -1, err
+	}
+
+	return int64(res.Space.Total), nil
+}
+
+// storagePoolCommitted returns the sum of the sizes of the root disks on a pool
+// of all the containers but the given one, plus the sizes of the custom volumes
+// of the pool. The other disks backed by a pool are custom volumes, so they are
+// accounted for once through the volumes. Ceph pools are shared by the cluster
+// nodes, other pools are local to each of them.
+func storagePoolCommitted(s *state.State, c container, pool *api.StoragePool) (int64, error) {
+	var containers []container
+	var err error
+	if pool.Driver == "ceph" {
+		containers, err = containerLoadFromAllProjects(s)
+	} else {
+		containers, err = containerLoadNodeAll(s)
+	}
+	if err != nil {
+		return -1, err
+	}
+
+	committed := int64(0)
+	for _, other := range containers {
+		if other.IsSnapshot() || (other.Project() == c.Project() && other.Name() == c.Name()) {
+			continue
+		}
+
+		_, rootDisk, err := shared.GetRootDiskDevice(other.ExpandedDevices())
+		if err != nil || rootDisk["pool"] != pool.Name {
+			continue
+		}
+
+		size, err := storagePoolRootDiskSize(rootDisk, pool)
+		if err != nil || size < 0 {
+			continue
+		}
+
+		committed += size
+	}
+
+	poolID, err := s.Cluster.StoragePoolGetID(pool.Name)
+	if err != nil {
+		return -1, err
+	}
+
+	var volumes []*api.StorageVolume
+	if pool.Driver == "ceph" {
+		volumes, err = s.Cluster.StoragePoolVolumesGet("default", poolID, []int{storagePoolVolumeTypeCustom})
+	} else {
+		volumes, err = s.Cluster.StoragePoolNodeVolumesGet(poolID, []int{storagePoolVolumeTypeCustom})
+	}
+	if err != nil && err != db.ErrNoSuchObject {
+		return -1, err
+	}
+
+	// Ceph volumes are listed once per node
+	seen := map[string]bool{}
+	for _, volume := range volumes {
+		if seen[volume.Name] {
+			continue
+		}
+		seen[volume.Name] = true
+
+		size, err := storagePoolVolumeSize(volume.Config["size"], pool)
+		if err != nil || size < 0 {
+			continue
+		}
+
+		committed += size
+	}
+
+	return committed, nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lxc/lxd/lxd/device/config"
+	"github.com/lxc/lxd/shared/api"
+)
+
+func TestStoragePoolRootDiskSize(t *testing.T) {
+	dir := &api.StoragePool{Driver: "dir"}
+	lvm := &api.StoragePool{Driver: "lvm"}
+	lvm.Config = map[string]string{"volume.size": "20GB"}
+	ceph := &api.StoragePool{Driver: "ceph"}
+	ceph.Config = map[string]string{}
+
+	size, err := storagePoolRootDiskSize(config.Device{"size": "5GB"}, dir)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5000000000), size)
+
+	size, err = storagePoolRootDiskSize(config.Device{}, dir)
+	require.NoError(t, err)
+	assert.Equal(t, int64(-1), size)
+
+	size, err = storagePoolRootDiskSize(config.Device{}, lvm)
+	require.NoError(t, err)
+	assert.Equal(t, int64(20000000000), size)
+
+	size, err = storagePoolRootDiskSize(config.Device{}, ceph)
+	require.NoError(t, err)
+	assert.Equal(t, int64(10000000000), size)
+
+	_, err = storagePoolRootDiskSize(config.Device{"size": "lots"}, dir)
+	assert.Error(t, err)
+}
+
+func TestStoragePoolOvercommitted(t *testing.T) {
+	assert.False(t, storagePoolOvercommitted(100, 100, 1))
+	assert.True(t, storagePoolOvercommitted(101, 100, 1))
+	assert.False(t, storagePoolOvercommitted(150, 100, 1.5))
+	assert.True(t, storagePoolOvercommitted(60, 100, 0.5))
+}
+
+func TestStoragePoolVolumeSize(t *testing.T) {
+	dir := &api.StoragePool{Driver: "dir"}
+	ceph := &api.StoragePool{Driver: "ceph"}
+	ceph.Config = map[string]string{"volume.size": "0"}
+
+	size, err := storagePoolVolumeSize("1GB", dir)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1000000000), size)
+
+	size, err = storagePoolVolumeSize("", dir)
+	require.NoError(t, err)
+	assert.Equal(t, int64(-1), size)
+
+	size, err = storagePoolVolumeSize("", ceph)
+	require.NoError(t, err)
+	assert.Equal(t, int64(10000000000), size)
+}
@@ -1393,6 +1393,27 @@ func (s *storageZfs) ContainerRefresh(target container, source container, snapsh
 	return s.doCrossPoolContainerCopy(target, source, len(snapshots) == 0, true, snapshots)
 }
 
+func (s *storageZfs) ContainerClone(target container, source container) error {
+	logger.Debugf("Cloning ZFS container storage %s to %s", source.Name(), target.Name())
+
+	ourStart, err := source.StorageStart()
+	if err != nil {
+		return err
+	}
+	if ourStart {
+		defer source.StorageStop()
+	}
+
+	// Always clone, regardless of zfs.clone_copy.
+	err = s.copyWithoutSnapshotsSparse(target, source)
+	if err != nil {
+		return err
+	}
+
+	logger.Debugf("Cloned ZFS container storage %s to %s", source.Name(), target.Name())
+	return nil
+}
+
 func (s *storageZfs) ContainerRename(container container, newName string) error {
 	logger.Debugf("Renaming ZFS storage volume for container \"%s\" from %s to %s", s.volume.Name, s.volume.Name, newName)
 
@@ -1869,20 +1890,8 @@ func (s *storageZfs) doContainerOnlyBackup(tmpPath string, backup backup, source
 
 	// Dump the container to a file
 	backupFile := fmt.Sprintf("%s/%s", tmpPath, "container.bin")
-	f, err := os.OpenFile(backupFile, os.O_RDWR|os.O_CREATE, 0644)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
 	zfsSendCmd := exec.Command("zfs", "send", sourceDataset)
-	zfsSendCmd.Stdout = f
-	err = zfsSendCmd.Run()
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return backupSendStream(zfsSendCmd, backupFile, backup.compressionAlgorithm)
 }
 
 func (s *storageZfs) doSnapshotBackup(tmpPath string, backup backup, source container, parentSnapshot string) error {
@@ -1906,15 +1915,8 @@ func (s *storageZfs) doSnapshotBackup(tmpPath string, backup backup, source cont
 	}
 
 	backupFile := fmt.Sprintf("%s/%s.bin", snapshotsPath, sourceSnapOnlyName)
-	f, err := os.OpenFile(backupFile, os.O_RDWR|os.O_CREATE, 0644)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
 	zfsSendCmd := exec.Command("zfs", args...)
-	zfsSendCmd.Stdout = f
-	return zfsSendCmd.Run()
+	return backupSendStream(zfsSendCmd, backupFile, backup.compressionAlgorithm)
 }
 
 func (s *storageZfs) doContainerBackupCreateOptimized(tmpPath string, backup backup, source container) error {
@@ -1963,16 +1965,8 @@ func (s *storageZfs) doContainerBackupCreateOptimized(tmpPath string, backup bac
 		}
 
 		backupFile := fmt.Sprintf("%s/container.bin", tmpPath)
-		f, err := os.OpenFile(backupFile, os.O_RDWR|os.O_CREATE, 0644)
-		if err != nil {
-			return err
-		}
-		defer f.Close()
-
 		zfsSendCmd := exec.Command("zfs", args...)
-		zfsSendCmd.Stdout = f
-
-		err = zfsSendCmd.Run()
+		err = backupSendStream(zfsSendCmd, backupFile, backup.compressionAlgorithm)
 		if err != nil {
 			return err
 		}
@@ -2112,6 +2106,10 @@ func (s *storageZfs) ContainerBackupCreate(backup backup, source container) erro
 		if err != nil {
 			return errors.Wrap(err, "Optimized backup")
 		}
+
+		if backup.compressionAlgorithm != "none" {
+			backup.streamCompression = backup.compressionAlgorithm
+		}
 	} else {
 		err = s.doContainerBackupCreateVanilla(tmpPath, backup, source)
 		if err != nil {
@@ -2169,18 +2167,9 @@ func (s *storageZfs) doContainerBackupLoadOptimized(info backupInfo, data io.Rea
 	poolName := s.getOnDiskPoolName()
 	for _, snapshotOnlyName := range info.Snapshots {
 		snapshotBackup := fmt.Sprintf("%s/snapshots/%s.bin", unpackPath, snapshotOnlyName)
-		feeder, err := os.Open(snapshotBackup)
-		if err != nil {
-			// can't use defer because it needs to run before the mount
-			os.RemoveAll(unpackPath)
-			return err
-		}
-
 		snapshotDataset := fmt.Sprintf("%s/containers/%s@snapshot-%s", poolName, project.Prefix(info.Project, containerName), snapshotOnlyName)
 		zfsRecvCmd := exec.Command("zfs", "receive", "-F", snapshotDataset)
-		zfsRecvCmd.Stdin = feeder
-		err = zfsRecvCmd.Run()
-		feeder.Close()
+		_, err = backupReceiveStream(zfsRecvCmd, snapshotBackup, info.Compression)
 		if err != nil {
 			// can't use defer because it needs to run before the mount
 			os.RemoveAll(unpackPath)
@@ -2200,19 +2189,9 @@ func (s *storageZfs) doContainerBackupLoadOptimized(info backupInfo, data io.Rea
 	}
 
 	containerBackup := fmt.Sprintf("%s/container.bin", unpackPath)
-	feeder, err := os.Open(containerBackup)
-	if err != nil {
-		// can't use defer because it needs to run before the mount
-		os.RemoveAll(unpackPath)
-		return err
-	}
-	defer feeder.Close()
-
 	containerSnapshotDataset := fmt.Sprintf("%s/containers/%s@backup", poolName, project.Prefix(info.Project, containerName))
 	zfsRecvCmd := exec.Command("zfs", "receive", "-F", containerSnapshotDataset)
-	zfsRecvCmd.Stdin = feeder
-
-	err = zfsRecvCmd.Run()
+	_, err = backupReceiveStream(zfsRecvCmd, containerBackup, info.Compression)
 	os.RemoveAll(unpackPath)
 	zfsPoolVolumeSnapshotDestroy(poolName, fmt.Sprintf("containers/%s", project.Prefix(info.Project, containerName)), "backup")
 	if err != nil {
@@ -1,7 +1,12 @@
 package sys
 
 import (
+	"bufio"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/logger"
@@ -16,17 +21,85 @@ func (s *OS) initCGroup() {
 		&s.CGroupCPUsetController,
 		&s.CGroupDevicesController,
 		&s.CGroupFreezerController,
+		&s.CGroupHugetlbController,
 		&s.CGroupMemoryController,
 		&s.CGroupNetPrioController,
 		&s.CGroupPidsController,
 		&s.CGroupSwapAccounting,
+		&s.CGroupCPUBurst,
 	}
+
+	// Hosts only having the unified hierarchy
+	s.CGroupV2 = shared.PathExists("/sys/fs/cgroup/cgroup.controllers")
+	if s.CGroupV2 {
+		s.initCGroupV2()
+	}
+
 	for i, flag := range flags {
-		*flag = shared.PathExists("/sys/fs/cgroup/" + cGroups[i].path)
+		if !s.CGroupV2 {
+			*flag = shared.PathExists("/sys/fs/cgroup/" + cGroups[i].path)
+		}
+
 		if !*flag {
 			logger.Warnf(cGroups[i].warn)
 		}
 	}
+
+	// Needed to give containers their own view of the cgroup tree
+	s.CGroupNamespace = shared.PathExists("/proc/self/ns/cgroup")
+
+	// Unified (cgroup2) hierarchy mounted alongside the legacy ones
+	s.CGroupUnified = shared.PathExists("/sys/fs/cgroup/unified/cgroup.controllers")
+}
+
+// Detect the controllers and features of the unified hierarchy, setting the
+// same flags as their legacy counterparts.
+func (s *OS) initCGroupV2() {
+	content, err := ioutil.ReadFile("/sys/fs/cgroup/cgroup.controllers")
+	if err != nil {
+		logger.Warnf("Couldn't read the available CGroup controllers: %v", err)
+	}
+
+	controllers := strings.Fields(string(content))
+	has := func(controller string) bool {
+		return shared.StringInSlice(controller, controllers)
+	}
+
+	// Some files are only found below the root cgroup, look for them in
+	// LXD's own.
+	self := filepath.Join("/sys/fs/cgroup", cGroupV2Self())
+
+	s.CGroupBlkioController = has("io")
+	s.CGroupCPUController = has("cpu")
+	s.CGroupCPUacctController = has("cpu")
+	s.CGroupCPUsetController = has("cpuset")
+	s.CGroupDevicesController = true // Through eBPF programs
+	s.CGroupFreezerController = shared.PathExists(filepath.Join(self, "cgroup.freeze"))
+	s.CGroupHugetlbController = has("hugetlb")
+	s.CGroupMemoryController = has("memory")
+	s.CGroupNetPrioController = false // No unified equivalent
+	s.CGroupPidsController = has("pids")
+	s.CGroupSwapAccounting = shared.PathExists(filepath.Join(self, "memory.swap.max"))
+	s.CGroupCPUBurst = shared.PathExists(filepath.Join(self, "cpu.max.burst"))
+	s.CGroupIOLatency = shared.PathExists(filepath.Join(self, "io.latency"))
+}
+
+// cGroupV2Self returns the unified hierarchy cgroup of the current process.
+func cGroupV2Self() string {
+	f, err := os.Open("/proc/self/cgroup")
+	if err != nil {
+		return "/"
+	}
+	defer f.Close()
+
+	scan := bufio.NewScanner(f)
+	for scan.Scan() {
+		if strings.HasPrefix(scan.Text(), "0::") {
+			return strings.TrimPrefix(scan.Text(), "0::")
+		}
+	}
+
+	return "/"
 }
 
 func cGroupMissing(name, message string) string {
@@ -47,8 +120,10 @@ var cGroups = []struct {
 	{"cpuset", cGroupMissing("CPUset controller", "CPU pinning will be ignored")},
 	{"devices", cGroupMissing("devices controller", "device access control won't work")},
 	{"freezer", cGroupMissing("freezer controller", "pausing/resuming containers won't work")},
+	{"hugetlb", cGroupMissing("hugetlb controller", "huge page limits will be ignored")},
 	{"memory", cGroupMissing("memory controller", "memory limits will be ignored")},
 	{"net_prio", cGroupMissing("network class controller", "network limits will be ignored")},
 	{"pids", cGroupMissing("pids controller", "process limits will be ignored")},
 	{"memory/memory.memsw.limit_in_bytes", cGroupDisabled("memory swap accounting", "swap limits will be ignored")},
+	{"cpu/cpu.cfs_burst_us", cGroupMissing("CPU burst support", "CPU burst limits will be ignored")},
 }
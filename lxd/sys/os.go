@@ -20,12 +20,23 @@ type InotifyTargetInfo struct {
 	Path string
 }
 
+// InotifyWatcher identifies the unix device of a container waiting for its
+// source path to appear or disappear
+type InotifyWatcher struct {
+	ContainerID int
+	Device      string
+}
+
 // InotifyInfo records the inotify information associated with a given
 // inotify instance
 type InotifyInfo struct {
 	Fd int
 	sync.RWMutex
 	Targets map[string]*InotifyTargetInfo
+
+	// Devices indexes the watchers by the path they wait for, so that an
+	// event only concerns the containers which care about it.
+	Devices map[string][]InotifyWatcher
 }
 
 // OS is a high-level facade for accessing all operating-system
@@ -57,18 +68,26 @@ type OS struct {
 	CGroupBlkioController   bool
 	CGroupCPUacctController bool
 	CGroupCPUController     bool
+	CGroupCPUBurst          bool
 	CGroupCPUsetController  bool
 	CGroupDevicesController bool
 	CGroupFreezerController bool
+	CGroupHugetlbController bool
+	CGroupIOLatency         bool
 	CGroupMemoryController  bool
+	CGroupNamespace         bool
 	CGroupNetPrioController bool
 	CGroupPidsController    bool
 	CGroupSwapAccounting    bool
+	CGroupUnified           bool
+	CGroupV2                bool
 
 	// Kernel features
+	IdmappedMounts  bool
 	NetnsGetifaddrs bool
 	SeccompListener bool
 	Shiftfs         bool
+	TimeNamespace   bool
 	UeventInjection bool
 	VFS3Fscaps      bool
 
@@ -85,6 +104,7 @@ func DefaultOS() *OS {
 	}
 	newOS.InotifyWatch.Fd = -1
 	newOS.InotifyWatch.Targets = make(map[string]*InotifyTargetInfo)
+	newOS.InotifyWatch.Devices = make(map[string][]InotifyWatcher)
 	return newOS
 }
 
@@ -0,0 +1,110 @@
+package util
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/lxc/lxd/shared"
+)
+
+// CompressionAlgorithms are the compressors which can be used, besides
+// "none".
+var CompressionAlgorithms = []string{"bzip2", "gzip", "lzma", "xz", "zstd"}
+
+// compressionName returns the compressor of a compression setting, checking
+// that it's one of CompressionAlgorithms.
+func compressionName(fields []string) (string, error) {
+	if len(fields) == 0 {
+		return "", fmt.Errorf("No compression algorithm specified")
+	}
+
+	if !shared.StringInSlice(fields[0], CompressionAlgorithms) {
+		return "", fmt.Errorf("Unsupported compression algorithm %q, must be one of %s or none", fields[0], strings.Join(CompressionAlgorithms, ", "))
+	}
+
+	return fields[0], nil
+}
+
+// CompressionCommand parses a compression setting, made of the compressor
+// name optionally followed by a level (e.g. "xz -9" or "zstd -19"), and
+// returns the command line compressing stdin to stdout. zstd is
+// multi-threaded unless a thread count is given with -T.
+func CompressionCommand(compress string) (string, []string, error) {
+	fields := strings.Fields(compress)
+	name, err := compressionName(fields)
+	if err != nil {
+		return "", nil, err
+	}
+
+	args := []string{"-c"}
+
+	// Don't store the timestamps in the gzip headers.
+	if name == "gzip" {
+		args = append(args, "-n")
+	}
+
+	threads := false
+	for _, arg := range fields[1:] {
+		if !strings.HasPrefix(arg, "-") {
+			return "", nil, fmt.Errorf("Invalid compression argument %q", arg)
+		}
+
+		if name == "zstd" && strings.HasPrefix(arg, "-T") {
+			_, err := strconv.Atoi(strings.TrimPrefix(arg, "-T"))
+			if err != nil {
+				return "", nil, fmt.Errorf("Invalid zstd thread count %q", arg)
+			}
+
+			threads = true
+			args = append(args, arg)
+			continue
+		}
+
+		level, err := strconv.Atoi(strings.TrimPrefix(arg, "-"))
+		if err != nil || level < 0 {
+			return "", nil, fmt.Errorf("Invalid compression level %q", arg)
+		}
+
+		// zstd levels above 19 need to be enabled explicitly.
+		if name == "zstd" && level > 19 {
+			args = append(args, "--ultra")
+		}
+
+		args = append(args, arg)
+	}
+
+	if name == "zstd" && !threads {
+		args = append(args, "-T0")
+	}
+
+	return name, args, nil
+}
+
+// DecompressionCommand returns the command line decompressing stdin to stdout
+// for data compressed with the given compression setting.
+func DecompressionCommand(compress string) (string, []string, error) {
+	name, err := compressionName(strings.Fields(compress))
+	if err != nil {
+		return "", nil, err
+	}
+
+	return name, []string{"-d", "-c"}, nil
+}
+
+// ValidateCompression checks that a compression setting is either "none" or
+// can be parsed by CompressionCommand with its compressor installed.
+func ValidateCompression(compress string) error {
+	if compress == "none" {
+		return nil
+	}
+
+	name, _, err := CompressionCommand(compress)
+	if err != nil {
+		return err
+	}
+
+	_, err = exec.LookPath(name)
+	return err
+}
@@ -0,0 +1,61 @@
+package util_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lxc/lxd/lxd/util"
+)
+
+func TestCompressionCommand(t *testing.T) {
+	name, args, err := util.CompressionCommand("gzip")
+	assert.NoError(t, err)
+	assert.Equal(t, "gzip", name)
+	assert.Equal(t, []string{"-c", "-n"}, args)
+
+	name, args, err = util.CompressionCommand("xz -9")
+	assert.NoError(t, err)
+	assert.Equal(t, "xz", name)
+	assert.Equal(t, []string{"-c", "-9"}, args)
+
+	_, args, err = util.CompressionCommand("zstd")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"-c", "-T0"}, args)
+
+	_, args, err = util.CompressionCommand("zstd -22 -T4")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"-c", "--ultra", "-22", "-T4"}, args)
+
+	_, _, err = util.CompressionCommand("xz --rm")
+	assert.Error(t, err)
+
+	_, _, err = util.CompressionCommand("zstd 19")
+	assert.Error(t, err)
+
+	_, _, err = util.CompressionCommand("")
+	assert.Error(t, err)
+
+	// Only the known compressors can be used
+	_, _, err = util.CompressionCommand("sh -c")
+	assert.Error(t, err)
+
+	_, _, err = util.CompressionCommand("/usr/bin/gzip")
+	assert.Error(t, err)
+}
+
+func TestValidateCompression(t *testing.T) {
+	assert.NoError(t, util.ValidateCompression("none"))
+	assert.Error(t, util.ValidateCompression("sh"))
+	assert.Error(t, util.ValidateCompression("none -9"))
+}
+
+func TestDecompressionCommand(t *testing.T) {
+	name, args, err := util.DecompressionCommand("zstd -19")
+	assert.NoError(t, err)
+	assert.Equal(t, "zstd", name)
+	assert.Equal(t, []string{"-d", "-c"}, args)
+
+	_, _, err = util.DecompressionCommand("sh")
+	assert.Error(t, err)
+}
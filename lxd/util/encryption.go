@@ -2,6 +2,9 @@ package util
 
 import (
 	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
 	"encoding/hex"
 	"fmt"
 	"io/ioutil"
@@ -39,6 +42,89 @@ func PasswordCheck(secret string, password string) error {
 	return nil
 }
 
+// SecretKeyLoad reads the key used to encrypt the secrets stored in the
+// database from the given var dir. It's kept apart from the server
+// certificate, for either to be replaced without affecting the other.
+func SecretKeyLoad(dir string) ([]byte, error) {
+	key, err := ioutil.ReadFile(filepath.Join(dir, "secrets.key"))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(key) != 32 {
+		return nil, fmt.Errorf("Invalid secrets key size: %d", len(key))
+	}
+
+	return key, nil
+}
+
+// SecretKeyGenerate returns a new random key to encrypt secrets with.
+func SecretKeyGenerate() ([]byte, error) {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// SecretKeyWrite saves the key used to encrypt the secrets stored in the
+// database in the given var dir.
+func SecretKeyWrite(dir string, key []byte) error {
+	return ioutil.WriteFile(filepath.Join(dir, "secrets.key"), key, 0600)
+}
+
+// SecretEncrypt encrypts value with AES-GCM using the given key, returning the
+// hex encoded nonce followed by the ciphertext.
+func SecretEncrypt(key []byte, value string) (string, error) {
+	gcm, err := secretCipher(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(gcm.Seal(nonce, nonce, []byte(value), nil)), nil
+}
+
+// SecretDecrypt decrypts a value encrypted by SecretEncrypt with the same key.
+func SecretDecrypt(key []byte, secret string) (string, error) {
+	gcm, err := secretCipher(key)
+	if err != nil {
+		return "", err
+	}
+
+	buff, err := hex.DecodeString(secret)
+	if err != nil {
+		return "", err
+	}
+
+	if len(buff) < gcm.NonceSize() {
+		return "", fmt.Errorf("Encrypted secret is too short")
+	}
+
+	value, err := gcm.Open(nil, buff[:gcm.NonceSize()], buff[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", errors.Wrap(err, "Failed to decrypt secret")
+	}
+
+	return string(value), nil
+}
+
+func secretCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
 // LoadCert reads the LXD server certificate from the given var dir.
 //
 // If a cluster certificate is found it will be loaded instead.
@@ -0,0 +1,54 @@
+package util_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/lxc/lxd/lxd/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecretEncrypt(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+
+	secret, err := util.SecretEncrypt(key, "hunter2")
+	require.NoError(t, err)
+	assert.NotContains(t, secret, "hunter2")
+
+	// A new nonce is used each time
+	other, err := util.SecretEncrypt(key, "hunter2")
+	require.NoError(t, err)
+	assert.NotEqual(t, secret, other)
+
+	value, err := util.SecretDecrypt(key, secret)
+	require.NoError(t, err)
+	assert.Equal(t, "hunter2", value)
+
+	_, err = util.SecretDecrypt(bytes.Repeat([]byte{2}, 32), secret)
+	assert.Error(t, err)
+
+	_, err = util.SecretDecrypt(key, "00")
+	assert.EqualError(t, err, "Encrypted secret is too short")
+}
+
+func TestSecretKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd-util-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	_, err = util.SecretKeyLoad(dir)
+	assert.True(t, os.IsNotExist(err))
+
+	key, err := util.SecretKeyGenerate()
+	require.NoError(t, err)
+	require.Len(t, key, 32)
+
+	require.NoError(t, util.SecretKeyWrite(dir, key))
+
+	loaded, err := util.SecretKeyLoad(dir)
+	require.NoError(t, err)
+	assert.Equal(t, key, loaded)
+}
@@ -0,0 +1,36 @@
+package api
+
+// ApparmorProfilesPost represents the fields of a new LXD managed AppArmor profile
+//
+// API extension: apparmor_profiles
+type ApparmorProfilesPost struct {
+	ApparmorProfilePut `yaml:",inline"`
+
+	Name string `json:"name" yaml:"name"`
+}
+
+// ApparmorProfilePut represents the modifiable fields of a LXD managed AppArmor profile
+//
+// API extension: apparmor_profiles
+type ApparmorProfilePut struct {
+	Description string `json:"description" yaml:"description"`
+	Content     string `json:"content" yaml:"content"`
+}
+
+// ApparmorProfile represents a LXD managed AppArmor profile
+//
+// API extension: apparmor_profiles
+type ApparmorProfile struct {
+	ApparmorProfilePut `yaml:",inline"`
+
+	Name string `json:"name" yaml:"name"`
+
+	// Containers and profiles setting security.apparmor.profile to it
+	UsedBy []string `json:"used_by" yaml:"used_by"`
+}
+
+// Writable converts a full ApparmorProfile struct into a ApparmorProfilePut struct
+// (filters read-only fields)
+func (profile *ApparmorProfile) Writable() ApparmorProfilePut {
+	return profile.ApparmorProfilePut
+}
@@ -0,0 +1,25 @@
+package api
+
+// BackupUploadsPost represents the fields available for a new backup upload
+// API extension: container_backup_chunks
+type BackupUploadsPost struct {
+	Size      int64    `json:"size" yaml:"size"`
+	ChunkSize int64    `json:"chunk_size" yaml:"chunk_size"`
+	Checksums []string `json:"checksums" yaml:"checksums"`
+}
+
+// BackupUpload represents a backup being uploaded in chunks
+// API extension: container_backup_chunks
+type BackupUpload struct {
+	BackupUploadsPost `yaml:",inline"`
+
+	ID      string `json:"id" yaml:"id"`
+	Missing []int  `json:"missing" yaml:"missing"`
+}
+
+// BackupUploadPost represents the fields required to create a container from
+// a complete backup upload
+// API extension: container_backup_chunks
+type BackupUploadPost struct {
+	Pool string `json:"pool" yaml:"pool"`
+}
@@ -30,6 +30,9 @@ type ContainerPost struct {
 
 	// API extension: container_push_target
 	Target *ContainerPostTarget `json:"target" yaml:"target"`
+
+	// API extension: migration_freeze_sync
+	FreezeSync bool `json:"freeze_sync" yaml:"freeze_sync"`
 }
 
 // ContainerPostTarget represents the migration target host and operation
@@ -73,6 +76,32 @@ type Container struct {
 
 	// API extension: clustering
 	Location string `json:"location" yaml:"location"`
+
+	// API extension: container_activity
+	Activity ContainerActivity `json:"activity" yaml:"activity"`
+}
+
+// ContainerActivity represents the latest interactions with a container
+//
+// API extension: container_activity
+type ContainerActivity struct {
+	LastExecAt    time.Time `json:"last_exec_at" yaml:"last_exec_at"`
+	LastConsoleAt time.Time `json:"last_console_at" yaml:"last_console_at"`
+	LastFileAt    time.Time `json:"last_file_at" yaml:"last_file_at"`
+
+	// Last time the network traffic of the container went above the activity threshold
+	LastNetworkAt time.Time `json:"last_network_at" yaml:"last_network_at"`
+}
+
+// ProjectActivity represents the latest interactions with the containers of a project
+//
+// API extension: container_activity
+type ProjectActivity struct {
+	// Latest interactions with each container, by name
+	Containers map[string]ContainerActivity `json:"containers" yaml:"containers"`
+
+	// Latest interaction with any of them
+	LastActivityAt time.Time `json:"last_activity_at" yaml:"last_activity_at"`
 }
 
 // ContainerFull is a combination of Container, ContainerState and CotnainerSnapshot
@@ -138,4 +167,179 @@ type ContainerSource struct {
 
 	// API extension: container_copy_project
 	Project string `json:"project,omitempty" yaml:"project,omitempty"`
+
+	// API extension: container_copy_cow
+	Cow bool `json:"cow,omitempty" yaml:"cow,omitempty"`
+}
+
+// ContainerFilesSyncPost represents a request to copy a path from another
+// container on the same node
+//
+// API extension: container_files_sync
+type ContainerFilesSyncPost struct {
+	// Name of the container to copy from, in the same project
+	Source     string `json:"source" yaml:"source"`
+	SourcePath string `json:"source_path" yaml:"source_path"`
+
+	// Destination path in the container
+	Path string `json:"path" yaml:"path"`
+}
+
+// ContainerNetworkReapplyPost represents a request to set up the host side of
+// the network devices of a running container again
+//
+// API extension: container_network_reapply
+type ContainerNetworkReapplyPost struct {
+	// Stop and start the devices rather than re-applying them in place
+	Restart bool `json:"restart" yaml:"restart"`
+}
+
+// ContainerRebuildPost represents a request to replace the root filesystem of
+// a container with the one of another image
+//
+// API extension: container_rebuild
+type ContainerRebuildPost struct {
+	Source ContainerSource `json:"source" yaml:"source"`
+}
+
+// ContainerRespawnPost represents a request to reset a container to its image
+// and start it again
+//
+// API extension: container_respawn
+type ContainerRespawnPost struct {
+	// Number of seconds to wait for a clean shutdown (the container is killed by default)
+	Timeout int `json:"timeout" yaml:"timeout"`
+}
+
+// ContainerRevision represents a recorded configuration of a container
+//
+// API extension: container_revisions
+type ContainerRevision struct {
+	Revision  int       `json:"revision" yaml:"revision"`
+	CreatedAt time.Time `json:"created_at" yaml:"created_at"`
+
+	// Who made the change (empty for the configuration preceding the first recorded update)
+	Requester string `json:"requester" yaml:"requester"`
+
+	Architecture string                       `json:"architecture" yaml:"architecture"`
+	Config       map[string]string            `json:"config" yaml:"config"`
+	Devices      map[string]map[string]string `json:"devices" yaml:"devices"`
+	Ephemeral    bool                         `json:"ephemeral" yaml:"ephemeral"`
+	Profiles     []string                     `json:"profiles" yaml:"profiles"`
+	Description  string                       `json:"description" yaml:"description"`
+
+	// API extension: container_config_snapshots
+	Snapshot string `json:"snapshot" yaml:"snapshot"`
+}
+
+// ContainerRevisionsPost represents a request to roll a container back to one
+// of its recorded configurations
+//
+// API extension: container_revisions
+type ContainerRevisionsPost struct {
+	Revision int `json:"revision" yaml:"revision"`
+}
+
+// ContainerConfigSnapshot represents a named copy of the configuration of a
+// container, without its data
+//
+// API extension: container_config_snapshots
+type ContainerConfigSnapshot struct {
+	Name        string    `json:"name" yaml:"name"`
+	CreatedAt   time.Time `json:"created_at" yaml:"created_at"`
+	Description string    `json:"description" yaml:"description"`
+
+	// The container revision kept by the snapshot
+	Revision int `json:"revision" yaml:"revision"`
+
+	Config   map[string]string            `json:"config" yaml:"config"`
+	Devices  map[string]map[string]string `json:"devices" yaml:"devices"`
+	Profiles []string                     `json:"profiles" yaml:"profiles"`
+}
+
+// ContainerConfigSnapshotsPost represents a request to save the current
+// configuration of a container
+//
+// API extension: container_config_snapshots
+type ContainerConfigSnapshotsPost struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description" yaml:"description"`
+}
+
+// ContainerCheckpoint represents a named checkpoint of a container, referencing
+// the snapshot holding its root filesystem and configuration
+//
+// API extension: container_checkpoint_tags
+type ContainerCheckpoint struct {
+	Name        string    `json:"name" yaml:"name"`
+	CreatedAt   time.Time `json:"created_at" yaml:"created_at"`
+	Description string    `json:"description" yaml:"description"`
+
+	// Name of the snapshot referenced by the checkpoint
+	Snapshot string `json:"snapshot" yaml:"snapshot"`
+}
+
+// ContainerCheckpointsPost represents a request to tag the current state of a
+// container as a checkpoint
+//
+// API extension: container_checkpoint_tags
+type ContainerCheckpointsPost struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description" yaml:"description"`
+}
+
+// ContainerSecretPut represents a request to set or rotate a secret
+// environment variable of a container
+//
+// API extension: container_secrets
+type ContainerSecretPut struct {
+	Value string `json:"value" yaml:"value"`
+}
+
+// ContainerSecurityTestPost represents a request to try a security policy on
+// a running container without enforcing it
+//
+// API extension: container_security_test
+type ContainerSecurityTestPost struct {
+	// Proposed configuration (only raw.apparmor is supported)
+	Config map[string]string `json:"config" yaml:"config"`
+
+	// Number of seconds to run the test for
+	Duration int `json:"duration" yaml:"duration"`
+}
+
+// ContainerSecurityTestDenial represents an access which the tested policy
+// would have denied
+//
+// API extension: container_security_test
+type ContainerSecurityTestDenial struct {
+	Operation string `json:"operation" yaml:"operation"`
+	Profile   string `json:"profile" yaml:"profile"`
+	Name      string `json:"name" yaml:"name"`
+	Mask      string `json:"mask" yaml:"mask"`
+	Command   string `json:"command" yaml:"command"`
+
+	// Number of times the access was attempted
+	Count int `json:"count" yaml:"count"`
+}
+
+// ContainerSecurityDenial represents an access denied to a container by its
+// AppArmor or seccomp policy
+//
+// API extension: container_security_denials
+type ContainerSecurityDenial struct {
+	Timestamp time.Time `json:"timestamp" yaml:"timestamp"`
+
+	// Either apparmor or seccomp
+	Type string `json:"type" yaml:"type"`
+
+	Operation string `json:"operation" yaml:"operation"`
+	Profile   string `json:"profile" yaml:"profile"`
+
+	// Path or capability for AppArmor, syscall number for seccomp
+	Name string `json:"name" yaml:"name"`
+
+	Mask    string `json:"mask" yaml:"mask"`
+	Command string `json:"command" yaml:"command"`
+	PID     int    `json:"pid" yaml:"pid"`
 }
@@ -0,0 +1,35 @@
+package api
+
+// ContainerApplyPost represents the desired state of a LXD container
+//
+// API extension: container_apply
+type ContainerApplyPost struct {
+	Config      map[string]string            `json:"config" yaml:"config"`
+	Devices     map[string]map[string]string `json:"devices" yaml:"devices"`
+	Ephemeral   bool                         `json:"ephemeral" yaml:"ephemeral"`
+	Profiles    []string                     `json:"profiles" yaml:"profiles"`
+	Description string                       `json:"description" yaml:"description"`
+
+	// Either "running", "stopped" or empty to keep the current state
+	State string `json:"state" yaml:"state"`
+
+	// Only compute the plan, without applying it
+	DryRun bool `json:"dry_run" yaml:"dry_run"`
+}
+
+// ContainerApplyStep represents one of the operations needed to bring a LXD
+// container to its desired state
+//
+// API extension: container_apply
+type ContainerApplyStep struct {
+	// One of "update", "stop", "restart" or "start"
+	Action string `json:"action" yaml:"action"`
+
+	// The changed config keys (config.<key>), devices (devices.<name>) and
+	// other properties requiring this step
+	Changes []string `json:"changes" yaml:"changes"`
+
+	// One of "planned", "pending", "done", "failed" or "skipped"
+	Status string `json:"status" yaml:"status"`
+	Error  string `json:"error" yaml:"error"`
+}
@@ -9,6 +9,12 @@ type ContainerBackupsPost struct {
 	ExpiresAt        time.Time `json:"expires_at" yaml:"expires_at"`
 	ContainerOnly    bool      `json:"container_only" yaml:"container_only"`
 	OptimizedStorage bool      `json:"optimized_storage" yaml:"optimized_storage"`
+
+	// API extension: container_backup_compression
+	CompressionAlgorithm string `json:"compression_algorithm" yaml:"compression_algorithm"`
+
+	// API extension: container_backup_chunks
+	ChunkSize int64 `json:"chunk_size" yaml:"chunk_size"`
 }
 
 // ContainerBackup represents a LXD container backup
@@ -19,6 +25,17 @@ type ContainerBackup struct {
 	ExpiresAt        time.Time `json:"expires_at" yaml:"expires_at"`
 	ContainerOnly    bool      `json:"container_only" yaml:"container_only"`
 	OptimizedStorage bool      `json:"optimized_storage" yaml:"optimized_storage"`
+
+	// API extension: container_backup_chunks
+	Chunks *ContainerBackupChunks `json:"chunks,omitempty" yaml:"chunks,omitempty"`
+}
+
+// ContainerBackupChunks represents the chunks a backup can be exported in
+// API extension: container_backup_chunks
+type ContainerBackupChunks struct {
+	Size      int64    `json:"size" yaml:"size"`
+	ChunkSize int64    `json:"chunk_size" yaml:"chunk_size"`
+	Checksums []string `json:"checksums" yaml:"checksums"`
 }
 
 // ContainerBackupPost represents the fields available for the renaming of a
@@ -0,0 +1,25 @@
+package api
+
+import (
+	"time"
+)
+
+// ContainerDeviceLogEntry represents a device change on a running container
+//
+// API extension: container_device_log
+type ContainerDeviceLogEntry struct {
+	Timestamp time.Time `json:"timestamp" yaml:"timestamp"`
+
+	// Name and type of the device
+	Device string `json:"device" yaml:"device"`
+	Type   string `json:"type" yaml:"type"`
+
+	// One of "add", "remove" or "update"
+	Action string `json:"action" yaml:"action"`
+
+	// Either "config" for configuration changes or "hotplug" for host events
+	Source string `json:"source" yaml:"source"`
+
+	// Error message if the change failed
+	Error string `json:"error" yaml:"error"`
+}
@@ -1,5 +1,9 @@
 package api
 
+import (
+	"time"
+)
+
 // ContainerExecControl represents a message on the container exec "control" socket
 type ContainerExecControl struct {
 	Command string            `json:"command" yaml:"command"`
@@ -24,3 +28,26 @@ type ContainerExecPost struct {
 	Group uint32 `json:"group" yaml:"group"`
 	Cwd   string `json:"cwd" yaml:"cwd"`
 }
+
+// ContainerExecSession represents a running exec session in a container
+//
+// API extension: container_exec_sessions
+type ContainerExecSession struct {
+	ID        string                    `json:"id" yaml:"id"`
+	Command   []string                  `json:"command" yaml:"command"`
+	Pid       int                       `json:"pid" yaml:"pid"`
+	CreatedAt time.Time                 `json:"created_at" yaml:"created_at"`
+	Usage     ContainerExecSessionUsage `json:"usage" yaml:"usage"`
+}
+
+// ContainerExecSessionUsage represents the resources consumed by an exec session
+//
+// API extension: container_exec_sessions
+type ContainerExecSessionUsage struct {
+	// Memory usage in bytes, -1 if unavailable
+	MemoryUsage     int64 `json:"memory_usage" yaml:"memory_usage"`
+	MemoryUsagePeak int64 `json:"memory_usage_peak" yaml:"memory_usage_peak"`
+
+	// CPU time in nanoseconds, -1 if unavailable
+	CPUUsage int64 `json:"cpu_usage" yaml:"cpu_usage"`
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"time"
+)
+
+// ContainerProcesses represents the processes of a container along with
+// their host PIDs
+//
+// API extension: container_host_pidns_view
+type ContainerProcesses struct {
+	// Time at which the processes were last looked up
+	UpdatedAt time.Time `json:"updated_at" yaml:"updated_at"`
+
+	Processes []ContainerProcess `json:"processes" yaml:"processes"`
+}
+
+// ContainerProcess represents a process of a container
+//
+// API extension: container_host_pidns_view
+type ContainerProcess struct {
+	// PIDs as seen from the container and from the host
+	PID     int `json:"pid" yaml:"pid"`
+	HostPID int `json:"host_pid" yaml:"host_pid"`
+
+	// Parent PID as seen from the container (0 for the container's init)
+	PPID int `json:"ppid" yaml:"ppid"`
+
+	Command string                   `json:"command" yaml:"command"`
+	Threads []ContainerProcessThread `json:"threads" yaml:"threads"`
+}
+
+// ContainerProcessThread represents a thread of a container process
+//
+// API extension: container_host_pidns_view
+type ContainerProcessThread struct {
+	// Thread IDs as seen from the container and from the host
+	TID     int `json:"tid" yaml:"tid"`
+	HostTID int `json:"host_tid" yaml:"host_tid"`
+}
+
+// ContainerMonitor represents the LXC monitor process of a container
+//
+// API extension: container_monitor
+type ContainerMonitor struct {
+	// Host PID of the monitor (0 if there's none)
+	PID int `json:"pid" yaml:"pid"`
+
+	// One of "running", "orphaned" (no container processes left), "zombie" or "missing"
+	Status string `json:"status" yaml:"status"`
+
+	// Number of direct child processes of the monitor
+	Children int `json:"children" yaml:"children"`
+
+	// Time at which the monitor was last checked by the periodic sweep
+	CheckedAt time.Time `json:"checked_at" yaml:"checked_at"`
+}
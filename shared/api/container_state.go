@@ -1,11 +1,18 @@
 package api
 
+import (
+	"time"
+)
+
 // ContainerStatePut represents the modifiable fields of a LXD container's state
 type ContainerStatePut struct {
 	Action   string `json:"action" yaml:"action"`
 	Timeout  int    `json:"timeout" yaml:"timeout"`
 	Force    bool   `json:"force" yaml:"force"`
 	Stateful bool   `json:"stateful" yaml:"stateful"`
+
+	// API extension: container_freeze_timeout
+	FreezeTimeout int `json:"freeze_timeout" yaml:"freeze_timeout"`
 }
 
 // ContainerState represents a LXD container's state
@@ -20,11 +27,58 @@ type ContainerState struct {
 
 	// API extension: container_cpu_time
 	CPU ContainerStateCPU `json:"cpu" yaml:"cpu"`
+
+	// API extension: container_exec_sessions_limit
+	ExecSessions int `json:"exec_sessions" yaml:"exec_sessions"`
+
+	// Whether the container is isolated in its own core scheduling group
+	// API extension: container_core_scheduling
+	CoreScheduling bool `json:"core_scheduling" yaml:"core_scheduling"`
+
+	// Why the container last failed to start, cleared once it starts
+	// API extension: container_start_error
+	StartError *ContainerStateStartError `json:"start_error" yaml:"start_error"`
+}
+
+// ContainerStateStartError represents the last failure to start a LXD container
+//
+// API extension: container_start_error
+type ContainerStateStartError struct {
+	Message string    `json:"message" yaml:"message"`
+	Log     []string  `json:"log" yaml:"log"`
+	Time    time.Time `json:"time" yaml:"time"`
 }
 
 // ContainerStateDisk represents the disk information section of a LXD container's state
 type ContainerStateDisk struct {
 	Usage int64 `json:"usage" yaml:"usage"`
+
+	// Filesystem usage as seen from inside the running container
+	// API extension: container_disk_usage_warning
+	Path        string `json:"path" yaml:"path"`
+	Total       int64  `json:"total" yaml:"total"`
+	Available   int64  `json:"available" yaml:"available"`
+	InodesUsage int64  `json:"inodes_usage" yaml:"inodes_usage"`
+	InodesTotal int64  `json:"inodes_total" yaml:"inodes_total"`
+	Warning     bool   `json:"warning" yaml:"warning"`
+
+	// I/O latency of the block devices backing the disk
+	// API extension: container_disk_latency
+	Latency *ContainerStateDiskLatency `json:"latency,omitempty" yaml:"latency,omitempty"`
+}
+
+// ContainerStateDiskLatency represents the I/O latency of the block devices backing a disk
+//
+// API extension: container_disk_latency
+type ContainerStateDiskLatency struct {
+	// Latency target, in microseconds
+	Target int64 `json:"target" yaml:"target"`
+
+	// Time the I/O of the container was delayed to meet the targets of others, in nanoseconds
+	Delay int64 `json:"delay" yaml:"delay"`
+
+	// Average I/O latency, in microseconds (only reported by kernels with cgroup debug statistics)
+	Average int64 `json:"average" yaml:"average"`
 }
 
 // ContainerStateCPU represents the cpu information section of a LXD container's state
@@ -32,6 +86,15 @@ type ContainerStateDisk struct {
 // API extension: container_cpu_time
 type ContainerStateCPU struct {
 	Usage int64 `json:"usage" yaml:"usage"`
+
+	// CPU time spent in user and system mode, in nanoseconds
+	// API extension: container_cpu_usage_breakdown
+	User   int64 `json:"user" yaml:"user"`
+	System int64 `json:"system" yaml:"system"`
+
+	// CPU usage of each CPU, in nanoseconds
+	// API extension: container_cpu_usage_breakdown
+	PerCPU []int64 `json:"per_cpu" yaml:"per_cpu"`
 }
 
 // ContainerStateMemory represents the memory information section of a LXD container's state
@@ -40,6 +103,12 @@ type ContainerStateMemory struct {
 	UsagePeak     int64 `json:"usage_peak" yaml:"usage_peak"`
 	SwapUsage     int64 `json:"swap_usage" yaml:"swap_usage"`
 	SwapUsagePeak int64 `json:"swap_usage_peak" yaml:"swap_usage_peak"`
+
+	// Processes killed by the kernel for running out of memory, and when
+	// it last happened
+	// API extension: container_oom_events
+	OOMKills int64      `json:"oom_kills" yaml:"oom_kills"`
+	LastOOM  *time.Time `json:"last_oom,omitempty" yaml:"last_oom,omitempty"`
 }
 
 // ContainerStateNetwork represents the network information section of a LXD container's state
@@ -51,6 +120,17 @@ type ContainerStateNetwork struct {
 	Mtu       int                            `json:"mtu" yaml:"mtu"`
 	State     string                         `json:"state" yaml:"state"`
 	Type      string                         `json:"type" yaml:"type"`
+
+	// API extension: container_network_conntrack
+	Conntrack int64 `json:"conntrack" yaml:"conntrack"`
+
+	// Link details, from the sysfs of the container
+	// API extension: container_network_link_state
+	Carrier bool                         `json:"carrier" yaml:"carrier"`
+	Speed   int64                        `json:"speed" yaml:"speed"`
+	Master  string                       `json:"master" yaml:"master"`
+	Bond    *ContainerStateNetworkBond   `json:"bond" yaml:"bond"`
+	Bridge  *ContainerStateNetworkBridge `json:"bridge" yaml:"bridge"`
 }
 
 // ContainerStateNetworkAddress represents a network address as part of the network section of a LXD container's state
@@ -67,4 +147,27 @@ type ContainerStateNetworkCounters struct {
 	BytesSent       int64 `json:"bytes_sent" yaml:"bytes_sent"`
 	PacketsReceived int64 `json:"packets_received" yaml:"packets_received"`
 	PacketsSent     int64 `json:"packets_sent" yaml:"packets_sent"`
+
+	// API extension: container_network_link_state
+	ErrorsReceived         int64 `json:"errors_received" yaml:"errors_received"`
+	ErrorsSent             int64 `json:"errors_sent" yaml:"errors_sent"`
+	PacketsDroppedInbound  int64 `json:"packets_dropped_inbound" yaml:"packets_dropped_inbound"`
+	PacketsDroppedOutbound int64 `json:"packets_dropped_outbound" yaml:"packets_dropped_outbound"`
+}
+
+// ContainerStateNetworkBond represents the bonding details of a network interface as part of the
+// network section of a LXD container's state
+//
+// API extension: container_network_link_state
+type ContainerStateNetworkBond struct {
+	Mode    string   `json:"mode" yaml:"mode"`
+	Members []string `json:"members" yaml:"members"`
+}
+
+// ContainerStateNetworkBridge represents the bridging details of a network interface as part of the
+// network section of a LXD container's state
+//
+// API extension: container_network_link_state
+type ContainerStateNetworkBridge struct {
+	Members []string `json:"members" yaml:"members"`
 }
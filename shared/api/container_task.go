@@ -0,0 +1,30 @@
+package api
+
+import (
+	"time"
+)
+
+// ContainerTask represents a periodic command defined in the container configuration
+//
+// API extension: container_tasks
+type ContainerTask struct {
+	Name     string             `json:"name" yaml:"name"`
+	Command  string             `json:"command" yaml:"command"`
+	Schedule string             `json:"schedule" yaml:"schedule"`
+	Running  bool               `json:"running" yaml:"running"`
+	History  []ContainerTaskRun `json:"history" yaml:"history"`
+}
+
+// ContainerTaskRun represents a past run of a container task
+//
+// API extension: container_tasks
+type ContainerTaskRun struct {
+	Operation  string    `json:"operation" yaml:"operation"`
+	CreatedAt  time.Time `json:"created_at" yaml:"created_at"`
+	FinishedAt time.Time `json:"finished_at" yaml:"finished_at"`
+	Return     int       `json:"return" yaml:"return"`
+	Error      string    `json:"error" yaml:"error"`
+
+	// Log files holding the output, indexed by file descriptor
+	Output map[string]string `json:"output" yaml:"output"`
+}
@@ -0,0 +1,18 @@
+package api
+
+// DatabaseBackup represents a cluster database backup stored on the
+// configured external target, as validated by the server
+//
+// API extension: database_backups
+type DatabaseBackup struct {
+	Name string `json:"name" yaml:"name"`
+
+	// Version of the database schema in the backup
+	SchemaVersion int `json:"schema_version" yaml:"schema_version"`
+
+	// Whether the backup includes raft snapshot files
+	RaftSnapshot bool `json:"raft_snapshot" yaml:"raft_snapshot"`
+
+	// Number of rows in the main tables of the backup
+	Entities map[string]int `json:"entities" yaml:"entities"`
+}
@@ -30,3 +30,33 @@ type EventLifecycle struct {
 	Source  string                 `yaml:"source" json:"source"`
 	Context map[string]interface{} `yaml:"context,omitempty" json:"context,omitempty"`
 }
+
+// EventResources represents the resource usage of a container since the previous event
+//
+// API extension: event_resources
+type EventResources struct {
+	Project   string `yaml:"project" json:"project"`
+	Container string `yaml:"container" json:"container"`
+
+	// Time covered by the event, in nanoseconds
+	Interval int64 `yaml:"interval" json:"interval"`
+
+	// CPU time used during the interval, in nanoseconds
+	CPUUsage int64 `yaml:"cpu_usage" json:"cpu_usage"`
+
+	// Current memory usage, in bytes
+	MemoryUsage int64 `yaml:"memory_usage" json:"memory_usage"`
+
+	// Network traffic during the interval, in bytes
+	NetworkBytesReceived int64 `yaml:"network_bytes_received" json:"network_bytes_received"`
+	NetworkBytesSent     int64 `yaml:"network_bytes_sent" json:"network_bytes_sent"`
+}
+
+// EventConsole represents the output written to the console of a container since the previous event
+//
+// API extension: event_console
+type EventConsole struct {
+	Project   string `yaml:"project" json:"project"`
+	Container string `yaml:"container" json:"container"`
+	Output    string `yaml:"output" json:"output"`
+}
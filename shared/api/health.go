@@ -0,0 +1,19 @@
+package api
+
+// Health represents the health of a LXD daemon
+//
+// API extension: daemon_health
+type Health struct {
+	Status string `json:"status" yaml:"status"`
+
+	// Per-subsystem checks (only reported by the internal endpoint)
+	Checks map[string]HealthCheck `json:"checks,omitempty" yaml:"checks,omitempty"`
+}
+
+// HealthCheck represents the status of a single daemon subsystem
+//
+// API extension: daemon_health
+type HealthCheck struct {
+	Status  string `json:"status" yaml:"status"`
+	Message string `json:"message,omitempty" yaml:"message,omitempty"`
+}
@@ -16,6 +16,12 @@ type ImagesPost struct {
 
 	// API extension: image_create_aliases
 	Aliases []ImageAlias `json:"aliases" yaml:"aliases"`
+
+	// API extension: image_publish_profile
+	Profile string `json:"profile" yaml:"profile"`
+
+	// API extension: image_delta
+	Parent string `json:"parent" yaml:"parent"`
 }
 
 // ImagesPostSource represents the source of a new LXD image
@@ -119,3 +125,37 @@ type ImageMetadataTemplate struct {
 	Template   string            `json:"template" yaml:"template"`
 	Properties map[string]string `json:"properties" yaml:"properties"`
 }
+
+// ImageDownloads represents the image download statistics of a LXD node
+//
+// API extension: image_delta_stats
+type ImageDownloads struct {
+	Downloads       int64 `json:"downloads" yaml:"downloads"`
+	DeltaDownloads  int64 `json:"delta_downloads" yaml:"delta_downloads"`
+	BytesDownloaded int64 `json:"bytes_downloaded" yaml:"bytes_downloaded"`
+	BytesSaved      int64 `json:"bytes_saved" yaml:"bytes_saved"`
+}
+
+// ImageCatalog represents a set of images, with their aliases and properties,
+// to be moved to another server
+//
+// API extension: images_catalog
+type ImageCatalog struct {
+	Images []Image `json:"images" yaml:"images"`
+}
+
+// ImageCatalogPost represents a request to import the image catalog of
+// another LXD server
+//
+// API extension: images_catalog
+type ImageCatalogPost struct {
+	// Address and certificate of the server to import from, which must trust this one
+	Server      string `json:"server" yaml:"server"`
+	Certificate string `json:"certificate" yaml:"certificate"`
+
+	// Project of the source server to import from (defaults to "default")
+	Project string `json:"project" yaml:"project"`
+
+	// Only import the images matching those fingerprint prefixes (all by default)
+	Fingerprints []string `json:"fingerprints" yaml:"fingerprints"`
+}
@@ -0,0 +1,46 @@
+package api
+
+// LoadBalancersPost represents the fields of a new LXD load-balancer
+//
+// API extension: load_balancers
+type LoadBalancersPost struct {
+	LoadBalancerPut `yaml:",inline"`
+
+	Name string `json:"name" yaml:"name"`
+}
+
+// LoadBalancerPut represents the modifiable fields of a LXD load-balancer
+//
+// API extension: load_balancers
+type LoadBalancerPut struct {
+	Config      map[string]string `json:"config" yaml:"config"`
+	Description string            `json:"description" yaml:"description"`
+}
+
+// LoadBalancer represents a LXD load-balancer
+//
+// API extension: load_balancers
+type LoadBalancer struct {
+	LoadBalancerPut `yaml:",inline"`
+
+	Name string `json:"name" yaml:"name"`
+
+	// Members as currently seen by the node serving the request
+	Members []LoadBalancerMember `json:"members" yaml:"members"`
+}
+
+// LoadBalancerMember represents a container connections are distributed to
+//
+// API extension: load_balancers
+type LoadBalancerMember struct {
+	Name    string `json:"name" yaml:"name"`
+	Address string `json:"address" yaml:"address"`
+	Weight  int    `json:"weight" yaml:"weight"`
+	Healthy bool   `json:"healthy" yaml:"healthy"`
+}
+
+// Writable converts a full LoadBalancer struct into a LoadBalancerPut struct
+// (filters read-only fields)
+func (lb *LoadBalancer) Writable() LoadBalancerPut {
+	return lb.LoadBalancerPut
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"time"
+)
+
+// MAASSyncEntry represents a MAAS record update which failed and is pending a retry
+//
+// API extension: maas_reconcile
+type MAASSyncEntry struct {
+	Name        string    `json:"name" yaml:"name"`
+	Project     string    `json:"project" yaml:"project"`
+	Container   string    `json:"container" yaml:"container"`
+	Action      string    `json:"action" yaml:"action"`
+	Attempts    int       `json:"attempts" yaml:"attempts"`
+	LastError   string    `json:"last_error" yaml:"last_error"`
+	LastAttempt time.Time `json:"last_attempt" yaml:"last_attempt"`
+	NextAttempt time.Time `json:"next_attempt" yaml:"next_attempt"`
+
+	// Whether the record failed to sync too many times in a row
+	Persistent bool `json:"persistent" yaml:"persistent"`
+}
+
+// MAASReconcilePost represents the fields of a MAAS reconciliation request
+//
+// API extension: maas_reconcile
+type MAASReconcilePost struct {
+	// Queue the mismatches found for synchronization
+	Fix bool `json:"fix" yaml:"fix"`
+}
+
+// MAASMismatch represents a difference between the containers and the MAAS records
+//
+// API extension: maas_reconcile
+type MAASMismatch struct {
+	Name      string `json:"name" yaml:"name"`
+	Project   string `json:"project" yaml:"project"`
+	Container string `json:"container" yaml:"container"`
+
+	// Either "missing" (no MAAS record for the container) or "stale"
+	// (MAAS record without a matching container)
+	Issue string `json:"issue" yaml:"issue"`
+}
@@ -0,0 +1,21 @@
+package api
+
+// MetadataConfiguration represents the configuration keys supported by a LXD server
+//
+// API extension: metadata_configuration
+type MetadataConfiguration struct {
+	// Map of entity type (container, network, storage-pool) to supported keys
+	Configs map[string]map[string]MetadataConfigurationKey `json:"configs" yaml:"configs"`
+}
+
+// MetadataConfigurationKey represents a single supported configuration key
+//
+// API extension: metadata_configuration
+type MetadataConfigurationKey struct {
+	Type         string `json:"type" yaml:"type"`
+	Default      string `json:"default" yaml:"default"`
+	Condition    string `json:"condition,omitempty" yaml:"condition,omitempty"`
+	LiveUpdate   string `json:"live_update,omitempty" yaml:"live_update,omitempty"`
+	APIExtension string `json:"api_extension,omitempty" yaml:"api_extension,omitempty"`
+	Description  string `json:"description" yaml:"description"`
+}
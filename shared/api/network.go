@@ -87,3 +87,46 @@ type NetworkStateCounters struct {
 	PacketsReceived int64 `json:"packets_received" yaml:"packets_received"`
 	PacketsSent     int64 `json:"packets_sent" yaml:"packets_sent"`
 }
+
+// NetworkTopology represents the graph of the networks, cluster members,
+// containers and NICs
+//
+// API extension: network_topology
+type NetworkTopology struct {
+	Nodes []NetworkTopologyNode `json:"nodes" yaml:"nodes"`
+	Edges []NetworkTopologyEdge `json:"edges" yaml:"edges"`
+}
+
+// NetworkTopologyNode represents an element of the network topology
+//
+// API extension: network_topology
+type NetworkTopologyNode struct {
+	// Unique identifier within the topology (e.g. "nic/default/c1/eth0")
+	ID string `json:"id" yaml:"id"`
+
+	// One of "member", "network", "interface", "container" or "nic"
+	Type string `json:"type" yaml:"type"`
+
+	Name     string `json:"name" yaml:"name"`
+	Project  string `json:"project" yaml:"project"`
+	Location string `json:"location" yaml:"location"`
+
+	// Configuration of networks and NICs (including the filtering keys)
+	Config map[string]string `json:"config" yaml:"config"`
+
+	// Addresses of NICs, static, allocated or leased
+	Addresses []string `json:"addresses" yaml:"addresses"`
+}
+
+// NetworkTopologyEdge represents a link between two elements of the network topology
+//
+// API extension: network_topology
+type NetworkTopologyEdge struct {
+	Source string `json:"source" yaml:"source"`
+	Target string `json:"target" yaml:"target"`
+
+	// One of "runs-on" (container to member), "has" (container to NIC),
+	// "connects-to" (NIC to network or interface) and "available-on"
+	// (network or interface to member)
+	Type string `json:"type" yaml:"type"`
+}
@@ -29,6 +29,29 @@ type Profile struct {
 	UsedBy []string `json:"used_by" yaml:"used_by"`
 }
 
+// ProfileInstancesRestartPost represents a rolling restart of the containers
+// using a profile
+//
+// API extension: profile_rolling_restart
+type ProfileInstancesRestartPost struct {
+	// Number of containers restarted at once (defaults to 1)
+	Concurrency int `json:"concurrency" yaml:"concurrency"`
+
+	// Seconds to wait between two batches
+	Delay int `json:"delay" yaml:"delay"`
+
+	// Seconds to wait for a clean shutdown before killing the container
+	Timeout int  `json:"timeout" yaml:"timeout"`
+	Force   bool `json:"force" yaml:"force"`
+
+	// Command run in each restarted container which must succeed before
+	// moving on to the next batch
+	HealthCheck []string `json:"health_check" yaml:"health_check"`
+
+	// Seconds to retry the health check for (defaults to 60)
+	HealthCheckTimeout int `json:"health_check_timeout" yaml:"health_check_timeout"`
+}
+
 // Writable converts a full Profile struct into a ProfilePut struct (filters read-only fields)
 func (profile *Profile) Writable() ProfilePut {
 	return profile.ProfilePut
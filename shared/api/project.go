@@ -24,6 +24,14 @@ type ProjectPut struct {
 	Config      map[string]string `json:"config" yaml:"config"`
 }
 
+// ProjectContainersProtectionPut represents the protection flags to set on all the containers of
+// a LXD project
+//
+// API extension: container_protection_start
+type ProjectContainersProtectionPut struct {
+	Start bool `json:"start" yaml:"start"`
+}
+
 // Project represents a LXD project
 //
 // API extension: projects
@@ -266,6 +266,9 @@ type ResourcesMemoryNode struct {
 type ResourcesStoragePool struct {
 	Space  ResourcesStoragePoolSpace  `json:"space,omitempty" yaml:"space,omitempty"`
 	Inodes ResourcesStoragePoolInodes `json:"inodes,omitempty" yaml:"inodes,omitempty"`
+
+	// API extension: storage_pool_operations_limit
+	Operations *ResourcesStoragePoolOperations `json:"operations,omitempty" yaml:"operations,omitempty"`
 }
 
 // ResourcesStoragePoolSpace represents the space available to a given storage pool
@@ -275,6 +278,17 @@ type ResourcesStoragePoolSpace struct {
 	Total uint64 `json:"total" yaml:"total"`
 }
 
+// ResourcesStoragePoolOperations represents the storage operations running or
+// waiting on a given storage pool
+// API extension: storage_pool_operations_limit
+type ResourcesStoragePoolOperations struct {
+	Running uint64 `json:"running" yaml:"running"`
+	Queued  uint64 `json:"queued" yaml:"queued"`
+
+	// Maximum number of concurrent operations, 0 if unlimited
+	Limit uint64 `json:"limit" yaml:"limit"`
+}
+
 // ResourcesStoragePoolInodes represents the inodes available to a given storage pool
 // API extension: resources
 type ResourcesStoragePoolInodes struct {
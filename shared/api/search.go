@@ -0,0 +1,25 @@
+package api
+
+// SearchResult represents a container matching a search query
+//
+// API extension: search
+type SearchResult struct {
+	Project  string `json:"project" yaml:"project"`
+	Name     string `json:"name" yaml:"name"`
+	Location string `json:"location" yaml:"location"`
+
+	// What matched the query, one of "name", "address" or "hwaddr"
+	Match string `json:"match" yaml:"match"`
+
+	Interfaces []SearchResultInterface `json:"interfaces" yaml:"interfaces"`
+}
+
+// SearchResultInterface represents a network interface of a matching container
+//
+// API extension: search
+type SearchResultInterface struct {
+	Name      string   `json:"name" yaml:"name"`
+	Network   string   `json:"network" yaml:"network"`
+	Hwaddr    string   `json:"hwaddr" yaml:"hwaddr"`
+	Addresses []string `json:"addresses" yaml:"addresses"`
+}
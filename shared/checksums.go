@@ -0,0 +1,130 @@
+package shared
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ChecksumsFile is the name of the sha256 manifest stored alongside
+// exported images and container backups.
+const ChecksumsFile = "checksums"
+
+// ChecksumsFromDir returns the sha256 of every regular file under root,
+// indexed by its path relative to root.
+func ChecksumsFromDir(root string) (map[string]string, error) {
+	checksums := map[string]string{}
+
+	err := filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		if relPath == ChecksumsFile {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		hash := sha256.New()
+		_, err = io.Copy(hash, f)
+		if err != nil {
+			return err
+		}
+
+		checksums[relPath] = fmt.Sprintf("%x", hash.Sum(nil))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return checksums, nil
+}
+
+// ChecksumsWrite writes the checksums in the sha256sum format, sorted by path.
+func ChecksumsWrite(w io.Writer, checksums map[string]string) error {
+	paths := make([]string, 0, len(checksums))
+	for path := range checksums {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		_, err := fmt.Fprintf(w, "%s  %s\n", checksums[path], path)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ChecksumsParse reads a manifest written by ChecksumsWrite.
+func ChecksumsParse(r io.Reader) (map[string]string, error) {
+	checksums := map[string]string{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 4096), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+
+		fields := strings.SplitN(line, "  ", 2)
+		if len(fields) != 2 || len(fields[0]) != sha256.Size*2 {
+			return nil, fmt.Errorf("Invalid checksum entry: %s", line)
+		}
+
+		checksums[fields[1]] = fields[0]
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return checksums, nil
+}
+
+// ChecksumsVerify checks that every file listed in expected is present in
+// actual with the same checksum. Extra files in actual are ignored.
+func ChecksumsVerify(expected map[string]string, actual map[string]string) error {
+	paths := make([]string, 0, len(expected))
+	for path := range expected {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		checksum, ok := actual[path]
+		if !ok {
+			return fmt.Errorf("Integrity check failed: %s is missing", path)
+		}
+
+		if checksum != expected[path] {
+			return fmt.Errorf("Integrity check failed: %s has checksum %s, expected %s", path, checksum, expected[path])
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package shared
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecksums(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd-checksums-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "rootfs", "etc"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "metadata.yaml"), []byte("architecture: x86_64\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "rootfs", "etc", "hostname"), []byte("c1\n"), 0644))
+	require.NoError(t, os.Symlink("hostname", filepath.Join(dir, "rootfs", "etc", "link")))
+
+	checksums, err := ChecksumsFromDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, checksums, 2)
+
+	// Round-trip through the manifest format.
+	buf := bytes.Buffer{}
+	require.NoError(t, ChecksumsWrite(&buf, checksums))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, ChecksumsFile), buf.Bytes(), 0644))
+
+	expected, err := ChecksumsParse(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, checksums, expected)
+
+	// The manifest itself isn't part of the checksums.
+	actual, err := ChecksumsFromDir(dir)
+	require.NoError(t, err)
+	assert.NoError(t, ChecksumsVerify(expected, actual))
+
+	// Detect corrupted and missing files.
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "rootfs", "etc", "hostname"), []byte("c2\n"), 0644))
+	actual, err = ChecksumsFromDir(dir)
+	require.NoError(t, err)
+	assert.Error(t, ChecksumsVerify(expected, actual))
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "rootfs", "etc", "hostname")))
+	actual, err = ChecksumsFromDir(dir)
+	require.NoError(t, err)
+	assert.Error(t, ChecksumsVerify(expected, actual))
+}
+
+func TestChecksumsParse_Invalid(t *testing.T) {
+	_, err := ChecksumsParse(bytes.NewBufferString("abcd  rootfs/etc/hostname\n"))
+	assert.Error(t, err)
+}
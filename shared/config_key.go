@@ -0,0 +1,31 @@
+package shared
+
+import (
+	"github.com/lxc/lxd/shared/api"
+)
+
+// ConfigKey describes a supported configuration key, as listed by
+// /1.0/metadata/configuration, along with the checker function validating
+// whether or not a given value is syntactically legal.
+type ConfigKey struct {
+	Type         string
+	Default      string
+	Condition    string
+	LiveUpdate   string
+	APIExtension string
+	Description  string
+
+	Validator func(value string) error
+}
+
+// Metadata returns the API representation of the key.
+func (k ConfigKey) Metadata() api.MetadataConfigurationKey {
+	return api.MetadataConfigurationKey{
+		Type:         k.Type,
+		Default:      k.Default,
+		Condition:    k.Condition,
+		LiveUpdate:   k.LiveUpdate,
+		APIExtension: k.APIExtension,
+		Description:  k.Description,
+	}
+}
@@ -13,6 +13,35 @@ import (
 	"github.com/lxc/lxd/shared/units"
 )
 
+// isHostScript validates the path to a script on the host.
+func isHostScript(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	if !strings.HasPrefix(value, "/") {
+		return fmt.Errorf("Invalid value for the host script, it must be an absolute path: %s", value)
+	}
+
+	return nil
+}
+
+// isTimeOffset validates a clock offset of a time namespace, an integer
+// followed by one of the h, m, s, ms, us or ns units.
+func isTimeOffset(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	if !timeOffsetRegexp.MatchString(value) {
+		return fmt.Errorf("Invalid time offset, it must be an integer followed by h, m, s, ms, us or ns: %s", value)
+	}
+
+	return nil
+}
+
+var timeOffsetRegexp = regexp.MustCompile(`^-?[0-9]+(h|m|s|ms|us|ns)$`)
+
 type ContainerAction string
 
 const (
@@ -79,6 +108,33 @@ func IsPriority(value string) error {
 	return nil
 }
 
+func IsSize(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	_, err := units.ParseByteSizeString(value)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// isBitSize validates a rate in bits per second (e.g. 100Mbit).
+func isBitSize(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	_, err := units.ParseBitSizeString(value)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func IsBool(value string) error {
 	if value == "" {
 		return nil
@@ -176,174 +232,1021 @@ func GetRootDiskDevice(devices map[string]map[string]string) (string, map[string
 }
 
 // KnownContainerConfigKeys maps all fully defined, well-known config keys
-// to an appropriate checker function, which validates whether or not a
-// given value is syntactically legal.
-var KnownContainerConfigKeys = map[string]func(value string) error{
-	"boot.autostart":             IsBool,
-	"boot.autostart.delay":       IsInt64,
-	"boot.autostart.priority":    IsInt64,
-	"boot.stop.priority":         IsInt64,
-	"boot.host_shutdown_timeout": IsInt64,
-
-	"limits.cpu": func(value string) error {
-		if value == "" {
-			return nil
-		}
+// to their description and checker function. Internal "volatile." keys
+// aren't described.
+var KnownContainerConfigKeys = map[string]ConfigKey{
+	"boot.autostart": {
+		Type:        "boolean",
+		Default:     "-",
+		LiveUpdate:  "n/a",
+		Description: "Always start the container when LXD starts (if not set, restore last state)",
+		Validator:   IsBool,
+	},
+	"boot.autostart.delay": {
+		Type:        "integer",
+		Default:     "0",
+		LiveUpdate:  "n/a",
+		Description: "Number of seconds to wait after the container started before starting the next one",
+		Validator:   IsInt64,
+	},
+	"boot.autostart.priority": {
+		Type:        "integer",
+		Default:     "0",
+		LiveUpdate:  "n/a",
+		Description: "What order to start the containers in (starting with highest)",
+		Validator:   IsInt64,
+	},
+	"boot.stop.priority": {
+		Type:         "integer",
+		Default:      "0",
+		LiveUpdate:   "n/a",
+		APIExtension: "container_stop_priority",
+		Description:  "What order to shutdown the containers (starting with highest)",
+		Validator:    IsInt64,
+	},
+	"boot.host_shutdown_timeout": {
+		Type:         "integer",
+		Default:      "30",
+		LiveUpdate:   "yes",
+		APIExtension: "container_host_shutdown_timeout",
+		Description:  "Seconds to wait for container to shutdown before it is force stopped",
+		Validator:    IsInt64,
+	},
+	"boot.emergency_shutdown_timeout": {
+		Type:         "integer",
+		Default:      "5",
+		LiveUpdate:   "n/a",
+		APIExtension: "emergency_shutdown",
+		Description:  "Seconds to wait for container to shutdown during an emergency shutdown before it is force stopped",
+		Validator:    IsInt64,
+	},
+	"boot.emergency_stateful": {
+		Type:         "boolean",
+		Default:      "false",
+		LiveUpdate:   "n/a",
+		APIExtension: "emergency_shutdown",
+		Description:  "Attempt a stateful stop (CRIU) of the container during an emergency shutdown",
+		Validator:    IsBool,
+	},
+	"boot.resume.command": {
+		Type:         "string",
+		Default:      "-",
+		LiveUpdate:   "yes",
+		APIExtension: "container_resume_hooks",
+		Description:  "Command run in the container after it's unfrozen or restored from a stateful stop or snapshot, with the clock skew in seconds in `LXD_CLOCK_SKEW`",
+		Validator:    IsAny,
+	},
+	"boot.resume.notify": {
+		Type:         "boolean",
+		Default:      "false",
+		LiveUpdate:   "yes",
+		APIExtension: "container_resume_hooks",
+		Description:  "Send a `resume` devlxd event with the clock skew after the container is unfrozen or restored",
+		Validator:    IsBool,
+	},
 
-		// Validate the character set
-		match, _ := regexp.MatchString("^[-,0-9]*$", value)
-		if !match {
-			return fmt.Errorf("Invalid CPU limit syntax")
-		}
+	"hooks.pre-start": {
+		Type:         "string",
+		Default:      "-",
+		LiveUpdate:   "yes",
+		APIExtension: "container_hooks",
+		Description:  "Path to a host script run before the container starts, a failure preventing the start (administrators only)",
+		Validator:    isHostScript,
+	},
+	"hooks.post-start": {
+		Type:         "string",
+		Default:      "-",
+		LiveUpdate:   "yes",
+		APIExtension: "container_hooks",
+		Description:  "Path to a host script run after the container started (administrators only)",
+		Validator:    isHostScript,
+	},
+	"hooks.pre-stop": {
+		Type:         "string",
+		Default:      "-",
+		LiveUpdate:   "yes",
+		APIExtension: "container_hooks",
+		Description:  "Path to a host script run before the container is stopped (administrators only)",
+		Validator:    isHostScript,
+	},
+	"hooks.post-stop": {
+		Type:         "string",
+		Default:      "-",
+		LiveUpdate:   "yes",
+		APIExtension: "container_hooks",
+		Description:  "Path to a host script run after the container stopped (administrators only)",
+		Validator:    isHostScript,
+	},
 
-		// Validate first character
-		if strings.HasPrefix(value, "-") || strings.HasPrefix(value, ",") {
-			return fmt.Errorf("CPU limit can't start with a separator")
-		}
+	"image.follow": {
+		Type:         "string",
+		Default:      "-",
+		LiveUpdate:   "yes",
+		APIExtension: "container_image_follow",
+		Description:  "Image alias to follow for updates, as `ALIAS@SERVER` with SERVER the URL of a simplestreams image server",
+		Validator: func(value string) error {
+			if value == "" {
+				return nil
+			}
 
-		// Validate last character
-		if strings.HasSuffix(value, "-") || strings.HasSuffix(value, ",") {
-			return fmt.Errorf("CPU limit can't end with a separator")
-		}
+			_, _, err := ParseImageFollow(value)
+			return err
+		},
+	},
+	"image.follow.mode": {
+		Type:         "string",
+		Default:      "notify",
+		LiveUpdate:   "yes",
+		APIExtension: "container_image_follow",
+		Description:  "What to do when the followed image changes, either `notify` or `rebuild` (requires `images.auto_rebuild` on the project)",
+		Validator: func(value string) error {
+			return IsOneOf(value, []string{"notify", "rebuild"})
+		},
+	},
+	"image.follow.window": {
+		Type:         "string",
+		Default:      "-",
+		LiveUpdate:   "yes",
+		APIExtension: "container_image_follow",
+		Description:  "Daily time range (`HH:MM-HH:MM`, host time) during which the container may be rebuilt, any time if unset",
+		Validator: func(value string) error {
+			if value == "" {
+				return nil
+			}
 
-		return nil
+			_, err := ParseMaintenanceWindow(value)
+			return err
+		},
 	},
-	"limits.cpu.allowance": func(value string) error {
-		if value == "" {
+
+	"limits.cpu": {
+		Type:        "string",
+		Default:     "- (all)",
+		LiveUpdate:  "yes",
+		Description: "Number or range of CPUs to expose to the container",
+		Validator: func(value string) error {
+			if value == "" {
+				return nil
+			}
+
+			// Validate the character set
+			match, _ := regexp.MatchString("^[-,0-9]*$", value)
+			if !match {
+				return fmt.Errorf("Invalid CPU limit syntax")
+			}
+
+			// Validate first character
+			if strings.HasPrefix(value, "-") || strings.HasPrefix(value, ",") {
+				return fmt.Errorf("CPU limit can't start with a separator")
+			}
+
+			// Validate last character
+			if strings.HasSuffix(value, "-") || strings.HasSuffix(value, ",") {
+				return fmt.Errorf("CPU limit can't end with a separator")
+			}
+
 			return nil
-		}
+		},
+	},
+	"limits.cpu.allowance": {
+		Type:        "string",
+		Default:     "100%",
+		LiveUpdate:  "yes",
+		Description: "How much of the CPU can be used. Can be a percentage (e.g. 50%) for a soft limit or hard a chunk of time (25ms/100ms)",
+		Validator: func(value string) error {
+			if value == "" {
+				return nil
+			}
+
+			if strings.HasSuffix(value, "%") {
+				// Percentage based allocation
+				_, err := strconv.Atoi(strings.TrimSuffix(value, "%"))
+				if err != nil {
+					return err
+				}
+
+				return nil
+			}
+
+			// Time based allocation
+			fields := strings.SplitN(value, "/", 2)
+			if len(fields) != 2 {
+				return fmt.Errorf("Invalid allowance: %s", value)
+			}
 
-		if strings.HasSuffix(value, "%") {
-			// Percentage based allocation
-			_, err := strconv.Atoi(strings.TrimSuffix(value, "%"))
+			_, err := strconv.Atoi(strings.TrimSuffix(fields[0], "ms"))
 			if err != nil {
 				return err
 			}
 
-			return nil
-		}
+			_, err = strconv.Atoi(strings.TrimSuffix(fields[1], "ms"))
+			if err != nil {
+				return err
+			}
 
-		// Time based allocation
-		fields := strings.SplitN(value, "/", 2)
-		if len(fields) != 2 {
-			return fmt.Errorf("Invalid allowance: %s", value)
-		}
+			return nil
+		},
+	},
+	"limits.cpu.allowance.burst": {
+		Type:         "string",
+		Default:      "-",
+		LiveUpdate:   "yes",
+		APIExtension: "container_cpu_burst",
+		Description:  "Extra chunk of time the container may accumulate and use above its time based allowance (e.g. 10ms)",
+		Validator: func(value string) error {
+			if value == "" {
+				return nil
+			}
 
-		_, err := strconv.Atoi(strings.TrimSuffix(fields[0], "ms"))
-		if err != nil {
-			return err
-		}
+			burst, err := strconv.Atoi(strings.TrimSuffix(value, "ms"))
+			if err != nil {
+				return err
+			}
 
-		_, err = strconv.Atoi(strings.TrimSuffix(fields[1], "ms"))
-		if err != nil {
-			return err
-		}
+			if burst < 0 {
+				return fmt.Errorf("Invalid value for a CPU burst '%s'. Must not be negative", value)
+			}
 
-		return nil
+			return nil
+		},
 	},
-	"limits.cpu.priority": IsPriority,
+	"limits.cpu.nodes": {
+		Type:         "string",
+		Default:      "-",
+		LiveUpdate:   "yes",
+		APIExtension: "container_cpu_numa_nodes",
+		Description:  "NUMA nodes (e.g. `0` or `0,1`) whose CPUs and memory the container is restricted to",
+		Validator: func(value string) error {
+			if value == "" {
+				return nil
+			}
 
-	"limits.disk.priority": IsPriority,
+			match, _ := regexp.MatchString("^[0-9]+(-[0-9]+)?(,[0-9]+(-[0-9]+)?)*$", value)
+			if !match {
+				return fmt.Errorf("Invalid NUMA nodes syntax")
+			}
 
-	"limits.memory": func(value string) error {
-		if value == "" {
 			return nil
-		}
+		},
+	},
+	"limits.cpu.priority": {
+		Type:        "integer",
+		Default:     "10 (maximum)",
+		LiveUpdate:  "yes",
+		Description: "CPU scheduling priority compared to other containers sharing the same CPUs (overcommit) (integer between 0 and 10)",
+		Validator:   IsPriority,
+	},
+
+	"limits.disk.latency": {
+		Type:         "string",
+		Default:      "-",
+		LiveUpdate:   "yes",
+		APIExtension: "container_disk_latency",
+		Description:  "Target I/O latency of the container's disks in milliseconds (e.g. 10ms), on hosts supporting io.latency",
+		Validator: func(value string) error {
+			if value == "" {
+				return nil
+			}
 
-		if strings.HasSuffix(value, "%") {
-			_, err := strconv.ParseInt(strings.TrimSuffix(value, "%"), 10, 64)
+			latency, err := strconv.Atoi(strings.TrimSuffix(value, "ms"))
 			if err != nil {
 				return err
 			}
 
+			if latency < 1 {
+				return fmt.Errorf("Invalid value for a latency target: %s", value)
+			}
+
 			return nil
-		}
+		},
+	},
+	"limits.disk.priority": {
+		Type:        "integer",
+		Default:     "5 (medium)",
+		LiveUpdate:  "yes",
+		Description: "When under load, how much priority to give to the container's I/O requests (integer between 0 and 10)",
+		Validator:   IsPriority,
+	},
 
-		_, err := units.ParseByteSizeString(value)
-		if err != nil {
-			return err
-		}
+	"limits.exec.sessions": {
+		Type:         "integer",
+		Default:      "-",
+		LiveUpdate:   "yes",
+		APIExtension: "container_exec_sessions_limit",
+		Description:  "Maximum number of concurrent exec sessions in the container (0 for no limit, defaults to core.exec_sessions_limit)",
+		Validator:    IsUint32,
+	},
 
-		return nil
+	"limits.hugepages.2MB": {
+		Type:         "string",
+		Default:      "-",
+		LiveUpdate:   "yes",
+		APIExtension: "container_hugepages_disk",
+		Description:  "Maximum amount of 2MB huge pages the container can use",
+		Validator:    IsSize,
 	},
-	"limits.memory.enforce": func(value string) error {
-		return IsOneOf(value, []string{"soft", "hard"})
+	"limits.hugepages.1GB": {
+		Type:         "string",
+		Default:      "-",
+		LiveUpdate:   "yes",
+		APIExtension: "container_hugepages_disk",
+		Description:  "Maximum amount of 1GB huge pages the container can use",
+		Validator:    IsSize,
 	},
-	"limits.memory.swap":          IsBool,
-	"limits.memory.swap.priority": IsPriority,
 
-	"limits.network.priority": IsPriority,
+	"limits.memory": {
+		Type:        "string",
+		Default:     "- (all)",
+		LiveUpdate:  "yes",
+		Description: "Percentage of the host's memory or fixed value in bytes (various suffixes supported, see below)",
+		Validator: func(value string) error {
+			if value == "" {
+				return nil
+			}
 
-	"limits.processes": IsInt64,
+			if strings.HasSuffix(value, "%") {
+				_, err := strconv.ParseInt(strings.TrimSuffix(value, "%"), 10, 64)
+				if err != nil {
+					return err
+				}
 
-	"linux.kernel_modules": IsAny,
+				return nil
+			}
 
-	"migration.incremental.memory":            IsBool,
-	"migration.incremental.memory.iterations": IsUint32,
-	"migration.incremental.memory.goal":       IsUint32,
+			_, err := units.ParseByteSizeString(value)
+			if err != nil {
+				return err
+			}
 
-	"nvidia.runtime":             IsBool,
-	"nvidia.driver.capabilities": IsAny,
-	"nvidia.require.cuda":        IsAny,
-	"nvidia.require.driver":      IsAny,
+			return nil
+		},
+	},
+	"limits.memory.enforce": {
+		Type:        "string",
+		Default:     "hard",
+		LiveUpdate:  "yes",
+		Description: "If hard, container can't exceed its memory limit. If soft, the container can exceed its memory limit when extra host memory is available.",
+		Validator: func(value string) error {
+			return IsOneOf(value, []string{"soft", "hard"})
+		},
+	},
+	"limits.memory.oom_policy": {
+		Type:         "string",
+		Default:      "ignore",
+		LiveUpdate:   "yes",
+		APIExtension: "container_oom_events",
+		Description:  "What to do when the kernel kills processes of the container for running out of memory (ignore, restart or stop)",
+		Validator: func(value string) error {
+			return IsOneOf(value, []string{"ignore", "restart", "stop"})
+		},
+	},
+	"limits.memory.swap": {
+		Type:        "boolean",
+		Default:     "true",
+		LiveUpdate:  "yes",
+		Description: "Whether to allow some of the container's memory to be swapped out to disk",
+		Validator:   IsBool,
+	},
+	"limits.memory.swap.priority": {
+		Type:        "integer",
+		Default:     "10 (maximum)",
+		LiveUpdate:  "yes",
+		Description: "The higher this is set, the least likely the container is to be swapped to disk (integer between 0 and 10)",
+		Validator:   IsPriority,
+	},
+
+	"limits.network.conntrack": {
+		Type:         "integer",
+		Default:      "- (max)",
+		LiveUpdate:   "yes",
+		APIExtension: "container_network_conntrack",
+		Description:  "Maximum number of tracked connections for each of the container's host side network interfaces",
+		Validator:    IsUint32,
+	},
+	"limits.network.egress": {
+		Type:         "string",
+		Default:      "-",
+		LiveUpdate:   "yes",
+		APIExtension: "container_network_limits",
+		Description:  "Default I/O limit in bit/s for outgoing traffic on the container's bridged and p2p network interfaces",
+		Validator:    isBitSize,
+	},
+	"limits.network.ingress": {
+		Type:         "string",
+		Default:      "-",
+		LiveUpdate:   "yes",
+		APIExtension: "container_network_limits",
+		Description:  "Default I/O limit in bit/s for incoming traffic on the container's bridged and p2p network interfaces",
+		Validator:    isBitSize,
+	},
+	"limits.network.priority": {
+		Type:        "integer",
+		Default:     "0 (minimum)",
+		LiveUpdate:  "yes",
+		Description: "When under load, how much priority to give to the container's network requests (integer between 0 and 10)",
+		Validator:   IsPriority,
+	},
+
+	"limits.processes": {
+		Type:        "integer",
+		Default:     "- (max)",
+		LiveUpdate:  "yes",
+		Description: "Maximum number of processes that can run in the container",
+		Validator:   IsInt64,
+	},
+
+	"linux.kernel_modules": {
+		Type:        "string",
+		Default:     "-",
+		LiveUpdate:  "yes",
+		Description: "Comma separated list of kernel modules to load before starting the container",
+		Validator:   IsAny,
+	},
 
-	"security.nesting":       IsBool,
-	"security.privileged":    IsBool,
-	"security.devlxd":        IsBool,
-	"security.devlxd.images": IsBool,
+	"linux.sysctl.net.core.somaxconn": {
+		Type:         "integer",
+		Default:      "-",
+		LiveUpdate:   "yes",
+		APIExtension: "container_net_sysctl",
+		Description:  "Maximum listen backlog for the container's sockets (`net.core.somaxconn`)",
+		Validator:    IsUint32,
+	},
+	"linux.sysctl.net.ipv4.conf.all.rp_filter": {
+		Type:         "integer",
+		Default:      "-",
+		LiveUpdate:   "yes",
+		APIExtension: "container_net_sysctl",
+		Description:  "Reverse path filtering mode for all the container's interfaces, 0, 1 or 2 (`net.ipv4.conf.all.rp_filter`)",
+		Validator: func(value string) error {
+			return IsOneOf(value, []string{"0", "1", "2"})
+		},
+	},
+	"linux.sysctl.net.ipv4.conf.default.rp_filter": {
+		Type:         "integer",
+		Default:      "-",
+		LiveUpdate:   "yes",
+		APIExtension: "container_net_sysctl",
+		Description:  "Reverse path filtering mode for new container interfaces, 0, 1 or 2 (`net.ipv4.conf.default.rp_filter`)",
+		Validator: func(value string) error {
+			return IsOneOf(value, []string{"0", "1", "2"})
+		},
+	},
+	"linux.sysctl.net.ipv4.ip_local_port_range": {
+		Type:         "string",
+		Default:      "-",
+		LiveUpdate:   "yes",
+		APIExtension: "container_net_sysctl",
+		Description:  "Range of local ports used for outgoing connections, as \"LOW HIGH\" (`net.ipv4.ip_local_port_range`)",
+		Validator: func(value string) error {
+			if value == "" {
+				return nil
+			}
 
-	"security.protection.delete": IsBool,
-	"security.protection.shift":  IsBool,
+			fields := strings.Fields(value)
+			if len(fields) != 2 {
+				return fmt.Errorf("Invalid port range: %s", value)
+			}
 
-	"security.idmap.base":     IsUint32,
-	"security.idmap.isolated": IsBool,
-	"security.idmap.size":     IsUint32,
+			low, err := strconv.ParseUint(fields[0], 10, 16)
+			if err != nil || low == 0 {
+				return fmt.Errorf("Invalid port range: %s", value)
+			}
 
-	"security.syscalls.blacklist_default":  IsBool,
-	"security.syscalls.blacklist_compat":   IsBool,
-	"security.syscalls.blacklist":          IsAny,
-	"security.syscalls.intercept.mknod":    IsBool,
-	"security.syscalls.intercept.setxattr": IsBool,
-	"security.syscalls.whitelist":          IsAny,
+			high, err := strconv.ParseUint(fields[1], 10, 16)
+			if err != nil || high < low {
+				return fmt.Errorf("Invalid port range: %s", value)
+			}
 
-	"snapshots.schedule": func(value string) error {
-		if value == "" {
 			return nil
-		}
+		},
+	},
+	"linux.sysctl.net.ipv4.tcp_keepalive_intvl": {
+		Type:         "integer",
+		Default:      "-",
+		LiveUpdate:   "yes",
+		APIExtension: "container_net_sysctl",
+		Description:  "Seconds between TCP keepalive probes (`net.ipv4.tcp_keepalive_intvl`)",
+		Validator:    IsUint32,
+	},
+	"linux.sysctl.net.ipv4.tcp_keepalive_probes": {
+		Type:         "integer",
+		Default:      "-",
+		LiveUpdate:   "yes",
+		APIExtension: "container_net_sysctl",
+		Description:  "Number of unanswered TCP keepalive probes before dropping the connection (`net.ipv4.tcp_keepalive_probes`)",
+		Validator:    IsUint32,
+	},
+	"linux.sysctl.net.ipv4.tcp_keepalive_time": {
+		Type:         "integer",
+		Default:      "-",
+		LiveUpdate:   "yes",
+		APIExtension: "container_net_sysctl",
+		Description:  "Seconds of idle time before TCP keepalive probes are sent (`net.ipv4.tcp_keepalive_time`)",
+		Validator:    IsUint32,
+	},
 
-		if len(strings.Split(value, " ")) != 5 {
-			return fmt.Errorf("Schedule must be of the form: <minute> <hour> <day-of-month> <month> <day-of-week>")
-		}
+	"migration.incremental.memory": {
+		Type:         "boolean",
+		Default:      "false",
+		LiveUpdate:   "yes",
+		APIExtension: "migration_pre_copy",
+		Description:  "Incremental memory transfer of the container's memory to reduce downtime.",
+		Validator:    IsBool,
+	},
+	"migration.incremental.memory.iterations": {
+		Type:         "integer",
+		Default:      "10",
+		LiveUpdate:   "yes",
+		APIExtension: "migration_pre_copy",
+		Description:  "Maximum number of transfer operations to go through before stopping the container.",
+		Validator:    IsUint32,
+	},
+	"migration.incremental.memory.goal": {
+		Type:         "integer",
+		Default:      "70",
+		LiveUpdate:   "yes",
+		APIExtension: "migration_pre_copy",
+		Description:  "Percentage of memory to have in sync before stopping the container.",
+		Validator:    IsUint32,
+	},
+	"migration.hooks.pre-dump": {
+		Type:         "string",
+		Default:      "-",
+		LiveUpdate:   "yes",
+		APIExtension: "migration_hooks",
+		Description:  "Path to a host script run before the container is dumped by CRIU (administrators only)",
+		Validator:    isHostScript,
+	},
+	"migration.hooks.post-restore": {
+		Type:         "string",
+		Default:      "-",
+		LiveUpdate:   "yes",
+		APIExtension: "migration_hooks",
+		Description:  "Path to a host script run after the container was restored by CRIU (administrators only)",
+		Validator:    isHostScript,
+	},
 
-		_, err := cron.Parse(fmt.Sprintf("* %s", value))
-		if err != nil {
-			return errors.Wrap(err, "Error parsing schedule")
-		}
+	"network.firewall.persist": {
+		Type:         "bool",
+		Default:      "false",
+		LiveUpdate:   "no",
+		APIExtension: "container_firewall_persist",
+		Description:  "Save the firewall rules of the container when it stops and restore them when it starts",
+		Validator:    IsBool,
+	},
 
-		return nil
+	"nvidia.runtime": {
+		Type:         "boolean",
+		Default:      "false",
+		LiveUpdate:   "no",
+		APIExtension: "nvidia_runtime",
+		Description:  "Pass the host NVIDIA and CUDA runtime libraries into the container",
+		Validator:    IsBool,
 	},
-	"snapshots.schedule.stopped": IsBool,
-	"snapshots.pattern":          IsAny,
-	"snapshots.expiry": func(value string) error {
-		// Validate expression
-		_, err := GetSnapshotExpiry(time.Time{}, value)
-		return err
+	"nvidia.driver.capabilities": {
+		Type:         "string",
+		Default:      "compute,utility",
+		LiveUpdate:   "no",
+		APIExtension: "nvidia_runtime_config",
+		Description:  "What driver capabilities the container needs (sets libnvidia-container NVIDIA_DRIVER_CAPABILITIES)",
+		Validator:    IsAny,
+	},
+	"nvidia.require.cuda": {
+		Type:         "string",
+		Default:      "-",
+		LiveUpdate:   "no",
+		APIExtension: "nvidia_runtime_config",
+		Description:  "Version expression for the required CUDA version (sets libnvidia-container NVIDIA_REQUIRE_CUDA)",
+		Validator:    IsAny,
+	},
+	"nvidia.require.driver": {
+		Type:         "string",
+		Default:      "-",
+		LiveUpdate:   "no",
+		APIExtension: "nvidia_runtime_config",
+		Description:  "Version expression for the required driver version (sets libnvidia-container NVIDIA_REQUIRE_DRIVER)",
+		Validator:    IsAny,
+	},
+
+	"security.nesting": {
+		Type:        "boolean",
+		Default:     "false",
+		LiveUpdate:  "yes",
+		Description: "Support running lxd (nested) inside the container",
+		Validator:   IsBool,
+	},
+	"security.privileged": {
+		Type:        "boolean",
+		Default:     "false",
+		LiveUpdate:  "no",
+		Description: "Runs the container in privileged mode",
+		Validator:   IsBool,
+	},
+	"security.devlxd": {
+		Type:         "boolean",
+		Default:      "true",
+		LiveUpdate:   "no",
+		APIExtension: "restrict_devlxd",
+		Description:  "Controls the presence of /dev/lxd in the container",
+		Validator:    IsBool,
+	},
+	"security.devlxd.images": {
+		Type:         "boolean",
+		Default:      "false",
+		LiveUpdate:   "no",
+		APIExtension: "devlxd_images",
+		Description:  "Controls the availability of the /1.0/images API over devlxd",
+		Validator:    IsBool,
+	},
+	"security.devlxd.management": {
+		Type:         "boolean",
+		Default:      "false",
+		LiveUpdate:   "yes",
+		APIExtension: "devlxd_management",
+		Description:  "Controls the availability of the snapshot and restart APIs over devlxd",
+		Validator:    IsBool,
+	},
+
+	"security.exec_record": {
+		Type:         "boolean",
+		Default:      "false",
+		LiveUpdate:   "yes",
+		APIExtension: "container_exec_record",
+		Description:  "Records the command, environment, user, timing and exit code of each exec session in the container log directory",
+		Validator:    IsBool,
+	},
+	"security.exec_record.transcript": {
+		Type:         "boolean",
+		Default:      "false",
+		LiveUpdate:   "yes",
+		APIExtension: "container_exec_record",
+		Description:  "Also records a ttyrec transcript of the output of the recorded exec sessions",
+		Validator:    IsBool,
+	},
+
+	"security.apparmor.profile": {
+		Type:         "string",
+		Default:      "-",
+		LiveUpdate:   "no",
+		APIExtension: "apparmor_profiles",
+		Description:  "AppArmor profile managed by LXD confining the container instead of the generated one",
+		Validator:    IsAny,
+	},
+
+	"security.cpu.core_scheduling": {
+		Type:         "boolean",
+		Default:      "false",
+		LiveUpdate:   "no",
+		APIExtension: "container_core_scheduling",
+		Description:  "Isolates the container in its own core scheduling group, so that it never shares SMT siblings with other tasks",
+		Validator:    IsBool,
+	},
+
+	"security.debug.host_pidns_view": {
+		Type:         "boolean",
+		Default:      "false",
+		LiveUpdate:   "yes",
+		APIExtension: "container_host_pidns_view",
+		Description:  "Records the host PIDs of the container processes and threads (can only be set by administrators)",
+		Validator:    IsBool,
+	},
+	"security.denials.events": {
+		Type:         "boolean",
+		Default:      "false",
+		LiveUpdate:   "yes",
+		APIExtension: "container_security_denials",
+		Description:  "Emits a lifecycle event for each AppArmor or seccomp denial of the container",
+		Validator:    IsBool,
+	},
+
+	"security.nesting.cgroups": {
+		Type:         "string",
+		Default:      "default",
+		LiveUpdate:   "no",
+		APIExtension: "container_nesting_cgroups",
+		Description:  "Set to `full` to give nested container runtimes a writable cgroup tree scoped to the container (requires `security.nesting`)",
+		Validator: func(value string) error {
+			return IsOneOf(value, []string{"default", "full"})
+		},
+	},
+	"security.nesting.profile": {
+		Type:         "string",
+		Default:      "-",
+		LiveUpdate:   "no",
+		APIExtension: "container_nesting_profile",
+		Description:  "Set to `lxd` to configure and check everything needed to run LXD inside the container",
+		Validator: func(value string) error {
+			return IsOneOf(value, []string{"lxd"})
+		},
+	},
+
+	"security.protection.delete": {
+		Type:         "boolean",
+		Default:      "false",
+		LiveUpdate:   "yes",
+		APIExtension: "container_protection_delete",
+		Description:  "Prevents the container from being deleted",
+		Validator:    IsBool,
+	},
+	"security.protection.shift": {
+		Type:         "boolean",
+		Default:      "false",
+		LiveUpdate:   "yes",
+		APIExtension: "container_protection_shift",
+		Description:  "Prevents the container's filesystem from being uid/gid shifted on startup",
+		Validator:    IsBool,
+	},
+	"security.protection.start": {
+		Type:         "boolean",
+		Default:      "false",
+		LiveUpdate:   "yes",
+		APIExtension: "container_protection_start",
+		Description:  "Prevents the container from being started, e.g. during maintenance",
+		Validator:    IsBool,
+	},
+
+	"security.idmap.base": {
+		Type:         "integer",
+		Default:      "-",
+		LiveUpdate:   "no",
+		APIExtension: "id_map_base",
+		Description:  "The base host ID to use for the allocation (overrides auto-detection)",
+		Validator:    IsUint32,
+	},
+	"security.idmap.isolated": {
+		Type:         "boolean",
+		Default:      "false",
+		LiveUpdate:   "no",
+		APIExtension: "id_map",
+		Description:  "Use an idmap for this container that is unique among containers with isolated set.",
+		Validator:    IsBool,
+	},
+	"security.idmap.size": {
+		Type:         "integer",
+		Default:      "-",
+		LiveUpdate:   "no",
+		APIExtension: "id_map",
+		Description:  "The size of the idmap to use",
+		Validator:    IsUint32,
+	},
+
+	"security.syscalls.blacklist_default": {
+		Type:         "boolean",
+		Default:      "true",
+		LiveUpdate:   "no",
+		APIExtension: "container_syscall_filtering",
+		Description:  "Enables the default syscall blacklist",
+		Validator:    IsBool,
+	},
+	"security.syscalls.blacklist_compat": {
+		Type:         "boolean",
+		Default:      "false",
+		LiveUpdate:   "no",
+		APIExtension: "container_syscall_filtering",
+		Description:  "On x86_64 this enables blocking of compat_* syscalls, it is a no-op on other arches",
+		Validator:    IsBool,
+	},
+	"security.syscalls.blacklist": {
+		Type:         "string",
+		Default:      "-",
+		LiveUpdate:   "no",
+		APIExtension: "container_syscall_filtering",
+		Description:  "A '\\n' separated list of syscalls to blacklist",
+		Validator:    IsAny,
+	},
+	"security.syscalls.intercept.mknod": {
+		Type:         "boolean",
+		Default:      "false",
+		LiveUpdate:   "no",
+		APIExtension: "container_syscall_intercept",
+		Description:  "Handles the `mknod` and `mknodat` system calls (allows creation of a limited subset of char/block devices)",
+		Validator:    IsBool,
+	},
+	"security.syscalls.intercept.setxattr": {
+		Type:         "boolean",
+		Default:      "false",
+		LiveUpdate:   "no",
+		APIExtension: "container_syscall_intercept",
+		Description:  "Handles the `setxattr` system call (allows setting a limited subset of restricted extended attributes)",
+		Validator:    IsBool,
+	},
+	"security.syscalls.log": {
+		Type:         "boolean",
+		Default:      "false",
+		LiveUpdate:   "no",
+		APIExtension: "container_syscalls_log",
+		Description:  "Logs the system calls denied by the seccomp policy",
+		Validator:    IsBool,
+	},
+	"security.syscalls.whitelist": {
+		Type:         "string",
+		Default:      "-",
+		LiveUpdate:   "no",
+		APIExtension: "container_syscall_filtering",
+		Description:  "A '\\n' separated list of syscalls to whitelist (mutually exclusive with security.syscalls.blacklist*)",
+		Validator:    IsAny,
+	},
+
+	"security.time.offset.boottime": {
+		Type:         "string",
+		Default:      "-",
+		LiveUpdate:   "no",
+		APIExtension: "container_time_namespace",
+		Description:  "Offset of the boot time clock of the container, in a time namespace (e.g. 1h or -30s)",
+		Validator:    isTimeOffset,
+	},
+	"security.time.offset.monotonic": {
+		Type:         "string",
+		Default:      "-",
+		LiveUpdate:   "no",
+		APIExtension: "container_time_namespace",
+		Description:  "Offset of the monotonic clock of the container, in a time namespace (e.g. 1h or -30s)",
+		Validator:    isTimeOffset,
+	},
+
+	"mounts.extra": {
+		Type:         "blob",
+		Default:      "-",
+		LiveUpdate:   "yes",
+		APIExtension: "container_extra_mounts",
+		Description:  "Bind mounts of paths of the container onto others, one \"SOURCE TARGET [OPTIONS]\" entry per line",
+		Validator: func(value string) error {
+			_, err := ParseExtraMounts(value)
+			return err
+		},
+	},
+
+	"snapshots.schedule": {
+		Type:         "string",
+		Default:      "-",
+		LiveUpdate:   "no",
+		APIExtension: "snapshot_scheduling",
+		Description:  "Cron expression (`<minute> <hour> <dom> <month> <dow>`)",
+		Validator:    isCronSchedule,
+	},
+	"snapshots.schedule.stopped": {
+		Type:         "bool",
+		Default:      "false",
+		LiveUpdate:   "no",
+		APIExtension: "snapshot_scheduling",
+		Description:  "Controls whether or not stopped containers are to be snapshoted automatically",
+		Validator:    IsBool,
+	},
+	"snapshots.pattern": {
+		Type:         "string",
+		Default:      "snap%d",
+		LiveUpdate:   "no",
+		APIExtension: "snapshot_scheduling",
+		Description:  "Pongo2 template string which represents the snapshot name (used for scheduled snapshots and unnamed snapshots)",
+		Validator:    IsAny,
+	},
+	"snapshots.expiry": {
+		Type:         "string",
+		Default:      "-",
+		LiveUpdate:   "no",
+		APIExtension: "snapshot_expiry",
+		Description:  "Controls when snapshots are to be deleted (expects expression like `1M 2H 3d 4w 5m 6y`)",
+		Validator: func(value string) error {
+			// Validate expression
+			_, err := GetSnapshotExpiry(time.Time{}, value)
+			return err
+		},
 	},
 
 	// Caller is responsible for full validation of any raw.* value
-	"raw.apparmor": IsAny,
-	"raw.lxc":      IsAny,
-	"raw.seccomp":  IsAny,
-	"raw.idmap":    IsAny,
-
-	"volatile.apply_template":   IsAny,
-	"volatile.base_image":       IsAny,
-	"volatile.last_state.idmap": IsAny,
-	"volatile.last_state.power": IsAny,
-	"volatile.idmap.base":       IsAny,
-	"volatile.idmap.current":    IsAny,
-	"volatile.idmap.next":       IsAny,
-	"volatile.apply_quota":      IsAny,
+	"raw.apparmor": {
+		Type:        "blob",
+		Default:     "-",
+		LiveUpdate:  "yes",
+		Description: "Apparmor profile entries to be appended to the generated profile",
+		Validator:   IsAny,
+	},
+	"raw.lxc": {
+		Type:        "blob",
+		Default:     "-",
+		LiveUpdate:  "no",
+		Description: "Raw LXC configuration to be appended to the generated one",
+		Validator:   IsAny,
+	},
+	"raw.seccomp": {
+		Type:         "blob",
+		Default:      "-",
+		LiveUpdate:   "no",
+		APIExtension: "container_syscall_filtering",
+		Description:  "Raw Seccomp configuration",
+		Validator:    IsAny,
+	},
+	"raw.idmap": {
+		Type:         "blob",
+		Default:      "-",
+		LiveUpdate:   "no",
+		APIExtension: "id_map",
+		Description:  "Raw idmap configuration (e.g. \"both 1000 1000\")",
+		Validator:    IsAny,
+	},
+
+	"volatile.apply_template":          {Validator: IsAny},
+	"volatile.base_image":              {Validator: IsAny},
+	"volatile.last_state.error":        {Validator: IsAny},
+	"volatile.last_state.error_log":    {Validator: IsAny},
+	"volatile.last_state.error_time":   {Validator: IsAny},
+	"volatile.last_state.idmap":        {Validator: IsAny},
+	"volatile.last_state.oom":          {Validator: IsAny},
+	"volatile.last_state.oom_kills":    {Validator: IsAny},
+	"volatile.last_state.oom_restarts": {Validator: IsAny},
+	"volatile.last_state.power":        {Validator: IsAny},
+	"volatile.last_state.suspended":    {Validator: IsAny},
+	"volatile.last_state.unfreeze":     {Validator: IsAny},
+	"volatile.idmap.base":              {Validator: IsAny},
+	"volatile.idmap.current":           {Validator: IsAny},
+	"volatile.idmap.next":              {Validator: IsAny},
+	"volatile.apply_quota":             {Validator: IsAny},
+
+	"volatile.image.follow.available": {Validator: IsAny},
+	"volatile.image.follow.failed":    {Validator: IsAny},
+}
+
+// KnownContainerConfigNamespaces maps the config key namespaces accepting
+// any sub-key, with "*" standing for the sub-key, to their description and
+// checker function.
+var KnownContainerConfigNamespaces = map[string]ConfigKey{
+	"environment.*": {
+		Type:        "string",
+		Default:     "-",
+		LiveUpdate:  "yes (exec)",
+		Description: "key/value environment variables to export to the container and set on exec",
+		Validator:   IsAny,
+	},
+	"limits.kernel.*": {
+		Type:         "string",
+		Default:      "-",
+		LiveUpdate:   "no",
+		APIExtension: "kernel_limits",
+		Description:  "This limits kernel resources per container (e.g. number of open files)",
+		Validator:    IsAny,
+	},
+	"linux.sysctl.*": {
+		Type:         "string",
+		Default:      "-",
+		LiveUpdate:   "yes",
+		APIExtension: "container_sysctl",
+		Description:  "Value of the sysctl of the same name in the container, only network and IPC namespace sysctls unless privileged (the others only apply on restart)",
+		Validator:    isSysctlValue,
+	},
+	"tasks.*.command": {
+		Type:         "string",
+		Default:      "-",
+		LiveUpdate:   "yes",
+		APIExtension: "container_tasks",
+		Description:  "Command periodically run in the container through `sh -c`",
+		Validator:    IsAny,
+	},
+	"tasks.*.schedule": {
+		Type:         "string",
+		Default:      "-",
+		LiveUpdate:   "yes",
+		APIExtension: "container_tasks",
+		Description:  "Cron expression (`<minute> <hour> <dom> <month> <dow>`) of when to run the task",
+		Validator:    isCronSchedule,
+	},
+	"user.*": {
+		Type:        "string",
+		Default:     "-",
+		LiveUpdate:  "n/a",
+		Description: "Free form user key/value storage (can be used in search)",
+		Validator:   IsAny,
+	},
+}
+
+// The names of the sysctls which can be set through linux.sysctl.* keys.
+var sysctlNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-]+(\.[a-zA-Z0-9_-]+)+$`)
+
+// isSysctlValue validates the value of a sysctl, which is written as is to
+// the LXC configuration and /proc/sys.
+func isSysctlValue(value string) error {
+	if strings.ContainsAny(value, "\n\r\x00") {
+		return fmt.Errorf("Invalid value for a sysctl: %q", value)
+	}
+
+	return nil
+}
+
+// isCronSchedule checks that the value is a cron expression with five fields.
+func isCronSchedule(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	if len(strings.Split(value, " ")) != 5 {
+		return fmt.Errorf("Schedule must be of the form: <minute> <hour> <day-of-month> <month> <day-of-week>")
+	}
+
+	_, err := cron.Parse(fmt.Sprintf("* %s", value))
+	if err != nil {
+		return errors.Wrap(err, "Error parsing schedule")
+	}
+
+	return nil
 }
 
 // ConfigKeyChecker returns a function that will check whether or not
@@ -353,8 +1256,8 @@ var KnownContainerConfigKeys = map[string]func(value string) error{
 // be done by the caller.  User defined keys are always considered to
 // be valid, e.g. user.* and environment.* keys.
 func ConfigKeyChecker(key string) (func(value string) error, error) {
-	if f, ok := KnownContainerConfigKeys[key]; ok {
-		return f, nil
+	if configKey, ok := KnownContainerConfigKeys[key]; ok {
+		return configKey.Validator, nil
 	}
 
 	if strings.HasPrefix(key, "volatile.") {
@@ -378,6 +1281,10 @@ func ConfigKeyChecker(key string) (func(value string) error, error) {
 			return IsAny, nil
 		}
 
+		if strings.HasSuffix(key, ".up") {
+			return IsBool, nil
+		}
+
 		if strings.HasSuffix(key, ".id") {
 			return IsAny, nil
 		}
@@ -389,23 +1296,30 @@ func ConfigKeyChecker(key string) (func(value string) error, error) {
 		if strings.HasSuffix(key, ".spoofcheck") {
 			return IsAny, nil
 		}
-	}
 
-	if strings.HasPrefix(key, "environment.") {
-		return IsAny, nil
-	}
+		if strings.HasSuffix(key, ".nic_index") {
+			return IsUint32, nil
+		}
 
-	if strings.HasPrefix(key, "user.") {
-		return IsAny, nil
+		if strings.HasSuffix(key, ".mdev") {
+			return IsAny, nil
+		}
 	}
 
 	if strings.HasPrefix(key, "image.") {
 		return IsAny, nil
 	}
 
-	if strings.HasPrefix(key, "limits.kernel.") &&
-		(len(key) > len("limits.kernel.")) {
-		return IsAny, nil
+	if strings.HasPrefix(key, "linux.sysctl.") &&
+		!sysctlNameRegexp.MatchString(strings.TrimPrefix(key, "linux.sysctl.")) {
+		return nil, fmt.Errorf("Unknown configuration key: %s", key)
+	}
+
+	for namespace, configKey := range KnownContainerConfigNamespaces {
+		fields := strings.SplitN(namespace, "*", 2)
+		if len(key) > len(namespace)-1 && strings.HasPrefix(key, fields[0]) && strings.HasSuffix(key, fields[1]) {
+			return configKey.Validator, nil
+		}
 	}
 
 	return nil, fmt.Errorf("Unknown configuration key: %s", key)
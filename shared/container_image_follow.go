@@ -0,0 +1,71 @@
+package shared
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ParseImageFollow parses the image.follow container configuration, of the
+// form "ALIAS@SERVER" where SERVER is the URL of a simplestreams image server.
+func ParseImageFollow(value string) (string, string, error) {
+	fields := strings.SplitN(value, "@", 2)
+	if len(fields) != 2 || fields[0] == "" || fields[1] == "" {
+		return "", "", fmt.Errorf("Invalid image to follow %q, expected: ALIAS@SERVER", value)
+	}
+
+	u, err := url.Parse(fields[1])
+	if err != nil {
+		return "", "", err
+	}
+
+	if !StringInSlice(u.Scheme, []string{"http", "https"}) || u.Host == "" {
+		return "", "", fmt.Errorf("Invalid image server URL %q", fields[1])
+	}
+
+	return fields[0], fields[1], nil
+}
+
+// MaintenanceWindow represents a daily time range, as offsets from midnight.
+// The end may be before the start for windows spanning midnight.
+type MaintenanceWindow struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// ParseMaintenanceWindow parses a "HH:MM-HH:MM" daily time range.
+func ParseMaintenanceWindow(value string) (*MaintenanceWindow, error) {
+	fields := strings.Split(value, "-")
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("Invalid maintenance window %q, expected: HH:MM-HH:MM", value)
+	}
+
+	offsets := []time.Duration{}
+	for _, field := range fields {
+		t, err := time.Parse("15:04", strings.TrimSpace(field))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid maintenance window %q, expected: HH:MM-HH:MM", value)
+		}
+
+		offsets = append(offsets, time.Duration(t.Hour())*time.Hour+time.Duration(t.Minute())*time.Minute)
+	}
+
+	if offsets[0] == offsets[1] {
+		return nil, fmt.Errorf("Empty maintenance window %q", value)
+	}
+
+	return &MaintenanceWindow{Start: offsets[0], End: offsets[1]}, nil
+}
+
+// Contains returns whether the given time, in its own location, falls inside
+// the window.
+func (w *MaintenanceWindow) Contains(t time.Time) bool {
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+
+	if w.Start < w.End {
+		return offset >= w.Start && offset < w.End
+	}
+
+	return offset >= w.Start || offset < w.End
+}
@@ -0,0 +1,46 @@
+package shared
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseImageFollow(t *testing.T) {
+	alias, server, err := ParseImageFollow("ubuntu/18.04@https://cloud-images.ubuntu.com/releases")
+	require.NoError(t, err)
+	assert.Equal(t, "ubuntu/18.04", alias)
+	assert.Equal(t, "https://cloud-images.ubuntu.com/releases", server)
+
+	for _, value := range []string{"ubuntu/18.04", "@https://images.example.com", "ubuntu@", "ubuntu@images:", "ubuntu@ftp://images.example.com"} {
+		_, _, err := ParseImageFollow(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestMaintenanceWindow(t *testing.T) {
+	at := func(hour int, minute int) time.Time {
+		return time.Date(2019, 10, 9, hour, minute, 0, 0, time.UTC)
+	}
+
+	window, err := ParseMaintenanceWindow("02:00-04:30")
+	require.NoError(t, err)
+	assert.True(t, window.Contains(at(2, 0)))
+	assert.True(t, window.Contains(at(4, 29)))
+	assert.False(t, window.Contains(at(4, 30)))
+	assert.False(t, window.Contains(at(1, 59)))
+
+	// Spanning midnight
+	window, err = ParseMaintenanceWindow("23:00-01:00")
+	require.NoError(t, err)
+	assert.True(t, window.Contains(at(23, 30)))
+	assert.True(t, window.Contains(at(0, 30)))
+	assert.False(t, window.Contains(at(12, 0)))
+
+	for _, value := range []string{"", "02:00", "02:00-02:00", "25:00-03:00", "2am-3am"} {
+		_, err := ParseMaintenanceWindow(value)
+		assert.Error(t, err, value)
+	}
+}
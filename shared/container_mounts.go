@@ -0,0 +1,78 @@
+package shared
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ExtraMount represents an entry of the mounts.extra container configuration,
+// bind-mounting a path of the container onto another.
+type ExtraMount struct {
+	Source    string
+	Target    string
+	ReadOnly  bool
+	Recursive bool
+	Optional  bool
+}
+
+// ParseExtraMounts parses the fstab-like mounts.extra configuration, made of
+// one "SOURCE TARGET [OPTIONS]" entry per line. Both paths are absolute paths
+// inside the container and OPTIONS is a comma separated list of "ro", "rw",
+// "bind", "rbind" and "optional". Empty lines and comments are ignored.
+func ParseExtraMounts(value string) ([]ExtraMount, error) {
+	mounts := []ExtraMount{}
+	targets := map[string]bool{}
+
+	for _, line := range strings.Split(value, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, fmt.Errorf("Invalid mount entry %q, expected: SOURCE TARGET [OPTIONS]", line)
+		}
+
+		mount := ExtraMount{Source: fields[0], Target: fields[1]}
+
+		for _, path := range []string{mount.Source, mount.Target} {
+			if !filepath.IsAbs(path) || filepath.Clean(path) != path {
+				return nil, fmt.Errorf("Invalid path %q in mount entry %q, must be a clean absolute path", path, line)
+			}
+		}
+
+		if mount.Target == "/" || mount.Target == "/proc" || strings.HasPrefix(mount.Target, "/proc/") || mount.Target == "/sys" || strings.HasPrefix(mount.Target, "/sys/") {
+			return nil, fmt.Errorf("Invalid target in mount entry %q", line)
+		}
+
+		if targets[mount.Target] {
+			return nil, fmt.Errorf("Duplicate mount target %q", mount.Target)
+		}
+		targets[mount.Target] = true
+
+		if len(fields) == 3 {
+			for _, option := range strings.Split(fields[2], ",") {
+				switch option {
+				case "ro":
+					mount.ReadOnly = true
+				case "rw":
+					mount.ReadOnly = false
+				case "bind":
+					mount.Recursive = false
+				case "rbind":
+					mount.Recursive = true
+				case "optional":
+					mount.Optional = true
+				default:
+					return nil, fmt.Errorf("Invalid option %q in mount entry %q", option, line)
+				}
+			}
+		}
+
+		mounts = append(mounts, mount)
+	}
+
+	return mounts, nil
+}
@@ -0,0 +1,42 @@
+package shared
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseExtraMounts(t *testing.T) {
+	mounts, err := ParseExtraMounts(`
+# Application data
+/srv/data /var/lib/app
+/srv/config /etc/app ro,optional
+/srv/tree /mnt/tree rbind
+`)
+	require.NoError(t, err)
+	assert.Equal(t, []ExtraMount{
+		{Source: "/srv/data", Target: "/var/lib/app"},
+		{Source: "/srv/config", Target: "/etc/app", ReadOnly: true, Optional: true},
+		{Source: "/srv/tree", Target: "/mnt/tree", Recursive: true},
+	}, mounts)
+}
+
+func TestParseExtraMounts_Invalid(t *testing.T) {
+	cases := map[string]string{
+		"missing target":   "/srv/data",
+		"relative source":  "srv/data /mnt",
+		"unclean path":     "/srv/../etc /mnt",
+		"root target":      "/srv/data /",
+		"proc target":      "/srv/data /proc/sys",
+		"unknown option":   "/srv/data /mnt nosuid",
+		"duplicate target": "/srv/a /mnt\n/srv/b /mnt",
+	}
+
+	for name, value := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseExtraMounts(value)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package shared
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigKeyCheckerSysctl(t *testing.T) {
+	validator, err := ConfigKeyChecker("linux.sysctl.kernel.shmmax")
+	require.NoError(t, err)
+	assert.NoError(t, validator("68719476736"))
+	assert.Error(t, validator("1\nlxc.apparmor.profile = unconfined"))
+
+	// The allow-listed keys keep their own validators
+	validator, err = ConfigKeyChecker("linux.sysctl.net.core.somaxconn")
+	require.NoError(t, err)
+	assert.Error(t, validator("lots"))
+
+	for _, key := range []string{"linux.sysctl.", "linux.sysctl.kernel", "linux.sysctl.net/../vm.swappiness", "linux.sysctl.net..core", "linux.sysctl.kernel.shm max"} {
+		_, err = ConfigKeyChecker(key)
+		assert.Error(t, err, key)
+	}
+}
@@ -2,10 +2,13 @@ package containerwriter
 
 import (
 	"archive/tar"
+	"bytes"
+	"crypto/sha256"
 	"fmt"
 	"io"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/lxc/lxd/shared"
 	"github.com/lxc/lxd/shared/idmap"
@@ -15,6 +18,7 @@ type ContainerTarWriter struct {
 	tarWriter *tar.Writer
 	idmapSet  *idmap.IdmapSet
 	linkMap   map[uint64]string
+	checksums map[string]string
 }
 
 func NewContainerTarWriter(writer io.Writer, idmapSet *idmap.IdmapSet) *ContainerTarWriter {
@@ -22,6 +26,7 @@ func NewContainerTarWriter(writer io.Writer, idmapSet *idmap.IdmapSet) *Containe
 	ctw.tarWriter = tar.NewWriter(writer)
 	ctw.idmapSet = idmapSet
 	ctw.linkMap = map[uint64]string{}
+	ctw.checksums = map[string]string{}
 	return ctw
 }
 
@@ -106,9 +111,40 @@ func (ctw *ContainerTarWriter) WriteFile(offset int, path string, fi os.FileInfo
 		}
 		defer f.Close()
 
-		if _, err := io.Copy(ctw.tarWriter, f); err != nil {
+		hash := sha256.New()
+		if _, err := io.Copy(io.MultiWriter(ctw.tarWriter, hash), f); err != nil {
 			return fmt.Errorf("failed to copy file content: %s", err)
 		}
+
+		ctw.checksums[hdr.Name] = fmt.Sprintf("%x", hash.Sum(nil))
+	}
+
+	return nil
+}
+
+// WriteChecksums adds a sha256 manifest of all the regular files written so
+// far to the tarball.
+func (ctw *ContainerTarWriter) WriteChecksums(name string) error {
+	buf := bytes.Buffer{}
+	err := shared.ChecksumsWrite(&buf, ctw.checksums)
+	if err != nil {
+		return fmt.Errorf("failed to generate checksums: %s", err)
+	}
+
+	hdr := &tar.Header{
+		Name:     name,
+		Typeflag: tar.TypeReg,
+		Mode:     0644,
+		Size:     int64(buf.Len()),
+		ModTime:  time.Now(),
+	}
+
+	if err := ctw.tarWriter.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("failed to write tar header: %s", err)
+	}
+
+	if _, err := io.Copy(ctw.tarWriter, &buf); err != nil {
+		return fmt.Errorf("failed to write checksums: %s", err)
 	}
 
 	return nil
@@ -92,7 +92,8 @@ func NetnsGetifaddrs(initPID int32) (map[string]api.ContainerStateNetwork, error
 		addNetwork.Type = netType
 		addNetwork.Mtu = int(addr.ifa_mtu)
 
-		if initPID != 0 && int(addr.ifa_ifindex_peer) > 0 {
+		// The peer index only resolves from the host network namespace.
+		if initPID > 0 && int(addr.ifa_ifindex_peer) > 0 {
 			hostInterface, err := net.InterfaceByIndex(int(addr.ifa_ifindex_peer))
 			if err == nil {
 				addNetwork.HostName = hostInterface.Name
@@ -163,6 +164,10 @@ func NetnsGetifaddrs(initPID int32) (map[string]api.ContainerStateNetwork, error
 			addNetwork.Counters.BytesSent = int64(addr.ifa_stats64.tx_bytes)
 			addNetwork.Counters.PacketsReceived = int64(addr.ifa_stats64.rx_packets)
 			addNetwork.Counters.PacketsSent = int64(addr.ifa_stats64.tx_packets)
+			addNetwork.Counters.ErrorsReceived = int64(addr.ifa_stats64.rx_errors)
+			addNetwork.Counters.ErrorsSent = int64(addr.ifa_stats64.tx_errors)
+			addNetwork.Counters.PacketsDroppedInbound = int64(addr.ifa_stats64.rx_dropped)
+			addNetwork.Counters.PacketsDroppedOutbound = int64(addr.ifa_stats64.tx_dropped)
 		}
 		ifName := C.GoString(addr.ifa_name)
 
@@ -164,6 +164,103 @@ var APIExtensions = []string{
 	"storage_shifted",
 	"resources_infiniband",
 	"daemon_storage",
+	"metadata_configuration",
+	"container_network_conntrack",
+	"image_publish_profile",
+	"daemon_health",
+	"container_nic_dns_name",
+	"container_cpu_burst",
+	"integrity_checksums",
+	"emergency_shutdown",
+	"container_net_sysctl",
+	"storage_dir_dedup",
+	"database_backups",
+	"devlxd_management",
+	"container_device_log",
+	"container_extra_mounts",
+	"profile_rolling_restart",
+	"load_balancers",
+	"container_exec_sessions",
+	"maas_reconcile",
+	"container_image_follow",
+	"container_nesting_cgroups",
+	"storage_pool_operations_limit",
+	"container_disk_usage_warning",
+	"container_apply",
+	"container_watchdog",
+	"container_files_sync",
+	"container_entropy",
+	"search",
+	"container_tasks",
+	"projects_storage_pools",
+	"event_resources",
+	"container_firewall_persist",
+	"container_security_test",
+	"container_security_denials",
+	"container_exec_sessions_limit",
+	"network_external",
+	"container_respawn",
+	"container_host_pidns_view",
+	"container_revisions",
+	"network_topology",
+	"container_restricted_view",
+	"container_resume_hooks",
+	"migration_hooks",
+	"image_delta_stats",
+	"container_nesting_profile",
+	"container_activity",
+	"projects_restricted",
+	"images_catalog",
+	"container_hugepages_disk",
+	"container_monitor",
+	"container_config_snapshots",
+	"container_device_claims",
+	"container_network_reapply",
+	"oidc",
+	"container_rebuild",
+	"container_cpu_numa_nodes",
+	"container_rebuild_idmap",
+	"cgroup_v2",
+	"container_network_limits",
+	"container_freeze_timeout",
+	"image_delta",
+	"device_hotplug",
+	"event_console",
+	"container_stateful_pre_dump",
+	"disk_device_size",
+	"container_disk_latency",
+	"container_exec_record",
+	"migration_freeze_sync",
+	"container_syscalls_log",
+	"container_nic_raw_lxc",
+	"container_render_workers",
+	"container_copy_cow",
+	"container_idmapped_mounts",
+	"container_core_scheduling",
+	"container_backup_compression",
+	"container_secrets",
+	"container_nic_index",
+	"container_oom_events",
+	"project_defaults",
+	"gpu_mdev",
+	"container_network_link_state",
+	"container_hooks",
+	"nic_vrf",
+	"container_protection_start",
+	"container_start_error",
+	"disk_io_threads",
+	"container_checkpoint_tags",
+	"container_operation_timeout",
+	"apparmor_profiles",
+	"container_backup_chunks",
+	"ephemeral_cleanup",
+	"storage_pool_overcommit",
+	"container_cpu_usage_breakdown",
+	"container_nic_bonded",
+	"metrics",
+	"container_time_namespace",
+	"container_tpm",
+	"container_sysctl",
 }
 
 // APIExtensionsCount returns the number of available API extensions.